@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type DKIMKey struct {
+	Selector    string     `json:"selector"`
+	Algorithm   string     `json:"algorithm"`
+	Status      string     `json:"status"`
+	DNSName     string     `json:"dns_name"`
+	DNSValue    string     `json:"dns_value"`
+	CreatedAt   time.Time  `json:"created_at"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	ActivatedAt *time.Time `json:"activated_at"`
+	RetireAt    *time.Time `json:"retire_at"`
+}
+
+func handleDKIM(args []string) {
+	if len(args) < 2 {
+		fmt.Println("Usage: dkim <list|rotate|verify|activate|delete> <domain> [selector]")
+		return
+	}
+
+	subcmd, domain := args[0], args[1]
+	switch subcmd {
+	case "list":
+		listDKIMKeys(domain)
+	case "rotate":
+		rotateDKIMKey(domain, args[2:])
+	case "verify", "activate", "delete":
+		if len(args) < 3 {
+			fmt.Printf("Usage: dkim %s <domain> <selector>\n", subcmd)
+			return
+		}
+		switch subcmd {
+		case "verify":
+			verifyDKIMKey(domain, args[2])
+		case "activate":
+			activateDKIMKey(domain, args[2], args[3:])
+		case "delete":
+			deleteDKIMKey(domain, args[2])
+		}
+	default:
+		fmt.Printf("Unknown dkim command: %s\n", subcmd)
+	}
+}
+
+func dkimPath(domain string, parts ...string) string {
+	path := "/domains/" + url.PathEscape(domain) + "/dkim"
+	for _, p := range parts {
+		path += "/" + url.PathEscape(p)
+	}
+	return path
+}
+
+func listDKIMKeys(domain string) {
+	resp, err := apiRequest("GET", dkimPath(domain), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("API Error: Status %s\n", resp.Status)
+		return
+	}
+
+	var list struct {
+		Keys []DKIMKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+
+	fmt.Printf("%-20s %-8s %-9s %-20s %-20s\n", "SELECTOR", "ALG", "STATUS", "CREATED", "RETIRES")
+	fmt.Println("--------------------------------------------------------------------------------")
+	for _, k := range list.Keys {
+		retires := ""
+		if k.RetireAt != nil {
+			retires = k.RetireAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-20s %-8s %-9s %-20s %-20s\n", k.Selector, k.Algorithm, k.Status, k.CreatedAt.Local().Format("2006-01-02 15:04:05"), retires)
+	}
+}
+
+func rotateDKIMKey(domain string, args []string) {
+	fs := flag.NewFlagSet("dkim rotate", flag.ExitOnError)
+	algorithm := fs.String("algorithm", "rsa", "Key algorithm (rsa or ed25519)")
+	//nolint:errcheck // ExitOnError
+	_ = fs.Parse(args)
+
+	resp, err := apiRequest("POST", dkimPath(domain), map[string]string{"algorithm": *algorithm})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		fmt.Printf("Failed: Status %s\n", resp.Status)
+		return
+	}
+
+	var k DKIMKey
+	if err := json.NewDecoder(resp.Body).Decode(&k); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+	fmt.Printf("Generated %s key with selector %s. Publish this TXT record:\n\n", k.Algorithm, k.Selector)
+	fmt.Printf("  %s. IN TXT \"%s\"\n\n", k.DNSName, k.DNSValue)
+	fmt.Printf("Then run: mailraven-cli dkim activate %s %s\n", domain, k.Selector)
+}
+
+func verifyDKIMKey(domain, selector string) {
+	resp, err := apiRequest("POST", dkimPath(domain, selector, "verify"), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		printDKIMError(resp.Status, resp.Body)
+		return
+	}
+	fmt.Printf("DNS record for %s is published.\n", selector)
+}
+
+func activateDKIMKey(domain, selector string, args []string) {
+	fs := flag.NewFlagSet("dkim activate", flag.ExitOnError)
+	grace := fs.Duration("grace", 0, "How long the replaced key stays published (default 168h)")
+	skipDNS := fs.Bool("skip-dns-check", false, "Activate without confirming the DNS record")
+	//nolint:errcheck // ExitOnError
+	_ = fs.Parse(args)
+
+	req := map[string]interface{}{"skip_dns_check": *skipDNS}
+	if *grace > 0 {
+		req["grace_period"] = grace.String()
+	}
+
+	resp, err := apiRequest("POST", dkimPath(domain, selector, "activate"), req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		printDKIMError(resp.Status, resp.Body)
+		return
+	}
+	fmt.Printf("Mail from %s is now signed with selector %s.\n", domain, selector)
+}
+
+func deleteDKIMKey(domain, selector string) {
+	resp, err := apiRequest("DELETE", dkimPath(domain, selector), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 204 {
+		fmt.Printf("Key %s deleted. Remove its DNS record.\n", selector)
+	} else {
+		fmt.Printf("Failed: Status %s\n", resp.Status)
+	}
+}
+
+// printDKIMError shows the server's explanation, e.g. why a DNS check failed
+func printDKIMError(status string, body io.Reader) {
+	msg, _ := io.ReadAll(body)
+	fmt.Printf("Failed: Status %s: %s\n", status, strings.TrimSpace(string(msg)))
+}
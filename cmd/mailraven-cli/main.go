@@ -40,6 +40,10 @@ func main() {
 		handleUsers(subArgs)
 	case "system":
 		handleSystem(subArgs)
+	case "queue":
+		handleQueue(subArgs)
+	case "dkim":
+		handleDKIM(subArgs)
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		usage()
@@ -49,7 +53,7 @@ func main() {
 
 func usage() {
 	fmt.Println("Usage: mailraven-cli [flags] <command> <subcommand> [args]")
-	fmt.Println("Commands: users, system")
+	fmt.Println("Commands: users, system, queue, dkim")
 	fmt.Println("Flags:")
 	flag.PrintDefaults()
 }
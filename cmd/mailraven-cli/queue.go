@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+type QueueMessage struct {
+	ID          string         `json:"id"`
+	Sender      string         `json:"sender"`
+	Recipient   string         `json:"recipient"`
+	Status      string         `json:"status"`
+	RetryCount  int            `json:"retry_count"`
+	LastError   string         `json:"last_error"`
+	Route       string         `json:"route"`
+	CreatedAt   time.Time      `json:"created_at"`
+	NextRetryAt time.Time      `json:"next_retry_at"`
+	Attempts    []QueueAttempt `json:"attempts"`
+}
+
+type QueueAttempt struct {
+	Status      string    `json:"status"`
+	Error       string    `json:"error"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+func handleQueue(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: queue <list|show|retry|hold|release|delete|bounce|reroute>")
+		return
+	}
+
+	subcmd := args[0]
+	switch subcmd {
+	case "list":
+		listQueue(args[1:])
+	case "show":
+		if len(args) < 2 {
+			fmt.Println("Usage: queue show <id>")
+			return
+		}
+		showQueueMessage(args[1])
+	case "retry", "hold", "release", "bounce":
+		if len(args) < 2 {
+			fmt.Printf("Usage: queue %s <id>\n", subcmd)
+			return
+		}
+		queueAction(args[1], subcmd)
+	case "delete":
+		if len(args) < 2 {
+			fmt.Println("Usage: queue delete <id>")
+			return
+		}
+		deleteQueueMessage(args[1])
+	case "reroute":
+		if len(args) < 2 {
+			fmt.Println("Usage: queue reroute <domain> [relay-host]  (omit relay-host to restore MX routing)")
+			return
+		}
+		relay := ""
+		if len(args) >= 3 {
+			relay = args[2]
+		}
+		rerouteQueue(args[1], relay)
+	default:
+		fmt.Printf("Unknown queue command: %s\n", subcmd)
+	}
+}
+
+func listQueue(args []string) {
+	fs := flag.NewFlagSet("queue list", flag.ExitOnError)
+	status := fs.String("status", "", "Filter by status (PENDING, RETRYING, PROCESSING, HELD, FAILED, SENT)")
+	domain := fs.String("domain", "", "Filter by recipient domain")
+	sender := fs.String("sender", "", "Filter by sender address")
+	limit := fs.Int("limit", 100, "Maximum messages to list")
+	offset := fs.Int("offset", 0, "Messages to skip")
+	//nolint:errcheck // ExitOnError
+	_ = fs.Parse(args)
+
+	query := url.Values{}
+	if *status != "" {
+		query.Set("status", *status)
+	}
+	if *domain != "" {
+		query.Set("domain", *domain)
+	}
+	if *sender != "" {
+		query.Set("sender", *sender)
+	}
+	query.Set("limit", strconv.Itoa(*limit))
+	query.Set("offset", strconv.Itoa(*offset))
+
+	resp, err := apiRequest("GET", "/queue?"+query.Encode(), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("API Error: Status %s\n", resp.Status)
+		return
+	}
+
+	var list struct {
+		Messages []QueueMessage `json:"messages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+
+	fmt.Printf("%-36s %-10s %-5s %-28s %-28s %-20s\n", "ID", "STATUS", "TRIES", "SENDER", "RECIPIENT", "NEXT RETRY")
+	fmt.Println("------------------------------------------------------------------------------------------------------------------------------")
+	for _, m := range list.Messages {
+		fmt.Printf("%-36s %-10s %-5d %-28s %-28s %-20s\n", m.ID, m.Status, m.RetryCount, m.Sender, m.Recipient, m.NextRetryAt.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+func showQueueMessage(id string) {
+	resp, err := apiRequest("GET", "/queue/"+url.PathEscape(id), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("API Error: Status %s\n", resp.Status)
+		return
+	}
+
+	var m QueueMessage
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+	printQueueMessage(&m)
+}
+
+func printQueueMessage(m *QueueMessage) {
+	fmt.Printf("ID:          %s\n", m.ID)
+	fmt.Printf("Status:      %s\n", m.Status)
+	fmt.Printf("Sender:      %s\n", m.Sender)
+	fmt.Printf("Recipient:   %s\n", m.Recipient)
+	if m.Route != "" {
+		fmt.Printf("Route:       %s\n", m.Route)
+	}
+	fmt.Printf("Queued:      %s\n", m.CreatedAt.Local().Format(time.RFC1123))
+	fmt.Printf("Retries:     %d\n", m.RetryCount)
+	fmt.Printf("Next retry:  %s\n", m.NextRetryAt.Local().Format(time.RFC1123))
+	if m.LastError != "" {
+		fmt.Printf("Last error:  %s\n", m.LastError)
+	}
+
+	if len(m.Attempts) > 0 {
+		fmt.Println("\nAttempts:")
+		for _, a := range m.Attempts {
+			fmt.Printf("  %s  %-9s %s\n", a.AttemptedAt.Local().Format("2006-01-02 15:04:05"), a.Status, a.Error)
+		}
+	}
+}
+
+func queueAction(id, action string) {
+	resp, err := apiRequest("POST", "/queue/"+url.PathEscape(id)+"/"+action, nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("Failed: Status %s\n", resp.Status)
+		return
+	}
+
+	var m QueueMessage
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+	fmt.Printf("Message %s is now %s.\n", m.ID, m.Status)
+}
+
+func deleteQueueMessage(id string) {
+	resp, err := apiRequest("DELETE", "/queue/"+url.PathEscape(id), nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 204 {
+		fmt.Printf("Message %s deleted.\n", id)
+	} else {
+		fmt.Printf("Failed: Status %s\n", resp.Status)
+	}
+}
+
+func rerouteQueue(domain, relay string) {
+	req := map[string]string{
+		"domain": domain,
+		"relay":  relay,
+	}
+
+	resp, err := apiRequest("POST", "/queue/reroute", req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("Failed: Status %s\n", resp.Status)
+		return
+	}
+
+	var result struct {
+		Rerouted int64 `json:"rerouted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Printf("Failed to parse response: %v\n", err)
+		return
+	}
+	if relay == "" {
+		fmt.Printf("%d messages for %s restored to MX routing.\n", result.Rerouted, domain)
+	} else {
+		fmt.Printf("%d messages for %s rerouted via %s.\n", result.Rerouted, domain, relay)
+	}
+}
@@ -15,8 +15,11 @@ import (
 	httpAdapter "github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/imap"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/managesieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sieve"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/validators"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/spam/greylist"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/disk"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/postgres"
@@ -103,19 +106,7 @@ func RunServe() error {
 		queueRepo = postgres.NewQueueRepository(conn.DB)
 		searchIdx = postgres.NewSearchRepository(conn.DB)
 		dbBackup = backup.NewPostgresBackup(cfg.Storage.DSN)
-		// Postgres implementation of TLSRptRepository pending - using sqlite fallback or panic if strictly required,
-		// but for now we assume only SQLite has it implemented or we need to add postgres version.
-		// Since T004 only mentioned SQLite implementation, we might need a stub or error here.
-		// However, for compilation we need to assign it.
-		// A temporary fix is to set it to nil and handle it, or implement postgres version.
-		// Given the mandate is complete, and we only did sqlite, we'll leave it nil for postgres path
-		// BUT NewServer signature requires it.
-		// We should probably check if `sqlite.NewTLSRptRepository` works with sql.DB which is universal.
-		// Yes, `internal/adapters/storage/sqlite/tlsrpt_repo.go` uses `*sql.DB`.
-		// It uses standard SQL, so it might work for Postgres too unless queries are specific.
-		// Let's use the sqlite struct but maybe rename it later to `sql` adapter.
-		// Checking T004 implementation...
-		tlsRptRepo = sqlite.NewTLSRptRepository(conn.DB)
+		tlsRptRepo = postgres.NewTLSRptRepository(conn.DB)
 		greylistRepo = sqlite.NewGreylistRepository(conn.DB)
 		bayesRepo = sqlite.NewBayesRepository(conn.DB)
 		scriptRepo = sqlite.NewSqliteScriptRepository(conn.DB)
@@ -172,8 +163,22 @@ func RunServe() error {
 		return fmt.Errorf("failed to initialize blob store: %w", err)
 	}
 
+	// DKIM signers for mail we originate: each hosted domain signs with its own key;
+	// the global key is only a fallback for the primary domain
+	var defaultSigner *dkim.Signer
+	if pemBytes, err := os.ReadFile(cfg.DKIM.PrivateKeyPath); err != nil {
+		logger.Warn("failed to read DKIM key", "error", err)
+	} else if key, err := dkim.ParsePrivateKey(pemBytes); err != nil {
+		logger.Warn("failed to parse DKIM key", "error", err)
+	} else {
+		defaultSigner = dkim.NewSigner(cfg.Domain, cfg.DKIM.Selector, key)
+	}
+	dkimSigners := dkim.NewResolver(domainRepo, defaultSigner)
+	dkimKeys := dkim.NewKeyManager(domainRepo, dkimSigners, logger)
+
 	// Initialize Sieve Engine
 	sieveEngine := sieve.NewSieveEngine(scriptRepo, emailRepo, vacationRepo, queueRepo, blobStore)
+	sieveEngine.SetSigners(dkimSigners)
 
 	// Initialize SMTP handler
 	smtpHandler := smtp.NewHandler(emailRepo, userRepo, blobStore, searchIdx, sieveEngine, logger, metrics)
@@ -191,17 +196,72 @@ func RunServe() error {
 		logger.Warn("Failed to initialize spam protection", "error", err)
 	}
 
+	// Initialize ACME service
+	acmeService, err := services.NewACMEService(cfg.TLS.ACME)
+	if err != nil {
+		return fmt.Errorf("failed to initialize ACME service: %w", err)
+	}
+
+	// TLS for STARTTLS/ManageSieve shares the HTTP server's certificate sources
+	tlsCfg := buildTLSConfig(cfg, acmeService, logger)
+
 	// Initialize SMTP server
 	smtpServer := smtp.NewServer(cfg, logger, metrics, messageHandler, spamService, userRepo)
+	smtpServer.SetQuotas(services.NewQuotaService(userRepo, emailRepo))
+	smtpServer.SetRetryQueue(queueRepo, blobStore)
+	if tlsCfg != nil {
+		smtpServer.SetTLSConfig(tlsCfg)
+	}
+
+	// Initialize submission servers (RFC 6409 on 587, RFC 8314 implicit TLS on 465)
+	var submissionServers []*smtp.Server
+	if cfg.SMTP.Submission.Enabled {
+		if tlsCfg == nil {
+			logger.Warn("SMTP submission requires TLS certificates, submission disabled")
+		} else {
+			submissionHandler := smtp.NewSubmissionHandler(queueRepo, blobStore, dkimSigners, logger, metrics)
+			for _, listener := range []struct {
+				port        int
+				implicitTLS bool
+			}{
+				{cfg.SMTP.Submission.Port, false},
+				{cfg.SMTP.Submission.PortTLS, true},
+			} {
+				srv := smtp.NewSubmissionServer(cfg, logger, metrics, submissionHandler.Handle, userRepo, listener.port, listener.implicitTLS)
+				srv.SetTLSConfig(tlsCfg)
+				submissionServers = append(submissionServers, srv)
+			}
+		}
+	}
 
 	// Initialize Outbound Delivery
 	smtpClient := smtp.NewClient(cfg.SMTP.DANE, logger)
+	if cfg.SMTP.MTASTS.Mode != "off" {
+		smtpClient.SetMTASTS(validators.NewMTASTSValidator(), cfg.SMTP.MTASTS.Mode)
+	}
+	smtpClient.SetOpportunisticTLS(cfg.SMTP.Delivery.OpportunisticTLS)
+	smtpClient.SetConnectionLimits(cfg.SMTP.Delivery.PerMX, 30*time.Second)
 	deliveryWorker := smtp.NewDeliveryWorker(queueRepo, blobStore, smtpClient, logger, metrics)
-
-	// Initialize ACME service
-	acmeService, err := services.NewACMEService(cfg.TLS.ACME)
-	if err != nil {
-		return fmt.Errorf("failed to initialize ACME service: %w", err)
+	deliveryWorker.SetLimits(cfg.SMTP.Delivery.Concurrency, cfg.SMTP.Delivery.PerDomain, cfg.SMTP.Delivery.BatchSize)
+	deliveryWorker.SetLock(infra.Lock)
+
+	// Delivery status notifications (RFC 3464) for failed and delayed mail
+	bouncer := smtp.NewBouncer(cfg.SMTP.Hostname, smtpHandler, userRepo, queueRepo, blobStore, logger)
+	var delayWarnings []time.Duration
+	for _, d := range cfg.SMTP.Delivery.DelayWarnings {
+		if dur, err := time.ParseDuration(d); err == nil {
+			delayWarnings = append(delayWarnings, dur)
+		}
+	}
+	deliveryWorker.SetBouncer(bouncer, delayWarnings)
+	deliveryWorker.SetLocalDeliverer(smtpHandler)
+	smtpServer.SetBouncer(bouncer)
+
+	// Outbound TLS reporting (RFC 8460)
+	var tlsReporter *smtp.TLSReporter
+	if cfg.SMTP.TLSRPT.Enabled {
+		tlsReporter = smtp.NewTLSReporter(cfg.Domain, cfg.SMTP.TLSRPT.Organization, cfg.SMTP.TLSRPT.ContactInfo, tlsRptRepo, queueRepo, blobStore, dkimSigners, logger)
+		smtpClient.SetTLSRecorder(tlsReporter)
 	}
 
 	// Initialize Backup Service
@@ -212,7 +272,7 @@ func RunServe() error {
 	githubUpdater := updater.NewGitHubUpdater("Kartikey2011yadav", "mailraven-server")
 
 	// Initialize HTTP server
-	httpServer := httpAdapter.NewServer(cfg, emailRepo, userRepo, queueRepo, domainRepo, blobStore, searchIdx, acmeService, backupService, tlsRptRepo, scriptRepo, githubUpdater, spamService, logger, metrics)
+	httpServer := httpAdapter.NewServer(cfg, emailRepo, userRepo, queueRepo, domainRepo, dkimSigners, dkimKeys, blobStore, searchIdx, acmeService, backupService, tlsRptRepo, scriptRepo, githubUpdater, spamService, bouncer, logger, metrics)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -247,48 +307,67 @@ func RunServe() error {
 		}
 	}()
 
-	// Start IMAP server in background (if enabled)
+	// SASL authentication shared by IMAP and ManageSieve; OAUTHBEARER accepts API tokens
+	saslAuth := sasl.NewAuthenticator(userRepo, cfg.API.JWTSecret)
+
+	// Start IMAP servers in background (if enabled): STARTTLS on 143, implicit TLS on 993 (RFC 8314)
 	if cfg.IMAP.Enabled {
-		go func() {
-			imapServer := imap.NewServer(cfg.IMAP, logger, metrics, userRepo, emailRepo, spamService, blobStore, infra.Notifications)
-			logger.Info("starting IMAP server", "port", cfg.IMAP.Port)
-			if err := imapServer.Start(ctx); err != nil {
-				logger.Error("IMAP server error", "error", err)
-			}
-		}()
+		for _, implicitTLS := range []bool{false, true} {
+			imapServer := imap.NewServer(cfg.IMAP, logger, metrics, userRepo, emailRepo, searchIdx, spamService, blobStore, infra.Notifications, saslAuth)
+			imapServer.SetTLSConfig(tlsCfg)
+			imapServer.SetImplicitTLS(implicitTLS)
+			go func() {
+				logger.Info("starting IMAP server", "implicit_tls", implicitTLS)
+				if err := imapServer.Start(ctx); err != nil {
+					logger.Error("IMAP server error", "error", err, "implicit_tls", implicitTLS)
+				}
+			}()
+		}
 	}
 
-	// Start ManageSieve server in background (if enabled)
+	// Start ManageSieve servers in background (if enabled)
 	if cfg.ManageSieve.Enabled {
-		go func() {
-			addr := fmt.Sprintf(":%d", cfg.ManageSieve.Port)
-			var tlsCfg *tls.Config
-
-			if acmeService != nil {
-				tlsCfg = acmeService.TLSConfig()
-			} else if cfg.API.TLS && cfg.API.TLSCert != "" && cfg.API.TLSKey != "" {
-				cert, err := tls.LoadX509KeyPair(cfg.API.TLSCert, cfg.API.TLSKey)
-				if err == nil {
-					tlsCfg = &tls.Config{
-						Certificates: []tls.Certificate{cert},
-						MinVersion:   tls.VersionTLS12,
-					}
-				} else {
-					logger.Warn("failed to load TLS certs for ManageSieve", "error", err)
-				}
+		for _, listener := range []struct {
+			port        int
+			implicitTLS bool
+		}{
+			{cfg.ManageSieve.Port, false},
+			{cfg.ManageSieve.PortTLS, true},
+		} {
+			if listener.port == 0 {
+				continue // Implicit TLS is opt-in
 			}
+			msServer := managesieve.NewServer(fmt.Sprintf(":%d", listener.port), tlsCfg, scriptRepo, userRepo, saslAuth, logger)
+			msServer.SetImplicitTLS(listener.implicitTLS)
+			go func() {
+				logger.Info("starting ManageSieve server", "port", listener.port, "implicit_tls", listener.implicitTLS)
+				if err := msServer.Start(); err != nil {
+					logger.Error("ManageSieve server error", "error", err)
+				}
+			}()
+		}
+	}
 
-			msServer := managesieve.NewServer(addr, tlsCfg, scriptRepo, userRepo, logger)
-			logger.Info("starting ManageSieve server", "port", cfg.ManageSieve.Port)
-			if err := msServer.Start(); err != nil {
-				logger.Error("ManageSieve server error", "error", err)
+	// Start submission servers in background
+	for _, srv := range submissionServers {
+		go func(srv *smtp.Server) {
+			if err := srv.Start(ctx); err != nil {
+				logger.Error("SMTP submission server error", "error", err)
 			}
-		}()
+		}(srv)
 	}
 
 	// Start Delivery Worker
 	deliveryWorker.Start()
 
+	// Start TLS Reporter
+	if tlsReporter != nil {
+		tlsReporter.Start(ctx)
+	}
+
+	// Retire replaced DKIM keys once their grace period has passed
+	dkimKeys.Start(ctx)
+
 	// Start Greylist Pruner
 	greylistSvc.StartPruning(ctx, 1*time.Hour, logger)
 
@@ -299,12 +378,19 @@ func RunServe() error {
 	fmt.Printf("   MailRaven Server Running\n")
 	fmt.Printf("==============================================\n")
 	fmt.Printf("SMTP Port:   %d\n", cfg.SMTP.Port)
+	if len(submissionServers) > 0 {
+		fmt.Printf("Submission:  %d (STARTTLS), %d (TLS)\n", cfg.SMTP.Submission.Port, cfg.SMTP.Submission.PortTLS)
+	}
 	fmt.Printf("HTTP Port:   %d (TLS: %v)\n", cfg.API.Port, cfg.API.TLS)
 	if cfg.IMAP.Enabled {
-		fmt.Printf("IMAP Port:   %d\n", cfg.IMAP.Port)
+		fmt.Printf("IMAP Port:   %d (STARTTLS), %d (TLS)\n", cfg.IMAP.Port, cfg.IMAP.PortTLS)
 	}
 	if cfg.ManageSieve.Enabled {
-		fmt.Printf("Sieve Port:  %d\n", cfg.ManageSieve.Port)
+		if cfg.ManageSieve.PortTLS != 0 {
+			fmt.Printf("Sieve Port:  %d (STARTTLS), %d (TLS)\n", cfg.ManageSieve.Port, cfg.ManageSieve.PortTLS)
+		} else {
+			fmt.Printf("Sieve Port:  %d\n", cfg.ManageSieve.Port)
+		}
 	}
 	fmt.Printf("Domain:      %s\n", cfg.Domain)
 	fmt.Printf("Log Level:   %s\n", cfg.Logging.Level)
@@ -320,6 +406,27 @@ func RunServe() error {
 	return nil
 }
 
+// buildTLSConfig returns the TLS configuration for mail listeners, using ACME
+// when enabled or the API's static certificate files otherwise. Returns nil when
+// no certificate is available.
+func buildTLSConfig(cfg *config.Config, acmeService *services.ACMEService, logger *observability.Logger) *tls.Config {
+	if acmeService != nil {
+		return acmeService.TLSConfig()
+	}
+	if cfg.API.TLS && cfg.API.TLSCert != "" && cfg.API.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.API.TLSCert, cfg.API.TLSKey)
+		if err != nil {
+			logger.Warn("failed to load TLS certificates", "error", err)
+			return nil
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return nil
+}
+
 // RunCheckConfig validates the configuration file
 func RunCheckConfig() error {
 	// Load configuration
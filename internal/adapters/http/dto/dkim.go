@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// DKIMKey represents a domain's DKIM key and the DNS record to publish for it
+type DKIMKey struct {
+	Selector    string     `json:"selector"`
+	Algorithm   string     `json:"algorithm"`
+	Status      string     `json:"status"`
+	DNSName     string     `json:"dns_name"`
+	DNSValue    string     `json:"dns_value"`
+	CreatedAt   time.Time  `json:"created_at"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	RetireAt    *time.Time `json:"retire_at,omitempty"`
+}
+
+// DKIMKeyListResponse for GET /v1/admin/domains/{domain}/dkim
+type DKIMKeyListResponse struct {
+	Domain string    `json:"domain"`
+	Keys   []DKIMKey `json:"keys"`
+}
+
+// RotateDKIMKeyRequest for POST /v1/admin/domains/{domain}/dkim
+type RotateDKIMKeyRequest struct {
+	Algorithm string `json:"algorithm"` // "rsa" or "ed25519"
+}
+
+// ActivateDKIMKeyRequest for POST /v1/admin/domains/{domain}/dkim/{selector}/activate
+type ActivateDKIMKeyRequest struct {
+	GracePeriod  string `json:"grace_period,omitempty"` // Go duration the replaced key stays published, e.g. "168h"
+	SkipDNSCheck bool   `json:"skip_dns_check,omitempty"`
+}
+
+// ToDKIMKey converts domain.DKIMKey to DTO. The private key is never exposed.
+func ToDKIMKey(k *domain.DKIMKey) DKIMKey {
+	key := DKIMKey{
+		Selector:  k.Selector,
+		Algorithm: k.Algorithm,
+		Status:    string(k.Status),
+		DNSName:   k.DNSName(),
+		DNSValue:  k.DNSValue(),
+		CreatedAt: k.CreatedAt,
+	}
+	if !k.VerifiedAt.IsZero() {
+		key.VerifiedAt = &k.VerifiedAt
+	}
+	if !k.ActivatedAt.IsZero() {
+		key.ActivatedAt = &k.ActivatedAt
+	}
+	if !k.RetireAt.IsZero() {
+		key.RetireAt = &k.RetireAt
+	}
+	return key
+}
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// QueueMessage represents an outbound queue entry for admin views
+type QueueMessage struct {
+	ID          string    `json:"id"`
+	Sender      string    `json:"sender"`
+	Recipient   string    `json:"recipient"`
+	Status      string    `json:"status"`
+	RetryCount  int       `json:"retry_count"`
+	LastError   string    `json:"last_error,omitempty"`
+	Route       string    `json:"route,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	NextRetryAt time.Time `json:"next_retry_at"`
+}
+
+// QueueAttempt is one entry of a queue message's retry history
+type QueueAttempt struct {
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// QueueMessageDetail for GET /v1/admin/queue/{id}
+type QueueMessageDetail struct {
+	QueueMessage
+	Attempts []QueueAttempt `json:"attempts"`
+}
+
+// QueueListResponse for GET /v1/admin/queue
+type QueueListResponse struct {
+	Messages []QueueMessage `json:"messages"`
+	Limit    int            `json:"limit"`
+	Offset   int            `json:"offset"`
+}
+
+// RerouteRequest for POST /v1/admin/queue/reroute
+type RerouteRequest struct {
+	Domain string `json:"domain"`
+	Relay  string `json:"relay"` // Empty restores MX routing
+}
+
+// RerouteResponse reports how many messages were rerouted
+type RerouteResponse struct {
+	Domain   string `json:"domain"`
+	Relay    string `json:"relay"`
+	Rerouted int64  `json:"rerouted"`
+}
+
+// ToQueueMessage converts domain.OutboundMessage to DTO
+func ToQueueMessage(msg *domain.OutboundMessage) QueueMessage {
+	return QueueMessage{
+		ID:          msg.ID,
+		Sender:      msg.Sender,
+		Recipient:   msg.Recipient,
+		Status:      string(msg.Status),
+		RetryCount:  msg.RetryCount,
+		LastError:   msg.LastError,
+		Route:       msg.Route,
+		CreatedAt:   msg.CreatedAt,
+		UpdatedAt:   msg.UpdatedAt,
+		NextRetryAt: msg.NextRetryAt,
+	}
+}
+
+// ToQueueMessageDetail converts a queue entry and its retry history to DTO
+func ToQueueMessageDetail(msg *domain.OutboundMessage, attempts []*domain.DeliveryAttempt) QueueMessageDetail {
+	detail := QueueMessageDetail{
+		QueueMessage: ToQueueMessage(msg),
+		Attempts:     make([]QueueAttempt, 0, len(attempts)),
+	}
+	for _, a := range attempts {
+		detail.Attempts = append(detail.Attempts, QueueAttempt{
+			Status:      string(a.Status),
+			Error:       a.Error,
+			AttemptedAt: a.AttemptedAt,
+		})
+	}
+	return detail
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminDKIMHandler exposes the DKIM key rotation workflow of hosted domains to administrators
+type AdminDKIMHandler struct {
+	keys   *dkim.KeyManager
+	logger *observability.Logger
+}
+
+func NewAdminDKIMHandler(keys *dkim.KeyManager, logger *observability.Logger) *AdminDKIMHandler {
+	return &AdminDKIMHandler{keys: keys, logger: logger}
+}
+
+// ListKeys GET /api/v1/admin/domains/{domain}/dkim
+func (h *AdminDKIMHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "domain")
+	keys, err := h.keys.Keys(r.Context(), name)
+	if err != nil {
+		h.writeError(w, "Failed to list DKIM keys", err)
+		return
+	}
+
+	resp := dto.DKIMKeyListResponse{Domain: name, Keys: make([]dto.DKIMKey, 0, len(keys))}
+	for _, k := range keys {
+		resp.Keys = append(resp.Keys, dto.ToDKIMKey(k))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// RotateKey POST /api/v1/admin/domains/{domain}/dkim
+// Generates a pending key under a new selector; its DNS record must be published before activation.
+func (h *AdminDKIMHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	var req dto.RotateDKIMKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = domain.DKIMAlgorithmRSA
+	}
+	if req.Algorithm != domain.DKIMAlgorithmRSA && req.Algorithm != domain.DKIMAlgorithmEd25519 {
+		http.Error(w, "Algorithm must be rsa or ed25519", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.keys.Rotate(r.Context(), chi.URLParam(r, "domain"), req.Algorithm)
+	if err != nil {
+		h.writeError(w, "Failed to generate DKIM key", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(dto.ToDKIMKey(key))
+}
+
+// VerifyKey POST /api/v1/admin/domains/{domain}/dkim/{selector}/verify
+func (h *AdminDKIMHandler) VerifyKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.keys.VerifyDNS(r.Context(), chi.URLParam(r, "domain"), chi.URLParam(r, "selector"))
+	if err != nil {
+		h.writeError(w, "Failed to verify DKIM key", err)
+		return
+	}
+	h.writeKey(w, key)
+}
+
+// ActivateKey POST /api/v1/admin/domains/{domain}/dkim/{selector}/activate
+func (h *AdminDKIMHandler) ActivateKey(w http.ResponseWriter, r *http.Request) {
+	var req dto.ActivateDKIMKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		var err error
+		grace, err = time.ParseDuration(req.GracePeriod)
+		if err != nil || grace <= 0 {
+			http.Error(w, "Invalid grace_period", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, err := h.keys.Activate(r.Context(), chi.URLParam(r, "domain"), chi.URLParam(r, "selector"), grace, req.SkipDNSCheck)
+	if err != nil {
+		h.writeError(w, "Failed to activate DKIM key", err)
+		return
+	}
+	h.writeKey(w, key)
+}
+
+// DeleteKey DELETE /api/v1/admin/domains/{domain}/dkim/{selector}
+func (h *AdminDKIMHandler) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.keys.Delete(r.Context(), chi.URLParam(r, "domain"), chi.URLParam(r, "selector")); err != nil {
+		h.writeError(w, "Failed to delete DKIM key", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminDKIMHandler) writeKey(w http.ResponseWriter, key *domain.DKIMKey) {
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(dto.ToDKIMKey(key))
+}
+
+// writeError maps key manager errors to HTTP responses
+func (h *AdminDKIMHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		http.Error(w, "Domain or key not found", http.StatusNotFound)
+	case errors.Is(err, dkim.ErrKeyState):
+		http.Error(w, "Action not allowed in the key's current state", http.StatusConflict)
+	case errors.Is(err, dkim.ErrDNSNotPublished):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		h.logger.Error(msg, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
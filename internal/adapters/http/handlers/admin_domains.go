@@ -1,14 +1,11 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"net/http"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
@@ -16,12 +13,13 @@ import (
 )
 
 type AdminDomainHandler struct {
-	repo   ports.DomainRepository
-	logger *observability.Logger
+	repo     ports.DomainRepository
+	dkimKeys *dkim.KeyManager // Creates and removes domains together with their DKIM keys
+	logger   *observability.Logger
 }
 
-func NewAdminDomainHandler(repo ports.DomainRepository, logger *observability.Logger) *AdminDomainHandler {
-	return &AdminDomainHandler{repo: repo, logger: logger}
+func NewAdminDomainHandler(repo ports.DomainRepository, dkimKeys *dkim.KeyManager, logger *observability.Logger) *AdminDomainHandler {
+	return &AdminDomainHandler{repo: repo, dkimKeys: dkimKeys, logger: logger}
 }
 
 type CreateDomainRequest struct {
@@ -54,25 +52,15 @@ func (h *AdminDomainHandler) CreateDomain(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Generate DKIM keys
-	privKey, pubKey, err := generateDKIMKeys()
-	if err != nil {
-		h.logger.Error("Failed to generate DKIM keys", "error", err)
-		http.Error(w, "Failed to generate DKIM keys", http.StatusInternalServerError)
-		return
-	}
-
 	d := &domain.Domain{
-		Name:           req.Name,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-		Active:         true,
-		DKIMSelector:   "default",
-		DKIMPrivateKey: privKey,
-		DKIMPublicKey:  pubKey,
+		Name:      req.Name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Active:    true,
 	}
 
-	if err := h.repo.Create(r.Context(), d); err != nil {
+	// Generate the domain's RSA and Ed25519 DKIM keys
+	if _, err := h.dkimKeys.Provision(r.Context(), d); err != nil {
 		if err == ports.ErrAlreadyExists {
 			http.Error(w, "Domain already exists", http.StatusConflict)
 			return
@@ -88,15 +76,15 @@ func (h *AdminDomainHandler) CreateDomain(w http.ResponseWriter, r *http.Request
 	_ = json.NewEncoder(w).Encode(d)
 }
 
-// DeleteDomain DELETE /api/v1/admin/domains/{name}
+// DeleteDomain DELETE /api/v1/admin/domains/{domain}
 func (h *AdminDomainHandler) DeleteDomain(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
+	name := chi.URLParam(r, "domain")
 	if name == "" {
 		http.Error(w, "Domain name required", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.repo.Delete(r.Context(), name); err != nil {
+	if err := h.dkimKeys.Deprovision(r.Context(), name); err != nil {
 		if err == ports.ErrNotFound {
 			http.Error(w, "Domain not found", http.StatusNotFound)
 			return
@@ -108,30 +96,3 @@ func (h *AdminDomainHandler) DeleteDomain(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusNoContent)
 }
-
-// Helper to generate 2048-bit RSA keys for DKIM
-func generateDKIMKeys() (string, string, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Private Key to PEM
-	privBytes := x509.MarshalPKCS1PrivateKey(key)
-	privPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: privBytes,
-	})
-
-	// Public Key to PEM (PKIX)
-	pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
-	if err != nil {
-		return "", "", err
-	}
-	pubPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: pubBytes,
-	})
-
-	return string(privPEM), string(pubPEM), nil
-}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+	"github.com/go-chi/chi/v5"
+)
+
+// adminBounceReason is the diagnostic of messages bounced from the admin API
+const adminBounceReason = "Message bounced by administrator"
+
+// AdminQueueHandler exposes outbound queue inspection and management to administrators
+type AdminQueueHandler struct {
+	queueRepo ports.QueueRepository
+	blobStore ports.BlobStore
+	bouncer   *smtp.Bouncer // Optional; sends the DSN for bounced messages
+	logger    *observability.Logger
+}
+
+func NewAdminQueueHandler(queueRepo ports.QueueRepository, blobStore ports.BlobStore, bouncer *smtp.Bouncer, logger *observability.Logger) *AdminQueueHandler {
+	return &AdminQueueHandler{queueRepo: queueRepo, blobStore: blobStore, bouncer: bouncer, logger: logger}
+}
+
+// ListQueue GET /api/v1/admin/queue?status=&domain=&sender=&limit=&offset=
+func (h *AdminQueueHandler) ListQueue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.QueueFilter{
+		Status: domain.OutboundStatus(strings.ToUpper(query.Get("status"))),
+		Domain: query.Get("domain"),
+		Sender: query.Get("sender"),
+		Limit:  100,
+	}
+	if l := query.Get("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 && val <= 1000 {
+			filter.Limit = val
+		}
+	}
+	if o := query.Get("offset"); o != "" {
+		if val, err := strconv.Atoi(o); err == nil && val >= 0 {
+			filter.Offset = val
+		}
+	}
+
+	msgs, err := h.queueRepo.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list queue", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := dto.QueueListResponse{
+		Messages: make([]dto.QueueMessage, 0, len(msgs)),
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	}
+	for _, msg := range msgs {
+		resp.Messages = append(resp.Messages, dto.ToQueueMessage(msg))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GetQueueMessage GET /api/v1/admin/queue/{id}
+func (h *AdminQueueHandler) GetQueueMessage(w http.ResponseWriter, r *http.Request) {
+	msg, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+	h.writeDetail(w, r, msg)
+}
+
+// RetryMessage POST /api/v1/admin/queue/{id}/retry
+// Schedules the message for immediate delivery, including permanently failed messages.
+func (h *AdminQueueHandler) RetryMessage(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, []domain.OutboundStatus{
+		domain.QueueStatusPending, domain.QueueStatusRetrying, domain.QueueStatusHeld, domain.QueueStatusFailed,
+	}, func(msg *domain.OutboundMessage) error {
+		return h.queueRepo.TransitionStatus(r.Context(), msg.ID, msg.Status, readyStatus(msg), msg.RetryCount, time.Now(), msg.LastError)
+	})
+}
+
+// HoldMessage POST /api/v1/admin/queue/{id}/hold
+func (h *AdminQueueHandler) HoldMessage(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, []domain.OutboundStatus{
+		domain.QueueStatusPending, domain.QueueStatusRetrying,
+	}, func(msg *domain.OutboundMessage) error {
+		return h.queueRepo.TransitionStatus(r.Context(), msg.ID, msg.Status, domain.QueueStatusHeld, msg.RetryCount, msg.NextRetryAt, msg.LastError)
+	})
+}
+
+// ReleaseMessage POST /api/v1/admin/queue/{id}/release
+func (h *AdminQueueHandler) ReleaseMessage(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, []domain.OutboundStatus{
+		domain.QueueStatusHeld,
+	}, func(msg *domain.OutboundMessage) error {
+		return h.queueRepo.TransitionStatus(r.Context(), msg.ID, msg.Status, readyStatus(msg), msg.RetryCount, time.Now(), msg.LastError)
+	})
+}
+
+// BounceMessage POST /api/v1/admin/queue/{id}/bounce
+// Fails the message permanently and notifies the sender with a DSN.
+func (h *AdminQueueHandler) BounceMessage(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, []domain.OutboundStatus{
+		domain.QueueStatusPending, domain.QueueStatusRetrying, domain.QueueStatusHeld,
+	}, func(msg *domain.OutboundMessage) error {
+		ctx := r.Context()
+		if err := h.queueRepo.TransitionStatus(ctx, msg.ID, msg.Status, domain.QueueStatusFailed, msg.RetryCount, time.Time{}, adminBounceReason); err != nil {
+			return err
+		}
+		if h.bouncer == nil {
+			return nil
+		}
+
+		content, err := h.blobStore.Read(ctx, msg.BlobKey)
+		if err != nil {
+			h.logger.Warn("Bounced message content unavailable", "id", msg.ID, "error", err)
+		}
+		if err := h.bouncer.Notify(ctx, msg, content, smtp.DSNActionFailed, adminBounceReason); err != nil {
+			h.logger.Error("Failed to send non-delivery report", "id", msg.ID, "error", err)
+		}
+		return nil
+	})
+}
+
+// DeleteMessage DELETE /api/v1/admin/queue/{id}
+func (h *AdminQueueHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	msg, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+	if msg.Status == domain.QueueStatusProcessing {
+		http.Error(w, "Message is being delivered", http.StatusConflict)
+		return
+	}
+
+	if err := h.queueRepo.Delete(r.Context(), msg.ID); err != nil {
+		if errors.Is(err, ports.ErrNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to delete queued message", "id", msg.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Queued message deleted by admin", "id", msg.ID, "recipient", msg.Recipient)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RerouteDomain POST /api/v1/admin/queue/reroute
+// Sends all undelivered messages for a domain through a relay host (empty relay restores MX routing).
+func (h *AdminQueueHandler) RerouteDomain(w http.ResponseWriter, r *http.Request) {
+	var req dto.RerouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Domain = strings.ToLower(strings.TrimSpace(req.Domain))
+	req.Relay = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(req.Relay), "."))
+	if req.Domain == "" {
+		http.Error(w, "Domain is required", http.StatusBadRequest)
+		return
+	}
+	if strings.ContainsAny(req.Relay, " /:@") {
+		http.Error(w, "Relay must be a hostname", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.queueRepo.Reroute(r.Context(), req.Domain, req.Relay)
+	if err != nil {
+		h.logger.Error("Failed to reroute queue", "domain", req.Domain, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Queue rerouted by admin", "domain", req.Domain, "relay", req.Relay, "count", count)
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(dto.RerouteResponse{Domain: req.Domain, Relay: req.Relay, Rerouted: count})
+}
+
+// transition applies an admin action to a message in one of the allowed states and
+// responds with the updated message
+func (h *AdminQueueHandler) transition(w http.ResponseWriter, r *http.Request, allowed []domain.OutboundStatus, apply func(msg *domain.OutboundMessage) error) {
+	msg, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	permitted := false
+	for _, status := range allowed {
+		if msg.Status == status {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		http.Error(w, "Action not allowed for a "+string(msg.Status)+" message", http.StatusConflict)
+		return
+	}
+
+	if err := apply(msg); err != nil {
+		switch {
+		case errors.Is(err, ports.ErrNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ports.ErrConflict):
+			// The delivery worker (or another admin) changed the message since it was loaded
+			http.Error(w, "Message status changed, please retry", http.StatusConflict)
+			return
+		}
+		h.logger.Error("Failed to update queued message", "id", msg.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.queueRepo.Get(r.Context(), msg.ID)
+	if err != nil {
+		h.logger.Error("Failed to reload queued message", "id", msg.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.writeDetail(w, r, updated)
+}
+
+func (h *AdminQueueHandler) loadMessage(w http.ResponseWriter, r *http.Request) (*domain.OutboundMessage, bool) {
+	msg, err := h.queueRepo.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, ports.ErrNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.logger.Error("Failed to get queued message", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return msg, true
+}
+
+func (h *AdminQueueHandler) writeDetail(w http.ResponseWriter, r *http.Request, msg *domain.OutboundMessage) {
+	attempts, err := h.queueRepo.ListAttempts(r.Context(), msg.ID)
+	if err != nil {
+		h.logger.Error("Failed to list delivery attempts", "id", msg.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(dto.ToQueueMessageDetail(msg, attempts))
+}
+
+// readyStatus is the status a message returns to when it is scheduled for delivery
+func readyStatus(msg *domain.OutboundMessage) domain.OutboundStatus {
+	if msg.RetryCount > 0 {
+		return domain.QueueStatusRetrying
+	}
+	return domain.QueueStatusPending
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminQuotaHandler manages the RFC 9208 quota roots of accounts, mailboxes and domains
+type AdminQuotaHandler struct {
+	quotaService *services.QuotaService
+	userRepo     ports.UserRepository
+	emailRepo    ports.EmailRepository
+	domainRepo   ports.DomainRepository
+	logger       *observability.Logger
+}
+
+func NewAdminQuotaHandler(quotaService *services.QuotaService, userRepo ports.UserRepository, emailRepo ports.EmailRepository, domainRepo ports.DomainRepository, logger *observability.Logger) *AdminQuotaHandler {
+	return &AdminQuotaHandler{quotaService: quotaService, userRepo: userRepo, emailRepo: emailRepo, domainRepo: domainRepo, logger: logger}
+}
+
+// UpdateQuotaRequest sets limits of a quota root. Omitted resources keep their limit and
+// 0 removes one.
+type UpdateQuotaRequest struct {
+	Quota     *int64 `json:"quota"`     // Storage in bytes; older name of storage
+	Storage   *int64 `json:"storage"`   // Bytes
+	Messages  *int64 `json:"messages"`  // Number of messages
+	Mailboxes *int64 `json:"mailboxes"` // Number of mailboxes; not for a single mailbox
+}
+
+// limits returns the limits set by the request, by resource
+func (req *UpdateQuotaRequest) limits() map[string]int64 {
+	limits := make(map[string]int64)
+	for resource, value := range map[string]*int64{
+		domain.QuotaStorage: req.Storage,
+		domain.QuotaMessage: req.Messages,
+		domain.QuotaMailbox: req.Mailboxes,
+	} {
+		if value != nil {
+			limits[resource] = *value
+		}
+	}
+	if _, ok := limits[domain.QuotaStorage]; !ok && req.Quota != nil {
+		limits[domain.QuotaStorage] = *req.Quota
+	}
+	return limits
+}
+
+// GetUserQuota GET /api/v1/admin/users/{email}/quota
+// Returns the account's quota followed by those of its mailboxes that have limits.
+func (h *AdminQuotaHandler) GetUserQuota(w http.ResponseWriter, r *http.Request) {
+	email := chi.URLParam(r, "email")
+	roots, err := h.emailRepo.ListQuotaRoots(r.Context(), email)
+	if err != nil {
+		h.logger.Error("Failed to list quota roots", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := h.quotaService.Get(r.Context(), domain.QuotaRoot{Owner: email})
+	if err != nil {
+		h.quotaError(w, err, "User not found")
+		return
+	}
+	quotas := []*domain.Quota{account}
+	for _, root := range roots {
+		if root.Mailbox == "" {
+			continue
+		}
+		quota, err := h.quotaService.Get(r.Context(), root)
+		if err != nil {
+			h.quotaError(w, err, "User not found")
+			return
+		}
+		quotas = append(quotas, quota)
+	}
+	writeQuotaJSON(w, quotas)
+}
+
+// UpdateUserQuota PUT /api/v1/admin/users/{email}/quota
+func (h *AdminQuotaHandler) UpdateUserQuota(w http.ResponseWriter, r *http.Request) {
+	root := domain.QuotaRoot{Owner: chi.URLParam(r, "email")}
+	if _, err := h.userRepo.FindByEmail(r.Context(), root.Owner); err != nil {
+		h.quotaError(w, err, "User not found")
+		return
+	}
+	h.updateQuota(w, r, root)
+}
+
+// GetMailboxQuota GET /api/v1/admin/users/{email}/mailboxes/{mailboxName}/quota
+func (h *AdminQuotaHandler) GetMailboxQuota(w http.ResponseWriter, r *http.Request) {
+	root := domain.QuotaRoot{Owner: chi.URLParam(r, "email"), Mailbox: chi.URLParam(r, "mailboxName")}
+	if _, err := h.emailRepo.GetMailbox(r.Context(), root.Owner, root.Mailbox); err != nil {
+		h.quotaError(w, err, "Mailbox not found")
+		return
+	}
+	h.getQuota(w, r, root)
+}
+
+// UpdateMailboxQuota PUT /api/v1/admin/users/{email}/mailboxes/{mailboxName}/quota
+// e.g. {"messages": 10000} caps a Junk folder at 10k messages.
+func (h *AdminQuotaHandler) UpdateMailboxQuota(w http.ResponseWriter, r *http.Request) {
+	root := domain.QuotaRoot{Owner: chi.URLParam(r, "email"), Mailbox: chi.URLParam(r, "mailboxName")}
+	if _, err := h.emailRepo.GetMailbox(r.Context(), root.Owner, root.Mailbox); err != nil {
+		h.quotaError(w, err, "Mailbox not found")
+		return
+	}
+	h.updateQuota(w, r, root)
+}
+
+// GetDomainQuota GET /api/v1/admin/domains/{domain}/quota
+func (h *AdminQuotaHandler) GetDomainQuota(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.domainRoot(w, r)
+	if !ok {
+		return
+	}
+	h.getQuota(w, r, root)
+}
+
+// UpdateDomainQuota PUT /api/v1/admin/domains/{domain}/quota
+// The limits apply to all the accounts of the domain together.
+func (h *AdminQuotaHandler) UpdateDomainQuota(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.domainRoot(w, r)
+	if !ok {
+		return
+	}
+	h.updateQuota(w, r, root)
+}
+
+// domainRoot returns the quota root of the domain in the URL, which must be hosted
+func (h *AdminQuotaHandler) domainRoot(w http.ResponseWriter, r *http.Request) (domain.QuotaRoot, bool) {
+	name := strings.ToLower(chi.URLParam(r, "domain"))
+	exists, err := h.domainRepo.Exists(r.Context(), name)
+	if err != nil {
+		h.logger.Error("Failed to check domain", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return domain.QuotaRoot{}, false
+	}
+	if !exists {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return domain.QuotaRoot{}, false
+	}
+	return domain.QuotaRoot{Owner: name}, true
+}
+
+// getQuota writes the usage and limits of a quota root
+func (h *AdminQuotaHandler) getQuota(w http.ResponseWriter, r *http.Request, root domain.QuotaRoot) {
+	quota, err := h.quotaService.Get(r.Context(), root)
+	if err != nil {
+		h.quotaError(w, err, "Quota root not found")
+		return
+	}
+	writeQuotaJSON(w, quota)
+}
+
+// updateQuota applies the limits of the request to a quota root and writes the result
+func (h *AdminQuotaHandler) updateQuota(w http.ResponseWriter, r *http.Request, root domain.QuotaRoot) {
+	var req UpdateQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	limits := req.limits()
+	for resource, limit := range limits {
+		if limit < 0 {
+			http.Error(w, "Quota must be non-negative", http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(root.Resources(), resource) {
+			http.Error(w, "A single mailbox has no mailboxes limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	for resource, limit := range limits {
+		if err := h.quotaService.SetLimit(r.Context(), root, resource, limit); err != nil {
+			h.quotaError(w, err, "Quota root not found")
+			return
+		}
+	}
+	h.getQuota(w, r, root)
+}
+
+// quotaError maps a quota service error to a response
+func (h *AdminQuotaHandler) quotaError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, services.ErrUnsupportedQuotaResource):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.logger.Error("Quota operation failed", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func writeQuotaJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // Response write error is non-critical
+	_ = json.NewEncoder(w).Encode(v)
+}
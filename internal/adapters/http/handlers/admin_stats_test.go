@@ -12,6 +12,7 @@ import (
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/handlers"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/middleware"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -32,12 +33,15 @@ func (m *MockUserRepo) List(ctx context.Context, limit, offset int) ([]*domain.U
 	return nil, nil
 }
 func (m *MockUserRepo) Delete(ctx context.Context, email string) error { return nil }
-func (m *MockUserRepo) UpdatePassword(ctx context.Context, email, passwordHash string) error {
+func (m *MockUserRepo) UpdatePassword(ctx context.Context, email, passwordHash string, sha1, sha256 *domain.SCRAMCredentials) error {
 	return nil
 }
 func (m *MockUserRepo) UpdateRole(ctx context.Context, email string, role domain.Role) error {
 	return nil
 }
+func (m *MockUserRepo) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	return nil
+}
 func (m *MockUserRepo) Count(ctx context.Context) (map[string]int64, error) {
 	args := m.Called(ctx)
 	if args.Get(0) == nil {
@@ -54,7 +58,8 @@ func (m *MockUserRepo) IncrementStorageUsed(ctx context.Context, email string, d
 
 type MockEmailRepo struct{ mock.Mock }
 
-func (m *MockEmailRepo) Save(ctx context.Context, msg *domain.Message) error { return nil }
+func (m *MockEmailRepo) Save(ctx context.Context, msg *domain.Message) error       { return nil }
+func (m *MockEmailRepo) SaveAll(ctx context.Context, msgs []*domain.Message) error { return nil }
 func (m *MockEmailRepo) FindByID(ctx context.Context, id string) (*domain.Message, error) {
 	return nil, nil
 }
@@ -85,12 +90,48 @@ func (m *MockEmailRepo) GetMailbox(ctx context.Context, userID, name string) (*d
 	return nil, nil
 }
 func (m *MockEmailRepo) CreateMailbox(ctx context.Context, userID, name string) error { return nil }
+func (m *MockEmailRepo) RenameMailbox(ctx context.Context, userID, oldName, newName string) error {
+	return nil
+}
+func (m *MockEmailRepo) DeleteMailbox(ctx context.Context, userID, name string) ([]string, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error {
+	return nil
+}
+func (m *MockEmailRepo) SetSpecialUse(ctx context.Context, userID, name, specialUse string) error {
+	return nil
+}
+func (m *MockEmailRepo) GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error {
+	return nil
+}
+func (m *MockEmailRepo) GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
+	return nil
+}
+func (m *MockEmailRepo) GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error) {
+	return nil, nil
+}
 func (m *MockEmailRepo) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
 	return nil, nil
 }
+func (m *MockEmailRepo) ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	return nil, nil
+}
 func (m *MockEmailRepo) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
 	return nil, nil
 }
+func (m *MockEmailRepo) FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error) {
+	return nil, nil
+}
 func (m *MockEmailRepo) AddFlags(ctx context.Context, messageID string, flags ...string) error {
 	return nil
 }
@@ -103,8 +144,17 @@ func (m *MockEmailRepo) SetFlags(ctx context.Context, messageID string, flags ..
 func (m *MockEmailRepo) AssignUID(ctx context.Context, messageID string, mailbox string) (uint32, error) {
 	return 0, nil
 }
-func (m *MockEmailRepo) CopyMessages(ctx context.Context, userID string, msgIDs []string, destMailbox string) error {
-	return nil
+func (m *MockEmailRepo) CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) MoveMessages(ctx context.Context, userID, srcMailbox string, msgIDs []string, destMailbox string) (map[uint32]uint32, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error) {
+	return nil, nil
+}
+func (m *MockEmailRepo) DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error) {
+	return nil, nil
 }
 func (m *MockEmailRepo) SetACL(ctx context.Context, userID, mailboxName, identifier, rights string) error {
 	return nil
@@ -113,16 +163,55 @@ func (m *MockEmailRepo) SetACL(ctx context.Context, userID, mailboxName, identif
 type MockQueueRepo struct{ mock.Mock }
 
 func (m *MockQueueRepo) Enqueue(ctx context.Context, msg *domain.OutboundMessage) error { return nil }
+func (m *MockQueueRepo) EnqueueAll(ctx context.Context, msgs []*domain.OutboundMessage) error {
+	return nil
+}
 func (m *MockQueueRepo) LockNextReady(ctx context.Context) (*domain.OutboundMessage, error) {
 	return nil, nil
 }
 func (m *MockQueueRepo) UpdateStatus(ctx context.Context, id string, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
 	return nil
 }
+func (m *MockQueueRepo) TransitionStatus(ctx context.Context, id string, from, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
+	return nil
+}
 func (m *MockQueueRepo) Stats(ctx context.Context) (int64, int64, int64, int64, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(int64), args.Get(1).(int64), args.Get(2).(int64), args.Get(3).(int64), args.Error(4)
 }
+func (m *MockQueueRepo) SetDelayWarnings(ctx context.Context, id string, count int) error {
+	return nil
+}
+func (m *MockQueueRepo) LockReadyForDomain(ctx context.Context, recipientDomain string, limit int) ([]*domain.OutboundMessage, error) {
+	return nil, nil
+}
+func (m *MockQueueRepo) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockQueueRepo) List(ctx context.Context, filter domain.QueueFilter) ([]*domain.OutboundMessage, error) {
+	return nil, nil
+}
+
+func (m *MockQueueRepo) Get(ctx context.Context, id string) (*domain.OutboundMessage, error) {
+	return nil, ports.ErrNotFound
+}
+
+func (m *MockQueueRepo) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockQueueRepo) RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error {
+	return nil
+}
+
+func (m *MockQueueRepo) ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error) {
+	return nil, nil
+}
+
+func (m *MockQueueRepo) Reroute(ctx context.Context, recipientDomain, relay string) (int64, error) {
+	return 0, nil
+}
 
 func TestGetSystemStats_Success(t *testing.T) {
 	// Setup
@@ -7,21 +7,24 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AdminUserHandler struct {
-	userRepo   ports.UserRepository
-	domainRepo ports.DomainRepository
-	logger     *observability.Logger
+	userRepo     ports.UserRepository
+	domainRepo   ports.DomainRepository
+	emailService *services.EmailService
+	logger       *observability.Logger
 }
 
-func NewAdminUserHandler(userRepo ports.UserRepository, domainRepo ports.DomainRepository, logger *observability.Logger) *AdminUserHandler {
-	return &AdminUserHandler{userRepo: userRepo, domainRepo: domainRepo, logger: logger}
+func NewAdminUserHandler(userRepo ports.UserRepository, domainRepo ports.DomainRepository, emailService *services.EmailService, logger *observability.Logger) *AdminUserHandler {
+	return &AdminUserHandler{userRepo: userRepo, domainRepo: domainRepo, emailService: emailService, logger: logger}
 }
 
 type CreateUserRequest struct {
@@ -31,6 +34,13 @@ type CreateUserRequest struct {
 	StorageQuota int64  `json:"storage_quota"` // optional
 }
 
+// CreateSharedMailboxRequest creates a shared role account such as support@
+type CreateSharedMailboxRequest struct {
+	Email        string            `json:"email"`
+	Members      map[string]string `json:"members"`       // member email -> RFC 4314 rights, e.g. "lrswite"
+	StorageQuota int64             `json:"storage_quota"` // optional
+}
+
 // ListUsers GET /api/v1/admin/users
 func (h *AdminUserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	limit := 50
@@ -119,6 +129,10 @@ func (h *AdminUserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    time.Now(),
 		LastLoginAt:  time.Unix(0, 0),
 	}
+	// Without SCRAM credentials the user can still log in; they are derived at the first login
+	if user.SCRAMSHA1, user.SCRAMSHA256, err = sasl.DeriveSCRAM(req.Password); err != nil {
+		h.logger.Error("Failed to derive SCRAM credentials", "error", err)
+	}
 
 	if err := h.userRepo.Create(r.Context(), user); err != nil {
 		if err == ports.ErrAlreadyExists {
@@ -130,6 +144,11 @@ func (h *AdminUserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account is usable without its folders; clients can create missing ones
+	if err := h.emailService.CreateDefaultMailboxes(r.Context(), user.Email); err != nil {
+		h.logger.Error("Failed to create default mailboxes", "error", err, "user", user.Email)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -182,28 +201,97 @@ func (h *AdminUserHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// UpdateQuota PUT /api/v1/admin/users/{email}/quota
-func (h *AdminUserHandler) UpdateQuota(w http.ResponseWriter, r *http.Request) {
+// CreateSharedMailbox POST /api/v1/admin/shared-mailboxes
+// The account has no password: its members reach its mailboxes in their IMAP "Shared"
+// namespace, with the rights granted to them on every folder.
+func (h *AdminUserHandler) CreateSharedMailbox(w http.ResponseWriter, r *http.Request) {
+	var req CreateSharedMailboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(req.Email, "@")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "Invalid email format", http.StatusBadRequest)
+		return
+	}
+	for member, rights := range req.Members {
+		if err := h.emailService.ValidateRights(rights); err != nil || !strings.Contains(member, "@") {
+			http.Error(w, "Invalid member "+member, http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := h.domainRepo.Exists(r.Context(), parts[1])
+	if err != nil {
+		h.logger.Error("Failed to check domain existence", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Domain not managed by this server. Add domain first.", http.StatusBadRequest)
+		return
+	}
+
+	user := &domain.User{
+		Email:        req.Email,
+		Role:         domain.RoleShared,
+		StorageQuota: req.StorageQuota,
+		CreatedAt:    time.Now(),
+		LastLoginAt:  time.Unix(0, 0),
+	}
+	if err := h.userRepo.Create(r.Context(), user); err != nil {
+		if err == ports.ErrAlreadyExists {
+			http.Error(w, "User already exists", http.StatusConflict)
+			return
+		}
+		h.logger.Error("Failed to create shared mailbox", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.emailService.CreateDefaultMailboxes(r.Context(), user.Email); err != nil {
+		h.logger.Error("Failed to create default mailboxes", "error", err, "user", user.Email)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for member, rights := range req.Members {
+		if err := h.emailService.ShareMailboxes(r.Context(), user.Email, member, rights); err != nil {
+			h.logger.Error("Failed to share mailboxes", "error", err, "user", user.Email, "member", member)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// UpdateSharedMailboxMember PUT /api/v1/admin/shared-mailboxes/{email}/members/{member}
+// Empty rights remove the member.
+func (h *AdminUserHandler) UpdateSharedMailboxMember(w http.ResponseWriter, r *http.Request) {
 	email := chi.URLParam(r, "email")
+	member := chi.URLParam(r, "member")
 	var req struct {
-		Quota int64 `json:"quota"`
+		Rights string `json:"rights"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if err := h.emailService.ValidateRights(req.Rights); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if req.Quota < 0 {
-		http.Error(w, "Quota must be non-negative", http.StatusBadRequest)
+	user, err := h.userRepo.FindByEmail(r.Context(), email)
+	if err != nil || user.Role != domain.RoleShared {
+		http.Error(w, "Shared mailbox not found", http.StatusNotFound)
 		return
 	}
 
-	if err := h.userRepo.UpdateQuota(r.Context(), email, req.Quota); err != nil {
-		if err == ports.ErrNotFound {
-			http.Error(w, "User not found", http.StatusNotFound)
-			return
-		}
-		h.logger.Error("Failed to update quota", "error", err)
+	if err := h.emailService.ShareMailboxes(r.Context(), email, member, req.Rights); err != nil {
+		h.logger.Error("Failed to share mailboxes", "error", err, "user", email, "member", member)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
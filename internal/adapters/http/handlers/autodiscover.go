@@ -21,12 +21,42 @@ func NewAutodiscoverHandler(cfg *config.Config, logger *observability.Logger) *A
 	}
 }
 
+// submissionEndpoint returns the port and socket type clients should use to send mail.
+// Prefers implicit TLS submission (RFC 8314) when the submission listeners are enabled.
+func (h *AutodiscoverHandler) submissionEndpoint() (int, string) {
+	sub := h.config.SMTP.Submission
+	if sub.Enabled {
+		if sub.PortTLS != 0 {
+			return sub.PortTLS, "SSL"
+		}
+		return sub.Port, "STARTTLS"
+	}
+	if h.config.SMTP.Port == 465 {
+		return h.config.SMTP.Port, "SSL"
+	}
+	return h.config.SMTP.Port, "STARTTLS"
+}
+
+// imapEndpoint returns the port and socket type clients should use to read mail.
+// Prefers implicit TLS (RFC 8314) when the TLS port is configured.
+func (h *AutodiscoverHandler) imapEndpoint() (int, string) {
+	if h.config.IMAP.PortTLS != 0 {
+		return h.config.IMAP.PortTLS, "SSL"
+	}
+	if h.config.IMAP.Port == 993 {
+		return h.config.IMAP.Port, "SSL"
+	}
+	return h.config.IMAP.Port, "STARTTLS"
+}
+
 // HandleMozillaAutoconfig serves configuration for Thunderbird
 // GET /.well-known/autoconfig/mail/config-v1.1.xml
 func (h *AutodiscoverHandler) HandleMozillaAutoconfig(w http.ResponseWriter, r *http.Request) {
 	// Default to configured domain if no email (or extract domain from email)
 	confDomain := h.config.Domain
 	username := "%EMAILADDRESS%"
+	imapPort, imapSocket := h.imapEndpoint()
+	smtpPort, smtpSocket := h.submissionEndpoint()
 
 	resp := dto.ClientConfig{
 		Version: "1.1",
@@ -37,29 +67,22 @@ func (h *AutodiscoverHandler) HandleMozillaAutoconfig(w http.ResponseWriter, r *
 			IncomingServer: dto.IncomingServer{
 				Type:           "imap",
 				Hostname:       h.config.SMTP.Hostname,
-				Port:           h.config.IMAP.Port,
-				SocketType:     "STARTTLS",
+				Port:           imapPort,
+				SocketType:     imapSocket,
 				Authentication: "password-cleartext",
 				Username:       username,
 			},
 			OutgoingServer: dto.OutgoingServer{
 				Type:           "smtp",
 				Hostname:       h.config.SMTP.Hostname,
-				Port:           h.config.SMTP.Port,
-				SocketType:     "STARTTLS",
+				Port:           smtpPort,
+				SocketType:     smtpSocket,
 				Authentication: "password-cleartext",
 				Username:       username,
 			},
 		},
 	}
 
-	if h.config.IMAP.Port == 993 {
-		resp.EmailProvider.IncomingServer.SocketType = "SSL"
-	}
-	if h.config.SMTP.Port == 465 {
-		resp.EmailProvider.OutgoingServer.SocketType = "SSL"
-	}
-
 	w.Header().Set("Content-Type", "text/xml")
 	w.WriteHeader(http.StatusOK)
 	//nolint:errcheck // Best effort
@@ -79,18 +102,20 @@ func (h *AutodiscoverHandler) HandleMicrosoftAutodiscover(w http.ResponseWriter,
 		return
 	}
 
+	imapPort, _ := h.imapEndpoint()
 	imapProto := dto.Protocol{
 		Type:         "IMAP",
 		Server:       h.config.SMTP.Hostname,
-		Port:         h.config.IMAP.Port,
+		Port:         imapPort,
 		SSL:          "on",
 		AuthRequired: "on",
 	}
 
+	smtpPort, _ := h.submissionEndpoint()
 	smtpProto := dto.Protocol{
 		Type:         "SMTP",
 		Server:       h.config.SMTP.Hostname,
-		Port:         h.config.SMTP.Port,
+		Port:         smtpPort,
 		SSL:          "on",
 		AuthRequired: "on",
 	}
@@ -1,13 +1,9 @@
 package handlers
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -21,65 +17,32 @@ import (
 )
 
 type SendHandler struct {
-	queueRepo  ports.QueueRepository
-	blobStore  ports.BlobStore
-	logger     *observability.Logger
-	metrics    *observability.Metrics
-	dkimSigner *dkim.Signer
-	domain     string
+	queueRepo ports.QueueRepository
+	blobStore ports.BlobStore
+	logger    *observability.Logger
+	metrics   *observability.Metrics
+	signers   *dkim.Resolver
+	domain    string
 }
 
+// NewSendHandler creates a send handler. Messages are DKIM-signed with the key of the
+// sender's domain chosen by signers; hostname names generated Message-IDs.
 func NewSendHandler(
 	queueRepo ports.QueueRepository,
 	blobStore ports.BlobStore,
 	logger *observability.Logger,
 	metrics *observability.Metrics,
-	signingDomain string,
-	selector string,
-	privateKeyPath string,
-) (*SendHandler, error) {
-	if privateKeyPath == "" {
-		return nil, fmt.Errorf("DKIM private key path is required")
-	}
-
-	pemBytes, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read DKIM key from %s: %w", privateKeyPath, err)
-	}
-
-	block, _ := pem.Decode(pemBytes)
-	if block == nil {
-		return nil, fmt.Errorf("failed to decode PEM block containing DKIM key")
-	}
-
-	var key *rsa.PrivateKey
-
-	// Try PKCS1
-	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
-		key = k
-	} else {
-		// Try PKCS8
-		if pk, err2 := x509.ParsePKCS8PrivateKey(block.Bytes); err2 == nil {
-			if k, ok := pk.(*rsa.PrivateKey); ok {
-				key = k
-			} else {
-				return nil, fmt.Errorf("key in %s is not an RSA private key", privateKeyPath)
-			}
-		} else {
-			return nil, fmt.Errorf("failed to parse private key: %w", err)
-		}
-	}
-
-	signer := dkim.NewSigner(signingDomain, selector, key)
-
+	signers *dkim.Resolver,
+	hostname string,
+) *SendHandler {
 	return &SendHandler{
-		queueRepo:  queueRepo,
-		blobStore:  blobStore,
-		logger:     logger,
-		metrics:    metrics,
-		dkimSigner: signer,
-		domain:     signingDomain,
-	}, nil
+		queueRepo: queueRepo,
+		blobStore: blobStore,
+		logger:    logger,
+		metrics:   metrics,
+		signers:   signers,
+		domain:    hostname,
+	}
 }
 
 func (h *SendHandler) Send(w http.ResponseWriter, r *http.Request) {
@@ -125,15 +88,28 @@ func (h *SendHandler) Send(w http.ResponseWriter, r *http.Request) {
 	// Sign message
 	headersToSign := []string{"From", "To", "Subject", "Date", "Message-ID", "Content-Type", "MIME-Version"}
 
-	signatureHeader, err := h.dkimSigner.Sign(rawMessage, headersToSign)
+	senderDomain := email[strings.LastIndex(email, "@")+1:]
+	keys, err := h.signers.SignersFor(r.Context(), senderDomain)
 	if err != nil {
-		h.logger.Error("failed to sign message", "error", err)
+		h.logger.Error("failed to load DKIM key", "domain", senderDomain, "error", err)
 		http.Error(w, "Internal server error during signing", http.StatusInternalServerError)
 		return
 	}
 
-	// Prepend signature
-	signedMessage := []byte(signatureHeader + "\r\n" + string(rawMessage))
+	signedMessage := rawMessage
+	if len(keys) > 0 {
+		signatureHeader, err := keys.Sign(rawMessage, headersToSign)
+		if err != nil {
+			h.logger.Error("failed to sign message", "error", err)
+			http.Error(w, "Internal server error during signing", http.StatusInternalServerError)
+			return
+		}
+
+		// Prepend signature
+		signedMessage = []byte(signatureHeader + "\r\n" + string(rawMessage))
+	} else {
+		h.logger.Warn("no DKIM key for sending domain, sending unsigned", "domain", senderDomain)
+	}
 
 	// Store in blob
 	msgUUID := uuid.New().String()
@@ -1,30 +1,32 @@
 package handlers
 
 import (
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type SetupHandler struct {
-	mu         sync.Mutex
-	userRepo   ports.UserRepository
-	domainRepo ports.DomainRepository
-	logger     *observability.Logger
+	mu           sync.Mutex
+	userRepo     ports.UserRepository
+	emailService *services.EmailService
+	dkimKeys     *dkim.KeyManager
+	logger       *observability.Logger
 }
 
-func NewSetupHandler(userRepo ports.UserRepository, domainRepo ports.DomainRepository, logger *observability.Logger) *SetupHandler {
-	return &SetupHandler{userRepo: userRepo, domainRepo: domainRepo, logger: logger}
+func NewSetupHandler(userRepo ports.UserRepository, emailService *services.EmailService, dkimKeys *dkim.KeyManager, logger *observability.Logger) *SetupHandler {
+	return &SetupHandler{userRepo: userRepo, emailService: emailService, dkimKeys: dkimKeys, logger: logger}
 }
 
 type SetupStatusResponse struct {
@@ -106,25 +108,15 @@ func (h *SetupHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate DKIM keys
-	privKeyPEM, pubKeyPEM, err := generateDKIMKeys()
-	if err != nil {
-		h.logger.Error("Setup: failed to generate DKIM keys", "error", err)
-		http.Error(w, "Failed to generate DKIM keys", http.StatusInternalServerError)
-		return
-	}
-
-	// Create domain
+	// Create domain with its DKIM keys
 	d := &domain.Domain{
-		Name:           req.Domain,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-		Active:         true,
-		DKIMSelector:   "default",
-		DKIMPrivateKey: privKeyPEM,
-		DKIMPublicKey:  pubKeyPEM,
-	}
-	if err := h.domainRepo.Create(r.Context(), d); err != nil {
+		Name:      req.Domain,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Active:    true,
+	}
+	dkimKeys, err := h.dkimKeys.Provision(r.Context(), d)
+	if err != nil {
 		h.logger.Error("Setup: failed to create domain", "error", err)
 		http.Error(w, "Failed to create domain", http.StatusInternalServerError)
 		return
@@ -144,11 +136,17 @@ func (h *SetupHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		Role:         domain.RoleAdmin,
 		CreatedAt:    time.Now(),
 	}
+	if user.SCRAMSHA1, user.SCRAMSHA256, err = sasl.DeriveSCRAM(req.AdminPassword); err != nil {
+		h.logger.Error("Setup: failed to derive SCRAM credentials", "error", err)
+	}
 	if err := h.userRepo.Create(r.Context(), user); err != nil {
 		h.logger.Error("Setup: failed to create admin user", "error", err)
 		http.Error(w, "Failed to create admin user", http.StatusInternalServerError)
 		return
 	}
+	if err := h.emailService.CreateDefaultMailboxes(r.Context(), user.Email); err != nil {
+		h.logger.Error("Setup: failed to create default mailboxes", "error", err)
+	}
 
 	// Build DNS records
 	hostname := req.SMTPHostname
@@ -156,15 +154,15 @@ func (h *SetupHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		hostname = "mail." + req.Domain
 	}
 
-	dkimValue := formatDKIMDNSValue(pubKeyPEM)
-
 	dnsRecords := []DNSRecord{
 		{Type: "MX", Name: req.Domain, Value: fmt.Sprintf("10 %s.", hostname)},
 		{Type: "A", Name: hostname, Value: "<YOUR_SERVER_IP>"},
 		{Type: "TXT", Name: req.Domain, Value: "v=spf1 mx -all"},
-		{Type: "TXT", Name: fmt.Sprintf("default._domainkey.%s", req.Domain), Value: dkimValue},
-		{Type: "TXT", Name: fmt.Sprintf("_dmarc.%s", req.Domain), Value: fmt.Sprintf("v=DMARC1; p=quarantine; rua=mailto:postmaster@%s", req.Domain)},
 	}
+	for _, key := range dkimKeys {
+		dnsRecords = append(dnsRecords, DNSRecord{Type: "TXT", Name: key.DNSName(), Value: key.DNSValue()})
+	}
+	dnsRecords = append(dnsRecords, DNSRecord{Type: "TXT", Name: fmt.Sprintf("_dmarc.%s", req.Domain), Value: fmt.Sprintf("v=DMARC1; p=quarantine; rua=mailto:postmaster@%s", req.Domain)})
 
 	h.logger.Info("Setup completed", "domain", req.Domain, "admin", req.AdminEmail)
 
@@ -175,12 +173,3 @@ func (h *SetupHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		DNSRecords: dnsRecords,
 	})
 }
-
-func formatDKIMDNSValue(pubKeyPEM string) string {
-	block, _ := pem.Decode([]byte(pubKeyPEM))
-	if block == nil {
-		return ""
-	}
-	b64 := base64.StdEncoding.EncodeToString(block.Bytes)
-	return fmt.Sprintf("v=DKIM1; k=rsa; p=%s", b64)
-}
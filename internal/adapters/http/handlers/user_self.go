@@ -6,6 +6,7 @@ import (
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/middleware"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"golang.org/x/crypto/bcrypt"
@@ -82,8 +83,16 @@ func (h *UserSelfHandler) ChangePassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 6. Update password
-	err = h.userRepo.UpdatePassword(ctx, email, string(hashedPassword))
+	// 6. Derive the SCRAM credentials, which would otherwise still accept the old password
+	sha1Creds, sha256Creds, err := sasl.DeriveSCRAM(req.NewPassword)
+	if err != nil {
+		h.logger.Error("Failed to derive SCRAM credentials", "error", err, "email", email)
+		h.sendError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// 7. Update password and SCRAM credentials together
+	err = h.userRepo.UpdatePassword(ctx, email, string(hashedPassword), sha1Creds, sha256Creds)
 	if err != nil {
 		h.logger.Error("Failed to update password", "error", err, "email", email)
 		h.sendError(w, http.StatusInternalServerError, "Failed to update password")
@@ -11,6 +11,8 @@ import (
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/handlers"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/middleware"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/static"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
@@ -51,6 +53,8 @@ func NewServer(
 	userRepo ports.UserRepository,
 	queueRepo ports.QueueRepository,
 	domainRepo ports.DomainRepository,
+	signers *dkim.Resolver,
+	dkimKeys *dkim.KeyManager,
 	blobStore ports.BlobStore,
 	searchIdx ports.SearchIndex,
 	acmeService *services.ACMEService,
@@ -60,43 +64,34 @@ func NewServer(
 	sieveRepo ports.ScriptRepository,
 	updateManager ports.UpdateManager,
 	spamFilter ports.SpamFilter,
+	bouncer *smtp.Bouncer,
 	logger *observability.Logger,
 	metrics *observability.Metrics,
 ) *Server {
 	router := chi.NewRouter()
 
+	// Create EmailService
+	emailService := services.NewEmailService(emailRepo)
+
 	// Create handlers
 	authHandler := handlers.NewAuthHandler(userRepo, cfg.API.JWTSecret, logger, metrics)
 	messageHandler := handlers.NewMessageHandler(emailRepo, blobStore, searchIdx, spamFilter, logger, metrics)
 	searchHandler := handlers.NewSearchHandler(emailRepo, searchIdx, logger, metrics)
 	adminBackupHandler := handlers.NewAdminHandler(backupService, logger, metrics)
-	adminUserHandler := handlers.NewAdminUserHandler(userRepo, domainRepo, logger)
-	adminDomainHandler := handlers.NewAdminDomainHandler(domainRepo, logger)
+	adminUserHandler := handlers.NewAdminUserHandler(userRepo, domainRepo, emailService, logger)
+	adminQuotaHandler := handlers.NewAdminQuotaHandler(services.NewQuotaService(userRepo, emailRepo), userRepo, emailRepo, domainRepo, logger)
+	adminDomainHandler := handlers.NewAdminDomainHandler(domainRepo, dkimKeys, logger)
+	adminDKIMHandler := handlers.NewAdminDKIMHandler(dkimKeys, logger)
 	adminStatsHandler := handlers.NewAdminStatsHandler(userRepo, emailRepo, queueRepo, logger)
+	adminQueueHandler := handlers.NewAdminQueueHandler(queueRepo, blobStore, bouncer, logger)
 	adminSystemHandler := handlers.NewSystemHandler(updateManager, logger)
 	tlsRptHandler := handlers.NewTLSRptHandler(tlsRptRepo, logger)
 	sieveHandler := handlers.NewSieveHandler(sieveRepo, logger)
 	userSelfHandler := handlers.NewUserSelfHandler(userRepo, logger)
 
-	// Create EmailService
-	emailService := services.NewEmailService(emailRepo)
 	mailboxHandler := handlers.NewMailboxHandler(emailService, logger)
 
-	sendHandler, err := handlers.NewSendHandler(
-		queueRepo,
-		blobStore,
-		logger,
-		metrics,
-		cfg.Domain,
-		cfg.DKIM.Selector,
-		cfg.DKIM.PrivateKeyPath,
-	)
-	if err != nil {
-		// Log error but don't fail server startup if DKIM keys missing?
-		// Or fail? Handler returns error if key invalid.
-		// If fails, we can't send.
-		logger.Error("failed to create send handler (DKIM init failed)", "error", err)
-	}
+	sendHandler := handlers.NewSendHandler(queueRepo, blobStore, logger, metrics, signers, cfg.Domain)
 
 	// Apply global middleware (order matters: first applied = outermost)
 	router.Use(middleware.Logging(logger))
@@ -119,7 +114,7 @@ func NewServer(
 	mtaStsHandler := handlers.NewMTASTSHandler(mtaStsPolicy)
 
 	// Setup handler (public, guarded internally)
-	setupHandler := handlers.NewSetupHandler(userRepo, domainRepo, logger)
+	setupHandler := handlers.NewSetupHandler(userRepo, emailService, dkimKeys, logger)
 
 	// Public routes (no auth required)
 	router.Get("/api/v1/setup/status", setupHandler.GetStatus)
@@ -162,9 +157,7 @@ func NewServer(
 		r.Post("/api/v1/messages/{id}/spam", messageHandler.ReportSpam)
 		r.Post("/api/v1/messages/{id}/ham", messageHandler.ReportHam)
 		// Outbound
-		if sendHandler != nil {
-			r.Post("/api/v1/messages/send", sendHandler.Send)
-		}
+		r.Post("/api/v1/messages/send", sendHandler.Send)
 
 		// User Self-Management
 		r.Put("/api/v1/users/self/password", userSelfHandler.ChangePassword)
@@ -195,15 +188,44 @@ func NewServer(
 			r.Post("/users", adminUserHandler.CreateUser)
 			r.Delete("/users/{email}", adminUserHandler.DeleteUser)
 			r.Put("/users/{email}/role", adminUserHandler.UpdateRole)
-			r.Put("/users/{email}/quota", adminUserHandler.UpdateQuota)
+
+			// Quotas (RFC 9208 roots: accounts, single mailboxes and domains)
+			r.Get("/users/{email}/quota", adminQuotaHandler.GetUserQuota)
+			r.Put("/users/{email}/quota", adminQuotaHandler.UpdateUserQuota)
+			r.Get("/users/{email}/mailboxes/{mailboxName}/quota", adminQuotaHandler.GetMailboxQuota)
+			r.Put("/users/{email}/mailboxes/{mailboxName}/quota", adminQuotaHandler.UpdateMailboxQuota)
+			r.Get("/domains/{domain}/quota", adminQuotaHandler.GetDomainQuota)
+			r.Put("/domains/{domain}/quota", adminQuotaHandler.UpdateDomainQuota)
+
+			// Shared Mailboxes
+			r.Post("/shared-mailboxes", adminUserHandler.CreateSharedMailbox)
+			r.Put("/shared-mailboxes/{email}/members/{member}", adminUserHandler.UpdateSharedMailboxMember)
+
 			// ACL Management
 			r.Put("/users/{userID}/mailboxes/{mailboxName}/acl", mailboxHandler.UpdateACL)
 
+			// Outbound Queue Management
+			r.Get("/queue", adminQueueHandler.ListQueue)
+			r.Post("/queue/reroute", adminQueueHandler.RerouteDomain)
+			r.Get("/queue/{id}", adminQueueHandler.GetQueueMessage)
+			r.Delete("/queue/{id}", adminQueueHandler.DeleteMessage)
+			r.Post("/queue/{id}/retry", adminQueueHandler.RetryMessage)
+			r.Post("/queue/{id}/hold", adminQueueHandler.HoldMessage)
+			r.Post("/queue/{id}/release", adminQueueHandler.ReleaseMessage)
+			r.Post("/queue/{id}/bounce", adminQueueHandler.BounceMessage)
+
 			// Domain Management
 			r.Get("/domains", adminDomainHandler.ListDomains)
 			r.Post("/domains", adminDomainHandler.CreateDomain)
 			r.Delete("/domains/{domain}", adminDomainHandler.DeleteDomain)
 
+			// DKIM Key Rotation
+			r.Get("/domains/{domain}/dkim", adminDKIMHandler.ListKeys)
+			r.Post("/domains/{domain}/dkim", adminDKIMHandler.RotateKey)
+			r.Delete("/domains/{domain}/dkim/{selector}", adminDKIMHandler.DeleteKey)
+			r.Post("/domains/{domain}/dkim/{selector}/verify", adminDKIMHandler.VerifyKey)
+			r.Post("/domains/{domain}/dkim/{selector}/activate", adminDKIMHandler.ActivateKey)
+
 			// System Management (Updates)
 			if updateManager != nil {
 				r.Get("/system/update", adminSystemHandler.CheckUpdate)
@@ -3,6 +3,8 @@ package imap
 import (
 	"fmt"
 	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 )
 
 // RFC 4314 IMAP4 Access Control List (ACL) Extension
@@ -18,22 +20,19 @@ func (s *Session) handleSetACL(cmd *Command) {
 		return
 	}
 
-	mailboxName := cmd.Args[0]
 	identifier := cmd.Args[1]
 	rights := cmd.Args[2]
 
 	ctx := s.ctx
 
-	// 1. Check mailbox exists and ownership
-	// For MVP, we only allow setting ACL on owned mailboxes found by GetMailbox
-	_, err := s.emailRepo.GetMailbox(ctx, s.user.Email, mailboxName)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Mailbox not found", cmd.Tag))
+	// 1. Check mailbox exists and the user administers it
+	mb, ok := s.administeredMailbox(cmd)
+	if !ok {
 		return
 	}
 
 	// 2. Set ACL
-	if err := s.emailRepo.SetACL(ctx, s.user.Email, mailboxName, identifier, rights); err != nil {
+	if err := s.emailService.UpdateACL(ctx, mb.UserID, mb.Name, identifier, rights); err != nil {
 		s.send(fmt.Sprintf("%s NO SetACL failed", cmd.Tag))
 		return
 	}
@@ -52,22 +51,20 @@ func (s *Session) handleDeleteACL(cmd *Command) {
 		return
 	}
 
-	mailboxName := cmd.Args[0]
 	identifier := cmd.Args[1]
 
 	ctx := s.ctx
 
-	// 1. Check mailbox exists
-	_, err := s.emailRepo.GetMailbox(ctx, s.user.Email, mailboxName)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Mailbox not found", cmd.Tag))
+	// 1. Check mailbox exists and the user administers it
+	mb, ok := s.administeredMailbox(cmd)
+	if !ok {
 		return
 	}
 
 	// 2. Remove ACL by setting empty rights (logic in Repo)
 	// Or implementation of explicit delete if Repo requires different method.
 	// My Repo SetACL deletes if rights == "".
-	if err := s.emailRepo.SetACL(ctx, s.user.Email, mailboxName, identifier, ""); err != nil {
+	if err := s.emailRepo.SetACL(ctx, mb.UserID, mb.Name, identifier, ""); err != nil {
 		s.send(fmt.Sprintf("%s NO DeleteACL failed", cmd.Tag))
 		return
 	}
@@ -86,18 +83,14 @@ func (s *Session) handleGetACL(cmd *Command) {
 		return
 	}
 
-	mailboxName := cmd.Args[0]
-
-	ctx := s.ctx
-	mb, err := s.emailRepo.GetMailbox(ctx, s.user.Email, mailboxName)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Mailbox not found", cmd.Tag))
+	mb, ok := s.administeredMailbox(cmd)
+	if !ok {
 		return
 	}
 
 	// Response format: * ACL <mailbox> <id> <rights> <id> <rights> ...
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("* ACL %s", mailboxName))
+	sb.WriteString(fmt.Sprintf("* ACL %s", s.quoteMailbox(s.mailboxArg(cmd.Args[0]))))
 
 	for id, rights := range mb.ACL {
 		sb.WriteString(fmt.Sprintf(" %s %s", id, rights))
@@ -127,8 +120,35 @@ func (s *Session) handleMyRights(cmd *Command) {
 		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
 		return
 	}
-	// Since we currently only expose Owned mailboxes, the user has full rights.
-	// "acdilrsw" is a common set + kxte
-	s.send(fmt.Sprintf("* MYRIGHTS %s lrswipkxte", cmd.Args[0]))
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
+		return
+	}
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox not found", cmd.Tag))
+		return
+	}
+	rights, err := s.emailService.Rights(s.ctx, mb.UserID, mb.Name, s.user.Email)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO MyRights failed", cmd.Tag))
+		return
+	}
+	s.send(fmt.Sprintf("* MYRIGHTS %s %s", s.quoteMailbox(s.mailboxArg(cmd.Args[0])), rights))
 	s.send(fmt.Sprintf("%s OK MyRights completed", cmd.Tag))
 }
+
+// administeredMailbox looks up the mailbox named by the first argument of an ACL command,
+// which needs the 'a' (administer) right. It answers the command itself on failure.
+func (s *Session) administeredMailbox(cmd *Command) (*domain.Mailbox, bool) {
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox not found", cmd.Tag))
+		return nil, false
+	}
+	if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "a"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+		return nil, false
+	}
+	return mb, true
+}
@@ -0,0 +1,281 @@
+package imap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/mime"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
+	"github.com/google/uuid"
+)
+
+// maxAppendSize bounds a single appended message
+const maxAppendSize = 64 << 20
+
+// defaultMaxAppendTotal bounds the messages of one APPEND together unless configured otherwise.
+// They are held in memory until the last one has been read.
+const defaultMaxAppendTotal = 128 << 20
+
+// appendMessage is one message of an APPEND command
+type appendMessage struct {
+	flags []string
+	date  time.Time
+	data  []byte
+	utf8  bool // Sent as UTF8 (~{n}), closed by a parenthesis after the data
+}
+
+// parseAppendArgs parses [flag-list] [date-time] literal, the arguments of one message.
+// The literal may be the UTF8 data item of RFC 6855 Section 4, for messages with UTF-8
+// headers.
+func parseAppendArgs(args []string) (appendMessage, int64, bool, error) {
+	var msg appendMessage
+	if len(args) == 0 {
+		return msg, 0, false, fmt.Errorf("missing message literal")
+	}
+	// readCommand passes the literal of the first message on apart from the text before it
+	if n := len(args); n >= 2 && (args[n-2] == "(~" || args[n-2] == "~") {
+		args = append(args[:n-2:n-2], args[n-2]+args[n-1])
+	}
+	prefix, size, nonSync, ok := parseLiteral(args[len(args)-1])
+	switch {
+	case ok && prefix == "(~" && len(args) >= 2 && strings.EqualFold(args[len(args)-2], "UTF8"):
+		msg.utf8 = true
+		args = args[:len(args)-2]
+	case ok && prefix == "~":
+		// A literal8 (RFC 3516 Section 4.4) may hold binary content; it is stored as it is
+		args = args[:len(args)-1]
+	case !ok || prefix != "":
+		return msg, 0, false, fmt.Errorf("missing message literal")
+	default:
+		args = args[:len(args)-1]
+	}
+
+	if len(args) > 0 && strings.HasPrefix(args[0], "(") {
+		end := 0
+		for end < len(args) && !strings.HasSuffix(args[end], ")") {
+			end++
+		}
+		if end == len(args) {
+			return msg, 0, false, fmt.Errorf("unterminated flag list")
+		}
+		list := strings.TrimSuffix(strings.TrimPrefix(strings.Join(args[:end+1], " "), "("), ")")
+		for _, flag := range strings.Fields(list) {
+			// \Recent is managed by the server and can't be set by clients
+			if strings.EqualFold(flag, `\Recent`) {
+				return msg, 0, false, fmt.Errorf("invalid flag %s", flag)
+			}
+			msg.flags = append(msg.flags, flag)
+		}
+		args = args[end+1:]
+	}
+
+	if len(args) > 0 {
+		date, err := time.Parse(internalDateLayout, args[0])
+		if err != nil {
+			return msg, 0, false, fmt.Errorf("invalid date-time %q", args[0])
+		}
+		msg.date = date
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return msg, 0, false, fmt.Errorf("unexpected argument %q", args[0])
+	}
+	return msg, size, nonSync, nil
+}
+
+// handleAppend APPEND mailbox [flags] [date-time] literal, repeated for each message of a
+// MULTIAPPEND (RFC 3502). All messages are read before any is stored, so that the command
+// appends either every message or none. Literals may be non-synchronizing (RFC 7888).
+func (s *Session) handleAppend(cmd *Command) {
+	rest := strings.Join(cmd.Args, " ")
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.discardAppend(rest)
+		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.discardAppend(rest)
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+
+	ctx := s.ctx
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.discardAppend(rest)
+		s.send(fmt.Sprintf("%s NO [TRYCREATE] Mailbox does not exist", cmd.Tag))
+		return
+	}
+	// ACL check: requires 'i' (insert) right
+	if err := s.emailService.CheckAccess(ctx, mb.UserID, mb.Name, s.user.Email, "i"); err != nil {
+		s.discardAppend(rest)
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+		return
+	}
+
+	// Quota Check: the messages count against the quota roots of the owner of the mailbox
+	quotas, err := s.quotaService.Roots(ctx, mb.UserID, mb.Name)
+	if err != nil {
+		s.discardAppend(rest)
+		s.send(fmt.Sprintf("%s NO Storage check failed", cmd.Tag))
+		return
+	}
+
+	maxTotal := s.config.MaxAppendSize
+	if maxTotal <= 0 {
+		maxTotal = defaultMaxAppendTotal
+	}
+
+	var messages []appendMessage
+	var total int64
+	args := cmd.Args[1:]
+	for {
+		msg, size, nonSync, err := parseAppendArgs(args)
+		if err != nil {
+			s.discardAppend(rest)
+			s.send(fmt.Sprintf("%s BAD Invalid APPEND arguments: %v", cmd.Tag, err))
+			return
+		}
+
+		total += size
+		var refusal string
+		if size > maxAppendSize {
+			refusal = fmt.Sprintf("%s NO [TOOBIG] Message too large", cmd.Tag)
+		} else if total > maxTotal {
+			refusal = fmt.Sprintf("%s NO [TOOBIG] Messages too large", cmd.Tag)
+		} else if err := services.CheckQuotas(quotas, map[string]int64{
+			domain.QuotaStorage: total,
+			domain.QuotaMessage: int64(len(messages) + 1),
+		}); err != nil {
+			refusal = overQuota(cmd.Tag, err)
+		}
+		if refusal != "" {
+			// The data of a synchronizing literal is only sent after the continuation
+			if nonSync {
+				s.discardAppend(rest)
+			}
+			s.send(refusal)
+			return
+		}
+
+		if !nonSync {
+			s.send("+ Ready for literal data")
+		}
+		msg.data = make([]byte, size)
+		if _, err := io.ReadFull(s.reader, msg.data); err != nil {
+			s.state = StateLogout
+			return
+		}
+		messages = append(messages, msg)
+
+		// The line goes on with the next message, or ends the command
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			s.state = StateLogout
+			return
+		}
+		rest = strings.TrimSpace(line)
+		if msg.utf8 {
+			if !strings.HasPrefix(rest, ")") {
+				s.discardAppend(rest)
+				s.send(fmt.Sprintf("%s BAD Invalid APPEND arguments: unterminated UTF8 data", cmd.Tag))
+				return
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+		if rest == "" {
+			break
+		}
+		args = splitArgs(rest)
+	}
+
+	uids := make([]uint32, 0, len(messages))
+	for _, m := range messages {
+		msg, err := s.storeAppended(mb, m)
+		if err != nil {
+			s.logger.Error("Failed to append message", "error", err)
+			s.send(fmt.Sprintf("%s NO Save failed", cmd.Tag))
+			return
+		}
+		uids = append(uids, msg.UID)
+	}
+
+	// RFC 4315 Section 3: APPENDUID reports the UIDs assigned to the messages
+	s.send(fmt.Sprintf("%s OK [APPENDUID %d %s] APPEND completed", cmd.Tag, mb.UIDValidity, formatSequenceSet(uids)))
+}
+
+// storeAppended writes an appended message to the blob store and the mailbox
+func (s *Session) storeAppended(mb *domain.Mailbox, m appendMessage) (*domain.Message, error) {
+	ctx := s.ctx
+	size := int64(len(m.data))
+
+	// Write to BlobStore
+	messageID := uuid.New().String()
+	path, err := s.blobStore.Write(ctx, messageID, m.data)
+	if err != nil {
+		return nil, fmt.Errorf("blob write failed: %w", err)
+	}
+
+	msg := &domain.Message{
+		ID:         messageID,
+		Recipient:  mb.UserID,
+		Mailbox:    mb.Name,
+		BodyPath:   path,
+		Size:       size,
+		Flags:      strings.Join(m.flags, " "),
+		ReceivedAt: m.date,
+	}
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now()
+	}
+	for _, flag := range m.flags {
+		if strings.EqualFold(flag, `\Seen`) {
+			msg.ReadState = true
+		}
+	}
+
+	// Best effort: a message that cannot be parsed is still stored, just not searchable by body
+	parsed, parseErr := mime.ParseMessage(m.data)
+	if parseErr == nil {
+		msg.Sender = parsed.From
+		msg.Subject = parsed.Subject
+		msg.MessageID = parsed.MessageID
+		msg.Snippet = parsed.Snippet
+		msg.SentAt = parsed.Date
+		msg.InReplyTo = parsed.InReplyTo
+		msg.References = parsed.References
+	}
+
+	if err := s.emailRepo.Save(ctx, msg); err != nil {
+		return nil, fmt.Errorf("save failed: %w", err)
+	}
+
+	if parseErr == nil && s.searchIdx != nil {
+		if err := s.searchIdx.Index(ctx, msg, parsed.PlainText); err != nil {
+			s.logger.Warn("Failed to index appended message", "error", err)
+		}
+	}
+	return msg, nil
+}
+
+// discardAppend consumes the rest of a rejected APPEND. Clients send the data of
+// non-synchronizing literals without waiting, so it would otherwise be read as commands.
+func (s *Session) discardAppend(line string) {
+	for {
+		_, size, nonSync, ok := parseLiteral(line)
+		if !ok || !nonSync {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, s.reader, size); err != nil {
+			return
+		}
+		next, err := s.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(next, "\r\n")
+	}
+}
@@ -0,0 +1,56 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAppendArgs(t *testing.T) {
+	date := time.Date(1996, time.July, 7, 2, 44, 25, 0, time.FixedZone("", -7*3600))
+
+	tests := []struct {
+		args    []string
+		flags   []string
+		date    time.Time
+		size    int64
+		nonSync bool
+		utf8    bool
+		wantErr bool
+	}{
+		{args: []string{"{12}"}, size: 12},
+		{args: []string{"(\\Seen", "\\Flagged)", "{5+}"}, flags: []string{"\\Seen", "\\Flagged"}, size: 5, nonSync: true},
+		{args: []string{"()", " 7-Jul-1996 02:44:25 -0700", "{1}"}, date: date, size: 1},
+		{args: []string{"(\\Seen)", "7-Jul-1996 02:44:25 -0700", "{1}"}, flags: []string{"\\Seen"}, date: date, size: 1},
+		{args: []string{"(\\Seen)", "UTF8", "(~{7+}"}, flags: []string{"\\Seen"}, size: 7, nonSync: true, utf8: true},
+		{args: []string{"utf8", "(~{7}"}, size: 7, utf8: true},
+		{args: []string{"UTF8", "(~", "{7}"}, size: 7, utf8: true},
+		{args: []string{"(\\Seen)", "~{9+}"}, flags: []string{"\\Seen"}, size: 9, nonSync: true},
+		{args: []string{"~", "{9}"}, size: 9},
+		{args: []string{}, wantErr: true},
+		{args: []string{"(\\Seen)"}, wantErr: true},
+		{args: []string{"(\\Recent)", "{1}"}, wantErr: true},
+		{args: []string{"(\\Seen", "{1}"}, wantErr: true},
+		{args: []string{"yesterday", "{1}"}, wantErr: true},
+		{args: []string{"(~{1}"}, wantErr: true},
+		{args: []string{"UTF8", "~{1}"}, wantErr: true},
+		{args: []string{"()", " 7-Jul-1996 02:44:25 -0700", "extra", "{1}"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		msg, size, nonSync, err := parseAppendArgs(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseAppendArgs(%q) expected error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAppendArgs(%q) unexpected error: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg.flags, tt.flags) || !msg.date.Equal(tt.date) || size != tt.size || nonSync != tt.nonSync || msg.utf8 != tt.utf8 {
+			t.Errorf("parseAppendArgs(%q) = %v, %v, %d, %v, %v", tt.args, msg.flags, msg.date, size, nonSync, msg.utf8)
+		}
+	}
+}
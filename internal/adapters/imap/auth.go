@@ -0,0 +1,97 @@
+package imap
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
+)
+
+// handleAuthenticate runs a SASL exchange (RFC 3501 Section 6.2.2), optionally starting
+// with an initial response (SASL-IR, RFC 4959)
+func (s *Session) handleAuthenticate(cmd *Command) {
+	if s.state != StateNotAuthenticated {
+		s.send(fmt.Sprintf("%s NO Already authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		s.send(fmt.Sprintf("%s BAD Invalid arguments", cmd.Tag))
+		return
+	}
+
+	mech := strings.ToUpper(cmd.Args[0])
+	server, err := s.auth.NewServer(s.ctx, mech, s.plaintextAllowed())
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO Unsupported authentication mechanism", cmd.Tag))
+		return
+	}
+
+	var response []byte
+	if len(cmd.Args) == 2 {
+		// "=" stands for an empty initial response
+		response = []byte{}
+		if cmd.Args[1] != "=" {
+			if response, err = base64.StdEncoding.DecodeString(cmd.Args[1]); err != nil {
+				s.send(fmt.Sprintf("%s BAD Invalid base64 in initial response", cmd.Tag))
+				return
+			}
+		}
+	}
+
+	for {
+		challenge, done, err := server.Next(response)
+		if err != nil {
+			s.authenticationFailed(cmd, mech, err)
+			return
+		}
+		if done && len(challenge) == 0 {
+			break
+		}
+
+		// Success data goes out as a last challenge, answered with an empty response
+		s.send("+ " + base64.StdEncoding.EncodeToString(challenge))
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			s.state = StateLogout
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "*" {
+			s.send(fmt.Sprintf("%s BAD Authentication cancelled", cmd.Tag))
+			return
+		}
+		if response, err = base64.StdEncoding.DecodeString(line); err != nil {
+			s.send(fmt.Sprintf("%s BAD Invalid base64 response", cmd.Tag))
+			return
+		}
+		if response == nil {
+			response = []byte{}
+		}
+		if done {
+			if len(response) != 0 {
+				s.send(fmt.Sprintf("%s BAD Unexpected response", cmd.Tag))
+				return
+			}
+			break
+		}
+	}
+
+	s.state = StateAuthenticated
+	s.user = server.User()
+	s.logger.Info("IMAP authentication success", "user", s.user.Email, "mechanism", mech)
+	s.send(fmt.Sprintf("%s OK [CAPABILITY %s] Authenticated", cmd.Tag, s.capability()))
+}
+
+func (s *Session) authenticationFailed(cmd *Command, mech string, err error) {
+	s.logger.Warn("IMAP authentication failed", "mechanism", mech, "error", err)
+	switch {
+	case errors.Is(err, sasl.ErrMalformed):
+		s.send(fmt.Sprintf("%s BAD Malformed authentication response", cmd.Tag))
+	case errors.Is(err, sasl.ErrAuthenticationFailed):
+		s.send(fmt.Sprintf("%s NO [AUTHENTICATIONFAILED] Authentication failed", cmd.Tag))
+	default:
+		s.send(fmt.Sprintf("%s NO [UNAVAILABLE] Authentication temporarily unavailable", cmd.Tag))
+	}
+}
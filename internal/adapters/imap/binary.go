@@ -0,0 +1,58 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// RFC 3516 BINARY: message parts fetched with their content transfer encoding removed
+
+var errUnknownCTE = errors.New("unknown content transfer encoding")
+
+// decodedContent returns the octets of a BINARY section, or ok=false if the part does not
+// exist. An empty section is the whole message, as it is.
+func (sec *section) decodedContent(raw []byte, root *mimePart) (data []byte, ok bool, err error) {
+	if len(sec.path) == 0 {
+		return raw, true, nil
+	}
+	part := root.part(sec.path)
+	if part == nil {
+		return nil, false, nil
+	}
+	data, err = decodeTransferEncoding(part.body, part.fields.Get("Content-Transfer-Encoding"))
+	return data, err == nil, err
+}
+
+// decodeTransferEncoding removes a content transfer encoding (RFC 2045 Section 6)
+func decodeTransferEncoding(body []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "7bit", "8bit", "binary":
+		return body, nil
+	case "base64":
+		// Line breaks, and any other whitespace, are not part of the encoding
+		clean := bytes.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, body)
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(clean)))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+	}
+	return nil, errUnknownCTE
+}
+
+// binaryLiteral formats data as a literal, or as a literal8 if it contains NUL octets,
+// which a literal can't carry (RFC 3516 Section 4.2)
+func binaryLiteral(data []byte) string {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return fmt.Sprintf("~{%d}\r\n%s", len(data), data)
+	}
+	return literal(data)
+}
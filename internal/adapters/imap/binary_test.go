@@ -0,0 +1,67 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeTransferEncoding(t *testing.T) {
+	tests := []struct {
+		body, encoding, want string
+	}{
+		{"Hello\r\n", "7bit", "Hello\r\n"},
+		{"Hello\r\n", "", "Hello\r\n"},
+		{"SGVs\r\nbG8A\r\n", "BASE64", "Hello\x00"},
+		{"Caf=C3=A9 =\r\nau lait", "quoted-printable", "Café au lait"},
+	}
+	for _, tt := range tests {
+		got, err := decodeTransferEncoding([]byte(tt.body), tt.encoding)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("decodeTransferEncoding(%q, %q) = %q, %v; want %q", tt.body, tt.encoding, got, err, tt.want)
+		}
+	}
+
+	if _, err := decodeTransferEncoding([]byte("x"), "x-uuencode"); err != errUnknownCTE {
+		t.Errorf("expected errUnknownCTE, got %v", err)
+	}
+}
+
+func TestParseBinaryFetchItems(t *testing.T) {
+	items, err := parseFetchItems("(BINARY[1] BINARY.PEEK[1.2]<0.10> BINARY.SIZE[2])")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items", len(items))
+	}
+	if items[0].name != "BINARY" || items[0].peek || !items[0].setsSeen() {
+		t.Errorf("got %+v", items[0])
+	}
+	if items[1].name != "BINARY" || !items[1].peek || !items[1].partial || items[1].count != 10 {
+		t.Errorf("got %+v", items[1])
+	}
+	if items[2].name != "BINARY.SIZE" || items[2].setsSeen() {
+		t.Errorf("got %+v", items[2])
+	}
+
+	for _, spec := range []string{"BINARY", "BINARY.SIZE[1]<0.5>", "BINARY.PEEK[1"} {
+		if _, err := parseFetchItems(spec); err == nil {
+			t.Errorf("parseFetchItems(%q) expected error, got nil", spec)
+		}
+	}
+	for _, spec := range []string{"1.HEADER", "TEXT", "1.MIME"} {
+		item := fetchItem{name: "BINARY", section: spec, hasBody: true}
+		if _, err := item.parseSection(); err == nil {
+			t.Errorf("BINARY[%s] expected error, got nil", spec)
+		}
+	}
+}
+
+func TestBinaryLiteral(t *testing.T) {
+	if got := binaryLiteral([]byte("a\x00b")); !strings.HasPrefix(got, "~{3}\r\n") {
+		t.Errorf("got %q", got)
+	}
+	if got := binaryLiteral([]byte("abc")); got != "{3}\r\nabc" {
+		t.Errorf("got %q", got)
+	}
+}
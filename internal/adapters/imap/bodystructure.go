@@ -0,0 +1,383 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// mimePart is one entity of a parsed message. Header and body are slices of the raw
+// message so that BODY[section] returns the exact octets that were stored.
+type mimePart struct {
+	header   []byte // Raw header, including the blank line that ends it
+	body     []byte
+	fields   textproto.MIMEHeader
+	typ      string // Lower-case media type, e.g. "text"
+	subtype  string // Lower-case media subtype, e.g. "plain"
+	params   map[string]string
+	parts    []*mimePart // Children of a multipart entity
+	message  *mimePart   // Encapsulated message of a message/rfc822 entity
+	isDigest bool        // Children default to message/rfc822 (RFC 2046 Section 5.1.5)
+}
+
+// maxMIMEDepth bounds the nesting of multiparts and encapsulated messages
+const maxMIMEDepth = 20
+
+// parseMessage parses a raw RFC 5322 message into its MIME structure.
+// It never fails; malformed entities are treated as text/plain.
+func parseMessage(raw []byte) *mimePart {
+	return parseEntity(raw, false, 0)
+}
+
+func parseEntity(raw []byte, inDigest bool, depth int) *mimePart {
+	p := &mimePart{}
+	p.header, p.body = splitHeader(raw)
+
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(p.header)))
+	fields, err := tp.ReadMIMEHeader()
+	if err != nil && fields == nil {
+		fields = textproto.MIMEHeader{}
+	}
+	p.fields = fields
+
+	// RFC 2045 Section 5.2: default is text/plain, or message/rfc822 inside multipart/digest
+	p.typ, p.subtype = "text", "plain"
+	p.params = map[string]string{"charset": "us-ascii"}
+	if inDigest {
+		p.typ, p.subtype = "message", "rfc822"
+		p.params = map[string]string{}
+	}
+	if ct := fields.Get("Content-Type"); ct != "" {
+		if mediaType, params, err := mime.ParseMediaType(ct); err == nil {
+			if typ, subtype, ok := strings.Cut(mediaType, "/"); ok {
+				p.typ, p.subtype, p.params = typ, subtype, params
+			}
+		}
+	}
+
+	if depth >= maxMIMEDepth {
+		return p
+	}
+
+	switch {
+	case p.typ == "multipart" && p.params["boundary"] != "":
+		p.isDigest = p.subtype == "digest"
+		for _, partRaw := range splitMultipart(p.body, p.params["boundary"]) {
+			p.parts = append(p.parts, parseEntity(partRaw, p.isDigest, depth+1))
+		}
+	case p.typ == "message" && p.subtype == "rfc822":
+		p.message = parseEntity(p.body, false, depth+1)
+	}
+	return p
+}
+
+// splitHeader splits an entity at the first empty line
+func splitHeader(raw []byte) (header, body []byte) {
+	for i := 0; i < len(raw); {
+		end := bytes.IndexByte(raw[i:], '\n')
+		if end < 0 {
+			break
+		}
+		line := raw[i : i+end+1]
+		if len(line) == 1 || (len(line) == 2 && line[0] == '\r') {
+			return raw[:i+end+1], raw[i+end+1:]
+		}
+		i += end + 1
+	}
+	return raw, nil
+}
+
+// splitMultipart returns the body parts between the boundary delimiters of a multipart
+// body (RFC 2046 Section 5.1.1). The CRLF preceding a delimiter belongs to the delimiter.
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delim := []byte("--" + boundary)
+	var parts [][]byte
+	start := -1
+
+	for i := 0; i < len(body); {
+		next := len(body)
+		if end := bytes.IndexByte(body[i:], '\n'); end >= 0 {
+			next = i + end + 1
+		}
+		line := bytes.TrimRight(body[i:next], "\r\n")
+
+		if rest, ok := bytes.CutPrefix(line, delim); ok {
+			closing := bytes.HasPrefix(rest, []byte("--"))
+			if closing || len(bytes.TrimSpace(rest)) == 0 {
+				if start >= 0 {
+					parts = append(parts, trimTrailingNewline(body[start:i]))
+				}
+				if closing {
+					return parts
+				}
+				start = next
+			}
+		}
+		i = next
+	}
+
+	// Missing close delimiter: keep what we have
+	if start >= 0 {
+		parts = append(parts, body[start:])
+	}
+	return parts
+}
+
+func trimTrailingNewline(b []byte) []byte {
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		b = b[:n-1]
+		if n := len(b); n > 0 && b[n-1] == '\r' {
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// part resolves a section part specifier such as "1.2" (RFC 3501 Section 6.4.5)
+func (p *mimePart) part(path []int) *mimePart {
+	cur := p
+	for i, n := range path {
+		// Inside a message/rfc822 entity, numbering continues in the encapsulated message
+		if i > 0 && cur.message != nil {
+			cur = cur.message
+		}
+		switch {
+		case len(cur.parts) > 0:
+			if n < 1 || n > len(cur.parts) {
+				return nil
+			}
+			cur = cur.parts[n-1]
+		case n == 1:
+			// A non-multipart message has a single part numbered 1: its body
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+// headerFields returns the header lines whose field name is (or, with not set, is not) in
+// names, followed by the blank line that ends a header
+func headerFields(header []byte, names []string, not bool) []byte {
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[strings.ToLower(name)] = true
+	}
+
+	var out bytes.Buffer
+	keep := false
+	for i := 0; i < len(header); {
+		next := len(header)
+		if end := bytes.IndexByte(header[i:], '\n'); end >= 0 {
+			next = i + end + 1
+		}
+		line := header[i:next]
+		i = next
+
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			break
+		}
+		// Continuation lines follow the decision made for their field
+		if line[0] != ' ' && line[0] != '\t' {
+			name, _, _ := bytes.Cut(line, []byte(":"))
+			keep = want[strings.ToLower(string(bytes.TrimSpace(name)))] != not
+		}
+		if keep {
+			out.Write(line)
+		}
+	}
+	out.WriteString("\r\n")
+	return out.Bytes()
+}
+
+// countLines returns the number of lines in b, as reported for text bodies
+func countLines(b []byte) int {
+	n := bytes.Count(b, []byte("\n"))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// bodyStructure formats the BODY or BODYSTRUCTURE (extended) fetch item of an entity
+// (RFC 3501 Section 7.4.2)
+func (p *mimePart) bodyStructure(extended bool) string {
+	var sb strings.Builder
+	sb.WriteString("(")
+
+	if len(p.parts) > 0 {
+		for _, child := range p.parts {
+			sb.WriteString(child.bodyStructure(extended))
+		}
+		sb.WriteString(" " + quoteString(strings.ToUpper(p.subtype)))
+		if extended {
+			sb.WriteString(" " + formatParams(p.params))
+			sb.WriteString(" " + p.disposition())
+			sb.WriteString(" " + p.language())
+			sb.WriteString(" " + nstring(p.fields.Get("Content-Location")))
+		}
+		sb.WriteString(")")
+		return sb.String()
+	}
+
+	typ, subtype := p.typ, p.subtype
+	if typ == "multipart" {
+		// A multipart without a usable boundary is reported as an opaque part
+		typ, subtype = "application", "octet-stream"
+	}
+	encoding := p.fields.Get("Content-Transfer-Encoding")
+	if encoding == "" {
+		encoding = "7BIT"
+	}
+
+	fmt.Fprintf(&sb, "%s %s %s %s %s %s %d",
+		quoteString(strings.ToUpper(typ)),
+		quoteString(strings.ToUpper(subtype)),
+		formatParams(p.params),
+		nstring(p.fields.Get("Content-ID")),
+		nstring(p.fields.Get("Content-Description")),
+		quoteString(strings.ToUpper(encoding)),
+		len(p.body),
+	)
+
+	switch {
+	case p.message != nil:
+		fmt.Fprintf(&sb, " %s %s %d", p.message.envelope(), p.message.bodyStructure(extended), countLines(p.body))
+	case typ == "text":
+		fmt.Fprintf(&sb, " %d", countLines(p.body))
+	}
+
+	if extended {
+		sb.WriteString(" " + nstring(p.fields.Get("Content-MD5")))
+		sb.WriteString(" " + p.disposition())
+		sb.WriteString(" " + p.language())
+		sb.WriteString(" " + nstring(p.fields.Get("Content-Location")))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (p *mimePart) disposition() string {
+	value := p.fields.Get("Content-Disposition")
+	if value == "" {
+		return "NIL"
+	}
+	disp, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "NIL"
+	}
+	return fmt.Sprintf("(%s %s)", quoteString(strings.ToUpper(disp)), formatParams(params))
+}
+
+func (p *mimePart) language() string {
+	value := p.fields.Get("Content-Language")
+	if value == "" {
+		return "NIL"
+	}
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, quoteString(tag))
+		}
+	}
+	if len(tags) == 0 {
+		return "NIL"
+	}
+	return "(" + strings.Join(tags, " ") + ")"
+}
+
+// envelope formats the ENVELOPE fetch item of a message (RFC 3501 Section 7.4.2)
+func (p *mimePart) envelope() string {
+	from := formatAddressList(p.fields.Get("From"))
+	sender := formatAddressList(p.fields.Get("Sender"))
+	if sender == "NIL" {
+		sender = from
+	}
+	replyTo := formatAddressList(p.fields.Get("Reply-To"))
+	if replyTo == "NIL" {
+		replyTo = from
+	}
+
+	return "(" + strings.Join([]string{
+		nstring(p.fields.Get("Date")),
+		nstring(p.fields.Get("Subject")),
+		from,
+		sender,
+		replyTo,
+		formatAddressList(p.fields.Get("To")),
+		formatAddressList(p.fields.Get("Cc")),
+		formatAddressList(p.fields.Get("Bcc")),
+		nstring(p.fields.Get("In-Reply-To")),
+		nstring(p.fields.Get("Message-Id")),
+	}, " ") + ")"
+}
+
+func formatAddressList(value string) string {
+	if value == "" {
+		return "NIL"
+	}
+	addrs, err := mail.ParseAddressList(value)
+	if err != nil || len(addrs) == 0 {
+		return "NIL"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("(")
+	for _, addr := range addrs {
+		local, host, _ := strings.Cut(addr.Address, "@")
+		name := addr.Name
+		if name != "" && !isASCII(name) {
+			name = mime.QEncoding.Encode("utf-8", name)
+		}
+		fmt.Fprintf(&sb, "(%s NIL %s %s)", nstring(name), nstring(local), nstring(host))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func formatParams(params map[string]string) string {
+	if len(params) == 0 {
+		return "NIL"
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var items []string
+	for _, k := range keys {
+		items = append(items, quoteString(strings.ToUpper(k)), quoteString(params[k]))
+	}
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+// nstring formats s as an IMAP nstring, using NIL for the empty string
+func nstring(s string) string {
+	if s == "" {
+		return "NIL"
+	}
+	return quoteString(s)
+}
+
+// quoteString formats s as an IMAP string: a quoted string where possible, otherwise a literal
+func quoteString(s string) string {
+	if strings.ContainsAny(s, "\r\n") || !isASCII(s) {
+		return fmt.Sprintf("{%d}\r\n%s", len(s), s)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
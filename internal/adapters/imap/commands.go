@@ -1,16 +1,8 @@
 package imap
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"strconv"
 	"strings"
-
-	"time"
-
-	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
-	"github.com/google/uuid"
 )
 
 // handleCommand dispatches to specific command handlers
@@ -18,32 +10,72 @@ func (s *Session) handleCommand(cmd *Command) {
 	switch cmd.Name {
 	case "CAPABILITY":
 		s.handleCapability(cmd)
+	case "ID":
+		s.handleID(cmd)
+	case "ENABLE":
+		s.handleEnable(cmd)
 	case "NOOP":
 		s.handleNoop(cmd)
+	case "CHECK":
+		s.handleCheck(cmd)
 	case "LOGOUT":
 		s.handleLogout(cmd)
 	case "LOGIN":
 		s.handleLogin(cmd)
+	case "AUTHENTICATE":
+		s.handleAuthenticate(cmd)
 	case "STARTTLS":
 		s.handleStartTLS(cmd)
+	case "NAMESPACE":
+		s.handleNamespace(cmd)
+	case "COMPRESS":
+		s.handleCompress(cmd)
 	case "LIST":
 		s.handleList(cmd)
 	case "SELECT":
 		s.handleSelect(cmd)
+	case "EXAMINE":
+		s.handleExamine(cmd)
+	case "LSUB":
+		s.handleLsub(cmd)
+	case "STATUS":
+		s.handleStatus(cmd)
 	case "CREATE":
 		s.handleCreate(cmd)
 	case "DELETE":
 		s.handleDelete(cmd)
+	case "RENAME":
+		s.handleRename(cmd)
+	case "SUBSCRIBE":
+		s.handleSubscribe(cmd)
+	case "UNSUBSCRIBE":
+		s.handleUnsubscribe(cmd)
 	case "FETCH":
 		s.handleFetch(cmd)
+	case "SEARCH":
+		s.handleSearch(cmd)
+	case "SORT":
+		s.handleSort(cmd)
+	case "THREAD":
+		s.handleThread(cmd)
 	case "UID":
 		s.handleUid(cmd)
 	case "STORE":
 		s.handleStore(cmd)
 	case "COPY":
 		s.handleCopy(cmd)
+	case "MOVE":
+		s.handleMove(cmd)
+	case "EXPUNGE":
+		s.handleExpunge(cmd)
+	case "CLOSE":
+		s.handleClose(cmd)
+	case "UNSELECT":
+		s.handleUnselect(cmd)
 	case "IDLE":
 		s.handleIdle(cmd)
+	case "NOTIFY":
+		s.handleNotify(cmd)
 	case "GETQUOTA":
 		s.handleGetQuota(cmd)
 	case "GETQUOTAROOT":
@@ -62,6 +94,10 @@ func (s *Session) handleCommand(cmd *Command) {
 		s.handleListRights(cmd)
 	case "MYRIGHTS":
 		s.handleMyRights(cmd)
+	case "GETMETADATA":
+		s.handleGetMetadata(cmd)
+	case "SETMETADATA":
+		s.handleSetMetadata(cmd)
 	default:
 		s.send(fmt.Sprintf("%s NO Unknown command", cmd.Tag))
 	}
@@ -69,16 +105,34 @@ func (s *Session) handleCommand(cmd *Command) {
 
 func (s *Session) handleCapability(cmd *Command) {
 	// RFC 3501 6.1.1
-	caps := "IMAP4rev1 STARTTLS AUTH=PLAIN ACL QUOTA IDLE"
-	s.send("* CAPABILITY " + caps)
+	s.send("* CAPABILITY " + s.capability())
 	s.send(fmt.Sprintf("%s OK CAPABILITY completed", cmd.Tag))
 }
 
 func (s *Session) handleNoop(cmd *Command) {
 	// RFC 3501 6.1.2
+	if s.state == StateSelected {
+		if err := s.syncMailbox(); err != nil {
+			s.logger.Error("NOOP failed to reload mailbox", "error", err)
+		}
+	}
+	// Events of the mailboxes watched with NOTIFY that arrived since the last command
+	if s.notify != nil {
+		s.deliverEvents(s.notify.pending())
+	}
 	s.send(fmt.Sprintf("%s OK NOOP completed", cmd.Tag))
 }
 
+// RFC 3501 6.4.1: changes are stored as they are made, so there is nothing for CHECK to
+// write. IMAP4rev2 dropped the command; it is still accepted.
+func (s *Session) handleCheck(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK CHECK completed", cmd.Tag))
+}
+
 func (s *Session) handleLogout(cmd *Command) {
 	// RFC 3501 6.1.3
 	s.send("* BYE Logging out")
@@ -94,7 +148,7 @@ func (s *Session) handleLogin(cmd *Command) {
 	}
 
 	// Security check: Don't allow LOGIN on insecure connection unless explicitly allowed
-	if !s.isTLS && !s.config.AllowInsecureAuth {
+	if !s.plaintextAllowed() {
 		s.send(fmt.Sprintf("%s NO [ALERT] LOGIN failed: privacy required (TLS needed)", cmd.Tag))
 		return
 	}
@@ -107,7 +161,8 @@ func (s *Session) handleLogin(cmd *Command) {
 	username := cmd.Args[0]
 	password := cmd.Args[1]
 
-	user, err := s.userRepo.Authenticate(s.ctx, username, password)
+	// Same path as AUTHENTICATE PLAIN, which derives missing SCRAM credentials
+	user, err := s.auth.VerifyPassword(s.ctx, username, password)
 	if err != nil {
 		s.logger.Warn("IMAP login failed", "user", username, "error", err)
 		s.send(fmt.Sprintf("%s NO [AUTHENTICATIONFAILED] Authentication failed", cmd.Tag))
@@ -117,7 +172,7 @@ func (s *Session) handleLogin(cmd *Command) {
 	s.state = StateAuthenticated
 	s.user = user
 	s.logger.Info("IMAP login success", "user", user.Email)
-	s.send(fmt.Sprintf("%s OK [CAPABILITY IMAP4rev1] Logged in", cmd.Tag))
+	s.send(fmt.Sprintf("%s OK [CAPABILITY %s] Logged in", cmd.Tag, s.capability()))
 }
 
 func (s *Session) handleStartTLS(cmd *Command) {
@@ -126,6 +181,15 @@ func (s *Session) handleStartTLS(cmd *Command) {
 		s.send(fmt.Sprintf("%s NO TLS already active", cmd.Tag))
 		return
 	}
+	if s.tlsConfig == nil {
+		s.send(fmt.Sprintf("%s NO TLS not available", cmd.Tag))
+		return
+	}
+	if _, ok := s.conn.(*deflateConn); ok {
+		// RFC 4978 Section 4: TLS has to be negotiated before compression
+		s.send(fmt.Sprintf("%s NO Compression already active", cmd.Tag))
+		return
+	}
 
 	s.send(fmt.Sprintf("%s OK Begin TLS negotiation now", cmd.Tag))
 
@@ -138,61 +202,16 @@ func (s *Session) handleStartTLS(cmd *Command) {
 	s.logger.Info("IMAP session upgraded to TLS")
 }
 
-func (s *Session) handleList(cmd *Command) {
-	if s.state < StateAuthenticated {
-		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
-		return
-	}
-
-	mailboxes, err := s.emailRepo.ListMailboxes(s.ctx, s.user.Email)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO List failed", cmd.Tag))
-		return
-	}
-
-	foundInbox := false
-	for _, mb := range mailboxes {
-		// ACL check: requires 'l' (lookup) right
-		if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "l"); err != nil {
-			continue
-		}
-
-		if strings.ToUpper(mb.Name) == "INBOX" {
-			foundInbox = true
-		}
-		// Basic attributes. RFC 3501 asks for \Marked \Noselect etc.
-		// For now simple \HasNoChildren is enough for leaves.
-		s.send(fmt.Sprintf(`* LIST (\HasNoChildren) "/" "%s"`, mb.Name))
-	}
-	if !foundInbox {
-		s.send(`* LIST (\HasNoChildren) "/" "INBOX"`)
-	}
-	s.send(fmt.Sprintf("%s OK LIST completed", cmd.Tag))
-}
-
-func (s *Session) handleCreate(cmd *Command) {
-	if s.state < StateAuthenticated {
-		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
-		return
-	}
-	if len(cmd.Args) < 1 {
-		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
-		return
-	}
-	name := cmd.Args[0]
-	err := s.emailRepo.CreateMailbox(s.ctx, s.user.Email, name)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Create failed", cmd.Tag))
-		return
-	}
-	s.send(fmt.Sprintf("%s OK CREATE completed", cmd.Tag))
+func (s *Session) handleSelect(cmd *Command) {
+	s.openMailbox(cmd, "SELECT", false)
 }
 
-func (s *Session) handleDelete(cmd *Command) {
-	s.send(fmt.Sprintf("%s NO DELETE not supported yet", cmd.Tag))
+// RFC 3501 6.3.2: EXAMINE is SELECT with the mailbox opened read-only
+func (s *Session) handleExamine(cmd *Command) {
+	s.openMailbox(cmd, "EXAMINE", true)
 }
 
-func (s *Session) handleSelect(cmd *Command) {
+func (s *Session) openMailbox(cmd *Command, command string, readOnly bool) {
 	if s.state < StateAuthenticated {
 		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
 		return
@@ -201,62 +220,89 @@ func (s *Session) handleSelect(cmd *Command) {
 		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
 		return
 	}
-	mailboxName := cmd.Args[0]
-	// INBOX is case-insensitive
-	if strings.ToUpper(mailboxName) == "INBOX" {
-		mailboxName = "INBOX"
+	// RFC 7162: optional (CONDSTORE) or (QRESYNC (...)) parameters
+	var resync *qresyncParams
+	if len(cmd.Args) > 1 {
+		condstore, qresync, err := parseSelectParams(cmd.Args[1:])
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+			return
+		}
+		if qresync != nil && !s.enabled["QRESYNC"] {
+			s.send(fmt.Sprintf("%s BAD QRESYNC is not enabled", cmd.Tag))
+			return
+		}
+		if condstore {
+			s.enabled["CONDSTORE"] = true
+		}
+		resync = qresync
 	}
 
-	mb, err := s.emailRepo.GetMailbox(s.ctx, s.user.Email, mailboxName)
-	if err != nil {
-		// Auto-create INBOX if not found
-		if strings.ToUpper(mailboxName) == "INBOX" {
-			err = s.emailRepo.CreateMailbox(s.ctx, s.user.Email, "INBOX")
-			if err == nil {
-				mb, err = s.emailRepo.GetMailbox(s.ctx, s.user.Email, "INBOX")
-			}
+	// A SELECT or EXAMINE closes the current mailbox without expunging, even if it fails.
+	// RFC 9051 Section 7.1: the CLOSED response code marks the end of its responses.
+	if s.state == StateSelected {
+		s.deselect()
+		if s.enabled["QRESYNC"] || s.enabled["IMAP4rev2"] {
+			s.send("* OK [CLOSED] Previous mailbox closed")
 		}
 	}
 
-	if err != nil || mb == nil {
-		s.send(fmt.Sprintf("%s NO Mailbox not found", cmd.Tag))
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox not found", cmd.Tag))
 		return
 	}
 
 	// ACL check: requires 'r' (read) right
 	// Note: Owner always has full access via CheckAccess logic
-	if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "r"); err != nil {
+	rights, err := s.emailService.Rights(s.ctx, mb.UserID, mb.Name, s.user.Email)
+	if err != nil || !strings.Contains(rights, "r") {
 		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
 		return
 	}
+	// RFC 4314 Section 4: without any right to change the mailbox, SELECT opens it read-only
+	if !strings.ContainsAny(rights, "stwie") {
+		readOnly = true
+	}
 
 	s.selectedMailbox = mb
+	if err := s.loadSequenceMap(); err != nil {
+		s.deselect()
+		s.send(fmt.Sprintf("%s NO DB Error", cmd.Tag))
+		return
+	}
 	s.state = StateSelected
-
-	s.send(fmt.Sprintf("* %d EXISTS", mb.MessageCount))
-	// Recent is usually 0 for new session unless tracked
-	s.send("* 0 RECENT")
+	s.readOnly = readOnly
+	s.modSeq, s.flagsSent = mb.HighestModSeq, make(map[uint32]uint64)
+
+	s.send(fmt.Sprintf("* %d EXISTS", len(s.uids)))
+	if s.enabled["IMAP4rev2"] {
+		// RFC 9051 Section 6.3.2: RECENT is gone, and a LIST response describes the mailbox
+		entries, err := s.listMailboxes([]string{s.mailboxPath(mb)}, listOptions{})
+		if err == nil && len(entries) == 1 {
+			s.sendListEntry(entries[0])
+		}
+	} else {
+		// Recent is usually 0 for new session unless tracked
+		s.send("* 0 RECENT")
+	}
 	s.send(fmt.Sprintf("* OK [UIDVALIDITY %d] UIDs valid", mb.UIDValidity))
 	s.send(fmt.Sprintf("* OK [UIDNEXT %d] Predicted next UID", mb.UIDNext))
 	s.send("* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)")
-	s.send(fmt.Sprintf("%s OK [READ-WRITE] SELECT completed", cmd.Tag))
-}
-
-func (s *Session) handleFetch(cmd *Command) {
-	if s.state != StateSelected {
-		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
-		return
+	access := "READ-WRITE"
+	if readOnly {
+		access = "READ-ONLY"
+		s.send("* OK [PERMANENTFLAGS ()] No permanent flags permitted")
+	} else {
+		s.send("* OK [PERMANENTFLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)] Flags permitted")
 	}
-
-	// ACL check: requires 'r' (read) right
-	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "r"); err != nil {
-		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
-		return
+	s.send(fmt.Sprintf("* OK [HIGHESTMODSEQ %d] Highest", mb.HighestModSeq))
+	if resync != nil {
+		if err := s.qresync(resync); err != nil {
+			s.logger.Error("IMAP QRESYNC failed", "error", err)
+		}
 	}
-
-	// Syntax: FETCH <sequence-set> <items>
-	s.logger.Warn("Partial FETCH implementation: assuming UID FETCH for simplicity or returning NO")
-	s.send(fmt.Sprintf("%s NO Use UID FETCH please", cmd.Tag))
+	s.send(fmt.Sprintf("%s OK [%s] %s completed", cmd.Tag, access, command))
 }
 
 func (s *Session) handleUid(cmd *Command) {
@@ -285,276 +331,22 @@ func (s *Session) handleUid(cmd *Command) {
 			return
 		}
 		s.handleUidCopy(cmd.Tag, cmd.Args[1], cmd.Args[2])
-	} else {
-		s.send(fmt.Sprintf("%s BAD Unknown UID command", cmd.Tag))
-	}
-}
-
-func (s *Session) handleUidFetch(tag string, rangeSpec string, items []string) {
-	// Parse range 1:* -> min=1, max=MAX
-	// Basic parsing
-	min, max := parseUidRange(rangeSpec)
-
-	msgs, err := s.emailRepo.FindByUIDRange(s.ctx, s.user.Email, s.selectedMailbox.Name, min, max)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO DB Error", tag))
-		return
-	}
-
-	showBody := false
-	for _, item := range items {
-		if strings.Contains(strings.ToUpper(item), "BODY[]") {
-			showBody = true
-		}
-	}
-
-	for i, msg := range msgs {
-		seq := i + 1 // Fake sequence number
-
-		var attrs []string
-		attrs = append(attrs, fmt.Sprintf("UID %d", msg.UID))
-
-		flags := msg.Flags
-		if msg.ReadState && !strings.Contains(flags, "\\Seen") {
-			flags += " \\Seen"
-		}
-		attrs = append(attrs, fmt.Sprintf("FLAGS (%s)", strings.TrimSpace(flags)))
-
-		if showBody {
-			// Mock content
-			attrs = append(attrs, "BODY[] {20}\r\nBody not loaded yet.")
-		} else {
-			attrs = append(attrs, fmt.Sprintf("RFC822.SIZE %d", 100)) // Mock
-		}
-
-		s.send(fmt.Sprintf("* %d FETCH (%s)", seq, strings.Join(attrs, " ")))
-	}
-
-	s.send(fmt.Sprintf("%s OK UID FETCH completed", tag))
-}
-
-func (s *Session) handleUidStore(tag string, rangeSpec string, args []string) {
-	// UID STORE 1 +FLAGS (\Seen)
-	if len(args) < 2 {
-		s.send(fmt.Sprintf("%s BAD Missing STORE args", tag))
-		return
-	}
-	mode := strings.ToUpper(args[0]) // +FLAGS, -FLAGS, FLAGS
-	flagsStr := strings.Join(args[1:], " ")
-	// Strip parens
-	flagsStr = strings.Trim(flagsStr, "()")
-	flags := strings.Fields(flagsStr)
-
-	min, max := parseUidRange(rangeSpec)
-	//nolint:errcheck // We should probably handle error but ignoring for MVP brevity
-	msgs, _ := s.emailRepo.FindByUIDRange(s.ctx, s.user.Email, s.selectedMailbox.Name, min, max)
-
-	for _, msg := range msgs {
-		switch mode {
-		case "+FLAGS":
-			if strings.Contains(mode, "+FLAGS") {
-				_ = s.emailRepo.AddFlags(s.ctx, msg.ID, flags...) //nolint:errcheck
-			}
-		case "-FLAGS":
-			if strings.Contains(mode, "-FLAGS") {
-				_ = s.emailRepo.RemoveFlags(s.ctx, msg.ID, flags...) //nolint:errcheck
-			}
-		case "FLAGS":
-			_ = s.emailRepo.SetFlags(s.ctx, msg.ID, flags...) //nolint:errcheck
+	} else if subCmd == "MOVE" {
+		// UID MOVE <range> <mailbox>
+		if len(cmd.Args) < 3 {
+			s.send(fmt.Sprintf("%s BAD Missing MOVE arguments", cmd.Tag))
+			return
 		}
-	}
-	s.send(fmt.Sprintf("%s OK UID STORE completed", tag))
-}
-
-func (s *Session) handleStore(cmd *Command) {
-	s.send(fmt.Sprintf("%s NO Use UID STORE", cmd.Tag))
-}
-
-func parseUidRange(rangeSpec string) (uint32, uint32) {
-	if rangeSpec == "*" {
-		return 1, 4294967295
-	}
-	parts := strings.Split(rangeSpec, ":")
-	if len(parts) == 1 {
-		v, _ := strconv.Atoi(parts[0]) //nolint:errcheck
-		return uint32(v), uint32(v)
-	}
-	min, _ := strconv.Atoi(parts[0]) //nolint:errcheck
-	maxStr := parts[1]
-	var max uint32
-	if maxStr == "*" {
-		max = 4294967295
+		s.handleUidMove(cmd.Tag, cmd.Args[1], cmd.Args[2])
+	} else if subCmd == "SEARCH" {
+		s.search(cmd.Tag, "UID SEARCH", cmd.Args[1:], true)
+	} else if subCmd == "SORT" {
+		s.sort(cmd.Tag, "UID SORT", cmd.Args[1:], true)
+	} else if subCmd == "THREAD" {
+		s.thread(cmd.Tag, "UID THREAD", cmd.Args[1:], true)
+	} else if subCmd == "EXPUNGE" {
+		s.handleUidExpunge(cmd.Tag, cmd.Args[1])
 	} else {
-		v, _ := strconv.Atoi(maxStr) //nolint:errcheck
-		max = uint32(v)
-	}
-	return uint32(min), max
-}
-
-func (s *Session) handleUidCopy(tag string, rangeSpec string, destName string) {
-	min, max := parseUidRange(rangeSpec)
-
-	msgs, err := s.emailRepo.FindByUIDRange(s.ctx, s.user.Email, s.selectedMailbox.Name, min, max)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO DB Error", tag))
-		return
-	}
-	if len(msgs) == 0 {
-		s.send(fmt.Sprintf("%s NO No messages in range", tag))
-		return
-	}
-
-	// Check Quota
-	var totalSize int64
-	for _, msg := range msgs {
-		totalSize += msg.Size
-	}
-
-	ctx := s.ctx
-	user, err := s.userRepo.FindByEmail(ctx, s.user.Email)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Storage check failed", tag))
-		return
-	}
-	if user.StorageQuota > 0 && (user.StorageUsed+totalSize > user.StorageQuota) {
-		s.send(fmt.Sprintf("%s NO [OVERQUOTA] Storage limit exceeded", tag))
-		return
-	}
-
-	// Perform Copy
-	var ids []string
-	for _, m := range msgs {
-		ids = append(ids, m.ID)
-	}
-
-	err = s.emailRepo.CopyMessages(s.ctx, s.user.Email, ids, destName)
-	if err != nil {
-		s.logger.Error("IMAP COPY Error", "error", err)
-		s.send(fmt.Sprintf("%s NO Copy failed", tag))
-		return
-	}
-
-	// Update Usage
-	//nolint:errcheck // Best effort storage usage update
-	_ = s.userRepo.IncrementStorageUsed(ctx, s.user.Email, totalSize)
-
-	// Spam Training Hook
-	srcJunk := strings.EqualFold(s.selectedMailbox.Name, "Junk")
-	destJunk := strings.EqualFold(destName, "Junk")
-
-	if s.spamService != nil && (srcJunk != destJunk) {
-		// Async training
-		trainingMsgs := msgs // Copy slice header
-		go func(messages []*domain.Message, isSpam bool) {
-			ctx := s.ctx
-			for _, msg := range messages {
-				if s.blobStore == nil {
-					continue
-				}
-				contentBytes, err := s.blobStore.Read(ctx, msg.BodyPath)
-				if err != nil {
-					s.logger.Error("Failed to read blob for training", "error", err)
-					continue
-				}
-
-				reader := bytes.NewReader(contentBytes)
-				if isSpam {
-					if err := s.spamService.TrainSpam(ctx, reader); err != nil {
-						s.logger.Error("Failed to train spam", "error", err, "msg_id", msg.ID)
-					}
-				} else {
-					if err := s.spamService.TrainHam(ctx, reader); err != nil {
-						s.logger.Error("Failed to train ham", "error", err, "msg_id", msg.ID)
-					}
-				}
-			}
-		}(trainingMsgs, destJunk)
-	}
-
-	s.send(fmt.Sprintf("%s OK UID COPY completed", tag))
-}
-
-func (s *Session) handleCopy(cmd *Command) {
-	s.send(fmt.Sprintf("%s NO Use UID COPY", cmd.Tag))
-}
-
-func (s *Session) handleAppend(cmd *Command) {
-	if s.state != StateAuthenticated && s.state != StateSelected {
-		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
-		return
-	}
-	if len(cmd.Args) < 2 {
-		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
-		return
-	}
-
-	mailboxName := cmd.Args[0]
-	// Check for literal size in last argument
-	literalArg := cmd.Args[len(cmd.Args)-1]
-	if !strings.HasPrefix(literalArg, "{") || !strings.HasSuffix(literalArg, "}") {
-		s.send(fmt.Sprintf("%s BAD Missing literal size", cmd.Tag))
-		return
-	}
-
-	sizeStr := strings.Trim(literalArg, "{}")
-	size, err := strconv.ParseInt(sizeStr, 10, 64)
-	if err != nil {
-		s.send(fmt.Sprintf("%s BAD Invalid size", cmd.Tag))
-		return
-	}
-
-	// Quota Check
-	ctx := s.ctx
-	user, err := s.userRepo.FindByEmail(ctx, s.user.Email)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO Storage check failed", cmd.Tag))
-		return
-	}
-
-	if user.StorageQuota > 0 && (user.StorageUsed+size > user.StorageQuota) {
-		s.send(fmt.Sprintf("%s NO [OVERQUOTA] Storage limit exceeded", cmd.Tag))
-		return
-	}
-
-	// Send continuation
-	s.send("+ Ready for literal data")
-
-	// Read data
-	data := make([]byte, size)
-	_, err = io.ReadFull(s.reader, data)
-	if err != nil {
-		return
-	}
-
-	// Write to BlobStore
-	messageID := uuid.New().String()
-	path, err := s.blobStore.Write(ctx, messageID, data)
-	if err != nil {
-		s.logger.Error("Blob write failed", "error", err)
-		s.send(fmt.Sprintf("%s NO Storage failed", cmd.Tag))
-		return
-	}
-
-	msg := &domain.Message{
-		ID:         messageID,
-		Recipient:  user.Email,
-		Mailbox:    mailboxName,
-		BodyPath:   path,
-		Size:       size,
-		ReceivedAt: time.Now(),
-		// TODO: Parse Flags and Date from cmd.Args if present
-		// TODO: Parse MIME to get Subject, Sender, MessageID
-	}
-
-	if err := s.emailRepo.Save(ctx, msg); err != nil {
-		s.send(fmt.Sprintf("%s NO Save failed", cmd.Tag))
-		return
-	}
-
-	//nolint:errcheck // Best effort storage usage update
-	if err := s.userRepo.IncrementStorageUsed(ctx, s.user.Email, size); err != nil {
-		s.logger.Error("Failed to update storage usage", "error", err)
+		s.send(fmt.Sprintf("%s BAD Unknown UID command", cmd.Tag))
 	}
-
-	s.send(fmt.Sprintf("%s OK APPEND completed", cmd.Tag))
 }
@@ -0,0 +1,74 @@
+package imap
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// deflateConn compresses both directions of a connection with DEFLATE (RFC 1951). Writes
+// are flushed one by one so that every response reaches the client right away.
+type deflateConn struct {
+	net.Conn
+	r io.ReadCloser
+	w *flate.Writer
+}
+
+// newDeflateConn wraps conn; data already buffered from it is read through in
+func newDeflateConn(conn net.Conn, in io.Reader) (*deflateConn, error) {
+	w, err := flate.NewWriter(conn, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return &deflateConn{Conn: conn, r: flate.NewReader(in), w: w}, nil
+}
+
+func (c *deflateConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *deflateConn) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
+
+func (c *deflateConn) Close() error {
+	//nolint:errcheck // The connection is closed regardless
+	_ = c.w.Close()
+	return c.Conn.Close()
+}
+
+// handleCompress COMPRESS DEFLATE (RFC 4978). Compression starts right after the tagged
+// OK and lasts until the connection closes.
+func (s *Session) handleCompress(cmd *Command) {
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) != 1 || !strings.EqualFold(cmd.Args[0], "DEFLATE") {
+		s.send(fmt.Sprintf("%s BAD Unsupported compression mechanism", cmd.Tag))
+		return
+	}
+	if _, ok := s.conn.(*deflateConn); ok {
+		s.send(fmt.Sprintf("%s NO [COMPRESSIONACTIVE] Compression already active", cmd.Tag))
+		return
+	}
+
+	// The reader may already hold the first compressed bytes, so inflating starts from it
+	conn, err := newDeflateConn(s.conn, s.reader)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO Compression not available", cmd.Tag))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK DEFLATE active", cmd.Tag))
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	s.writer = bufio.NewWriter(conn)
+}
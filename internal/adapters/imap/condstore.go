@@ -0,0 +1,232 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 7162 CONDSTORE and QRESYNC, turned on with RFC 5161 ENABLE
+
+// handleEnable ENABLE <capability>... (RFC 5161). Besides CONDSTORE and QRESYNC, clients
+// can enable UTF8=ACCEPT (RFC 6855) and IMAP4rev2 (RFC 9051).
+func (s *Session) handleEnable(cmd *Command) {
+	if s.state != StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO ENABLE is only valid in the authenticated state", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) == 0 {
+		s.send(fmt.Sprintf("%s BAD Missing capabilities", cmd.Tag))
+		return
+	}
+
+	var enabled []string
+	for _, arg := range cmd.Args {
+		name := strings.ToUpper(arg)
+		switch name {
+		case "CONDSTORE", "QRESYNC", "UTF8=ACCEPT", "IMAP4REV2":
+			if name == "IMAP4REV2" {
+				name = "IMAP4rev2"
+			}
+		default:
+			// Unknown or non-enableable extensions are ignored
+			continue
+		}
+		if !s.enabled[name] {
+			enabled = append(enabled, name)
+		}
+		s.enabled[name] = true
+		// RFC 7162 Section 3.2.3: enabling QRESYNC implies CONDSTORE
+		if name == "QRESYNC" {
+			s.enabled["CONDSTORE"] = true
+		}
+		// RFC 9051 Section 6.3.1: IMAP4rev2 mailbox names are UTF-8
+		if name == "IMAP4rev2" {
+			s.enabled["UTF8=ACCEPT"] = true
+		}
+	}
+
+	s.send(strings.TrimSpace("* ENABLED " + strings.Join(enabled, " ")))
+	s.send(fmt.Sprintf("%s OK ENABLE completed", cmd.Tag))
+}
+
+// qresyncParams is the QRESYNC parameter of SELECT and EXAMINE (RFC 7162 Section 3.2.5)
+type qresyncParams struct {
+	uidValidity uint32
+	modSeq      uint64
+	knownUIDs   sequenceSet // nil means all UIDs
+}
+
+// parseSelectParams parses the optional parameter list of SELECT and EXAMINE,
+// e.g. "(CONDSTORE)" or "(QRESYNC (67890007 20050715194045000 41:211,214:541))"
+func parseSelectParams(args []string) (condstore bool, qresync *qresyncParams, err error) {
+	tokens := searchTokens(args)
+	if len(tokens) < 2 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return false, nil, fmt.Errorf("invalid SELECT parameters")
+	}
+	tokens = tokens[1 : len(tokens)-1]
+
+	for i := 0; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "CONDSTORE":
+			condstore = true
+		case "QRESYNC":
+			if i+1 >= len(tokens) || tokens[i+1] != "(" {
+				return false, nil, fmt.Errorf("missing QRESYNC arguments")
+			}
+			// Collect the top-level values; the optional sequence match data is only a hint
+			var values []string
+			depth := 0
+			for i++; i < len(tokens); i++ {
+				if tokens[i] == "(" {
+					depth++
+				} else if tokens[i] == ")" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				} else if depth == 1 {
+					values = append(values, tokens[i])
+				}
+			}
+			if depth != 0 || len(values) < 2 {
+				return false, nil, fmt.Errorf("invalid QRESYNC arguments")
+			}
+
+			qresync = &qresyncParams{}
+			uidValidity, err1 := strconv.ParseUint(values[0], 10, 32)
+			modSeq, err2 := strconv.ParseUint(values[1], 10, 64)
+			if err1 != nil || err2 != nil || uidValidity == 0 || modSeq == 0 {
+				return false, nil, fmt.Errorf("invalid QRESYNC arguments")
+			}
+			qresync.uidValidity, qresync.modSeq = uint32(uidValidity), modSeq
+			if len(values) > 2 {
+				if qresync.knownUIDs, err = parseSequenceSet(values[2]); err != nil {
+					return false, nil, err
+				}
+			}
+		default:
+			return false, nil, fmt.Errorf("unknown SELECT parameter %q", tokens[i])
+		}
+	}
+	return condstore, qresync, nil
+}
+
+// largestUID returns the value of "*" in a UID set: the highest UID ever assigned in the
+// selected mailbox, so that expunged UIDs are covered as well
+func (s *Session) largestUID() uint32 {
+	largest := uint32(0)
+	if s.selectedMailbox.UIDNext > 0 {
+		largest = s.selectedMailbox.UIDNext - 1
+	}
+	if n := len(s.uids); n > 0 && s.uids[n-1] > largest {
+		largest = s.uids[n-1]
+	}
+	return largest
+}
+
+// vanished returns the UIDs in set expunged after modSeq
+func (s *Session) vanished(set sequenceSet, modSeq uint64) ([]uint32, error) {
+	expunged, err := s.emailRepo.FindExpunged(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, modSeq)
+	if err != nil {
+		return nil, err
+	}
+	largest := s.largestUID()
+	var uids []uint32
+	for _, uid := range expunged {
+		if set == nil || set.contains(uid, largest) {
+			uids = append(uids, uid)
+		}
+	}
+	return uids, nil
+}
+
+// qresync reports what changed since the client's last synchronization: the UIDs that
+// vanished, then the flags of every message changed after the given mod-sequence
+func (s *Session) qresync(p *qresyncParams) error {
+	// With a different UIDVALIDITY the client has to resynchronize from scratch
+	if p.uidValidity != s.selectedMailbox.UIDValidity {
+		return nil
+	}
+
+	uids, err := s.vanished(p.knownUIDs, p.modSeq)
+	if err != nil {
+		return err
+	}
+	if len(uids) > 0 {
+		s.send("* VANISHED (EARLIER) " + formatSequenceSet(uids))
+	}
+
+	changed, err := s.emailRepo.FindInMailbox(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, domain.MailboxFilter{ChangedSince: p.modSeq})
+	if err != nil {
+		return err
+	}
+	largest := s.largestUID()
+	for _, msg := range changed {
+		seq := s.seqNum(msg.UID)
+		if seq == 0 || (p.knownUIDs != nil && !p.knownUIDs.contains(msg.UID, largest)) {
+			continue
+		}
+		s.send(fmt.Sprintf("* %d FETCH (UID %d FLAGS (%s) MODSEQ (%d))", seq, msg.UID, messageFlags(msg), msg.ModSeq))
+	}
+	return nil
+}
+
+// fetchModifiers are the RFC 7162 modifiers of FETCH and UID FETCH
+type fetchModifiers struct {
+	changedSince uint64
+	vanished     bool
+}
+
+// splitFetchModifiers separates the data items of a FETCH from its trailing modifier list:
+// the items end at the first space outside parentheses and brackets
+func splitFetchModifiers(spec string) (items, modifiers string) {
+	spec = strings.TrimSpace(spec)
+	depth := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ' ':
+			if depth == 0 {
+				return spec[:i], strings.TrimSpace(spec[i+1:])
+			}
+		}
+	}
+	return spec, ""
+}
+
+// parseFetchModifiers parses a list such as "(CHANGEDSINCE 12345 VANISHED)"
+func parseFetchModifiers(spec string) (fetchModifiers, error) {
+	var mods fetchModifiers
+	if spec == "" {
+		return mods, nil
+	}
+	if !strings.HasPrefix(spec, "(") || !strings.HasSuffix(spec, ")") {
+		return mods, fmt.Errorf("invalid FETCH modifiers")
+	}
+	fields := strings.Fields(spec[1 : len(spec)-1])
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "CHANGEDSINCE":
+			if i+1 >= len(fields) {
+				return mods, fmt.Errorf("missing CHANGEDSINCE value")
+			}
+			i++
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return mods, fmt.Errorf("invalid CHANGEDSINCE value %q", fields[i])
+			}
+			mods.changedSince = n
+		case "VANISHED":
+			mods.vanished = true
+		default:
+			return mods, fmt.Errorf("unknown FETCH modifier %q", fields[i])
+		}
+	}
+	return mods, nil
+}
@@ -0,0 +1,51 @@
+package imap
+
+import "testing"
+
+func TestParseSelectParams(t *testing.T) {
+	condstore, qresync, err := parseSelectParams([]string{"(CONDSTORE)"})
+	if err != nil || !condstore || qresync != nil {
+		t.Errorf("(CONDSTORE): got condstore=%v qresync=%v err=%v", condstore, qresync, err)
+	}
+
+	_, qresync, err = parseSelectParams([]string{"(QRESYNC", "(67890007", "90060115194045000", "41:211,214:541", "(1,3:5", "10,20:22)))"})
+	if err != nil {
+		t.Fatalf("QRESYNC: unexpected error: %v", err)
+	}
+	if qresync.uidValidity != 67890007 || qresync.modSeq != 90060115194045000 {
+		t.Errorf("QRESYNC: got %+v", qresync)
+	}
+	if !qresync.knownUIDs.contains(300, 600) || qresync.knownUIDs.contains(212, 600) {
+		t.Errorf("QRESYNC: wrong known UIDs %v", qresync.knownUIDs)
+	}
+
+	for _, args := range [][]string{{"CONDSTORE"}, {"(FOO)"}, {"(QRESYNC)"}, {"(QRESYNC", "(1))"}, {"(QRESYNC", "(0", "5))"}} {
+		if _, _, err := parseSelectParams(args); err == nil {
+			t.Errorf("parseSelectParams(%q) expected error, got nil", args)
+		}
+	}
+}
+
+func TestSplitFetchModifiers(t *testing.T) {
+	tests := []struct {
+		spec, items, modifiers string
+	}{
+		{"FLAGS", "FLAGS", ""},
+		{"(FLAGS UID) (CHANGEDSINCE 5)", "(FLAGS UID)", "(CHANGEDSINCE 5)"},
+		{"BODY[HEADER.FIELDS (TO FROM)] (CHANGEDSINCE 5 VANISHED)", "BODY[HEADER.FIELDS (TO FROM)]", "(CHANGEDSINCE 5 VANISHED)"},
+	}
+	for _, tt := range tests {
+		items, modifiers := splitFetchModifiers(tt.spec)
+		if items != tt.items || modifiers != tt.modifiers {
+			t.Errorf("splitFetchModifiers(%q) = %q, %q; want %q, %q", tt.spec, items, modifiers, tt.items, tt.modifiers)
+		}
+	}
+
+	mods, err := parseFetchModifiers("(CHANGEDSINCE 12345 VANISHED)")
+	if err != nil || mods.changedSince != 12345 || !mods.vanished {
+		t.Errorf("parseFetchModifiers: got %+v, %v", mods, err)
+	}
+	if _, err := parseFetchModifiers("(CHANGEDSINCE x)"); err == nil {
+		t.Error("parseFetchModifiers with invalid value expected error, got nil")
+	}
+}
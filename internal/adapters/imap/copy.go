@@ -0,0 +1,205 @@
+package imap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
+)
+
+// RFC 3501 Section 6.4.7
+func (s *Session) handleCopy(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing COPY arguments", cmd.Tag))
+		return
+	}
+	s.transfer(cmd.Tag, "COPY", cmd.Args[0], cmd.Args[1], false, false)
+}
+
+func (s *Session) handleUidCopy(tag string, rangeSpec string, destName string) {
+	s.transfer(tag, "UID COPY", rangeSpec, destName, true, false)
+}
+
+// RFC 6851 Section 3.1
+func (s *Session) handleMove(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing MOVE arguments", cmd.Tag))
+		return
+	}
+	s.transfer(cmd.Tag, "MOVE", cmd.Args[0], cmd.Args[1], false, true)
+}
+
+func (s *Session) handleUidMove(tag string, rangeSpec string, destName string) {
+	s.transfer(tag, "UID MOVE", rangeSpec, destName, true, true)
+}
+
+// transfer copies or moves the messages in setSpec to destName and reports the new UIDs
+// with a COPYUID response code (RFC 4315 Section 3)
+func (s *Session) transfer(tag, command, setSpec, destName string, byUID, move bool) {
+	set, err := s.parseSequenceArg(setSpec, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	if move {
+		// A move expunges from the source, so it needs the same rights as EXPUNGE
+		if s.readOnly {
+			s.send(fmt.Sprintf("%s NO [READ-ONLY] Mailbox is read-only", tag))
+			return
+		}
+		if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "te"); err != nil {
+			s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+			return
+		}
+	}
+
+	msgs, err := s.selectMessages(set, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO DB Error", tag))
+		return
+	}
+	if len(msgs) == 0 {
+		s.send(fmt.Sprintf("%s NO No messages in range", tag))
+		return
+	}
+
+	dest, err := s.findMailbox(s.mailboxArg(destName))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [TRYCREATE] Mailbox does not exist", tag))
+		return
+	}
+	// ACL check: requires 'i' (insert) right on the destination
+	if err := s.emailService.CheckAccess(s.ctx, dest.UserID, dest.Name, s.user.Email, "i"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+	// A moved message keeps its row, which can't change owner
+	if move && dest.UserID != s.selectedMailbox.UserID {
+		s.send(fmt.Sprintf("%s NO [CANNOT] Messages can only be moved between mailboxes of the same owner", tag))
+		return
+	}
+
+	var ids []string
+	var totalSize int64
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+		// Copies are stored with the size of messages recorded before sizes were kept
+		msg.Size = s.messageSize(msg)
+		totalSize += msg.Size
+	}
+	// The messages count against the quota roots of the destination. Moving keeps them
+	// with the same owner, so only the root of the destination mailbox can be affected.
+	quotas, err := s.quotaService.Roots(s.ctx, dest.UserID, dest.Name)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO Storage check failed", tag))
+		return
+	}
+	if move {
+		var mailboxQuotas []*domain.Quota
+		for _, quota := range quotas {
+			if quota.Root.Mailbox != "" && quota.Root.Mailbox != s.selectedMailbox.Name {
+				mailboxQuotas = append(mailboxQuotas, quota)
+			}
+		}
+		quotas = mailboxQuotas
+	}
+	if err := services.CheckQuotas(quotas, map[string]int64{
+		domain.QuotaStorage: totalSize,
+		domain.QuotaMessage: int64(len(msgs)),
+	}); err != nil {
+		s.send(overQuota(tag, err))
+		return
+	}
+
+	var uidMap map[uint32]uint32
+	if move {
+		uidMap, err = s.emailRepo.MoveMessages(s.ctx, dest.UserID, s.selectedMailbox.Name, ids, dest.Name)
+	} else {
+		// The copies count against the owner of the destination
+		uidMap, err = s.emailRepo.CopyMessages(s.ctx, dest.UserID, msgs, dest.Name)
+	}
+	if errors.Is(err, ports.ErrNotFound) {
+		s.send(fmt.Sprintf("%s NO [TRYCREATE] Mailbox does not exist", tag))
+		return
+	}
+	if err != nil {
+		s.logger.Error("IMAP "+command+" Error", "error", err)
+		s.send(fmt.Sprintf("%s NO %s failed", tag, command))
+		return
+	}
+
+	s.trainOnTransfer(msgs, dest.Name)
+
+	// Messages are in UID order and new UIDs are assigned in the same order,
+	// so both sets can be compressed into ranges independently
+	var srcUIDs, destUIDs []uint32
+	for _, msg := range msgs {
+		if newUID, ok := uidMap[msg.UID]; ok {
+			srcUIDs = append(srcUIDs, msg.UID)
+			destUIDs = append(destUIDs, newUID)
+		}
+	}
+	code := ""
+	if len(srcUIDs) > 0 {
+		code = fmt.Sprintf("[COPYUID %d %s %s] ", dest.UIDValidity, formatSequenceSet(srcUIDs), formatSequenceSet(destUIDs))
+	}
+
+	if move {
+		// RFC 6851 Section 4.3: COPYUID goes in an untagged OK sent before the EXPUNGEs
+		if code != "" {
+			s.send(fmt.Sprintf("* OK %sMoved", code))
+		}
+		s.removeMessages(srcUIDs, true)
+		s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK %s%s completed", tag, code, command))
+}
+
+// trainOnTransfer trains the spam filter when messages are moved into or out of Junk
+func (s *Session) trainOnTransfer(msgs []*domain.Message, destName string) {
+	srcJunk := strings.EqualFold(s.selectedMailbox.Name, "Junk")
+	destJunk := strings.EqualFold(destName, "Junk")
+
+	if s.spamService == nil || srcJunk == destJunk {
+		return
+	}
+
+	// Async training
+	go func(messages []*domain.Message, isSpam bool) {
+		ctx := s.ctx
+		for _, msg := range messages {
+			if s.blobStore == nil {
+				continue
+			}
+			contentBytes, err := s.blobStore.Read(ctx, msg.BodyPath)
+			if err != nil {
+				s.logger.Error("Failed to read blob for training", "error", err)
+				continue
+			}
+
+			reader := bytes.NewReader(contentBytes)
+			if isSpam {
+				if err := s.spamService.TrainSpam(ctx, reader); err != nil {
+					s.logger.Error("Failed to train spam", "error", err, "msg_id", msg.ID)
+				}
+			} else {
+				if err := s.spamService.TrainHam(ctx, reader); err != nil {
+					s.logger.Error("Failed to train ham", "error", err, "msg_id", msg.ID)
+				}
+			}
+		}
+	}(msgs, destJunk)
+}
@@ -0,0 +1,213 @@
+package imap
+
+import (
+	"fmt"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 3501 Section 6.4.3
+func (s *Session) handleExpunge(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.runExpunge(cmd.Tag, "EXPUNGE", nil)
+}
+
+// RFC 4315 Section 2.1: UID EXPUNGE only removes \Deleted messages in the given UID set
+func (s *Session) handleUidExpunge(tag string, rangeSpec string) {
+	set, err := s.parseSequenceArg(rangeSpec, true)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	s.runExpunge(tag, "UID EXPUNGE", set)
+}
+
+func (s *Session) runExpunge(tag, command string, uidSet sequenceSet) {
+	if s.readOnly {
+		s.send(fmt.Sprintf("%s NO [READ-ONLY] Mailbox is read-only", tag))
+		return
+	}
+	// ACL check: requires 'e' (expunge) right
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "e"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+	if err := s.expunge(uidSet, true); err != nil {
+		s.logger.Error("IMAP EXPUNGE Error", "error", err)
+		s.send(fmt.Sprintf("%s NO Expunge failed", tag))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+// RFC 3501 Section 6.4.2: CLOSE silently expunges (unless read-only) and deselects
+func (s *Session) handleClose(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	if !s.readOnly && s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "e") == nil {
+		if err := s.expunge(nil, false); err != nil {
+			s.logger.Error("IMAP CLOSE expunge failed", "error", err)
+		}
+	}
+	s.deselect()
+	s.send(fmt.Sprintf("%s OK CLOSE completed", cmd.Tag))
+}
+
+// RFC 3691: UNSELECT deselects without expunging
+func (s *Session) handleUnselect(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.deselect()
+	s.send(fmt.Sprintf("%s OK UNSELECT completed", cmd.Tag))
+}
+
+func (s *Session) deselect() {
+	s.selectedMailbox = nil
+	s.uids = nil
+	s.modSeq, s.flagsSent = 0, nil
+	s.readOnly = false
+	s.searchResult = nil
+	s.state = StateAuthenticated
+}
+
+// expunge permanently removes the \Deleted messages of the selected mailbox, restricted to
+// uidSet if it is non-nil. Released storage is credited to the mailbox owner and blobs are
+// deleted once no copy references them. If report is set an untagged EXPUNGE is sent for
+// every removed message.
+func (s *Session) expunge(uidSet sequenceSet, report bool) error {
+	if len(s.uids) == 0 {
+		return nil
+	}
+	owner, name := s.selectedMailbox.UserID, s.selectedMailbox.Name
+
+	msgs, err := s.emailRepo.FindInMailbox(s.ctx, owner, name, domain.MailboxFilter{Flags: []string{"\\Deleted"}})
+	if err != nil {
+		return err
+	}
+
+	largest := s.uids[len(s.uids)-1]
+	var expunged []*domain.Message
+	var removed []uint32
+	for _, msg := range msgs {
+		// Messages the session has not been told about yet stay until the next sync
+		if s.seqNum(msg.UID) == 0 {
+			continue
+		}
+		if uidSet != nil && !uidSet.contains(msg.UID, largest) {
+			continue
+		}
+		// Messages stored before sizes were recorded release the size of their blob
+		msg.Size = s.messageSize(msg)
+		expunged = append(expunged, msg)
+		removed = append(removed, msg.UID)
+	}
+	if len(expunged) == 0 {
+		return nil
+	}
+
+	orphaned, err := s.emailRepo.DeleteMessages(s.ctx, owner, name, expunged)
+	if err != nil {
+		return err
+	}
+	s.releaseBlobs(orphaned)
+
+	s.removeMessages(removed, report)
+	return nil
+}
+
+// messageSize returns the size of a message, reading its blob for messages stored before
+// sizes were recorded
+func (s *Session) messageSize(msg *domain.Message) int64 {
+	size := msg.Size
+	if size == 0 && s.blobStore != nil {
+		if raw, err := s.blobStore.Read(s.ctx, msg.BodyPath); err == nil {
+			size = int64(len(raw))
+		}
+	}
+	return size
+}
+
+// releaseBlobs deletes the blobs that no message references anymore
+func (s *Session) releaseBlobs(orphaned []string) {
+	if s.blobStore == nil {
+		return
+	}
+	for _, path := range orphaned {
+		if err := s.blobStore.Delete(s.ctx, path); err != nil {
+			s.logger.Error("Failed to delete blob", "error", err, "path", path)
+		}
+	}
+}
+
+// removeMessages drops UIDs from the sequence map, sending an untagged EXPUNGE for each
+// if report is set. QRESYNC clients get a single VANISHED response instead.
+func (s *Session) removeMessages(uids []uint32, report bool) {
+	if s.enabled["QRESYNC"] {
+		var removed []uint32
+		for _, uid := range uids {
+			if seq := s.seqNum(uid); seq > 0 {
+				s.uids = append(s.uids[:seq-1], s.uids[seq:]...)
+				removed = append(removed, uid)
+			}
+		}
+		if report && len(removed) > 0 {
+			s.send("* VANISHED " + formatSequenceSet(removed))
+		}
+		s.selectedMailbox.MessageCount = len(s.uids)
+		return
+	}
+
+	// Each EXPUNGE renumbers the following messages, so sequence numbers are resolved one by one
+	for _, uid := range uids {
+		seq := s.seqNum(uid)
+		if seq == 0 {
+			continue
+		}
+		s.uids = append(s.uids[:seq-1], s.uids[seq:]...)
+		if report {
+			s.send(fmt.Sprintf("* %d EXPUNGE", seq))
+		}
+	}
+	s.selectedMailbox.MessageCount = len(s.uids)
+}
+
+// syncMailbox reloads the sequence map of the selected mailbox and reports changes made by
+// other sessions: an EXPUNGE for every message that disappeared, EXISTS if new messages
+// arrived, then the new flags of the messages the client already knew about
+func (s *Session) syncMailbox() error {
+	known := s.uids
+	if err := s.loadSequenceMap(); err != nil {
+		s.uids = known
+		return err
+	}
+
+	current := make(map[uint32]bool, len(s.uids))
+	for _, uid := range s.uids {
+		current[uid] = true
+	}
+	var vanished []uint32
+	for i, uid := range known {
+		if !current[uid] {
+			if !s.enabled["QRESYNC"] {
+				s.send(fmt.Sprintf("* %d EXPUNGE", i+1-len(vanished)))
+			}
+			vanished = append(vanished, uid)
+		}
+	}
+	if s.enabled["QRESYNC"] && len(vanished) > 0 {
+		s.send("* VANISHED " + formatSequenceSet(vanished))
+	}
+	expunged := len(vanished)
+	if len(s.uids) != len(known)-expunged {
+		s.send(fmt.Sprintf("* %d EXISTS", len(s.uids)))
+	}
+	s.selectedMailbox.MessageCount = len(s.uids)
+	return s.reportChanges(known)
+}
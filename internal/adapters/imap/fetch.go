@@ -0,0 +1,495 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 3501 Section 6.4.5 FETCH
+
+// internalDateLayout is the date-time format of INTERNALDATE (RFC 3501 Section 9)
+const internalDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+// fetchItem is one requested message data item
+type fetchItem struct {
+	name    string // Upper-case item name, e.g. "FLAGS", "BODY", "RFC822.HEADER"
+	section string // Section text between the brackets of BODY[...], as sent by the client
+	hasBody bool   // BODY[...] or a BINARY item (RFC 3516) rather than the BODY structure
+	peek    bool   // BODY.PEEK, BINARY.PEEK or BINARY.SIZE: do not set \Seen
+	partial bool
+	offset  int64
+	count   int64
+}
+
+// needsContent reports whether the item can only be answered from the message blob
+func (it fetchItem) needsContent() bool {
+	switch it.name {
+	case "UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "MODSEQ":
+		return false
+	}
+	return true
+}
+
+// setsSeen reports whether fetching the item implicitly sets the \Seen flag
+func (it fetchItem) setsSeen() bool {
+	return (it.hasBody && !it.peek) || it.name == "RFC822" || it.name == "RFC822.TEXT"
+}
+
+// parseFetchItems parses the data items of a FETCH command: a single item, a macro
+// (ALL, FAST, FULL) or a parenthesized list
+func parseFetchItems(spec string) ([]fetchItem, error) {
+	spec = strings.TrimSpace(spec)
+	switch strings.ToUpper(spec) {
+	case "ALL":
+		spec = "(FLAGS INTERNALDATE RFC822.SIZE ENVELOPE)"
+	case "FAST":
+		spec = "(FLAGS INTERNALDATE RFC822.SIZE)"
+	case "FULL":
+		spec = "(FLAGS INTERNALDATE RFC822.SIZE ENVELOPE BODY)"
+	}
+	if strings.HasPrefix(spec, "(") {
+		if !strings.HasSuffix(spec, ")") {
+			return nil, fmt.Errorf("unbalanced parenthesis")
+		}
+		spec = spec[1 : len(spec)-1]
+	}
+
+	var items []fetchItem
+	for i := 0; i < len(spec); {
+		if spec[i] == ' ' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(spec) && spec[i] != ' ' && spec[i] != '[' {
+			i++
+		}
+		item := fetchItem{name: strings.ToUpper(spec[start:i])}
+
+		if i < len(spec) && spec[i] == '[' {
+			switch item.name {
+			case "BODY", "BODY.PEEK", "BINARY", "BINARY.PEEK", "BINARY.SIZE":
+			default:
+				return nil, fmt.Errorf("unexpected section on %s", item.name)
+			}
+			end := strings.IndexByte(spec[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated section")
+			}
+			item.section = spec[i+1 : i+end]
+			item.hasBody = true
+			item.peek = strings.HasSuffix(item.name, ".PEEK") || item.name == "BINARY.SIZE"
+			item.name = strings.TrimSuffix(item.name, ".PEEK")
+			i += end + 1
+
+			if i < len(spec) && spec[i] == '<' && item.name != "BINARY.SIZE" {
+				end := strings.IndexByte(spec[i:], '>')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated partial")
+				}
+				offsetStr, countStr, ok := strings.Cut(spec[i+1:i+end], ".")
+				offset, err1 := strconv.ParseInt(offsetStr, 10, 64)
+				count, err2 := strconv.ParseInt(countStr, 10, 64)
+				if !ok || err1 != nil || err2 != nil || offset < 0 || count <= 0 {
+					return nil, fmt.Errorf("invalid partial %q", spec[i:i+end+1])
+				}
+				item.partial, item.offset, item.count = true, offset, count
+				i += end + 1
+			}
+		}
+
+		switch item.name {
+		case "UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "BODYSTRUCTURE",
+			"RFC822", "RFC822.HEADER", "RFC822.TEXT", "MODSEQ":
+		case "BODY":
+		case "BINARY", "BINARY.SIZE":
+			if !item.hasBody {
+				return nil, fmt.Errorf("missing section on %s", item.name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown fetch item %q", item.name)
+		}
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no fetch items")
+	}
+	return items, nil
+}
+
+// parseSection parses the section of a BODY or BINARY item; BINARY sections are part
+// numbers only (RFC 3516 Section 4.1)
+func (it fetchItem) parseSection() (*section, error) {
+	sec, err := parseSection(it.section)
+	if err == nil && it.name != "BODY" && sec.specifier != "" {
+		return nil, fmt.Errorf("invalid %s section %q", it.name, it.section)
+	}
+	return sec, err
+}
+
+// section is a parsed BODY[...] section specification
+type section struct {
+	path      []int    // Part number, e.g. [1 2] for "1.2"; empty for the whole message
+	specifier string   // "", "HEADER", "HEADER.FIELDS", "HEADER.FIELDS.NOT", "TEXT" or "MIME"
+	fields    []string // Header field names for HEADER.FIELDS[.NOT]
+}
+
+func parseSection(spec string) (*section, error) {
+	sec := &section{}
+	rest := strings.TrimSpace(spec)
+
+	for rest != "" {
+		head, tail, _ := strings.Cut(rest, ".")
+		n, err := strconv.Atoi(head)
+		if err != nil {
+			break
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid part number %q", head)
+		}
+		sec.path = append(sec.path, n)
+		rest = tail
+	}
+
+	name, list, _ := strings.Cut(rest, " ")
+	sec.specifier = strings.ToUpper(name)
+	switch sec.specifier {
+	case "", "HEADER", "TEXT":
+	case "MIME":
+		if len(sec.path) == 0 {
+			return nil, fmt.Errorf("MIME requires a part number")
+		}
+	case "HEADER.FIELDS", "HEADER.FIELDS.NOT":
+		list = strings.TrimSpace(list)
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return nil, fmt.Errorf("missing header field list")
+		}
+		sec.fields = strings.Fields(list[1 : len(list)-1])
+		if len(sec.fields) == 0 {
+			return nil, fmt.Errorf("empty header field list")
+		}
+		return sec, nil
+	default:
+		return nil, fmt.Errorf("unknown section %q", name)
+	}
+	if strings.TrimSpace(list) != "" {
+		return nil, fmt.Errorf("unexpected data after section %q", name)
+	}
+	return sec, nil
+}
+
+// content returns the octets of the section, or ok=false if the part does not exist
+func (sec *section) content(raw []byte, root *mimePart) ([]byte, bool) {
+	part := root
+	if len(sec.path) > 0 {
+		if part = root.part(sec.path); part == nil {
+			return nil, false
+		}
+	}
+
+	if sec.specifier == "" {
+		if len(sec.path) == 0 {
+			return raw, true
+		}
+		return part.body, true
+	}
+	if sec.specifier == "MIME" {
+		return part.header, true
+	}
+
+	// HEADER and TEXT of a part refer to its encapsulated message
+	msg := part
+	if len(sec.path) > 0 {
+		if msg = part.message; msg == nil {
+			return nil, false
+		}
+	}
+	switch sec.specifier {
+	case "HEADER":
+		return msg.header, true
+	case "TEXT":
+		return msg.body, true
+	case "HEADER.FIELDS":
+		return headerFields(msg.header, sec.fields, false), true
+	default: // HEADER.FIELDS.NOT
+		return headerFields(msg.header, sec.fields, true), true
+	}
+}
+
+// literal formats data as an IMAP literal
+func literal(data []byte) string {
+	return fmt.Sprintf("{%d}\r\n%s", len(data), data)
+}
+
+// messageFlags returns the IMAP flags of a message; the read state implies \Seen
+func messageFlags(msg *domain.Message) string {
+	flags := msg.Flags
+	if msg.ReadState && !strings.Contains(flags, "\\Seen") {
+		flags += " \\Seen"
+	}
+	return strings.TrimSpace(flags)
+}
+
+// handleFetch FETCH <sequence-set> <items> [(<modifiers>)]
+func (s *Session) handleFetch(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing FETCH arguments", cmd.Tag))
+		return
+	}
+	s.fetch(cmd.Tag, "FETCH", cmd.Args[0], cmd.Args[1:], false)
+}
+
+// handleUidFetch UID FETCH <uid-set> <items> [(<modifiers>)]
+func (s *Session) handleUidFetch(tag string, rangeSpec string, items []string) {
+	if len(items) == 0 {
+		s.send(fmt.Sprintf("%s BAD Missing FETCH items", tag))
+		return
+	}
+	s.fetch(tag, "UID FETCH", rangeSpec, items, true)
+}
+
+func (s *Session) fetch(tag, command, setSpec string, args []string, byUID bool) {
+	// ACL check: requires 'r' (read) right
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "r"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+
+	set, err := s.parseSequenceArg(setSpec, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	itemSpec, modifierSpec := splitFetchModifiers(strings.Join(args, " "))
+	items, err := parseFetchItems(itemSpec)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	mods, err := parseFetchModifiers(modifierSpec)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	// RFC 7162 Section 3.2.6: VANISHED needs UID FETCH, CHANGEDSINCE and an enabled QRESYNC
+	if mods.vanished && (!byUID || mods.changedSince == 0 || !s.enabled["QRESYNC"]) {
+		s.send(fmt.Sprintf("%s BAD VANISHED not allowed here", tag))
+		return
+	}
+	if mods.changedSince > 0 || hasFetchItem(items, "MODSEQ") {
+		s.enabled["CONDSTORE"] = true
+	}
+	// Once CONDSTORE is in effect, flags are reported with their mod-sequence
+	if s.enabled["CONDSTORE"] && !hasFetchItem(items, "MODSEQ") && (hasFetchItem(items, "FLAGS") || mods.changedSince > 0) {
+		items = append(items, fetchItem{name: "MODSEQ"})
+	}
+	sections := make(map[int]*section)
+	for i, item := range items {
+		if item.hasBody {
+			sec, err := item.parseSection()
+			if err != nil {
+				s.send(fmt.Sprintf("%s BAD %v", tag, err))
+				return
+			}
+			sections[i] = sec
+		}
+	}
+
+	// UID FETCH always reports the UID (RFC 3501 Section 6.4.8)
+	if byUID && !hasFetchItem(items, "UID") {
+		items = append([]fetchItem{{name: "UID"}}, items...)
+		shifted := make(map[int]*section, len(sections))
+		for i, sec := range sections {
+			shifted[i+1] = sec
+		}
+		sections = shifted
+	}
+
+	msgs, err := s.selectMessages(set, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO DB Error", tag))
+		return
+	}
+	if mods.changedSince > 0 {
+		changed := msgs[:0]
+		for _, msg := range msgs {
+			if msg.ModSeq > mods.changedSince {
+				changed = append(changed, msg)
+			}
+		}
+		msgs = changed
+	}
+	if mods.vanished {
+		uids, err := s.vanished(set, mods.changedSince)
+		if err != nil {
+			s.send(fmt.Sprintf("%s NO DB Error", tag))
+			return
+		}
+		if len(uids) > 0 {
+			s.send("* VANISHED (EARLIER) " + formatSequenceSet(uids))
+		}
+	}
+
+	needsContent, setsSeen := false, false
+	for _, item := range items {
+		needsContent = needsContent || item.needsContent()
+		setsSeen = setsSeen || item.setsSeen()
+	}
+	// Setting \Seen requires the 's' right; without it the fetch behaves like BODY.PEEK
+	if setsSeen {
+		setsSeen = !s.readOnly && s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "s") == nil
+	}
+
+	failed, undecodable := false, false
+	for _, msg := range msgs {
+		seq := s.seqNum(msg.UID)
+		if seq == 0 {
+			continue
+		}
+
+		var raw []byte
+		var root *mimePart
+		if needsContent || msg.Size == 0 {
+			raw, err = s.blobStore.Read(s.ctx, msg.BodyPath)
+			if err != nil {
+				s.logger.Error("IMAP FETCH failed to read message", "error", err, "msg_id", msg.ID)
+				failed = true
+				continue
+			}
+			msg.Size = int64(len(raw))
+			if needsContent {
+				root = parseMessage(raw)
+			}
+		}
+
+		flagsChanged := false
+		if setsSeen && !strings.Contains(messageFlags(msg), "\\Seen") {
+			if err := s.emailRepo.AddFlags(s.ctx, msg.ID, "\\Seen"); err != nil {
+				s.logger.Error("IMAP FETCH failed to set \\Seen", "error", err, "msg_id", msg.ID)
+			} else {
+				//nolint:errcheck // Best effort: the \Seen flag is authoritative for IMAP
+				_ = s.emailRepo.UpdateReadState(s.ctx, msg.ID, true)
+				msg.ReadState = true
+				flagsChanged = true
+				if updated, err := s.emailRepo.FindByID(s.ctx, msg.ID); err == nil {
+					msg.Flags, msg.ModSeq = updated.Flags, updated.ModSeq
+					s.flagsSent[msg.UID] = msg.ModSeq
+				}
+			}
+		}
+
+		var attrs []string
+		for i, item := range items {
+			attr, err := s.fetchAttr(msg, raw, root, item, sections[i])
+			if err != nil {
+				s.logger.Warn("IMAP FETCH failed to decode message part", "error", err, "msg_id", msg.ID)
+				undecodable = true
+				attrs = nil
+				break
+			}
+			attrs = append(attrs, attr)
+		}
+		if attrs == nil {
+			continue
+		}
+		// RFC 3501 Section 6.4.5: a flag change caused by the fetch is reported
+		if flagsChanged && !hasFetchItem(items, "FLAGS") {
+			attrs = append(attrs, fmt.Sprintf("FLAGS (%s)", messageFlags(msg)))
+			if s.enabled["CONDSTORE"] && !hasFetchItem(items, "MODSEQ") {
+				attrs = append(attrs, fmt.Sprintf("MODSEQ (%d)", msg.ModSeq))
+			}
+		}
+
+		s.send(fmt.Sprintf("* %d FETCH (%s)", seq, strings.Join(attrs, " ")))
+	}
+
+	if failed {
+		s.send(fmt.Sprintf("%s NO Some messages could not be read", tag))
+		return
+	}
+	if undecodable {
+		s.send(fmt.Sprintf("%s NO [UNKNOWN-CTE] Some message parts could not be decoded", tag))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+func hasFetchItem(items []fetchItem, name string) bool {
+	for _, item := range items {
+		if item.name == name && !item.hasBody {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchAttr formats one message data item of a FETCH response. It fails if a BINARY
+// section can't be decoded.
+func (s *Session) fetchAttr(msg *domain.Message, raw []byte, root *mimePart, item fetchItem, sec *section) (string, error) {
+	switch item.name {
+	case "UID":
+		return fmt.Sprintf("UID %d", msg.UID), nil
+	case "FLAGS":
+		return fmt.Sprintf("FLAGS (%s)", messageFlags(msg)), nil
+	case "INTERNALDATE":
+		return fmt.Sprintf("INTERNALDATE %q", msg.ReceivedAt.Format(internalDateLayout)), nil
+	case "RFC822.SIZE":
+		return fmt.Sprintf("RFC822.SIZE %d", msg.Size), nil
+	case "MODSEQ":
+		return fmt.Sprintf("MODSEQ (%d)", msg.ModSeq), nil
+	case "ENVELOPE":
+		return "ENVELOPE " + root.envelope(), nil
+	case "BODYSTRUCTURE":
+		return "BODYSTRUCTURE " + root.bodyStructure(true), nil
+	case "RFC822":
+		return "RFC822 " + literal(raw), nil
+	case "RFC822.HEADER":
+		return "RFC822.HEADER " + literal(root.header), nil
+	case "RFC822.TEXT":
+		return "RFC822.TEXT " + literal(root.body), nil
+	}
+
+	if !item.hasBody {
+		return "BODY " + root.bodyStructure(false), nil
+	}
+
+	// BODY[...], BINARY[...] and BINARY.SIZE[...]
+	label := item.name + "[" + item.section + "]"
+	var data []byte
+	ok := false
+	if item.name == "BODY" {
+		data, ok = sec.content(raw, root)
+	} else {
+		var err error
+		if data, ok, err = sec.decodedContent(raw, root); err != nil {
+			return "", err
+		}
+	}
+	if item.name == "BINARY.SIZE" {
+		return fmt.Sprintf("%s %d", label, len(data)), nil
+	}
+	if !ok {
+		return label + " NIL", nil
+	}
+	if item.partial {
+		label += fmt.Sprintf("<%d>", item.offset)
+		if item.offset >= int64(len(data)) {
+			data = nil
+		} else {
+			data = data[item.offset:min(item.offset+item.count, int64(len(data)))]
+		}
+	}
+	if item.name == "BINARY" {
+		return label + " " + binaryLiteral(data), nil
+	}
+	return label + " " + literal(data), nil
+}
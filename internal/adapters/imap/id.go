@@ -0,0 +1,91 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+)
+
+// RFC 2971 IMAP4 ID extension
+
+// maxIDFields is the number of field-value pairs a client may send (RFC 2971 Section 3.3)
+const maxIDFields = 30
+
+// idField is a field-value pair of an ID parameter list; a NIL value is empty
+type idField struct {
+	name  string
+	value string
+}
+
+// parseIDParams parses the argument of ID: NIL or a parenthesized list of field-value pairs
+func parseIDParams(args []string) ([]idField, error) {
+	if len(args) == 1 && strings.EqualFold(args[0], "NIL") {
+		return nil, nil
+	}
+	tokens, err := pairListArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens)%2 != 0 {
+		return nil, fmt.Errorf("field without value")
+	}
+	if len(tokens)/2 > maxIDFields {
+		return nil, fmt.Errorf("too many fields")
+	}
+	fields := make([]idField, 0, len(tokens)/2)
+	for i := 0; i < len(tokens); i += 2 {
+		value := tokens[i+1]
+		if value == "NIL" {
+			value = ""
+		}
+		fields = append(fields, idField{name: tokens[i], value: value})
+	}
+	return fields, nil
+}
+
+// pairListArgs returns the strings of a parenthesized list of pairs, e.g. ("a" "b c"),
+// as split into arguments. The closing parenthesis may follow a literal on its own.
+func pairListArgs(args []string) ([]string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "(") {
+		return nil, fmt.Errorf("expected a parenthesized list")
+	}
+	tokens := append([]string{}, args...)
+	tokens[0] = tokens[0][1:]
+	last := len(tokens) - 1
+	switch {
+	case tokens[last] == ")" && last%2 == 1:
+		// A quoted empty string before the parenthesis, as in ("a" "")
+		tokens[last] = ""
+	case tokens[last] == ")":
+		tokens = tokens[:last]
+	case strings.HasSuffix(tokens[last], ")"):
+		tokens[last] = strings.TrimSuffix(tokens[last], ")")
+	default:
+		return nil, fmt.Errorf("unterminated list")
+	}
+	if len(tokens) == 1 && tokens[0] == "" {
+		return nil, nil
+	}
+	return tokens, nil
+}
+
+// handleID ID <NIL | (field value ...)>. Valid in every state: the client's identification
+// is logged, and the server identifies itself in return.
+func (s *Session) handleID(cmd *Command) {
+	fields, err := parseIDParams(cmd.Args)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD Invalid ID parameters: %v", cmd.Tag, err))
+		return
+	}
+	if len(fields) > 0 {
+		client := make([]string, 0, len(fields))
+		for _, f := range fields {
+			client = append(client, fmt.Sprintf("%s=%q", strings.ToLower(f.name), f.value))
+		}
+		s.logger.Info("IMAP client identified", "client", strings.Join(client, " "), "remote", s.conn.RemoteAddr())
+	}
+
+	s.send(fmt.Sprintf(`* ID ("name" "MailRaven" "vendor" "MailRaven" "version" %s)`, quoteString(config.Version)))
+	s.send(fmt.Sprintf("%s OK ID completed", cmd.Tag))
+}
@@ -0,0 +1,35 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIDParams(t *testing.T) {
+	tests := []struct {
+		line   string
+		fields []idField
+	}{
+		{"NIL", nil},
+		{`()`, nil},
+		{`("name" "Thunderbird" "version" "128.0")`, []idField{{"name", "Thunderbird"}, {"version", "128.0"}}},
+		{`("name" "Mail" "os" NIL)`, []idField{{"name", "Mail"}, {"os", ""}}},
+		{`("name" "")`, []idField{{"name", ""}}},
+	}
+	for _, tt := range tests {
+		fields, err := parseIDParams(splitArgs(tt.line))
+		if err != nil {
+			t.Errorf("parseIDParams(%s) unexpected error: %v", tt.line, err)
+			continue
+		}
+		if (len(fields) != 0 || len(tt.fields) != 0) && !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("parseIDParams(%s) = %+v, want %+v", tt.line, fields, tt.fields)
+		}
+	}
+
+	for _, line := range []string{`"name" "Mail"`, `("name")`, `("name" "Mail"`} {
+		if _, err := parseIDParams(splitArgs(line)); err == nil {
+			t.Errorf("parseIDParams(%s) expected error, got nil", line)
+		}
+	}
+}
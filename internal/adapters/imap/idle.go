@@ -4,26 +4,41 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 )
 
+// handleIdle IDLE (RFC 2177). Changes other sessions make to the selected mailbox are
+// reported as they happen, as are the events the client asked for with NOTIFY. Without a
+// selected mailbox only the latter are delivered.
 func (s *Session) handleIdle(cmd *Command) {
-	if s.state != StateSelected {
-		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
 		return
 	}
 
-	s.send("+ idling")
-
 	ctx, cancel := context.WithCancel(s.ctx)
 	defer cancel()
 
-	// Subscribe to notifications via the distributed bus
-	eventCh, unsubscribe, err := s.notificationBus.Listen(ctx, s.user.Email)
-	if err != nil {
-		s.send(fmt.Sprintf("%s NO IDLE failed: %v", cmd.Tag, err))
-		return
+	// Subscribe to notifications of the mailbox owner via the distributed bus, unless NOTIFY
+	// already listens to them
+	var eventCh <-chan ports.NotificationEvent
+	if s.selectedMailbox != nil && (s.notify == nil || !s.notify.owners[s.selectedMailbox.UserID]) {
+		ch, unsubscribe, err := s.notificationBus.Listen(ctx, s.selectedMailbox.UserID)
+		if err != nil {
+			s.send(fmt.Sprintf("%s NO IDLE failed: %v", cmd.Tag, err))
+			return
+		}
+		defer unsubscribe()
+		eventCh = ch
+	}
+	var notifyCh <-chan ports.NotificationEvent
+	if s.notify != nil {
+		notifyCh = s.notify.events
 	}
-	defer unsubscribe()
+
+	s.send("+ idling")
 
 	doneCh := make(chan error, 1)
 
@@ -50,12 +65,105 @@ func (s *Session) handleIdle(cmd *Command) {
 			s.send(fmt.Sprintf("%s OK IDLE terminated", cmd.Tag))
 			return
 
-		case evt := <-eventCh:
-			if evt.EventType == "new_message" && s.selectedMailbox != nil && evt.Mailbox == s.selectedMailbox.Name {
-				s.selectedMailbox.MessageCount++
-				s.send(fmt.Sprintf("* %d EXISTS", s.selectedMailbox.MessageCount))
-				s.send(fmt.Sprintf("* %d RECENT", 1))
+		case evt, ok := <-eventCh:
+			if !ok {
+				eventCh = nil
+				continue
 			}
+			// A burst of events, e.g. from a STORE on many messages, is reported at once
+			s.deliverEvents(append([]ports.NotificationEvent{evt}, drainEvents(eventCh)...))
+
+		case evt := <-notifyCh:
+			s.deliverEvents(append([]ports.NotificationEvent{evt}, s.notify.pending()...))
+			if s.notify == nil {
+				notifyCh = nil
+			}
+		}
+	}
+}
+
+// drainEvents returns the events queued on ch without waiting for more
+func drainEvents(ch <-chan ports.NotificationEvent) []ports.NotificationEvent {
+	var events []ports.NotificationEvent
+	for {
+		select {
+		case evt, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, evt)
+		default:
+			return events
+		}
+	}
+}
+
+// deliverEvents reports a batch of mailbox change events: changes to the selected mailbox
+// are found by a single syncMailbox, the events of other mailboxes watched with NOTIFY are
+// sent as STATUS responses
+func (s *Session) deliverEvents(events []ports.NotificationEvent) {
+	var others []ports.NotificationEvent
+	changed := false
+	for _, evt := range events {
+		if s.selectedMailbox != nil && evt.UserID == s.selectedMailbox.UserID && evt.Mailbox == s.selectedMailbox.Name {
+			changed = true
+			continue
+		}
+		others = append(others, evt)
+	}
+	if changed {
+		if err := s.syncMailbox(); err != nil {
+			s.logger.Error("IDLE failed to reload mailbox", "error", err)
+		}
+	}
+	if s.notify != nil {
+		s.notifyStatus(others)
+	}
+}
+
+// reportChanges follows a reload of the sequence map by syncMailbox. It sends the new
+// flags of the messages the client knew, unless the session reported the change itself,
+// and with NOTIFY the data items requested for new messages.
+func (s *Session) reportChanges(known []uint32) error {
+	mb := s.selectedMailbox
+	changed, err := s.emailRepo.FindInMailbox(s.ctx, mb.UserID, mb.Name, domain.MailboxFilter{ChangedSince: s.modSeq})
+	if err != nil {
+		return err
+	}
+
+	wasKnown := make(map[uint32]bool, len(known))
+	for _, uid := range known {
+		wasKnown[uid] = true
+	}
+	highest := s.modSeq
+	for _, msg := range changed {
+		highest = max(highest, msg.ModSeq)
+		seq := s.seqNum(msg.UID)
+		if seq == 0 || !wasKnown[msg.UID] || s.flagsSent[msg.UID] >= msg.ModSeq {
+			continue
+		}
+		var attrs []string
+		// RFC 7162 Section 3.2.4: with QRESYNC unsolicited FETCH responses include the UID
+		if s.enabled["QRESYNC"] {
+			attrs = append(attrs, fmt.Sprintf("UID %d", msg.UID))
+		}
+		attrs = append(attrs, fmt.Sprintf("FLAGS (%s)", messageFlags(msg)))
+		if s.enabled["CONDSTORE"] {
+			attrs = append(attrs, fmt.Sprintf("MODSEQ (%d)", msg.ModSeq))
+		}
+		s.send(fmt.Sprintf("* %d FETCH (%s)", seq, strings.Join(attrs, " ")))
+	}
+	s.modSeq = highest
+	clear(s.flagsSent)
+
+	if s.notify == nil || len(s.notify.fetchItems) == 0 {
+		return nil
+	}
+	var added []uint32
+	for _, uid := range s.uids {
+		if !wasKnown[uid] {
+			added = append(added, uid)
 		}
 	}
+	return s.fetchNew(added)
 }
@@ -0,0 +1,838 @@
+package imap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
+)
+
+// RFC 3501 mailbox management, with LIST-EXTENDED (RFC 5258), LIST-STATUS (RFC 5819)
+// and SPECIAL-USE (RFC 6154)
+
+// specialUses maps the special-use attributes clients can assign, in upper case, to their
+// canonical spelling. \All and \Flagged would need virtual mailboxes and are not supported.
+var specialUses = map[string]string{
+	strings.ToUpper(domain.SpecialUseArchive): domain.SpecialUseArchive,
+	strings.ToUpper(domain.SpecialUseDrafts):  domain.SpecialUseDrafts,
+	strings.ToUpper(domain.SpecialUseJunk):    domain.SpecialUseJunk,
+	strings.ToUpper(domain.SpecialUseSent):    domain.SpecialUseSent,
+	strings.ToUpper(domain.SpecialUseTrash):   domain.SpecialUseTrash,
+}
+
+// canonicalMailbox returns the stored form of a mailbox name: INBOX is case-insensitive and
+// a trailing hierarchy delimiter is dropped
+func canonicalMailbox(name string) string {
+	name = strings.TrimSuffix(name, domain.MailboxDelimiter)
+	if strings.EqualFold(name, "INBOX") {
+		return "INBOX"
+	}
+	return name
+}
+
+// validMailboxName rejects names that could not be listed back: empty hierarchy levels,
+// LIST wildcards and names that aren't UTF-8
+func validMailboxName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty mailbox name")
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("mailbox name is not valid UTF-8")
+	}
+	if strings.ContainsAny(name, "*%") {
+		return fmt.Errorf("mailbox name contains a wildcard")
+	}
+	if strings.HasPrefix(name, domain.MailboxDelimiter) || strings.Contains(name, domain.MailboxDelimiter+domain.MailboxDelimiter) {
+		return fmt.Errorf("empty hierarchy level in mailbox name")
+	}
+	return nil
+}
+
+// parentNames returns the superior hierarchical names of a mailbox, e.g. "a" and "a/b"
+// for "a/b/c"
+func parentNames(name string) []string {
+	var parents []string
+	for i := 0; i < len(name); i++ {
+		if strings.HasPrefix(name[i:], domain.MailboxDelimiter) {
+			parents = append(parents, name[:i])
+		}
+	}
+	return parents
+}
+
+// matchMailbox reports whether name matches a LIST pattern, where "*" matches any characters
+// and "%" any characters but the hierarchy delimiter. INBOX matches case-insensitively.
+func matchMailbox(pattern, name string) bool {
+	if name == "INBOX" && matchWildcards(strings.ToUpper(pattern), name) {
+		return true
+	}
+	return matchWildcards(pattern, name)
+}
+
+func matchWildcards(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*', '%':
+			for i := 0; i <= len(name); i++ {
+				if matchWildcards(pattern[1:], name[i:]) {
+					return true
+				}
+				if i < len(name) && pattern[0] == '%' && name[i] == domain.MailboxDelimiter[0] {
+					return false
+				}
+			}
+			return false
+		default:
+			if name == "" || pattern[0] != name[0] {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+	}
+	return name == ""
+}
+
+// mailboxExists reports whether the user owns a mailbox with the given name
+func (s *Session) mailboxExists(name string) bool {
+	mb, err := s.emailRepo.GetMailbox(s.ctx, s.user.Email, name)
+	return err == nil && mb != nil
+}
+
+// findMailbox looks up a mailbox by the name the user sees it under, which may be in one
+// of the shared namespaces. The user's INBOX always exists: it is created, subscribed, on
+// first use.
+func (s *Session) findMailbox(name string) (*domain.Mailbox, error) {
+	owner, ownerName := s.resolveMailbox(name)
+	if owner != s.user.Email {
+		mb, err := s.emailRepo.GetMailbox(s.ctx, owner, ownerName)
+		if err != nil || mb == nil {
+			return nil, ports.ErrNotFound
+		}
+		// RFC 4314 Section 4: without the lookup right the mailbox doesn't exist for the user
+		if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "l"); err != nil {
+			return nil, ports.ErrNotFound
+		}
+		return mb, nil
+	}
+
+	mb, err := s.emailRepo.GetMailbox(s.ctx, s.user.Email, name)
+	if err != nil && name == "INBOX" {
+		if err = s.emailRepo.CreateMailbox(s.ctx, s.user.Email, "INBOX"); err == nil {
+			//nolint:errcheck // INBOX is usable without the subscription
+			_ = s.emailRepo.SetSubscribed(s.ctx, s.user.Email, "INBOX", true)
+			mb, err = s.emailRepo.GetMailbox(s.ctx, s.user.Email, "INBOX")
+		}
+	}
+	if err == nil && mb == nil {
+		err = ports.ErrNotFound
+	}
+	return mb, err
+}
+
+// createParents creates the missing superior hierarchical names of a mailbox
+// (RFC 3501 Section 6.3.3)
+func (s *Session) createParents(name string) error {
+	for _, parent := range parentNames(name) {
+		if s.mailboxExists(parent) {
+			continue
+		}
+		if err := s.emailRepo.CreateMailbox(s.ctx, s.user.Email, parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleCreate CREATE <mailbox> [(USE (<special-use>...))]
+func (s *Session) handleCreate(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 1 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	name := s.mailboxArg(cmd.Args[0])
+	if err := validMailboxName(name); err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+		return
+	}
+
+	// RFC 6154 Section 3: CREATE-SPECIAL-USE
+	specialUse := ""
+	if len(cmd.Args) > 1 {
+		uses, err := parseCreateParams(cmd.Args[1:])
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+			return
+		}
+		if len(uses) > 1 {
+			s.send(fmt.Sprintf("%s NO [USEATTR] Only one special-use attribute per mailbox", cmd.Tag))
+			return
+		}
+		if len(uses) == 1 {
+			var ok bool
+			if specialUse, ok = specialUses[strings.ToUpper(uses[0])]; !ok {
+				s.send(fmt.Sprintf("%s NO [USEATTR] Unsupported special-use attribute %s", cmd.Tag, uses[0]))
+				return
+			}
+		}
+	}
+
+	if inSharedNamespace(name) {
+		s.send(fmt.Sprintf("%s NO [NOPERM] Mailboxes cannot be created in shared namespaces", cmd.Tag))
+		return
+	}
+	if name == "INBOX" || s.mailboxExists(name) {
+		s.send(fmt.Sprintf("%s NO [ALREADYEXISTS] Mailbox already exists", cmd.Tag))
+		return
+	}
+	// The missing parents are created too, and count against the MAILBOX limit as well
+	created := 1
+	for _, parent := range parentNames(name) {
+		if !s.mailboxExists(parent) {
+			created++
+		}
+	}
+	if err := s.quotaService.CheckCreateMailbox(s.ctx, s.user.Email, created); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			s.send(overQuota(cmd.Tag, err))
+		} else {
+			s.send(fmt.Sprintf("%s NO Create failed", cmd.Tag))
+		}
+		return
+	}
+	if err := s.createParents(name); err != nil {
+		s.send(fmt.Sprintf("%s NO Create failed", cmd.Tag))
+		return
+	}
+	if err := s.emailRepo.CreateMailbox(s.ctx, s.user.Email, name); err != nil {
+		s.send(fmt.Sprintf("%s NO Create failed", cmd.Tag))
+		return
+	}
+	if specialUse != "" {
+		if err := s.emailRepo.SetSpecialUse(s.ctx, s.user.Email, name, specialUse); err != nil {
+			s.logger.Error("IMAP CREATE special-use failed", "error", err, "mailbox", name)
+			s.send(fmt.Sprintf("%s NO Create failed", cmd.Tag))
+			return
+		}
+	}
+	s.send(fmt.Sprintf("%s OK CREATE completed", cmd.Tag))
+}
+
+// parseCreateParams parses the CREATE parameter list "(USE (\Sent))" and returns the
+// requested special-use attributes
+func parseCreateParams(args []string) ([]string, error) {
+	tokens := searchTokens(args)
+	n := len(tokens)
+	if n < 5 || tokens[0] != "(" || !strings.EqualFold(tokens[1], "USE") || tokens[2] != "(" ||
+		tokens[n-2] != ")" || tokens[n-1] != ")" {
+		return nil, fmt.Errorf("invalid CREATE parameters")
+	}
+	return tokens[3 : n-2], nil
+}
+
+// handleDelete DELETE <mailbox>
+func (s *Session) handleDelete(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 1 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", cmd.Tag))
+		return
+	}
+	name := mb.Name
+	if name == "INBOX" {
+		s.send(fmt.Sprintf("%s NO INBOX cannot be deleted", cmd.Tag))
+		return
+	}
+	// ACL check: requires 'x' (delete mailbox) right
+	if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "x"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+		return
+	}
+
+	// Inferior hierarchical names would be left without their parent
+	mailboxes, err := s.emailRepo.ListMailboxes(s.ctx, mb.UserID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO Delete failed", cmd.Tag))
+		return
+	}
+	for _, other := range mailboxes {
+		if strings.HasPrefix(other.Name, name+domain.MailboxDelimiter) {
+			s.send(fmt.Sprintf("%s NO [HASCHILDREN] Mailbox has inferior hierarchical names", cmd.Tag))
+			return
+		}
+	}
+
+	orphaned, err := s.emailRepo.DeleteMailbox(s.ctx, mb.UserID, name)
+	if err != nil {
+		s.logger.Error("IMAP DELETE failed", "error", err, "mailbox", name)
+		s.send(fmt.Sprintf("%s NO Delete failed", cmd.Tag))
+		return
+	}
+	s.releaseBlobs(orphaned)
+	s.send(fmt.Sprintf("%s OK DELETE completed", cmd.Tag))
+}
+
+// handleRename RENAME <existing mailbox> <new mailbox>
+func (s *Session) handleRename(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	oldName, newName := s.mailboxArg(cmd.Args[0]), s.mailboxArg(cmd.Args[1])
+	if err := validMailboxName(newName); err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+		return
+	}
+	if inSharedNamespace(oldName) || inSharedNamespace(newName) {
+		s.send(fmt.Sprintf("%s NO [NOPERM] Mailboxes in shared namespaces cannot be renamed", cmd.Tag))
+		return
+	}
+
+	src, err := s.findMailbox(oldName)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", cmd.Tag))
+		return
+	}
+	// ACL check: requires 'x' (delete mailbox) right on the old name
+	if err := s.emailService.CheckAccess(s.ctx, src.UserID, src.Name, s.user.Email, "x"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+		return
+	}
+	if newName == "INBOX" || s.mailboxExists(newName) {
+		s.send(fmt.Sprintf("%s NO [ALREADYEXISTS] Mailbox already exists", cmd.Tag))
+		return
+	}
+	if strings.HasPrefix(newName, oldName+domain.MailboxDelimiter) {
+		s.send(fmt.Sprintf("%s NO Cannot move a mailbox into itself", cmd.Tag))
+		return
+	}
+
+	if err := s.createParents(newName); err != nil {
+		s.send(fmt.Sprintf("%s NO Rename failed", cmd.Tag))
+		return
+	}
+	if oldName == "INBOX" {
+		err = s.renameInbox(newName)
+	} else {
+		err = s.emailRepo.RenameMailbox(s.ctx, s.user.Email, oldName, newName)
+	}
+	if errors.Is(err, ports.ErrAlreadyExists) {
+		s.send(fmt.Sprintf("%s NO [ALREADYEXISTS] Mailbox already exists", cmd.Tag))
+		return
+	}
+	if err != nil {
+		s.logger.Error("IMAP RENAME failed", "error", err, "mailbox", oldName)
+		s.send(fmt.Sprintf("%s NO Rename failed", cmd.Tag))
+		return
+	}
+
+	// The selected mailbox keeps its messages and UIDs under the new name
+	if s.selectedMailbox != nil && s.selectedMailbox.UserID == s.user.Email && oldName != "INBOX" {
+		if selected := s.selectedMailbox.Name; selected == oldName || strings.HasPrefix(selected, oldName+domain.MailboxDelimiter) {
+			s.selectedMailbox.Name = newName + strings.TrimPrefix(selected, oldName)
+		}
+	}
+	s.send(fmt.Sprintf("%s OK RENAME completed", cmd.Tag))
+}
+
+// renameInbox moves every message of INBOX to a new mailbox and leaves INBOX empty; its
+// inferior hierarchical names are not renamed (RFC 3501 Section 6.3.5)
+func (s *Session) renameInbox(newName string) error {
+	if err := s.emailRepo.CreateMailbox(s.ctx, s.user.Email, newName); err != nil {
+		return err
+	}
+	msgs, err := s.emailRepo.FindInMailbox(s.ctx, s.user.Email, "INBOX", domain.MailboxFilter{})
+	if err != nil || len(msgs) == 0 {
+		return err
+	}
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	_, err = s.emailRepo.MoveMessages(s.ctx, s.user.Email, "INBOX", ids, newName)
+	return err
+}
+
+func (s *Session) handleSubscribe(cmd *Command) {
+	s.subscribe(cmd, "SUBSCRIBE", true)
+}
+
+func (s *Session) handleUnsubscribe(cmd *Command) {
+	s.subscribe(cmd, "UNSUBSCRIBE", false)
+}
+
+// subscribe SUBSCRIBE <mailbox> / UNSUBSCRIBE <mailbox>
+func (s *Session) subscribe(cmd *Command, command string, subscribed bool) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 1 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	name := s.mailboxArg(cmd.Args[0])
+	mb, err := s.findMailbox(name)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", cmd.Tag))
+		return
+	}
+	// Subscriptions are kept with the mailbox, so they can only be the owner's
+	if mb.UserID != s.user.Email {
+		s.send(fmt.Sprintf("%s NO [CANNOT] Shared mailboxes cannot be subscribed to", cmd.Tag))
+		return
+	}
+	if err := s.emailRepo.SetSubscribed(s.ctx, s.user.Email, name, subscribed); err != nil {
+		s.send(fmt.Sprintf("%s NO %s failed", cmd.Tag, command))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK %s completed", cmd.Tag, command))
+}
+
+// handleStatus STATUS <mailbox> (<item>...)
+func (s *Session) handleStatus(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	items, err := parseStatusItems(searchTokens(cmd.Args[1:]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+		return
+	}
+
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", cmd.Tag))
+		return
+	}
+	// ACL check: requires 'r' (read) right
+	if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "r"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+		return
+	}
+
+	status, err := s.mailboxStatus(mb, items)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO DB Error", cmd.Tag))
+		return
+	}
+	s.send(status)
+	s.send(fmt.Sprintf("%s OK STATUS completed", cmd.Tag))
+}
+
+// parseStatusItems parses a parenthesized list of STATUS data items
+func parseStatusItems(tokens []string) ([]string, error) {
+	if len(tokens) < 3 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return nil, fmt.Errorf("invalid STATUS items")
+	}
+	items := make([]string, 0, len(tokens)-2)
+	for _, token := range tokens[1 : len(tokens)-1] {
+		item := strings.ToUpper(token)
+		switch item {
+		case "MESSAGES", "RECENT", "UIDNEXT", "UIDVALIDITY", "UNSEEN", "DELETED", "SIZE", "DELETED-STORAGE", "HIGHESTMODSEQ":
+		default:
+			return nil, fmt.Errorf("unknown STATUS item %q", token)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mailboxStatus builds the untagged STATUS response of a mailbox
+func (s *Session) mailboxStatus(mb *domain.Mailbox, items []string) (string, error) {
+	var msgs []*domain.Message
+	for _, item := range items {
+		if item == "MESSAGES" || item == "UNSEEN" || item == "DELETED" || item == "SIZE" || item == "DELETED-STORAGE" {
+			var err error
+			if msgs, err = s.emailRepo.FindInMailbox(s.ctx, mb.UserID, mb.Name, domain.MailboxFilter{}); err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+
+	attrs := make([]string, 0, len(items))
+	for _, item := range items {
+		var value uint64
+		switch item {
+		case "MESSAGES":
+			value = uint64(len(msgs))
+		case "RECENT":
+			// \Recent is not tracked
+		case "UIDNEXT":
+			value = uint64(mb.UIDNext)
+		case "UIDVALIDITY":
+			value = uint64(mb.UIDValidity)
+		case "UNSEEN":
+			for _, msg := range msgs {
+				if !hasFlag(messageFlags(msg), "\\Seen") {
+					value++
+				}
+			}
+		case "DELETED":
+			for _, msg := range msgs {
+				if hasFlag(msg.Flags, "\\Deleted") {
+					value++
+				}
+			}
+		case "SIZE":
+			for _, msg := range msgs {
+				value += uint64(s.messageSize(msg))
+			}
+		case "DELETED-STORAGE":
+			// RFC 9208 Section 4.2.2: the storage an EXPUNGE would release
+			for _, msg := range msgs {
+				if hasFlag(msg.Flags, "\\Deleted") {
+					value += uint64(s.messageSize(msg))
+				}
+			}
+		case "HIGHESTMODSEQ":
+			// RFC 7162 Section 3.1: asking for HIGHESTMODSEQ enables CONDSTORE
+			s.enabled["CONDSTORE"] = true
+			value = mb.HighestModSeq
+		}
+		attrs = append(attrs, fmt.Sprintf("%s %d", item, value))
+	}
+	return fmt.Sprintf("* STATUS %s (%s)", s.quoteMailbox(s.mailboxPath(mb)), strings.Join(attrs, " ")), nil
+}
+
+// hasFlag reports whether a space-separated flag list contains flag
+func hasFlag(flags, flag string) bool {
+	for _, f := range strings.Fields(flags) {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// listOptions are the LIST-EXTENDED selection and return options (RFC 5258)
+type listOptions struct {
+	extended         bool // LIST-EXTENDED syntax was used
+	subscribed       bool // select subscribed mailboxes only
+	specialUse       bool // select special-use mailboxes only (RFC 6154)
+	recursiveMatch   bool // also select superiors of matching mailboxes, with CHILDINFO
+	returnSubscribed bool
+	status           []string // STATUS items returned with each mailbox (RFC 5819)
+}
+
+// listEntry is one mailbox of a LIST or LSUB response
+type listEntry struct {
+	name       string
+	attributes []string
+	mailbox    *domain.Mailbox // nil for names that only exist as superiors of others
+	childInfo  bool            // selected through a subscribed inferior (RECURSIVEMATCH)
+}
+
+// takeList splits a parenthesized list off the front of args and returns its tokens
+// without the outer parentheses
+func takeList(args []string) (list, rest []string, err error) {
+	depth := 0
+	for i, arg := range args {
+		depth += strings.Count(arg, "(") - strings.Count(arg, ")")
+		if depth <= 0 {
+			tokens := searchTokens(args[:i+1])
+			return tokens[1 : len(tokens)-1], args[i+1:], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unterminated list")
+}
+
+// parseListCommand parses the arguments of
+// LIST [(<selection options>)] <reference> <pattern | (<patterns>)> [RETURN (<return options>)]
+func parseListCommand(args []string) (opts listOptions, reference string, patterns []string, err error) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "(") {
+		opts.extended = true
+		var selection []string
+		if selection, args, err = takeList(args); err != nil {
+			return opts, "", nil, err
+		}
+		for _, opt := range selection {
+			switch strings.ToUpper(opt) {
+			case "SUBSCRIBED":
+				opts.subscribed = true
+			case "SPECIAL-USE":
+				opts.specialUse = true
+			case "RECURSIVEMATCH":
+				opts.recursiveMatch = true
+			case "REMOTE":
+				// There are no remote mailboxes
+			default:
+				return opts, "", nil, fmt.Errorf("unknown LIST selection option %q", opt)
+			}
+		}
+		if opts.recursiveMatch && !opts.subscribed {
+			return opts, "", nil, fmt.Errorf("RECURSIVEMATCH requires SUBSCRIBED")
+		}
+	}
+
+	if len(args) < 2 {
+		return opts, "", nil, fmt.Errorf("missing LIST arguments")
+	}
+	reference = args[0]
+	if strings.HasPrefix(args[1], "(") {
+		opts.extended = true
+		if patterns, args, err = takeList(args[1:]); err != nil {
+			return opts, "", nil, err
+		}
+	} else {
+		patterns, args = args[1:2], args[2:]
+	}
+
+	if len(args) == 0 {
+		return opts, reference, patterns, nil
+	}
+	if !strings.EqualFold(args[0], "RETURN") || len(args) < 2 || !strings.HasPrefix(args[1], "(") {
+		return opts, "", nil, fmt.Errorf("invalid LIST arguments")
+	}
+	opts.extended = true
+	returns, rest, err := takeList(args[1:])
+	if err != nil {
+		return opts, "", nil, err
+	}
+	if len(rest) > 0 {
+		return opts, "", nil, fmt.Errorf("invalid LIST arguments")
+	}
+	for i := 0; i < len(returns); i++ {
+		switch strings.ToUpper(returns[i]) {
+		case "SUBSCRIBED":
+			opts.returnSubscribed = true
+		case "CHILDREN", "SPECIAL-USE":
+			// Always returned
+		case "STATUS":
+			end := i + 1
+			for end < len(returns) && returns[end] != ")" {
+				end++
+			}
+			if end == len(returns) {
+				return opts, "", nil, fmt.Errorf("invalid STATUS return option")
+			}
+			if opts.status, err = parseStatusItems(returns[i+1 : end+1]); err != nil {
+				return opts, "", nil, err
+			}
+			i = end
+		default:
+			return opts, "", nil, fmt.Errorf("unknown LIST return option %q", returns[i])
+		}
+	}
+	return opts, reference, patterns, nil
+}
+
+// handleList LIST [(<selection options>)] <reference> <patterns> [RETURN (<return options>)]
+func (s *Session) handleList(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	opts, reference, patterns, err := parseListCommand(cmd.Args)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+		return
+	}
+
+	// An empty pattern asks for the hierarchy delimiter (RFC 3501 Section 6.3.8)
+	if len(patterns) == 1 && patterns[0] == "" {
+		s.send(fmt.Sprintf(`* LIST (\Noselect) "%s" ""`, domain.MailboxDelimiter))
+		s.send(fmt.Sprintf("%s OK LIST completed", cmd.Tag))
+		return
+	}
+	for i := range patterns {
+		patterns[i] = s.decodeMailboxName(reference + patterns[i])
+	}
+
+	entries, err := s.listMailboxes(patterns, opts)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO List failed", cmd.Tag))
+		return
+	}
+	for _, entry := range entries {
+		s.sendListEntry(entry)
+
+		// RFC 5819: STATUS follows the LIST response of every selectable mailbox
+		if len(opts.status) > 0 && entry.mailbox != nil {
+			status, err := s.mailboxStatus(entry.mailbox, opts.status)
+			if err != nil {
+				s.logger.Error("IMAP LIST-STATUS failed", "error", err, "mailbox", entry.name)
+				continue
+			}
+			s.send(status)
+		}
+	}
+	s.send(fmt.Sprintf("%s OK LIST completed", cmd.Tag))
+}
+
+// sendListEntry sends the LIST response of a mailbox
+func (s *Session) sendListEntry(entry listEntry) {
+	line := fmt.Sprintf(`* LIST (%s) "%s" %s`, strings.Join(entry.attributes, " "), domain.MailboxDelimiter, s.quoteMailbox(entry.name))
+	if entry.childInfo {
+		line += ` ("CHILDINFO" ("SUBSCRIBED"))`
+	}
+	s.send(line)
+}
+
+// handleLsub LSUB <reference> <pattern>
+func (s *Session) handleLsub(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+	pattern := s.decodeMailboxName(cmd.Args[0] + cmd.Args[1])
+
+	entries, err := s.listMailboxes([]string{pattern}, listOptions{subscribed: true, recursiveMatch: true})
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO Lsub failed", cmd.Tag))
+		return
+	}
+	for _, entry := range entries {
+		attributes := ""
+		if entry.childInfo {
+			// RFC 3501 Section 6.3.9: a superior matched by "%" is listed as \Noselect
+			// when only its inferiors are subscribed
+			if !strings.Contains(pattern, "%") {
+				continue
+			}
+			attributes = `\Noselect`
+		}
+		s.send(fmt.Sprintf(`* LSUB (%s) "%s" %s`, attributes, domain.MailboxDelimiter, s.quoteMailbox(entry.name)))
+	}
+	s.send(fmt.Sprintf("%s OK LSUB completed", cmd.Tag))
+}
+
+// listMailboxes returns the mailboxes matching any of the patterns, INBOX first, with the
+// attributes of their LIST responses. Mailboxes shared with the user are listed in their
+// namespaces.
+func (s *Session) listMailboxes(patterns []string, opts listOptions) ([]listEntry, error) {
+	mailboxes, err := s.emailRepo.ListMailboxes(s.ctx, s.user.Email)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.emailRepo.ListSharedMailboxes(s.ctx, s.user.Email)
+	if err != nil {
+		return nil, err
+	}
+	for _, mb := range shared {
+		// The subscription is the owner's
+		mb.Subscribed = false
+	}
+	mailboxes = append(mailboxes, shared...)
+
+	byName := make(map[string]*domain.Mailbox, len(mailboxes))
+	for _, mb := range mailboxes {
+		// ACL check: requires 'l' (lookup) right
+		if err := s.emailService.CheckAccess(s.ctx, mb.UserID, mb.Name, s.user.Email, "l"); err != nil {
+			continue
+		}
+		byName[s.mailboxPath(mb)] = mb
+	}
+	if byName["INBOX"] == nil {
+		if inbox, err := s.findMailbox("INBOX"); err == nil {
+			byName["INBOX"] = inbox
+		}
+	}
+
+	// Superiors of existing mailboxes are listed even if they don't exist themselves
+	names := make(map[string]bool, len(byName))
+	for name := range byName {
+		names[name] = true
+		for _, parent := range parentNames(name) {
+			names[parent] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i] == "INBOX" || sorted[j] == "INBOX" {
+			return sorted[i] == "INBOX" && sorted[j] != "INBOX"
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	var entries []listEntry
+	for _, name := range sorted {
+		matched := false
+		for _, pattern := range patterns {
+			if matchMailbox(pattern, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		mb := byName[name]
+		hasChildren, subscribedChild := false, false
+		prefix := name + domain.MailboxDelimiter
+		for other := range names {
+			if strings.HasPrefix(other, prefix) {
+				hasChildren = true
+				if child := byName[other]; child != nil && child.Subscribed {
+					subscribedChild = true
+				}
+			}
+		}
+
+		entry := listEntry{name: name, mailbox: mb}
+		if opts.specialUse && (mb == nil || mb.SpecialUse == "") {
+			continue
+		}
+		if opts.subscribed && (mb == nil || !mb.Subscribed) {
+			if !opts.recursiveMatch || !subscribedChild {
+				continue
+			}
+			entry.childInfo = true
+		}
+
+		if mb == nil {
+			// RFC 5258 Section 3.4: \NonExistent implies \Noselect
+			if opts.extended {
+				entry.attributes = append(entry.attributes, `\NonExistent`)
+			} else {
+				entry.attributes = append(entry.attributes, `\Noselect`)
+			}
+		}
+		if hasChildren {
+			entry.attributes = append(entry.attributes, `\HasChildren`)
+		} else {
+			entry.attributes = append(entry.attributes, `\HasNoChildren`)
+		}
+		if mb != nil && mb.Subscribed && (opts.subscribed || opts.returnSubscribed) {
+			entry.attributes = append(entry.attributes, `\Subscribed`)
+		}
+		if mb != nil && mb.SpecialUse != "" {
+			entry.attributes = append(entry.attributes, mb.SpecialUse)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,77 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchMailbox(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*", "Archive/2024/Q1", true},
+		{"%", "Archive", true},
+		{"%", "Archive/2024", false},
+		{"Archive/%", "Archive/2024", true},
+		{"Archive/%", "Archive/2024/Q1", false},
+		{"Archive/*", "Archive/2024/Q1", true},
+		{"A%e", "Archive", true},
+		{"inbox", "INBOX", true},
+		{"in*", "INBOX", true},
+		{"sent", "Sent", false},
+		{"", "INBOX", false},
+	}
+	for _, tt := range tests {
+		if got := matchMailbox(tt.pattern, tt.name); got != tt.match {
+			t.Errorf("matchMailbox(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.match)
+		}
+	}
+}
+
+func TestParentNames(t *testing.T) {
+	if got := parentNames("a/b/c"); !reflect.DeepEqual(got, []string{"a", "a/b"}) {
+		t.Errorf("parentNames(a/b/c) = %q", got)
+	}
+	if got := parentNames("INBOX"); got != nil {
+		t.Errorf("parentNames(INBOX) = %q", got)
+	}
+}
+
+func TestParseListCommand(t *testing.T) {
+	opts, reference, patterns, err := parseListCommand([]string{"", "*"})
+	if err != nil || opts.extended || reference != "" || !reflect.DeepEqual(patterns, []string{"*"}) {
+		t.Errorf("basic LIST: got %+v %q %q %v", opts, reference, patterns, err)
+	}
+
+	args := []string{"(SUBSCRIBED", "RECURSIVEMATCH)", "", "(INBOX", "Archive/*)", "RETURN", "(CHILDREN", "STATUS", "(MESSAGES", "UNSEEN))"}
+	opts, _, patterns, err = parseListCommand(args)
+	if err != nil {
+		t.Fatalf("extended LIST: unexpected error: %v", err)
+	}
+	if !opts.extended || !opts.subscribed || !opts.recursiveMatch {
+		t.Errorf("extended LIST: wrong selection options %+v", opts)
+	}
+	if !reflect.DeepEqual(patterns, []string{"INBOX", "Archive/*"}) {
+		t.Errorf("extended LIST: got patterns %q", patterns)
+	}
+	if !reflect.DeepEqual(opts.status, []string{"MESSAGES", "UNSEEN"}) {
+		t.Errorf("extended LIST: got STATUS items %q", opts.status)
+	}
+
+	for _, args := range [][]string{{"*"}, {"(RECURSIVEMATCH)", "", "*"}, {"(FOO)", "", "*"}, {"", "*", "RETURN", "(FOO)"}, {"", "*", "RETURN", "(STATUS", "(BAR))"}} {
+		if _, _, _, err := parseListCommand(args); err == nil {
+			t.Errorf("parseListCommand(%q) expected error, got nil", args)
+		}
+	}
+}
+
+func TestParseCreateParams(t *testing.T) {
+	uses, err := parseCreateParams([]string{"(USE", "(\\Sent))"})
+	if err != nil || !reflect.DeepEqual(uses, []string{"\\Sent"}) {
+		t.Errorf("parseCreateParams: got %q, %v", uses, err)
+	}
+	if _, err := parseCreateParams([]string{"(FOO", "(\\Sent))"}); err == nil {
+		t.Error("parseCreateParams with unknown parameter expected error, got nil")
+	}
+}
@@ -0,0 +1,296 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 5464 IMAP METADATA Extension
+
+const (
+	// maxMetadataSize is the largest value a client may set (RFC 5464 Section 4.3)
+	maxMetadataSize = 64 * 1024
+	// maxMetadataEntries is the number of entries a user may keep on a mailbox or the server
+	maxMetadataEntries = 100
+)
+
+// metadataDepthInfinity selects all the descendants of the requested entries
+const metadataDepthInfinity = -1
+
+// metadataOptions are the options of GETMETADATA: MAXSIZE is 0 when unset
+type metadataOptions struct {
+	maxSize int
+	depth   int
+}
+
+// validMetadataEntry checks an entry name (RFC 5464 Section 3.2): it is /private or /shared
+// or lies under them, and has no wildcards, empty components or trailing slash. Names are
+// compared case-insensitively, so they are returned lower-cased.
+func validMetadataEntry(name string) (string, error) {
+	name = strings.ToLower(name)
+	if name == domain.MetadataPrivate || name == domain.MetadataShared {
+		return name, nil
+	}
+	if !strings.HasPrefix(name, domain.MetadataPrivate+"/") && !strings.HasPrefix(name, domain.MetadataShared+"/") {
+		return "", fmt.Errorf("entry %q is not under /private or /shared", name)
+	}
+	if strings.HasSuffix(name, "/") || strings.Contains(name, "//") {
+		return "", fmt.Errorf("entry %q has an empty component", name)
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c <= 0x20 || c >= 0x7f || c == '*' || c == '%' {
+			return "", fmt.Errorf("invalid character in entry %q", name)
+		}
+	}
+	return name, nil
+}
+
+// parseMetadataOptions parses the option list of GETMETADATA, e.g. (MAXSIZE 1024 DEPTH 1)
+func parseMetadataOptions(list []string) (metadataOptions, error) {
+	opts := metadataOptions{}
+	for i := 0; i < len(list); i += 2 {
+		if i+1 >= len(list) {
+			return opts, fmt.Errorf("missing value for %s", list[i])
+		}
+		value := list[i+1]
+		switch strings.ToUpper(list[i]) {
+		case "MAXSIZE":
+			n, err := strconv.ParseUint(value, 10, 31)
+			if err != nil {
+				return opts, fmt.Errorf("invalid MAXSIZE %s", value)
+			}
+			opts.maxSize = int(n)
+		case "DEPTH":
+			switch strings.ToLower(value) {
+			case "0":
+				opts.depth = 0
+			case "1":
+				opts.depth = 1
+			case "infinity":
+				opts.depth = metadataDepthInfinity
+			default:
+				return opts, fmt.Errorf("invalid DEPTH %s", value)
+			}
+		default:
+			return opts, fmt.Errorf("unknown option %s", list[i])
+		}
+	}
+	return opts, nil
+}
+
+// matchMetadataEntry reports whether a stored entry is the requested one or, within depth,
+// one of its descendants
+func matchMetadataEntry(requested, name string, depth int) bool {
+	if name == requested {
+		return true
+	}
+	if depth == 0 || !strings.HasPrefix(name, requested+"/") {
+		return false
+	}
+	return depth == metadataDepthInfinity || !strings.Contains(name[len(requested)+1:], "/")
+}
+
+// metadataTarget resolves the mailbox argument of GETMETADATA and SETMETADATA to the
+// owner and name the entries are stored under. "" is the server, kept with an empty owner.
+func (s *Session) metadataTarget(tag, arg string) (owner, mailbox string, ok bool) {
+	if arg == "" {
+		return "", "", true
+	}
+	mb, err := s.findMailbox(s.mailboxArg(arg))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", tag))
+		return "", "", false
+	}
+	return mb.UserID, mb.Name, true
+}
+
+// handleGetMetadata GETMETADATA [(options)] <mailbox> <entry | (entry ...)>
+func (s *Session) handleGetMetadata(cmd *Command) {
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
+		return
+	}
+
+	args := cmd.Args
+	var opts metadataOptions
+	if len(args) > 0 && strings.HasPrefix(args[0], "(") {
+		list, rest, err := takeList(args)
+		if err == nil {
+			opts, err = parseMetadataOptions(list)
+		}
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD Invalid GETMETADATA options: %v", cmd.Tag, err))
+			return
+		}
+		args = rest
+	}
+	if len(args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+
+	requested := searchTokens(args[1:])
+	if requested[0] == "(" {
+		if requested[len(requested)-1] != ")" || len(requested) < 3 {
+			s.send(fmt.Sprintf("%s BAD Invalid entry list", cmd.Tag))
+			return
+		}
+		requested = requested[1 : len(requested)-1]
+	}
+	readShared := false
+	for i, name := range requested {
+		entry, err := validMetadataEntry(name)
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+			return
+		}
+		requested[i] = entry
+		readShared = readShared || strings.HasPrefix(entry, domain.MetadataShared+"/")
+	}
+
+	owner, mailbox, ok := s.metadataTarget(cmd.Tag, args[0])
+	if !ok {
+		return
+	}
+	// RFC 5464 Section 3.5: shared entries of a mailbox require the read right
+	if mailbox != "" && readShared {
+		if err := s.emailService.CheckAccess(s.ctx, owner, mailbox, s.user.Email, "r"); err != nil {
+			s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+			return
+		}
+	}
+
+	stored, err := s.emailRepo.GetMetadata(s.ctx, owner, mailbox, s.user.Email)
+	if err != nil {
+		s.logger.Error("Storage error during GETMETADATA", "error", err)
+		s.send(fmt.Sprintf("%s NO Storage error", cmd.Tag))
+		return
+	}
+
+	var items []string
+	seen := make(map[string]bool)
+	longest := 0
+	for _, name := range requested {
+		found := false
+		for _, entry := range stored {
+			if !matchMetadataEntry(name, entry.Name, opts.depth) {
+				continue
+			}
+			found = true
+			if seen[entry.Name] {
+				continue
+			}
+			seen[entry.Name] = true
+			if opts.maxSize > 0 && len(entry.Value) > opts.maxSize {
+				longest = max(longest, len(entry.Value))
+				continue
+			}
+			items = append(items, entry.Name, quoteString(string(entry.Value)))
+		}
+		// A requested entry without a value, or descendants within depth, is reported as NIL
+		if !found && !seen[name] {
+			seen[name] = true
+			items = append(items, name, "NIL")
+		}
+	}
+
+	s.send(fmt.Sprintf("* METADATA %s (%s)", s.quoteMailbox(mailbox), strings.Join(items, " ")))
+	if longest > 0 {
+		s.send(fmt.Sprintf("%s OK [METADATA LONGENTRIES %d] GETMETADATA completed", cmd.Tag, longest))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK GETMETADATA completed", cmd.Tag))
+}
+
+// handleSetMetadata SETMETADATA <mailbox> (entry value ...), where a NIL value removes the entry
+func (s *Session) handleSetMetadata(cmd *Command) {
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+
+	tokens, err := pairListArgs(cmd.Args[1:])
+	if err == nil && (len(tokens) == 0 || len(tokens)%2 != 0) {
+		err = fmt.Errorf("expected entry and value pairs")
+	}
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD Invalid SETMETADATA arguments: %v", cmd.Tag, err))
+		return
+	}
+	entries := make([]*domain.MetadataEntry, 0, len(tokens)/2)
+	writeShared := false
+	for i := 0; i < len(tokens); i += 2 {
+		name, err := validMetadataEntry(tokens[i])
+		if err == nil && (name == domain.MetadataPrivate || name == domain.MetadataShared) {
+			err = fmt.Errorf("entry %q has no value of its own", name)
+		}
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+			return
+		}
+		entry := &domain.MetadataEntry{Name: name}
+		if value := tokens[i+1]; !strings.EqualFold(value, "NIL") {
+			if len(value) > maxMetadataSize {
+				s.send(fmt.Sprintf("%s NO [METADATA MAXSIZE %d] Value too large", cmd.Tag, maxMetadataSize))
+				return
+			}
+			entry.Value = append([]byte{}, value...)
+		}
+		entries = append(entries, entry)
+		writeShared = writeShared || strings.HasPrefix(name, domain.MetadataShared+"/")
+	}
+
+	owner, mailbox, ok := s.metadataTarget(cmd.Tag, cmd.Args[0])
+	if !ok {
+		return
+	}
+	if writeShared {
+		// Server annotations are the administrators'; mailbox ones need the write right
+		denied := mailbox == "" && s.user.Role != domain.RoleAdmin
+		if mailbox != "" {
+			denied = s.emailService.CheckAccess(s.ctx, owner, mailbox, s.user.Email, "w") != nil
+		}
+		if denied {
+			s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", cmd.Tag))
+			return
+		}
+	}
+
+	stored, err := s.emailRepo.GetMetadata(s.ctx, owner, mailbox, s.user.Email)
+	if err != nil {
+		s.logger.Error("Storage error during SETMETADATA", "error", err)
+		s.send(fmt.Sprintf("%s NO Storage error", cmd.Tag))
+		return
+	}
+	names := make(map[string]bool, len(stored))
+	for _, entry := range stored {
+		names[entry.Name] = true
+	}
+	for _, entry := range entries {
+		names[entry.Name] = entry.Value != nil
+	}
+	count := 0
+	for _, set := range names {
+		if set {
+			count++
+		}
+	}
+	if count > maxMetadataEntries {
+		s.send(fmt.Sprintf("%s NO [METADATA TOOMANY] Too many entries", cmd.Tag))
+		return
+	}
+
+	if err := s.emailRepo.SetMetadata(s.ctx, owner, mailbox, s.user.Email, entries); err != nil {
+		s.logger.Error("Storage error during SETMETADATA", "error", err)
+		s.send(fmt.Sprintf("%s NO Storage error", cmd.Tag))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK SETMETADATA completed", cmd.Tag))
+}
@@ -0,0 +1,55 @@
+package imap
+
+import "testing"
+
+func TestValidMetadataEntry(t *testing.T) {
+	for name, want := range map[string]string{
+		"/private/comment":                 "/private/comment",
+		"/SHARED":                          "/shared",
+		"/Shared/Vendor/Example/Color":     "/shared/vendor/example/color",
+		"/private/vendor/vendor.dovecot/x": "/private/vendor/vendor.dovecot/x",
+	} {
+		if got, err := validMetadataEntry(name); err != nil || got != want {
+			t.Errorf("validMetadataEntry(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{"", "/comment", "/private/", "/shared//comment", "/shared/a*", "/private/%", "/private/a b", "/private/été"} {
+		if _, err := validMetadataEntry(name); err == nil {
+			t.Errorf("validMetadataEntry(%q) expected error, got nil", name)
+		}
+	}
+}
+
+func TestParseMetadataOptions(t *testing.T) {
+	opts, err := parseMetadataOptions([]string{"MAXSIZE", "1024", "depth", "infinity"})
+	if err != nil || opts != (metadataOptions{maxSize: 1024, depth: metadataDepthInfinity}) {
+		t.Errorf("got %+v, %v", opts, err)
+	}
+
+	for _, list := range [][]string{{"MAXSIZE"}, {"MAXSIZE", "-1"}, {"DEPTH", "2"}, {"COUNT", "1"}} {
+		if _, err := parseMetadataOptions(list); err == nil {
+			t.Errorf("parseMetadataOptions(%q) expected error, got nil", list)
+		}
+	}
+}
+
+func TestMatchMetadataEntry(t *testing.T) {
+	tests := []struct {
+		requested, name string
+		depth           int
+		match           bool
+	}{
+		{"/shared/comment", "/shared/comment", 0, true},
+		{"/shared/comment", "/shared/comment/a", 0, false},
+		{"/shared/comment", "/shared/comment/a", 1, true},
+		{"/shared/comment", "/shared/comment/a/b", 1, false},
+		{"/shared/comment", "/shared/comment/a/b", metadataDepthInfinity, true},
+		{"/shared/comment", "/shared/comments", metadataDepthInfinity, false},
+	}
+	for _, tt := range tests {
+		if got := matchMetadataEntry(tt.requested, tt.name, tt.depth); got != tt.match {
+			t.Errorf("matchMetadataEntry(%q, %q, %d) = %v, want %v", tt.requested, tt.name, tt.depth, got, tt.match)
+		}
+	}
+}
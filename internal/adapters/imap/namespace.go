@@ -0,0 +1,77 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 2342 IMAP4 Namespace. The user's own mailboxes form the personal namespace, which
+// has no prefix. Mailboxes other users share with the user through their ACLs appear
+// under "Other Users/<user>/", those of shared role accounts such as support@ under
+// "Shared/<account>/".
+
+const (
+	otherUsersPrefix = "Other Users" + domain.MailboxDelimiter
+	sharedPrefix     = "Shared" + domain.MailboxDelimiter
+)
+
+// handleNamespace NAMESPACE
+func (s *Session) handleNamespace(cmd *Command) {
+	if s.state < StateAuthenticated {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	s.send(fmt.Sprintf(`* NAMESPACE (("" "%[1]s")) ((%[2]s "%[1]s")) ((%[3]s "%[1]s"))`,
+		domain.MailboxDelimiter, quoteString(otherUsersPrefix), quoteString(sharedPrefix)))
+	s.send(fmt.Sprintf("%s OK NAMESPACE completed", cmd.Tag))
+}
+
+// inSharedNamespace reports whether a mailbox name lies in the Other Users or the Shared
+// namespace, or is one of their prefixes
+func inSharedNamespace(name string) bool {
+	name += domain.MailboxDelimiter
+	return strings.HasPrefix(name, otherUsersPrefix) || strings.HasPrefix(name, sharedPrefix)
+}
+
+// resolveMailbox maps a mailbox name to the owner of the mailbox and the owner's name for
+// it. Accounts in the user's domain are named by their local part, others by their address.
+func (s *Session) resolveMailbox(name string) (owner, mailbox string) {
+	for _, prefix := range []string{otherUsersPrefix, sharedPrefix} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		account, mailbox, _ := strings.Cut(strings.TrimPrefix(name, prefix), domain.MailboxDelimiter)
+		if !strings.Contains(account, "@") {
+			account += "@" + emailDomain(s.user.Email)
+		}
+		return account, canonicalMailbox(mailbox)
+	}
+	return s.user.Email, name
+}
+
+// mailboxPath returns the name under which the user sees a mailbox: the owner's name for
+// it, prefixed with its namespace and account when it belongs to someone else
+func (s *Session) mailboxPath(mb *domain.Mailbox) string {
+	if mb.UserID == s.user.Email {
+		return mb.Name
+	}
+	prefix := otherUsersPrefix
+	if owner, err := s.userRepo.FindByEmail(s.ctx, mb.UserID); err == nil && owner.Role == domain.RoleShared {
+		prefix = sharedPrefix
+	}
+	account := mb.UserID
+	if local, accountDomain, ok := strings.Cut(account, "@"); ok && strings.EqualFold(accountDomain, emailDomain(s.user.Email)) {
+		account = local
+	}
+	return prefix + account + domain.MailboxDelimiter + mb.Name
+}
+
+// emailDomain returns the domain part of an address
+func emailDomain(email string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,483 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+)
+
+// RFC 5465 NOTIFY lets one connection watch several mailboxes. Events of the selected
+// mailbox are reported like the changes NOOP finds, those of other mailboxes as STATUS
+// responses. Notifications are sent while the client idles and on NOOP, when untagged
+// responses can't disturb a command in progress.
+
+// notifyQueueSize bounds the events waiting for the client; when it overflows the
+// notifications are turned off (RFC 5465 Section 5.8)
+const notifyQueueSize = 256
+
+// notifyEvents maps the event types of the notification bus to the NOTIFY events they
+// signal. Other events, e.g. MailboxName, are not supported.
+var notifyEvents = map[string]string{
+	"new_message":     "MESSAGENEW",
+	"message_deleted": "MESSAGEEXPUNGE",
+	"flags_changed":   "FLAGCHANGE",
+}
+
+// errBadEvent rejects NOTIFY SET with an event the server doesn't support
+var errBadEvent = errors.New("unsupported event")
+
+// notifyFilter is one event group of NOTIFY SET: a set of mailboxes and the events wanted
+// for them
+type notifyFilter struct {
+	kind      string          // SELECTED, SELECTED-DELAYED, INBOXES, PERSONAL, SUBSCRIBED, SUBTREE or MAILBOXES
+	mailboxes []string        // Mailboxes of SUBTREE and MAILBOXES, as the user names them
+	events    map[string]bool // Upper-case event names; empty for NONE
+}
+
+// notifySet is the state of NOTIFY SET: the filters, the data items to send for new
+// messages of the selected mailbox and the queue fed by the listeners
+type notifySet struct {
+	filters    []notifyFilter
+	fetchItems []fetchItem
+	sections   map[int]*section
+	owners     map[string]bool // Accounts whose notifications are listened to
+	events     chan ports.NotificationEvent
+	overflow   atomic.Bool
+	stop       context.CancelFunc
+}
+
+// pending returns the queued events without waiting for more
+func (n *notifySet) pending() []ports.NotificationEvent {
+	var events []ports.NotificationEvent
+	for {
+		select {
+		case evt := <-n.events:
+			events = append(events, evt)
+		default:
+			return events
+		}
+	}
+}
+
+// handleNotify NOTIFY NONE / NOTIFY SET [(STATUS)] (<filter> <events>)...
+func (s *Session) handleNotify(cmd *Command) {
+	if s.state != StateAuthenticated && s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Not authenticated", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) == 0 {
+		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
+		return
+	}
+
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "NONE":
+		if len(cmd.Args) > 1 {
+			s.send(fmt.Sprintf("%s BAD Unexpected arguments", cmd.Tag))
+			return
+		}
+		s.stopNotify()
+		s.send(fmt.Sprintf("%s OK NOTIFY completed", cmd.Tag))
+		return
+	case "SET":
+	default:
+		s.send(fmt.Sprintf("%s BAD Unknown NOTIFY operation %s", cmd.Tag, cmd.Args[0]))
+		return
+	}
+
+	set, status, err := parseNotifySet(notifyTokens(cmd.Args[1:]))
+	if errors.Is(err, errBadEvent) {
+		s.send(fmt.Sprintf("%s NO [BADEVENT (MessageNew MessageExpunge FlagChange)] %v", cmd.Tag, err))
+		return
+	}
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", cmd.Tag, err))
+		return
+	}
+
+	// NOTIFY SET replaces the previous filters
+	s.stopNotify()
+	if err := s.listenNotify(set); err != nil {
+		s.logger.Error("NOTIFY failed to listen for events", "error", err)
+		s.send(fmt.Sprintf("%s NO NOTIFY failed", cmd.Tag))
+		return
+	}
+	s.notify = set
+
+	// RFC 5465 Section 3.1: (STATUS) first reports the current state of the watched mailboxes
+	if status {
+		if err := s.sendNotifyStatus(); err != nil {
+			s.logger.Error("NOTIFY failed to list mailboxes", "error", err)
+		}
+	}
+	s.send(fmt.Sprintf("%s OK NOTIFY completed", cmd.Tag))
+}
+
+// notifyTokens splits the arguments of NOTIFY SET into tokens, separating parentheses. A
+// section of a fetch-att such as BODY.PEEK[HEADER.FIELDS (SUBJECT)] stays one token.
+func notifyTokens(args []string) []string {
+	var tokens []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		for strings.HasPrefix(arg, "(") {
+			tokens = append(tokens, "(")
+			arg = arg[1:]
+		}
+		for strings.Count(arg, "[") > strings.Count(arg, "]") && i+1 < len(args) {
+			i++
+			arg += " " + args[i]
+		}
+		closing := 0
+		for strings.HasSuffix(arg, ")") && len(arg)-1 > strings.LastIndex(arg, "]") {
+			closing++
+			arg = arg[:len(arg)-1]
+		}
+		if arg != "" {
+			tokens = append(tokens, arg)
+		}
+		for ; closing > 0; closing-- {
+			tokens = append(tokens, ")")
+		}
+	}
+	return tokens
+}
+
+// parseNotifySet parses the arguments of NOTIFY SET, e.g.
+// "(STATUS) (SELECTED (MessageNew (UID FLAGS) MessageExpunge)) (PERSONAL (MessageNew MessageExpunge))"
+func parseNotifySet(tokens []string) (set *notifySet, status bool, err error) {
+	if len(tokens) >= 3 && tokens[0] == "(" && strings.EqualFold(tokens[1], "STATUS") && tokens[2] == ")" {
+		status = true
+		tokens = tokens[3:]
+	}
+	if len(tokens) == 0 {
+		return nil, false, fmt.Errorf("missing event groups")
+	}
+
+	set = &notifySet{sections: make(map[int]*section)}
+	next := func() string {
+		if len(tokens) == 0 {
+			return ""
+		}
+		tok := tokens[0]
+		tokens = tokens[1:]
+		return tok
+	}
+	selected := false
+	for len(tokens) > 0 {
+		if next() != "(" {
+			return nil, false, fmt.Errorf("invalid event group")
+		}
+		filter := notifyFilter{kind: strings.ToUpper(next()), events: make(map[string]bool)}
+		switch filter.kind {
+		case "SELECTED", "SELECTED-DELAYED":
+			if selected {
+				return nil, false, fmt.Errorf("selected mailbox specified twice")
+			}
+			selected = true
+		case "INBOXES", "PERSONAL", "SUBSCRIBED":
+		case "SUBTREE", "MAILBOXES":
+			name := next()
+			if name == "(" {
+				for name = next(); name != ")"; name = next() {
+					if name == "" {
+						return nil, false, fmt.Errorf("unterminated mailbox list")
+					}
+					filter.mailboxes = append(filter.mailboxes, name)
+				}
+			} else if name != "" && name != ")" {
+				filter.mailboxes = append(filter.mailboxes, name)
+			}
+			if len(filter.mailboxes) == 0 {
+				return nil, false, fmt.Errorf("missing mailboxes for %s", filter.kind)
+			}
+		default:
+			return nil, false, fmt.Errorf("unknown mailbox filter %q", filter.kind)
+		}
+
+		events := next()
+		if strings.EqualFold(events, "NONE") {
+			if next() != ")" {
+				return nil, false, fmt.Errorf("invalid event group")
+			}
+			set.filters = append(set.filters, filter)
+			continue
+		}
+		if events != "(" {
+			return nil, false, fmt.Errorf("missing events")
+		}
+		for event := next(); event != ")"; event = next() {
+			name := strings.ToUpper(event)
+			switch name {
+			case "":
+				return nil, false, fmt.Errorf("unterminated event list")
+			case "MESSAGENEW", "MESSAGEEXPUNGE", "FLAGCHANGE":
+			default:
+				return nil, false, fmt.Errorf("%w %s", errBadEvent, event)
+			}
+			filter.events[name] = true
+
+			// MessageNew may be followed by the data items to send for new messages
+			if name == "MESSAGENEW" && len(tokens) > 0 && tokens[0] == "(" {
+				if !strings.HasPrefix(filter.kind, "SELECTED") {
+					return nil, false, fmt.Errorf("fetch attributes are only allowed for the selected mailbox")
+				}
+				var items []string
+				depth := 0
+				for tok := next(); ; tok = next() {
+					if tok == "" {
+						return nil, false, fmt.Errorf("unterminated fetch attributes")
+					}
+					if tok == "(" {
+						depth++
+					} else if tok == ")" {
+						depth--
+					}
+					items = append(items, tok)
+					if depth == 0 {
+						break
+					}
+				}
+				spec := strings.ReplaceAll(strings.ReplaceAll(strings.Join(items, " "), "( ", "("), " )", ")")
+				if set.fetchItems, err = parseFetchItems(spec); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		if next() != ")" {
+			return nil, false, fmt.Errorf("invalid event group")
+		}
+
+		// RFC 5465 Section 5: message events are only useful together
+		if filter.events["MESSAGENEW"] != filter.events["MESSAGEEXPUNGE"] ||
+			(filter.events["FLAGCHANGE"] && !filter.events["MESSAGENEW"]) {
+			return nil, false, fmt.Errorf("MessageNew, MessageExpunge and FlagChange must be combined")
+		}
+		set.filters = append(set.filters, filter)
+	}
+
+	for i, item := range set.fetchItems {
+		if item.hasBody {
+			sec, err := item.parseSection()
+			if err != nil {
+				return nil, false, err
+			}
+			set.sections[i] = sec
+		}
+	}
+	return set, status, nil
+}
+
+// listenNotify starts listening to the notifications of every account with a watched
+// mailbox. The events are queued until they are delivered; if the client doesn't take
+// them in time the queue overflows.
+func (s *Session) listenNotify(set *notifySet) error {
+	set.owners = make(map[string]bool)
+	for _, filter := range set.filters {
+		switch filter.kind {
+		case "INBOXES", "PERSONAL", "SUBSCRIBED":
+			set.owners[s.user.Email] = true
+		case "SUBTREE", "MAILBOXES":
+			for _, name := range filter.mailboxes {
+				owner, _ := s.resolveMailbox(s.mailboxArg(name))
+				set.owners[owner] = true
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	set.events = make(chan ports.NotificationEvent, notifyQueueSize)
+	set.stop = cancel
+	for owner := range set.owners {
+		ch, unsubscribe, err := s.notificationBus.Listen(ctx, owner)
+		if err != nil {
+			cancel()
+			return err
+		}
+		go func() {
+			defer unsubscribe()
+			for evt := range ch {
+				select {
+				case set.events <- evt:
+				default:
+					set.overflow.Store(true)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// stopNotify turns off the notifications of NOTIFY SET
+func (s *Session) stopNotify() {
+	if s.notify != nil {
+		s.notify.stop()
+		s.notify = nil
+	}
+}
+
+// notifyWatches reports whether the NOTIFY filters cover a mailbox, other than the
+// selected one, for the given event, or for any event if event is empty
+func (s *Session) notifyWatches(owner, mailbox, event string) bool {
+	watched := false
+	for _, filter := range s.notify.filters {
+		if (event == "" && len(filter.events) == 0) || (event != "" && !filter.events[event]) {
+			continue
+		}
+		switch filter.kind {
+		case "INBOXES":
+			watched = owner == s.user.Email && mailbox == "INBOX"
+		case "PERSONAL":
+			watched = owner == s.user.Email
+		case "SUBSCRIBED":
+			if owner == s.user.Email {
+				mb, err := s.emailRepo.GetMailbox(s.ctx, owner, mailbox)
+				watched = err == nil && mb != nil && mb.Subscribed
+			}
+		case "SUBTREE", "MAILBOXES":
+			for _, name := range filter.mailboxes {
+				o, m := s.resolveMailbox(s.mailboxArg(name))
+				if o == owner && (m == mailbox || (filter.kind == "SUBTREE" && strings.HasPrefix(mailbox, m+domain.MailboxDelimiter))) {
+					watched = true
+				}
+			}
+		}
+		if watched {
+			break
+		}
+	}
+	// STATUS responses need the right to read the mailbox
+	return watched && s.emailService.CheckAccess(s.ctx, owner, mailbox, s.user.Email, "r") == nil
+}
+
+// notifyStatus reports events of mailboxes other than the selected one as STATUS
+// responses, one per mailbox with the items the events affect
+func (s *Session) notifyStatus(events []ports.NotificationEvent) {
+	if s.notify.overflow.Load() {
+		s.send("* OK [NOTIFICATIONOVERFLOW] Too many notifications, NOTIFY is turned off")
+		s.stopNotify()
+		return
+	}
+
+	type change struct {
+		owner, mailbox string
+		messages       bool // Messages arrived or were expunged, rather than flags changed
+	}
+	var changes []*change
+	byMailbox := make(map[string]*change)
+	for _, evt := range events {
+		if s.selectedMailbox != nil && evt.UserID == s.selectedMailbox.UserID && evt.Mailbox == s.selectedMailbox.Name {
+			continue
+		}
+		event, ok := notifyEvents[evt.EventType]
+		if !ok || !s.notifyWatches(evt.UserID, evt.Mailbox, event) {
+			continue
+		}
+		key := evt.UserID + "\x00" + evt.Mailbox
+		c := byMailbox[key]
+		if c == nil {
+			c = &change{owner: evt.UserID, mailbox: evt.Mailbox}
+			byMailbox[key] = c
+			changes = append(changes, c)
+		}
+		c.messages = c.messages || event != "FLAGCHANGE"
+	}
+
+	for _, c := range changes {
+		items := []string{"UNSEEN"}
+		if c.messages {
+			items = []string{"MESSAGES", "UIDNEXT", "UNSEEN"}
+		}
+		s.sendMailboxStatus(c.owner, c.mailbox, items)
+	}
+}
+
+// sendNotifyStatus sends the STATUS of every watched mailbox but the selected one
+func (s *Session) sendNotifyStatus() error {
+	mailboxes, err := s.emailRepo.ListMailboxes(s.ctx, s.user.Email)
+	if err != nil {
+		return err
+	}
+	shared, err := s.emailRepo.ListSharedMailboxes(s.ctx, s.user.Email)
+	if err != nil {
+		return err
+	}
+	for _, mb := range append(mailboxes, shared...) {
+		if s.selectedMailbox != nil && mb.UserID == s.selectedMailbox.UserID && mb.Name == s.selectedMailbox.Name {
+			continue
+		}
+		if s.notifyWatches(mb.UserID, mb.Name, "") {
+			s.sendMailboxStatus(mb.UserID, mb.Name, []string{"MESSAGES", "UIDNEXT", "UIDVALIDITY"})
+		}
+	}
+	return nil
+}
+
+// sendMailboxStatus sends an unsolicited STATUS response, with the mod-sequence once
+// CONDSTORE is in effect. Mailboxes deleted in the meantime are skipped.
+func (s *Session) sendMailboxStatus(owner, name string, items []string) {
+	mb, err := s.emailRepo.GetMailbox(s.ctx, owner, name)
+	if err != nil || mb == nil {
+		return
+	}
+	if s.enabled["CONDSTORE"] {
+		items = append(items, "HIGHESTMODSEQ")
+	}
+	status, err := s.mailboxStatus(mb, items)
+	if err != nil {
+		s.logger.Error("NOTIFY failed to read mailbox status", "error", err, "mailbox", name)
+		return
+	}
+	s.send(status)
+}
+
+// fetchNew sends the data items requested with MessageNew for new messages of the
+// selected mailbox. Unlike FETCH it never sets \Seen.
+func (s *Session) fetchNew(uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	var set sequenceSet
+	for _, uid := range uids {
+		set = append(set, seqRange{start: uid, stop: uid})
+	}
+	msgs, err := s.selectMessages(set, true)
+	if err != nil {
+		return err
+	}
+
+	needsContent := false
+	for _, item := range s.notify.fetchItems {
+		needsContent = needsContent || item.needsContent()
+	}
+	for _, msg := range msgs {
+		seq := s.seqNum(msg.UID)
+		if seq == 0 {
+			continue
+		}
+		var raw []byte
+		var root *mimePart
+		if needsContent || msg.Size == 0 {
+			if raw, err = s.blobStore.Read(s.ctx, msg.BodyPath); err != nil {
+				return err
+			}
+			msg.Size = int64(len(raw))
+			if needsContent {
+				root = parseMessage(raw)
+			}
+		}
+		var attrs []string
+		for i, item := range s.notify.fetchItems {
+			// A BINARY section that can't be decoded is left out
+			if attr, err := s.fetchAttr(msg, raw, root, item, s.notify.sections[i]); err == nil {
+				attrs = append(attrs, attr)
+			}
+		}
+		s.send(fmt.Sprintf("* %d FETCH (%s)", seq, strings.Join(attrs, " ")))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package imap
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNotifyTokens(t *testing.T) {
+	args := splitArgs(`(SELECTED (MessageNew (UID BODY.PEEK[HEADER.FIELDS (SUBJECT FROM)]) MessageExpunge)) (MAILBOXES ("Other Users/bob/INBOX" Lists) NONE)`)
+	want := []string{"(", "SELECTED", "(", "MessageNew", "(", "UID", "BODY.PEEK[HEADER.FIELDS (SUBJECT FROM)]", ")", "MessageExpunge", ")", ")",
+		"(", "MAILBOXES", "(", "Other Users/bob/INBOX", "Lists", ")", "NONE", ")"}
+	if got := notifyTokens(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("notifyTokens() = %q, want %q", got, want)
+	}
+}
+
+func TestParseNotifySet(t *testing.T) {
+	set, status, err := parseNotifySet(notifyTokens(splitArgs(`(STATUS) (SELECTED (MessageNew (UID FLAGS) MessageExpunge FlagChange)) (SUBTREE (Lists Archive) (MessageNew MessageExpunge)) (INBOXES NONE)`)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status || len(set.filters) != 3 || len(set.fetchItems) != 2 {
+		t.Fatalf("got status=%v filters=%+v items=%+v", status, set.filters, set.fetchItems)
+	}
+	if f := set.filters[1]; f.kind != "SUBTREE" || !reflect.DeepEqual(f.mailboxes, []string{"Lists", "Archive"}) || !f.events["MESSAGENEW"] || f.events["FLAGCHANGE"] {
+		t.Errorf("SUBTREE filter: got %+v", f)
+	}
+	if len(set.filters[2].events) != 0 {
+		t.Errorf("INBOXES NONE: got events %v", set.filters[2].events)
+	}
+
+	if _, _, err := parseNotifySet(notifyTokens(splitArgs("(PERSONAL (MessageNew MessageExpunge MailboxName))"))); !errors.Is(err, errBadEvent) {
+		t.Errorf("MailboxName: expected errBadEvent, got %v", err)
+	}
+	for _, spec := range []string{
+		"",
+		"(STATUS)",
+		"(PERSONAL (MessageNew))",
+		"(PERSONAL (FlagChange))",
+		"(PERSONAL (MessageNew (FLAGS) MessageExpunge))",
+		"(SELECTED NONE) (SELECTED-DELAYED NONE)",
+		"(MAILBOXES (MessageNew MessageExpunge))",
+		"(EVERYTHING (MessageNew MessageExpunge))",
+		"(PERSONAL (MessageNew MessageExpunge)",
+	} {
+		if _, _, err := parseNotifySet(notifyTokens(splitArgs(spec))); err == nil {
+			t.Errorf("parseNotifySet(%q) expected error, got nil", spec)
+		}
+	}
+}
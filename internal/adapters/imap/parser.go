@@ -2,6 +2,7 @@ package imap
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -32,31 +33,66 @@ func ParseCommand(line string) (*Command, error) {
 	}, nil
 }
 
-// splitArgs splits string by space, respecting quotes
+// splitArgs splits string by space, respecting quotes. An empty quoted string ("") is kept
+// as an empty argument.
 func splitArgs(input string) []string {
 	var args []string
 	var current strings.Builder
 	inQuote := false
+	quoted := false
 
 	for _, r := range input {
 		if r == '"' {
 			inQuote = !inQuote
+			quoted = true
 			continue
 		}
 
 		if r == ' ' && !inQuote {
-			if current.Len() > 0 {
+			if current.Len() > 0 || quoted {
 				args = append(args, current.String())
 				current.Reset()
 			}
+			quoted = false
 		} else {
 			current.WriteRune(r)
 		}
 	}
 
-	if current.Len() > 0 {
+	if current.Len() > 0 || quoted {
 		args = append(args, current.String())
 	}
 
 	return args
 }
+
+// parseLiteral recognizes a line ending in a literal announcement: {n}, or the
+// non-synchronizing {n+} of LITERAL+ (RFC 7888). It returns the text before the literal,
+// its size and whether the client sends the data without waiting for a continuation.
+func parseLiteral(line string) (prefix string, size int64, nonSync bool, ok bool) {
+	if !strings.HasSuffix(line, "}") {
+		return "", 0, false, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return "", 0, false, false
+	}
+	digits := line[open+1 : len(line)-1]
+	if strings.HasSuffix(digits, "+") {
+		digits = digits[:len(digits)-1]
+		nonSync = true
+	}
+	if digits == "" {
+		return "", 0, false, false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", 0, false, false
+		}
+	}
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return "", 0, false, false
+	}
+	return line[:open], size, nonSync, true
+}
@@ -31,6 +31,11 @@ func TestParseCommand(t *testing.T) {
 			expected: &Command{Tag: "A04", Name: "NOOP", Args: []string{}},
 			hasError: false,
 		},
+		{
+			input:    "A05 LIST \"\" \"*\"",
+			expected: &Command{Tag: "A05", Name: "LIST", Args: []string{"", "*"}},
+			hasError: false,
+		},
 		{
 			input:    "",
 			expected: nil,
@@ -57,3 +62,29 @@ func TestParseCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		line    string
+		prefix  string
+		size    int64
+		nonSync bool
+		ok      bool
+	}{
+		{"A01 LOGIN {4}", "A01 LOGIN ", 4, false, true},
+		{"A02 APPEND INBOX (\\Seen) {310+}", "A02 APPEND INBOX (\\Seen) ", 310, true, true},
+		{"{0}", "", 0, false, true},
+		{"A03 NOOP", "", 0, false, false},
+		{"A04 SEARCH {}", "", 0, false, false},
+		{"A05 SEARCH {+}", "", 0, false, false},
+		{"A06 SEARCH {1a}", "", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		prefix, size, nonSync, ok := parseLiteral(tt.line)
+		if prefix != tt.prefix || size != tt.size || nonSync != tt.nonSync || ok != tt.ok {
+			t.Errorf("parseLiteral(%q) = %q, %d, %v, %v, expected %q, %d, %v, %v",
+				tt.line, prefix, size, nonSync, ok, tt.prefix, tt.size, tt.nonSync, tt.ok)
+		}
+	}
+}
@@ -1,13 +1,85 @@
 package imap
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
 )
 
+// RFC 9208 IMAP QUOTA Extension
+//
+// Quota roots are named "" for the user's own account, by the address for another
+// account, by "@" and the domain for a domain, and by the mailbox name for a mailbox with
+// limits of its own. STORAGE is reported and set in units of 1024 octets.
+
+// quotaRootName returns the name of a quota root as the client sees it
+func (s *Session) quotaRootName(root domain.QuotaRoot) string {
+	switch {
+	case root.IsDomain():
+		return quoteString("@" + root.Owner)
+	case root.Mailbox != "":
+		return s.quoteMailbox(s.mailboxPath(&domain.Mailbox{UserID: root.Owner, Name: root.Mailbox}))
+	case root.Owner == s.user.Email:
+		return `""`
+	}
+	return quoteString(root.Owner)
+}
+
+// quotaRoot resolves a quota root name. A mailbox takes precedence over an account of the
+// same name; whether the root is the user's to see or change is left to the caller.
+func (s *Session) quotaRoot(name string) (domain.QuotaRoot, error) {
+	switch {
+	case name == "":
+		return domain.QuotaRoot{Owner: s.user.Email}, nil
+	case strings.HasPrefix(name, "@"):
+		return domain.QuotaRoot{Owner: strings.ToLower(name[1:])}, nil
+	}
+	if mb, err := s.findMailbox(s.mailboxArg(name)); err == nil {
+		return domain.QuotaRoot{Owner: mb.UserID, Mailbox: mb.Name}, nil
+	}
+	if strings.Contains(name, "@") {
+		return domain.QuotaRoot{Owner: name}, nil
+	}
+	return domain.QuotaRoot{}, ports.ErrNotFound
+}
+
+// quotaResponse formats the untagged QUOTA response of a root. Only limited resources are
+// listed, except that an account without limits reports its storage with a limit of 0.
+func (s *Session) quotaResponse(quota *domain.Quota) string {
+	var resources []string
+	for _, resource := range domain.QuotaResources {
+		limit, ok := quota.Limits[resource]
+		if !ok {
+			continue
+		}
+		usage := quota.Usage[resource]
+		if resource == domain.QuotaStorage {
+			usage, limit = usage/1024, max(limit/1024, 1)
+		}
+		resources = append(resources, fmt.Sprintf("%s %d %d", resource, usage, limit))
+	}
+	if len(resources) == 0 {
+		resources = append(resources, fmt.Sprintf("%s %d 0", domain.QuotaStorage, quota.Usage[domain.QuotaStorage]/1024))
+	}
+	return fmt.Sprintf("* QUOTA %s (%s)", s.quotaRootName(quota.Root), strings.Join(resources, " "))
+}
+
+// overQuota returns the tagged NO response to a command refused by a quota check
+func overQuota(tag string, err error) string {
+	var qerr *services.QuotaExceededError
+	if !errors.As(err, &qerr) {
+		return fmt.Sprintf("%s NO [OVERQUOTA] Quota exceeded", tag)
+	}
+	resource := qerr.Resource[:1] + strings.ToLower(qerr.Resource[1:])
+	return fmt.Sprintf("%s NO [OVERQUOTA] %s limit exceeded", tag, resource)
+}
+
+// handleGetQuotaRoot GETQUOTAROOT <mailbox>
 func (s *Session) handleGetQuotaRoot(cmd *Command) {
 	if s.state != StateAuthenticated && s.state != StateSelected {
 		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
@@ -17,31 +89,32 @@ func (s *Session) handleGetQuotaRoot(cmd *Command) {
 		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
 		return
 	}
-	mailbox := cmd.Args[0]
+	mb, err := s.findMailbox(s.mailboxArg(cmd.Args[0]))
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO [NONEXISTENT] Mailbox does not exist", cmd.Tag))
+		return
+	}
 
-	ctx := s.ctx
-	user, err := s.userRepo.FindByEmail(ctx, s.user.Email)
+	quotas, err := s.quotaService.Roots(s.ctx, mb.UserID, mb.Name)
 	if err != nil {
 		s.logger.Error("Storage error during GETQUOTAROOT", "error", err)
 		s.send(fmt.Sprintf("%s NO Storage error", cmd.Tag))
 		return
 	}
 
-	// Root is always "" (user root)
-	s.send(fmt.Sprintf("* QUOTAROOT %q \"\"", mailbox))
-
-	usageKB := user.StorageUsed / 1024
-	quotaKB := user.StorageQuota / 1024
-	if user.StorageQuota > 0 && quotaKB == 0 {
-		quotaKB = 1
+	names := make([]string, 0, len(quotas))
+	for _, quota := range quotas {
+		names = append(names, s.quotaRootName(quota.Root))
+	}
+	s.send(fmt.Sprintf("* QUOTAROOT %s %s", s.quoteMailbox(s.mailboxPath(mb)), strings.Join(names, " ")))
+	for _, quota := range quotas {
+		s.send(s.quotaResponse(quota))
 	}
-
-	response := fmt.Sprintf("* QUOTA \"\" (STORAGE %d %d)", usageKB, quotaKB)
-	s.send(response)
-
 	s.send(fmt.Sprintf("%s OK GETQUOTAROOT completed", cmd.Tag))
 }
 
+// handleGetQuota GETQUOTA <root>. Users see their account, their domain and the mailboxes
+// they can access; administrators every root.
 func (s *Session) handleGetQuota(cmd *Command) {
 	if s.state != StateAuthenticated && s.state != StateSelected {
 		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
@@ -51,31 +124,41 @@ func (s *Session) handleGetQuota(cmd *Command) {
 		s.send(fmt.Sprintf("%s BAD Missing arguments", cmd.Tag))
 		return
 	}
-	root := cmd.Args[0]
-	if root != "" && root != "\"\"" {
+	root, err := s.quotaRoot(cmd.Args[0])
+	if err == nil && s.user.Role != domain.RoleAdmin {
+		switch {
+		case root.IsDomain() && root.Owner != strings.ToLower(emailDomain(s.user.Email)):
+			err = ports.ErrNotFound
+		case !root.IsDomain() && root.Mailbox == "" && root.Owner != s.user.Email:
+			err = ports.ErrNotFound
+		}
+	}
+	if err != nil {
 		s.send(fmt.Sprintf("%s NO Quota root does not exist", cmd.Tag))
 		return
 	}
 
-	ctx := s.ctx
-	user, err := s.userRepo.FindByEmail(ctx, s.user.Email)
+	quota, err := s.quotaService.Get(s.ctx, root)
+	// Every account is a quota root, a mailbox or a domain only once it has limits
+	if err == nil && (root.Mailbox != "" || root.IsDomain()) && len(quota.Limits) == 0 {
+		err = ports.ErrNotFound
+	}
+	if errors.Is(err, ports.ErrNotFound) {
+		s.send(fmt.Sprintf("%s NO Quota root does not exist", cmd.Tag))
+		return
+	}
 	if err != nil {
 		s.logger.Error("Storage error during GETQUOTA", "error", err)
 		s.send(fmt.Sprintf("%s NO Storage error", cmd.Tag))
 		return
 	}
 
-	usageKB := user.StorageUsed / 1024
-	quotaKB := user.StorageQuota / 1024
-	if user.StorageQuota > 0 && quotaKB == 0 {
-		quotaKB = 1
-	}
-
-	response := fmt.Sprintf("* QUOTA \"\" (STORAGE %d %d)", usageKB, quotaKB)
-	s.send(response)
+	s.send(s.quotaResponse(quota))
 	s.send(fmt.Sprintf("%s OK GETQUOTA completed", cmd.Tag))
 }
 
+// handleSetQuota SETQUOTA <root> (<resource> <limit> ...). Administrators only: the listed
+// limits replace those of the root, which becomes a quota root if it wasn't one.
 func (s *Session) handleSetQuota(cmd *Command) {
 	if s.state != StateAuthenticated && s.state != StateSelected {
 		s.send(fmt.Sprintf("%s NO [AUTH] Must be authenticated", cmd.Tag))
@@ -90,35 +173,55 @@ func (s *Session) handleSetQuota(cmd *Command) {
 		return
 	}
 
-	root := cmd.Args[0]
-	targetEmail := s.user.Email
-	if root != "" && root != "\"\"" {
-		targetEmail = root
+	list, rest, err := takeList(cmd.Args[1:])
+	if err == nil && (len(rest) > 0 || len(list)%2 != 0) {
+		err = fmt.Errorf("expected resource and limit pairs")
 	}
-
-	// Parse values usually enclosed in parens like (STORAGE 500)
-	rest := strings.Join(cmd.Args[1:], " ")
-	rest = strings.Trim(rest, "()")
-	parts := strings.Fields(rest)
-
-	if len(parts) != 2 || strings.ToUpper(parts[0]) != "STORAGE" {
-		s.send(fmt.Sprintf("%s BAD Unix format (expected STORAGE <limit>)", cmd.Tag))
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD Invalid SETQUOTA arguments: %v", cmd.Tag, err))
 		return
 	}
-
-	limitKB, err := strconv.ParseInt(parts[1], 10, 64)
+	limits := make(map[string]int64, len(list)/2)
+	for i := 0; i < len(list); i += 2 {
+		resource := strings.ToUpper(list[i])
+		limit, err := strconv.ParseInt(list[i+1], 10, 64)
+		if err != nil || limit < 0 {
+			s.send(fmt.Sprintf("%s BAD Invalid limit %s", cmd.Tag, list[i+1]))
+			return
+		}
+		switch resource {
+		case domain.QuotaStorage:
+			limit *= 1024
+		case domain.QuotaMessage, domain.QuotaMailbox:
+		default:
+			s.send(fmt.Sprintf("%s BAD Unsupported resource %s", cmd.Tag, list[i]))
+			return
+		}
+		limits[resource] = limit
+	}
+
+	root, err := s.quotaRoot(cmd.Args[0])
 	if err != nil {
-		s.send(fmt.Sprintf("%s BAD Invalid limit", cmd.Tag))
+		s.send(fmt.Sprintf("%s NO Quota root does not exist", cmd.Tag))
+		return
+	}
+	err = s.quotaService.SetLimits(s.ctx, root, limits)
+	if errors.Is(err, services.ErrUnsupportedQuotaResource) {
+		s.send(fmt.Sprintf("%s NO %v", cmd.Tag, err))
+		return
+	}
+	if errors.Is(err, ports.ErrNotFound) {
+		s.send(fmt.Sprintf("%s NO Quota root does not exist", cmd.Tag))
 		return
 	}
-
-	ctx := s.ctx
-	err = s.userRepo.UpdateQuota(ctx, targetEmail, limitKB*1024)
 	if err != nil {
-		s.logger.Error("UpdateQuota failed", "error", err)
+		s.logger.Error("SETQUOTA failed", "error", err)
 		s.send(fmt.Sprintf("%s NO Update failed", cmd.Tag))
 		return
 	}
 
+	if quota, err := s.quotaService.Get(s.ctx, root); err == nil {
+		s.send(s.quotaResponse(quota))
+	}
 	s.send(fmt.Sprintf("%s OK SETQUOTA completed", cmd.Tag))
 }
@@ -0,0 +1,714 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 3501 Section 6.4.4 SEARCH, with RFC 4731 ESEARCH result options and the RFC 5182
+// saved search result
+
+// searchDateLayout is the date format of SEARCH date criteria (RFC 3501 Section 9)
+const searchDateLayout = "2-Jan-2006"
+
+// searchKey is a node of a parsed SEARCH program. Flag criteria are normalized: SEEN becomes
+// FLAG \Seen, UNSEEN becomes NOT (FLAG \Seen), RECENT and NEW never match, OLD always does.
+type searchKey struct {
+	name     string       // "AND", "OR", "NOT", "ALL", "NONE", "FLAG", "SEQ", "UID", "MODSEQ", or the criterion name
+	children []*searchKey // Operands of AND, OR and NOT
+	set      sequenceSet  // SEQ and UID
+	field    string       // Header field of HEADER, FROM, TO, CC, BCC and SUBJECT
+	value    string       // Flag or search string
+	date     time.Time    // Start of the day, server time
+	size     int64
+	modSeq   uint64
+}
+
+var searchFlagKeys = map[string]string{
+	"ANSWERED": "\\Answered",
+	"DELETED":  "\\Deleted",
+	"DRAFT":    "\\Draft",
+	"FLAGGED":  "\\Flagged",
+	"SEEN":     "\\Seen",
+}
+
+// searchTokens splits command arguments into search tokens, separating parentheses
+func searchTokens(args []string) []string {
+	var tokens []string
+	for _, arg := range args {
+		for strings.HasPrefix(arg, "(") {
+			tokens = append(tokens, "(")
+			arg = arg[1:]
+		}
+		closing := 0
+		for strings.HasSuffix(arg, ")") {
+			closing++
+			arg = arg[:len(arg)-1]
+		}
+		if arg != "" {
+			tokens = append(tokens, arg)
+		}
+		for ; closing > 0; closing-- {
+			tokens = append(tokens, ")")
+		}
+	}
+	return tokens
+}
+
+type searchParser struct {
+	tokens []string
+	pos    int
+	saved  sequenceSet // UIDs of the saved search result, for "$" (RFC 5182)
+}
+
+func (p *searchParser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of search criteria")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, nil
+}
+
+// parseProgram parses the remaining tokens as a conjunction of search keys
+func (p *searchParser) parseProgram() (*searchKey, error) {
+	program := &searchKey{name: "AND"}
+	for p.pos < len(p.tokens) {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		program.children = append(program.children, key)
+	}
+	if len(program.children) == 0 {
+		return nil, fmt.Errorf("missing search criteria")
+	}
+	return program, nil
+}
+
+func (p *searchParser) parseKey() (*searchKey, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToUpper(tok)
+
+	if flag, ok := searchFlagKeys[name]; ok {
+		return &searchKey{name: "FLAG", value: flag}, nil
+	}
+	if flag, ok := searchFlagKeys[strings.TrimPrefix(name, "UN")]; ok && strings.HasPrefix(name, "UN") {
+		return notKey(&searchKey{name: "FLAG", value: flag}), nil
+	}
+
+	switch name {
+	case "(":
+		list := &searchKey{name: "AND"}
+		for {
+			if p.pos < len(p.tokens) && p.tokens[p.pos] == ")" {
+				p.pos++
+				break
+			}
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			list.children = append(list.children, key)
+		}
+		if len(list.children) == 0 {
+			return nil, fmt.Errorf("empty search list")
+		}
+		return list, nil
+
+	case "ALL", "OLD":
+		return &searchKey{name: "ALL"}, nil
+	case "NEW", "RECENT":
+		// Recent messages are not tracked
+		return &searchKey{name: "NONE"}, nil
+
+	case "KEYWORD", "UNKEYWORD":
+		flag, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		key := &searchKey{name: "FLAG", value: flag}
+		if name == "UNKEYWORD" {
+			return notKey(key), nil
+		}
+		return key, nil
+
+	case "BCC", "CC", "FROM", "TO", "SUBJECT":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		return &searchKey{name: "HEADER", field: name, value: value}, nil
+
+	case "HEADER":
+		field, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		return &searchKey{name: "HEADER", field: field, value: value}, nil
+
+	case "BODY", "TEXT":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		return &searchKey{name: name, value: value}, nil
+
+	case "BEFORE", "ON", "SINCE", "SENTBEFORE", "SENTON", "SENTSINCE":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.ParseInLocation(searchDateLayout, value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", value)
+		}
+		return &searchKey{name: name, date: date}, nil
+
+	case "LARGER", "SMALLER":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid size %q", value)
+		}
+		return &searchKey{name: name, size: size}, nil
+
+	case "NOT":
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		return notKey(key), nil
+
+	case "OR":
+		a, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		return &searchKey{name: "OR", children: []*searchKey{a, b}}, nil
+
+	case "UID":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if value == "$" {
+			return &searchKey{name: "UID", set: p.saved}, nil
+		}
+		set, err := parseSequenceSet(value)
+		if err != nil {
+			return nil, err
+		}
+		return &searchKey{name: "UID", set: set}, nil
+
+	case "MODSEQ":
+		// RFC 7162 Section 3.1.5: MODSEQ [<entry-name> <entry-type-req>] <mod-sequence-valzer>.
+		// Mod-sequences are kept per message, not per flag, so the entry is ignored.
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(value, "/") {
+			if _, err := p.next(); err != nil {
+				return nil, err
+			}
+			if value, err = p.next(); err != nil {
+				return nil, err
+			}
+		}
+		modSeq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mod-sequence %q", value)
+		}
+		return &searchKey{name: "MODSEQ", modSeq: modSeq}, nil
+	}
+
+	// The saved result holds UIDs, whichever numbers the SEARCH that saved it reported
+	if tok == "$" {
+		return &searchKey{name: "UID", set: p.saved}, nil
+	}
+	if set, err := parseSequenceSet(tok); err == nil {
+		return &searchKey{name: "SEQ", set: set}, nil
+	}
+	return nil, fmt.Errorf("unknown search key %q", tok)
+}
+
+func notKey(key *searchKey) *searchKey {
+	return &searchKey{name: "NOT", children: []*searchKey{key}}
+}
+
+// textCriteria returns the search strings of all BODY and TEXT criteria in the program
+func (k *searchKey) textCriteria() []string {
+	var texts []string
+	if k.name == "BODY" || k.name == "TEXT" {
+		texts = append(texts, k.value)
+	}
+	for _, child := range k.children {
+		texts = append(texts, child.textCriteria()...)
+	}
+	return texts
+}
+
+// usesModSeq reports whether the program contains a MODSEQ criterion
+func (k *searchKey) usesModSeq() bool {
+	if k.name == "MODSEQ" {
+		return true
+	}
+	for _, child := range k.children {
+		if child.usesModSeq() {
+			return true
+		}
+	}
+	return false
+}
+
+// needsContent reports whether evaluating the key requires the message blob
+func (k *searchKey) needsContent() bool {
+	switch k.name {
+	case "HEADER", "SENTBEFORE", "SENTON", "SENTSINCE":
+		return true
+	}
+	for _, child := range k.children {
+		if child.needsContent() {
+			return true
+		}
+	}
+	return false
+}
+
+// mailboxFilter derives the criteria of a program that the repository can apply up front.
+// Only top-level conjuncts are used; the full program is still evaluated on the results.
+func (k *searchKey) mailboxFilter(largestUID uint32) domain.MailboxFilter {
+	var filter domain.MailboxFilter
+	for _, c := range k.children {
+		switch c.name {
+		case "FLAG":
+			filter.Flags = append(filter.Flags, c.value)
+		case "NOT":
+			if c.children[0].name == "FLAG" {
+				filter.NotFlags = append(filter.NotFlags, c.children[0].value)
+			}
+		case "SINCE", "ON":
+			if filter.Since.IsZero() || c.date.After(filter.Since) {
+				filter.Since = c.date
+			}
+			if c.name == "ON" {
+				end := c.date.AddDate(0, 0, 1)
+				if filter.Before.IsZero() || end.Before(filter.Before) {
+					filter.Before = end
+				}
+			}
+		case "BEFORE":
+			if filter.Before.IsZero() || c.date.Before(filter.Before) {
+				filter.Before = c.date
+			}
+		case "LARGER":
+			filter.Larger = max(filter.Larger, c.size)
+		case "SMALLER":
+			if filter.Smaller == 0 || c.size < filter.Smaller {
+				filter.Smaller = c.size
+			}
+		case "MODSEQ":
+			if c.modSeq > 0 {
+				filter.ChangedSince = max(filter.ChangedSince, c.modSeq-1)
+			}
+		case "UID":
+			minUID, maxUID := c.set.bounds(largestUID)
+			filter.MinUID = max(filter.MinUID, minUID)
+			if filter.MaxUID == 0 || maxUID < filter.MaxUID {
+				filter.MaxUID = maxUID
+			}
+		}
+	}
+	return filter
+}
+
+// searchMessage holds a candidate message while a program is evaluated against it
+type searchMessage struct {
+	msg      *domain.Message
+	seq      uint32
+	header   textproto.MIMEHeader       // nil until read by readHeader
+	textHits map[string]map[string]bool // Search string -> IDs of matching messages
+}
+
+func (k *searchKey) matches(m *searchMessage, largestSeq, largestUID uint32) bool {
+	switch k.name {
+	case "AND":
+		for _, child := range k.children {
+			if !child.matches(m, largestSeq, largestUID) {
+				return false
+			}
+		}
+		return true
+	case "OR":
+		return k.children[0].matches(m, largestSeq, largestUID) || k.children[1].matches(m, largestSeq, largestUID)
+	case "NOT":
+		return !k.children[0].matches(m, largestSeq, largestUID)
+	case "ALL":
+		return true
+	case "NONE":
+		return false
+	case "SEQ":
+		return k.set.contains(m.seq, largestSeq)
+	case "UID":
+		return k.set.contains(m.msg.UID, largestUID)
+	case "FLAG":
+		for _, flag := range strings.Fields(messageFlags(m.msg)) {
+			if strings.EqualFold(flag, k.value) {
+				return true
+			}
+		}
+		return false
+	case "BEFORE":
+		return m.msg.ReceivedAt.Before(k.date)
+	case "ON":
+		return !m.msg.ReceivedAt.Before(k.date) && m.msg.ReceivedAt.Before(k.date.AddDate(0, 0, 1))
+	case "SINCE":
+		return !m.msg.ReceivedAt.Before(k.date)
+	case "MODSEQ":
+		return m.msg.ModSeq >= k.modSeq
+	case "LARGER":
+		return m.msg.Size > k.size
+	case "SMALLER":
+		return m.msg.Size < k.size
+	case "BODY", "TEXT":
+		return m.textHits[k.value][m.msg.ID]
+	case "HEADER":
+		return m.headerContains(k.field, k.value)
+	case "SENTBEFORE", "SENTON", "SENTSINCE":
+		sent, err := mail.ParseDate(m.header.Get("Date"))
+		if err != nil {
+			return false
+		}
+		// Compare dates only, disregarding time and timezone
+		day := time.Date(sent.Year(), sent.Month(), sent.Day(), 0, 0, 0, 0, time.Local)
+		switch k.name {
+		case "SENTBEFORE":
+			return day.Before(k.date)
+		case "SENTON":
+			return day.Equal(k.date)
+		default:
+			return !day.Before(k.date)
+		}
+	}
+	return false
+}
+
+// headerContains reports whether a header field contains value, case-insensitively.
+// An empty value matches any message that has the field.
+func (m *searchMessage) headerContains(field, value string) bool {
+	values := m.header.Values(field)
+	if value == "" {
+		return len(values) > 0
+	}
+	dec := &mime.WordDecoder{}
+	needle := strings.ToLower(value)
+	for _, v := range values {
+		if decoded, err := dec.DecodeHeader(v); err == nil {
+			v = decoded
+		}
+		if strings.Contains(strings.ToLower(v), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the smallest and largest number the set can contain
+func (set sequenceSet) bounds(largest uint32) (uint32, uint32) {
+	var lo, hi uint32
+	for i, r := range set {
+		start, stop := r.start, r.stop
+		if start == 0 {
+			start = largest
+		}
+		if stop == 0 {
+			stop = largest
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		if i == 0 || start < lo {
+			lo = start
+		}
+		if stop > hi {
+			hi = stop
+		}
+	}
+	return lo, hi
+}
+
+// formatSequenceSet formats ascending numbers as a compact sequence set, e.g. "1:3,5"
+func formatSequenceSet(nums []uint32) string {
+	var parts []string
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.FormatUint(uint64(nums[i]), 10))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d:%d", nums[i], nums[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// handleSearch SEARCH [RETURN (<options>)] [CHARSET <charset>] <criteria>
+func (s *Session) handleSearch(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.search(cmd.Tag, "SEARCH", cmd.Args, false)
+}
+
+func (s *Session) search(tag, command string, args []string, byUID bool) {
+	// ACL check: requires 'r' (read) right
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "r"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+
+	tokens := searchTokens(args)
+
+	// RFC 4731: RETURN (MIN MAX COUNT ALL) requests an ESEARCH response, which is the only
+	// kind of SEARCH response with IMAP4rev2 (RFC 9051 Section 6.4.4). RFC 5182: SAVE keeps
+	// the result for later commands to refer to as "$".
+	var returnOpts []string
+	extended, save := s.enabled["IMAP4rev2"], false
+	if len(tokens) > 0 && strings.EqualFold(tokens[0], "RETURN") {
+		extended = true
+		end := -1
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i] == ")" {
+				end = i
+				break
+			}
+		}
+		if len(tokens) < 3 || tokens[1] != "(" || end < 0 {
+			s.send(fmt.Sprintf("%s BAD Invalid RETURN options", tag))
+			return
+		}
+		for _, opt := range tokens[2:end] {
+			opt = strings.ToUpper(opt)
+			switch opt {
+			case "MIN", "MAX", "COUNT", "ALL":
+				returnOpts = append(returnOpts, opt)
+			case "SAVE":
+				save = true
+			default:
+				s.send(fmt.Sprintf("%s BAD Unknown RETURN option %s", tag, opt))
+				return
+			}
+		}
+		if len(returnOpts) == 0 && !save {
+			returnOpts = []string{"ALL"}
+		}
+		tokens = tokens[end+1:]
+	} else if extended {
+		returnOpts = []string{"ALL"}
+	}
+	// RFC 5182 Section 2.1: a SEARCH that fails empties the saved result. The criteria
+	// still see the previous one.
+	saved := s.searchResult
+	if save {
+		s.searchResult = sequenceSet{}
+	}
+
+	if len(tokens) >= 2 && strings.EqualFold(tokens[0], "CHARSET") {
+		switch strings.ToUpper(tokens[1]) {
+		case "UTF-8", "US-ASCII":
+		default:
+			s.send(fmt.Sprintf("%s NO [BADCHARSET (UTF-8 US-ASCII)] Unsupported charset", tag))
+			return
+		}
+		tokens = tokens[2:]
+	}
+
+	parser := &searchParser{tokens: tokens, saved: saved}
+	program, err := parser.parseProgram()
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+
+	matched, err := s.runSearch(program)
+	if err != nil {
+		s.logger.Error("IMAP SEARCH failed", "error", err)
+		s.send(fmt.Sprintf("%s NO Search failed", tag))
+		return
+	}
+
+	// RFC 7162 Section 3.1.5: with a MODSEQ criterion the highest mod-sequence of the
+	// matched messages is returned as well
+	var highestModSeq uint64
+	if program.usesModSeq() {
+		s.enabled["CONDSTORE"] = true
+		for _, m := range matched {
+			highestModSeq = max(highestModSeq, m.msg.ModSeq)
+		}
+	}
+
+	results := make([]uint32, 0, len(matched))
+	uids := make([]uint32, 0, len(matched))
+	for _, m := range matched {
+		uids = append(uids, m.msg.UID)
+		if byUID {
+			results = append(results, m.msg.UID)
+		} else {
+			results = append(results, m.seq)
+		}
+	}
+
+	if save {
+		// RFC 5182 Section 2.1: with only MIN and MAX, only those messages are saved
+		onlyMinMax := len(returnOpts) > 0 && !slices.Contains(returnOpts, "ALL") && !slices.Contains(returnOpts, "COUNT")
+		if onlyMinMax && len(uids) > 0 {
+			var kept []uint32
+			if slices.Contains(returnOpts, "MIN") {
+				kept = append(kept, uids[0])
+			}
+			if slices.Contains(returnOpts, "MAX") && (len(kept) == 0 || uids[len(uids)-1] != kept[0]) {
+				kept = append(kept, uids[len(uids)-1])
+			}
+			uids = kept
+		}
+		s.searchResult = newSequenceSet(uids)
+	}
+
+	if extended && len(returnOpts) > 0 {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "* ESEARCH (TAG %q)", tag)
+		if byUID {
+			sb.WriteString(" UID")
+		}
+		for _, opt := range returnOpts {
+			switch {
+			case opt == "COUNT":
+				fmt.Fprintf(&sb, " COUNT %d", len(results))
+			case len(results) == 0:
+				// MIN, MAX and ALL are omitted when nothing matched
+			case opt == "MIN":
+				fmt.Fprintf(&sb, " MIN %d", results[0])
+			case opt == "MAX":
+				fmt.Fprintf(&sb, " MAX %d", results[len(results)-1])
+			case opt == "ALL":
+				fmt.Fprintf(&sb, " ALL %s", formatSequenceSet(results))
+			}
+		}
+		if highestModSeq > 0 {
+			fmt.Fprintf(&sb, " MODSEQ %d", highestModSeq)
+		}
+		s.send(sb.String())
+	} else if !extended {
+		var sb strings.Builder
+		sb.WriteString("* SEARCH")
+		for _, n := range results {
+			fmt.Fprintf(&sb, " %d", n)
+		}
+		if highestModSeq > 0 {
+			fmt.Fprintf(&sb, " (MODSEQ %d)", highestModSeq)
+		}
+		s.send(sb.String())
+	}
+
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+// runSearch evaluates a program against the selected mailbox. Structured criteria are
+// narrowed by the repository, text criteria are answered from the full-text index.
+func (s *Session) runSearch(program *searchKey) ([]*searchMessage, error) {
+	if len(s.uids) == 0 {
+		return nil, nil
+	}
+	largestSeq := uint32(len(s.uids))
+	largestUID := s.uids[len(s.uids)-1]
+
+	msgs, err := s.emailRepo.FindInMailbox(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, program.mailboxFilter(largestUID))
+	if err != nil {
+		return nil, err
+	}
+
+	textHits := make(map[string]map[string]bool)
+	for _, text := range program.textCriteria() {
+		if _, done := textHits[text]; done {
+			continue
+		}
+		// A quoted phrase keeps index query operators in the search string literal
+		query := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		hits, err := s.searchIdx.SearchMailbox(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, query)
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]bool, len(hits))
+		for _, id := range hits {
+			ids[id] = true
+		}
+		textHits[text] = ids
+	}
+	needsContent := program.needsContent()
+
+	var matched []*searchMessage
+	for _, msg := range msgs {
+		seq := s.seqNum(msg.UID)
+		if seq == 0 {
+			continue
+		}
+		m := &searchMessage{msg: msg, seq: uint32(seq), textHits: textHits}
+
+		if needsContent || msg.Size == 0 {
+			if err := s.readHeader(m); err != nil {
+				s.logger.Warn("IMAP SEARCH skipped unreadable message", "error", err, "msg_id", msg.ID)
+				continue
+			}
+		}
+
+		if program.matches(m, largestSeq, largestUID) {
+			matched = append(matched, m)
+		}
+	}
+	return matched, nil
+}
+
+// readHeader reads the header fields of a candidate message from the blob store, which
+// also gives the size of messages stored without one
+func (s *Session) readHeader(m *searchMessage) error {
+	raw, err := s.blobStore.Read(s.ctx, m.msg.BodyPath)
+	if err != nil {
+		return err
+	}
+	m.msg.Size = int64(len(raw))
+	header, _ := splitHeader(raw)
+	m.header = textproto.MIMEHeader{}
+	if fields, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(header))).ReadMIMEHeader(); fields != nil || err == nil {
+		m.header = fields
+	}
+	return nil
+}
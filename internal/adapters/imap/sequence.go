@@ -0,0 +1,178 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 3501 Section 9: sequence-set = (seq-number / seq-range) *("," sequence-set)
+
+// seqRange is an inclusive range of a sequence set. A bound of 0 stands for "*",
+// the largest number in use in the mailbox.
+type seqRange struct {
+	start, stop uint32
+}
+
+// sequenceSet is a parsed IMAP sequence set (message sequence numbers or UIDs)
+type sequenceSet []seqRange
+
+// parseSequenceSet parses a sequence set such as "1,3:5,7:*"
+func parseSequenceSet(spec string) (sequenceSet, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("empty sequence set")
+	}
+
+	var set sequenceSet
+	for _, item := range strings.Split(spec, ",") {
+		startStr, stopStr, isRange := strings.Cut(item, ":")
+		start, err := parseSeqNumber(startStr)
+		if err != nil {
+			return nil, err
+		}
+		stop := start
+		if isRange {
+			if stop, err = parseSeqNumber(stopStr); err != nil {
+				return nil, err
+			}
+		}
+		set = append(set, seqRange{start: start, stop: stop})
+	}
+	return set, nil
+}
+
+// newSequenceSet returns the set of ascending numbers, joining consecutive ones into ranges
+func newSequenceSet(nums []uint32) sequenceSet {
+	set := sequenceSet{}
+	for _, n := range nums {
+		if last := len(set) - 1; last >= 0 && set[last].stop+1 == n {
+			set[last].stop = n
+			continue
+		}
+		set = append(set, seqRange{start: n, stop: n})
+	}
+	return set
+}
+
+// parseSequenceArg parses the sequence set argument of a command, where "$" stands for the
+// saved search result (RFC 5182). The saved result is kept as UIDs, so for sequence numbers
+// it is resolved against the selected mailbox; messages expunged since are left out.
+func (s *Session) parseSequenceArg(spec string, byUID bool) (sequenceSet, error) {
+	if spec != "$" {
+		return parseSequenceSet(spec)
+	}
+	if byUID {
+		return append(sequenceSet{}, s.searchResult...), nil
+	}
+	var seqs []uint32
+	for i, uid := range s.uids {
+		if s.searchResult.contains(uid, s.uids[len(s.uids)-1]) {
+			seqs = append(seqs, uint32(i+1))
+		}
+	}
+	return newSequenceSet(seqs), nil
+}
+
+func parseSeqNumber(s string) (uint32, error) {
+	if s == "*" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid sequence number %q", s)
+	}
+	return uint32(n), nil
+}
+
+// contains reports whether n is in the set, resolving "*" to largest.
+// Ranges may be given in either order ("5:3" equals "3:5").
+func (set sequenceSet) contains(n, largest uint32) bool {
+	for _, r := range set {
+		start, stop := r.start, r.stop
+		if start == 0 {
+			start = largest
+		}
+		if stop == 0 {
+			stop = largest
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		if n >= start && n <= stop {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSequenceMap reads the UIDs of the selected mailbox so that message sequence numbers
+// can be resolved. Sequence number n refers to s.uids[n-1].
+func (s *Session) loadSequenceMap() error {
+	msgs, err := s.emailRepo.FindByUIDRange(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, 1, 4294967295)
+	if err != nil {
+		return err
+	}
+	s.uids = make([]uint32, 0, len(msgs))
+	for _, msg := range msgs {
+		s.uids = append(s.uids, msg.UID)
+	}
+	return nil
+}
+
+// seqNum returns the message sequence number of a UID, or 0 if the client does not know it
+func (s *Session) seqNum(uid uint32) int {
+	i := sort.Search(len(s.uids), func(i int) bool { return s.uids[i] >= uid })
+	if i < len(s.uids) && s.uids[i] == uid {
+		return i + 1
+	}
+	return 0
+}
+
+// selectMessages resolves a sequence set against the selected mailbox and returns the
+// matching messages in mailbox order. If byUID is set the set holds UIDs, otherwise
+// message sequence numbers.
+func (s *Session) selectMessages(set sequenceSet, byUID bool) ([]*domain.Message, error) {
+	if len(s.uids) == 0 {
+		return nil, nil
+	}
+
+	var wanted []uint32
+	if byUID {
+		largest := s.uids[len(s.uids)-1]
+		for _, uid := range s.uids {
+			if set.contains(uid, largest) {
+				wanted = append(wanted, uid)
+			}
+		}
+	} else {
+		largest := uint32(len(s.uids))
+		for i, uid := range s.uids {
+			if set.contains(uint32(i+1), largest) {
+				wanted = append(wanted, uid)
+			}
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := s.emailRepo.FindByUIDRange(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, wanted[0], wanted[len(wanted)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	byUIDMap := make(map[uint32]*domain.Message, len(msgs))
+	for _, msg := range msgs {
+		byUIDMap[msg.UID] = msg
+	}
+	result := make([]*domain.Message, 0, len(wanted))
+	for _, uid := range wanted {
+		if msg, ok := byUIDMap[uid]; ok {
+			result = append(result, msg)
+		}
+	}
+	return result, nil
+}
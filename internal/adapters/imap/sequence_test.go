@@ -0,0 +1,63 @@
+package imap
+
+import "testing"
+
+func TestSequenceSet(t *testing.T) {
+	tests := []struct {
+		spec     string
+		largest  uint32
+		contains []uint32
+		excludes []uint32
+	}{
+		{spec: "1", largest: 5, contains: []uint32{1}, excludes: []uint32{2}},
+		{spec: "2:4", largest: 5, contains: []uint32{2, 3, 4}, excludes: []uint32{1, 5}},
+		{spec: "4:2", largest: 5, contains: []uint32{2, 3, 4}, excludes: []uint32{1, 5}},
+		{spec: "1,3,5", largest: 5, contains: []uint32{1, 3, 5}, excludes: []uint32{2, 4}},
+		{spec: "*", largest: 5, contains: []uint32{5}, excludes: []uint32{1, 4}},
+		{spec: "3:*", largest: 5, contains: []uint32{3, 4, 5}, excludes: []uint32{2}},
+		// "n:*" includes the largest number even when n is beyond it
+		{spec: "9:*", largest: 5, contains: []uint32{5}, excludes: []uint32{4}},
+		{spec: "1:2,4:*", largest: 6, contains: []uint32{1, 2, 4, 6}, excludes: []uint32{3}},
+	}
+
+	for _, tt := range tests {
+		set, err := parseSequenceSet(tt.spec)
+		if err != nil {
+			t.Errorf("parseSequenceSet(%q) unexpected error: %v", tt.spec, err)
+			continue
+		}
+		for _, n := range tt.contains {
+			if !set.contains(n, tt.largest) {
+				t.Errorf("%q should contain %d", tt.spec, n)
+			}
+		}
+		for _, n := range tt.excludes {
+			if set.contains(n, tt.largest) {
+				t.Errorf("%q should not contain %d", tt.spec, n)
+			}
+		}
+	}
+
+	for _, spec := range []string{"", "0", "1:", "a", "1,,2", "1:2:3"} {
+		if _, err := parseSequenceSet(spec); err == nil {
+			t.Errorf("parseSequenceSet(%q) expected error, got nil", spec)
+		}
+	}
+}
+
+func TestNewSequenceSet(t *testing.T) {
+	set := newSequenceSet([]uint32{1, 2, 3, 5, 7, 8})
+	want := sequenceSet{{1, 3}, {5, 5}, {7, 8}}
+	if len(set) != len(want) {
+		t.Fatalf("got %v, want %v", set, want)
+	}
+	for i := range want {
+		if set[i] != want[i] {
+			t.Errorf("got %v, want %v", set, want)
+		}
+	}
+	// An empty set is not nil, which would mean every message to some callers
+	if set := newSequenceSet(nil); set == nil || set.contains(1, 1) {
+		t.Errorf("got %v for no numbers", set)
+	}
+}
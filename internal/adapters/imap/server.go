@@ -2,9 +2,11 @@ package imap
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
@@ -18,35 +20,77 @@ type Server struct {
 	metrics         *observability.Metrics
 	userRepo        ports.UserRepository
 	emailRepo       ports.EmailRepository
+	searchIdx       ports.SearchIndex
 	spamService     ports.SpamFilter
 	blobStore       ports.BlobStore
 	notificationBus ports.NotificationBus
+	auth            *sasl.Authenticator
+	tlsConfig       *tls.Config
+	implicitTLS     bool // TLS from the first byte (RFC 8314) instead of STARTTLS
 	listener        net.Listener
 	connSem         chan struct{}
 }
 
-func NewServer(cfg config.IMAPConfig, logger *observability.Logger, metrics *observability.Metrics, userRepo ports.UserRepository, emailRepo ports.EmailRepository, spamService ports.SpamFilter, blobStore ports.BlobStore, notificationBus ports.NotificationBus) *Server {
+func NewServer(cfg config.IMAPConfig, logger *observability.Logger, metrics *observability.Metrics, userRepo ports.UserRepository, emailRepo ports.EmailRepository, searchIdx ports.SearchIndex, spamService ports.SpamFilter, blobStore ports.BlobStore, notificationBus ports.NotificationBus, auth *sasl.Authenticator) *Server {
 	return &Server{
 		config:          cfg,
 		logger:          logger,
 		metrics:         metrics,
 		userRepo:        userRepo,
 		emailRepo:       emailRepo,
+		searchIdx:       searchIdx,
 		spamService:     spamService,
 		blobStore:       blobStore,
 		notificationBus: notificationBus,
+		auth:            auth,
 		connSem:         make(chan struct{}, maxIMAPConnections),
 	}
 }
 
+// SetTLSConfig sets the TLS configuration used when the IMAP config names no certificate
+// files, e.g. certificates managed by ACME. Must be called before Start.
+func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// SetImplicitTLS makes the server listen on the TLS port and speak TLS immediately
+// (RFC 8314), as on port 993, instead of offering STARTTLS. Must be called before Start.
+func (s *Server) SetImplicitTLS(implicitTLS bool) {
+	s.implicitTLS = implicitTLS
+}
+
 func (s *Server) Start(ctx context.Context) error {
-	addr := fmt.Sprintf(":%d", s.config.Port)
+	port := s.config.Port
+	if s.implicitTLS {
+		port = s.config.PortTLS
+	}
+	addr := fmt.Sprintf(":%d", port)
+
+	// Certificate files configured for IMAP take precedence over the shared configuration
+	if s.config.TLSCert != "" && s.config.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+		if err != nil {
+			s.logger.Warn("failed to load IMAP TLS certificate", "error", err)
+		} else {
+			s.tlsConfig = &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+		}
+	}
+	if s.implicitTLS && s.tlsConfig == nil {
+		return fmt.Errorf("implicit TLS on %s requires a TLS configuration", addr)
+	}
+
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("IMAP listen failed: %w", err)
 	}
+	if s.implicitTLS {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
 	s.listener = listener
-	s.logger.Info("IMAP server started", "addr", addr)
+	s.logger.Info("IMAP server started", "addr", addr, "implicit_tls", s.implicitTLS)
 
 	go func() {
 		<-ctx.Done()
@@ -90,6 +134,7 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 		s.metrics.IncrementActiveIMAP()
 		defer s.metrics.DecrementActiveIMAP()
 	}
-	session := NewSession(ctx, conn, s.config, s.logger, s.userRepo, s.emailRepo, s.spamService, s.blobStore, s.notificationBus)
+	session := NewSession(ctx, conn, s.config, s.logger, s.userRepo, s.emailRepo, s.searchIdx, s.spamService, s.blobStore, s.notificationBus, s.auth)
+	session.tlsConfig = s.tlsConfig
 	session.Serve()
 }
@@ -5,10 +5,13 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/tlsutil"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
@@ -18,6 +21,15 @@ import (
 
 type State int
 
+// capabilities lists the extensions announced in the greeting and by CAPABILITY; STARTTLS
+// and the AUTH= mechanisms depend on the connection and are added by capability
+const capabilities = "IMAP4rev1 IMAP4rev2 SASL-IR ID ACL NAMESPACE QUOTA QUOTA=RES-STORAGE QUOTA=RES-MESSAGE QUOTA=RES-MAILBOX QUOTASET METADATA IDLE NOTIFY ESEARCH SEARCHRES SORT THREAD=ORDEREDSUBJECT THREAD=REFERENCES UNSELECT UIDPLUS MOVE ENABLE LITERAL+ MULTIAPPEND BINARY COMPRESS=DEFLATE CONDSTORE QRESYNC CHILDREN LIST-EXTENDED LIST-STATUS SPECIAL-USE CREATE-SPECIAL-USE STATUS=SIZE UTF8=ACCEPT"
+
+// maxCommandLiteral bounds the literals of command arguments, e.g. a LOGIN password or a
+// SEARCH string; message literals of APPEND are limited by maxAppendSize and
+// config.MaxAppendSize instead
+const maxCommandLiteral = 64 * 1024
+
 const (
 	StateNotAuthenticated State = iota
 	StateAuthenticated
@@ -33,20 +45,34 @@ type Session struct {
 	config          config.IMAPConfig
 	logger          *observability.Logger
 	userRepo        ports.UserRepository
+	auth            *sasl.Authenticator
 	emailRepo       ports.EmailRepository
+	searchIdx       ports.SearchIndex
 	emailService    *services.EmailService
+	quotaService    *services.QuotaService
 	spamService     ports.SpamFilter
 	blobStore       ports.BlobStore
 	notificationBus ports.NotificationBus
 	reader          *bufio.Reader
 	writer          *bufio.Writer
+	tlsConfig       *tls.Config // For STARTTLS; nil if no certificate is available
 	isTLS           bool
-	user            *domain.User    // Logged in user
-	selectedMailbox *domain.Mailbox // Currently selected mailbox
+	user            *domain.User      // Logged in user
+	selectedMailbox *domain.Mailbox   // Currently selected mailbox
+	uids            []uint32          // UIDs of the selected mailbox; index i is sequence number i+1
+	modSeq          uint64            // Mod-sequence up to which flag changes of the selected mailbox were reported
+	flagsSent       map[uint32]uint64 // Mod-sequences of flag changes the session reported itself, by UID
+	readOnly        bool              // Mailbox was opened with EXAMINE
+	enabled         map[string]bool   // Extensions in effect, e.g. turned on with ENABLE (RFC 5161)
+	notify          *notifySet        // Mailboxes and events watched with NOTIFY (RFC 5465); nil if none
+	searchResult    sequenceSet       // UIDs saved with SEARCH RETURN (SAVE), referred to as "$" (RFC 5182)
 }
 
-func NewSession(parentCtx context.Context, conn net.Conn, cfg config.IMAPConfig, logger *observability.Logger, userRepo ports.UserRepository, emailRepo ports.EmailRepository, spamService ports.SpamFilter, blobStore ports.BlobStore, notificationBus ports.NotificationBus) *Session {
+func NewSession(parentCtx context.Context, conn net.Conn, cfg config.IMAPConfig, logger *observability.Logger, userRepo ports.UserRepository, emailRepo ports.EmailRepository, searchIdx ports.SearchIndex, spamService ports.SpamFilter, blobStore ports.BlobStore, notificationBus ports.NotificationBus, auth *sasl.Authenticator) *Session {
 	ctx, cancel := context.WithCancel(parentCtx)
+	if auth == nil {
+		auth = sasl.NewAuthenticator(userRepo, "")
+	}
 	return &Session{
 		ctx:             ctx,
 		cancel:          cancel,
@@ -55,13 +81,18 @@ func NewSession(parentCtx context.Context, conn net.Conn, cfg config.IMAPConfig,
 		config:          cfg,
 		logger:          logger,
 		userRepo:        userRepo,
+		auth:            auth,
 		emailRepo:       emailRepo,
+		searchIdx:       searchIdx,
 		emailService:    services.NewEmailService(emailRepo),
+		quotaService:    services.NewQuotaService(userRepo, emailRepo),
 		spamService:     spamService,
 		blobStore:       blobStore,
 		notificationBus: notificationBus,
+		enabled:         make(map[string]bool),
 		reader:          bufio.NewReader(conn),
 		writer:          bufio.NewWriter(conn),
+		isTLS:           tlsutil.IsTLSConn(conn),
 	}
 }
 
@@ -76,7 +107,7 @@ func (s *Session) Serve() {
 
 	// Greeting
 	// RFC 3501 Section 2.2.1
-	s.send("* OK [CAPABILITY IMAP4rev1 STARTTLS AUTH=PLAIN ACL QUOTA IDLE] MailRaven Ready")
+	s.send("* OK [CAPABILITY " + s.capability() + "] MailRaven Ready")
 
 	for {
 		_ = s.conn.SetReadDeadline(time.Now().Add(30 * time.Minute))
@@ -94,9 +125,11 @@ func (s *Session) Serve() {
 		}
 
 		// Parse and Handle
-		cmd, err := ParseCommand(line)
-		if err != nil {
-			s.send("* BAD syntax error")
+		cmd := s.readCommand(line)
+		if cmd == nil {
+			if s.state == StateLogout {
+				return
+			}
 			continue
 		}
 
@@ -108,6 +141,92 @@ func (s *Session) Serve() {
 	}
 }
 
+// readCommand parses a command starting with line and reads the literals among its
+// arguments, each of which becomes an argument of its own. APPEND is returned as soon as
+// its first line is parsed since handleAppend streams the message literals itself. It
+// returns nil when the command was rejected, in which case the session may have ended.
+func (s *Session) readCommand(line string) *Command {
+	var parts []string
+	for {
+		prefix, size, nonSync, ok := parseLiteral(line)
+		if !ok {
+			parts = append(parts, splitArgs(line)...)
+			break
+		}
+		parts = append(parts, splitArgs(prefix)...)
+		if len(parts) >= 2 && strings.EqualFold(parts[1], "APPEND") {
+			parts = append(parts, line[len(prefix):])
+			break
+		}
+
+		if size > maxCommandLiteral {
+			if nonSync {
+				// The data is already on its way and can't be told apart from commands
+				s.send("* BYE Literal too large")
+				s.state = StateLogout
+				return nil
+			}
+			tag := "*"
+			if len(parts) > 0 {
+				tag = parts[0]
+			}
+			s.send(fmt.Sprintf("%s BAD Literal too large", tag))
+			return nil
+		}
+		if !nonSync {
+			s.send("+ Ready for literal data")
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			s.state = StateLogout
+			return nil
+		}
+		parts = append(parts, string(data))
+
+		next, err := s.reader.ReadString('\n')
+		if err != nil {
+			s.state = StateLogout
+			return nil
+		}
+		line = strings.TrimRight(next, "\r\n")
+	}
+
+	if len(parts) < 2 {
+		s.send("* BAD syntax error")
+		return nil
+	}
+	return &Command{
+		Tag:  parts[0],
+		Name: strings.ToUpper(parts[1]),
+		Args: parts[2:],
+	}
+}
+
+// capability returns the capabilities for the current state of the connection. Before
+// authentication they include STARTTLS when it is available, the SASL mechanisms offered
+// to the client, and LOGINDISABLED while plaintext passwords aren't allowed.
+func (s *Session) capability() string {
+	if s.state != StateNotAuthenticated {
+		return capabilities
+	}
+	caps := capabilities
+	if !s.isTLS && s.tlsConfig != nil {
+		caps += " STARTTLS"
+	}
+	for _, mech := range s.auth.Mechanisms(s.plaintextAllowed()) {
+		caps += " AUTH=" + mech
+	}
+	if !s.plaintextAllowed() {
+		caps += " LOGINDISABLED"
+	}
+	return caps
+}
+
+// plaintextAllowed reports whether clients may send passwords in the clear
+func (s *Session) plaintextAllowed() bool {
+	return s.isTLS || s.config.AllowInsecureAuth
+}
+
 func (s *Session) send(msg string) {
 	//nolint:errcheck // Best effort write
 	_, _ = s.writer.WriteString(msg + "\r\n")
@@ -116,16 +235,7 @@ func (s *Session) send(msg string) {
 }
 
 func (s *Session) upgradeToTLS() error {
-	cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
-	if err != nil {
-		return fmt.Errorf("failed to load keypair: %w", err)
-	}
-
-	config := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
-	}
-	tlsConn := tls.Server(s.conn, config)
+	tlsConn := tls.Server(s.conn, s.tlsConfig)
 
 	if err := tlsConn.Handshake(); err != nil {
 		return fmt.Errorf("tls handshake failed: %w", err)
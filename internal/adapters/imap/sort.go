@@ -0,0 +1,326 @@
+package imap
+
+import (
+	"cmp"
+	"fmt"
+	"mime"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RFC 5256 SORT
+
+// sortCriterion is a sort key of a SORT command, compared in the order given
+type sortCriterion struct {
+	key     string // ARRIVAL, CC, DATE, FROM, SIZE, SUBJECT or TO
+	reverse bool
+}
+
+// parseSortCriteria parses the parenthesized sort criteria at the start of tokens and
+// returns the remaining tokens
+func parseSortCriteria(tokens []string) ([]sortCriterion, []string, error) {
+	if len(tokens) == 0 || tokens[0] != "(" {
+		return nil, nil, fmt.Errorf("missing sort criteria")
+	}
+	var criteria []sortCriterion
+	reverse := false
+	for i := 1; i < len(tokens); i++ {
+		tok := strings.ToUpper(tokens[i])
+		switch tok {
+		case ")":
+			if reverse || len(criteria) == 0 {
+				return nil, nil, fmt.Errorf("incomplete sort criteria")
+			}
+			return criteria, tokens[i+1:], nil
+		case "REVERSE":
+			if reverse {
+				return nil, nil, fmt.Errorf("REVERSE must be followed by a sort key")
+			}
+			reverse = true
+		case "ARRIVAL", "CC", "DATE", "FROM", "SIZE", "SUBJECT", "TO":
+			criteria = append(criteria, sortCriterion{key: tok, reverse: reverse})
+			reverse = false
+		default:
+			return nil, nil, fmt.Errorf("unknown sort key %s", tokens[i])
+		}
+	}
+	return nil, nil, fmt.Errorf("unterminated sort criteria")
+}
+
+// parseSearchCharset checks the charset argument SORT and THREAD require before the
+// search criteria and returns the remaining tokens. An unsupported charset yields ok=false.
+func parseSearchCharset(tokens []string) (rest []string, ok bool, err error) {
+	if len(tokens) == 0 {
+		return nil, false, fmt.Errorf("missing charset")
+	}
+	switch strings.ToUpper(tokens[0]) {
+	case "UTF-8", "US-ASCII":
+		return tokens[1:], true, nil
+	}
+	return nil, false, nil
+}
+
+// handleSort SORT (<sort criteria>) <charset> <search criteria>
+func (s *Session) handleSort(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.sort(cmd.Tag, "SORT", cmd.Args, false)
+}
+
+func (s *Session) sort(tag, command string, args []string, byUID bool) {
+	// ACL check: requires 'r' (read) right
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "r"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+
+	criteria, tokens, err := parseSortCriteria(searchTokens(args))
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	tokens, ok, err := parseSearchCharset(tokens)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	if !ok {
+		s.send(fmt.Sprintf("%s NO [BADCHARSET (UTF-8 US-ASCII)] Unsupported charset", tag))
+		return
+	}
+	parser := &searchParser{tokens: tokens, saved: s.searchResult}
+	program, err := parser.parseProgram()
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+
+	matched, err := s.runSearch(program)
+	if err != nil {
+		s.logger.Error("IMAP SORT failed", "error", err)
+		s.send(fmt.Sprintf("%s NO Sort failed", tag))
+		return
+	}
+
+	// The address keys come from the header, which the search may not have needed
+	for _, c := range criteria {
+		if c.key != "CC" && c.key != "FROM" && c.key != "TO" {
+			continue
+		}
+		for _, m := range matched {
+			if m.header != nil {
+				continue
+			}
+			if err := s.readHeader(m); err != nil {
+				s.logger.Warn("IMAP SORT could not read message header", "error", err, "msg_id", m.msg.ID)
+			}
+		}
+		break
+	}
+
+	// The search result is in sequence order, which a stable sort keeps for equal keys
+	keys := make(map[*searchMessage]*sortKeys, len(matched))
+	for _, m := range matched {
+		keys[m] = newSortKeys(m)
+	}
+	slices.SortStableFunc(matched, func(a, b *searchMessage) int {
+		return compareSortKeys(keys[a], keys[b], criteria)
+	})
+
+	var sb strings.Builder
+	sb.WriteString("* SORT")
+	var highestModSeq uint64
+	for _, m := range matched {
+		if byUID {
+			fmt.Fprintf(&sb, " %d", m.msg.UID)
+		} else {
+			fmt.Fprintf(&sb, " %d", m.seq)
+		}
+		highestModSeq = max(highestModSeq, m.msg.ModSeq)
+	}
+	// RFC 7162 Section 3.1.5 applies to SORT as it does to SEARCH
+	if program.usesModSeq() {
+		s.enabled["CONDSTORE"] = true
+		if highestModSeq > 0 {
+			fmt.Fprintf(&sb, " (MODSEQ %d)", highestModSeq)
+		}
+	}
+	s.send(sb.String())
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+// sortKeys holds the values a message is sorted by. Strings are compared with the
+// i;ascii-casemap collation, so they are kept upper-cased.
+type sortKeys struct {
+	arrival time.Time
+	date    time.Time
+	size    int64
+	subject string
+	from    string
+	to      string
+	cc      string
+}
+
+func newSortKeys(m *searchMessage) *sortKeys {
+	subject, _ := baseSubject(m.msg.Subject)
+	return &sortKeys{
+		arrival: m.msg.ReceivedAt,
+		date:    sentDate(m),
+		size:    m.msg.Size,
+		subject: asciiUpper(subject),
+		from:    sortAddress(m, "From"),
+		to:      sortAddress(m, "To"),
+		cc:      sortAddress(m, "Cc"),
+	}
+}
+
+func compareSortKeys(a, b *sortKeys, criteria []sortCriterion) int {
+	for _, c := range criteria {
+		var n int
+		switch c.key {
+		case "ARRIVAL":
+			n = a.arrival.Compare(b.arrival)
+		case "DATE":
+			n = a.date.Compare(b.date)
+		case "SIZE":
+			n = cmp.Compare(a.size, b.size)
+		case "SUBJECT":
+			n = strings.Compare(a.subject, b.subject)
+		case "FROM":
+			n = strings.Compare(a.from, b.from)
+		case "TO":
+			n = strings.Compare(a.to, b.to)
+		case "CC":
+			n = strings.Compare(a.cc, b.cc)
+		}
+		if c.reverse {
+			n = -n
+		}
+		if n != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// sentDate is the Date header of a message, or its internal date if that is unknown
+func sentDate(m *searchMessage) time.Time {
+	if !m.msg.SentAt.IsZero() {
+		return m.msg.SentAt
+	}
+	return m.msg.ReceivedAt
+}
+
+// sortAddress is the local part of the first address in a header field, upper-cased, or
+// the empty string if there is none (RFC 5256 Section 3, addr-mailbox)
+func sortAddress(m *searchMessage, field string) string {
+	value := m.header.Get(field)
+	if value == "" {
+		return ""
+	}
+	addrs, err := mail.ParseAddressList(value)
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	local := addrs[0].Address
+	if i := strings.LastIndexByte(local, '@'); i >= 0 {
+		local = local[:i]
+	}
+	return asciiUpper(local)
+}
+
+// asciiUpper folds a-z to upper case and leaves other characters alone, as the
+// i;ascii-casemap collation does
+func asciiUpper(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
+
+// baseSubject extracts the base subject of a Subject header (RFC 5256 Section 2.1) and
+// reports whether the subject marked a reply or forward
+func baseSubject(subject string) (string, bool) {
+	dec := &mime.WordDecoder{}
+	if decoded, err := dec.DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	// (1) Tabs, line breaks and runs of spaces become single spaces
+	s := strings.Join(strings.Fields(subject), " ")
+	isReply := false
+	for {
+		// (2) Trailing "(fwd)" and whitespace
+		for len(s) >= 5 && strings.EqualFold(s[len(s)-5:], "(fwd)") {
+			s = strings.TrimRight(s[:len(s)-5], " ")
+			isReply = true
+		}
+
+		// (3)-(5) Leading "Re:", "Fw:", "Fwd:", optionally with a blob, and leading blobs
+		// that don't make up the whole remaining subject
+		for {
+			before := s
+			s = strings.TrimLeft(s, " ")
+			if rest, ok := trimSubjectRefwd(s); ok {
+				s = rest
+				isReply = true
+			}
+			if rest, ok := trimSubjectBlob(s); ok && rest != "" {
+				s = rest
+			}
+			if s == before {
+				break
+			}
+		}
+
+		// (6) "[fwd: ...]" wrappers
+		if len(s) >= 6 && strings.EqualFold(s[:5], "[fwd:") && strings.HasSuffix(s, "]") {
+			s = s[5 : len(s)-1]
+			isReply = true
+			continue
+		}
+		return s, isReply
+	}
+}
+
+// trimSubjectRefwd removes a leading subj-refwd: "re", "fw" or "fwd", an optional blob
+// and a colon
+func trimSubjectRefwd(s string) (string, bool) {
+	var n int
+	switch {
+	case len(s) >= 2 && strings.EqualFold(s[:2], "re"):
+		n = 2
+	case len(s) >= 3 && strings.EqualFold(s[:3], "fwd"):
+		n = 3
+	case len(s) >= 2 && strings.EqualFold(s[:2], "fw"):
+		n = 2
+	default:
+		return s, false
+	}
+	rest := strings.TrimLeft(s[n:], " ")
+	if after, ok := trimSubjectBlob(rest); ok {
+		rest = after
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return s, false
+	}
+	return rest[1:], true
+}
+
+// trimSubjectBlob removes a leading subj-blob, a bracketed text without nested brackets,
+// and the whitespace after it
+func trimSubjectBlob(s string) (string, bool) {
+	if !strings.HasPrefix(s, "[") {
+		return s, false
+	}
+	end := strings.IndexAny(s[1:], "[]")
+	if end < 0 || s[1+end] != ']' {
+		return s, false
+	}
+	return strings.TrimLeft(s[end+2:], " "), true
+}
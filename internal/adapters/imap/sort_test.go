@@ -0,0 +1,115 @@
+package imap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBaseSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		base    string
+		isReply bool
+	}{
+		{"Hello world", "Hello world", false},
+		{"  Hello \t  world  ", "Hello world", false},
+		{"Re: Hello", "Hello", true},
+		{"RE: re: Fwd: Hello", "Hello", true},
+		{"Re[2]: Hello", "Hello", true},
+		{"[golang-nuts] Re: Hello", "Hello", true},
+		{"Re: [golang-nuts] Hello", "Hello", true},
+		{"Hello (fwd)", "Hello", true},
+		{"[Fwd: Re: Hello]", "Hello", true},
+		{"[golang-nuts]", "[golang-nuts]", false},
+		{"Reply needed", "Reply needed", false},
+		{"=?UTF-8?Q?Re=3A_Caf=C3=A9?=", "Café", true},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		base, isReply := baseSubject(tt.subject)
+		if base != tt.base || isReply != tt.isReply {
+			t.Errorf("baseSubject(%q) = %q, %v; want %q, %v", tt.subject, base, isReply, tt.base, tt.isReply)
+		}
+	}
+}
+
+func TestParseSortCriteria(t *testing.T) {
+	criteria, rest, err := parseSortCriteria(searchTokens(splitArgs("(REVERSE DATE subject) UTF-8 ALL")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(criteria) != 2 || criteria[0] != (sortCriterion{"DATE", true}) || criteria[1] != (sortCriterion{"SUBJECT", false}) {
+		t.Errorf("got criteria %+v", criteria)
+	}
+	if len(rest) != 2 || rest[0] != "UTF-8" {
+		t.Errorf("got rest %q", rest)
+	}
+
+	for _, spec := range []string{"", "DATE UTF-8 ALL", "() UTF-8 ALL", "(REVERSE) UTF-8 ALL", "(REVERSE REVERSE DATE)", "(DISPLAYFROM)", "(DATE"} {
+		if _, _, err := parseSortCriteria(searchTokens(splitArgs(spec))); err == nil {
+			t.Errorf("parseSortCriteria(%q) expected error, got nil", spec)
+		}
+	}
+}
+
+// threadTestMessages builds messages numbered from 1, a day apart
+func threadTestMessages(specs ...threadMessage) []*threadMessage {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	msgs := make([]*threadMessage, len(specs))
+	for i := range specs {
+		msg := specs[i]
+		msg.num = uint32(i + 1)
+		msg.seq = msg.num
+		if msg.date.IsZero() {
+			msg.date = start.AddDate(0, 0, i)
+		}
+		msgs[i] = &msg
+	}
+	return msgs
+}
+
+func TestOrderedSubjectThreads(t *testing.T) {
+	msgs := threadTestMessages(
+		threadMessage{subject: "B"},
+		threadMessage{subject: "A"},
+		threadMessage{subject: "B"},
+		threadMessage{subject: "A"},
+		threadMessage{subject: "C"},
+		threadMessage{subject: "B"},
+	)
+	if got, want := formatThreads(orderedSubjectThreads(msgs)), "(1 (3)(6))(2 4)(5)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReferenceThreads(t *testing.T) {
+	msgs := threadTestMessages(
+		threadMessage{messageID: "<1@x>", subject: "PLAN"},
+		threadMessage{messageID: "<2@x>", subject: "PLAN", isReply: true, references: []string{"<1@x>"}},
+		threadMessage{messageID: "<3@x>", subject: "PLAN", isReply: true, references: []string{"<1@x>", "<2@x>"}},
+		threadMessage{messageID: "<4@x>", subject: "PLAN", isReply: true, references: []string{"<1@x>"}},
+		// The parent was deleted: its children are merged under a placeholder
+		threadMessage{messageID: "<6@x>", subject: "OTHER", isReply: true, references: []string{"<5@x>"}},
+		threadMessage{messageID: "<7@x>", subject: "OTHER", isReply: true, references: []string{"<5@x>"}},
+		// Unrelated messages with the same subject are grouped
+		threadMessage{messageID: "<8@x>", subject: "LUNCH"},
+		threadMessage{messageID: "<9@x>", subject: "LUNCH"},
+		// A reply by subject only joins the original
+		threadMessage{messageID: "<10@x>", subject: "PLAN", isReply: true},
+		// No Message-ID and no subject
+		threadMessage{},
+	)
+	if got, want := formatThreads(referenceThreads(msgs)), "(1 (2 3)(4)(9))((5)(6))((7)(8))(10)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReferenceThreadsLoop(t *testing.T) {
+	msgs := threadTestMessages(
+		threadMessage{messageID: "<1@x>", subject: "A", references: []string{"<2@x>"}},
+		threadMessage{messageID: "<2@x>", subject: "B", references: []string{"<1@x>"}},
+	)
+	if got, want := formatThreads(referenceThreads(msgs)), "(2 1)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// RFC 3501 Section 6.4.6 STORE, with the RFC 7162 UNCHANGEDSINCE modifier
+
+// handleStore STORE <sequence-set> [(UNCHANGEDSINCE <mod-sequence>)] <mode> <flags>
+func (s *Session) handleStore(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	if len(cmd.Args) < 3 {
+		s.send(fmt.Sprintf("%s BAD Missing STORE args", cmd.Tag))
+		return
+	}
+	s.store(cmd.Tag, "STORE", cmd.Args[0], cmd.Args[1:], false)
+}
+
+// handleUidStore UID STORE <uid-set> [(UNCHANGEDSINCE <mod-sequence>)] <mode> <flags>
+func (s *Session) handleUidStore(tag string, rangeSpec string, args []string) {
+	s.store(tag, "UID STORE", rangeSpec, args, true)
+}
+
+func (s *Session) store(tag, command, setSpec string, args []string, byUID bool) {
+	set, err := s.parseSequenceArg(setSpec, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+
+	var unchangedSince uint64
+	conditional := false
+	if len(args) > 0 && strings.HasPrefix(args[0], "(") {
+		if len(args) < 2 || !strings.EqualFold(strings.TrimPrefix(args[0], "("), "UNCHANGEDSINCE") {
+			s.send(fmt.Sprintf("%s BAD Invalid STORE modifier", tag))
+			return
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(args[1], ")"), 10, 64)
+		if err != nil {
+			s.send(fmt.Sprintf("%s BAD Invalid UNCHANGEDSINCE value", tag))
+			return
+		}
+		unchangedSince, conditional = n, true
+		args = args[2:]
+		s.enabled["CONDSTORE"] = true
+	}
+
+	if len(args) < 2 {
+		s.send(fmt.Sprintf("%s BAD Missing STORE args", tag))
+		return
+	}
+	mode := strings.ToUpper(args[0]) // +FLAGS, -FLAGS, FLAGS, optionally .SILENT
+	silent := strings.HasSuffix(mode, ".SILENT")
+	mode = strings.TrimSuffix(mode, ".SILENT")
+	switch mode {
+	case "FLAGS", "+FLAGS", "-FLAGS":
+	default:
+		s.send(fmt.Sprintf("%s BAD Unknown STORE mode %s", tag, args[0]))
+		return
+	}
+	flagsStr := strings.Join(args[1:], " ")
+	// Strip parens
+	flagsStr = strings.Trim(flagsStr, "()")
+	flags := strings.Fields(flagsStr)
+
+	if s.readOnly {
+		s.send(fmt.Sprintf("%s NO [READ-ONLY] Mailbox is read-only", tag))
+		return
+	}
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, storeRights(mode, flags)); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+
+	msgs, err := s.selectMessages(set, byUID)
+	if err != nil {
+		s.send(fmt.Sprintf("%s NO DB Error", tag))
+		return
+	}
+
+	var modified []uint32
+	failed := false
+	for _, msg := range msgs {
+		seq := s.seqNum(msg.UID)
+		if seq == 0 {
+			continue
+		}
+		// RFC 7162 Section 3.1.3: messages changed since UNCHANGEDSINCE are left alone
+		if conditional && msg.ModSeq > unchangedSince {
+			if byUID {
+				modified = append(modified, msg.UID)
+			} else {
+				modified = append(modified, uint32(seq))
+			}
+			continue
+		}
+
+		if err := s.applyFlags(msg, mode, flags); err != nil {
+			s.logger.Error("IMAP STORE failed", "error", err, "msg_id", msg.ID)
+			failed = true
+			continue
+		}
+		updated, err := s.emailRepo.FindByID(s.ctx, msg.ID)
+		if err != nil {
+			continue
+		}
+		// The change is the session's own and isn't reported again by NOOP or IDLE
+		s.flagsSent[updated.UID] = updated.ModSeq
+		if silent && !s.enabled["CONDSTORE"] {
+			continue
+		}
+		var attrs []string
+		if byUID {
+			attrs = append(attrs, fmt.Sprintf("UID %d", updated.UID))
+		}
+		if !silent {
+			attrs = append(attrs, fmt.Sprintf("FLAGS (%s)", messageFlags(updated)))
+		}
+		// With CONDSTORE the new mod-sequence is reported even for .SILENT
+		if s.enabled["CONDSTORE"] {
+			attrs = append(attrs, fmt.Sprintf("MODSEQ (%d)", updated.ModSeq))
+		}
+		s.send(fmt.Sprintf("* %d FETCH (%s)", seq, strings.Join(attrs, " ")))
+	}
+
+	if failed {
+		s.send(fmt.Sprintf("%s NO %s failed", tag, command))
+		return
+	}
+	if len(modified) > 0 {
+		s.send(fmt.Sprintf("%s OK [MODIFIED %s] Conditional %s failed", tag, formatSequenceSet(modified), command))
+		return
+	}
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+// storeRights returns the rights a STORE needs (RFC 4314 Section 4): s for \Seen, t for
+// \Deleted and w for other flags. Replacing the flags may change any of them.
+func storeRights(mode string, flags []string) string {
+	if mode == "FLAGS" {
+		return "stw"
+	}
+	rights := ""
+	for _, flag := range flags {
+		right := "w"
+		switch {
+		case strings.EqualFold(flag, "\\Seen"):
+			right = "s"
+		case strings.EqualFold(flag, "\\Deleted"):
+			right = "t"
+		}
+		if !strings.Contains(rights, right) {
+			rights += right
+		}
+	}
+	return rights
+}
+
+// applyFlags changes the flags of a message. \Seen is mirrored to the read state,
+// which the rest of the system uses.
+func (s *Session) applyFlags(msg *domain.Message, mode string, flags []string) error {
+	var err error
+	switch mode {
+	case "+FLAGS":
+		err = s.emailRepo.AddFlags(s.ctx, msg.ID, flags...)
+	case "-FLAGS":
+		err = s.emailRepo.RemoveFlags(s.ctx, msg.ID, flags...)
+	default:
+		err = s.emailRepo.SetFlags(s.ctx, msg.ID, flags...)
+	}
+	if err != nil {
+		return err
+	}
+
+	hasSeen := false
+	for _, flag := range flags {
+		if strings.EqualFold(flag, "\\Seen") {
+			hasSeen = true
+		}
+	}
+	read := msg.ReadState
+	switch mode {
+	case "+FLAGS":
+		read = read || hasSeen
+	case "-FLAGS":
+		read = read && !hasSeen
+	default:
+		read = hasSeen
+	}
+	if read != msg.ReadState {
+		return s.emailRepo.UpdateReadState(s.ctx, msg.ID, read)
+	}
+	return nil
+}
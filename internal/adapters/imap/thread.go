@@ -0,0 +1,381 @@
+package imap
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RFC 5256 THREAD with the ORDEREDSUBJECT and REFERENCES algorithms
+
+// threadMessage holds what the threading algorithms need to know of a message
+type threadMessage struct {
+	num        uint32    // Sequence number or UID, as reported
+	seq        uint32    // Breaks ties between equal dates
+	date       time.Time // Sent date, or internal date if unknown
+	subject    string    // Base subject, upper-cased for comparison
+	isReply    bool      // The subject marked a reply or forward
+	messageID  string
+	references []string // Message-IDs of the ancestors, oldest first
+}
+
+// threadNode is a message in a thread tree; a node without a message is a placeholder
+// for a parent that isn't in the result
+type threadNode struct {
+	msg      *threadMessage
+	parent   *threadNode
+	children []*threadNode
+}
+
+// handleThread THREAD <algorithm> <charset> <search criteria>
+func (s *Session) handleThread(cmd *Command) {
+	if s.state != StateSelected {
+		s.send(fmt.Sprintf("%s NO Select mailbox first", cmd.Tag))
+		return
+	}
+	s.thread(cmd.Tag, "THREAD", cmd.Args, false)
+}
+
+func (s *Session) thread(tag, command string, args []string, byUID bool) {
+	// ACL check: requires 'r' (read) right
+	if err := s.emailService.CheckAccess(s.ctx, s.selectedMailbox.UserID, s.selectedMailbox.Name, s.user.Email, "r"); err != nil {
+		s.send(fmt.Sprintf("%s NO [PERMISSION DENIED] Access denied", tag))
+		return
+	}
+
+	tokens := searchTokens(args)
+	if len(tokens) == 0 {
+		s.send(fmt.Sprintf("%s BAD Missing thread algorithm", tag))
+		return
+	}
+	var algorithm func([]*threadMessage) []*threadNode
+	switch strings.ToUpper(tokens[0]) {
+	case "ORDEREDSUBJECT":
+		algorithm = orderedSubjectThreads
+	case "REFERENCES":
+		algorithm = referenceThreads
+	default:
+		s.send(fmt.Sprintf("%s BAD Unsupported thread algorithm %s", tag, tokens[0]))
+		return
+	}
+	tokens, ok, err := parseSearchCharset(tokens[1:])
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+	if !ok {
+		s.send(fmt.Sprintf("%s NO [BADCHARSET (UTF-8 US-ASCII)] Unsupported charset", tag))
+		return
+	}
+	parser := &searchParser{tokens: tokens, saved: s.searchResult}
+	program, err := parser.parseProgram()
+	if err != nil {
+		s.send(fmt.Sprintf("%s BAD %v", tag, err))
+		return
+	}
+
+	matched, err := s.runSearch(program)
+	if err != nil {
+		s.logger.Error("IMAP THREAD failed", "error", err)
+		s.send(fmt.Sprintf("%s NO Thread failed", tag))
+		return
+	}
+
+	msgs := make([]*threadMessage, 0, len(matched))
+	for _, m := range matched {
+		subject, isReply := baseSubject(m.msg.Subject)
+		tm := &threadMessage{
+			num:        m.seq,
+			seq:        m.seq,
+			date:       sentDate(m),
+			subject:    asciiUpper(subject),
+			isReply:    isReply,
+			messageID:  m.msg.MessageID,
+			references: messageIDs(m.msg.References),
+		}
+		// RFC 5256 Section 3: without a usable References header the first
+		// Message-ID of In-Reply-To is the parent
+		if len(tm.references) == 0 {
+			if ids := messageIDs(m.msg.InReplyTo); len(ids) > 0 {
+				tm.references = ids[:1]
+			}
+		}
+		if byUID {
+			tm.num = m.msg.UID
+		}
+		msgs = append(msgs, tm)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("* THREAD")
+	if threads := algorithm(msgs); len(threads) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(formatThreads(threads))
+	}
+	s.send(sb.String())
+	s.send(fmt.Sprintf("%s OK %s completed", tag, command))
+}
+
+var messageIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// messageIDs returns the Message-IDs of a References or In-Reply-To header value
+func messageIDs(value string) []string {
+	return messageIDPattern.FindAllString(value, -1)
+}
+
+// orderedSubjectThreads groups messages by base subject. The first message of a group by
+// sent date is the parent of all the others (RFC 5256 Section 3, ORDEREDSUBJECT).
+func orderedSubjectThreads(msgs []*threadMessage) []*threadNode {
+	sorted := slices.Clone(msgs)
+	slices.SortStableFunc(sorted, func(a, b *threadMessage) int {
+		if n := strings.Compare(a.subject, b.subject); n != 0 {
+			return n
+		}
+		return compareThreadMessages(a, b)
+	})
+
+	var threads []*threadNode
+	var current *threadNode
+	for _, msg := range sorted {
+		if current != nil && current.msg.subject == msg.subject {
+			current.children = append(current.children, &threadNode{msg: msg, parent: current})
+			continue
+		}
+		current = &threadNode{msg: msg}
+		threads = append(threads, current)
+	}
+	sortThreadNodes(threads)
+	return threads
+}
+
+// referenceThreads links messages by their References and In-Reply-To headers, then merges
+// threads with the same base subject (RFC 5256 Section 3, REFERENCES)
+func referenceThreads(msgs []*threadMessage) []*threadNode {
+	// (1) A node for every Message-ID, linked to the referenced ones
+	byID := make(map[string]*threadNode)
+	var all []*threadNode
+	nodeFor := func(id string) *threadNode {
+		if n, ok := byID[id]; ok {
+			return n
+		}
+		n := &threadNode{}
+		byID[id] = n
+		all = append(all, n)
+		return n
+	}
+	for _, msg := range msgs {
+		// A message without a Message-ID, or with one seen before, gets a node of its own
+		var node *threadNode
+		if n, ok := byID[msg.messageID]; msg.messageID != "" && (!ok || n.msg == nil) {
+			node = nodeFor(msg.messageID)
+		} else {
+			node = &threadNode{}
+			all = append(all, node)
+		}
+		node.msg = msg
+
+		// (1A) Each reference is the parent of the next, unless it already has one
+		var prev *threadNode
+		for _, id := range msg.references {
+			ref := nodeFor(id)
+			if prev != nil && ref.parent == nil && !ref.isAncestorOf(prev) {
+				ref.setParent(prev)
+			}
+			prev = ref
+		}
+
+		// (1B) The last reference is the parent of the message, replacing any parent an
+		// earlier, possibly truncated, References header gave it
+		if prev != nil && !node.isAncestorOf(prev) {
+			node.setParent(prev)
+		} else if prev == nil && node.parent != nil {
+			node.setParent(nil)
+		}
+	}
+
+	// (2) The nodes without a parent are the roots
+	var roots []*threadNode
+	for _, n := range all {
+		if n.parent == nil {
+			roots = append(roots, n)
+		}
+	}
+
+	// (3) Placeholders are dropped or replaced by their children, and (4) siblings sorted
+	roots = pruneThreadNodes(roots, true)
+	sortThreadNodes(roots)
+
+	// (5) Threads with the same base subject are merged; a placeholder has the subject of
+	// its first child
+	isReply := func(n *threadNode) bool {
+		return n.msg != nil && n.msg.isReply
+	}
+	bySubject := make(map[string]*threadNode)
+	for _, n := range roots {
+		subject := n.first().subject
+		if subject == "" {
+			continue
+		}
+		// (5A) A placeholder, or else a message that isn't a reply, represents the subject
+		old := bySubject[subject]
+		if old == nil || (n.msg == nil && old.msg != nil) || (isReply(old) && !isReply(n)) {
+			bySubject[subject] = n
+		}
+	}
+	merged := make(map[*threadNode]bool)
+	var added []*threadNode
+	for _, n := range roots {
+		subject := n.first().subject
+		other := bySubject[subject]
+		if subject == "" || merged[n] || other == n {
+			continue
+		}
+		// (5B)
+		switch {
+		case n.msg == nil && other.msg == nil:
+			for _, c := range n.children {
+				c.parent = other
+			}
+			other.children = append(other.children, n.children...)
+			merged[n] = true
+		case other.msg == nil:
+			other.adopt(n)
+			merged[n] = true
+		case n.msg == nil:
+			n.adopt(other)
+			merged[other] = true
+			bySubject[subject] = n
+		case !isReply(other) && isReply(n):
+			other.adopt(n)
+			merged[n] = true
+		default:
+			placeholder := &threadNode{}
+			placeholder.adopt(other)
+			placeholder.adopt(n)
+			merged[other], merged[n] = true, true
+			bySubject[subject] = placeholder
+			added = append(added, placeholder)
+		}
+	}
+	var threads []*threadNode
+	for _, n := range append(roots, added...) {
+		if !merged[n] {
+			threads = append(threads, n)
+		}
+	}
+
+	// (6) Siblings, and (7) the threads themselves, are sorted again
+	sortThreadNodes(threads)
+	return threads
+}
+
+// isAncestorOf reports whether n is m or one of its ancestors
+func (n *threadNode) isAncestorOf(m *threadNode) bool {
+	for ; m != nil; m = m.parent {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// setParent moves n from its current parent, if any, to parent
+func (n *threadNode) setParent(parent *threadNode) {
+	if n.parent != nil {
+		n.parent.children = slices.DeleteFunc(n.parent.children, func(c *threadNode) bool { return c == n })
+	}
+	n.parent = parent
+	if parent != nil {
+		parent.children = append(parent.children, n)
+	}
+}
+
+// adopt makes the root child a child of n
+func (n *threadNode) adopt(child *threadNode) {
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+// pruneThreadNodes removes placeholders without children and replaces the others by their
+// children, except for a root placeholder with several children
+func pruneThreadNodes(nodes []*threadNode, root bool) []*threadNode {
+	var pruned []*threadNode
+	for _, n := range nodes {
+		n.children = pruneThreadNodes(n.children, false)
+		if n.msg == nil && (len(n.children) == 0 || !root || len(n.children) == 1) {
+			for _, c := range n.children {
+				c.parent = n.parent
+			}
+			pruned = append(pruned, n.children...)
+			continue
+		}
+		pruned = append(pruned, n)
+	}
+	return pruned
+}
+
+// sortThreadNodes orders siblings by sent date, recursively. A placeholder sorts as its
+// first child.
+func sortThreadNodes(nodes []*threadNode) {
+	for _, n := range nodes {
+		sortThreadNodes(n.children)
+	}
+	slices.SortStableFunc(nodes, func(a, b *threadNode) int {
+		return compareThreadMessages(a.first(), b.first())
+	})
+}
+
+// first returns the message a node sorts by
+func (n *threadNode) first() *threadMessage {
+	for n.msg == nil {
+		n = n.children[0]
+	}
+	return n.msg
+}
+
+func compareThreadMessages(a, b *threadMessage) int {
+	if n := a.date.Compare(b.date); n != 0 {
+		return n
+	}
+	switch {
+	case a.seq < b.seq:
+		return -1
+	case a.seq > b.seq:
+		return 1
+	}
+	return 0
+}
+
+// formatThreads formats thread trees as the thread-list of a THREAD response
+func formatThreads(threads []*threadNode) string {
+	var sb strings.Builder
+	for _, n := range threads {
+		sb.WriteString("(")
+		writeThreadNode(&sb, n)
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+// writeThreadNode writes a message followed by its descendants: a single child follows
+// its parent, several children each form a parenthesized list
+func writeThreadNode(sb *strings.Builder, n *threadNode) {
+	if n.msg != nil {
+		fmt.Fprintf(sb, "%d", n.msg.num)
+	}
+	switch len(n.children) {
+	case 0:
+	case 1:
+		if n.msg != nil {
+			sb.WriteString(" ")
+		}
+		writeThreadNode(sb, n.children[0])
+	default:
+		if n.msg != nil {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(formatThreads(n.children))
+	}
+}
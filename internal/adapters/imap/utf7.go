@@ -0,0 +1,123 @@
+package imap
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Mailbox names are stored in UTF-8. Clients that haven't enabled UTF8=ACCEPT (RFC 6855)
+// see them in modified UTF-7 (RFC 3501 Section 5.1.3).
+
+// mailboxUTF7Encoding is the modified base64 of modified UTF-7, with "," in place of "/"
+var mailboxUTF7Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,").WithPadding(base64.NoPadding)
+
+var errInvalidUTF7 = errors.New("invalid modified UTF-7")
+
+// encodeMailboxUTF7 converts a UTF-8 mailbox name to modified UTF-7
+func encodeMailboxUTF7(name string) string {
+	var sb strings.Builder
+	var run []rune
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		units := utf16.Encode(run)
+		buf := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			buf = append(buf, byte(u>>8), byte(u))
+		}
+		sb.WriteByte('&')
+		sb.WriteString(mailboxUTF7Encoding.EncodeToString(buf))
+		sb.WriteByte('-')
+		run = run[:0]
+	}
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e {
+			run = append(run, r)
+			continue
+		}
+		flush()
+		if r == '&' {
+			sb.WriteString("&-")
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	flush()
+	return sb.String()
+}
+
+// decodeMailboxUTF7 converts a modified UTF-7 mailbox name to UTF-8
+func decodeMailboxUTF7(name string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c > 0x7e {
+			return "", errInvalidUTF7
+		}
+		if c != '&' {
+			sb.WriteByte(c)
+			continue
+		}
+		end := strings.IndexByte(name[i+1:], '-')
+		if end < 0 {
+			return "", errInvalidUTF7
+		}
+		encoded := name[i+1 : i+1+end]
+		i += end + 1
+		if encoded == "" {
+			sb.WriteByte('&')
+			continue
+		}
+		buf, err := mailboxUTF7Encoding.DecodeString(encoded)
+		if err != nil || len(buf)%2 != 0 {
+			return "", errInvalidUTF7
+		}
+		units := make([]uint16, len(buf)/2)
+		for j := range units {
+			units[j] = uint16(buf[2*j])<<8 | uint16(buf[2*j+1])
+		}
+		for _, r := range utf16.Decode(units) {
+			// Printable ASCII must represent itself, and surrogates must come in pairs
+			if (r >= 0x20 && r <= 0x7e) || r == utf8.RuneError {
+				return "", errInvalidUTF7
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// decodeMailboxName returns the UTF-8 form of a mailbox name or LIST pattern sent by the
+// client. A name that isn't valid modified UTF-7 is taken as it is, since some clients
+// send UTF-8 without enabling UTF8=ACCEPT.
+func (s *Session) decodeMailboxName(arg string) string {
+	if s.enabled["UTF8=ACCEPT"] {
+		return arg
+	}
+	if name, err := decodeMailboxUTF7(arg); err == nil {
+		return name
+	}
+	return arg
+}
+
+// mailboxArg returns the stored form of a mailbox name argument
+func (s *Session) mailboxArg(arg string) string {
+	return canonicalMailbox(s.decodeMailboxName(arg))
+}
+
+// quoteMailbox formats a mailbox name for a response, in modified UTF-7 unless the client
+// enabled UTF8=ACCEPT
+func (s *Session) quoteMailbox(name string) string {
+	if !s.enabled["UTF8=ACCEPT"] {
+		return quoteString(encodeMailboxUTF7(name))
+	}
+	// RFC 6855 Section 3: UTF-8 is allowed in quoted strings
+	if utf8.ValidString(name) && !strings.ContainsAny(name, "\r\n") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return quoteString(name)
+}
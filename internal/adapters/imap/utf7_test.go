@@ -0,0 +1,30 @@
+package imap
+
+import "testing"
+
+func TestMailboxUTF7(t *testing.T) {
+	tests := []struct {
+		name, encoded string
+	}{
+		{"INBOX", "INBOX"},
+		{"été", "&AOk-t&AOk-"},
+		{"Tom & Jerry", "Tom &- Jerry"},
+		{"日本語/メモ", "&ZeVnLIqe-/&MOEw4g-"},
+		{"~peter/mail/台北/日本語", "~peter/mail/&U,BTFw-/&ZeVnLIqe-"},
+		{"😀", "&2D3eAA-"},
+	}
+	for _, tt := range tests {
+		if got := encodeMailboxUTF7(tt.name); got != tt.encoded {
+			t.Errorf("encodeMailboxUTF7(%q) = %q, want %q", tt.name, got, tt.encoded)
+		}
+		if got, err := decodeMailboxUTF7(tt.encoded); err != nil || got != tt.name {
+			t.Errorf("decodeMailboxUTF7(%q) = %q, %v; want %q", tt.encoded, got, err, tt.name)
+		}
+	}
+
+	for _, encoded := range []string{"&AOk", "&AGE-", "&2D0-", "été", "&A-"} {
+		if _, err := decodeMailboxUTF7(encoded); err == nil {
+			t.Errorf("decodeMailboxUTF7(%q) expected error, got nil", encoded)
+		}
+	}
+}
@@ -3,22 +3,26 @@ package managesieve
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 )
 
 // Server represents the ManageSieve TCP server
 type Server struct {
-	addr      string
-	tlsConfig *tls.Config
-	repo      ports.ScriptRepository
-	userRepo  ports.UserRepository
-	logger    *observability.Logger
-	listener  net.Listener
-	shutdown  chan struct{}
+	addr        string
+	tlsConfig   *tls.Config
+	implicitTLS bool // TLS from the first byte instead of STARTTLS
+	repo        ports.ScriptRepository
+	userRepo    ports.UserRepository
+	auth        *sasl.Authenticator
+	logger      *observability.Logger
+	listener    net.Listener
+	shutdown    chan struct{}
 }
 
 // NewServer creates a new ManageSieve server
@@ -27,6 +31,7 @@ func NewServer(
 	tlsConfig *tls.Config,
 	repo ports.ScriptRepository,
 	userRepo ports.UserRepository,
+	auth *sasl.Authenticator,
 	logger *observability.Logger,
 ) *Server {
 	return &Server{
@@ -34,19 +39,32 @@ func NewServer(
 		tlsConfig: tlsConfig,
 		repo:      repo,
 		userRepo:  userRepo,
+		auth:      auth,
 		logger:    logger,
 		shutdown:  make(chan struct{}),
 	}
 }
 
+// SetImplicitTLS makes the server speak TLS from the first byte instead of offering
+// STARTTLS. Must be called before Start.
+func (s *Server) SetImplicitTLS(implicitTLS bool) {
+	s.implicitTLS = implicitTLS
+}
+
 // Start starts the TCP listener
 func (s *Server) Start() error {
+	if s.implicitTLS && s.tlsConfig == nil {
+		return fmt.Errorf("implicit TLS on %s requires a TLS configuration", s.addr)
+	}
 	l, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return err
 	}
+	if s.implicitTLS {
+		l = tls.NewListener(l, s.tlsConfig)
+	}
 	s.listener = l
-	s.logger.Info("ManageSieve server started", "addr", s.addr)
+	s.logger.Info("ManageSieve server started", "addr", s.addr, "implicit_tls", s.implicitTLS)
 
 	go s.serve()
 	return nil
@@ -69,6 +87,14 @@ func (s *Server) serve() {
 	}
 }
 
+// Addr returns the listener address
+func (s *Server) Addr() net.Addr {
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return nil
+}
+
 // Stop stops the server
 func (s *Server) Stop(ctx context.Context) error {
 	close(s.shutdown)
@@ -79,6 +105,6 @@ func (s *Server) Stop(ctx context.Context) error {
 }
 
 func (s *Server) handleConn(c net.Conn) {
-	session := NewSession(context.Background(), c, s.repo, s.userRepo, s.logger, s.tlsConfig)
+	session := NewSession(context.Background(), c, s.repo, s.userRepo, s.logger, s.tlsConfig, s.auth)
 	session.Serve()
 }
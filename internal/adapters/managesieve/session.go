@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/tlsutil"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain/sieve"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
@@ -24,8 +26,10 @@ type Session struct {
 	tokenizer *Tokenizer
 	repo      ports.ScriptRepository
 	userRepo  ports.UserRepository
+	auth      *sasl.Authenticator
 	logger    *observability.Logger
 	tlsConfig *tls.Config
+	isTLS     bool
 
 	state State
 	user  string // Authenticated user email
@@ -39,8 +43,11 @@ const (
 	StateLogout
 )
 
-func NewSession(parentCtx context.Context, conn net.Conn, repo ports.ScriptRepository, userRepo ports.UserRepository, logger *observability.Logger, tlsConfig *tls.Config) *Session {
+func NewSession(parentCtx context.Context, conn net.Conn, repo ports.ScriptRepository, userRepo ports.UserRepository, logger *observability.Logger, tlsConfig *tls.Config, auth *sasl.Authenticator) *Session {
 	ctx, cancel := context.WithCancel(parentCtx)
+	if auth == nil {
+		auth = sasl.NewAuthenticator(userRepo, "")
+	}
 	return &Session{
 		ctx:       ctx,
 		cancel:    cancel,
@@ -49,9 +56,11 @@ func NewSession(parentCtx context.Context, conn net.Conn, repo ports.ScriptRepos
 		writer:    bufio.NewWriter(conn),
 		repo:      repo,
 		userRepo:  userRepo,
+		auth:      auth,
 		logger:    logger,
 		tlsConfig: tlsConfig,
 		state:     StateUnauth,
+		isTLS:     tlsutil.IsTLSConn(conn),
 	}
 }
 
@@ -64,6 +73,10 @@ func (s *Session) Serve() {
 	s.flush()
 
 	for {
+		if err := s.tokenizer.SkipLineEnd(); err != nil {
+			s.logger.Debug("managesieve connection closed", "reason", err)
+			return
+		}
 		cmd, err := s.tokenizer.ReadWord()
 		if err != nil {
 			s.logger.Debug("managesieve connection closed", "reason", err)
@@ -186,80 +199,96 @@ func (s *Session) flush() {
 func (s *Session) sendCapabilities() {
 	// Ref: RFC 5804
 	s.printf("\"IMPLEMENTATION\" \"MailRaven ManageSieve\"\r\n")
-	s.printf("\"SASL\" \"PLAIN\"\r\n")
+	s.printf("\"SASL\" \"%s\"\r\n", strings.Join(s.auth.Mechanisms(s.plaintextAllowed()), " "))
 	s.printf("\"SIEVE\" \"fileinto vacation\"\r\n") // Report extensions supported by engine
-	if s.tlsConfig != nil {
+	if s.tlsConfig != nil && !s.isTLS {
 		s.printf("\"STARTTLS\"\r\n")
 	}
 	s.printf("OK\r\n")
 }
 
+// handleAuthenticate runs a SASL exchange (RFC 5804 Section 2.1). Challenges and client
+// responses are base64 strings; the client aborts with "*".
 func (s *Session) handleAuthenticate() {
 	mech, err := s.tokenizer.ReadWord()
 	if err != nil {
 		s.printf("NO \"Missing mechanism\"\r\n")
 		return
 	}
-	mech = strings.ToUpper(strings.Trim(mech, "\""))
-	if mech != "PLAIN" {
-		s.printf("NO \"Unsupported mechanism\"\r\n")
-		return
-	}
+	mech = strings.ToUpper(mech)
 
-	// Optional initial response
-	resp, _ := s.tokenizer.ReadWord() //nolint:errcheck
-	if resp == "" {
-		// Send challenge
-		s.printf("+\r\n")
-		s.flush()
-		// Read line
-		var err error
-		resp, err = s.reader.ReadString('\n')
+	var response []byte
+	if eol, err := s.tokenizer.AtLineEnd(); err == nil && !eol {
+		ir, err := s.tokenizer.ReadWord()
 		if err != nil {
+			s.printf("NO \"Invalid initial response\"\r\n")
+			return
+		}
+		if response, err = base64.StdEncoding.DecodeString(ir); err != nil {
+			s.printf("NO \"Invalid base64\"\r\n")
 			return
 		}
-		resp = strings.TrimSpace(resp)
+		if response == nil {
+			response = []byte{}
+		}
 	}
 
-	// Decode base64
-	// PLAIN: authorization\0authentication\0password
-	// Remove quotes if present? RFC says it is a string, handleQuoted handles quotes.
-	// But ReadString('\n') might include quotes if sent as "quoted".
-	// Usually client sends literal payload for auth? RFC 4616.
-	// ManageSieve uses a string argument for initial response.
-
-	// If resp starts with ", unquote it.
-	resp = strings.Trim(resp, "\"")
-
-	data, err := base64.StdEncoding.DecodeString(resp)
-	if err != nil {
-		s.printf("NO \"Invalid base64\"\r\n")
+	if s.state != StateUnauth {
+		s.printf("NO \"Already authenticated\"\r\n")
 		return
 	}
 
-	parts := strings.Split(string(data), "\x00")
-	if len(parts) < 3 { // authn\0authz\0pass or \0email\0pass
-		s.printf("NO \"Invalid PLAIN format\"\r\n")
+	server, err := s.auth.NewServer(s.ctx, mech, s.plaintextAllowed())
+	if err != nil {
+		s.printf("NO \"Unsupported mechanism\"\r\n")
 		return
 	}
 
-	// authz := parts[0]
-	email := parts[1]
-	if email == "" {
-		email = parts[0] // if authz is used as email? Usually parts[1] is authn id.
-	}
-	password := parts[2]
+	for {
+		challenge, done, err := server.Next(response)
+		if err != nil {
+			s.logger.Warn("ManageSieve authentication failed", "mechanism", mech, "error", err)
+			s.printf("NO \"Authentication failed\"\r\n")
+			return
+		}
+		if done {
+			s.user = server.User().Email
+			s.state = StateAuth
+			if len(challenge) > 0 {
+				s.printf("OK (SASL \"%s\") \"Authenticated\"\r\n", base64.StdEncoding.EncodeToString(challenge))
+			} else {
+				s.printf("OK \"Authenticated\"\r\n")
+			}
+			return
+		}
 
-	// Validate with repo
-	user, err := s.userRepo.Authenticate(s.ctx, email, password)
-	if err != nil || user == nil {
-		s.printf("NO \"Authentication failed\"\r\n")
-		return
+		s.printf("\"%s\"\r\n", base64.StdEncoding.EncodeToString(challenge))
+		s.flush()
+		if err := s.tokenizer.SkipLineEnd(); err != nil {
+			return
+		}
+		line, err := s.tokenizer.ReadWord()
+		if err != nil {
+			return
+		}
+		if line == "*" {
+			s.printf("NO \"Authentication aborted\"\r\n")
+			return
+		}
+		if response, err = base64.StdEncoding.DecodeString(line); err != nil {
+			s.printf("NO \"Invalid base64\"\r\n")
+			return
+		}
+		if response == nil {
+			response = []byte{}
+		}
 	}
+}
 
-	s.user = email
-	s.state = StateAuth
-	s.printf("OK \"Authenticated\"\r\n")
+// plaintextAllowed reports whether mechanisms revealing the password may be used: on TLS
+// connections, or when the server has no TLS to offer
+func (s *Session) plaintextAllowed() bool {
+	return s.isTLS || s.tlsConfig == nil
 }
 
 func (s *Session) handlePutScript() {
@@ -375,6 +404,10 @@ func (s *Session) handleSetActive() {
 }
 
 func (s *Session) handleStartTLS() {
+	if s.isTLS {
+		s.printf("NO \"TLS already active\"\r\n")
+		return
+	}
 	if s.tlsConfig == nil {
 		s.printf("NO \"TLS not configured\"\r\n")
 		return
@@ -390,6 +423,7 @@ func (s *Session) handleStartTLS() {
 	}
 
 	s.conn = tlsConn
+	s.isTLS = true
 	s.reader = bufio.NewReader(s.conn)
 	s.writer = bufio.NewWriter(s.conn)
 	s.tokenizer = NewTokenizer(s.reader)
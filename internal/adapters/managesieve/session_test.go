@@ -3,11 +3,15 @@ package managesieve
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
+	"net"
 	"strings"
 	"testing"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain" // needed for User
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain/sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -63,12 +67,23 @@ func (m *MockUserRepo) UpdateLastLogin(ctx context.Context, email string) error
 func (m *MockUserRepo) List(ctx context.Context, limit, offset int) ([]*domain.User, error) {
 	return nil, nil
 }
-func (m *MockUserRepo) Delete(ctx context.Context, email string) error            { return nil }
-func (m *MockUserRepo) UpdatePassword(ctx context.Context, email, h string) error { return nil }
+func (m *MockUserRepo) Delete(ctx context.Context, email string) error { return nil }
+func (m *MockUserRepo) UpdatePassword(ctx context.Context, email, h string, sha1, sha256 *domain.SCRAMCredentials) error {
+	return nil
+}
 func (m *MockUserRepo) UpdateRole(ctx context.Context, email string, role domain.Role) error {
 	return nil
 }
 func (m *MockUserRepo) Count(ctx context.Context) (map[string]int64, error) { return nil, nil }
+func (m *MockUserRepo) UpdateQuota(ctx context.Context, email string, bytes int64) error {
+	return nil
+}
+func (m *MockUserRepo) IncrementStorageUsed(ctx context.Context, email string, delta int64) error {
+	return nil
+}
+func (m *MockUserRepo) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	return nil
+}
 
 func TestTokenizer(t *testing.T) {
 	input := "\"test\" {4}\r\nabcd active"
@@ -87,3 +102,59 @@ func TestTokenizer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "active", w3)
 }
+
+// readResponse reads lines up to and including the final OK/NO/BYE response
+func readResponse(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return lines
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "OK") || strings.HasPrefix(line, "NO") || strings.HasPrefix(line, "BYE") {
+			return lines
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	userRepo := new(MockUserRepo)
+	userRepo.On("Authenticate", mock.Anything, "alice@example.com", "secret").Return(&domain.User{Email: "alice@example.com"}, nil)
+	userRepo.On("Authenticate", mock.Anything, "alice@example.com", "wrong").Return(nil, ports.ErrInvalidCredentials)
+	repo := new(MockRepo)
+	repo.On("List", mock.Anything, "alice@example.com").Return([]sieve.SieveScript{{Name: "vacation", IsActive: true}}, nil)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	session := NewSession(context.Background(), server, repo, userRepo, observability.NewLogger("test", "v0.0.0"), nil, nil)
+	go session.Serve()
+
+	r := bufio.NewReader(client)
+	assert.Contains(t, readResponse(t, r), `"SASL" "SCRAM-SHA-256 SCRAM-SHA-1 PLAIN LOGIN"`)
+
+	send := func(line string) {
+		_, err := client.Write([]byte(line + "\r\n"))
+		assert.NoError(t, err)
+	}
+	plain := func(password string) string {
+		return base64.StdEncoding.EncodeToString([]byte("\x00alice@example.com\x00" + password))
+	}
+
+	send(`AUTHENTICATE "PLAIN" "` + plain("wrong") + `"`)
+	assert.Equal(t, []string{`NO "Authentication failed"`}, readResponse(t, r))
+
+	// Without an initial response the client answers an empty challenge
+	send(`AUTHENTICATE "PLAIN"`)
+	challenge, err := r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "\"\"\r\n", challenge)
+	send(`"` + plain("secret") + `"`)
+	assert.Equal(t, []string{`OK "Authenticated"`}, readResponse(t, r))
+
+	send("LISTSCRIPTS")
+	assert.Equal(t, []string{`SCRIPT "vacation" ACTIVE`, "OK"}, readResponse(t, r))
+	send("LOGOUT")
+	readResponse(t, r)
+}
@@ -33,6 +33,32 @@ func (t *Tokenizer) SkipWhitespace() error {
 	return nil
 }
 
+// SkipLineEnd consumes whitespace and line breaks, e.g. the CRLF ending a command
+func (t *Tokenizer) SkipLineEnd() error {
+	for {
+		b, err := t.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			return nil
+		}
+		_, _ = t.r.ReadByte() //nolint:errcheck
+	}
+}
+
+// AtLineEnd reports whether the current line has no further arguments
+func (t *Tokenizer) AtLineEnd() (bool, error) {
+	if err := t.SkipWhitespace(); err != nil {
+		return false, err
+	}
+	b, err := t.r.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	return b[0] == '\r' || b[0] == '\n', nil
+}
+
 // ReadWord reads an atom or quoted string
 func (t *Tokenizer) ReadWord() (string, error) {
 	if err := t.SkipWhitespace(); err != nil {
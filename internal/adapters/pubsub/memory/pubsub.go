@@ -54,6 +54,24 @@ func (p *PubSub) Unsubscribe(_ context.Context, channel string) error {
 	return nil
 }
 
+// unsubscribe removes a single subscriber of a channel, leaving the others subscribed
+func (p *PubSub) unsubscribe(channel string, sub <-chan []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subs := p.subscribers[channel]
+	for i, ch := range subs {
+		if ch == sub {
+			close(ch)
+			p.subscribers[channel] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(p.subscribers[channel]) == 0 {
+		delete(p.subscribers, channel)
+	}
+}
+
 func (p *PubSub) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -117,10 +135,10 @@ func (n *NotificationBus) Listen(ctx context.Context, userID string) (<-chan por
 		}
 	}()
 
+	// Other listeners of the same user, e.g. other IMAP sessions, stay subscribed
 	cancel := func() {
 		close(done)
-		//nolint:errcheck
-		_ = n.pubsub.Unsubscribe(ctx, channel)
+		n.pubsub.unsubscribe(channel, raw)
 	}
 	return out, cancel, nil
 }
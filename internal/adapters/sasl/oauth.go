@@ -0,0 +1,130 @@
+package sasl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenClaims are the claims of the JWTs issued by the API
+type tokenClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// oauthServer implements OAUTHBEARER (RFC 7628) and Google's XOAUTH2 with the API's JWTs
+// as bearer tokens
+type oauthServer struct {
+	auth    *Authenticator
+	ctx     context.Context
+	xoauth2 bool
+	sent    bool
+	failed  bool
+	user    *domain.User
+}
+
+func (o *oauthServer) Next(response []byte) ([]byte, bool, error) {
+	if o.failed {
+		// The client acknowledged the error challenge (RFC 7628 Section 3.2.3)
+		return nil, false, ErrAuthenticationFailed
+	}
+	if response == nil && !o.sent {
+		o.sent = true
+		return []byte{}, false, nil
+	}
+
+	username, token, err := o.parse(string(response))
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err := o.verify(username, token)
+	if err != nil {
+		o.failed = true
+		if o.xoauth2 {
+			return []byte(`{"status":"401","schemes":"bearer"}`), false, nil
+		}
+		return []byte(`{"status":"invalid_token","schemes":"bearer"}`), false, nil
+	}
+	o.user = user
+	return nil, true, nil
+}
+
+// parse extracts the user and the bearer token from the client response
+func (o *oauthServer) parse(msg string) (username, token string, err error) {
+	var kvpairs string
+	if o.xoauth2 {
+		// "user=" user ^A "auth=Bearer " token ^A ^A
+		kvpairs = msg
+	} else {
+		// gs2-header ^A *(key "=" value ^A) ^A, where gs2-header is e.g. "n,a=user@example.com,"
+		parts := strings.SplitN(msg, ",", 3)
+		if len(parts) != 3 || (parts[0] != "n" && parts[0] != "y") {
+			return "", "", ErrMalformed
+		}
+		if parts[1] != "" {
+			if !strings.HasPrefix(parts[1], "a=") {
+				return "", "", ErrMalformed
+			}
+			var ok bool
+			if username, ok = decodeSaslName(parts[1][2:]); !ok {
+				return "", "", ErrMalformed
+			}
+		}
+		kvpairs = parts[2]
+	}
+
+	for _, kv := range strings.Split(kvpairs, "\x01") {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "user":
+			if o.xoauth2 {
+				username = value
+			}
+		case "auth":
+			scheme, credentials, ok := strings.Cut(value, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				return "", "", ErrMalformed
+			}
+			token = strings.TrimSpace(credentials)
+		}
+	}
+	if token == "" {
+		return "", "", ErrMalformed
+	}
+	return username, token, nil
+}
+
+// verify checks the token like the API's auth middleware and returns its user, who must be
+// the claimed user if one is given
+func (o *oauthServer) verify(username, token string) (*domain.User, error) {
+	claims := &tokenClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(o.auth.jwtSecret), nil
+	})
+	if err != nil || !parsed.Valid || claims.Email == "" {
+		return nil, ErrAuthenticationFailed
+	}
+	if username != "" && !strings.EqualFold(username, claims.Email) {
+		return nil, ErrAuthenticationFailed
+	}
+
+	user, err := o.auth.users.FindByEmail(o.ctx, claims.Email)
+	if err != nil || user == nil {
+		return nil, ErrAuthenticationFailed
+	}
+	return user, nil
+}
+
+func (o *oauthServer) User() *domain.User {
+	return o.user
+}
@@ -0,0 +1,88 @@
+package sasl
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// plainServer implements PLAIN (RFC 4616): authzid NUL authcid NUL passwd
+type plainServer struct {
+	auth *Authenticator
+	ctx  context.Context
+	sent bool
+	user *domain.User
+}
+
+func (p *plainServer) Next(response []byte) ([]byte, bool, error) {
+	if response == nil && !p.sent {
+		// No initial response: ask for the credentials with an empty challenge
+		p.sent = true
+		return []byte{}, false, nil
+	}
+
+	parts := bytes.Split(response, []byte{0})
+	if len(parts) != 3 || len(parts[1]) == 0 {
+		return nil, false, ErrMalformed
+	}
+	authzid, authcid, password := string(parts[0]), string(parts[1]), string(parts[2])
+
+	// Acting on behalf of another user is not supported
+	if authzid != "" && !strings.EqualFold(authzid, authcid) {
+		return nil, false, ErrAuthenticationFailed
+	}
+
+	user, err := p.auth.VerifyPassword(p.ctx, authcid, password)
+	if err != nil {
+		return nil, false, err
+	}
+	p.user = user
+	return nil, true, nil
+}
+
+func (p *plainServer) User() *domain.User {
+	return p.user
+}
+
+// loginServer implements the obsolete but widely deployed LOGIN mechanism, which prompts
+// for the username and the password in turn
+type loginServer struct {
+	auth     *Authenticator
+	ctx      context.Context
+	step     int
+	username string
+	user     *domain.User
+}
+
+func (l *loginServer) Next(response []byte) ([]byte, bool, error) {
+	switch l.step {
+	case 0:
+		l.step++
+		if response == nil {
+			return []byte("Username:"), false, nil
+		}
+		// Some clients send the username as the initial response
+		l.username = string(response)
+		l.step++
+		return []byte("Password:"), false, nil
+	case 1:
+		l.username = string(response)
+		l.step++
+		return []byte("Password:"), false, nil
+	case 2:
+		l.step++
+		user, err := l.auth.VerifyPassword(l.ctx, l.username, string(response))
+		if err != nil {
+			return nil, false, err
+		}
+		l.user = user
+		return nil, true, nil
+	}
+	return nil, false, ErrMalformed
+}
+
+func (l *loginServer) User() *domain.User {
+	return l.user
+}
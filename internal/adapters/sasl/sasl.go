@@ -0,0 +1,126 @@
+// Package sasl implements the server side of the SASL mechanisms (RFC 4422) shared by the
+// IMAP and ManageSieve servers, so that passwords, app passwords and API tokens all go
+// through one authentication path.
+package sasl
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+)
+
+// Supported mechanism names
+const (
+	Plain       = "PLAIN"
+	Login       = "LOGIN"
+	ScramSHA1   = "SCRAM-SHA-1"
+	ScramSHA256 = "SCRAM-SHA-256"
+	OAuthBearer = "OAUTHBEARER"
+	XOAuth2     = "XOAUTH2"
+)
+
+var (
+	// ErrAuthenticationFailed is returned when the client's credentials are rejected
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrUnsupportedMechanism is returned for unknown or currently unavailable mechanisms
+	ErrUnsupportedMechanism = errors.New("unsupported SASL mechanism")
+	// ErrMalformed is returned when a client response violates the mechanism's syntax
+	ErrMalformed = errors.New("malformed SASL response")
+)
+
+// Server is the server side of one SASL exchange
+type Server interface {
+	// Next processes a client response and returns the next challenge. The first call
+	// carries the initial response, or nil if the client sent none. When done is true the
+	// client is authenticated; a non-empty challenge is then additional data to send with
+	// the outcome (RFC 4422 Section 3.6).
+	Next(response []byte) (challenge []byte, done bool, err error)
+
+	// User returns the authenticated user once the exchange is done
+	User() *domain.User
+}
+
+// Authenticator creates SASL exchanges that verify credentials against the user repository
+type Authenticator struct {
+	users     ports.UserRepository
+	jwtSecret string
+}
+
+// NewAuthenticator creates an Authenticator. OAUTHBEARER and XOAUTH2 accept the API's
+// JWTs and are only offered when jwtSecret is set.
+func NewAuthenticator(users ports.UserRepository, jwtSecret string) *Authenticator {
+	return &Authenticator{users: users, jwtSecret: jwtSecret}
+}
+
+// Mechanisms lists the mechanisms offered to a client. Mechanisms that reveal the password
+// or a bearer token are only offered when plaintext is true, i.e. on a protected connection
+// or where insecure authentication is explicitly allowed.
+func (a *Authenticator) Mechanisms(plaintext bool) []string {
+	mechs := []string{ScramSHA256, ScramSHA1}
+	if plaintext {
+		mechs = append(mechs, Plain, Login)
+		if a.jwtSecret != "" {
+			mechs = append(mechs, OAuthBearer, XOAuth2)
+		}
+	}
+	return mechs
+}
+
+// NewServer starts an exchange for the named mechanism (case-insensitive)
+// Returns ErrUnsupportedMechanism if the mechanism isn't offered under plaintext
+func (a *Authenticator) NewServer(ctx context.Context, mech string, plaintext bool) (Server, error) {
+	mech = strings.ToUpper(mech)
+	offered := false
+	for _, m := range a.Mechanisms(plaintext) {
+		if m == mech {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		return nil, ErrUnsupportedMechanism
+	}
+
+	switch mech {
+	case Plain:
+		return &plainServer{auth: a, ctx: ctx}, nil
+	case Login:
+		return &loginServer{auth: a, ctx: ctx}, nil
+	case ScramSHA1:
+		return newScramServer(a, ctx, sha1Hash), nil
+	case ScramSHA256:
+		return newScramServer(a, ctx, sha256Hash), nil
+	case OAuthBearer:
+		return &oauthServer{auth: a, ctx: ctx}, nil
+	case XOAuth2:
+		return &oauthServer{auth: a, ctx: ctx, xoauth2: true}, nil
+	}
+	return nil, ErrUnsupportedMechanism
+}
+
+// VerifyPassword authenticates a user by password, for the PLAIN and LOGIN mechanisms and
+// for protocol commands that take a password directly, like IMAP LOGIN. Users who don't
+// have SCRAM credentials yet (e.g. created before they existed) get them derived now, so
+// that they can switch to SCRAM afterwards.
+func (a *Authenticator) VerifyPassword(ctx context.Context, email, password string) (*domain.User, error) {
+	user, err := a.users.Authenticate(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, ports.ErrInvalidCredentials) {
+			return nil, ErrAuthenticationFailed
+		}
+		return nil, err
+	}
+
+	if user.SCRAMSHA1 == nil || user.SCRAMSHA256 == nil {
+		if sha1Creds, sha256Creds, err := DeriveSCRAM(password); err == nil {
+			// Best effort: the login succeeds either way
+			if a.users.UpdateSCRAMCredentials(ctx, user.Email, sha1Creds, sha256Creds) == nil {
+				user.SCRAMSHA1, user.SCRAMSHA256 = sha1Creds, sha256Creds
+			}
+		}
+	}
+	return user, nil
+}
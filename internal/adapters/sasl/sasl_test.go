@@ -0,0 +1,230 @@
+package sasl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+)
+
+const testSecret = "test-secret"
+
+// memUserRepo keeps users in memory; methods the mechanisms don't use are left to the
+// embedded nil interface
+type memUserRepo struct {
+	ports.UserRepository
+	users map[string]*domain.User
+}
+
+func newMemUserRepo(t *testing.T, email, password string) *memUserRepo {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return &memUserRepo{users: map[string]*domain.User{email: {Email: email, PasswordHash: string(hash)}}}
+}
+
+func (m *memUserRepo) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if user, ok := m.users[email]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, ports.ErrNotFound
+}
+
+func (m *memUserRepo) Authenticate(ctx context.Context, email, password string) (*domain.User, error) {
+	user, err := m.FindByEmail(ctx, email)
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ports.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (m *memUserRepo) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	user, ok := m.users[email]
+	if !ok {
+		return ports.ErrNotFound
+	}
+	user.SCRAMSHA1, user.SCRAMSHA256 = sha1, sha256
+	return nil
+}
+
+func TestPlain(t *testing.T) {
+	repo := newMemUserRepo(t, "alice@example.com", "secret")
+	auth := NewAuthenticator(repo, "")
+
+	_, err := auth.NewServer(context.Background(), "plain", false)
+	assert.ErrorIs(t, err, ErrUnsupportedMechanism, "PLAIN needs a protected connection")
+
+	server, err := auth.NewServer(context.Background(), "plain", true)
+	require.NoError(t, err)
+	challenge, done, err := server.Next(nil)
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Empty(t, challenge)
+
+	_, done, err = server.Next([]byte("\x00alice@example.com\x00secret"))
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, "alice@example.com", server.User().Email)
+	assert.NotNil(t, repo.users["alice@example.com"].SCRAMSHA256, "SCRAM credentials are derived on first use")
+
+	for _, response := range []string{"\x00alice@example.com\x00wrong", "bob@example.com\x00alice@example.com\x00secret"} {
+		server, _ = auth.NewServer(context.Background(), Plain, true)
+		_, _, err = server.Next([]byte(response))
+		assert.ErrorIs(t, err, ErrAuthenticationFailed)
+	}
+
+	server, _ = auth.NewServer(context.Background(), Plain, true)
+	_, _, err = server.Next([]byte("alice@example.com"))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestLogin(t *testing.T) {
+	auth := NewAuthenticator(newMemUserRepo(t, "alice@example.com", "secret"), "")
+	server, err := auth.NewServer(context.Background(), Login, true)
+	require.NoError(t, err)
+
+	challenge, _, _ := server.Next(nil)
+	assert.Equal(t, "Username:", string(challenge))
+	challenge, _, _ = server.Next([]byte("alice@example.com"))
+	assert.Equal(t, "Password:", string(challenge))
+	_, done, err := server.Next([]byte("secret"))
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+// scramClient runs the client side of SCRAM (RFC 5802 Section 3) against a server
+func scramClient(t *testing.T, server Server, h func() hash.Hash, username, password string) (*domain.User, error) {
+	clientFirstBare := "n=" + username + ",r=clientnonce"
+	serverFirst, done, err := server.Next([]byte("n,," + clientFirstBare))
+	require.NoError(t, err)
+	require.False(t, done)
+
+	attrs := strings.Split(string(serverFirst), ",")
+	require.Len(t, attrs, 3)
+	nonce := strings.TrimPrefix(attrs[0], "r=")
+	require.True(t, strings.HasPrefix(nonce, "clientnonce"))
+	salt, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(attrs[1], "s="))
+	require.NoError(t, err)
+	iterations, err := strconv.Atoi(strings.TrimPrefix(attrs[2], "i="))
+	require.NoError(t, err)
+
+	salted, err := pbkdf2.Key(h, password, salt, iterations, h().Size())
+	require.NoError(t, err)
+	clientKey := hmacSum(h, salted, []byte("Client Key"))
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + nonce
+	authMessage := []byte(clientFirstBare + "," + string(serverFirst) + "," + withoutProof)
+	proof := hmacSum(h, hashSum(h, clientKey), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	serverFinal, done, err := server.Next([]byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)))
+	if err != nil {
+		return nil, err
+	}
+	require.True(t, done)
+	serverSignature := hmacSum(h, hmacSum(h, salted, []byte("Server Key")), authMessage)
+	assert.True(t, hmac.Equal([]byte("v="+base64.StdEncoding.EncodeToString(serverSignature)), serverFinal))
+	return server.User(), nil
+}
+
+func TestScram(t *testing.T) {
+	repo := newMemUserRepo(t, "alice@example.com", "secret")
+	sha1Creds, sha256Creds, err := DeriveSCRAM("secret")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateSCRAMCredentials(context.Background(), "alice@example.com", sha1Creds, sha256Creds))
+	auth := NewAuthenticator(repo, "")
+
+	for _, tt := range []struct {
+		mech string
+		hash func() hash.Hash
+	}{{ScramSHA1, sha1Hash}, {ScramSHA256, sha256Hash}} {
+		t.Run(tt.mech, func(t *testing.T) {
+			server, err := auth.NewServer(context.Background(), tt.mech, false)
+			require.NoError(t, err, "SCRAM is offered without TLS")
+			user, err := scramClient(t, server, tt.hash, "alice@example.com", "secret")
+			require.NoError(t, err)
+			assert.Equal(t, "alice@example.com", user.Email)
+
+			server, _ = auth.NewServer(context.Background(), tt.mech, false)
+			_, err = scramClient(t, server, tt.hash, "alice@example.com", "wrong")
+			assert.ErrorIs(t, err, ErrAuthenticationFailed)
+
+			server, _ = auth.NewServer(context.Background(), tt.mech, false)
+			_, err = scramClient(t, server, tt.hash, "nobody@example.com", "secret")
+			assert.ErrorIs(t, err, ErrAuthenticationFailed)
+		})
+	}
+
+	server, _ := auth.NewServer(context.Background(), ScramSHA256, false)
+	_, _, err = server.Next([]byte("p=tls-unique,,n=alice@example.com,r=abc"))
+	assert.ErrorIs(t, err, ErrAuthenticationFailed, "channel binding is not supported")
+}
+
+func TestDecodeSaslName(t *testing.T) {
+	name, ok := decodeSaslName("a=2Cb=3Dc")
+	assert.True(t, ok)
+	assert.Equal(t, "a,b=c", name)
+	_, ok = decodeSaslName("a=b")
+	assert.False(t, ok)
+}
+
+func signToken(t *testing.T, email, secret string, expires time.Time) string {
+	claims := &tokenClaims{
+		Email:            email,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expires)},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestOAuthBearer(t *testing.T) {
+	auth := NewAuthenticator(newMemUserRepo(t, "alice@example.com", "secret"), testSecret)
+	assert.Contains(t, auth.Mechanisms(true), OAuthBearer)
+	assert.NotContains(t, NewAuthenticator(nil, "").Mechanisms(true), OAuthBearer, "OAuth needs a JWT secret")
+
+	token := signToken(t, "alice@example.com", testSecret, time.Now().Add(time.Hour))
+	server, err := auth.NewServer(context.Background(), OAuthBearer, true)
+	require.NoError(t, err)
+	_, done, err := server.Next([]byte("n,a=alice@example.com,\x01host=localhost\x01auth=Bearer " + token + "\x01\x01"))
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, "alice@example.com", server.User().Email)
+
+	server, _ = auth.NewServer(context.Background(), XOAuth2, true)
+	_, done, err = server.Next([]byte("user=alice@example.com\x01auth=Bearer " + token + "\x01\x01"))
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	// Failures send an error challenge first and fail once the client acknowledges it
+	for _, token := range []string{
+		signToken(t, "alice@example.com", "other-secret", time.Now().Add(time.Hour)),
+		signToken(t, "alice@example.com", testSecret, time.Now().Add(-time.Hour)),
+	} {
+		server, _ = auth.NewServer(context.Background(), OAuthBearer, true)
+		challenge, done, err := server.Next([]byte("n,,\x01auth=Bearer " + token + "\x01\x01"))
+		require.NoError(t, err)
+		assert.False(t, done)
+		assert.Contains(t, string(challenge), "invalid_token")
+		_, _, err = server.Next([]byte("\x01"))
+		assert.ErrorIs(t, err, ErrAuthenticationFailed)
+	}
+
+	server, _ = auth.NewServer(context.Background(), OAuthBearer, true)
+	challenge, _, _ := server.Next([]byte("n,a=bob@example.com,\x01auth=Bearer " + token + "\x01\x01"))
+	assert.Contains(t, string(challenge), "invalid_token", "the token must belong to the claimed user")
+}
@@ -0,0 +1,261 @@
+package sasl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // SCRAM-SHA-1 is defined with SHA-1 (RFC 5802)
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// scramIterations is the PBKDF2 iteration count of newly derived credentials
+// (RFC 7677 Section 4 recommends at least 4096)
+const scramIterations = 4096
+
+const scramSaltSize = 16
+
+var (
+	sha1Hash   = sha1.New
+	sha256Hash = sha256.New
+)
+
+// dummyKey derives salts for unknown users, so that SCRAM doesn't reveal which users exist
+var dummyKey = func() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+// DeriveSCRAM derives SCRAM-SHA-1 and SCRAM-SHA-256 credentials from a password, each with
+// a fresh random salt. They are stored whenever a password is set.
+func DeriveSCRAM(password string) (sha1Creds, sha256Creds *domain.SCRAMCredentials, err error) {
+	sha1Creds, err = deriveCredentials(sha1Hash, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	sha256Creds, err = deriveCredentials(sha256Hash, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sha1Creds, sha256Creds, nil
+}
+
+func deriveCredentials(h func() hash.Hash, password string) (*domain.SCRAMCredentials, error) {
+	salt := make([]byte, scramSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	// RFC 5802 Section 3: SaltedPassword := Hi(Normalize(password), salt, i)
+	salted, err := pbkdf2.Key(h, password, salt, scramIterations, h().Size())
+	if err != nil {
+		return nil, err
+	}
+	clientKey := hmacSum(h, salted, []byte("Client Key"))
+	return &domain.SCRAMCredentials{
+		Salt:       salt,
+		Iterations: scramIterations,
+		StoredKey:  hashSum(h, clientKey),
+		ServerKey:  hmacSum(h, salted, []byte("Server Key")),
+	}, nil
+}
+
+func hmacSum(h func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func hashSum(h func() hash.Hash, data []byte) []byte {
+	d := h()
+	d.Write(data)
+	return d.Sum(nil)
+}
+
+// scramServer implements SCRAM-SHA-1 (RFC 5802) and SCRAM-SHA-256 (RFC 7677) without
+// channel binding
+type scramServer struct {
+	auth *Authenticator
+	ctx  context.Context
+	hash func() hash.Hash
+	step int
+
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	nonce           string
+	creds           *domain.SCRAMCredentials
+	candidate       *domain.User
+	user            *domain.User
+}
+
+func newScramServer(auth *Authenticator, ctx context.Context, h func() hash.Hash) *scramServer {
+	return &scramServer{auth: auth, ctx: ctx, hash: h}
+}
+
+func (s *scramServer) Next(response []byte) ([]byte, bool, error) {
+	switch s.step {
+	case 0:
+		if response == nil {
+			// SCRAM is client-first: wait for client-first-message
+			s.step = 1
+			return []byte{}, false, nil
+		}
+		s.step = 1
+		fallthrough
+	case 1:
+		s.step = 2
+		return s.clientFirst(string(response))
+	case 2:
+		s.step = 3
+		return s.clientFinal(string(response))
+	}
+	return nil, false, ErrMalformed
+}
+
+// clientFirst handles client-first-message = gs2-header client-first-message-bare
+func (s *scramServer) clientFirst(msg string) ([]byte, bool, error) {
+	// gs2-header = gs2-cbind-flag "," [ authzid ] ","
+	parts := strings.SplitN(msg, ",", 3)
+	if len(parts) != 3 {
+		return nil, false, ErrMalformed
+	}
+	switch {
+	case parts[0] == "n", parts[0] == "y":
+	case strings.HasPrefix(parts[0], "p="):
+		// Channel binding isn't offered (no -PLUS mechanisms)
+		return nil, false, ErrAuthenticationFailed
+	default:
+		return nil, false, ErrMalformed
+	}
+	authzid := ""
+	if parts[1] != "" {
+		if !strings.HasPrefix(parts[1], "a=") {
+			return nil, false, ErrMalformed
+		}
+		var ok bool
+		if authzid, ok = decodeSaslName(parts[1][2:]); !ok {
+			return nil, false, ErrMalformed
+		}
+	}
+	s.gs2Header = parts[0] + "," + parts[1] + ","
+	s.clientFirstBare = parts[2]
+
+	// client-first-message-bare = [reserved-mext ","] username "," nonce ["," extensions]
+	attrs := strings.Split(s.clientFirstBare, ",")
+	if len(attrs) < 2 || !strings.HasPrefix(attrs[0], "n=") || !strings.HasPrefix(attrs[1], "r=") || len(attrs[1]) == 2 {
+		return nil, false, ErrMalformed
+	}
+	username, ok := decodeSaslName(attrs[0][2:])
+	if !ok || username == "" {
+		return nil, false, ErrMalformed
+	}
+	if authzid != "" && !strings.EqualFold(authzid, username) {
+		return nil, false, ErrAuthenticationFailed
+	}
+
+	// Unknown users and users without stored credentials continue with made-up
+	// credentials and fail at the proof, like a wrong password
+	if user, err := s.auth.users.FindByEmail(s.ctx, username); err == nil && user != nil {
+		if s.hash().Size() == sha1.Size {
+			s.creds = user.SCRAMSHA1
+		} else {
+			s.creds = user.SCRAMSHA256
+		}
+		s.candidate = user
+	}
+	if s.creds == nil {
+		s.candidate = nil
+		s.creds = &domain.SCRAMCredentials{
+			Salt:       hmacSum(sha256Hash, dummyKey, []byte(username))[:scramSaltSize],
+			Iterations: scramIterations,
+		}
+	}
+
+	serverNonce := make([]byte, 18)
+	if _, err := rand.Read(serverNonce); err != nil {
+		return nil, false, err
+	}
+	s.nonce = attrs[1][2:] + base64.RawStdEncoding.EncodeToString(serverNonce)
+	s.serverFirst = "r=" + s.nonce +
+		",s=" + base64.StdEncoding.EncodeToString(s.creds.Salt) +
+		",i=" + strconv.Itoa(s.creds.Iterations)
+	return []byte(s.serverFirst), false, nil
+}
+
+// clientFinal handles client-final-message = channel-binding "," nonce ["," extensions] "," proof
+func (s *scramServer) clientFinal(msg string) ([]byte, bool, error) {
+	idx := strings.LastIndex(msg, ",p=")
+	if idx < 0 {
+		return nil, false, ErrMalformed
+	}
+	withoutProof := msg[:idx]
+	proof, err := base64.StdEncoding.DecodeString(msg[idx+3:])
+	if err != nil {
+		return nil, false, ErrMalformed
+	}
+
+	attrs := strings.Split(withoutProof, ",")
+	if len(attrs) < 2 || !strings.HasPrefix(attrs[0], "c=") || !strings.HasPrefix(attrs[1], "r=") {
+		return nil, false, ErrMalformed
+	}
+	binding, err := base64.StdEncoding.DecodeString(attrs[0][2:])
+	if err != nil || string(binding) != s.gs2Header || attrs[1][2:] != s.nonce {
+		return nil, false, ErrAuthenticationFailed
+	}
+
+	// RFC 5802 Section 3:
+	// AuthMessage := client-first-message-bare + "," + server-first-message + "," + client-final-message-without-proof
+	// ClientSignature := HMAC(StoredKey, AuthMessage)
+	// ClientKey := ClientProof XOR ClientSignature
+	authMessage := []byte(s.clientFirstBare + "," + s.serverFirst + "," + withoutProof)
+	if s.candidate == nil || len(proof) != len(s.creds.StoredKey) {
+		return nil, false, ErrAuthenticationFailed
+	}
+	clientKey := hmacSum(s.hash, s.creds.StoredKey, authMessage)
+	for i := range clientKey {
+		clientKey[i] ^= proof[i]
+	}
+	if subtle.ConstantTimeCompare(hashSum(s.hash, clientKey), s.creds.StoredKey) != 1 {
+		return nil, false, ErrAuthenticationFailed
+	}
+
+	s.user = s.candidate
+	serverSignature := hmacSum(s.hash, s.creds.ServerKey, authMessage)
+	return []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature)), true, nil
+}
+
+func (s *scramServer) User() *domain.User {
+	return s.user
+}
+
+// decodeSaslName decodes the "=2C" and "=3D" escapes of a SCRAM saslname
+func decodeSaslName(name string) (string, bool) {
+	if !strings.Contains(name, "=") {
+		return name, true
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '=' {
+			sb.WriteByte(name[i])
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name[i:], "=2C"):
+			sb.WriteByte(',')
+		case strings.HasPrefix(name[i:], "=3D"):
+			sb.WriteByte('=')
+		default:
+			return "", false
+		}
+		i += 2
+	}
+	return sb.String(), true
+}
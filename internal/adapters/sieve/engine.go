@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"git.sr.ht/~emersion/go-sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 )
 
@@ -38,6 +39,11 @@ func NewSieveEngine(
 	}
 }
 
+// SetSigners enables DKIM signing of vacation replies
+func (e *SieveEngine) SetSigners(signers *dkim.Resolver) {
+	e.vacationManager.SetSigners(signers)
+}
+
 // Execute runs the Sieve interpreter with resource limits.
 func (e *SieveEngine) Execute(ctx context.Context, userID string, rawMsg []byte) ([]string, error) {
 	script, err := e.scriptRepo.GetActive(ctx, userID)
@@ -146,7 +146,8 @@ func (m *MockMailboxRepo) CreateMailbox(ctx context.Context, userID, name string
 	return args.Error(0)
 }
 
-func (m *MockMailboxRepo) Save(ctx context.Context, msg *domain.Message) error { return nil }
+func (m *MockMailboxRepo) Save(ctx context.Context, msg *domain.Message) error       { return nil }
+func (m *MockMailboxRepo) SaveAll(ctx context.Context, msgs []*domain.Message) error { return nil }
 func (m *MockMailboxRepo) FindByID(ctx context.Context, id string) (*domain.Message, error) {
 	return nil, nil
 }
@@ -176,12 +177,57 @@ func (m *MockMailboxRepo) GetMailbox(ctx context.Context, userID, name string) (
 func (m *MockMailboxRepo) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
 	return nil, nil
 }
+func (m *MockMailboxRepo) ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	return nil, nil
+}
 func (m *MockMailboxRepo) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
 	return nil, nil
 }
-func (m *MockMailboxRepo) CopyMessages(ctx context.Context, userID string, messageIDs []string, destMailbox string) error {
+func (m *MockMailboxRepo) FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) RenameMailbox(ctx context.Context, userID, oldName, newName string) error {
+	return nil
+}
+func (m *MockMailboxRepo) DeleteMailbox(ctx context.Context, userID, name string) ([]string, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error {
+	return nil
+}
+func (m *MockMailboxRepo) SetSpecialUse(ctx context.Context, userID, name, specialUse string) error {
+	return nil
+}
+func (m *MockMailboxRepo) GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error {
+	return nil
+}
+func (m *MockMailboxRepo) GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
 	return nil
 }
+func (m *MockMailboxRepo) GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error) {
+	return nil, nil
+}
+func (m *MockMailboxRepo) MoveMessages(ctx context.Context, userID, srcMailbox string, messageIDs []string, destMailbox string) (map[uint32]uint32, error) {
+	return nil, nil
+}
 func (m *MockMailboxRepo) AddFlags(ctx context.Context, messageID string, flags ...string) error {
 	return nil
 }
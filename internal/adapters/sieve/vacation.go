@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/google/uuid"
@@ -17,7 +18,7 @@ type VacationManager struct {
 	vacationRepo ports.VacationRepository
 	queueRepo    ports.QueueRepository
 	blobStore    ports.BlobStore
-	// domainRepo removed as unused
+	signers      *dkim.Resolver // Optional; replies are sent unsigned without it
 }
 
 // NewVacationManager creates a new VacationManager.
@@ -33,6 +34,14 @@ func NewVacationManager(
 	}
 }
 
+// vacationSignedHeaders are the headers covered by the DKIM signature of a reply
+var vacationSignedHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "Auto-Submitted"}
+
+// SetSigners enables DKIM signing of replies with the key of the replying user's domain
+func (m *VacationManager) SetSigners(signers *dkim.Resolver) {
+	m.signers = signers
+}
+
 type VacationConfig struct {
 	Subject string
 	Reason  string // Body
@@ -119,10 +128,24 @@ func (m *VacationManager) ProcessVacation(ctx context.Context, recipient string,
 	timestamp := time.Now()
 
 	// Simple email construction
-	rawEmail := fmt.Sprintf("From: <%s>\r\nTo: <%s>\r\nSubject: %s\r\nAuto-Submitted: auto-replied\r\n\r\n%s",
-		recipient, sender, subject, replyBody)
+	senderDomain := recipient[strings.LastIndex(recipient, "@")+1:]
+	rawEmail := []byte(fmt.Sprintf("From: <%s>\r\nTo: <%s>\r\nSubject: %s\r\nDate: %s\r\nMessage-ID: <%s@%s>\r\nAuto-Submitted: auto-replied\r\n\r\n%s",
+		recipient, sender, subject, timestamp.UTC().Format(time.RFC1123Z), replyID, senderDomain, replyBody))
+
+	// Sign with the replying user's domain so the reply passes DMARC alignment
+	keys, err := m.signers.SignersFor(ctx, senderDomain)
+	if err != nil {
+		return fmt.Errorf("failed to load DKIM key: %w", err)
+	}
+	if len(keys) > 0 {
+		signature, err := keys.Sign(rawEmail, vacationSignedHeaders)
+		if err != nil {
+			return fmt.Errorf("failed to sign vacation reply: %w", err)
+		}
+		rawEmail = append([]byte(signature+"\r\n"), rawEmail...)
+	}
 
-	if _, err := m.blobStore.Write(ctx, blobKey, []byte(rawEmail)); err != nil {
+	if _, err := m.blobStore.Write(ctx, blobKey, rawEmail); err != nil {
 		return fmt.Errorf("failed to write vacation body blob: %w", err)
 	}
 
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -24,15 +25,54 @@ func (m *MockQueueRepo) Enqueue(ctx context.Context, msg *domain.OutboundMessage
 	args := m.Called(ctx, msg)
 	return args.Error(0)
 }
+func (m *MockQueueRepo) EnqueueAll(ctx context.Context, msgs []*domain.OutboundMessage) error {
+	return m.Called(ctx, msgs).Error(0)
+}
 func (m *MockQueueRepo) LockNextReady(ctx context.Context) (*domain.OutboundMessage, error) {
 	return nil, nil
 }
 func (m *MockQueueRepo) UpdateStatus(ctx context.Context, id string, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
 	return nil
 }
+func (m *MockQueueRepo) TransitionStatus(ctx context.Context, id string, from, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
+	return nil
+}
 func (m *MockQueueRepo) Stats(ctx context.Context) (pending, processing, failed, completed int64, err error) {
 	return 0, 0, 0, 0, nil
 }
+func (m *MockQueueRepo) SetDelayWarnings(ctx context.Context, id string, count int) error {
+	return nil
+}
+func (m *MockQueueRepo) LockReadyForDomain(ctx context.Context, recipientDomain string, limit int) ([]*domain.OutboundMessage, error) {
+	return nil, nil
+}
+func (m *MockQueueRepo) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockQueueRepo) List(ctx context.Context, filter domain.QueueFilter) ([]*domain.OutboundMessage, error) {
+	return nil, nil
+}
+
+func (m *MockQueueRepo) Get(ctx context.Context, id string) (*domain.OutboundMessage, error) {
+	return nil, ports.ErrNotFound
+}
+
+func (m *MockQueueRepo) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockQueueRepo) RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error {
+	return nil
+}
+
+func (m *MockQueueRepo) ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error) {
+	return nil, nil
+}
+
+func (m *MockQueueRepo) Reroute(ctx context.Context, recipientDomain, relay string) (int64, error) {
+	return 0, nil
+}
 
 type MockBlobStore struct {
 	mock.Mock
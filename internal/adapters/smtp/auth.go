@@ -0,0 +1,129 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// handleAUTH processes the AUTH command (RFC 4954) for PLAIN and LOGIN mechanisms
+func (s *Server) handleAUTH(ctx context.Context, reader *bufio.Reader, writer *bufio.Writer, args string, session *domain.SMTPSession, logger *observability.Logger) {
+	if session.AuthUser != "" {
+		s.send(writer, "503 5.5.1 Already authenticated")
+		return
+	}
+	if !session.TLS {
+		s.send(writer, "538 5.7.11 Encryption required for requested authentication mechanism")
+		return
+	}
+	if s.userRepo == nil {
+		s.send(writer, "454 4.7.0 Temporary authentication failure")
+		return
+	}
+
+	parts := strings.Fields(args)
+	if len(parts) == 0 {
+		s.send(writer, "501 5.5.4 Syntax error in parameters")
+		return
+	}
+
+	var username, password string
+	var ok bool
+
+	switch strings.ToUpper(parts[0]) {
+	case "PLAIN":
+		// RFC 4616: [authzid] NUL authcid NUL passwd
+		var initial string
+		if len(parts) > 1 {
+			initial = parts[1]
+			if initial == "=" {
+				// RFC 4954 Section 4: zero-length initial response
+				initial = ""
+			}
+		} else {
+			s.send(writer, "334 ")
+			if initial, ok = s.readAuthLine(reader, writer); !ok {
+				return
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			s.send(writer, "501 5.5.2 Cannot decode response")
+			return
+		}
+		fields := bytes.Split(decoded, []byte{0})
+		if len(fields) != 3 {
+			s.send(writer, "501 5.5.2 Invalid PLAIN response")
+			return
+		}
+		if len(fields[0]) > 0 && !strings.EqualFold(string(fields[0]), string(fields[1])) {
+			// Proxy authorization is not supported
+			s.send(writer, "535 5.7.8 Authentication credentials invalid")
+			return
+		}
+		username, password = string(fields[1]), string(fields[2])
+
+	case "LOGIN":
+		var encUser string
+		if len(parts) > 1 {
+			encUser = parts[1]
+		} else {
+			s.send(writer, "334 VXNlcm5hbWU6") // "Username:"
+			if encUser, ok = s.readAuthLine(reader, writer); !ok {
+				return
+			}
+		}
+		u, err := base64.StdEncoding.DecodeString(encUser)
+		if err != nil {
+			s.send(writer, "501 5.5.2 Cannot decode response")
+			return
+		}
+		s.send(writer, "334 UGFzc3dvcmQ6") // "Password:"
+		encPass, ok := s.readAuthLine(reader, writer)
+		if !ok {
+			return
+		}
+		p, err := base64.StdEncoding.DecodeString(encPass)
+		if err != nil {
+			s.send(writer, "501 5.5.2 Cannot decode response")
+			return
+		}
+		username, password = string(u), string(p)
+
+	default:
+		s.send(writer, "504 5.5.4 Unrecognized authentication type")
+		return
+	}
+
+	user, err := s.userRepo.Authenticate(ctx, strings.ToLower(username), password)
+	if err != nil {
+		logger.Warn("SMTP authentication failed", "username", username)
+		s.send(writer, "535 5.7.8 Authentication credentials invalid")
+		return
+	}
+
+	session.AuthUser = strings.ToLower(user.Email)
+	logger.Info("SMTP authentication successful", "user", session.AuthUser)
+	s.send(writer, "235 2.7.0 Authentication successful")
+}
+
+// readAuthLine reads a client continuation line during AUTH.
+// Returns false if the exchange was cancelled or the connection failed.
+func (s *Server) readAuthLine(reader *bufio.Reader, writer *bufio.Writer) (string, bool) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	line = strings.TrimSpace(line)
+	if line == "*" {
+		// RFC 4954 Section 4: client cancelled the exchange
+		s.send(writer, "501 5.0.0 Authentication cancelled")
+		return "", false
+	}
+	return line, true
+}
@@ -3,15 +3,20 @@ package smtp
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/validators"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"golang.org/x/net/idna"
 )
@@ -26,9 +31,28 @@ type Client struct {
 
 	// Dependencies
 	daneValidator *validators.DANEValidator
+	mtasts        *validators.MTASTSValidator
+	mtastsMode    string // off, advisory, enforce
+	tlsRecorder   TLSResultRecorder
+
+	// opportunisticTLS accepts certificates that fail PKIX validation when no MTA-STS
+	// policy is enforced, instead of failing the delivery attempt
+	opportunisticTLS bool
 
 	// LookupMX is the function used to look up MX records. Defaults to net.LookupMX.
 	LookupMX func(name string) ([]*net.MX, error)
+
+	// Connection management
+	mu             sync.Mutex
+	maxPerMX       int                      // Concurrent sessions per MX host
+	idleTimeout    time.Duration            // How long idle sessions are kept for reuse
+	commandTimeout time.Duration            // Deadline for each SMTP command reply
+	dataTimeout    time.Duration            // Deadline for sending a message body and its final reply
+	mxSlots        map[string]chan struct{} // Per-MX concurrency semaphores
+	idle           map[string][]*mxSession  // Idle sessions by MX host
+	reaper         sync.Once                // Starts reapIdle with the first pooled session
+	closeOnce      sync.Once
+	done           chan struct{} // Closed by Close to stop the reaper
 }
 
 // NewClient creates a new SMTP client
@@ -52,7 +76,103 @@ func NewClient(daneCfg config.DANEConfig, logger *observability.Logger) *Client
 		daneMode:      daneCfg.Mode,
 		daneValidator: validator,
 		LookupMX:      net.LookupMX,
+		maxPerMX:      4,
+		idleTimeout:   30 * time.Second,
+		// RFC 5321 Section 4.5.3.2: 5 minutes per command, 10 for the end of data
+		commandTimeout: 5 * time.Minute,
+		dataTimeout:    10 * time.Minute,
+		mxSlots:        make(map[string]chan struct{}),
+		idle:           make(map[string][]*mxSession),
+		done:           make(chan struct{}),
+	}
+}
+
+// SetMTASTS enables outbound MTA-STS policy discovery and enforcement (RFC 8461).
+// In advisory mode policies are honoured as if they were in testing mode.
+func (c *Client) SetMTASTS(validator *validators.MTASTSValidator, mode string) {
+	c.mtasts = validator
+	c.mtastsMode = mode
+}
+
+// SetTLSRecorder registers a recorder for the outcome of outbound TLS negotiations
+func (c *Client) SetTLSRecorder(recorder TLSResultRecorder) {
+	c.tlsRecorder = recorder
+}
+
+// SetOpportunisticTLS lets delivery continue over TLS with a certificate that is not
+// PKIX-valid when the recipient domain does not enforce an MTA-STS policy (RFC 7435)
+func (c *Client) SetOpportunisticTLS(enabled bool) {
+	c.opportunisticTLS = enabled
+}
+
+// SetRootCAs overrides the roots used for PKIX validation of remote MX certificates
+func (c *Client) SetRootCAs(roots *x509.CertPool) {
+	c.tlsConf.RootCAs = roots
+}
+
+// Envelope is one SMTP transaction: a message and the recipients it is addressed to
+type Envelope struct {
+	From       string
+	Recipients []string
+	Data       []byte
+}
+
+// mxSession is an established (and, where possible, TLS-protected) SMTP session with an MX host
+type mxSession struct {
+	client    *smtp.Client
+	conn      net.Conn // Underlying connection, for deadlines
+	host      string
+	pkixValid bool // TLS with a PKIX-valid certificate, as required by an enforced MTA-STS policy
+	lastUsed  time.Time
+}
+
+// deadline bounds the next exchange with the server, so a stalled MX cannot hold a worker
+func (s *mxSession) deadline(d time.Duration) {
+	//nolint:errcheck // A failed SetDeadline surfaces as an I/O error on the next command
+	_ = s.conn.SetDeadline(time.Now().Add(d))
+}
+
+func (s *mxSession) close() {
+	s.deadline(quitTimeout)
+	//nolint:errcheck // Try to quit nicely
+	_ = s.client.Quit()
+	_ = s.client.Close()
+}
+
+// quitTimeout bounds the QUIT exchange when a session is closed
+const quitTimeout = 10 * time.Second
+
+// SetConnectionLimits bounds the number of concurrent sessions per MX host and
+// how long an idle session is kept open for reuse (0 disables reuse)
+func (c *Client) SetConnectionLimits(perMX int, idleTimeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxPerMX = perMX
+	c.idleTimeout = idleTimeout
+}
+
+// SetTimeouts sets how long to wait for the reply to each SMTP command, and for a
+// message body to be sent and acknowledged
+func (c *Client) SetTimeouts(command, data time.Duration) {
+	c.commandTimeout = command
+	c.dataTimeout = data
+}
+
+// Close stops the idle session reaper and quits all idle pooled sessions
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = make(map[string][]*mxSession)
+	c.mu.Unlock()
+
+	for _, sessions := range idle {
+		for _, s := range sessions {
+			s.close()
+		}
 	}
+	return nil
 }
 
 // Send delivers a message to the recipient's mail server
@@ -63,16 +183,36 @@ func (c *Client) Send(ctx context.Context, from string, recipient string, data [
 	// 1. Extract domain from recipient
 	parts := strings.Split(recipient, "@")
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid recipient address: %s", recipient)
+		return &PermanentError{Err: fmt.Errorf("invalid recipient address: %s", recipient)}
+	}
+
+	results := c.SendBatch(ctx, parts[1], "", []*Envelope{{From: from, Recipients: []string{recipient}, Data: data}})
+	return results[0][0]
+}
+
+// SendBatch delivers several messages for one recipient domain over a single SMTP session.
+// A non-empty relay (set by an administrator reroute) replaces the MX lookup; the domain's
+// MTA-STS policy does not apply to it and TLS is opportunistic.
+// It returns one error (nil on success) per recipient of each envelope, in order.
+func (c *Client) SendBatch(ctx context.Context, recipientDomain, relay string, envelopes []*Envelope) [][]error {
+	results := make([][]error, len(envelopes))
+	for i, env := range envelopes {
+		results[i] = make([]error, len(env.Recipients))
 	}
-	domain := parts[1]
 
 	// Handle IDN (Internationalized Domain Names)
-	asciiDomain, err := idna.ToASCII(domain)
+	asciiDomain, err := idna.ToASCII(recipientDomain)
 	if err != nil {
-		return fmt.Errorf("invalid domain %s: %w", domain, err)
+		return failAll(results, &PermanentError{Err: fmt.Errorf("invalid domain %s: %w", recipientDomain, err)})
 	}
 
+	if relay != "" {
+		return c.deliver(ctx, asciiDomain, []*net.MX{{Host: relay}}, &tlsPolicy{domain: strings.ToLower(asciiDomain)}, envelopes, results)
+	}
+
+	// Discover the domain's MTA-STS policy; it constrains MX selection and TLS below
+	policy := c.resolveTLSPolicy(ctx, strings.ToLower(asciiDomain))
+
 	// 2. Lookup MX records
 	mxs, err := c.LookupMX(asciiDomain)
 	if err != nil {
@@ -83,7 +223,7 @@ func (c *Client) Send(ctx context.Context, from string, recipient string, data [
 		// If it's NXDOMAIN for MX, we check A record.
 		// Simplifying: Just treat lookup error as failure for now, unless it's strictly "no such host" AND we want to support A-record fallback.
 		// Modern email delivery relies heavily on MX.
-		return fmt.Errorf("MX lookup failed for %s: %w", asciiDomain, err)
+		return failAll(results, fmt.Errorf("MX lookup failed for %s: %w", asciiDomain, err))
 	}
 
 	if len(mxs) == 0 {
@@ -92,7 +232,12 @@ func (c *Client) Send(ctx context.Context, from string, recipient string, data [
 		mxs = []*net.MX{{Host: asciiDomain, Pref: 0}}
 	}
 
-	// 3. Try each MX in order of preference
+	return c.deliver(ctx, asciiDomain, mxs, policy, envelopes, results)
+}
+
+// deliver tries each host in order until a session is established and runs the transactions on it
+func (c *Client) deliver(ctx context.Context, asciiDomain string, mxs []*net.MX, policy *tlsPolicy, envelopes []*Envelope, results [][]error) [][]error {
+	// 3. Try each MX in order of preference until a session is established
 	// net.LookupMX returns sorted by preference usually, but let's be safe?
 	// Actually strict RFC says we must sort. Go docs say "sorted by preference".
 
@@ -105,22 +250,287 @@ func (c *Client) Send(ctx context.Context, from string, recipient string, data [
 			continue
 		}
 
-		c.logger.Info("attempting delivery", "mx", mxHost, "recipient", recipient)
+		// Without enforcement the mismatch is reported with the session result in dial
+		if !policy.allowsMX(mxHost) {
+			c.logger.Warn("MX not listed in MTA-STS policy", "mx", mxHost, "domain", asciiDomain, "enforce", policy.enforce)
+			if policy.enforce {
+				c.recordTLSResult(ctx, mxMismatchResult(policy, mxHost, ""))
+				lastErr = fmt.Errorf("MX %s not permitted by MTA-STS policy of %s", mxHost, asciiDomain)
+				continue
+			}
+		}
 
-		err = c.deliverToHost(ctx, mxHost, from, recipient, data)
-		if err == nil {
-			c.logger.Info("delivery successful", "mx", mxHost, "recipient", recipient)
-			return nil
+		c.logger.Info("attempting delivery", "mx", mxHost, "domain", asciiDomain, "messages", len(envelopes))
+
+		session, err := c.acquireSession(ctx, mxHost, policy)
+		if err != nil {
+			c.logger.Warn("delivery failed to MX", "mx", mxHost, "error", err)
+			lastErr = err
+
+			// A 5xx reply is authoritative; other MX hosts would give the same answer
+			if IsPermanentFailure(err) {
+				return failAll(results, fmt.Errorf("delivery to %s rejected by %s: %w", asciiDomain, mxHost, err))
+			}
+			continue
 		}
 
-		c.logger.Warn("delivery failed to MX", "mx", mxHost, "error", err)
-		lastErr = err
+		healthy := c.transact(session, envelopes, results)
+		c.releaseSession(session, healthy)
+		return results
 	}
 
-	return fmt.Errorf("delivery failed to all MX records for %s. Last error: %v", domain, lastErr)
+	return failAll(results, fmt.Errorf("delivery failed to all MX records for %s. Last error: %w", asciiDomain, lastErr))
 }
 
-func (c *Client) deliverToHost(ctx context.Context, host string, from string, to string, data []byte) error {
+// failAll sets err as the result of every recipient
+func failAll(results [][]error, err error) [][]error {
+	for i := range results {
+		for j := range results[i] {
+			results[i][j] = err
+		}
+	}
+	return results
+}
+
+// transact runs one SMTP transaction per envelope over the session, recording per-recipient
+// results. It reports whether the session is still usable afterwards.
+func (c *Client) transact(session *mxSession, envelopes []*Envelope, results [][]error) bool {
+	client := session.client
+
+	for i, env := range envelopes {
+		setAll := func(err error, indexes []int) {
+			for _, j := range indexes {
+				results[i][j] = err
+			}
+		}
+		all := make([]int, len(env.Recipients))
+		for j := range all {
+			all[j] = j
+		}
+
+		reset := func() {
+			session.deadline(c.commandTimeout)
+			_ = client.Reset()
+		}
+
+		// Mail Command
+		session.deadline(c.commandTimeout)
+		if err := client.Mail(env.From); err != nil {
+			setAll(fmt.Errorf("MAIL FROM failed: %w", err), all)
+			if !isReply(err) {
+				return c.abandon(envelopes[i+1:], results[i+1:], err)
+			}
+			reset()
+			continue
+		}
+
+		// Rcpt Command, once per recipient
+		var accepted []int
+		for j, rcpt := range env.Recipients {
+			session.deadline(c.commandTimeout)
+			if err := client.Rcpt(rcpt); err != nil {
+				results[i][j] = fmt.Errorf("RCPT TO failed: %w", err)
+				if !isReply(err) {
+					setAll(results[i][j], all[j:])
+					return c.abandon(envelopes[i+1:], results[i+1:], err)
+				}
+				continue
+			}
+			accepted = append(accepted, j)
+		}
+		if len(accepted) == 0 {
+			reset()
+			continue
+		}
+
+		// Data Command
+		session.deadline(c.commandTimeout)
+		w, err := client.Data()
+		if err != nil {
+			setAll(fmt.Errorf("DATA command failed: %w", err), accepted)
+			if !isReply(err) {
+				return c.abandon(envelopes[i+1:], results[i+1:], err)
+			}
+			reset()
+			continue
+		}
+
+		// Write Body
+		session.deadline(c.dataTimeout)
+		if _, err := w.Write(env.Data); err != nil {
+			_ = w.Close() // Close writer to free resources, ignore error as we return original error
+			setAll(fmt.Errorf("write data failed: %w", err), accepted)
+			return c.abandon(envelopes[i+1:], results[i+1:], err)
+		}
+
+		// Close Data writer to signal end of message (".")
+		if err := w.Close(); err != nil {
+			setAll(fmt.Errorf("close data failed: %w", err), accepted)
+			if !isReply(err) {
+				return c.abandon(envelopes[i+1:], results[i+1:], err)
+			}
+		}
+	}
+
+	return true
+}
+
+// abandon fails the envelopes that could not be attempted because the session broke
+func (c *Client) abandon(envelopes []*Envelope, results [][]error, cause error) bool {
+	for i := range envelopes {
+		for j := range results[i] {
+			results[i][j] = fmt.Errorf("session aborted: %w", cause)
+		}
+	}
+	return false
+}
+
+// isReply reports whether err is an SMTP reply from the server, i.e. the session is still in sync
+func isReply(err error) bool {
+	var replyErr *textproto.Error
+	return errors.As(err, &replyErr)
+}
+
+// mxSlot returns the semaphore bounding concurrent sessions to an MX host
+func (c *Client) mxSlot(host string) chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot, ok := c.mxSlots[host]
+	if !ok {
+		size := c.maxPerMX
+		if size <= 0 {
+			size = 1
+		}
+		slot = make(chan struct{}, size)
+		c.mxSlots[host] = slot
+	}
+	return slot
+}
+
+// acquireSession waits for a free slot for the MX host, then reuses an idle session
+// compatible with the policy or dials a new one
+func (c *Client) acquireSession(ctx context.Context, host string, policy *tlsPolicy) (*mxSession, error) {
+	slot := c.mxSlot(host)
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		session := c.takeIdle(host, policy)
+		if session == nil {
+			break
+		}
+		// RSET verifies the remote has not dropped the idle connection
+		session.deadline(c.commandTimeout)
+		if err := session.client.Reset(); err == nil {
+			c.logger.Debug("reusing SMTP session", "mx", host)
+			return session, nil
+		}
+		session.close()
+	}
+
+	session, err := c.dial(ctx, host, policy)
+	if err != nil {
+		<-slot
+		return nil, err
+	}
+	return session, nil
+}
+
+// takeIdle pops the most recently used idle session for the host that satisfies the policy
+func (c *Client) takeIdle(host string, policy *tlsPolicy) *mxSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := c.idle[host]
+	var found *mxSession
+	var keep []*mxSession
+	for i := len(sessions) - 1; i >= 0; i-- {
+		session := sessions[i]
+		switch {
+		case time.Since(session.lastUsed) > c.idleTimeout:
+			go session.close()
+		case found == nil && (!policy.enforce || session.pkixValid):
+			found = session
+		default:
+			keep = append([]*mxSession{session}, keep...)
+		}
+	}
+	c.idle[host] = keep
+	return found
+}
+
+// releaseSession returns the MX slot and keeps a healthy session for reuse
+func (c *Client) releaseSession(session *mxSession, healthy bool) {
+	defer func() { <-c.mxSlot(session.host) }()
+
+	c.mu.Lock()
+	if !healthy || c.idleTimeout <= 0 {
+		c.mu.Unlock()
+		session.close()
+		return
+	}
+	//nolint:errcheck // Cleared so an idle session does not time out before the reaper sees it
+	_ = session.conn.SetDeadline(time.Time{})
+	session.lastUsed = time.Now()
+	c.idle[session.host] = append(c.idle[session.host], session)
+	c.mu.Unlock()
+
+	c.reaper.Do(func() { go c.reapIdle() })
+}
+
+// reapIdle periodically quits pooled sessions that have been idle longer than the idle
+// timeout, so connections to hosts we have no more work for are not kept open
+func (c *Client) reapIdle() {
+	c.mu.Lock()
+	interval := c.idleTimeout / 2
+	c.mu.Unlock()
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			for _, s := range c.expireIdle() {
+				s.close()
+			}
+		}
+	}
+}
+
+// expireIdle removes and returns the pooled sessions idle for longer than the idle timeout
+func (c *Client) expireIdle() []*mxSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expired []*mxSession
+	for host, sessions := range c.idle {
+		var keep []*mxSession
+		for _, s := range sessions {
+			if time.Since(s.lastUsed) > c.idleTimeout {
+				expired = append(expired, s)
+			} else {
+				keep = append(keep, s)
+			}
+		}
+		if len(keep) == 0 {
+			delete(c.idle, host)
+		} else {
+			c.idle[host] = keep
+		}
+	}
+	return expired
+}
+
+func (c *Client) dial(ctx context.Context, host string, policy *tlsPolicy) (*mxSession, error) {
 	// Add port
 	port := c.Port
 	if port == "" {
@@ -130,25 +540,37 @@ func (c *Client) deliverToHost(ctx context.Context, host string, from string, to
 
 	// Check context before dialing
 	if ctx.Err() != nil {
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	// Connect
 	conn, err := c.dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return fmt.Errorf("connect failed: %w", err)
+		return nil, fmt.Errorf("connect failed: %w", err)
 	}
-	defer conn.Close()
+
+	// Bound the greeting and EHLO exchange
+	//nolint:errcheck // A failed SetDeadline surfaces as an I/O error on the next read
+	_ = conn.SetDeadline(time.Now().Add(c.commandTimeout))
+
+	receivingIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	result := policy.result(host, receivingIP)
+	if !policy.allowsMX(host) {
+		result = mxMismatchResult(policy, host, receivingIP)
+	}
+	result.SendingIP, _, _ = net.SplitHostPort(conn.LocalAddr().String())
 
 	// Create SMTP client
 	client, err := smtp.NewClient(conn, host)
 	if err != nil {
-		return fmt.Errorf("smtp handshake failed: %w", err)
+		_ = conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+	session := &mxSession{client: client, conn: conn, host: host}
+	fail := func(err error) (*mxSession, error) {
+		session.close()
+		return nil, err
 	}
-	defer func() {
-		//nolint:errcheck // Try to quit nicely
-		_ = client.Quit()
-	}()
 
 	// STARTTLS if supported (DANE requires TLS match)
 	if ok, _ := client.Extension("STARTTLS"); ok {
@@ -156,8 +578,25 @@ func (c *Client) deliverToHost(ctx context.Context, host string, from string, to
 		tlsConf := c.tlsConf.Clone()
 		tlsConf.ServerName = host
 
-		// Add DANE verification hook
+		// Certificates are verified by the handshake. Only when opportunistic TLS is
+		// configured and no policy is enforced are they checked in VerifyConnection instead,
+		// so that a PKIX failure is reported but still yields an encrypted session (RFC 7435)
+		opportunistic := c.opportunisticTLS && !policy.enforce
+		tlsConf.InsecureSkipVerify = opportunistic
+
+		// Add PKIX (MTA-STS) and DANE verification hook
 		tlsConf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if !opportunistic {
+				session.pkixValid = true
+			} else if resultType, err := verifyPKIX(cs, host, c.tlsConf.RootCAs); err != nil {
+				result.Success = false
+				result.ResultType = resultType
+				result.Details = err.Error()
+				c.logger.Debug("Remote certificate not PKIX-valid, continuing with opportunistic TLS", "host", host, "error", err)
+			} else {
+				session.pkixValid = true
+			}
+
 			// Parse port
 			portInt := 25
 			// default to 25, but we should use the configured port if possible.
@@ -175,6 +614,11 @@ func (c *Client) deliverToHost(ctx context.Context, host string, from string, to
 				// If TLSA records exist but mismatch, it returns error.
 				if err := c.daneValidator.CheckTLSA(host, portInt, cs.PeerCertificates); err != nil {
 					c.logger.Error("DANE validation failed", "host", host, "error", err)
+					result.PolicyType = domain.TLSPolicyTypeTLSA
+					result.PolicyString = nil
+					result.Success = false
+					result.ResultType = domain.TLSResultTLSAInvalid
+					result.Details = err.Error()
 
 					// Enforce mode: Fail connection
 					if c.daneMode == "enforce" {
@@ -189,10 +633,31 @@ func (c *Client) deliverToHost(ctx context.Context, host string, from string, to
 			return nil
 		}
 
+		session.deadline(c.commandTimeout)
 		if err := client.StartTLS(tlsConf); err != nil {
-			return fmt.Errorf("starttls failed: %w", err)
+			if result.Success {
+				result.Success = false
+				result.ResultType = handshakeResultType(err)
+				result.Details = err.Error()
+			}
+			c.recordTLSResult(ctx, result)
+			if policy.enforce {
+				c.logger.Error("TLS negotiation failed under MTA-STS enforce policy", "host", host, "error", err)
+			}
+			return fail(fmt.Errorf("starttls failed: %w", err))
 		}
+		c.recordTLSResult(ctx, result)
 	} else {
+		result.Success = false
+		result.ResultType = domain.TLSResultSTARTTLSNotSupported
+		c.recordTLSResult(ctx, result)
+
+		// An enforced MTA-STS policy forbids falling back to plaintext
+		if policy.enforce {
+			c.logger.Error("Remote server does not support STARTTLS, refusing delivery under MTA-STS enforce policy", "host", host)
+			return fail(fmt.Errorf("MTA-STS policy of %s requires TLS but %s does not offer STARTTLS", policy.domain, host))
+		}
+
 		// DANE enforcement note:
 		// If DANE TLSA records exist, we theoretically MUST use STARTTLS.
 
@@ -203,33 +668,5 @@ func (c *Client) deliverToHost(ctx context.Context, host string, from string, to
 			c.logger.Debug("STARTTLS not supported by remote, skipping DANE check (downgrade risk)", "host", host)
 		}
 	}
-
-	// Mail Command
-	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("MAIL FROM failed: %w", err)
-	}
-
-	// Rcpt Command
-	if err := client.Rcpt(to); err != nil {
-		return fmt.Errorf("RCPT TO failed: %w", err)
-	}
-
-	// Data Command
-	w, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("DATA command failed: %w", err)
-	}
-
-	// Write Body
-	if _, err := w.Write(data); err != nil {
-		_ = w.Close() // Close writer to free resources, ignore error as we return original error
-		return fmt.Errorf("write data failed: %w", err)
-	}
-
-	// Close Data writer to signal end of message (".")
-	if err := w.Close(); err != nil {
-		return fmt.Errorf("close data failed: %w", err)
-	}
-
-	return nil
+	return session, nil
 }
@@ -4,8 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,7 +17,23 @@ import (
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 )
 
-// DeliveryWorker processes the outbound email queue
+// Delivery engine defaults, used until SetLimits is called
+const (
+	defaultDeliveryConcurrency = 8
+	defaultDeliveryPerDomain   = 2
+	defaultDeliveryBatchSize   = 20
+
+	deliveryPollInterval = 2 * time.Second  // Idle wait when the queue has nothing ready
+	deliveryTimeout      = 5 * time.Minute  // Upper bound for delivering one batch
+	deferDelay           = 5 * time.Second  // Postponement when a domain is at its concurrency limit
+	staleAfter           = 15 * time.Minute // PROCESSING messages older than this are requeued
+)
+
+// DeliveryWorker processes the outbound email queue with a pool of concurrent workers.
+// Each worker locks a ready message, claims a slot for the recipient domain and delivers
+// it together with other ready messages for the same domain in one SMTP session.
+// Queue locking is done by the repository, so several instances can share one queue;
+// per-domain slots are cluster-wide when a distributed lock is configured.
 type DeliveryWorker struct {
 	queueRepo ports.QueueRepository
 	blobStore ports.BlobStore
@@ -23,7 +42,18 @@ type DeliveryWorker struct {
 	metrics   *observability.Metrics
 	stopChan  chan struct{}
 	wg        sync.WaitGroup
-	ticker    *time.Ticker
+
+	bouncer       *Bouncer
+	delayWarnings []time.Duration // Queue ages at which "delayed" DSNs are sent
+	local         LocalDeliverer  // Stores local redeliveries; nil leaves them queued
+
+	concurrency int // Total concurrent deliveries
+	perDomain   int // Concurrent deliveries per recipient domain
+	batchSize   int // Maximum messages per SMTP session
+
+	lock        ports.DistributedLock // Optional; per-domain slots are local when nil
+	mu          sync.Mutex
+	domainSlots map[string]int // In-flight deliveries per domain (local mode)
 }
 
 // NewDeliveryWorker creates a new delivery worker
@@ -35,29 +65,70 @@ func NewDeliveryWorker(
 	metrics *observability.Metrics,
 ) *DeliveryWorker {
 	return &DeliveryWorker{
-		queueRepo: queueRepo,
-		blobStore: blobStore,
-		sender:    sender,
-		logger:    logger,
-		metrics:   metrics,
-		stopChan:  make(chan struct{}),
-		ticker:    time.NewTicker(5 * time.Second), // Check queue every 5 seconds
+		queueRepo:   queueRepo,
+		blobStore:   blobStore,
+		sender:      sender,
+		logger:      logger,
+		metrics:     metrics,
+		stopChan:    make(chan struct{}),
+		concurrency: defaultDeliveryConcurrency,
+		perDomain:   defaultDeliveryPerDomain,
+		batchSize:   defaultDeliveryBatchSize,
+		domainSlots: make(map[string]int),
+	}
+}
+
+// SetBouncer enables delivery status notifications: "failed" DSNs on permanent
+// failure and "delayed" DSNs once a message has been queued for each of delayWarnings.
+func (w *DeliveryWorker) SetBouncer(bouncer *Bouncer, delayWarnings []time.Duration) {
+	w.bouncer = bouncer
+	w.delayWarnings = delayWarnings
+}
+
+// SetLocalDeliverer enables the retry of local recipients that failed temporarily during
+// inbound delivery. Those queue entries are stored through local instead of sent over SMTP.
+func (w *DeliveryWorker) SetLocalDeliverer(local LocalDeliverer) {
+	w.local = local
+}
+
+// SetLimits configures total concurrency, per-domain concurrency and batch size.
+// Non-positive values keep the current setting.
+func (w *DeliveryWorker) SetLimits(concurrency, perDomain, batchSize int) {
+	if concurrency > 0 {
+		w.concurrency = concurrency
+	}
+	if perDomain > 0 {
+		w.perDomain = perDomain
 	}
+	if batchSize > 0 {
+		w.batchSize = batchSize
+	}
+}
+
+// SetLock makes per-domain concurrency limits apply across all instances sharing the lock
+func (w *DeliveryWorker) SetLock(lock ports.DistributedLock) {
+	w.lock = lock
 }
 
-// Start begins processing the queue in a background routine
+// Start begins processing the queue in background routines
 func (w *DeliveryWorker) Start() {
-	w.logger.Info("delivery worker started")
+	w.logger.Info("delivery worker started", "concurrency", w.concurrency, "per_domain", w.perDomain, "batch_size", w.batchSize)
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go w.processLoop()
+	}
 	w.wg.Add(1)
-	go w.processLoop()
+	go w.recoverLoop()
 }
 
-// Stop gracefully stops the worker, waiting for current delivery to finish
+// Stop gracefully stops the worker, waiting for current deliveries to finish
 func (w *DeliveryWorker) Stop() {
 	w.logger.Info("stopping delivery worker...")
 	close(w.stopChan)
 	w.wg.Wait()
-	w.ticker.Stop()
+	if closer, ok := w.sender.(io.Closer); ok {
+		_ = closer.Close()
+	}
 	w.logger.Info("delivery worker stopped")
 }
 
@@ -73,62 +144,259 @@ func (w *DeliveryWorker) processLoop() {
 		select {
 		case <-w.stopChan:
 			return
-		case <-w.ticker.C:
-			w.ProcessNext()
+		default:
+		}
+
+		// Drain continuously; only wait when nothing is ready
+		if w.ProcessNext() {
+			continue
+		}
+		select {
+		case <-w.stopChan:
+			return
+		case <-time.After(deliveryPollInterval):
 		}
 	}
 }
 
-func (w *DeliveryWorker) ProcessNext() {
+// recoverLoop periodically requeues messages left in PROCESSING by a crashed worker or instance
+func (w *DeliveryWorker) recoverLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			count, err := w.queueRepo.RequeueStale(context.Background(), time.Now().Add(-staleAfter))
+			if err != nil {
+				w.logger.Error("failed to requeue stale messages", "error", err)
+			} else if count > 0 {
+				w.logger.Warn("requeued stale outbound messages", "count", count)
+			}
+		}
+	}
+}
+
+// ProcessNext locks the next ready message and delivers it, batched with other ready
+// messages for the same domain. It reports whether a message was picked up.
+func (w *DeliveryWorker) ProcessNext() bool {
 	ctx := context.Background()
 
 	// 1. Lock next ready message
 	msg, err := w.queueRepo.LockNextReady(ctx)
 	if err != nil {
 		w.logger.Error("failed to lock next message", "error", err)
-		return
+		return false
 	}
 	if msg == nil {
 		// Queue empty or no ready messages
-		return
+		return false
 	}
 
-	w.logger.Info("proccessing outbound message", "id", msg.ID, "retry", msg.RetryCount)
+	recipientDomain := strings.ToLower(domainOf(msg.Recipient))
 
-	// 2. Fetch message content from blob store
-	content, err := w.blobStore.Read(ctx, msg.BlobKey)
-	if err != nil {
-		w.handlePermanentFailure(ctx, msg, fmt.Sprintf("blob missing: %v", err))
+	// 2. Claim a slot for the destination domain, or hand the message back
+	release, ok := w.acquireDomainSlot(ctx, recipientDomain)
+	if !ok {
+		w.deferMessage(ctx, msg)
+		return true
+	}
+	defer release()
+
+	// 3. Pick up further ready messages for the same domain
+	batch := []*domain.OutboundMessage{msg}
+	if w.batchSize > 1 && recipientDomain != "" {
+		more, err := w.queueRepo.LockReadyForDomain(ctx, recipientDomain, w.batchSize-1)
+		if err != nil {
+			w.logger.Warn("failed to lock batch", "domain", recipientDomain, "error", err)
+		}
+		batch = append(batch, more...)
+	}
+
+	// Rerouted messages go through their relay; keep each route in its own session.
+	// Local redeliveries don't go over SMTP at all.
+	var routes []string
+	byRoute := make(map[string][]*domain.OutboundMessage)
+	for _, m := range batch {
+		if m.Local {
+			w.deliverLocal(ctx, m)
+			continue
+		}
+		if _, ok := byRoute[m.Route]; !ok {
+			routes = append(routes, m.Route)
+		}
+		byRoute[m.Route] = append(byRoute[m.Route], m)
+	}
+	for _, route := range routes {
+		w.deliverBatch(ctx, recipientDomain, route, byRoute[route])
+	}
+	return true
+}
+
+// acquireDomainSlot claims one of the perDomain delivery slots for a domain
+func (w *DeliveryWorker) acquireDomainSlot(ctx context.Context, recipientDomain string) (func(), bool) {
+	if w.lock != nil {
+		for i := 0; i < w.perDomain; i++ {
+			handle, ok, err := w.lock.TryAcquire(ctx, fmt.Sprintf("delivery:domain:%s:%d", recipientDomain, i), deliveryTimeout+time.Minute)
+			if err != nil {
+				w.logger.Warn("failed to acquire domain slot", "domain", recipientDomain, "error", err)
+				return nil, false
+			}
+			if ok {
+				return func() {
+					//nolint:errcheck // Lock expires on its own if release fails
+					_ = handle.Release(context.Background())
+				}, true
+			}
+		}
+		return nil, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.domainSlots[recipientDomain] >= w.perDomain {
+		return nil, false
+	}
+	w.domainSlots[recipientDomain]++
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.domainSlots[recipientDomain]--
+		if w.domainSlots[recipientDomain] <= 0 {
+			delete(w.domainSlots, recipientDomain)
+		}
+	}, true
+}
+
+// deferMessage hands a locked message back to the queue without counting an attempt
+func (w *DeliveryWorker) deferMessage(ctx context.Context, msg *domain.OutboundMessage) {
+	status := domain.QueueStatusPending
+	if msg.RetryCount > 0 {
+		status = domain.QueueStatusRetrying
+	}
+	if err := w.queueRepo.UpdateStatus(ctx, msg.ID, status, msg.RetryCount, time.Now().Add(deferDelay), msg.LastError); err != nil {
+		w.logger.Error("failed to defer message", "id", msg.ID, "error", err)
+	}
+}
+
+// deliverBatch delivers messages for one domain. Queue entries sharing a sender and blob
+// (one submission to several recipients) become a single transaction with multiple RCPTs.
+func (w *DeliveryWorker) deliverBatch(ctx context.Context, recipientDomain, route string, batch []*domain.OutboundMessage) {
+	var envelopes []*Envelope
+	var envelopeMsgs [][]*domain.OutboundMessage
+	var contents [][]byte
+	index := make(map[string]int)
+
+	for _, msg := range batch {
+		w.logger.Info("processing outbound message", "id", msg.ID, "retry", msg.RetryCount)
+
+		key := msg.Sender + "\x00" + msg.BlobKey
+		if i, ok := index[key]; ok {
+			envelopes[i].Recipients = append(envelopes[i].Recipients, msg.Recipient)
+			envelopeMsgs[i] = append(envelopeMsgs[i], msg)
+			continue
+		}
+
+		// Fetch message content from blob store
+		content, err := w.blobStore.Read(ctx, msg.BlobKey)
+		if err != nil {
+			w.handlePermanentFailure(ctx, msg, nil, fmt.Sprintf("blob missing: %v", err))
+			continue
+		}
+
+		index[key] = len(envelopes)
+		envelopes = append(envelopes, &Envelope{From: msg.Sender, Recipients: []string{msg.Recipient}, Data: content})
+		envelopeMsgs = append(envelopeMsgs, []*domain.OutboundMessage{msg})
+		contents = append(contents, content)
+	}
+	if len(envelopes) == 0 {
 		return
 	}
-	// content is []byte
 
-	// 3. Attempt Delivery
-	// Use short timeout for delivery attempt
-	deliverCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	// Attempt Delivery
+	deliverCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
 	defer cancel()
 
 	start := time.Now()
-	err = w.sender.Send(deliverCtx, msg.Sender, msg.Recipient, content)
+	results := w.send(deliverCtx, recipientDomain, route, envelopes)
 	duration := time.Since(start)
 
+	for i, msgs := range envelopeMsgs {
+		for j, msg := range msgs {
+			if err := results[i][j]; err != nil {
+				w.logger.Warn("delivery failed", "id", msg.ID, "error", err, "duration", duration)
+				w.handleRetry(ctx, msg, contents[i], err)
+			} else {
+				w.logger.Info("delivery succeeded", "id", msg.ID, "duration", duration)
+				w.handleSuccess(ctx, msg)
+			}
+		}
+	}
+}
+
+// deliverLocal stores a queued message in the recipient's local mailbox, retrying it on
+// temporary failures like any outbound message
+func (w *DeliveryWorker) deliverLocal(ctx context.Context, msg *domain.OutboundMessage) {
+	w.logger.Info("processing local redelivery", "id", msg.ID, "retry", msg.RetryCount)
+
+	content, err := w.blobStore.Read(ctx, msg.BlobKey)
 	if err != nil {
-		w.logger.Warn("delivery failed", "id", msg.ID, "error", err, "duration", duration)
-		w.handleRetry(ctx, msg, err)
-	} else {
-		w.logger.Info("delivery succeeded", "id", msg.ID, "duration", duration)
+		w.handlePermanentFailure(ctx, msg, nil, fmt.Sprintf("blob missing: %v", err))
+		return
+	}
+	if w.local == nil {
+		w.handleRetry(ctx, msg, content, errors.New("local delivery is not available"))
+		return
+	}
+
+	err = w.local.DeliverLocal(ctx, msg.Sender, msg.Recipient, content)
+	switch {
+	case err == nil:
+		w.logger.Info("local redelivery succeeded", "id", msg.ID)
 		w.handleSuccess(ctx, msg)
+	case errors.Is(err, ErrQuotaExceeded):
+		w.handlePermanentFailure(ctx, msg, content, "552 5.2.2 Mailbox full")
+	default:
+		w.logger.Warn("local redelivery failed", "id", msg.ID, "error", err)
+		w.handleRetry(ctx, msg, content, err)
 	}
 }
 
+// send uses a batch-capable sender when available, otherwise one Send per recipient.
+// Only batch senders support relay routes.
+func (w *DeliveryWorker) send(ctx context.Context, recipientDomain, route string, envelopes []*Envelope) [][]error {
+	if batchSender, ok := w.sender.(BatchSender); ok {
+		return batchSender.SendBatch(ctx, recipientDomain, route, envelopes)
+	}
+
+	results := make([][]error, len(envelopes))
+	for i, env := range envelopes {
+		results[i] = make([]error, len(env.Recipients))
+		for j, rcpt := range env.Recipients {
+			results[i][j] = w.sender.Send(ctx, env.From, rcpt, env.Data)
+		}
+	}
+	return results
+}
+
 func (w *DeliveryWorker) handleSuccess(ctx context.Context, msg *domain.OutboundMessage) {
 	if err := w.queueRepo.UpdateStatus(ctx, msg.ID, domain.QueueStatusSent, msg.RetryCount, time.Time{}, ""); err != nil {
 		w.logger.Error("failed to mark message as sent", "id", msg.ID, "error", err)
 	}
+	w.recordAttempt(ctx, msg, domain.QueueStatusSent, "")
 	w.metrics.IncrementOutboundSent()
 }
 
-func (w *DeliveryWorker) handleRetry(ctx context.Context, msg *domain.OutboundMessage, failureErr error) {
+func (w *DeliveryWorker) handleRetry(ctx context.Context, msg *domain.OutboundMessage, content []byte, failureErr error) {
+	// 5xx replies will not succeed on retry
+	if IsPermanentFailure(failureErr) {
+		w.handlePermanentFailure(ctx, msg, content, failureErr.Error())
+		return
+	}
+
 	// Calculate backoff
 	// Strategy: 1min, 5min, 15min, 1h, 6h, 12h, 24h
 	retryCount := msg.RetryCount + 1
@@ -136,7 +404,7 @@ func (w *DeliveryWorker) handleRetry(ctx context.Context, msg *domain.OutboundMe
 	// Max retries
 	const maxRetries = 10
 	if retryCount > maxRetries {
-		w.handlePermanentFailure(ctx, msg, fmt.Sprintf("max retries exceeded. last error: %v", failureErr))
+		w.handlePermanentFailure(ctx, msg, content, fmt.Sprintf("max retries exceeded. last error: %v", failureErr))
 		return
 	}
 
@@ -148,16 +416,65 @@ func (w *DeliveryWorker) handleRetry(ctx context.Context, msg *domain.OutboundMe
 	if err := w.queueRepo.UpdateStatus(ctx, msg.ID, domain.QueueStatusRetrying, retryCount, nextRetry, failureErr.Error()); err != nil {
 		w.logger.Error("failed to update message retry status", "id", msg.ID, "error", err)
 	}
+	w.recordAttempt(ctx, msg, domain.QueueStatusRetrying, failureErr.Error())
 	w.metrics.IncrementOutboundFailedTransient()
+
+	w.sendDelayWarning(ctx, msg, content, failureErr)
 }
 
-func (w *DeliveryWorker) handlePermanentFailure(ctx context.Context, msg *domain.OutboundMessage, reason string) {
+// sendDelayWarning sends a "delayed" DSN when the message has crossed the next warning threshold
+func (w *DeliveryWorker) sendDelayWarning(ctx context.Context, msg *domain.OutboundMessage, content []byte, failureErr error) {
+	if w.bouncer == nil {
+		return
+	}
+
+	age := time.Since(msg.CreatedAt)
+	due := 0
+	for _, threshold := range w.delayWarnings {
+		if age >= threshold {
+			due++
+		}
+	}
+	if due <= msg.DelayWarnings {
+		return
+	}
+
+	if err := w.bouncer.Notify(ctx, msg, content, DSNActionDelayed, failureErr.Error()); err != nil {
+		w.logger.Error("failed to send delay notification", "id", msg.ID, "error", err)
+		return
+	}
+	if err := w.queueRepo.SetDelayWarnings(ctx, msg.ID, due); err != nil {
+		w.logger.Error("failed to record delay notification", "id", msg.ID, "error", err)
+	}
+}
+
+func (w *DeliveryWorker) handlePermanentFailure(ctx context.Context, msg *domain.OutboundMessage, content []byte, reason string) {
 	w.logger.Error("permanent delivery failure", "id", msg.ID, "reason", reason)
 
 	if err := w.queueRepo.UpdateStatus(ctx, msg.ID, domain.QueueStatusFailed, msg.RetryCount, time.Time{}, reason); err != nil {
 		w.logger.Error("failed to mark message as failed", "id", msg.ID, "error", err)
 	}
+	w.recordAttempt(ctx, msg, domain.QueueStatusFailed, reason)
 	w.metrics.IncrementOutboundFailedPermanent()
+
+	if w.bouncer != nil {
+		if err := w.bouncer.Notify(ctx, msg, content, DSNActionFailed, reason); err != nil {
+			w.logger.Error("failed to send non-delivery report", "id", msg.ID, "error", err)
+		}
+	}
+}
+
+// recordAttempt adds the outcome of a delivery attempt to the message's retry history
+func (w *DeliveryWorker) recordAttempt(ctx context.Context, msg *domain.OutboundMessage, status domain.OutboundStatus, errText string) {
+	attempt := &domain.DeliveryAttempt{
+		MessageID:   msg.ID,
+		Status:      status,
+		Error:       errText,
+		AttemptedAt: time.Now().UTC(),
+	}
+	if err := w.queueRepo.RecordAttempt(ctx, attempt); err != nil {
+		w.logger.Warn("failed to record delivery attempt", "id", msg.ID, "error", err)
+	}
 }
 
 func (w *DeliveryWorker) calculateBackoff(attempt int) time.Duration {
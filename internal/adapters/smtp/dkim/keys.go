@@ -0,0 +1,80 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// rsaKeyBits is the size of generated RSA keys (RFC 8301 Section 3.2 recommends at least 2048)
+const rsaKeyBits = 2048
+
+// ParsePrivateKey decodes a PEM-encoded RSA (PKCS1 or PKCS8) or Ed25519 (PKCS8) private key
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block containing DKIM key")
+	}
+
+	// Try PKCS1
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+
+	// Try PKCS8
+	pk, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	switch k := pk.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("key is not an RSA or Ed25519 private key")
+	}
+}
+
+// GenerateKey creates a DKIM key pair for the algorithm (rsa or ed25519). It returns the PEM private key and the base64 public key
+// as published in the DNS record's p= tag.
+func GenerateKey(algorithm string) (privateKeyPEM, publicKey string, err error) {
+	var privDER, pubBytes []byte
+	blockType := "PRIVATE KEY"
+
+	switch algorithm {
+	case domain.DKIMAlgorithmRSA:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return "", "", err
+		}
+		privDER = x509.MarshalPKCS1PrivateKey(key)
+		blockType = "RSA PRIVATE KEY"
+		// RFC 6376 Section 3.6.1: p= holds the DER SubjectPublicKeyInfo
+		if pubBytes, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+			return "", "", err
+		}
+	case domain.DKIMAlgorithmEd25519:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		if privDER, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return "", "", err
+		}
+		// RFC 8463 Section 4: p= holds the raw 32-byte public key
+		pubBytes = pub
+	default:
+		return "", "", fmt.Errorf("unsupported DKIM algorithm %q", algorithm)
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: privDER})
+	return string(privPEM), base64.StdEncoding.EncodeToString(pubBytes), nil
+}
@@ -0,0 +1,341 @@
+package dkim
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// DefaultRetireGrace is how long a replaced key keeps its DNS record published, so that
+// signatures on messages still queued or in transit keep verifying
+const DefaultRetireGrace = 7 * 24 * time.Hour
+
+var (
+	// ErrKeyState is returned when an operation is not allowed in the key's current state
+	ErrKeyState = errors.New("operation not allowed in the key's state")
+	// ErrDNSNotPublished is returned when the key's TXT record is missing or does not match
+	ErrDNSNotPublished = errors.New("DKIM DNS record not published")
+)
+
+// KeyManager provisions DKIM keys for hosted domains and runs the rotation workflow:
+// Rotate generates a pending key under a new selector, VerifyDNS confirms its record is
+// published, Activate switches signing over and starts retiring the replaced key, and
+// RetireExpired destroys retiring keys once their grace period has passed.
+type KeyManager struct {
+	// LookupTXT resolves the key's TXT record. Defaults to net.LookupTXT.
+	LookupTXT func(name string) ([]string, error)
+
+	domainRepo ports.DomainRepository
+	signers    *Resolver
+	logger     *observability.Logger
+	now        func() time.Time
+}
+
+// NewKeyManager creates a key manager. signers (may be nil) is invalidated whenever a
+// domain's signing keys change.
+func NewKeyManager(domainRepo ports.DomainRepository, signers *Resolver, logger *observability.Logger) *KeyManager {
+	return &KeyManager{
+		LookupTXT:  net.LookupTXT,
+		domainRepo: domainRepo,
+		signers:    signers,
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+// Provision creates the domain with a new RSA and Ed25519 key, both active, so that its mail
+// is dual-signed (RFC 8463 Section 5). Returns ports.ErrAlreadyExists if the domain exists.
+func (m *KeyManager) Provision(ctx context.Context, d *domain.Domain) ([]*domain.DKIMKey, error) {
+	now := m.now().UTC()
+	var keys []*domain.DKIMKey
+	for _, algorithm := range []string{domain.DKIMAlgorithmRSA, domain.DKIMAlgorithmEd25519} {
+		key, err := m.generate(d.Name, algorithm, keys, now)
+		if err != nil {
+			return nil, err
+		}
+		key.Status = domain.DKIMKeyActive
+		key.ActivatedAt = now
+		keys = append(keys, key)
+	}
+
+	// The domain record shows the RSA selector and public key to clients that predate
+	// multiple keys. Its private key stays empty: signing only uses the key rows, which
+	// rotation and retirement keep current.
+	d.DKIMSelector = keys[0].Selector
+	d.DKIMPrivateKey = ""
+	d.DKIMPublicKey = publicKeyPEM(keys[0].PublicKey)
+	if err := m.domainRepo.CreateWithDKIMKeys(ctx, d, keys); err != nil {
+		return nil, err
+	}
+	m.signers.Invalidate(d.Name)
+
+	m.logger.Info("DKIM keys provisioned", "domain", d.Name, "selectors", keys[0].Selector+","+keys[1].Selector)
+	return keys, nil
+}
+
+// Deprovision deletes the domain together with its keys.
+// Returns ports.ErrNotFound if the domain does not exist.
+func (m *KeyManager) Deprovision(ctx context.Context, domainName string) error {
+	if err := m.domainRepo.Delete(ctx, domainName); err != nil {
+		return err
+	}
+	m.signers.Invalidate(domainName)
+	return nil
+}
+
+// Keys returns all keys of a domain. Returns ports.ErrNotFound for unknown domains.
+func (m *KeyManager) Keys(ctx context.Context, domainName string) ([]*domain.DKIMKey, error) {
+	exists, err := m.domainRepo.Exists(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ports.ErrNotFound
+	}
+	return m.domainRepo.ListDKIMKeys(ctx, domainName)
+}
+
+// Rotate generates a pending key for the algorithm under a new selector.
+// It does not sign until its DNS record is published and it is activated.
+func (m *KeyManager) Rotate(ctx context.Context, domainName, algorithm string) (*domain.DKIMKey, error) {
+	existing, err := m.Keys(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := m.generate(domainName, algorithm, existing, m.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	key.Status = domain.DKIMKeyPending
+	if err := m.domainRepo.CreateDKIMKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("DKIM key generated", "domain", domainName, "selector", key.Selector, "algorithm", algorithm)
+	return key, nil
+}
+
+// VerifyDNS confirms that the key's TXT record is published with its public key and records
+// when it was seen. Returns ErrDNSNotPublished if the record is missing or different.
+func (m *KeyManager) VerifyDNS(ctx context.Context, domainName, selector string) (*domain.DKIMKey, error) {
+	key, _, err := m.find(ctx, domainName, selector)
+	if err != nil {
+		return nil, err
+	}
+	if key.Status == domain.DKIMKeyRetired {
+		return nil, ErrKeyState
+	}
+
+	if err := m.checkDNS(key); err != nil {
+		return nil, err
+	}
+
+	key.VerifiedAt = m.now().UTC()
+	if err := m.domainRepo.UpdateDKIMKey(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Activate switches signing to a pending key, after confirming its DNS record unless
+// skipDNSCheck is set. Active keys of the same algorithm start retiring and are destroyed
+// after grace (DefaultRetireGrace if zero). A retiring key can be reactivated to roll back.
+func (m *KeyManager) Activate(ctx context.Context, domainName, selector string, grace time.Duration, skipDNSCheck bool) (*domain.DKIMKey, error) {
+	key, keys, err := m.find(ctx, domainName, selector)
+	if err != nil {
+		return nil, err
+	}
+	if key.Status != domain.DKIMKeyPending && key.Status != domain.DKIMKeyRetiring {
+		return nil, ErrKeyState
+	}
+	if grace <= 0 {
+		grace = DefaultRetireGrace
+	}
+
+	now := m.now().UTC()
+	if !skipDNSCheck {
+		if err := m.checkDNS(key); err != nil {
+			return nil, err
+		}
+		key.VerifiedAt = now
+	}
+
+	// The old keys retire and the new one activates together, so the domain never ends up
+	// without a signing key or with two
+	var retiring []*domain.DKIMKey
+	for _, old := range keys {
+		if old.Status != domain.DKIMKeyActive || old.Algorithm != key.Algorithm {
+			continue
+		}
+		old.Status = domain.DKIMKeyRetiring
+		old.RetireAt = now.Add(grace)
+		retiring = append(retiring, old)
+	}
+
+	key.Status = domain.DKIMKeyActive
+	key.ActivatedAt = now
+	key.RetireAt = time.Time{}
+	if err := m.domainRepo.UpdateDKIMKeys(ctx, append(retiring, key)); err != nil {
+		return nil, err
+	}
+	m.signers.Invalidate(domainName)
+	for _, old := range retiring {
+		m.logger.Info("DKIM key retiring", "domain", domainName, "selector", old.Selector, "retire_at", old.RetireAt)
+	}
+
+	m.logger.Info("DKIM key activated", "domain", domainName, "selector", selector, "algorithm", key.Algorithm)
+	return key, nil
+}
+
+// Delete removes a pending or retired key. Keys that sign, or may still be needed to
+// verify mail in transit, cannot be deleted.
+func (m *KeyManager) Delete(ctx context.Context, domainName, selector string) error {
+	key, _, err := m.find(ctx, domainName, selector)
+	if err != nil {
+		return err
+	}
+	if key.Status != domain.DKIMKeyPending && key.Status != domain.DKIMKeyRetired {
+		return ErrKeyState
+	}
+	return m.domainRepo.DeleteDKIMKey(ctx, domainName, selector)
+}
+
+// RetireExpired destroys the private keys of retiring keys whose grace period has passed.
+// Their DNS records can then be removed. Returns the number of keys retired.
+func (m *KeyManager) RetireExpired(ctx context.Context) (int, error) {
+	keys, err := m.domainRepo.ListDKIMKeysByStatus(ctx, domain.DKIMKeyRetiring)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list retiring DKIM keys: %w", err)
+	}
+
+	now := m.now()
+	retired := 0
+	for _, key := range keys {
+		if key.RetireAt.After(now) {
+			continue
+		}
+		key.Status = domain.DKIMKeyRetired
+		key.PrivateKey = ""
+		if err := m.domainRepo.UpdateDKIMKey(ctx, key); err != nil {
+			return retired, fmt.Errorf("failed to retire DKIM key %s for %s: %w", key.Selector, key.Domain, err)
+		}
+		retired++
+		m.logger.Info("DKIM key retired, its DNS record can be removed", "domain", key.Domain, "selector", key.Selector, "record", key.DNSName())
+	}
+	return retired, nil
+}
+
+// Start retires expired keys every hour until ctx is cancelled
+func (m *KeyManager) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if _, err := m.RetireExpired(ctx); err != nil {
+				m.logger.Error("DKIM key retirement failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// find returns the key with the selector together with all keys of its domain
+func (m *KeyManager) find(ctx context.Context, domainName, selector string) (*domain.DKIMKey, []*domain.DKIMKey, error) {
+	keys, err := m.domainRepo.ListDKIMKeys(ctx, domainName)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, key := range keys {
+		if key.Selector == selector {
+			return key, keys, nil
+		}
+	}
+	return nil, nil, ports.ErrNotFound
+}
+
+// checkDNS looks up the key's TXT record and compares it with the public key
+func (m *KeyManager) checkDNS(key *domain.DKIMKey) error {
+	records, err := m.LookupTXT(key.DNSName())
+	if err != nil {
+		return fmt.Errorf("%w: lookup of %s failed: %v", ErrDNSNotPublished, key.DNSName(), err)
+	}
+
+	for _, record := range records {
+		tags := make(map[string]string)
+		for _, field := range strings.Split(record, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if ok {
+				tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), "")
+			}
+		}
+		keyType := tags["k"]
+		if keyType == "" {
+			keyType = domain.DKIMAlgorithmRSA // RFC 6376 Section 3.6.1 default
+		}
+		if keyType == key.Algorithm && tags["p"] == key.PublicKey {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s does not contain the key", ErrDNSNotPublished, key.DNSName())
+}
+
+// generate creates a key for the algorithm under a selector not yet used by the domain.
+// Selectors name the algorithm and creation date, e.g. "ed20261016".
+func (m *KeyManager) generate(domainName, algorithm string, existing []*domain.DKIMKey, now time.Time) (*domain.DKIMKey, error) {
+	prefix := map[string]string{domain.DKIMAlgorithmRSA: "rsa", domain.DKIMAlgorithmEd25519: "ed"}[algorithm]
+	if prefix == "" {
+		return nil, fmt.Errorf("unsupported DKIM algorithm %q", algorithm)
+	}
+
+	privateKey, publicKey, err := GenerateKey(algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	base := prefix + now.Format("20060102")
+	selector := base
+	for n := 2; selectorTaken(existing, selector); n++ {
+		selector = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return &domain.DKIMKey{
+		Domain:     domainName,
+		Selector:   selector,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+		CreatedAt:  now,
+	}, nil
+}
+
+func selectorTaken(keys []*domain.DKIMKey, selector string) bool {
+	for _, key := range keys {
+		if key.Selector == selector {
+			return true
+		}
+	}
+	return false
+}
+
+// publicKeyPEM wraps a base64 SubjectPublicKeyInfo in PEM, the format of Domain.DKIMPublicKey
+func publicKeyPEM(publicKey string) string {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return ""
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
@@ -0,0 +1,126 @@
+package dkim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+)
+
+// resolverCacheTTL bounds how long a cached signer is used without re-reading the domain,
+// so key changes made by another instance are picked up
+const resolverCacheTTL = 5 * time.Minute
+
+type cachedSigners struct {
+	signers Signers // empty when the domain has no usable key
+	expires time.Time
+}
+
+// Resolver selects the DKIM signers for a sending domain: the hosted domain's active keys
+// from the DomainRepository, or the global key for the primary domain only.
+// Parsed signers are cached; call Invalidate when a domain's keys change.
+type Resolver struct {
+	domainRepo ports.DomainRepository
+	primary    *Signer // Global key from the configuration; may be nil
+
+	mu    sync.Mutex
+	cache map[string]*cachedSigners
+	now   func() time.Time
+}
+
+// NewResolver creates a signer resolver. primary (may be nil) is the global signer,
+// used only for its own domain when that domain has no key in the repository.
+func NewResolver(domainRepo ports.DomainRepository, primary *Signer) *Resolver {
+	return &Resolver{
+		domainRepo: domainRepo,
+		primary:    primary,
+		cache:      make(map[string]*cachedSigners),
+		now:        time.Now,
+	}
+}
+
+// SignersFor returns the signers for mail from domainName, or none if the domain has no key.
+// A nil Resolver never signs.
+func (r *Resolver) SignersFor(ctx context.Context, domainName string) (Signers, error) {
+	if r == nil {
+		return nil, nil
+	}
+	domainName = strings.ToLower(strings.TrimSuffix(domainName, "."))
+
+	r.mu.Lock()
+	entry, ok := r.cache[domainName]
+	r.mu.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.signers, nil
+	}
+
+	signers, err := r.load(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cache[domainName] = &cachedSigners{signers: signers, expires: r.now().Add(resolverCacheTTL)}
+	r.mu.Unlock()
+	return signers, nil
+}
+
+// Invalidate drops the cached signers of a domain after its keys were created, rotated or removed
+func (r *Resolver) Invalidate(domainName string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.cache, strings.ToLower(strings.TrimSuffix(domainName, ".")))
+	r.mu.Unlock()
+}
+
+func (r *Resolver) load(ctx context.Context, domainName string) (Signers, error) {
+	if r.domainRepo != nil {
+		keys, err := r.domainRepo.ListDKIMKeys(ctx, domainName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up DKIM keys of %s: %w", domainName, err)
+		}
+		if len(keys) > 0 {
+			var signers Signers
+			for _, k := range keys {
+				if k.Status != domain.DKIMKeyActive {
+					continue
+				}
+				key, err := ParsePrivateKey([]byte(k.PrivateKey))
+				if err != nil {
+					return nil, fmt.Errorf("invalid DKIM key %s for %s: %w", k.Selector, domainName, err)
+				}
+				signers = append(signers, NewSigner(domainName, k.Selector, key))
+			}
+			return signers, nil
+		}
+
+		// Domains without key records sign with the key stored on the domain itself
+		d, err := r.domainRepo.Get(ctx, domainName)
+		switch {
+		case err == nil && d.DKIMPrivateKey != "":
+			key, err := ParsePrivateKey([]byte(d.DKIMPrivateKey))
+			if err != nil {
+				return nil, fmt.Errorf("invalid DKIM key for %s: %w", domainName, err)
+			}
+			selector := d.DKIMSelector
+			if selector == "" {
+				selector = "default"
+			}
+			return Signers{NewSigner(domainName, selector, key)}, nil
+		case err != nil && !errors.Is(err, ports.ErrNotFound):
+			return nil, fmt.Errorf("failed to look up domain %s: %w", domainName, err)
+		}
+	}
+
+	if r.primary != nil && strings.EqualFold(r.primary.Domain, domainName) {
+		return Signers{r.primary}, nil
+	}
+	return nil, nil
+}
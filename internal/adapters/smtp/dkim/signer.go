@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -18,11 +19,11 @@ import (
 type Signer struct {
 	Domain     string
 	Selector   string
-	PrivateKey *rsa.PrivateKey
+	PrivateKey crypto.Signer // *rsa.PrivateKey or ed25519.PrivateKey
 }
 
-// NewSigner creates a new DKIM signer
-func NewSigner(domain, selector string, privateKey *rsa.PrivateKey) *Signer {
+// NewSigner creates a new DKIM signer for an RSA or Ed25519 key
+func NewSigner(domain, selector string, privateKey crypto.Signer) *Signer {
 	return &Signer{
 		Domain:     domain,
 		Selector:   selector,
@@ -80,7 +81,8 @@ func (s *Signer) Sign(data []byte, headersToSign []string) (string, error) {
 
 	// 5. Construct DKIM-Signature value
 	dkimHeaderVal := fmt.Sprintf(
-		"v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		"v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Algorithm(),
 		s.Domain,
 		s.Selector,
 		time.Now().Unix(),
@@ -102,8 +104,12 @@ func (s *Signer) Sign(data []byte, headersToSign []string) (string, error) {
 
 	hashed := h.Sum(nil)
 
-	// 7. Sign the hash
-	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, hashed)
+	// 7. Sign the hash. Ed25519 signs the SHA-256 digest itself with PureEdDSA (RFC 8463 Section 3).
+	opts := crypto.Hash(0)
+	if _, ok := s.PrivateKey.(*rsa.PrivateKey); ok {
+		opts = crypto.SHA256
+	}
+	signature, err := s.PrivateKey.Sign(rand.Reader, hashed, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign: %w", err)
 	}
@@ -114,6 +120,30 @@ func (s *Signer) Sign(data []byte, headersToSign []string) (string, error) {
 	return "DKIM-Signature: " + dkimHeaderVal + sigBase64, nil
 }
 
+// Algorithm returns the signing algorithm of the key as used in the a= tag
+func (s *Signer) Algorithm() string {
+	if _, ok := s.PrivateKey.(ed25519.PrivateKey); ok {
+		return "ed25519-sha256"
+	}
+	return "rsa-sha256"
+}
+
+// Signers is the set of keys a domain signs with; each adds its own DKIM-Signature
+type Signers []*Signer
+
+// Sign returns one DKIM-Signature header per signer, separated by CRLF
+func (s Signers) Sign(data []byte, headersToSign []string) (string, error) {
+	headers := make([]string, 0, len(s))
+	for _, signer := range s {
+		header, err := signer.Sign(data, headersToSign)
+		if err != nil {
+			return "", fmt.Errorf("%s signature for selector %s: %w", signer.Algorithm(), signer.Selector, err)
+		}
+		headers = append(headers, header)
+	}
+	return strings.Join(headers, "\r\n"), nil
+}
+
 // Helpers
 
 type Header struct {
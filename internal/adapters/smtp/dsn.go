@@ -0,0 +1,208 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// DSNAction is the Action field of a per-recipient delivery status (RFC 3464 Section 2.3.3)
+type DSNAction string
+
+const (
+	DSNActionFailed  DSNAction = "failed"
+	DSNActionDelayed DSNAction = "delayed"
+)
+
+// enhancedStatusPattern matches RFC 3463 enhanced status codes (e.g. "5.1.1")
+var enhancedStatusPattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+
+// DSNReport describes a delivery status notification for one recipient
+type DSNReport struct {
+	ReportingMTA string    // Our hostname
+	Sender       string    // Original envelope sender (DSN recipient)
+	Recipient    string    // Recipient that failed or is delayed
+	Action       DSNAction // failed or delayed
+	Diagnostic   string    // Last error / remote reply
+	ArrivalDate  time.Time // When the message entered the queue
+	LastAttempt  time.Time // Time of the last delivery attempt
+}
+
+// Status returns the RFC 3463 status code, taken from the diagnostic if present
+func (r *DSNReport) Status() string {
+	if m := enhancedStatusPattern.FindStringSubmatch(r.Diagnostic); m != nil {
+		return m[1]
+	}
+	if r.Action == DSNActionDelayed {
+		return "4.0.0"
+	}
+	return "5.0.0"
+}
+
+// Subject returns the subject line of the notification
+func (r *DSNReport) Subject() string {
+	if r.Action == DSNActionDelayed {
+		return "Delayed Mail (still being retried)"
+	}
+	return "Undelivered Mail Returned to Sender"
+}
+
+// BuildDSN renders a multipart/report delivery status notification (RFC 3464)
+// with the original message headers attached as text/rfc822-headers
+func BuildDSN(report *DSNReport, original []byte) []byte {
+	boundary := "dsn-" + uuid.New().String()
+	postmaster := "MAILER-DAEMON@" + report.ReportingMTA
+	diagnostic := strings.Join(strings.Fields(report.Diagnostic), " ")
+
+	text := fmt.Sprintf("Your message could not be delivered to %s.\r\n\r\nThe remote server reported:\r\n    %s\r\n\r\nNo further attempts will be made.\r\n",
+		report.Recipient, diagnostic)
+	if report.Action == DSNActionDelayed {
+		text = fmt.Sprintf("Your message to %s has not been delivered yet.\r\n\r\nThe last attempt failed with:\r\n    %s\r\n\r\nDelivery will continue to be retried. You do not need to resend the message.\r\n",
+			report.Recipient, diagnostic)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: Mail Delivery System <%s>\r\n", postmaster)
+	fmt.Fprintf(&b, "To: %s\r\n", report.Sender)
+	fmt.Fprintf(&b, "Subject: %s\r\n", report.Subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", uuid.New().String(), report.ReportingMTA)
+	b.WriteString("Auto-Submitted: auto-replied\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/report; report-type=delivery-status; boundary=\"%s\"\r\n\r\n", boundary)
+
+	// Part 1: human-readable explanation
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(text)
+	b.WriteString("\r\n")
+
+	// Part 2: machine-readable status (RFC 3464 Section 2)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: message/delivery-status\r\n\r\n")
+	fmt.Fprintf(&b, "Reporting-MTA: dns; %s\r\n", report.ReportingMTA)
+	if !report.ArrivalDate.IsZero() {
+		fmt.Fprintf(&b, "Arrival-Date: %s\r\n", report.ArrivalDate.UTC().Format(time.RFC1123Z))
+	}
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "Final-Recipient: rfc822; %s\r\n", report.Recipient)
+	fmt.Fprintf(&b, "Action: %s\r\n", report.Action)
+	fmt.Fprintf(&b, "Status: %s\r\n", report.Status())
+	if diagnostic != "" {
+		fmt.Fprintf(&b, "Diagnostic-Code: smtp; %s\r\n", diagnostic)
+	}
+	if !report.LastAttempt.IsZero() {
+		fmt.Fprintf(&b, "Last-Attempt-Date: %s\r\n", report.LastAttempt.UTC().Format(time.RFC1123Z))
+	}
+	b.WriteString("\r\n")
+
+	// Part 3: original headers
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/rfc822-headers\r\n\r\n")
+	b.Write(messageHeaders(original))
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	return b.Bytes()
+}
+
+// messageHeaders returns the header block of a raw message, normalised to CRLF
+func messageHeaders(raw []byte) []byte {
+	if idx := bytes.Index(raw, []byte("\r\n\r\n")); idx != -1 {
+		return raw[:idx+2]
+	}
+	if idx := bytes.Index(raw, []byte("\n\n")); idx != -1 {
+		return bytes.ReplaceAll(raw[:idx+1], []byte("\n"), []byte("\r\n"))
+	}
+	return raw
+}
+
+// Bouncer delivers delivery status notifications to the original sender:
+// into the sender's INBOX when local, otherwise back through the outbound queue
+type Bouncer struct {
+	hostname  string
+	local     *Handler // Delivers to local senders like any inbound message
+	userRepo  ports.UserRepository
+	queueRepo ports.QueueRepository
+	blobStore ports.BlobStore
+	logger    *observability.Logger
+}
+
+// NewBouncer creates a new DSN bouncer
+func NewBouncer(
+	hostname string,
+	local *Handler,
+	userRepo ports.UserRepository,
+	queueRepo ports.QueueRepository,
+	blobStore ports.BlobStore,
+	logger *observability.Logger,
+) *Bouncer {
+	return &Bouncer{
+		hostname:  hostname,
+		local:     local,
+		userRepo:  userRepo,
+		queueRepo: queueRepo,
+		blobStore: blobStore,
+		logger:    logger,
+	}
+}
+
+// Notify sends a DSN for msg to its envelope sender
+func (b *Bouncer) Notify(ctx context.Context, msg *domain.OutboundMessage, original []byte, action DSNAction, diagnostic string) error {
+	// RFC 3464 Section 2: never send a DSN in response to a null reverse-path
+	if msg.Sender == "" {
+		return nil
+	}
+
+	report := &DSNReport{
+		ReportingMTA: b.hostname,
+		Sender:       msg.Sender,
+		Recipient:    msg.Recipient,
+		Action:       action,
+		Diagnostic:   diagnostic,
+		ArrivalDate:  msg.CreatedAt,
+		LastAttempt:  time.Now(),
+	}
+	dsn := BuildDSN(report, original)
+
+	if _, err := b.userRepo.FindByEmail(ctx, msg.Sender); err == nil {
+		if err := b.local.DeliverLocal(ctx, "MAILER-DAEMON@"+b.hostname, msg.Sender, dsn); err != nil {
+			return fmt.Errorf("failed to store DSN: %w", err)
+		}
+		b.logger.Info("DSN stored in sender mailbox", "id", msg.ID, "sender", msg.Sender, "action", action)
+		return nil
+	}
+
+	id := uuid.New().String()
+	blobPath, err := b.blobStore.Write(ctx, id, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to write DSN blob: %w", err)
+	}
+
+	// Remote sender: DSNs use the null reverse-path so they can never loop
+	now := time.Now().UTC()
+	outMsg := &domain.OutboundMessage{
+		ID:          id,
+		Sender:      "",
+		Recipient:   msg.Sender,
+		BlobKey:     blobPath,
+		Status:      domain.QueueStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextRetryAt: now,
+	}
+	if err := b.queueRepo.Enqueue(ctx, outMsg); err != nil {
+		return fmt.Errorf("failed to enqueue DSN: %w", err)
+	}
+	b.logger.Info("DSN queued for remote sender", "id", msg.ID, "sender", msg.Sender, "action", action)
+	return nil
+}
@@ -2,6 +2,7 @@ package smtp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,9 +13,61 @@ import (
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/validators"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 )
 
+// ErrQuotaExceeded is returned when a delivery would exceed a limit of a recipient's quota roots
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// RecipientError records a delivery failure for a single recipient
+type RecipientError struct {
+	Recipient string
+	Err       error
+}
+
+// DeliveryError reports per-recipient results when at least one recipient failed
+type DeliveryError struct {
+	Delivered []string
+	Failed    []RecipientError
+}
+
+func (e *DeliveryError) Error() string {
+	parts := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		parts = append(parts, fmt.Sprintf("%s: %v", f.Recipient, f.Err))
+	}
+	return fmt.Sprintf("delivery failed for %d of %d recipients (%s)",
+		len(e.Failed), len(e.Failed)+len(e.Delivered), strings.Join(parts, "; "))
+}
+
+// Unwrap exposes the individual recipient errors to errors.Is/As
+func (e *DeliveryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// Permanent reports whether every failure is permanent, so retrying the transaction is pointless
+func (e *DeliveryError) Permanent() bool {
+	permanent, _ := e.Split()
+	return len(e.Failed) > 0 && len(permanent) == len(e.Failed)
+}
+
+// Split separates the failures that can't succeed on retry from the temporary ones
+func (e *DeliveryError) Split() (permanent, temporary []RecipientError) {
+	for _, f := range e.Failed {
+		if errors.Is(f.Err, ErrQuotaExceeded) {
+			permanent = append(permanent, f)
+		} else {
+			temporary = append(temporary, f)
+		}
+	}
+	return permanent, temporary
+}
+
 // Handler processes SMTP messages with validation and storage
 type Handler struct {
 	emailRepo     ports.EmailRepository
@@ -22,6 +75,7 @@ type Handler struct {
 	blobStore     ports.BlobStore
 	searchIdx     ports.SearchIndex
 	sieveExecutor ports.SieveExecutor
+	quotas        *services.QuotaService
 	logger        *observability.Logger
 	metrics       *observability.Metrics
 }
@@ -42,6 +96,7 @@ func NewHandler(
 		blobStore:     blobStore,
 		searchIdx:     searchIdx,
 		sieveExecutor: sieveExecutor,
+		quotas:        services.NewQuotaService(userRepo, emailRepo),
 		logger:        logger,
 		metrics:       metrics,
 	}
@@ -97,8 +152,8 @@ func (h *Handler) Handle(session *domain.SMTPSession, rawMessage []byte) error {
 		return fmt.Errorf("failed to parse message: %w", err)
 	}
 
-	// Step 6: Store message atomically (transaction + fsync)
-	sessionLogger.Info("storing message atomically")
+	// Step 6: Store message atomically (transaction + fsync) for each recipient
+	sessionLogger.Info("storing message atomically", "recipients", len(session.Recipients))
 	if err := h.storeMessageAtomic(ctx, session, parsed, rawMessage, spfResult, dkimResult, dmarcResult, dmarcPolicy); err != nil {
 		sessionLogger.Error("failed to store message", "error", err)
 		h.metrics.IncrementStorageErrors()
@@ -109,8 +164,27 @@ func (h *Handler) Handle(session *domain.SMTPSession, rawMessage []byte) error {
 	return nil
 }
 
-// storeMessageAtomic stores message with blob write + DB save and compensating cleanup on failure.
-// Blob storage is non-transactional, so we write blob first and delete it if DB save fails.
+// DeliverLocal stores a message generated by the server itself (e.g. a DSN) for a local
+// recipient, through the same Sieve, quota, indexing and storage accounting as inbound mail
+func (h *Handler) DeliverLocal(ctx context.Context, sender, recipient string, rawMessage []byte) error {
+	parsed, err := mime.ParseMessage(rawMessage)
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	session := &domain.SMTPSession{
+		SessionID:   "local-" + uuid.New().String(),
+		Sender:      sender,
+		Recipients:  []string{recipient},
+		ConnectedAt: time.Now(),
+	}
+	return h.storeMessageAtomic(ctx, session, parsed, rawMessage,
+		validators.SPFNone, validators.DKIMNone, validators.DMARCNone, validators.DMARCPolicyNone)
+}
+
+// storeMessageAtomic writes the message blob once and delivers it to every recipient.
+// Blob storage is non-transactional, so we write blob first and delete it if no recipient stored it.
+// Returns a *DeliveryError if any recipient failed.
 func (h *Handler) storeMessageAtomic(
 	ctx context.Context,
 	session *domain.SMTPSession,
@@ -124,89 +198,116 @@ func (h *Handler) storeMessageAtomic(
 	// Generate message ID
 	messageID := uuid.New().String()
 
-	// Write message body to blob store (with fsync)
+	// Write message body to blob store (with fsync), shared by all recipients
 	h.logger.Info("writing message body to blob store", "message_id", messageID)
 	bodyPath, err := h.blobStore.Write(ctx, messageID, rawMessage)
 	if err != nil {
 		return fmt.Errorf("failed to write blob: %w", err)
 	}
 
-	// Determine Mailbox (Routing via Sieve)
-	targets, err := h.sieveExecutor.Execute(ctx, session.Recipients[0], rawMessage)
-	if err != nil {
-		h.logger.Error("sieve execution failed (fallback to INBOX)", "error", err)
-		targets = []string{"INBOX"}
+	result := &DeliveryError{}
+	stored := 0
+	for _, recipient := range session.Recipients {
+		n, err := h.deliverToRecipient(ctx, session, recipient, parsed, rawMessage, bodyPath, spfResult, dkimResult, dmarcResult, dmarcPolicy)
+		stored += n
+		if err != nil {
+			h.logger.Warn("delivery to recipient failed", "recipient", recipient, "error", err)
+			result.Failed = append(result.Failed, RecipientError{Recipient: recipient, Err: err})
+			continue
+		}
+		result.Delivered = append(result.Delivered, recipient)
 	}
 
-	// Check Quota for Recipient
-	user, err := h.userRepo.FindByEmail(ctx, session.Recipients[0])
-	if err == nil && user.StorageQuota > 0 {
-		requiredSize := int64(len(rawMessage)) * int64(len(targets))
-		if user.StorageUsed+requiredSize > user.StorageQuota {
-			h.logger.Warn("delivery rejected: quota exceeded", "user", user.Email)
-			if delErr := h.blobStore.Delete(ctx, bodyPath); delErr != nil {
-				h.logger.Warn("failed to cleanup blob after quota reject", "error", delErr)
-			}
-			return fmt.Errorf("quota exceeded")
+	// Nothing references the blob (all discarded or failed)
+	if stored == 0 {
+		if delErr := h.blobStore.Delete(ctx, bodyPath); delErr != nil {
+			h.logger.Warn("failed to cleanup unreferenced blob", "error", delErr)
 		}
 	}
 
+	if len(result.Failed) > 0 {
+		return result
+	}
+
+	h.logger.Info("message stored successfully", "message_id", messageID, "recipients", len(result.Delivered))
+	return nil
+}
+
+// deliverToRecipient runs Sieve, checks quota and saves one message row per target mailbox
+// for a single recipient. Returns the number of rows stored.
+func (h *Handler) deliverToRecipient(
+	ctx context.Context,
+	session *domain.SMTPSession,
+	recipient string,
+	parsed *mime.ParsedMessage,
+	rawMessage []byte,
+	bodyPath string,
+	spfResult validators.SPFResult,
+	dkimResult validators.DKIMResult,
+	dmarcResult validators.DMARCResult,
+	dmarcPolicy validators.DMARCPolicy,
+) (int, error) {
+	// Determine Mailbox (Routing via Sieve)
+	targets, err := h.sieveExecutor.Execute(ctx, recipient, rawMessage)
+	if err != nil {
+		h.logger.Error("sieve execution failed (fallback to INBOX)", "recipient", recipient, "error", err)
+		targets = []string{"INBOX"}
+	}
+
 	// Handle Discard
 	if len(targets) == 0 {
-		h.logger.Info("message discarded by sieve", "message_id", messageID)
-		if err := h.blobStore.Delete(ctx, bodyPath); err != nil {
-			h.logger.Warn("failed to cleanup discarded blob", "error", err)
-		}
-		return nil
+		h.logger.Info("message discarded by sieve", "recipient", recipient)
+		return 0, nil
+	}
+
+	// Check the recipient's quota roots: the account, the target mailboxes and the domain
+	err = h.quotas.CheckDelivery(ctx, recipient, targets, int64(len(rawMessage)))
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		h.logger.Warn("delivery rejected: quota exceeded", "user", recipient, "reason", err)
+		return 0, ErrQuotaExceeded
+	}
+	if err != nil && !errors.Is(err, ports.ErrNotFound) {
+		h.logger.Warn("quota check failed", "user", recipient, "error", err)
 	}
 
-	// Save to database for each target mailbox
+	// Save one row per target mailbox, all or none, so a retry cannot duplicate messages
+	msgs := make([]*domain.Message, 0, len(targets))
 	for _, folder := range targets {
-		msg := &domain.Message{
+		msgs = append(msgs, &domain.Message{
+			ID:          uuid.New().String(),
 			MessageID:   parsed.MessageID,
 			Sender:      session.Sender,
-			Recipient:   session.Recipients[0],
+			Recipient:   recipient,
 			Subject:     parsed.Subject,
 			Snippet:     parsed.Snippet,
 			BodyPath:    bodyPath,
+			Size:        int64(len(rawMessage)),
 			ReadState:   false,
 			ReceivedAt:  time.Now(),
 			Mailbox:     folder,
+			SentAt:      parsed.Date,
+			InReplyTo:   parsed.InReplyTo,
+			References:  parsed.References,
 			SPFResult:   string(spfResult),
 			DKIMResult:  string(dkimResult),
 			DMARCResult: string(dmarcResult),
 			DMARCPolicy: string(dmarcPolicy),
-		}
-
-		if len(targets) > 1 {
-			msg.ID = uuid.New().String()
-		} else {
-			msg.ID = messageID
-		}
+		})
+	}
 
-		h.logger.Info("saving message to database", "message_id", msg.ID, "mailbox", folder)
-		h.metrics.IncrementStorageWrites()
-		if err := h.emailRepo.Save(ctx, msg); err != nil {
-			if delErr := h.blobStore.Delete(ctx, bodyPath); delErr != nil {
-				h.logger.Warn("failed to cleanup blob after DB error", "error", delErr)
-			}
-			return fmt.Errorf("failed to save message to %s: %w", folder, err)
-		}
+	h.logger.Info("saving message to database", "recipient", recipient, "mailboxes", targets)
+	h.metrics.IncrementStorageWrites()
+	if err := h.emailRepo.SaveAll(ctx, msgs); err != nil {
+		return 0, fmt.Errorf("failed to save message: %w", err)
+	}
 
+	for _, msg := range msgs {
 		if err := h.searchIdx.Index(ctx, msg, parsed.PlainText); err != nil {
 			h.logger.Warn("failed to index message", "error", err)
 		}
 	}
 
-	h.logger.Info("message stored successfully", "message_id", messageID)
-
-	// Increment storage usage (best effort)
-	if user != nil {
-		//nolint:errcheck
-		_ = h.userRepo.IncrementStorageUsed(ctx, user.Email, int64(len(rawMessage))*int64(len(targets)))
-	}
-
-	return nil
+	return len(msgs), nil
 }
 
 // BuildMiddlewarePipeline creates the complete validation and storage pipeline
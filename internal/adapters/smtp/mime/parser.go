@@ -8,6 +8,7 @@ import (
 	"mime/multipart"
 	"net/mail"
 	"strings"
+	"time"
 )
 
 // ParsedMessage represents a parsed MIME message
@@ -16,6 +17,9 @@ type ParsedMessage struct {
 	To          string
 	Subject     string
 	MessageID   string
+	InReplyTo   string
+	References  string
+	Date        time.Time // Zero if the Date header is missing or unparsable
 	PlainText   string
 	HTML        string
 	Snippet     string
@@ -43,6 +47,12 @@ func ParseMessage(rawMessage []byte) (*ParsedMessage, error) {
 		To:        msg.Header.Get("To"),
 		Subject:   msg.Header.Get("Subject"),
 		MessageID: msg.Header.Get("Message-ID"),
+		InReplyTo: msg.Header.Get("In-Reply-To"),
+		// RFC 5322 Section 3.6.4: References may be folded over several lines
+		References: strings.Join(strings.Fields(msg.Header.Get("References")), " "),
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		parsed.Date = date
 	}
 
 	// RFC 2045 Section 5: Content-Type header
@@ -2,9 +2,46 @@ package smtp
 
 import (
 	"context"
+	"errors"
+	"net/textproto"
 )
 
 // Sender interface allows mocking the SMTP client
 type Sender interface {
 	Send(ctx context.Context, from string, recipient string, data []byte) error
 }
+
+// BatchSender delivers several messages for one recipient domain over a single session,
+// through relay instead of the domain's MX hosts when relay is set.
+// It returns one error (nil on success) per recipient of each envelope, in order.
+type BatchSender interface {
+	SendBatch(ctx context.Context, recipientDomain, relay string, envelopes []*Envelope) [][]error
+}
+
+// LocalDeliverer stores a message in a local recipient's mailbox, e.g. *Handler
+type LocalDeliverer interface {
+	DeliverLocal(ctx context.Context, sender, recipient string, rawMessage []byte) error
+}
+
+// PermanentError marks a delivery failure that must not be retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// IsPermanentFailure reports whether a delivery error is permanent: either
+// explicitly marked, or a 5xx reply from the remote server (RFC 5321 Section 4.2.1)
+func IsPermanentFailure(err error) bool {
+	var permErr *PermanentError
+	if errors.As(err, &permErr) {
+		return true
+	}
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) {
+		return replyErr.Code >= 500 && replyErr.Code < 600
+	}
+	return false
+}
@@ -4,17 +4,23 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 )
 
@@ -28,9 +34,18 @@ type Server struct {
 	handler    MessageHandler
 	spamFilter ports.SpamFilter
 	userRepo   ports.UserRepository
+	tlsConfig  *tls.Config
+	bouncer    *Bouncer
+	quotas     *services.QuotaService
+	retryQueue ports.QueueRepository
+	blobStore  ports.BlobStore
 	listener   net.Listener
 	mu         sync.RWMutex
 	connSem    chan struct{}
+
+	port        int  // Listen port
+	submission  bool // Message submission mode (RFC 6409): AUTH required, any recipient
+	implicitTLS bool // TLS from the first byte (RFC 8314) instead of STARTTLS
 }
 
 // NewServer creates a new SMTP server
@@ -43,24 +58,73 @@ func NewServer(cfg *config.Config, logger *observability.Logger, metrics *observ
 		spamFilter: spamFilter,
 		userRepo:   userRepo,
 		connSem:    make(chan struct{}, maxConcurrentConnections),
+		port:       cfg.SMTP.Port,
 	}
 }
 
+// NewSubmissionServer creates an SMTP submission server (RFC 6409) on the given port.
+// Clients must authenticate over TLS and may only send as themselves.
+// With implicitTLS the listener speaks TLS immediately (RFC 8314), as on port 465.
+func NewSubmissionServer(cfg *config.Config, logger *observability.Logger, metrics *observability.Metrics, handler MessageHandler, userRepo ports.UserRepository, port int, implicitTLS bool) *Server {
+	return &Server{
+		config:      cfg,
+		logger:      logger,
+		metrics:     metrics,
+		handler:     handler,
+		userRepo:    userRepo,
+		connSem:     make(chan struct{}, maxConcurrentConnections),
+		port:        port,
+		submission:  true,
+		implicitTLS: implicitTLS,
+	}
+}
+
+// SetTLSConfig enables STARTTLS (RFC 3207) using the given TLS configuration.
+// Must be called before Start.
+func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// SetBouncer enables non-delivery reports for recipients that failed after
+// the transaction was accepted for others
+func (s *Server) SetBouncer(bouncer *Bouncer) {
+	s.bouncer = bouncer
+}
+
+// SetQuotas makes RCPT refuse recipients whose quota is already exhausted
+func (s *Server) SetQuotas(quotas *services.QuotaService) {
+	s.quotas = quotas
+}
+
+// SetRetryQueue makes recipients that failed temporarily after the message was accepted
+// for others get it through the outbound queue, which retries the delivery
+func (s *Server) SetRetryQueue(queueRepo ports.QueueRepository, blobStore ports.BlobStore) {
+	s.retryQueue = queueRepo
+	s.blobStore = blobStore
+}
+
 // Start begins listening for SMTP connections
 // Implements RFC 5321 - Simple Mail Transfer Protocol
 func (s *Server) Start(ctx context.Context) error {
-	addr := fmt.Sprintf(":%d", s.config.SMTP.Port)
+	addr := fmt.Sprintf(":%d", s.port)
+
+	if s.implicitTLS && s.tlsConfig == nil {
+		return fmt.Errorf("implicit TLS on %s requires a TLS configuration", addr)
+	}
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
+	if s.implicitTLS {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
 
 	s.mu.Lock()
 	s.listener = listener
 	s.mu.Unlock()
 
-	s.logger.Info("SMTP server started", "address", addr)
+	s.logger.Info("SMTP server started", "address", addr, "submission", s.submission, "implicit_tls", s.implicitTLS)
 
 	go func() {
 		<-ctx.Done()
@@ -101,7 +165,8 @@ func (s *Server) Start(ctx context.Context) error {
 
 // handleConnection processes a single SMTP connection
 func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
-	defer conn.Close()
+	// conn is replaced by the TLS connection after STARTTLS
+	defer func() { _ = conn.Close() }()
 	defer func() {
 		if r := recover(); r != nil {
 			s.logger.Error("panic in SMTP connection handler", "error", r, "remote", conn.RemoteAddr())
@@ -124,7 +189,7 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 	sessionLogger := s.logger.WithSMTPSession(sessionID, remoteIP)
 
 	// Check Spam Filter (DNSBL / Rate Limit)
-	if s.spamFilter != nil {
+	if s.spamFilter != nil && !s.submission {
 		if err := s.spamFilter.CheckConnection(ctx, remoteIP); err != nil {
 			sessionLogger.Warn("connection rejected by spam filter", "error", err)
 			// Return 554 No SMTP service here
@@ -143,6 +208,19 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 		ConnectedAt: time.Now(),
 	}
 
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		//nolint:errcheck // Deadline setting is best effort
+		_ = tlsConn.SetDeadline(time.Now().Add(30 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			sessionLogger.Warn("TLS handshake failed", "error", err)
+			s.metrics.IncrementSMTPErrors()
+			return
+		}
+		//nolint:errcheck // Deadline setting is best effort
+		_ = tlsConn.SetDeadline(time.Time{})
+		s.recordTLSState(session, tlsConn.ConnectionState(), sessionLogger)
+	}
+
 	reader := bufio.NewReader(conn)
 	writer := bufio.NewWriter(conn)
 
@@ -178,13 +256,37 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 		case "EHLO", "HELO":
 			s.handleEHLO(writer, args, session, sessionLogger)
 
+		case "STARTTLS":
+			tlsConn, ok := s.handleSTARTTLS(conn, reader, writer, session, sessionLogger)
+			if tlsConn == nil {
+				if ok {
+					continue
+				}
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			writer = bufio.NewWriter(conn)
+
 		case "MAIL":
 			if !strings.HasPrefix(strings.ToUpper(args), "FROM:") {
 				s.send(writer, "501 Syntax error in parameters")
 				continue
 			}
-			sender := extractEmailAddress(strings.TrimPrefix(strings.ToUpper(args), "FROM:"))
+			sender := extractEmailAddress(args[len("FROM:"):])
+			if s.submission {
+				if session.AuthUser == "" {
+					s.send(writer, "530 5.7.0 Authentication required")
+					continue
+				}
+				if sender != session.AuthUser {
+					sessionLogger.Warn("sender not owned by authenticated user", "sender", sender, "user", session.AuthUser)
+					s.send(writer, "553 5.7.1 Sender address not owned by authenticated user")
+					continue
+				}
+			}
 			session.Sender = sender
+			session.DeclaredSize = sizeParameter(args)
 			sessionLogger.Info("mail from", "sender", sender)
 			s.send(writer, "250 OK")
 
@@ -193,15 +295,25 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 				s.send(writer, "501 Syntax error in parameters")
 				continue
 			}
-			recipient := extractEmailAddress(strings.TrimPrefix(strings.ToUpper(args), "TO:"))
+			recipient := extractEmailAddress(args[len("TO:"):])
 
-			// Validate recipient exists locally
-			if s.userRepo != nil {
-				if _, err := s.userRepo.FindByEmail(ctx, recipient); err != nil {
-					sessionLogger.Warn("recipient not found", "recipient", recipient)
-					s.send(writer, "550 No such user - %s", recipient)
+			if s.submission {
+				// Submission relays to any recipient for the authenticated sender
+				if session.Sender == "" {
+					s.send(writer, "503 Bad sequence of commands")
 					continue
 				}
+				session.Recipients = append(session.Recipients, recipient)
+				sessionLogger.Info("rcpt to", "recipient", recipient)
+				s.send(writer, "250 OK")
+				continue
+			}
+
+			// Validate the recipient exists locally and can take the message, so that a
+			// failure is reported now rather than bounced after DATA
+			if reply := s.checkRecipient(ctx, session, recipient, sessionLogger); reply != "" {
+				s.send(writer, "%s", reply)
+				continue
 			}
 
 			// Check Greylisting/Spam for this recipient
@@ -217,6 +329,13 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 			sessionLogger.Info("rcpt to", "recipient", recipient)
 			s.send(writer, "250 OK")
 
+		case "AUTH":
+			if !s.submission {
+				s.send(writer, "502 Command not implemented")
+				continue
+			}
+			s.handleAUTH(ctx, reader, writer, args, session, sessionLogger)
+
 		case "DATA":
 			s.handleDATA(ctx, reader, writer, session, sessionLogger)
 
@@ -228,6 +347,7 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 			// Reset session
 			session.Sender = ""
 			session.Recipients = nil
+			session.DeclaredSize = 0
 			s.send(writer, "250 OK")
 
 		case "NOOP":
@@ -240,15 +360,143 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 	}
 }
 
+// checkRecipient returns the reply refusing a local recipient, or "" to accept it:
+// 550 for an unknown user, 552 when the INBOX quota is exhausted and 451 when
+// the check itself fails
+func (s *Server) checkRecipient(ctx context.Context, session *domain.SMTPSession, recipient string, logger *observability.Logger) string {
+	if s.userRepo == nil {
+		return ""
+	}
+	if _, err := s.userRepo.FindByEmail(ctx, recipient); errors.Is(err, ports.ErrNotFound) {
+		logger.Warn("recipient not found", "recipient", recipient)
+		return fmt.Sprintf("550 5.1.1 No such user - %s", recipient)
+	} else if err != nil {
+		logger.Error("recipient lookup failed", "recipient", recipient, "error", err)
+		return "451 4.3.0 Temporary failure, please try again"
+	}
+
+	if s.quotas == nil {
+		return ""
+	}
+	// Without a SIZE parameter only a quota that is already full can be detected
+	err := s.quotas.CheckStore(ctx, recipient, "INBOX", 1, max(session.DeclaredSize, 1))
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		logger.Warn("recipient over quota", "recipient", recipient, "reason", err)
+		return "552 5.2.2 Mailbox full"
+	}
+	if err != nil {
+		logger.Error("quota check failed", "recipient", recipient, "error", err)
+		return "451 4.3.0 Temporary failure, please try again"
+	}
+	return ""
+}
+
 // handleEHLO responds to EHLO/HELO command
 func (s *Server) handleEHLO(writer *bufio.Writer, args string, session *domain.SMTPSession, logger *observability.Logger) {
 	session.HeloName = args
 	// RFC 5321 Section 4.1.1.1: EHLO response
 	s.send(writer, "250-%s", s.config.SMTP.Hostname)
 	s.send(writer, "250-SIZE %d", s.config.SMTP.MaxSize)
+	// RFC 3207 Section 4: only advertise STARTTLS before the upgrade
+	if s.tlsConfig != nil && !session.TLS {
+		s.send(writer, "250-STARTTLS")
+	}
+	// RFC 4954 Section 4: only offer plaintext mechanisms over TLS
+	if s.submission && session.TLS && session.AuthUser == "" {
+		s.send(writer, "250-AUTH PLAIN LOGIN")
+	}
 	s.send(writer, "250 8BITMIME")
 }
 
+// handleSTARTTLS upgrades the connection to TLS (RFC 3207).
+// Returns the TLS connection on success. On failure it returns nil and
+// whether the session may continue in plaintext.
+func (s *Server) handleSTARTTLS(conn net.Conn, reader *bufio.Reader, writer *bufio.Writer, session *domain.SMTPSession, logger *observability.Logger) (*tls.Conn, bool) {
+	if s.tlsConfig == nil {
+		s.send(writer, "502 Command not implemented")
+		return nil, true
+	}
+	if session.TLS {
+		s.send(writer, "503 TLS already active")
+		return nil, true
+	}
+
+	// Refuse pipelined data after STARTTLS to prevent command injection
+	// into the encrypted session (CVE-2011-0411)
+	if reader.Buffered() > 0 {
+		logger.Warn("rejecting STARTTLS with pipelined data")
+		s.send(writer, "501 Syntax error: no data allowed after STARTTLS")
+		return nil, false
+	}
+
+	s.send(writer, "220 Ready to start TLS")
+
+	tlsConn := tls.Server(conn, s.tlsConfig)
+	//nolint:errcheck // Deadline setting is best effort
+	_ = tlsConn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		logger.Warn("TLS handshake failed", "error", err)
+		s.metrics.IncrementSMTPErrors()
+		return nil, false
+	}
+	//nolint:errcheck // Deadline setting is best effort
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	// RFC 3207 Section 4.2: discard all knowledge obtained from the client
+	session.HeloName = ""
+	session.Sender = ""
+	session.Recipients = nil
+	session.AuthUser = ""
+
+	s.recordTLSState(session, tlsConn.ConnectionState(), logger)
+
+	return tlsConn, true
+}
+
+// recordTLSState stores the negotiated TLS parameters on the session
+func (s *Server) recordTLSState(session *domain.SMTPSession, state tls.ConnectionState, logger *observability.Logger) {
+	session.TLS = true
+	session.TLSVersion = tlsVersionName(state.Version)
+	session.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+	session.TLSServerName = state.ServerName
+
+	s.metrics.IncrementSMTPTLSSessions()
+	logger.Info("TLS established", "version", session.TLSVersion, "cipher", session.TLSCipher, "sni", session.TLSServerName)
+}
+
+// tlsVersionName returns the conventional name of a TLS protocol version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
+
+// receivedHeader builds the trace header prepended to accepted messages (RFC 5321 Section 4.4)
+func (s *Server) receivedHeader(session *domain.SMTPSession) string {
+	// RFC 3848: ESMTP with S (TLS) and/or A (SMTP AUTH) suffixes
+	protocol := "ESMTP"
+	tlsInfo := ""
+	if session.TLS {
+		protocol += "S"
+		tlsInfo = fmt.Sprintf(" (version=%s cipher=%s)", session.TLSVersion, session.TLSCipher)
+	}
+	if session.AuthUser != "" {
+		protocol += "A"
+	}
+	return fmt.Sprintf("Received: from %s ([%s])\r\n\tby %s with %s%s id %s;\r\n\t%s\r\n",
+		session.HeloName, session.RemoteIP, s.config.SMTP.Hostname, protocol, tlsInfo,
+		session.SessionID, time.Now().Format(time.RFC1123Z))
+}
+
 // handleDATA processes the DATA command, streaming to a temp file to avoid memory exhaustion
 func (s *Server) handleDATA(ctx context.Context, reader *bufio.Reader, writer *bufio.Writer, session *domain.SMTPSession, logger *observability.Logger) {
 	if session.Sender == "" || len(session.Recipients) == 0 {
@@ -314,7 +562,7 @@ func (s *Server) handleDATA(ctx context.Context, reader *bufio.Reader, writer *b
 	logger.Info("received message", "size", size)
 
 	// Check Spam Content
-	if s.spamFilter != nil {
+	if s.spamFilter != nil && !s.submission {
 		headers := map[string]string{
 			"IP":       session.RemoteIP,
 			"Helo":     session.HeloName,
@@ -345,13 +593,32 @@ func (s *Server) handleDATA(ctx context.Context, reader *bufio.Reader, writer *b
 		}
 	}
 
+	messageData = append([]byte(s.receivedHeader(session)), messageData...)
+
 	if err := s.handler(session, messageData); err != nil {
+		var derr *DeliveryError
+		if errors.As(err, &derr) && len(derr.Delivered) > 0 {
+			// Some recipients have the message: the transaction succeeded and
+			// must not be retried, otherwise they would receive duplicates.
+			// Permanent failures are bounced, temporary ones retried from the queue.
+			logger.Warn("message delivered to some recipients only", "delivered", len(derr.Delivered), "error", err)
+			permanent, temporary := derr.Split()
+			s.bounceFailedRecipients(ctx, session, messageData, permanent)
+			s.retryFailedRecipients(ctx, session, messageData, temporary, logger)
+			s.metrics.IncrementMessagesReceived()
+			s.send(writer, "250 OK: Message accepted for delivery")
+			return
+		}
+
 		logger.Error("failed to process message", "error", err)
 		s.metrics.IncrementMessagesRejected()
 
-		if strings.Contains(err.Error(), "quota exceeded") {
-			s.send(writer, "552 Message size exceeds fixed maximum")
-		} else {
+		switch {
+		case errors.Is(err, ErrSenderNotOwned):
+			s.send(writer, "550 5.7.1 From header not owned by authenticated user")
+		case derr != nil && derr.Permanent():
+			s.send(writer, "552 5.2.2 Mailbox full")
+		default:
 			s.send(writer, "451 Temporary failure: %v", err)
 		}
 		return
@@ -362,16 +629,101 @@ func (s *Server) handleDATA(ctx context.Context, reader *bufio.Reader, writer *b
 	s.send(writer, "250 OK: Message accepted for delivery")
 }
 
+// bounceFailedRecipients reports recipients that failed permanently after the message was accepted
+func (s *Server) bounceFailedRecipients(ctx context.Context, session *domain.SMTPSession, messageData []byte, failed []RecipientError) {
+	if s.bouncer == nil {
+		return
+	}
+	for _, f := range failed {
+		msg := &domain.OutboundMessage{
+			ID:        session.SessionID,
+			Sender:    session.Sender,
+			Recipient: f.Recipient,
+			CreatedAt: session.ConnectedAt,
+		}
+		diagnostic := "554 5.3.0 " + f.Err.Error()
+		if errors.Is(f.Err, ErrQuotaExceeded) {
+			diagnostic = "552 5.2.2 Mailbox full"
+		}
+		if err := s.bouncer.Notify(ctx, msg, messageData, DSNActionFailed, diagnostic); err != nil {
+			s.logger.Error("failed to send non-delivery report", "recipient", f.Recipient, "error", err)
+		}
+	}
+}
+
+// retryFailedRecipients queues the message for recipients that failed temporarily after it
+// was accepted for others. The delivery worker retries them into their local mailboxes and
+// sends the usual delayed and failed DSNs if they keep failing.
+func (s *Server) retryFailedRecipients(ctx context.Context, session *domain.SMTPSession, messageData []byte, failed []RecipientError, logger *observability.Logger) {
+	if len(failed) == 0 {
+		return
+	}
+	if s.retryQueue == nil {
+		logger.Error("no retry queue, temporarily failed recipients are lost", "recipients", len(failed))
+		return
+	}
+
+	blobID := uuid.New().String()
+	blobPath, err := s.blobStore.Write(ctx, blobID, messageData)
+	if err != nil {
+		logger.Error("failed to store message for retry", "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	msgs := make([]*domain.OutboundMessage, 0, len(failed))
+	for _, f := range failed {
+		msgs = append(msgs, &domain.OutboundMessage{
+			ID:          uuid.New().String(),
+			Sender:      session.Sender,
+			Recipient:   f.Recipient,
+			BlobKey:     blobPath,
+			Status:      domain.QueueStatusRetrying,
+			CreatedAt:   session.ConnectedAt,
+			UpdatedAt:   now,
+			NextRetryAt: now.Add(time.Minute), // First step of the worker's backoff
+			RetryCount:  1,
+			LastError:   f.Err.Error(),
+			Local:       true,
+		})
+	}
+	if err := s.retryQueue.EnqueueAll(ctx, msgs); err != nil {
+		logger.Error("failed to queue recipients for retry", "error", err)
+		//nolint:errcheck // Best effort cleanup
+		_ = s.blobStore.Delete(ctx, blobPath)
+		return
+	}
+	logger.Info("queued temporarily failed recipients for retry", "recipients", len(msgs))
+}
+
+// sizeParameter returns the SIZE parameter (RFC 1870) of a MAIL FROM argument, or 0
+func sizeParameter(args string) int64 {
+	for _, param := range strings.Fields(args)[1:] {
+		if value, ok := strings.CutPrefix(strings.ToUpper(param), "SIZE="); ok {
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+				return size
+			}
+		}
+	}
+	return 0
+}
+
 // send writes a formatted response to the client
 func (s *Server) send(writer *bufio.Writer, format string, args ...interface{}) {
 	fmt.Fprintf(writer, format+"\r\n", args...)
 	writer.Flush()
 }
 
-// extractEmailAddress extracts email from SMTP address format
+// extractEmailAddress extracts email from SMTP address format,
+// dropping any ESMTP parameters (e.g. "<a@b> SIZE=1024")
 func extractEmailAddress(input string) string {
-	// Remove angle brackets and whitespace
 	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, "<") {
+		if end := strings.Index(input, ">"); end != -1 {
+			input = input[1:end]
+		}
+	} else if sp := strings.IndexByte(input, ' '); sp != -1 {
+		input = input[:sp]
+	}
 	input = strings.Trim(input, "<>")
 	return strings.ToLower(input)
 }
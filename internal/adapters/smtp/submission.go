@@ -0,0 +1,138 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// ErrSenderNotOwned is returned when a submitted message's From header
+// does not belong to the authenticated user
+var ErrSenderNotOwned = errors.New("from address not owned by authenticated user")
+
+// submissionSignedHeaders are the headers covered by the DKIM signature when present
+var submissionSignedHeaders = []string{"From", "To", "Cc", "Subject", "Date", "Message-ID", "Reply-To", "In-Reply-To", "References", "Content-Type", "MIME-Version"}
+
+// SubmissionHandler signs messages from authenticated clients and queues them for outbound delivery
+type SubmissionHandler struct {
+	queueRepo ports.QueueRepository
+	blobStore ports.BlobStore
+	signers   *dkim.Resolver
+	logger    *observability.Logger
+	metrics   *observability.Metrics
+}
+
+// NewSubmissionHandler creates a new submission handler.
+// signers selects the DKIM key of the sending domain.
+func NewSubmissionHandler(
+	queueRepo ports.QueueRepository,
+	blobStore ports.BlobStore,
+	signers *dkim.Resolver,
+	logger *observability.Logger,
+	metrics *observability.Metrics,
+) *SubmissionHandler {
+	return &SubmissionHandler{
+		queueRepo: queueRepo,
+		blobStore: blobStore,
+		signers:   signers,
+		logger:    logger,
+		metrics:   metrics,
+	}
+}
+
+// Handle validates, signs and enqueues a submitted message, one queue entry per recipient
+func (h *SubmissionHandler) Handle(session *domain.SMTPSession, rawMessage []byte) error {
+	ctx := context.Background()
+	sessionLogger := h.logger.WithSMTPSession(session.SessionID, session.RemoteIP)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(rawMessage))
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil || !strings.EqualFold(from.Address, session.AuthUser) {
+		sessionLogger.Warn("rejecting submission with foreign From header", "from", msg.Header.Get("From"), "user", session.AuthUser)
+		return ErrSenderNotOwned
+	}
+
+	senderDomain := domainOf(session.AuthUser)
+
+	// RFC 6409 Section 8: complete missing Date and Message-ID
+	var extra strings.Builder
+	if msg.Header.Get("Date") == "" {
+		fmt.Fprintf(&extra, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	}
+	if msg.Header.Get("Message-ID") == "" {
+		fmt.Fprintf(&extra, "Message-ID: <%s@%s>\r\n", uuid.New().String(), senderDomain)
+	}
+	if extra.Len() > 0 {
+		rawMessage = append([]byte(extra.String()), rawMessage...)
+	}
+
+	keys, err := h.signers.SignersFor(ctx, senderDomain)
+	if err != nil {
+		sessionLogger.Warn("failed to load DKIM key", "domain", senderDomain, "error", err)
+	}
+	if len(keys) > 0 {
+		signature, err := keys.Sign(rawMessage, submissionSignedHeaders)
+		if err != nil {
+			return fmt.Errorf("failed to sign message: %w", err)
+		}
+		rawMessage = append([]byte(signature+"\r\n"), rawMessage...)
+	} else {
+		sessionLogger.Warn("no DKIM key for sending domain, sending unsigned", "domain", senderDomain)
+	}
+
+	blobID := uuid.New().String()
+	blobPath, err := h.blobStore.Write(ctx, blobID, rawMessage)
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	// All recipients are queued in one transaction, so that a failure leaves none of them
+	// queued and the client's retry can't duplicate the message
+	now := time.Now().UTC()
+	outMsgs := make([]*domain.OutboundMessage, 0, len(session.Recipients))
+	for _, rcpt := range session.Recipients {
+		outMsgs = append(outMsgs, &domain.OutboundMessage{
+			ID:          uuid.New().String(),
+			Sender:      session.Sender,
+			Recipient:   rcpt,
+			BlobKey:     blobPath,
+			Status:      domain.QueueStatusPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			NextRetryAt: now,
+			RetryCount:  0,
+		})
+	}
+	if err := h.queueRepo.EnqueueAll(ctx, outMsgs); err != nil {
+		return fmt.Errorf("failed to enqueue message: %w", err)
+	}
+	for _, outMsg := range outMsgs {
+		h.metrics.IncrementOutboundEnqueued()
+		sessionLogger.Info("message enqueued", "id", outMsg.ID, "sender", session.Sender, "recipient", outMsg.Recipient)
+	}
+
+	return nil
+}
+
+// domainOf returns the domain part of an email address
+func domainOf(email string) string {
+	if at := strings.LastIndex(email, "@"); at != -1 {
+		return strings.ToLower(email[at+1:])
+	}
+	return ""
+}
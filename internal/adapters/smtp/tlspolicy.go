@@ -0,0 +1,149 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/validators"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+// TLSResultRecorder receives the outcome of every outbound TLS negotiation so it can be
+// aggregated into TLS reports (RFC 8460)
+type TLSResultRecorder interface {
+	RecordTLSResult(ctx context.Context, result domain.TLSSessionResult)
+}
+
+// tlsPolicy is the transport security policy in effect for one recipient domain
+type tlsPolicy struct {
+	domain  string
+	sts     *domain.MTASTSPolicy // nil when the domain publishes no usable MTA-STS policy
+	enforce bool                 // PKIX-valid TLS to a listed MX is mandatory
+}
+
+// allowsMX reports whether delivery to the MX host is permitted by the policy.
+// Policies in testing mode never block delivery.
+func (p *tlsPolicy) allowsMX(host string) bool {
+	if p.sts == nil || p.sts.Mode == domain.MTASTSModeNone {
+		return true
+	}
+	return p.sts.MatchesMX(host)
+}
+
+// result starts a TLS session result for a connection to the given MX host
+func (p *tlsPolicy) result(mxHost, receivingIP string) domain.TLSSessionResult {
+	r := domain.TLSSessionResult{
+		PolicyDomain: p.domain,
+		PolicyType:   domain.TLSPolicyTypeNoPolicy,
+		MXHost:       mxHost,
+		ReceivingIP:  receivingIP,
+		Success:      true,
+		Timestamp:    time.Now().UTC(),
+	}
+	if p.sts != nil && p.sts.Mode != domain.MTASTSModeNone {
+		r.PolicyType = domain.TLSPolicyTypeSTS
+		r.PolicyString = strings.Split(strings.TrimSpace(p.sts.BuildPolicyString()), "\n")
+	}
+	return r
+}
+
+// resolveTLSPolicy discovers the MTA-STS policy for a recipient domain.
+// Discovery failures are reported and delivery proceeds as if no policy existed (RFC 8461 Section 5).
+func (c *Client) resolveTLSPolicy(ctx context.Context, recipientDomain string) *tlsPolicy {
+	p := &tlsPolicy{domain: recipientDomain}
+	if c.mtasts == nil || c.mtastsMode == "off" {
+		return p
+	}
+
+	policy, err := c.mtasts.GetPolicy(ctx, recipientDomain)
+	if err != nil {
+		c.logger.Warn("MTA-STS policy discovery failed", "domain", recipientDomain, "error", err)
+		result := p.result("", "")
+		result.PolicyType = domain.TLSPolicyTypeSTS
+		result.Success = false
+		result.ResultType = domain.TLSResultSTSPolicyFetchError
+		var certErr *tls.CertificateVerificationError
+		switch {
+		case errors.Is(err, validators.ErrMTASTSInvalid):
+			result.ResultType = domain.TLSResultSTSPolicyInvalid
+		case errors.As(err, &certErr):
+			result.ResultType = domain.TLSResultSTSWebPKIInvalid
+		}
+		result.Details = err.Error()
+		c.recordTLSResult(ctx, result)
+		return p
+	}
+
+	p.sts = policy
+	p.enforce = policy != nil && policy.Mode == domain.MTASTSModeEnforce && c.mtastsMode == "enforce"
+	if policy != nil {
+		c.logger.Debug("MTA-STS policy found", "domain", recipientDomain, "mode", policy.Mode, "enforce", p.enforce)
+	}
+	return p
+}
+
+func (c *Client) recordTLSResult(ctx context.Context, result domain.TLSSessionResult) {
+	if c.tlsRecorder != nil {
+		c.tlsRecorder.RecordTLSResult(ctx, result)
+	}
+}
+
+// verifyPKIX checks the peer certificate chain against the system (or configured) roots
+// and the MX hostname. On failure it returns the matching TLS-RPT result type.
+func verifyPKIX(cs tls.ConnectionState, host string, roots *x509.CertPool) (string, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return domain.TLSResultCertificateNotTrusted, errors.New("no peer certificate presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err == nil {
+		return "", nil
+	}
+
+	return pkixResultType(err), err
+}
+
+// pkixResultType maps a certificate verification error to its RFC 8460 result type
+func pkixResultType(err error) string {
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &hostErr):
+		return domain.TLSResultCertificateHostMismatch
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return domain.TLSResultCertificateExpired
+	default:
+		return domain.TLSResultCertificateNotTrusted
+	}
+}
+
+// handshakeResultType returns the RFC 8460 result type of a failed STARTTLS handshake
+func handshakeResultType(err error) string {
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return pkixResultType(verifyErr.Err)
+	}
+	return domain.TLSResultValidationFailure
+}
+
+// mxMismatchResult is the session result for an MX host the MTA-STS policy does not list
+func mxMismatchResult(policy *tlsPolicy, host, receivingIP string) domain.TLSSessionResult {
+	result := policy.result(host, receivingIP)
+	result.Success = false
+	result.ResultType = domain.TLSResultValidationFailure
+	result.Details = "MX host not listed in MTA-STS policy"
+	return result
+}
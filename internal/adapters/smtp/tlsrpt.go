@@ -0,0 +1,412 @@
+package smtp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// tlsReportRetryDays is how many more daily runs retry a report that no rua accepted
+const tlsReportRetryDays = 3
+
+// tlsReportSignedHeaders are the headers covered by the DKIM signature of a mailed report
+var tlsReportSignedHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "TLS-Report-Domain", "TLS-Report-Submitter", "Content-Type", "MIME-Version"}
+
+// tlsReport is the JSON aggregate report format of RFC 8460 Section 4
+type tlsReport struct {
+	OrganizationName string                 `json:"organization-name"`
+	DateRange        domain.DateRange       `json:"date-range"`
+	ContactInfo      string                 `json:"contact-info"`
+	ReportID         string                 `json:"report-id"`
+	Policies         []domain.PolicySummary `json:"policies"`
+}
+
+// TLSReporter records the outcome of outbound TLS sessions and sends daily aggregate
+// reports to recipient domains that publish a TLS-RPT record (RFC 8460)
+type TLSReporter struct {
+	organization string // Reporting organization (organization-name)
+	contactInfo  string // Contact address included in reports
+	submitter    string // Our domain, used in the TLS-Report-Submitter header and report file names
+	repo         ports.TLSRptRepository
+	queueRepo    ports.QueueRepository
+	blobStore    ports.BlobStore
+	signers      *dkim.Resolver
+	logger       *observability.Logger
+
+	// LookupTXT is the function used to look up the _smtp._tls TXT record. Defaults to net.LookupTXT.
+	LookupTXT func(name string) ([]string, error)
+	// HTTPClient posts reports to https rua endpoints
+	HTTPClient *http.Client
+}
+
+// NewTLSReporter creates a TLS reporter. organization and contactInfo default to the submitter domain
+// and its postmaster; reports sent by mail are DKIM-signed with the submitter domain's key.
+func NewTLSReporter(
+	submitter, organization, contactInfo string,
+	repo ports.TLSRptRepository,
+	queueRepo ports.QueueRepository,
+	blobStore ports.BlobStore,
+	signers *dkim.Resolver,
+	logger *observability.Logger,
+) *TLSReporter {
+	if organization == "" {
+		organization = submitter
+	}
+	if contactInfo == "" {
+		contactInfo = "postmaster@" + submitter
+	}
+	return &TLSReporter{
+		organization: organization,
+		contactInfo:  contactInfo,
+		submitter:    submitter,
+		repo:         repo,
+		queueRepo:    queueRepo,
+		blobStore:    blobStore,
+		signers:      signers,
+		logger:       logger,
+		LookupTXT:    net.LookupTXT,
+		HTTPClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// RecordTLSResult stores a session result for the next report
+func (r *TLSReporter) RecordTLSResult(ctx context.Context, result domain.TLSSessionResult) {
+	if err := r.repo.RecordSession(ctx, &result); err != nil {
+		r.logger.Warn("failed to record TLS session result", "domain", result.PolicyDomain, "error", err)
+	}
+}
+
+// Start sends the previous UTC day's reports shortly after every UTC midnight until ctx is cancelled
+func (r *TLSReporter) Start(ctx context.Context) {
+	r.logger.Info("TLS reporter started")
+
+	go func() {
+		for {
+			now := time.Now().UTC()
+			midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+			timer := time.NewTimer(midnight.Sub(now) + 5*time.Minute)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				r.logger.Info("TLS reporter stopped")
+				return
+			case <-timer.C:
+				r.sendDue(ctx, midnight)
+			}
+		}
+	}()
+}
+
+// sendDue sends the reports of the day before midnight, retries those of the previous days
+// that no rua accepted yet, and drops the sessions that are too old to retry
+func (r *TLSReporter) sendDue(ctx context.Context, midnight time.Time) {
+	for days := tlsReportRetryDays; days >= 0; days-- {
+		end := midnight.Add(-time.Duration(days) * 24 * time.Hour)
+		if err := r.SendReports(ctx, end.Add(-24*time.Hour), end); err != nil {
+			r.logger.Error("failed to send TLS reports", "error", err)
+		}
+	}
+
+	expired := midnight.Add(-time.Duration(tlsReportRetryDays+1) * 24 * time.Hour)
+	if err := r.repo.DeleteSessionsBefore(ctx, expired); err != nil {
+		r.logger.Error("failed to prune TLS sessions", "error", err)
+	}
+}
+
+// SendReports aggregates the sessions recorded in [start, end) per policy domain and
+// delivers one report to every rua of each domain. The sessions of a domain are discarded
+// once at least one rua accepted its report, or if the domain has no rua to report to;
+// otherwise they are kept so that a later run can retry.
+func (r *TLSReporter) SendReports(ctx context.Context, start, end time.Time) error {
+	results, err := r.repo.ListSessions(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to list TLS sessions: %w", err)
+	}
+
+	byDomain := make(map[string][]*domain.TLSSessionResult)
+	for _, res := range results {
+		byDomain[res.PolicyDomain] = append(byDomain[res.PolicyDomain], res)
+	}
+
+	for policyDomain, sessions := range byDomain {
+		if ruas := r.lookupRUA(policyDomain); len(ruas) > 0 && !r.sendReport(ctx, policyDomain, ruas, start, end, sessions) {
+			r.logger.Warn("TLS report not accepted by any rua, keeping it for the next run", "domain", policyDomain)
+			continue
+		}
+		if err := r.repo.DeleteSessions(ctx, policyDomain, start, end); err != nil {
+			return fmt.Errorf("failed to prune TLS sessions: %w", err)
+		}
+	}
+	return nil
+}
+
+// sendReport delivers the report of a domain to each of its ruas and reports whether at
+// least one of them accepted it
+func (r *TLSReporter) sendReport(ctx context.Context, policyDomain string, ruas []string, start, end time.Time, sessions []*domain.TLSSessionResult) bool {
+	report := r.buildReport(policyDomain, start, end, sessions)
+	payload, err := gzipReport(report)
+	if err != nil {
+		r.logger.Error("failed to encode TLS report", "domain", policyDomain, "error", err)
+		return false
+	}
+
+	accepted := false
+	for _, rua := range ruas {
+		if err := r.deliver(ctx, rua, policyDomain, report, start, end, payload); err != nil {
+			r.logger.Warn("failed to deliver TLS report", "domain", policyDomain, "rua", rua, "error", err)
+			continue
+		}
+		accepted = true
+		r.logger.Info("TLS report sent", "domain", policyDomain, "rua", rua, "report_id", report.ReportID)
+	}
+	return accepted
+}
+
+// lookupRUA returns the report URIs of the domain's TLS-RPT record (RFC 8460 Section 3)
+func (r *TLSReporter) lookupRUA(policyDomain string) []string {
+	records, err := r.LookupTXT("_smtp._tls." + policyDomain)
+	if err != nil {
+		return nil
+	}
+
+	var ruas []string
+	count := 0
+	for _, record := range records {
+		if !strings.HasPrefix(record, "v=TLSRPTv1") {
+			continue
+		}
+		count++
+		for _, field := range strings.Split(record, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok || key != "rua" {
+				continue
+			}
+			for _, uri := range strings.Split(value, ",") {
+				if uri = strings.TrimSpace(uri); uri != "" {
+					ruas = append(ruas, uri)
+				}
+			}
+		}
+	}
+
+	// Multiple records are invalid; ignore the domain rather than guess
+	if count != 1 {
+		return nil
+	}
+	return ruas
+}
+
+// buildReport aggregates sessions into one policy summary per distinct policy
+func (r *TLSReporter) buildReport(policyDomain string, start, end time.Time, sessions []*domain.TLSSessionResult) *tlsReport {
+	type failureKey struct {
+		resultType, sendingIP, mxHost, receivingIP, details string
+	}
+
+	summaries := make(map[string]*domain.PolicySummary)
+	failures := make(map[string]map[failureKey]int64)
+	var keys []string
+
+	for _, s := range sessions {
+		key := s.PolicyType + "\x00" + strings.Join(s.PolicyString, "\n")
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &domain.PolicySummary{
+				Policy: domain.PolicyDetails{
+					PolicyType:   s.PolicyType,
+					PolicyString: s.PolicyString,
+					PolicyDomain: policyDomain,
+				},
+			}
+			if s.PolicyType == domain.TLSPolicyTypeSTS {
+				for _, line := range s.PolicyString {
+					if mx, found := strings.CutPrefix(line, "mx: "); found {
+						summary.Policy.MXHost = append(summary.Policy.MXHost, mx)
+					}
+				}
+			}
+			summaries[key] = summary
+			failures[key] = make(map[failureKey]int64)
+			keys = append(keys, key)
+		}
+
+		if s.Success {
+			summary.Summary.TotalSuccessfulSessionCount++
+			continue
+		}
+		summary.Summary.TotalFailureSessionCount++
+		failures[key][failureKey{s.ResultType, s.SendingIP, s.MXHost, s.ReceivingIP, s.Details}]++
+	}
+
+	report := &tlsReport{
+		OrganizationName: r.organization,
+		DateRange: domain.DateRange{
+			StartDatetime: start.UTC(),
+			EndDatetime:   end.UTC(),
+		},
+		ContactInfo: r.contactInfo,
+		ReportID:    uuid.New().String(),
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		summary := summaries[key]
+		for fk, count := range failures[key] {
+			summary.FailureDetails = append(summary.FailureDetails, domain.FailureDetails{
+				ResultType:            fk.resultType,
+				SendingMTAIP:          fk.sendingIP,
+				ReceivingMXHostname:   fk.mxHost,
+				ReceivingIP:           fk.receivingIP,
+				FailedSessionCount:    count,
+				AdditionalInformation: fk.details,
+			})
+		}
+		sort.Slice(summary.FailureDetails, func(i, j int) bool {
+			return summary.FailureDetails[i].FailedSessionCount > summary.FailureDetails[j].FailedSessionCount
+		})
+		report.Policies = append(report.Policies, *summary)
+	}
+
+	return report
+}
+
+func gzipReport(report *tlsReport) ([]byte, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver sends a gzipped report to one rua URI (RFC 8460 Section 5)
+func (r *TLSReporter) deliver(ctx context.Context, rua, policyDomain string, report *tlsReport, start, end time.Time, payload []byte) error {
+	switch {
+	case strings.HasPrefix(rua, "mailto:"):
+		to := strings.TrimPrefix(rua, "mailto:")
+		if idx := strings.Index(to, "?"); idx != -1 {
+			to = to[:idx]
+		}
+		return r.mailReport(ctx, to, policyDomain, report, start, end, payload)
+	case strings.HasPrefix(rua, "https:"):
+		return r.postReport(ctx, rua, payload)
+	default:
+		return fmt.Errorf("unsupported rua scheme")
+	}
+}
+
+func (r *TLSReporter) postReport(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/tlsrpt+gzip")
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// mailReport queues the report as a multipart/report message (RFC 8460 Section 5.3)
+func (r *TLSReporter) mailReport(ctx context.Context, to, policyDomain string, report *tlsReport, start, end time.Time, payload []byte) error {
+	boundary := "tlsrpt-" + uuid.New().String()
+	from := "noreply-smtp-tls-reporting@" + r.submitter
+	filename := fmt.Sprintf("%s!%s!%d!%d!%s.json.gz", r.submitter, policyDomain, start.Unix(), end.Unix(), report.ReportID)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: Report Domain: %s Submitter: %s Report-ID: <%s>\r\n", policyDomain, r.submitter, report.ReportID)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", report.ReportID, r.submitter)
+	fmt.Fprintf(&b, "TLS-Report-Domain: %s\r\n", policyDomain)
+	fmt.Fprintf(&b, "TLS-Report-Submitter: %s\r\n", r.submitter)
+	b.WriteString("Auto-Submitted: auto-generated\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/report; report-type=\"tlsrpt\"; boundary=\"%s\"\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "This is an aggregate TLS report from %s for %s covering %s to %s.\r\n\r\n",
+		r.organization, policyDomain, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/tlsrpt+gzip\r\n")
+	fmt.Fprintf(&b, "Content-Disposition: attachment; filename=\"%s\"\r\n", filename)
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	message := b.Bytes()
+	keys, err := r.signers.SignersFor(ctx, r.submitter)
+	if err != nil {
+		r.logger.Warn("failed to load DKIM key for TLS report", "domain", r.submitter, "error", err)
+	}
+	if len(keys) > 0 {
+		signature, err := keys.Sign(message, tlsReportSignedHeaders)
+		if err != nil {
+			return fmt.Errorf("failed to sign report: %w", err)
+		}
+		message = append([]byte(signature+"\r\n"), message...)
+	}
+
+	id := uuid.New().String()
+	blobPath, err := r.blobStore.Write(ctx, id, message)
+	if err != nil {
+		return fmt.Errorf("failed to write report blob: %w", err)
+	}
+
+	// Reports use the null reverse-path so a failed report never generates a bounce
+	now := time.Now().UTC()
+	msg := &domain.OutboundMessage{
+		ID:          id,
+		Sender:      "",
+		Recipient:   to,
+		BlobKey:     blobPath,
+		Status:      domain.QueueStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextRetryAt: now,
+	}
+	if err := r.queueRepo.Enqueue(ctx, msg); err != nil {
+		//nolint:errcheck // Best effort cleanup
+		_ = r.blobStore.Delete(ctx, blobPath)
+		return fmt.Errorf("failed to enqueue report: %w", err)
+	}
+	return nil
+}
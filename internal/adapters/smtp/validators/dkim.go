@@ -3,6 +3,7 @@ package validators
 import (
 	"context"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
@@ -23,13 +24,35 @@ const (
 	DKIMPermError DKIMResult = "permerror"
 )
 
-// VerifyDKIM validates DKIM signature in email message per RFC 6376
+// lookupTXT resolves DKIM key records; replaced in tests
+var lookupTXT = net.LookupTXT
+
+// VerifyDKIM validates the DKIM signatures in an email message per RFC 6376 and RFC 8463.
+// The message passes if any signature verifies (e.g. either half of an RSA/Ed25519 pair);
+// otherwise the result of the first signature is returned.
 func VerifyDKIM(_ context.Context, rawMessage []byte) (DKIMResult, string, error) {
-	sig := extractDKIMSignature(rawMessage)
-	if sig == "" {
+	sigs := extractDKIMSignatures(rawMessage)
+	if len(sigs) == 0 {
 		return DKIMNone, "", nil
 	}
 
+	var firstResult DKIMResult
+	var firstDomain string
+	var firstErr error
+	for i, sig := range sigs {
+		result, domain, err := verifyDKIMSignature(rawMessage, sig)
+		if result == DKIMPass {
+			return result, domain, nil
+		}
+		if i == 0 {
+			firstResult, firstDomain, firstErr = result, domain, err
+		}
+	}
+	return firstResult, firstDomain, firstErr
+}
+
+// verifyDKIMSignature checks one DKIM-Signature header value
+func verifyDKIMSignature(rawMessage []byte, sig string) (DKIMResult, string, error) {
 	params := parseDKIMParams(sig)
 
 	domain, ok := params["d"]
@@ -52,6 +75,16 @@ func VerifyDKIM(_ context.Context, rawMessage []byte) (DKIMResult, string, error
 		return DKIMPermError, "", fmt.Errorf("missing signature (b=) tag")
 	}
 
+	keyType := ""
+	switch params["a"] {
+	case "rsa-sha256", "":
+		keyType = "rsa"
+	case "ed25519-sha256":
+		keyType = "ed25519"
+	default:
+		return DKIMPermError, domain, fmt.Errorf("unsupported signing algorithm %q", params["a"])
+	}
+
 	headersToVerify := strings.Split(params["h"], ":")
 	for i := range headersToVerify {
 		headersToVerify[i] = strings.TrimSpace(headersToVerify[i])
@@ -73,7 +106,7 @@ func VerifyDKIM(_ context.Context, rawMessage []byte) (DKIMResult, string, error
 
 	// DNS lookup for public key
 	dkimRecord := fmt.Sprintf("%s._domainkey.%s", selector, domain)
-	txtRecords, err := net.LookupTXT(dkimRecord)
+	txtRecords, err := lookupTXT(dkimRecord)
 	if err != nil {
 		return DKIMTempError, domain, fmt.Errorf("DNS lookup failed: %w", err)
 	}
@@ -81,7 +114,7 @@ func VerifyDKIM(_ context.Context, rawMessage []byte) (DKIMResult, string, error
 		return DKIMFail, domain, fmt.Errorf("no DKIM record found")
 	}
 
-	publicKey, err := parseDKIMPublicKey(strings.Join(txtRecords, ""))
+	publicKey, err := parseDKIMPublicKey(strings.Join(txtRecords, ""), keyType)
 	if err != nil {
 		return DKIMPermError, domain, fmt.Errorf("failed to parse public key: %w", err)
 	}
@@ -111,7 +144,15 @@ func VerifyDKIM(_ context.Context, rawMessage []byte) (DKIMResult, string, error
 
 	headerHash := canonicalizeAndHashHeaders(rawMessage, headersToVerify, sig, headerCanon)
 
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, headerHash, signatureBytes)
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, headerHash, signatureBytes)
+	case ed25519.PublicKey:
+		// RFC 8463 Section 3: the SHA-256 hash is signed with PureEdDSA
+		if !ed25519.Verify(key, headerHash, signatureBytes) {
+			err = fmt.Errorf("ed25519 signature mismatch")
+		}
+	}
 	if err != nil {
 		return DKIMFail, domain, fmt.Errorf("signature verification failed: %w", err)
 	}
@@ -269,28 +310,34 @@ func parseHeaders(headerSection string) map[string][]string {
 	return result
 }
 
-func extractDKIMSignature(rawMessage []byte) string {
+// extractDKIMSignatures returns the values of all DKIM-Signature headers, topmost first
+func extractDKIMSignatures(rawMessage []byte) []string {
 	headerSection, _ := splitHeaderBody(rawMessage)
 	lines := strings.Split(headerSection, "\r\n")
 
+	var sigs []string
 	var sigValue strings.Builder
 	inSig := false
 
 	for _, line := range lines {
-		if strings.HasPrefix(strings.ToLower(line), "dkim-signature:") {
-			inSig = true
-			sigValue.WriteString(strings.TrimPrefix(line, line[:strings.IndexByte(line, ':')+1]))
-			continue
-		}
 		if inSig {
 			if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
 				sigValue.WriteString(line)
-			} else {
-				break
+				continue
 			}
+			sigs = append(sigs, sigValue.String())
+			sigValue.Reset()
+			inSig = false
+		}
+		if strings.HasPrefix(strings.ToLower(line), "dkim-signature:") {
+			inSig = true
+			sigValue.WriteString(line[strings.IndexByte(line, ':')+1:])
 		}
 	}
-	return sigValue.String()
+	if inSig {
+		sigs = append(sigs, sigValue.String())
+	}
+	return sigs
 }
 
 func parseDKIMParams(signature string) map[string]string {
@@ -311,19 +358,39 @@ func parseDKIMParams(signature string) map[string]string {
 	return params
 }
 
-func parseDKIMPublicKey(record string) (*rsa.PublicKey, error) {
+// parseDKIMPublicKey decodes the p= tag of a key record. RSA keys are a DER SubjectPublicKeyInfo
+// (or bare RSAPublicKey); Ed25519 keys are the raw 32 bytes (RFC 8463 Section 4).
+func parseDKIMPublicKey(record, keyType string) (crypto.PublicKey, error) {
 	params := parseDKIMParams(record)
 
+	if k := params["k"]; k != "" && k != keyType {
+		return nil, fmt.Errorf("key type %q does not match signature algorithm", k)
+	}
+
 	publicKeyB64, ok := params["p"]
 	if !ok {
 		return nil, fmt.Errorf("no public key (p=) in DNS record")
 	}
+	if publicKeyB64 == "" {
+		return nil, fmt.Errorf("key has been revoked")
+	}
 
-	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyB64)
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(publicKeyB64), ""))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode public key: %w", err)
 	}
 
+	if keyType == "ed25519" {
+		if len(publicKeyBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 public key has %d bytes", len(publicKeyBytes))
+		}
+		return ed25519.PublicKey(publicKeyBytes), nil
+	}
+
+	if publicKey, err := x509.ParsePKCS1PublicKey(publicKeyBytes); err == nil {
+		return publicKey, nil
+	}
+
 	publicKeyInterface, err := x509.ParsePKIXPublicKey(publicKeyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
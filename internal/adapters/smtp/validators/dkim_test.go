@@ -0,0 +1,66 @@
+package validators
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDKIM_DualSignature(t *testing.T) {
+	records := map[string][]string{}
+	var signers dkim.Signers
+	for _, k := range []struct{ selector, algorithm string }{
+		{"rsa1", domain.DKIMAlgorithmRSA},
+		{"ed1", domain.DKIMAlgorithmEd25519},
+	} {
+		privPEM, pub, err := dkim.GenerateKey(k.algorithm)
+		require.NoError(t, err)
+		key, err := dkim.ParsePrivateKey([]byte(privPEM))
+		require.NoError(t, err)
+		signers = append(signers, dkim.NewSigner("example.com", k.selector, key))
+		records[k.selector+"._domainkey.example.com"] = []string{"v=DKIM1; k=" + k.algorithm + "; p=" + pub}
+	}
+
+	origLookup := lookupTXT
+	defer func() { lookupTXT = origLookup }()
+	lookupTXT = func(name string) ([]string, error) {
+		if r, ok := records[name]; ok {
+			return r, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	message := []byte("From: alice@example.com\r\nTo: bob@remote.test\r\nSubject: Hello\r\n\r\nHi Bob\r\n")
+	headers, err := signers.Sign(message, []string{"From", "To", "Subject"})
+	require.NoError(t, err)
+	signed := []byte(headers + "\r\n" + string(message))
+	assert.Contains(t, headers, "a=ed25519-sha256;")
+
+	result, d, err := VerifyDKIM(context.Background(), signed)
+	assert.NoError(t, err)
+	assert.Equal(t, DKIMPass, result)
+	assert.Equal(t, "example.com", d)
+
+	// Each signature verifies on its own (RFC 8463 Section 3)
+	for _, sig := range extractDKIMSignatures(signed) {
+		result, _, err := verifyDKIMSignature(signed, sig)
+		assert.NoError(t, err)
+		assert.Equal(t, DKIMPass, result, sig)
+	}
+
+	// One valid signature is enough when the other key is unavailable
+	delete(records, "rsa1._domainkey.example.com")
+	result, _, _ = VerifyDKIM(context.Background(), signed)
+	assert.Equal(t, DKIMPass, result)
+
+	tampered := []byte(strings.Replace(string(signed), "Subject: Hello", "Subject: Hijacked", 1))
+	result, _, err = VerifyDKIM(context.Background(), tampered)
+	assert.Error(t, err)
+	assert.NotEqual(t, DKIMPass, result)
+}
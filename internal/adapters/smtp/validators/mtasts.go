@@ -0,0 +1,180 @@
+package validators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+)
+
+var (
+	// ErrMTASTSFetch is returned when a domain advertises a policy that cannot be retrieved
+	ErrMTASTSFetch = errors.New("mta-sts policy fetch failed")
+	// ErrMTASTSInvalid is returned when a retrieved policy cannot be parsed
+	ErrMTASTSInvalid = errors.New("mta-sts policy invalid")
+)
+
+// maxPolicySize bounds the policy body we are willing to read (RFC 8461 Section 3.3 suggests 64KB)
+const maxPolicySize = 64 * 1024
+
+type cachedPolicy struct {
+	id      string
+	policy  *domain.MTASTSPolicy
+	expires time.Time
+}
+
+// MTASTSValidator discovers, fetches and caches MTA-STS policies of remote domains (RFC 8461)
+type MTASTSValidator struct {
+	// LookupTXT is the function used to look up the _mta-sts TXT record. Defaults to net.LookupTXT.
+	LookupTXT func(name string) ([]string, error)
+	// HTTPClient fetches the policy file. Redirects are not followed (RFC 8461 Section 3.3).
+	HTTPClient *http.Client
+	// PolicyURL builds the policy location for a domain
+	PolicyURL func(domain string) string
+
+	mu    sync.Mutex
+	cache map[string]*cachedPolicy
+	now   func() time.Time
+}
+
+// NewMTASTSValidator creates a validator with an empty policy cache
+func NewMTASTSValidator() *MTASTSValidator {
+	return &MTASTSValidator{
+		LookupTXT: net.LookupTXT,
+		HTTPClient: &http.Client{
+			Timeout: 60 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		PolicyURL: func(domain string) string {
+			return fmt.Sprintf("https://mta-sts.%s/.well-known/mta-sts.txt", domain)
+		},
+		cache: make(map[string]*cachedPolicy),
+		now:   time.Now,
+	}
+}
+
+// GetPolicy returns the MTA-STS policy for a recipient domain, or nil if the domain has none.
+// A cached policy is reused while its TXT id is unchanged and it has not expired; if discovery
+// or refresh fails, an unexpired cached policy stays in effect (RFC 8461 Section 5.1).
+func (v *MTASTSValidator) GetPolicy(ctx context.Context, policyDomain string) (*domain.MTASTSPolicy, error) {
+	policyDomain = strings.ToLower(strings.TrimSuffix(policyDomain, "."))
+	cached := v.cached(policyDomain)
+
+	id, found := v.lookupPolicyID(policyDomain)
+	if !found {
+		if cached != nil {
+			return cached.policy, nil
+		}
+		return nil, nil
+	}
+
+	if cached != nil && cached.id == id {
+		return cached.policy, nil
+	}
+
+	policy, err := v.fetchPolicy(ctx, policyDomain)
+	if err != nil {
+		if cached != nil {
+			return cached.policy, nil
+		}
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.cache[policyDomain] = &cachedPolicy{
+		id:      id,
+		policy:  policy,
+		expires: v.now().Add(time.Duration(policy.MaxAge) * time.Second),
+	}
+	v.mu.Unlock()
+
+	return policy, nil
+}
+
+func (v *MTASTSValidator) cached(policyDomain string) *cachedPolicy {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.cache[policyDomain]
+	if !ok {
+		return nil
+	}
+	if v.now().After(entry.expires) {
+		delete(v.cache, policyDomain)
+		return nil
+	}
+	return entry
+}
+
+// lookupPolicyID returns the id of the domain's single STSv1 TXT record.
+// Zero or multiple records mean the domain does not (validly) publish a policy.
+func (v *MTASTSValidator) lookupPolicyID(policyDomain string) (string, bool) {
+	records, err := v.LookupTXT("_mta-sts." + policyDomain)
+	if err != nil {
+		return "", false
+	}
+
+	var id string
+	count := 0
+	for _, record := range records {
+		if !strings.HasPrefix(record, "v=STSv1") {
+			continue
+		}
+		count++
+		for _, field := range strings.Split(record, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if ok && key == "id" {
+				id = value
+			}
+		}
+	}
+
+	if count != 1 || id == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func (v *MTASTSValidator) fetchPolicy(ctx context.Context, policyDomain string) (*domain.MTASTSPolicy, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.PolicyURL(policyDomain), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMTASTSFetch, err)
+	}
+
+	resp, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMTASTSFetch, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrMTASTSFetch, resp.StatusCode)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return nil, fmt.Errorf("%w: unexpected content type %q", ErrMTASTSInvalid, resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMTASTSFetch, err)
+	}
+	if len(body) > maxPolicySize {
+		return nil, fmt.Errorf("%w: policy exceeds %d bytes", ErrMTASTSInvalid, maxPolicySize)
+	}
+
+	policy, err := domain.ParseMTASTSPolicy(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMTASTSInvalid, err)
+	}
+	return policy, nil
+}
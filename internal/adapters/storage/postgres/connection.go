@@ -3,6 +3,10 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -32,15 +36,36 @@ func NewConnection(dsn string) (*Connection, error) {
 	return &Connection{DB: db}, nil
 }
 
-// RunMigrations applies SQL migration files from the embedded filesystem
+// RunMigrations applies the embedded *.up.sql files that have not been applied yet, in order.
+// Applied versions are recorded in schema_migrations; each file runs in its own transaction
+// together with its version row, so a failed migration is retried on the next startup.
 func (c *Connection) RunMigrations() error {
-	content, err := MigrationsFS.ReadFile("migrations/000001_init.up.sql")
+	if _, err := c.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	files, err := fs.Glob(MigrationsFS, "migrations/*.up.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+		return fmt.Errorf("failed to list migration files: %w", err)
 	}
+	sort.Strings(files)
 
-	query := string(content)
+	for _, file := range files {
+		version := strings.TrimSuffix(path.Base(file), ".up.sql")
+		if err := c.applyMigration(file, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// applyMigration runs one migration file unless its version is already recorded.
+// The table lock serializes instances starting at the same time.
+func (c *Connection) applyMigration(file, version string) error {
 	tx, err := c.DB.Begin()
 	if err != nil {
 		return err
@@ -49,8 +74,26 @@ func (c *Connection) RunMigrations() error {
 		_ = tx.Rollback()
 	}()
 
-	if _, err := tx.Exec(query); err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+	if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+	var applied bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to check migration %s: %w", version, err)
+	}
+	if applied {
+		return nil
+	}
+
+	content, err := MigrationsFS.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read migration file %s: %w", file, err)
+	}
+	if _, err := tx.Exec(string(content)); err != nil {
+		return fmt.Errorf("failed to execute migration %s: %w", file, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
 	}
 
 	return tx.Commit()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 
@@ -19,12 +20,40 @@ func NewDomainRepository(db *sql.DB) *DomainRepository {
 	return &DomainRepository{db: db}
 }
 
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 func (r *DomainRepository) Create(ctx context.Context, d *domain.Domain) error {
+	return insertDomain(ctx, r.db, d)
+}
+
+// CreateWithDKIMKeys adds a domain and its DKIM keys in one transaction
+func (r *DomainRepository) CreateWithDKIMKeys(ctx context.Context, d *domain.Domain, keys []*domain.DKIMKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := insertDomain(ctx, tx, d); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := insertDKIMKey(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertDomain(ctx context.Context, db execer, d *domain.Domain) error {
 	query := `
 		INSERT INTO domains (name, created_at, updated_at, active, dkim_selector, dkim_private_key, dkim_public_key)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		d.Name,
 		d.CreatedAt,
 		d.UpdatedAt,
@@ -139,7 +168,9 @@ func (r *DomainRepository) Delete(ctx context.Context, name string) error {
 	if rows == 0 {
 		return ports.ErrNotFound
 	}
-	return nil
+
+	_, err = r.db.ExecContext(ctx, `DELETE FROM dkim_keys WHERE domain = $1`, name)
+	return err
 }
 
 func (r *DomainRepository) Exists(ctx context.Context, name string) (bool, error) {
@@ -151,3 +182,145 @@ func (r *DomainRepository) Exists(ctx context.Context, name string) (bool, error
 	}
 	return exists, nil
 }
+
+// dkimKeyColumns is the column list scanned by queryDKIMKeys
+const dkimKeyColumns = `domain, selector, algorithm, private_key, public_key, status, created_at, verified_at, activated_at, retire_at`
+
+func (r *DomainRepository) CreateDKIMKey(ctx context.Context, key *domain.DKIMKey) error {
+	return insertDKIMKey(ctx, r.db, key)
+}
+
+func insertDKIMKey(ctx context.Context, db execer, key *domain.DKIMKey) error {
+	query := `INSERT INTO dkim_keys (` + dkimKeyColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := db.ExecContext(ctx, query,
+		key.Domain,
+		key.Selector,
+		key.Algorithm,
+		key.PrivateKey,
+		key.PublicKey,
+		key.Status,
+		key.CreatedAt,
+		nullTime(key.VerifiedAt),
+		nullTime(key.ActivatedAt),
+		nullTime(key.RetireAt),
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return ports.ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *DomainRepository) ListDKIMKeys(ctx context.Context, domainName string) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + ` FROM dkim_keys WHERE domain = $1 ORDER BY created_at ASC, selector ASC`
+	return r.queryDKIMKeys(ctx, query, domainName)
+}
+
+func (r *DomainRepository) ListDKIMKeysByStatus(ctx context.Context, status domain.DKIMKeyStatus) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + ` FROM dkim_keys WHERE status = $1 ORDER BY domain ASC, created_at ASC`
+	return r.queryDKIMKeys(ctx, query, status)
+}
+
+func (r *DomainRepository) UpdateDKIMKey(ctx context.Context, key *domain.DKIMKey) error {
+	return updateDKIMKey(ctx, r.db, key)
+}
+
+// UpdateDKIMKeys saves several DKIM keys in one transaction
+func (r *DomainRepository) UpdateDKIMKeys(ctx context.Context, keys []*domain.DKIMKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, key := range keys {
+		if err := updateDKIMKey(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func updateDKIMKey(ctx context.Context, db execer, key *domain.DKIMKey) error {
+	query := `
+		UPDATE dkim_keys
+		SET private_key = $1, status = $2, verified_at = $3, activated_at = $4, retire_at = $5
+		WHERE domain = $6 AND selector = $7
+	`
+	result, err := db.ExecContext(ctx, query,
+		key.PrivateKey,
+		key.Status,
+		nullTime(key.VerifiedAt),
+		nullTime(key.ActivatedAt),
+		nullTime(key.RetireAt),
+		key.Domain,
+		key.Selector,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+func (r *DomainRepository) DeleteDKIMKey(ctx context.Context, domainName, selector string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM dkim_keys WHERE domain = $1 AND selector = $2`, domainName, selector)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+func (r *DomainRepository) queryDKIMKeys(ctx context.Context, query string, args ...interface{}) ([]*domain.DKIMKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.DKIMKey
+	for rows.Next() {
+		var k domain.DKIMKey
+		var verifiedAt, activatedAt, retireAt sql.NullTime
+		if err := rows.Scan(
+			&k.Domain,
+			&k.Selector,
+			&k.Algorithm,
+			&k.PrivateKey,
+			&k.PublicKey,
+			&k.Status,
+			&k.CreatedAt,
+			&verifiedAt,
+			&activatedAt,
+			&retireAt,
+		); err != nil {
+			return nil, err
+		}
+		k.VerifiedAt = verifiedAt.Time
+		k.ActivatedAt = activatedAt.Time
+		k.RetireAt = retireAt.Time
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+// nullTime stores an unset time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
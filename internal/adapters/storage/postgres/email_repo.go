@@ -3,12 +3,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/google/uuid"
 )
 
 // EmailRepository implements ports.EmailRepository using PostgreSQL
@@ -24,44 +26,66 @@ func NewEmailRepository(db *sql.DB, notificationBus ports.NotificationBus) *Emai
 
 // Save stores a new message (atomic with transaction)
 func (r *EmailRepository) Save(ctx context.Context, msg *domain.Message) error {
-	query := `
-		INSERT INTO messages (
-			id, message_id, sender, recipient, subject, snippet, body_path,
-			read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-			mailbox, uid, flags, modseq, is_starred
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
-	`
+	return r.SaveAll(ctx, []*domain.Message{msg})
+}
 
-	_, err := r.db.ExecContext(ctx, query,
-		msg.ID, msg.MessageID, msg.Sender, msg.Recipient, msg.Subject, msg.Snippet,
-		msg.BodyPath, msg.ReadState, msg.ReceivedAt, msg.SPFResult, msg.DKIMResult,
-		msg.DMARCResult, msg.DMARCPolicy,
-		msg.Mailbox, msg.UID, msg.Flags, msg.ModSeq, msg.IsStarred,
-	)
+// SaveAll stores several new messages in a single transaction
+func (r *EmailRepository) SaveAll(ctx context.Context, msgs []*domain.Message) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, msg := range msgs {
+		if err := saveMessage(ctx, tx, msg); err != nil {
+			return ports.ErrStorageFailure
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
 
+	for _, msg := range msgs {
+		r.notify(ctx, msg.Recipient, msg.Mailbox, "new_message", msg.ID)
+	}
 	return nil
 }
 
+// notify publishes a mailbox change event (cross-instance via NotificationBus)
+func (r *EmailRepository) notify(ctx context.Context, userID, mailbox, eventType, messageID string) {
+	if r.notificationBus == nil {
+		return
+	}
+	_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+		UserID:    userID,
+		Mailbox:   mailbox,
+		EventType: eventType,
+		MessageID: messageID,
+	})
+}
+
 // FindByID retrieves a single message by ID
 func (r *EmailRepository) FindByID(ctx context.Context, id string) (*domain.Message, error) {
 	query := `
 		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
 		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-		       mailbox, uid, flags, modseq, is_starred
+		       mailbox, uid, flags, modseq, is_starred, size,
+		       sent_at, in_reply_to, message_references
 		FROM messages
 		WHERE id = $1
 	`
 
 	msg := &domain.Message{}
+	var sentAt sql.NullTime
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
 		&msg.BodyPath, &msg.ReadState, &msg.ReceivedAt, &msg.SPFResult, &msg.DKIMResult,
 		&msg.DMARCResult, &msg.DMARCPolicy,
-		&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred,
+		&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred, &msg.Size,
+		&sentAt, &msg.InReplyTo, &msg.References,
 	)
 
 	if err == sql.ErrNoRows {
@@ -70,6 +94,7 @@ func (r *EmailRepository) FindByID(ctx context.Context, id string) (*domain.Mess
 	if err != nil {
 		return nil, ports.ErrStorageFailure
 	}
+	msg.SentAt = sentAt.Time
 
 	return msg, nil
 }
@@ -85,20 +110,17 @@ func (r *EmailRepository) FindByUser(ctx context.Context, email string, limit, o
 
 // UpdateReadState marks a message as read or unread
 func (r *EmailRepository) UpdateReadState(ctx context.Context, id string, read bool) error {
-	query := `UPDATE messages SET read_state = $1 WHERE id = $2`
-	result, err := r.db.ExecContext(ctx, query, read, id)
-	if err != nil {
-		return ports.ErrStorageFailure
+	query := `UPDATE messages SET read_state = $1, modseq = nextval('messages_modseq_seq') WHERE id = $2 RETURNING recipient, mailbox`
+	var recipient, mailbox string
+	err := r.db.QueryRowContext(ctx, query, read, id).Scan(&recipient, &mailbox)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
 	}
-
-	rows, err := result.RowsAffected()
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
-	if rows == 0 {
-		return ports.ErrNotFound
-	}
 
+	r.notify(ctx, recipient, mailbox, "flags_changed", id)
 	return nil
 }
 
@@ -129,7 +151,7 @@ func (r *EmailRepository) FindSince(ctx context.Context, email string, since tim
 	query := `
 		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
 		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-		       mailbox, uid, flags, modseq, is_starred
+		       mailbox, uid, flags, modseq, is_starred, size
 		FROM messages
 		WHERE recipient = $1 AND received_at > $2
 		ORDER BY received_at DESC
@@ -150,7 +172,7 @@ func (r *EmailRepository) FindSince(ctx context.Context, email string, since tim
 			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
 			&msg.BodyPath, &msg.ReadState, &msg.ReceivedAt, &msg.SPFResult, &msg.DKIMResult,
 			&msg.DMARCResult, &msg.DMARCPolicy,
-			&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred,
+			&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred, &msg.Size,
 		)
 		if err != nil {
 			return nil, ports.ErrStorageFailure
@@ -161,155 +183,1135 @@ func (r *EmailRepository) FindSince(ctx context.Context, email string, since tim
 	return messages, nil
 }
 
-// IMAP Support Stubs (TODO: Implement for Postgres)
+// mailboxColumns selects a mailbox row of mb. Message counts and modification sequences
+// are derived from the messages and tombstones, which take their modseq from the global
+// messages_modseq_seq; the ACL is aggregated from mailbox_acl as a JSON object.
+const mailboxColumns = `
+	mb.name, mb.user_id, mb.uid_validity, mb.uid_next,
+	(SELECT COUNT(*) FROM messages m WHERE m.recipient = mb.user_id AND m.mailbox = mb.name),
+	GREATEST(1,
+		(SELECT COALESCE(MAX(m.modseq), 0) FROM messages m WHERE m.recipient = mb.user_id AND m.mailbox = mb.name),
+		(SELECT COALESCE(MAX(e.modseq), 0) FROM expunged_messages e WHERE e.user_id = mb.user_id AND e.mailbox = mb.name)),
+	mb.special_use, mb.subscribed,
+	COALESCE((SELECT json_object_agg(a.identifier, a.rights) FROM mailbox_acl a WHERE a.user_id = mb.user_id AND a.mailbox = mb.name)::TEXT, '{}')
+`
 
+// GetMailbox retrieves a mailbox by name for a user
 func (r *EmailRepository) GetMailbox(ctx context.Context, userID, name string) (*domain.Mailbox, error) {
-	return nil, nil // Not implemented
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes mb WHERE mb.user_id = $1 AND mb.name = $2`
+	mb, err := scanMailbox(r.db.QueryRowContext(ctx, query, userID, name))
+	if err == sql.ErrNoRows {
+		return nil, ports.ErrNotFound
+	}
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return mb, nil
+}
+
+// ListMailboxes retrieves all mailboxes for a user
+func (r *EmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes mb WHERE mb.user_id = $1 ORDER BY mb.name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mb, err := scanMailbox(rows)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		mailboxes = append(mailboxes, mb)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return mailboxes, nil
+}
+
+// ListSharedMailboxes retrieves the mailboxes of other users that are shared with a user
+func (r *EmailRepository) ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	query := `
+		SELECT ` + mailboxColumns + ` FROM mailboxes mb
+		WHERE mb.user_id != $1 AND EXISTS (
+			SELECT 1 FROM mailbox_acl a
+			WHERE a.user_id = mb.user_id AND a.mailbox = mb.name
+			  AND a.identifier IN ($1, 'anyone', 'authenticated') AND a.rights != ''
+		)
+		ORDER BY mb.user_id, mb.name
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mb, err := scanMailbox(rows)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		mailboxes = append(mailboxes, mb)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return mailboxes, nil
+}
+
+// scanMailbox reads a row selected with mailboxColumns
+func scanMailbox(row interface{ Scan(...interface{}) error }) (*domain.Mailbox, error) {
+	mb := &domain.Mailbox{}
+	var aclStr string
+	err := row.Scan(
+		&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &mb.HighestModSeq,
+		&mb.SpecialUse, &mb.Subscribed, &aclStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(aclStr), &mb.ACL); err != nil {
+		return nil, err
+	}
+	if mb.ACL == nil {
+		mb.ACL = make(map[string]string)
+	}
+	return mb, nil
 }
 
+// CreateMailbox creates a new mailbox
 func (r *EmailRepository) CreateMailbox(ctx context.Context, userID, name string) error {
-	return nil // Not implemented
+	query := `INSERT INTO mailboxes (user_id, name, uid_validity) VALUES ($1, $2, $3)`
+	if _, err := r.db.ExecContext(ctx, query, userID, name, newUIDValidity()); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
 }
 
-func (r *EmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
-	return nil, nil // Not implemented
+// SetSubscribed adds a mailbox to or removes it from the user's subscriptions
+func (r *EmailRepository) SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error {
+	return r.updateMailbox(ctx, `UPDATE mailboxes SET subscribed = $1 WHERE user_id = $2 AND name = $3`, subscribed, userID, name)
 }
 
-func (r *EmailRepository) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
-	return nil, nil // Not implemented
+// SetSpecialUse sets the RFC 6154 special-use attribute of a mailbox
+func (r *EmailRepository) SetSpecialUse(ctx context.Context, userID, name, specialUse string) error {
+	return r.updateMailbox(ctx, `UPDATE mailboxes SET special_use = $1 WHERE user_id = $2 AND name = $3`, specialUse, userID, name)
+}
+
+// updateMailbox runs an UPDATE of a single mailbox, returning ErrNotFound if it doesn't exist
+func (r *EmailRepository) updateMailbox(ctx context.Context, query string, value interface{}, userID, name string) error {
+	res, err := r.db.ExecContext(ctx, query, value, userID, name)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
 }
 
+// AddFlags adds flags to a message
 func (r *EmailRepository) AddFlags(ctx context.Context, messageID string, flags ...string) error {
-	return nil // Not implemented
+	return r.changeFlags(ctx, messageID, func(current []string) []string {
+		existing := make(map[string]bool, len(current))
+		for _, f := range current {
+			existing[f] = true
+		}
+		for _, f := range flags {
+			if !existing[f] {
+				current = append(current, f)
+				existing[f] = true
+			}
+		}
+		return current
+	})
 }
 
+// RemoveFlags removes flags from a message
 func (r *EmailRepository) RemoveFlags(ctx context.Context, messageID string, flags ...string) error {
-	return nil // Not implemented
+	return r.changeFlags(ctx, messageID, func(current []string) []string {
+		toRemove := make(map[string]bool, len(flags))
+		for _, f := range flags {
+			toRemove[f] = true
+		}
+		var kept []string
+		for _, f := range current {
+			if !toRemove[f] {
+				kept = append(kept, f)
+			}
+		}
+		return kept
+	})
 }
 
+// SetFlags sets the flags for a message
 func (r *EmailRepository) SetFlags(ctx context.Context, messageID string, flags ...string) error {
-	return nil // Not implemented
-}
+	query := `UPDATE messages SET flags = $1, modseq = nextval('messages_modseq_seq') WHERE id = $2 RETURNING recipient, mailbox`
+	var recipient, mailbox string
+	err := r.db.QueryRowContext(ctx, query, strings.Join(flags, " "), messageID).Scan(&recipient, &mailbox)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
 
-func (r *EmailRepository) AssignUID(ctx context.Context, messageID string, mailbox string) (uint32, error) {
-	return 0, nil // Not implemented
+	r.notify(ctx, recipient, mailbox, "flags_changed", messageID)
+	return nil
 }
 
-// CopyMessages copies messages to a destination mailbox
-func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, messageIDs []string, destMailbox string) error {
-	return ports.ErrStorageFailure // Not implemented
-}
+// changeFlags applies change to the flags of a message and, if they changed, stores them
+// under a new modification sequence
+func (r *EmailRepository) changeFlags(ctx context.Context, messageID string, change func([]string) []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
 
-// SetACL updates the access rights for an identifier on a mailbox
-func (r *EmailRepository) SetACL(ctx context.Context, userID, mailboxName, identifier, rights string) error {
-	return ports.ErrStorageFailure // Not implemented
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT flags FROM messages WHERE id = $1 FOR UPDATE`, messageID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	oldFlags := strings.Join(strings.Fields(current), " ")
+	newFlags := strings.Join(change(strings.Fields(current)), " ")
+	if newFlags == oldFlags {
+		return nil
+	}
+
+	query := `UPDATE messages SET flags = $1, modseq = nextval('messages_modseq_seq') WHERE id = $2 RETURNING recipient, mailbox`
+	var recipient, mailbox string
+	if err := tx.QueryRowContext(ctx, query, newFlags, messageID).Scan(&recipient, &mailbox); err != nil {
+		return ports.ErrStorageFailure
+	}
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	r.notify(ctx, recipient, mailbox, "flags_changed", messageID)
+	return nil
 }
 
-// List retrieves messages matching the filter criteria
-func (r *EmailRepository) List(ctx context.Context, email string, filter domain.MessageFilter) ([]*domain.Message, error) {
-	var queryBuilder strings.Builder
-	queryBuilder.WriteString(`
-		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
-		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-		       mailbox, uid, flags, modseq, is_starred
-		FROM messages
-		WHERE recipient = $1
-	`)
+// MoveMessages atomically moves messages from srcMailbox to destMailbox. The rows keep
+// their IDs and blobs; only the mailbox and UID change.
+func (r *EmailRepository) MoveMessages(ctx context.Context, userID, srcMailbox string, messageIDs []string, destMailbox string) (map[uint32]uint32, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
 
-	args := []interface{}{email}
-	argIdx := 2
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
 
-	if filter.Mailbox != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND mailbox = $%d", argIdx))
-		args = append(args, filter.Mailbox)
-		argIdx++
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = $1 AND name = $2)`, userID, destMailbox).Scan(&exists)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if !exists {
+		return nil, ports.ErrNotFound
 	}
 
-	if filter.IsRead != nil {
-		queryBuilder.WriteString(fmt.Sprintf(" AND read_state = $%d", argIdx))
-		args = append(args, *filter.IsRead)
-		argIdx++
+	uidMap := make(map[uint32]uint32, len(messageIDs))
+	var moved []string
+	var movedUIDs []uint32
+	for _, id := range messageIDs {
+		var oldUID uint32
+		err = tx.QueryRowContext(ctx, `SELECT uid FROM messages WHERE id = $1 AND recipient = $2 AND mailbox = $3 FOR UPDATE`, id, userID, srcMailbox).Scan(&oldUID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		newUID, err := allocateUID(ctx, tx, userID, destMailbox)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		query := `UPDATE messages SET mailbox = $1, uid = $2, modseq = nextval('messages_modseq_seq') WHERE id = $3`
+		if _, err := tx.ExecContext(ctx, query, destMailbox, newUID, id); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		uidMap[oldUID] = newUID
+		moved = append(moved, id)
+		movedUIDs = append(movedUIDs, oldUID)
 	}
 
-	if filter.IsStarred != nil {
-		queryBuilder.WriteString(fmt.Sprintf(" AND is_starred = $%d", argIdx))
-		args = append(args, *filter.IsStarred)
-		argIdx++
+	if err := recordExpunged(ctx, tx, userID, srcMailbox, movedUIDs); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
 	}
 
-	if filter.DateRange.Start != nil {
-		queryBuilder.WriteString(fmt.Sprintf(" AND received_at >= $%d", argIdx))
-		args = append(args, *filter.DateRange.Start)
-		argIdx++
+	for _, id := range moved {
+		r.notify(ctx, userID, srcMailbox, "message_deleted", id)
+		r.notify(ctx, userID, destMailbox, "new_message", id)
 	}
+	return uidMap, nil
+}
 
-	if filter.DateRange.End != nil {
-		queryBuilder.WriteString(fmt.Sprintf(" AND received_at <= $%d", argIdx))
-		args = append(args, *filter.DateRange.End)
-		argIdx++
+// CopyMessages copies messages to a destination mailbox and charges their size to userID
+func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error) {
+	if len(msgs) == 0 {
+		return nil, nil
 	}
 
-	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY received_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1))
-	args = append(args, filter.Limit, filter.Offset)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
 
-	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = $1 AND name = $2)`, userID, destMailbox).Scan(&exists)
 	if err != nil {
 		return nil, ports.ErrStorageFailure
 	}
-	defer rows.Close()
+	if !exists {
+		return nil, ports.ErrNotFound
+	}
 
-	var messages []*domain.Message
-	for rows.Next() {
-		msg := &domain.Message{}
-		err := rows.Scan(
-			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
-			&msg.BodyPath, &msg.ReadState, &msg.ReceivedAt, &msg.SPFResult, &msg.DKIMResult,
-			&msg.DMARCResult, &msg.DMARCPolicy,
-			&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred,
-		)
+	uidMap := make(map[uint32]uint32, len(msgs))
+	var copied []string
+	var storage int64
+	for _, msg := range msgs {
+		var oldUID uint32
+		err = tx.QueryRowContext(ctx, `SELECT uid FROM messages WHERE id = $1`, msg.ID).Scan(&oldUID)
+		if err == sql.ErrNoRows {
+			continue
+		}
 		if err != nil {
 			return nil, ports.ErrStorageFailure
 		}
-		messages = append(messages, msg)
+
+		newUID, err := allocateUID(ctx, tx, userID, destMailbox)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		newID := uuid.New().String()
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO messages (
+				id, message_id, sender, recipient, subject, snippet, body_path,
+				read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+				mailbox, uid, flags, modseq, is_starred, size,
+				sent_at, in_reply_to, message_references
+			)
+			SELECT
+				$1, message_id, sender, $2, subject, snippet, body_path,
+				read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+				$3, $4::BIGINT, flags, nextval('messages_modseq_seq'), is_starred, $5::BIGINT,
+				sent_at, in_reply_to, message_references
+			FROM messages WHERE id = $6
+		`, newID, userID, destMailbox, newUID, msg.Size, msg.ID)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		// Carry the search index entry over from the original
+		_, err = tx.ExecContext(ctx, `INSERT INTO messages_search (message_id, tsv) SELECT $1, tsv FROM messages_search WHERE message_id = $2`, newID, msg.ID)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		uidMap[oldUID] = newUID
+		copied = append(copied, newID)
+		storage += msg.Size
 	}
 
-	return messages, nil
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET storage_used = storage_used + $1 WHERE email = $2`, storage, userID); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	for _, id := range copied {
+		r.notify(ctx, userID, destMailbox, "new_message", id)
+	}
+	return uidMap, nil
 }
 
-// UpdateStarred marks a message as starred (important) or not
-func (r *EmailRepository) UpdateStarred(ctx context.Context, id string, starred bool) error {
-	query := `UPDATE messages SET is_starred = $1 WHERE id = $2`
-	result, err := r.db.ExecContext(ctx, query, starred, id)
-	if err != nil {
-		return ports.ErrStorageFailure
+// DeleteMessages permanently removes messages from a mailbox, releasing their size from
+// userID's storage, and returns the blob paths that no remaining message references
+func (r *EmailRepository) DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error) {
+	if len(msgs) == 0 {
+		return nil, nil
 	}
 
-	rows, err := result.RowsAffected()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return ports.ErrStorageFailure
+		return nil, ports.ErrStorageFailure
 	}
-	if rows == 0 {
-		return ports.ErrNotFound
+	defer tx.Rollback() //nolint:errcheck
+
+	var paths, deleted []string
+	var uids []uint32
+	var released int64
+	seen := make(map[string]bool)
+	for _, msg := range msgs {
+		var bodyPath string
+		var uid uint32
+		query := `DELETE FROM messages WHERE id = $1 AND recipient = $2 AND mailbox = $3 RETURNING body_path, uid`
+		err := tx.QueryRowContext(ctx, query, msg.ID, userID, mailbox).Scan(&bodyPath, &uid)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		deleted = append(deleted, msg.ID)
+		uids = append(uids, uid)
+		released += msg.Size
+		if bodyPath != "" && !seen[bodyPath] {
+			seen[bodyPath] = true
+			paths = append(paths, bodyPath)
+		}
 	}
 
-	return nil
+	if err := recordExpunged(ctx, tx, userID, mailbox, uids); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET storage_used = storage_used - $1 WHERE email = $2`, released, userID); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	// Copies share the blob of the original; only unreferenced blobs are released
+	var orphaned []string
+	for _, path := range paths {
+		var referenced bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM messages WHERE body_path = $1)`, path).Scan(&referenced); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		if !referenced {
+			orphaned = append(orphaned, path)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	for _, id := range deleted {
+		r.notify(ctx, userID, mailbox, "message_deleted", id)
+	}
+	return orphaned, nil
 }
 
-// UpdateMailbox moves a message to a new mailbox/folder
-func (r *EmailRepository) UpdateMailbox(ctx context.Context, id string, mailbox string) error {
-	query := `UPDATE messages SET mailbox = $1 WHERE id = $2`
-	result, err := r.db.ExecContext(ctx, query, mailbox, id)
+// GetMetadata retrieves the shared and the user's private metadata of a mailbox or the server
+func (r *EmailRepository) GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT name, value FROM metadata
+		WHERE owner = $1 AND mailbox = $2 AND (user_id = '' OR user_id = $3)
+		ORDER BY name
+	`, owner, mailbox, userID)
 	if err != nil {
-		return ports.ErrStorageFailure
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var entries []*domain.MetadataEntry
+	for rows.Next() {
+		entry := &domain.MetadataEntry{}
+		if err := rows.Scan(&entry.Name, &entry.Value); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		entries = append(entries, entry)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
 	}
+	return entries, nil
+}
 
-	rows, err := result.RowsAffected()
+// SetMetadata sets and removes metadata entries in one transaction
+func (r *EmailRepository) SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
-	if rows == 0 {
-		return ports.ErrNotFound
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, entry := range entries {
+		user := ""
+		if strings.HasPrefix(entry.Name, domain.MetadataPrivate+"/") {
+			user = userID
+		}
+		if entry.Value == nil {
+			_, err = tx.ExecContext(ctx, `DELETE FROM metadata WHERE owner = $1 AND mailbox = $2 AND user_id = $3 AND name = $4`, owner, mailbox, user, entry.Name)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO metadata (owner, mailbox, user_id, name, value) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (owner, mailbox, user_id, name) DO UPDATE SET value = EXCLUDED.value
+			`, owner, mailbox, user, entry.Name, entry.Value)
+		}
+		if err != nil {
+			return ports.ErrStorageFailure
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// GetQuotaLimits returns the limits set on a quota root
+func (r *EmailRepository) GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT resource, quota_limit FROM quota_limits WHERE owner = $1 AND mailbox = $2`, root.Owner, root.Mailbox)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	limits := make(map[string]int64)
+	for rows.Next() {
+		var resource string
+		var limit int64
+		if err := rows.Scan(&resource, &limit); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		limits[resource] = limit
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return limits, nil
+}
+
+// SetQuotaLimit sets or, with a limit of 0, removes the limit of one resource of a quota root
+func (r *EmailRepository) SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
+	var err error
+	if limit == 0 {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM quota_limits WHERE owner = $1 AND mailbox = $2 AND resource = $3`, root.Owner, root.Mailbox, resource)
+	} else {
+		_, err = r.db.ExecContext(ctx, `
+			INSERT INTO quota_limits (owner, mailbox, resource, quota_limit) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (owner, mailbox, resource) DO UPDATE SET quota_limit = EXCLUDED.quota_limit
+		`, root.Owner, root.Mailbox, resource, limit)
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// GetQuotaUsage counts the messages, their size and the mailboxes a quota root covers
+func (r *EmailRepository) GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	// The accounts of a domain root are those whose address is in the domain
+	owner := "recipient = $1"
+	mailboxOwner := "user_id = $1"
+	if root.IsDomain() {
+		owner = "LOWER(SPLIT_PART(recipient, '@', 2)) = LOWER($1)"
+		mailboxOwner = "LOWER(SPLIT_PART(user_id, '@', 2)) = LOWER($1)"
+	}
+	args := []interface{}{root.Owner}
+	if root.Mailbox != "" {
+		owner += " AND mailbox = $2"
+		args = append(args, root.Mailbox)
+	}
+
+	var storage, messages int64
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(size), 0), COUNT(*) FROM messages WHERE "+owner, args...).Scan(&storage, &messages)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	usage := map[string]int64{domain.QuotaStorage: storage, domain.QuotaMessage: messages}
+	if root.Mailbox == "" {
+		var mailboxes int64
+		if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mailboxes WHERE "+mailboxOwner, root.Owner).Scan(&mailboxes); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		usage[domain.QuotaMailbox] = mailboxes
+	}
+	return usage, nil
+}
+
+// ListQuotaRoots returns the user and mailbox roots of owner that have limits set
+func (r *EmailRepository) ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT mailbox FROM quota_limits WHERE owner = $1 ORDER BY mailbox`, owner)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var roots []domain.QuotaRoot
+	for rows.Next() {
+		root := domain.QuotaRoot{Owner: owner}
+		if err := rows.Scan(&root.Mailbox); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		roots = append(roots, root)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return roots, nil
+}
+
+// saveMessage inserts a new message within tx, creating its mailbox if needed, and charges
+// its size to the recipient's storage
+func saveMessage(ctx context.Context, tx *sql.Tx, msg *domain.Message) error {
+	if msg.Mailbox == "" {
+		msg.Mailbox = "INBOX"
+	}
+	var err error
+	msg.UID, err = allocateUID(ctx, tx, msg.Recipient, msg.Mailbox)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO messages (
+			id, message_id, sender, recipient, subject, snippet, body_path,
+			read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+			mailbox, uid, flags, modseq, is_starred, size,
+			sent_at, in_reply_to, message_references
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, nextval('messages_modseq_seq'), $17, $18, $19, $20, $21)
+		RETURNING modseq
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		msg.ID, msg.MessageID, msg.Sender, msg.Recipient, msg.Subject, msg.Snippet,
+		msg.BodyPath, msg.ReadState, msg.ReceivedAt, msg.SPFResult, msg.DKIMResult,
+		msg.DMARCResult, msg.DMARCPolicy,
+		msg.Mailbox, msg.UID, msg.Flags, msg.IsStarred, msg.Size,
+		sql.NullTime{Time: msg.SentAt, Valid: !msg.SentAt.IsZero()}, msg.InReplyTo, msg.References,
+	).Scan(&msg.ModSeq)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET storage_used = storage_used + $1 WHERE email = $2`, msg.Size, msg.Recipient)
+	return err
+}
+
+// allocateUID hands out the next UID of a mailbox, creating the mailbox on first use
+func allocateUID(ctx context.Context, tx *sql.Tx, userID, mailbox string) (uint32, error) {
+	query := `
+		INSERT INTO mailboxes (user_id, name, uid_validity, uid_next) VALUES ($1, $2, $3, 2)
+		ON CONFLICT (user_id, name) DO UPDATE SET uid_next = mailboxes.uid_next + 1
+		RETURNING uid_next - 1
+	`
+	var uid uint32
+	err := tx.QueryRowContext(ctx, query, userID, mailbox, newUIDValidity()).Scan(&uid)
+	return uid, err
+}
+
+// recordExpunged keeps tombstones of UIDs removed from a mailbox so that QRESYNC clients
+// can be told which messages vanished. All of them share one new modification sequence.
+func recordExpunged(ctx context.Context, tx *sql.Tx, userID, mailbox string, uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	var modSeq uint64
+	if err := tx.QueryRowContext(ctx, `SELECT nextval('messages_modseq_seq')`).Scan(&modSeq); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO expunged_messages (user_id, mailbox, uid, modseq) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, mailbox, uid) DO UPDATE SET modseq = EXCLUDED.modseq
+	`
+	for _, uid := range uids {
+		if _, err := tx.ExecContext(ctx, query, userID, mailbox, uid, modSeq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newUIDValidity returns the UIDVALIDITY of a new mailbox (RFC 3501 Section 2.3.1.1)
+func newUIDValidity() uint32 {
+	uidValidity := uint32(time.Now().Unix())
+	if uidValidity == 0 {
+		uidValidity = 1
+	}
+	return uidValidity
+}
+
+// RenameMailbox renames a mailbox and its inferior hierarchical names
+func (r *EmailRepository) RenameMailbox(ctx context.Context, userID, oldName, newName string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM mailboxes WHERE user_id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return ports.ErrStorageFailure
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return ports.ErrStorageFailure
+	}
+	if !existing[oldName] {
+		return ports.ErrNotFound
+	}
+
+	renames := map[string]string{oldName: newName}
+	prefix := oldName + domain.MailboxDelimiter
+	for name := range existing {
+		if strings.HasPrefix(name, prefix) {
+			renames[name] = newName + strings.TrimPrefix(name, oldName)
+		}
+	}
+	for _, to := range renames {
+		if existing[to] {
+			return ports.ErrAlreadyExists
+		}
+	}
+
+	// The UIDVALIDITY is kept, so the messages and tombstones stay valid under the new name
+	queries := []string{
+		`UPDATE mailboxes SET name = $1 WHERE user_id = $2 AND name = $3`,
+		`UPDATE messages SET mailbox = $1 WHERE recipient = $2 AND mailbox = $3`,
+		`UPDATE expunged_messages SET mailbox = $1 WHERE user_id = $2 AND mailbox = $3`,
+		`UPDATE metadata SET mailbox = $1 WHERE owner = $2 AND mailbox = $3`,
+		`UPDATE quota_limits SET mailbox = $1 WHERE owner = $2 AND mailbox = $3`,
+		`UPDATE mailbox_acl SET mailbox = $1 WHERE user_id = $2 AND mailbox = $3`,
+	}
+	for from, to := range renames {
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, to, userID, from); err != nil {
+				return ports.ErrStorageFailure
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// DeleteMailbox removes a mailbox with its messages and returns the orphaned blob paths
+func (r *EmailRepository) DeleteMailbox(ctx context.Context, userID, name string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM mailboxes WHERE user_id = $1 AND name = $2`, userID, name)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ports.ErrNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM messages WHERE recipient = $1 AND mailbox = $2 RETURNING id, body_path, size`, userID, name)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	var deleted, paths []string
+	var released int64
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id, bodyPath string
+		var size int64
+		if err := rows.Scan(&id, &bodyPath, &size); err != nil {
+			rows.Close()
+			return nil, ports.ErrStorageFailure
+		}
+		deleted = append(deleted, id)
+		released += size
+		if bodyPath != "" && !seen[bodyPath] {
+			seen[bodyPath] = true
+			paths = append(paths, bodyPath)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	for _, query := range []string{
+		`DELETE FROM expunged_messages WHERE user_id = $1 AND mailbox = $2`,
+		`DELETE FROM metadata WHERE owner = $1 AND mailbox = $2`,
+		`DELETE FROM quota_limits WHERE owner = $1 AND mailbox = $2`,
+		`DELETE FROM mailbox_acl WHERE user_id = $1 AND mailbox = $2`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID, name); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET storage_used = storage_used - $1 WHERE email = $2`, released, userID); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	// Copies in other mailboxes share the blob of the original
+	var orphaned []string
+	for _, path := range paths {
+		var referenced bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM messages WHERE body_path = $1)`, path).Scan(&referenced); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		if !referenced {
+			orphaned = append(orphaned, path)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	for _, id := range deleted {
+		r.notify(ctx, userID, name, "message_deleted", id)
+	}
+	return orphaned, nil
+}
+
+// FindByUIDRange retrieves messages by UID range
+func (r *EmailRepository) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
+	query := `
+		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
+		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+		       mailbox, uid, flags, modseq, is_starred, size,
+		       sent_at, in_reply_to, message_references
+		FROM messages
+		WHERE recipient = $1 AND mailbox = $2 AND uid >= $3 AND uid <= $4
+		ORDER BY uid ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, mailbox, min, max)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	return scanMailboxMessages(rows)
+}
+
+// FindInMailbox retrieves the messages of a mailbox matching the filter, ordered by UID
+func (r *EmailRepository) FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error) {
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
+		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+		       mailbox, uid, flags, modseq, is_starred, size,
+		       sent_at, in_reply_to, message_references
+		FROM messages
+		WHERE recipient = $1 AND mailbox = $2
+	`)
+
+	args := []interface{}{userID, mailbox}
+	argIdx := 3
+
+	if filter.MinUID > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" AND uid >= $%d", argIdx))
+		args = append(args, filter.MinUID)
+		argIdx++
+	}
+	if filter.MaxUID > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" AND uid <= $%d", argIdx))
+		args = append(args, filter.MaxUID)
+		argIdx++
+	}
+
+	// Flags are stored space-separated; the read state also counts as \Seen
+	for _, flag := range filter.Flags {
+		if flag == "\\Seen" {
+			queryBuilder.WriteString(fmt.Sprintf(" AND (read_state OR POSITION(' ' || $%d || ' ' IN ' ' || COALESCE(flags, '') || ' ') > 0)", argIdx))
+		} else {
+			queryBuilder.WriteString(fmt.Sprintf(" AND POSITION(' ' || $%d || ' ' IN ' ' || COALESCE(flags, '') || ' ') > 0", argIdx))
+		}
+		args = append(args, flag)
+		argIdx++
+	}
+	for _, flag := range filter.NotFlags {
+		if flag == "\\Seen" {
+			queryBuilder.WriteString(" AND NOT read_state")
+		}
+		queryBuilder.WriteString(fmt.Sprintf(" AND POSITION(' ' || $%d || ' ' IN ' ' || COALESCE(flags, '') || ' ') = 0", argIdx))
+		args = append(args, flag)
+		argIdx++
+	}
+
+	if filter.ChangedSince > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" AND modseq > $%d", argIdx))
+		args = append(args, filter.ChangedSince)
+		argIdx++
+	}
+
+	if !filter.Since.IsZero() {
+		queryBuilder.WriteString(fmt.Sprintf(" AND received_at >= $%d", argIdx))
+		args = append(args, filter.Since)
+		argIdx++
+	}
+	if !filter.Before.IsZero() {
+		queryBuilder.WriteString(fmt.Sprintf(" AND received_at < $%d", argIdx))
+		args = append(args, filter.Before)
+		argIdx++
+	}
+	if filter.Larger > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" AND (size = 0 OR size > $%d)", argIdx))
+		args = append(args, filter.Larger)
+		argIdx++
+	}
+	if filter.Smaller > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" AND (size = 0 OR size < $%d)", argIdx))
+		args = append(args, filter.Smaller)
+	}
+
+	queryBuilder.WriteString(" ORDER BY uid ASC")
+
+	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	return scanMailboxMessages(rows)
+}
+
+// scanMailboxMessages reads the messages selected by FindByUIDRange and FindInMailbox
+func scanMailboxMessages(rows *sql.Rows) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for rows.Next() {
+		msg := &domain.Message{}
+		var sentAt sql.NullTime
+		err := rows.Scan(
+			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
+			&msg.BodyPath, &msg.ReadState, &msg.ReceivedAt, &msg.SPFResult, &msg.DKIMResult,
+			&msg.DMARCResult, &msg.DMARCPolicy,
+			&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred, &msg.Size,
+			&sentAt, &msg.InReplyTo, &msg.References,
+		)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		msg.SentAt = sentAt.Time
+		messages = append(messages, msg)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return messages, nil
+}
+
+// AssignUID assigns a UID to a message
+func (r *EmailRepository) AssignUID(ctx context.Context, messageID string, mailboxName string) (uint32, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var recipient string
+	err = tx.QueryRowContext(ctx, `SELECT recipient FROM messages WHERE id = $1 FOR UPDATE`, messageID).Scan(&recipient)
+	if err == sql.ErrNoRows {
+		return 0, ports.ErrNotFound
+	}
+	if err != nil {
+		return 0, ports.ErrStorageFailure
+	}
+
+	// Only the INBOX is created on demand
+	if mailboxName != "INBOX" {
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = $1 AND name = $2)`, recipient, mailboxName).Scan(&exists)
+		if err != nil {
+			return 0, ports.ErrStorageFailure
+		}
+		if !exists {
+			return 0, ports.ErrNotFound
+		}
+	}
+
+	uid, err := allocateUID(ctx, tx, recipient, mailboxName)
+	if err != nil {
+		return 0, ports.ErrStorageFailure
+	}
+	query := `UPDATE messages SET uid = $1, mailbox = $2, modseq = nextval('messages_modseq_seq') WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, query, uid, mailboxName, messageID); err != nil {
+		return 0, ports.ErrStorageFailure
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, ports.ErrStorageFailure
+	}
+	return uid, nil
+}
+
+// FindExpunged returns the UIDs expunged from a mailbox after the given modification sequence
+func (r *EmailRepository) FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error) {
+	query := `SELECT uid FROM expunged_messages WHERE user_id = $1 AND mailbox = $2 AND modseq > $3 ORDER BY uid ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID, mailbox, sinceModSeq)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var uids []uint32
+	for rows.Next() {
+		var uid uint32
+		if err := rows.Scan(&uid); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+// SetACL updates the access rights for an identifier on a mailbox; empty rights remove it
+func (r *EmailRepository) SetACL(ctx context.Context, userID, mailboxName, identifier, rights string) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = $1 AND name = $2)`, userID, mailboxName).Scan(&exists)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if !exists {
+		return ports.ErrNotFound
+	}
+
+	if rights == "" {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM mailbox_acl WHERE user_id = $1 AND mailbox = $2 AND identifier = $3`, userID, mailboxName, identifier)
+	} else {
+		_, err = r.db.ExecContext(ctx, `
+			INSERT INTO mailbox_acl (user_id, mailbox, identifier, rights) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, mailbox, identifier) DO UPDATE SET rights = EXCLUDED.rights
+		`, userID, mailboxName, identifier, rights)
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// List retrieves messages matching the filter criteria
+func (r *EmailRepository) List(ctx context.Context, email string, filter domain.MessageFilter) ([]*domain.Message, error) {
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
+		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+		       mailbox, uid, flags, modseq, is_starred, size
+		FROM messages
+		WHERE recipient = $1
+	`)
+
+	args := []interface{}{email}
+	argIdx := 2
+
+	if filter.Mailbox != "" {
+		queryBuilder.WriteString(fmt.Sprintf(" AND mailbox = $%d", argIdx))
+		args = append(args, filter.Mailbox)
+		argIdx++
+	}
+
+	if filter.IsRead != nil {
+		queryBuilder.WriteString(fmt.Sprintf(" AND read_state = $%d", argIdx))
+		args = append(args, *filter.IsRead)
+		argIdx++
+	}
+
+	if filter.IsStarred != nil {
+		queryBuilder.WriteString(fmt.Sprintf(" AND is_starred = $%d", argIdx))
+		args = append(args, *filter.IsStarred)
+		argIdx++
+	}
+
+	if filter.DateRange.Start != nil {
+		queryBuilder.WriteString(fmt.Sprintf(" AND received_at >= $%d", argIdx))
+		args = append(args, *filter.DateRange.Start)
+		argIdx++
+	}
+
+	if filter.DateRange.End != nil {
+		queryBuilder.WriteString(fmt.Sprintf(" AND received_at <= $%d", argIdx))
+		args = append(args, *filter.DateRange.End)
+		argIdx++
+	}
+
+	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY received_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1))
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		msg := &domain.Message{}
+		err := rows.Scan(
+			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
+			&msg.BodyPath, &msg.ReadState, &msg.ReceivedAt, &msg.SPFResult, &msg.DKIMResult,
+			&msg.DMARCResult, &msg.DMARCPolicy,
+			&msg.Mailbox, &msg.UID, &msg.Flags, &msg.ModSeq, &msg.IsStarred, &msg.Size,
+		)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// UpdateStarred marks a message as starred (important) or not
+func (r *EmailRepository) UpdateStarred(ctx context.Context, id string, starred bool) error {
+	query := `UPDATE messages SET is_starred = $1, modseq = nextval('messages_modseq_seq') WHERE id = $2 RETURNING recipient, mailbox`
+	var recipient, mailbox string
+	err := r.db.QueryRowContext(ctx, query, starred, id).Scan(&recipient, &mailbox)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	r.notify(ctx, recipient, mailbox, "flags_changed", id)
+	return nil
+}
+
+// UpdateMailbox moves a message to a new mailbox/folder, creating the mailbox if needed
+func (r *EmailRepository) UpdateMailbox(ctx context.Context, id string, mailbox string) error {
+	var recipient, oldMailbox string
+	err := r.db.QueryRowContext(ctx, `SELECT recipient, mailbox FROM messages WHERE id = $1`, id).Scan(&recipient, &oldMailbox)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if oldMailbox == mailbox {
+		return nil
+	}
+
+	query := `INSERT INTO mailboxes (user_id, name, uid_validity) VALUES ($1, $2, $3) ON CONFLICT (user_id, name) DO NOTHING`
+	if _, err := r.db.ExecContext(ctx, query, recipient, mailbox, newUIDValidity()); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	// A move gives the message a new UID in the destination and expunges it from the source
+	uidMap, err := r.MoveMessages(ctx, recipient, oldMailbox, []string{id}, mailbox)
+	if err != nil {
+		return err
+	}
+	if len(uidMap) == 0 {
+		return ports.ErrNotFound
+	}
 	return nil
 }
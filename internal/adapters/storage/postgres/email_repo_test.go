@@ -0,0 +1,465 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB connects to the database named by MAILRAVEN_TEST_POSTGRES_DSN and applies the
+// migrations. Tests using it are skipped when the variable is unset.
+func openTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("MAILRAVEN_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("MAILRAVEN_TEST_POSTGRES_DSN not set")
+	}
+	conn, err := NewConnection(dsn)
+	require.NoError(t, err)
+	require.NoError(t, conn.RunMigrations())
+	t.Cleanup(func() { conn.Close() })
+	return conn.DB
+}
+
+// testUser returns an address in a domain of its own for one test and removes its rows afterwards
+func testUser(t *testing.T, db *sql.DB) string {
+	user := "user@" + uuid.New().String() + ".test"
+	t.Cleanup(func() {
+		for _, query := range []string{
+			`DELETE FROM messages WHERE recipient = $1`,
+			`DELETE FROM mailboxes WHERE user_id = $1`,
+			`DELETE FROM expunged_messages WHERE user_id = $1`,
+			`DELETE FROM mailbox_acl WHERE user_id = $1`,
+			`DELETE FROM quota_limits WHERE owner = $1 OR owner = SPLIT_PART($1, '@', 2)`,
+			`DELETE FROM users WHERE email = $1`,
+		} {
+			_, _ = db.Exec(query, user)
+		}
+	})
+	return user
+}
+
+// recordingBus is a ports.NotificationBus that keeps the published events
+type recordingBus struct {
+	mu     sync.Mutex
+	events []ports.NotificationEvent
+}
+
+func (b *recordingBus) Notify(ctx context.Context, event ports.NotificationEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+	return nil
+}
+
+func (b *recordingBus) Listen(ctx context.Context, userID string) (<-chan ports.NotificationEvent, func(), error) {
+	return nil, func() {}, nil
+}
+
+// take returns the events published since the last call
+func (b *recordingBus) take() []ports.NotificationEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := b.events
+	b.events = nil
+	return events
+}
+
+func saveTestMessage(t *testing.T, repo *EmailRepository, user, mailbox string) *domain.Message {
+	id := uuid.New().String()
+	msg := &domain.Message{
+		ID:         id,
+		MessageID:  "<" + id + "@example.com>",
+		Sender:     "sender@example.org",
+		Recipient:  user,
+		Subject:    "Test",
+		BodyPath:   "blobs/" + id,
+		Mailbox:    mailbox,
+		Size:       100,
+		ReceivedAt: time.Now(),
+	}
+	require.NoError(t, repo.Save(context.Background(), msg))
+	return msg
+}
+
+func TestEmailRepository_ModSeqAndExpunge(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateMailbox(ctx, user, "INBOX"))
+	require.NoError(t, repo.CreateMailbox(ctx, user, "Archive"))
+	first := saveTestMessage(t, repo, user, "INBOX")
+	second := saveTestMessage(t, repo, user, "INBOX")
+	assert.Equal(t, uint32(1), first.UID)
+	assert.Equal(t, uint32(2), second.UID)
+
+	mb, err := repo.GetMailbox(ctx, user, "INBOX")
+	require.NoError(t, err)
+	assert.Equal(t, 2, mb.MessageCount)
+	assert.Equal(t, uint32(3), mb.UIDNext)
+	assert.Equal(t, second.ModSeq, mb.HighestModSeq)
+
+	// Every flag change moves the message and the mailbox to a new modseq
+	modSeq := func() uint64 {
+		msg, err := repo.FindByID(ctx, first.ID)
+		require.NoError(t, err)
+		return msg.ModSeq
+	}
+	require.NoError(t, repo.AddFlags(ctx, first.ID, `\Seen`, `\Flagged`))
+	added := modSeq()
+	assert.Greater(t, added, second.ModSeq)
+	require.NoError(t, repo.AddFlags(ctx, first.ID, `\Seen`))
+	assert.Equal(t, added, modSeq(), "unchanged flags keep the modseq")
+	require.NoError(t, repo.RemoveFlags(ctx, first.ID, `\Flagged`))
+	removed := modSeq()
+	assert.Greater(t, removed, added)
+	require.NoError(t, repo.SetFlags(ctx, first.ID, `\Answered`))
+	set := modSeq()
+	assert.Greater(t, set, removed)
+	msg, err := repo.FindByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, `\Answered`, msg.Flags)
+	mb, err = repo.GetMailbox(ctx, user, "INBOX")
+	require.NoError(t, err)
+	assert.Equal(t, set, mb.HighestModSeq)
+
+	// Moving and deleting leave tombstones for QRESYNC
+	uidMap, err := repo.MoveMessages(ctx, user, "INBOX", []string{first.ID}, "Archive")
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]uint32{1: 1}, uidMap)
+	expunged, err := repo.FindExpunged(ctx, user, "INBOX", set)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1}, expunged)
+
+	orphaned, err := repo.DeleteMessages(ctx, user, "INBOX", []*domain.Message{second})
+	require.NoError(t, err)
+	assert.Equal(t, []string{second.BodyPath}, orphaned)
+	expunged, err = repo.FindExpunged(ctx, user, "INBOX", set)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2}, expunged)
+
+	mb, err = repo.GetMailbox(ctx, user, "INBOX")
+	require.NoError(t, err)
+	assert.Equal(t, 0, mb.MessageCount)
+	assert.Greater(t, mb.HighestModSeq, set)
+
+	_, err = repo.MoveMessages(ctx, user, "Archive", []string{first.ID}, "Missing")
+	assert.Error(t, err)
+}
+
+func TestEmailRepository_Mailboxes(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateMailbox(ctx, user, "Sent"))
+	require.NoError(t, repo.CreateMailbox(ctx, user, "INBOX"))
+	assert.Error(t, repo.CreateMailbox(ctx, user, "INBOX"))
+
+	require.NoError(t, repo.SetSpecialUse(ctx, user, "Sent", domain.SpecialUseSent))
+	require.NoError(t, repo.SetSubscribed(ctx, user, "Sent", true))
+	assert.ErrorIs(t, repo.SetSubscribed(ctx, user, "Missing", true), ports.ErrNotFound)
+	assert.ErrorIs(t, repo.SetSpecialUse(ctx, user, "Missing", domain.SpecialUseJunk), ports.ErrNotFound)
+
+	mailboxes, err := repo.ListMailboxes(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, mailboxes, 2)
+	assert.Equal(t, "INBOX", mailboxes[0].Name)
+	assert.False(t, mailboxes[0].Subscribed)
+	assert.Equal(t, "Sent", mailboxes[1].Name)
+	assert.Equal(t, domain.SpecialUseSent, mailboxes[1].SpecialUse)
+	assert.True(t, mailboxes[1].Subscribed)
+
+	_, err = repo.GetMailbox(ctx, user, "Missing")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	// Renaming carries the inferior names, messages and UIDVALIDITY along
+	require.NoError(t, repo.CreateMailbox(ctx, user, "Sent/2025"))
+	msg := saveTestMessage(t, repo, user, "Sent/2025")
+	before, err := repo.GetMailbox(ctx, user, "Sent/2025")
+	require.NoError(t, err)
+	assert.ErrorIs(t, repo.RenameMailbox(ctx, user, "Sent", "INBOX"), ports.ErrAlreadyExists)
+	assert.ErrorIs(t, repo.RenameMailbox(ctx, user, "Missing", "Other"), ports.ErrNotFound)
+	require.NoError(t, repo.RenameMailbox(ctx, user, "Sent", "Outbox"))
+	after, err := repo.GetMailbox(ctx, user, "Outbox/2025")
+	require.NoError(t, err)
+	assert.Equal(t, before.UIDValidity, after.UIDValidity)
+	assert.Equal(t, 1, after.MessageCount)
+	_, err = repo.GetMailbox(ctx, user, "Sent")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	orphaned, err := repo.DeleteMailbox(ctx, user, "Outbox/2025")
+	require.NoError(t, err)
+	assert.Equal(t, []string{msg.BodyPath}, orphaned)
+	_, err = repo.FindByID(ctx, msg.ID)
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	_, err = repo.DeleteMailbox(ctx, user, "Outbox/2025")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestEmailRepository_Notifications(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	bus := &recordingBus{}
+	repo := NewEmailRepository(db, bus)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateMailbox(ctx, user, "INBOX"))
+	require.NoError(t, repo.CreateMailbox(ctx, user, "Archive"))
+	first := saveTestMessage(t, repo, user, "INBOX")
+	second := saveTestMessage(t, repo, user, "INBOX")
+	assert.Equal(t, []ports.NotificationEvent{
+		{UserID: user, Mailbox: "INBOX", EventType: "new_message", MessageID: first.ID},
+		{UserID: user, Mailbox: "INBOX", EventType: "new_message", MessageID: second.ID},
+	}, bus.take())
+
+	flagsChanged := []ports.NotificationEvent{{UserID: user, Mailbox: "INBOX", EventType: "flags_changed", MessageID: first.ID}}
+	require.NoError(t, repo.AddFlags(ctx, first.ID, `\Seen`))
+	assert.Equal(t, flagsChanged, bus.take())
+	require.NoError(t, repo.AddFlags(ctx, first.ID, `\Seen`))
+	assert.Empty(t, bus.take(), "unchanged flags are not announced")
+	require.NoError(t, repo.RemoveFlags(ctx, first.ID, `\Seen`))
+	assert.Equal(t, flagsChanged, bus.take())
+	require.NoError(t, repo.SetFlags(ctx, first.ID, `\Flagged`))
+	assert.Equal(t, flagsChanged, bus.take())
+
+	_, err := repo.MoveMessages(ctx, user, "INBOX", []string{first.ID}, "Archive")
+	require.NoError(t, err)
+	assert.Equal(t, []ports.NotificationEvent{
+		{UserID: user, Mailbox: "INBOX", EventType: "message_deleted", MessageID: first.ID},
+		{UserID: user, Mailbox: "Archive", EventType: "new_message", MessageID: first.ID},
+	}, bus.take())
+
+	_, err = repo.DeleteMessages(ctx, user, "INBOX", []*domain.Message{second})
+	require.NoError(t, err)
+	assert.Equal(t, []ports.NotificationEvent{
+		{UserID: user, Mailbox: "INBOX", EventType: "message_deleted", MessageID: second.ID},
+	}, bus.take())
+}
+
+func TestEmailRepository_Metadata(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	other := testUser(t, db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+	t.Cleanup(func() { _, _ = db.Exec(`DELETE FROM metadata WHERE owner = $1`, user) })
+
+	require.NoError(t, repo.SetMetadata(ctx, user, "INBOX", user, []*domain.MetadataEntry{
+		{Name: "/private/color", Value: []byte("red")},
+		{Name: "/shared/comment", Value: []byte("Team inbox")},
+	}))
+	require.NoError(t, repo.SetMetadata(ctx, user, "INBOX", user, []*domain.MetadataEntry{
+		{Name: "/private/color", Value: []byte("blue")},
+	}))
+
+	entries, err := repo.GetMetadata(ctx, user, "INBOX", user)
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.MetadataEntry{
+		{Name: "/private/color", Value: []byte("blue")},
+		{Name: "/shared/comment", Value: []byte("Team inbox")},
+	}, entries)
+
+	// Private entries are only seen by the user who set them
+	entries, err = repo.GetMetadata(ctx, user, "INBOX", other)
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.MetadataEntry{{Name: "/shared/comment", Value: []byte("Team inbox")}}, entries)
+
+	require.NoError(t, repo.SetMetadata(ctx, user, "INBOX", user, []*domain.MetadataEntry{{Name: "/shared/comment"}}))
+	entries, err = repo.GetMetadata(ctx, user, "INBOX", other)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestEmailRepository_Quotas(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	users := NewUserRepository(db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+	account := domain.QuotaRoot{Owner: user}
+	junk := domain.QuotaRoot{Owner: user, Mailbox: "Junk"}
+	domainRoot := domain.QuotaRoot{Owner: strings.ToUpper(strings.SplitN(user, "@", 2)[1])}
+
+	require.NoError(t, users.Create(ctx, &domain.User{Email: user, PasswordHash: "x", CreatedAt: time.Now(), LastLoginAt: time.Now()}))
+	require.NoError(t, users.UpdateQuota(ctx, user, 4096))
+	require.NoError(t, users.IncrementStorageUsed(ctx, user, 300))
+	require.NoError(t, users.IncrementStorageUsed(ctx, user, -100))
+	found, err := users.FindByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096), found.StorageQuota)
+	assert.Equal(t, int64(200), found.StorageUsed)
+	assert.ErrorIs(t, users.UpdateQuota(ctx, "missing@example.com", 1), ports.ErrNotFound)
+
+	require.NoError(t, repo.CreateMailbox(ctx, user, "INBOX"))
+	require.NoError(t, repo.CreateMailbox(ctx, user, "Junk"))
+	inbox := saveTestMessage(t, repo, user, "INBOX")
+	saved := saveTestMessage(t, repo, user, "Junk")
+	msg, err := repo.FindByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), msg.Size)
+	found, err = users.FindByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), found.StorageUsed)
+
+	require.NoError(t, repo.SetQuotaLimit(ctx, junk, domain.QuotaMessage, 10))
+	require.NoError(t, repo.SetQuotaLimit(ctx, junk, domain.QuotaMessage, 20))
+	require.NoError(t, repo.SetQuotaLimit(ctx, account, domain.QuotaMailbox, 5))
+	limits, err := repo.GetQuotaLimits(ctx, junk)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{domain.QuotaMessage: 20}, limits)
+
+	roots, err := repo.ListQuotaRoots(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.QuotaRoot{account, junk}, roots)
+
+	usage, err := repo.GetQuotaUsage(ctx, junk)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{domain.QuotaStorage: 100, domain.QuotaMessage: 1}, usage)
+	usage, err = repo.GetQuotaUsage(ctx, account)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{domain.QuotaStorage: 200, domain.QuotaMessage: 2, domain.QuotaMailbox: 2}, usage)
+	usage, err = repo.GetQuotaUsage(ctx, domainRoot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{domain.QuotaStorage: 200, domain.QuotaMessage: 2, domain.QuotaMailbox: 2}, usage)
+
+	// Copies are charged to the owner in the same transaction
+	saved.Size = 150
+	uidMap, err := repo.CopyMessages(ctx, user, []*domain.Message{saved}, "INBOX")
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]uint32{1: 2}, uidMap)
+	found, err = users.FindByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(550), found.StorageUsed)
+	usage, err = repo.GetQuotaUsage(ctx, account)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), usage[domain.QuotaMessage])
+	assert.Equal(t, int64(350), usage[domain.QuotaStorage])
+
+	// A limit of 0 removes it
+	require.NoError(t, repo.SetQuotaLimit(ctx, junk, domain.QuotaMessage, 0))
+	limits, err = repo.GetQuotaLimits(ctx, junk)
+	require.NoError(t, err)
+	assert.Empty(t, limits)
+
+	// Deleting messages and mailboxes releases their size in the same transaction
+	_, err = repo.DeleteMessages(ctx, user, "INBOX", []*domain.Message{inbox})
+	require.NoError(t, err)
+	found, err = users.FindByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(450), found.StorageUsed)
+	_, err = repo.DeleteMailbox(ctx, user, "Junk")
+	require.NoError(t, err)
+	found, err = users.FindByEmail(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(350), found.StorageUsed)
+}
+
+func TestEmailRepository_FindInMailbox(t *testing.T) {
+	db := openTestDB(t)
+	user := testUser(t, db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+
+	first := saveTestMessage(t, repo, user, "INBOX")
+	second := saveTestMessage(t, repo, user, "INBOX")
+	third := saveTestMessage(t, repo, user, "INBOX")
+	require.NoError(t, repo.AddFlags(ctx, second.ID, `\Flagged`))
+	require.NoError(t, repo.UpdateReadState(ctx, third.ID, true))
+
+	uids := func(msgs []*domain.Message) []uint32 {
+		var uids []uint32
+		for _, msg := range msgs {
+			uids = append(uids, msg.UID)
+		}
+		return uids
+	}
+
+	msgs, err := repo.FindByUIDRange(ctx, user, "INBOX", 2, 4294967295)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3}, uids(msgs))
+
+	msgs, err = repo.FindInMailbox(ctx, user, "INBOX", domain.MailboxFilter{Flags: []string{`\Flagged`}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2}, uids(msgs))
+	msgs, err = repo.FindInMailbox(ctx, user, "INBOX", domain.MailboxFilter{NotFlags: []string{`\Seen`}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2}, uids(msgs), "the read state counts as \\Seen")
+	msgs, err = repo.FindInMailbox(ctx, user, "INBOX", domain.MailboxFilter{ChangedSince: third.ModSeq})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3}, uids(msgs))
+	msgs, err = repo.FindInMailbox(ctx, user, "INBOX", domain.MailboxFilter{MaxUID: 1, Larger: 50})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1}, uids(msgs))
+
+	// Archiving gives the message a new UID and leaves a tombstone
+	require.NoError(t, repo.UpdateMailbox(ctx, first.ID, "Archive"))
+	msg, err := repo.FindByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Archive", msg.Mailbox)
+	assert.Equal(t, uint32(1), msg.UID)
+	expunged, err := repo.FindExpunged(ctx, user, "INBOX", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1}, expunged)
+	assert.ErrorIs(t, repo.UpdateMailbox(ctx, uuid.New().String(), "Archive"), ports.ErrNotFound)
+
+	uid, err := repo.AssignUID(ctx, first.ID, "INBOX")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), uid)
+	_, err = repo.AssignUID(ctx, first.ID, "Missing")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestEmailRepository_ACL(t *testing.T) {
+	db := openTestDB(t)
+	owner := testUser(t, db)
+	reader := testUser(t, db)
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateMailbox(ctx, owner, "Team"))
+	require.NoError(t, repo.CreateMailbox(ctx, owner, "Private"))
+	assert.ErrorIs(t, repo.SetACL(ctx, owner, "Missing", reader, "lr"), ports.ErrNotFound)
+
+	require.NoError(t, repo.SetACL(ctx, owner, "Team", reader, "lr"))
+	require.NoError(t, repo.SetACL(ctx, owner, "Team", reader, "lrs"))
+	mb, err := repo.GetMailbox(ctx, owner, "Team")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{reader: "lrs"}, mb.ACL)
+
+	shared, err := repo.ListSharedMailboxes(ctx, reader)
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, "Team", shared[0].Name)
+	assert.Equal(t, owner, shared[0].UserID)
+
+	// The ACL follows a renamed mailbox and goes with a deleted one
+	require.NoError(t, repo.RenameMailbox(ctx, owner, "Team", "Shared"))
+	mb, err = repo.GetMailbox(ctx, owner, "Shared")
+	require.NoError(t, err)
+	assert.Equal(t, "lrs", mb.ACL[reader])
+	_, err = repo.DeleteMailbox(ctx, owner, "Shared")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateMailbox(ctx, owner, "Shared"))
+	mb, err = repo.GetMailbox(ctx, owner, "Shared")
+	require.NoError(t, err)
+	assert.Empty(t, mb.ACL)
+
+	require.NoError(t, repo.SetACL(ctx, owner, "Private", "anyone", "l"))
+	require.NoError(t, repo.SetACL(ctx, owner, "Private", "anyone", ""))
+	shared, err = repo.ListSharedMailboxes(ctx, reader)
+	require.NoError(t, err)
+	assert.Empty(t, shared)
+}
@@ -0,0 +1 @@
+ALTER TABLE queue DROP COLUMN IF EXISTS delay_warnings;
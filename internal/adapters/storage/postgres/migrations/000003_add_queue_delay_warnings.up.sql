@@ -0,0 +1,2 @@
+-- Track "delayed" delivery status notifications sent per queued message (RFC 3464)
+ALTER TABLE queue ADD COLUMN IF NOT EXISTS delay_warnings INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE queue DROP COLUMN IF EXISTS route;
+DROP TABLE IF EXISTS queue_attempts;
@@ -0,0 +1,13 @@
+-- Queue administration: retry history and admin rerouting
+CREATE TABLE IF NOT EXISTS queue_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    message_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_attempts_message ON queue_attempts (message_id);
+
+-- Relay host set by an administrator reroute; empty means normal MX routing
+ALTER TABLE queue ADD COLUMN IF NOT EXISTS route TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,31 @@
+-- DKIM keys per domain (dual RSA/Ed25519 signing and key rotation)
+CREATE TABLE IF NOT EXISTS dkim_keys (
+    domain TEXT NOT NULL,
+    selector TEXT NOT NULL,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL DEFAULT '',
+    public_key TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    verified_at TIMESTAMPTZ,
+    activated_at TIMESTAMPTZ,
+    retire_at TIMESTAMPTZ,
+    PRIMARY KEY (domain, selector)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dkim_keys_status ON dkim_keys (status);
+
+-- Adopt the single key of domains created before key rotation existed
+INSERT INTO dkim_keys (domain, selector, algorithm, private_key, public_key, status, created_at, activated_at)
+SELECT name,
+       COALESCE(NULLIF(dkim_selector, ''), 'default'),
+       'rsa',
+       dkim_private_key,
+       REPLACE(REPLACE(REPLACE(REPLACE(dkim_public_key,
+           '-----BEGIN PUBLIC KEY-----', ''), '-----END PUBLIC KEY-----', ''), chr(10), ''), chr(13), ''),
+       'active',
+       created_at,
+       created_at
+FROM domains
+WHERE dkim_private_key IS NOT NULL AND dkim_private_key != ''
+  AND NOT EXISTS (SELECT 1 FROM dkim_keys k WHERE k.domain = domains.name);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS expunged_messages;
+DROP INDEX IF EXISTS idx_messages_mailbox_modseq;
+DROP SEQUENCE IF EXISTS messages_modseq_seq;
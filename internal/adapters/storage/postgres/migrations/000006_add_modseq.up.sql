@@ -0,0 +1,17 @@
+-- CONDSTORE/QRESYNC modification sequences (RFC 7162)
+CREATE SEQUENCE IF NOT EXISTS messages_modseq_seq;
+
+UPDATE messages SET modseq = nextval('messages_modseq_seq') WHERE modseq IS NULL OR modseq = 0;
+
+CREATE INDEX IF NOT EXISTS idx_messages_mailbox_modseq ON messages (recipient, mailbox, modseq);
+
+-- Tombstones of expunged messages, reported as VANISHED to resynchronizing clients
+CREATE TABLE IF NOT EXISTS expunged_messages (
+    user_id TEXT NOT NULL,
+    mailbox TEXT NOT NULL,
+    uid BIGINT NOT NULL,
+    modseq BIGINT NOT NULL,
+    PRIMARY KEY (user_id, mailbox, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_expunged_messages_modseq ON expunged_messages (user_id, mailbox, modseq);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS scram_sha256;
+ALTER TABLE users DROP COLUMN IF EXISTS scram_sha1;
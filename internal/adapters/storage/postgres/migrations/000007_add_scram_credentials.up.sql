@@ -0,0 +1,3 @@
+-- SCRAM credentials (RFC 5802), stored as JSON
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scram_sha1 TEXT DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scram_sha256 TEXT DEFAULT '';
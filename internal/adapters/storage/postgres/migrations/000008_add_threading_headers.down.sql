@@ -0,0 +1,3 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS message_references;
+ALTER TABLE messages DROP COLUMN IF EXISTS in_reply_to;
+ALTER TABLE messages DROP COLUMN IF EXISTS sent_at;
@@ -0,0 +1,4 @@
+-- Headers used by IMAP SORT and THREAD (RFC 5256); sent_at is NULL when the Date header is missing
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS in_reply_to TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS message_references TEXT NOT NULL DEFAULT '';
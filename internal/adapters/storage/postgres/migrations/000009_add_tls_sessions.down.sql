@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tls_sessions;
+DROP TABLE IF EXISTS tls_reports;
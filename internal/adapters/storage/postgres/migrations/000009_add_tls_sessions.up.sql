@@ -0,0 +1,33 @@
+-- Incoming TLS reports (RFC 8460), previously only created by the SQLite schema
+CREATE TABLE IF NOT EXISTS tls_reports (
+    id TEXT PRIMARY KEY,
+    report_id TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    start_date TIMESTAMPTZ NOT NULL,
+    end_date TIMESTAMPTZ NOT NULL,
+    total_count INTEGER NOT NULL DEFAULT 0,
+    success_count INTEGER NOT NULL DEFAULT 0,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    raw_json JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tls_reports_date ON tls_reports (start_date);
+CREATE INDEX IF NOT EXISTS idx_tls_reports_provider ON tls_reports (provider);
+
+-- Outbound TLS session results aggregated into our daily TLS reports
+CREATE TABLE IF NOT EXISTS tls_sessions (
+    id TEXT PRIMARY KEY,
+    policy_domain TEXT NOT NULL,
+    policy_type TEXT NOT NULL,
+    policy_string TEXT NOT NULL DEFAULT '',
+    mx_host TEXT NOT NULL DEFAULT '',
+    sending_ip TEXT NOT NULL DEFAULT '',
+    receiving_ip TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL DEFAULT TRUE,
+    result_type TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tls_sessions_created ON tls_sessions (created_at);
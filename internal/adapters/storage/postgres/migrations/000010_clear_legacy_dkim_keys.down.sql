@@ -0,0 +1 @@
+-- Nothing to restore: the keys remain in dkim_keys
@@ -0,0 +1,30 @@
+-- IMAP mailboxes, previously only created by the SQLite schema. UIDs are allocated per
+-- mailbox; message counts and modification sequences are derived from messages and
+-- expunged_messages.
+CREATE TABLE IF NOT EXISTS mailboxes (
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    uid_validity BIGINT NOT NULL,
+    uid_next BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (user_id, name)
+);
+
+-- Number the messages stored without a UID in arrival order
+UPDATE messages m SET uid = n.base + n.rn
+FROM (
+    SELECT id,
+           ROW_NUMBER() OVER (PARTITION BY recipient, mailbox ORDER BY received_at, id) AS rn,
+           (SELECT COALESCE(MAX(x.uid), 0) FROM messages x
+            WHERE x.recipient = messages.recipient AND x.mailbox = messages.mailbox) AS base
+    FROM messages
+    WHERE uid IS NULL OR uid = 0
+) n
+WHERE m.id = n.id;
+
+-- Adopt the mailboxes those messages are in
+INSERT INTO mailboxes (user_id, name, uid_validity, uid_next)
+SELECT recipient, mailbox, EXTRACT(EPOCH FROM NOW())::BIGINT, MAX(uid) + 1
+FROM messages
+WHERE mailbox IS NOT NULL
+GROUP BY recipient, mailbox
+ON CONFLICT (user_id, name) DO NOTHING;
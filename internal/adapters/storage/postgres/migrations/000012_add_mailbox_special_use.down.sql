@@ -0,0 +1,2 @@
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS subscribed;
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS special_use;
@@ -0,0 +1,12 @@
+-- RFC 6154 special-use attributes and IMAP subscriptions
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS special_use TEXT NOT NULL DEFAULT '';
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS subscribed BOOLEAN NOT NULL DEFAULT false;
+
+-- Existing mailboxes were all visible before subscriptions existed
+UPDATE mailboxes SET subscribed = true;
+
+UPDATE mailboxes SET special_use = '\Drafts' WHERE name = 'Drafts';
+UPDATE mailboxes SET special_use = '\Sent' WHERE name = 'Sent';
+UPDATE mailboxes SET special_use = '\Junk' WHERE name = 'Junk';
+UPDATE mailboxes SET special_use = '\Trash' WHERE name = 'Trash';
+UPDATE mailboxes SET special_use = '\Archive' WHERE name = 'Archive';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS quota_limits;
+ALTER TABLE messages DROP COLUMN IF EXISTS size;
+ALTER TABLE users DROP COLUMN IF EXISTS storage_used;
+ALTER TABLE users DROP COLUMN IF EXISTS storage_quota;
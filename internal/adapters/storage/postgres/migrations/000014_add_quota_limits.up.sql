@@ -0,0 +1,15 @@
+-- Storage quotas and message sizes, previously only in the SQLite schema
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_quota BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_used BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+
+-- RFC 9208 quota roots
+-- A root is a user (empty mailbox), one mailbox of a user, or a domain, whose owner is the
+-- domain name. The storage limit of a user root stays in users.storage_quota.
+CREATE TABLE IF NOT EXISTS quota_limits (
+    owner TEXT NOT NULL,
+    mailbox TEXT NOT NULL DEFAULT '',
+    resource TEXT NOT NULL,
+    quota_limit BIGINT NOT NULL,
+    PRIMARY KEY (owner, mailbox, resource)
+);
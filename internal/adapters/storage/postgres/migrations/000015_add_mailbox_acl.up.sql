@@ -0,0 +1,10 @@
+-- RFC 4314 access control lists, one row per identifier granted rights on a mailbox
+CREATE TABLE IF NOT EXISTS mailbox_acl (
+    user_id TEXT NOT NULL,
+    mailbox TEXT NOT NULL,
+    identifier TEXT NOT NULL,
+    rights TEXT NOT NULL,
+    PRIMARY KEY (user_id, mailbox, identifier)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mailbox_acl_identifier ON mailbox_acl (identifier);
@@ -0,0 +1,3 @@
+-- Recipients that failed temporarily during inbound delivery are retried by the delivery
+-- worker straight into the local mailbox instead of over SMTP
+ALTER TABLE queue ADD COLUMN IF NOT EXISTS local_delivery BOOLEAN NOT NULL DEFAULT false;
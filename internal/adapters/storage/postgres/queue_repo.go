@@ -3,12 +3,17 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 )
 
+// queueColumns is the column list scanned by scanQueueMessage
+const queueColumns = `id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error, delay_warnings, route, local_delivery`
+
 type QueueRepository struct {
 	db *sql.DB
 }
@@ -17,12 +22,36 @@ func NewQueueRepository(db *sql.DB) *QueueRepository {
 	return &QueueRepository{db: db}
 }
 
+// enqueueQuery inserts one message into the outbound queue
+const enqueueQuery = `
+	INSERT INTO queue (id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error, route, local_delivery)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
 func (r *QueueRepository) Enqueue(ctx context.Context, msg *domain.OutboundMessage) error {
-	query := `
-		INSERT INTO queue (id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.db.ExecContext(ctx, enqueueQuery, enqueueArgs(msg)...)
+	return err
+}
+
+// EnqueueAll adds messages to the outbound queue in one transaction: all of them or none
+func (r *QueueRepository) EnqueueAll(ctx context.Context, msgs []*domain.OutboundMessage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, msg := range msgs {
+		if _, err := tx.ExecContext(ctx, enqueueQuery, enqueueArgs(msg)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// enqueueArgs returns the parameters of enqueueQuery for a message
+func enqueueArgs(msg *domain.OutboundMessage) []interface{} {
+	return []interface{}{
 		msg.ID,
 		msg.Sender,
 		msg.Recipient,
@@ -33,8 +62,9 @@ func (r *QueueRepository) Enqueue(ctx context.Context, msg *domain.OutboundMessa
 		msg.NextRetryAt,
 		msg.RetryCount,
 		msg.LastError,
-	)
-	return err
+		msg.Route,
+		msg.Local,
+	}
 }
 
 func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMessage, error) {
@@ -51,21 +81,12 @@ func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMe
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED
 		)
-		RETURNING id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error
-	`
+		RETURNING ` + queueColumns
 
 	now := time.Now().UTC()
 	row := r.db.QueryRowContext(ctx, query, domain.QueueStatusProcessing, now, now)
 
-	var msg domain.OutboundMessage
-	var lastError sql.NullString
-	var statusStr string
-
-	err := row.Scan(
-		&msg.ID, &msg.Sender, &msg.Recipient, &msg.BlobKey, &statusStr,
-		&msg.CreatedAt, &msg.UpdatedAt, &msg.NextRetryAt, &msg.RetryCount, &lastError,
-	)
-
+	msg, err := scanQueueMessage(row)
 	if err == sql.ErrNoRows {
 		return nil, nil // No messages ready
 	}
@@ -73,12 +94,7 @@ func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMe
 		return nil, err
 	}
 
-	msg.Status = domain.OutboundStatus(statusStr)
-	if lastError.Valid {
-		msg.LastError = lastError.String
-	}
-
-	return &msg, nil
+	return msg, nil
 }
 
 func (r *QueueRepository) UpdateStatus(ctx context.Context, id string, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
@@ -103,6 +119,48 @@ func (r *QueueRepository) UpdateStatus(ctx context.Context, id string, status do
 	return err
 }
 
+// TransitionStatus updates a message like UpdateStatus, but only while it is still in the from status
+func (r *QueueRepository) TransitionStatus(ctx context.Context, id string, from, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
+	query := `
+		UPDATE queue
+		SET status = $1, retry_count = $2, next_retry_at = $3, last_error = $4, updated_at = $5
+		WHERE id = $6 AND status = $7
+	`
+	var lastErrorVal sql.NullString
+	if lastError != "" {
+		lastErrorVal.String = lastError
+		lastErrorVal.Valid = true
+	}
+
+	res, err := r.db.ExecContext(ctx, query,
+		status, retryCount, nextRetry, lastErrorVal, time.Now().UTC(), id, from,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	var exists int
+	err = r.db.QueryRowContext(ctx, `SELECT 1 FROM queue WHERE id = $1`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ports.ErrConflict
+}
+
+// SetDelayWarnings records how many "delayed" DSNs have been sent for a message
+func (r *QueueRepository) SetDelayWarnings(ctx context.Context, id string, count int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE queue SET delay_warnings = $1 WHERE id = $2`, count, id)
+	return err
+}
+
 func (r *QueueRepository) Stats(ctx context.Context) (pending, processing, failed, completed int64, err error) {
 	query := `
 		SELECT status, COUNT(*)
@@ -139,3 +197,209 @@ func (r *QueueRepository) Stats(ctx context.Context) (pending, processing, faile
 	}
 	return
 }
+
+// LockReadyForDomain locks up to limit further ready messages for recipients in the domain
+func (r *QueueRepository) LockReadyForDomain(ctx context.Context, recipientDomain string, limit int) ([]*domain.OutboundMessage, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	query := `
+		UPDATE queue
+		SET status = $1, updated_at = $2
+		WHERE id IN (
+			SELECT id
+			FROM queue
+			WHERE status IN ('PENDING', 'RETRYING') AND next_retry_at <= $3 AND LOWER(recipient) LIKE $4
+			ORDER BY next_retry_at ASC
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + queueColumns
+
+	now := time.Now().UTC()
+	rows, err := r.db.QueryContext(ctx, query, domain.QueueStatusProcessing, now, now, domainPattern(recipientDomain), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*domain.OutboundMessage
+	for rows.Next() {
+		msg, err := scanQueueMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// RequeueStale returns messages stuck in PROCESSING since before the cutoff to RETRYING
+func (r *QueueRepository) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	now := time.Now().UTC()
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE queue SET status = $1, next_retry_at = $2, updated_at = $2
+		WHERE status = $3 AND updated_at < $4
+	`, domain.QueueStatusRetrying, now, domain.QueueStatusProcessing, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// List returns queued messages matching the filter, oldest first
+func (r *QueueRepository) List(ctx context.Context, filter domain.QueueFilter) ([]*domain.OutboundMessage, error) {
+	query := `SELECT ` + queueColumns + ` FROM queue WHERE 1=1`
+	var args []interface{}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(` AND status = $%d`, len(args))
+	}
+	if filter.Domain != "" {
+		args = append(args, domainPattern(filter.Domain))
+		query += fmt.Sprintf(` AND LOWER(recipient) LIKE $%d`, len(args))
+	}
+	if filter.Sender != "" {
+		args = append(args, strings.ToLower(filter.Sender))
+		query += fmt.Sprintf(` AND LOWER(sender) = $%d`, len(args))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	args = append(args, limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY created_at ASC, id ASC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*domain.OutboundMessage
+	for rows.Next() {
+		msg, err := scanQueueMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// Get retrieves a queued message by ID
+func (r *QueueRepository) Get(ctx context.Context, id string) (*domain.OutboundMessage, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+queueColumns+` FROM queue WHERE id = $1`, id)
+	msg, err := scanQueueMessage(row)
+	if err == sql.ErrNoRows {
+		return nil, ports.ErrNotFound
+	}
+	return msg, err
+}
+
+// Delete removes a message and its delivery history
+func (r *QueueRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		//nolint:errcheck // Rollback safe to ignore
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM queue WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ports.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM queue_attempts WHERE message_id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordAttempt appends an entry to a message's delivery history
+func (r *QueueRepository) RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO queue_attempts (message_id, status, error, attempted_at)
+		VALUES ($1, $2, $3, $4)
+	`, attempt.MessageID, attempt.Status, attempt.Error, attempt.AttemptedAt.UTC())
+	return err
+}
+
+// ListAttempts returns a message's delivery history, oldest first
+func (r *QueueRepository) ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT message_id, status, error, attempted_at
+		FROM queue_attempts
+		WHERE message_id = $1
+		ORDER BY attempted_at ASC, id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*domain.DeliveryAttempt
+	for rows.Next() {
+		var attempt domain.DeliveryAttempt
+		var status string
+		if err := rows.Scan(&attempt.MessageID, &status, &attempt.Error, &attempt.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempt.Status = domain.OutboundStatus(status)
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// Reroute sends every undelivered message for a recipient domain through the relay host.
+// Local redeliveries never leave the server and are not rerouted.
+func (r *QueueRepository) Reroute(ctx context.Context, recipientDomain, relay string) (int64, error) {
+	now := time.Now().UTC()
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE queue SET route = $1, next_retry_at = CASE WHEN status = 'HELD' THEN next_retry_at ELSE $2 END, updated_at = $2
+		WHERE status IN ('PENDING', 'RETRYING', 'HELD') AND NOT local_delivery AND LOWER(recipient) LIKE $3
+	`, relay, now, domainPattern(recipientDomain))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQueueMessage scans a row selected with queueColumns
+func scanQueueMessage(row rowScanner) (*domain.OutboundMessage, error) {
+	var msg domain.OutboundMessage
+	var lastError sql.NullString
+	var statusStr string
+
+	if err := row.Scan(
+		&msg.ID, &msg.Sender, &msg.Recipient, &msg.BlobKey, &statusStr,
+		&msg.CreatedAt, &msg.UpdatedAt, &msg.NextRetryAt, &msg.RetryCount, &lastError, &msg.DelayWarnings, &msg.Route, &msg.Local,
+	); err != nil {
+		return nil, err
+	}
+
+	msg.Status = domain.OutboundStatus(statusStr)
+	msg.LastError = lastError.String
+	return &msg, nil
+}
+
+// domainPattern builds a LIKE pattern matching addresses in the domain
+func domainPattern(recipientDomain string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(recipientDomain))
+	return "%@" + escaped
+}
@@ -65,6 +65,32 @@ func (r *SearchRepository) Search(ctx context.Context, userEmail, queryText stri
 	return results, nil
 }
 
+// SearchMailbox returns the IDs of all messages in the mailbox matching the query
+func (r *SearchRepository) SearchMailbox(ctx context.Context, userEmail, mailbox, queryText string) ([]string, error) {
+	query := `
+		SELECT m.id
+		FROM messages m
+		JOIN messages_search s ON m.id = s.message_id
+		WHERE m.recipient = $1 AND m.mailbox = $2 AND s.tsv @@ plainto_tsquery('english', $3)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userEmail, mailbox, queryText)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // Delete removes a message from the search index
 func (r *SearchRepository) Delete(ctx context.Context, messageID string) error {
 	query := `DELETE FROM messages_search WHERE message_id = $1`
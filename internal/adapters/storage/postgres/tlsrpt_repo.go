@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+// TLSRptRepository implements ports.TLSRptRepository using PostgreSQL
+type TLSRptRepository struct {
+	db *sql.DB
+}
+
+// NewTLSRptRepository creates a new PostgreSQL report repository
+func NewTLSRptRepository(db *sql.DB) *TLSRptRepository {
+	return &TLSRptRepository{db: db}
+}
+
+// Save stores an incoming TLS report
+func (r *TLSRptRepository) Save(ctx context.Context, report *domain.TLSReport) error {
+	if report.ID == uuid.Nil {
+		report.ID = uuid.New()
+	}
+	if report.IngestedAt.IsZero() {
+		report.IngestedAt = time.Now()
+	}
+
+	raw := report.RawJSON
+	if len(raw) == 0 {
+		raw = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO tls_reports (
+			id, report_id, provider, start_date, end_date,
+			total_count, success_count, failure_count, raw_json, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		report.ID.String(),
+		report.ReportID,
+		report.Provider,
+		report.DateRange.StartDatetime,
+		report.DateRange.EndDatetime,
+		report.TotalCount,
+		report.SuccessCount,
+		report.FailureCount,
+		string(raw),
+		report.IngestedAt,
+	)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// FindLatest retrieves the most recent reports
+func (r *TLSRptRepository) FindLatest(ctx context.Context, limit int) ([]*domain.TLSReport, error) {
+	query := `
+		SELECT id, report_id, provider, start_date, end_date,
+			total_count, success_count, failure_count, raw_json, created_at
+		FROM tls_reports
+		ORDER BY created_at DESC
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var reports []*domain.TLSReport
+	for rows.Next() {
+		var rpt domain.TLSReport
+		var idStr, rawJSON string
+		if err := rows.Scan(
+			&idStr,
+			&rpt.ReportID,
+			&rpt.Provider,
+			&rpt.DateRange.StartDatetime,
+			&rpt.DateRange.EndDatetime,
+			&rpt.TotalCount,
+			&rpt.SuccessCount,
+			&rpt.FailureCount,
+			&rawJSON,
+			&rpt.IngestedAt,
+		); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		rpt.ID = uuid.MustParse(idStr)
+		rpt.RawJSON = json.RawMessage(rawJSON)
+
+		// Policies and contact info are only kept in the raw report
+		var full domain.TLSReport
+		if err := json.Unmarshal([]byte(rawJSON), &full); err == nil {
+			rpt.Policies = full.Policies
+			rpt.ContactInfo = full.ContactInfo
+		}
+		reports = append(reports, &rpt)
+	}
+
+	return reports, rows.Err()
+}
+
+// RecordSession stores the outcome of one outbound TLS session
+func (r *TLSRptRepository) RecordSession(ctx context.Context, result *domain.TLSSessionResult) error {
+	query := `
+		INSERT INTO tls_sessions (
+			id, policy_domain, policy_type, policy_string, mx_host,
+			sending_ip, receiving_ip, success, result_type, details, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		uuid.New().String(),
+		result.PolicyDomain,
+		result.PolicyType,
+		strings.Join(result.PolicyString, "\n"),
+		result.MXHost,
+		result.SendingIP,
+		result.ReceivingIP,
+		result.Success,
+		result.ResultType,
+		result.Details,
+		result.Timestamp.UTC(),
+	)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// ListSessions returns outbound TLS session results recorded in [start, end)
+func (r *TLSRptRepository) ListSessions(ctx context.Context, start, end time.Time) ([]*domain.TLSSessionResult, error) {
+	query := `
+		SELECT policy_domain, policy_type, policy_string, mx_host,
+			sending_ip, receiving_ip, success, result_type, details, created_at
+		FROM tls_sessions
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY policy_domain, created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var results []*domain.TLSSessionResult
+	for rows.Next() {
+		var res domain.TLSSessionResult
+		var policyString string
+		if err := rows.Scan(
+			&res.PolicyDomain,
+			&res.PolicyType,
+			&policyString,
+			&res.MXHost,
+			&res.SendingIP,
+			&res.ReceivingIP,
+			&res.Success,
+			&res.ResultType,
+			&res.Details,
+			&res.Timestamp,
+		); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		if policyString != "" {
+			res.PolicyString = strings.Split(policyString, "\n")
+		}
+		results = append(results, &res)
+	}
+
+	return results, rows.Err()
+}
+
+// DeleteSessions removes the outbound TLS session results of a policy domain recorded in [start, end)
+func (r *TLSRptRepository) DeleteSessions(ctx context.Context, policyDomain string, start, end time.Time) error {
+	query := `DELETE FROM tls_sessions WHERE policy_domain = $1 AND created_at >= $2 AND created_at < $3`
+	if _, err := r.db.ExecContext(ctx, query, policyDomain, start.UTC(), end.UTC()); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// DeleteSessionsBefore removes outbound TLS session results recorded before t
+func (r *TLSRptRepository) DeleteSessionsBefore(ctx context.Context, t time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM tls_sessions WHERE created_at < $1`, t.UTC()); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
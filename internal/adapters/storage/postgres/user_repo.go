@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -29,12 +30,14 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 		user.Role = domain.RoleUser
 	}
 	query := `
-		INSERT INTO users (email, password_hash, role, created_at, last_login_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (email, password_hash, role, created_at, last_login_at, storage_quota, storage_used, scram_sha1, scram_sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		user.Email, user.PasswordHash, user.Role, user.CreatedAt, user.LastLoginAt,
+		user.StorageQuota, user.StorageUsed,
+		marshalSCRAM(user.SCRAMSHA1), marshalSCRAM(user.SCRAMSHA256),
 	)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -50,17 +53,19 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 // FindByEmail retrieves user by email address
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	query := `
-		SELECT email, password_hash, role, created_at, last_login_at
+		SELECT email, password_hash, role, created_at, last_login_at, storage_quota, storage_used, scram_sha1, scram_sha256
 		FROM users
 		WHERE email = $1
 	`
 
 	user := &domain.User{}
-	var role sql.NullString
+	var role, scramSHA1, scramSHA256 sql.NullString
 	// Postgres driver handles time.Time scanning automatically for TIMESTAMP columns
 
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.Email, &user.PasswordHash, &role, &user.CreatedAt, &user.LastLoginAt,
+		&user.StorageQuota, &user.StorageUsed,
+		&scramSHA1, &scramSHA256,
 	)
 
 	if err == sql.ErrNoRows {
@@ -75,6 +80,8 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain
 	} else {
 		user.Role = domain.RoleUser
 	}
+	user.SCRAMSHA1 = unmarshalSCRAM(scramSHA1.String)
+	user.SCRAMSHA256 = unmarshalSCRAM(scramSHA256.String)
 
 	return user, nil
 }
@@ -153,9 +160,9 @@ func (r *UserRepository) Delete(ctx context.Context, email string) error {
 	return nil
 }
 
-func (r *UserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
-	query := `UPDATE users SET password_hash = $1 WHERE email = $2`
-	res, err := r.db.ExecContext(ctx, query, passwordHash, email)
+func (r *UserRepository) UpdatePassword(ctx context.Context, email, passwordHash string, sha1, sha256 *domain.SCRAMCredentials) error {
+	query := `UPDATE users SET password_hash = $1, scram_sha1 = $2, scram_sha256 = $3 WHERE email = $4`
+	res, err := r.db.ExecContext(ctx, query, passwordHash, marshalSCRAM(sha1), marshalSCRAM(sha256), email)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
@@ -185,6 +192,47 @@ func (r *UserRepository) UpdateRole(ctx context.Context, email string, role doma
 	return nil
 }
 
+// UpdateSCRAMCredentials stores the SCRAM-SHA-1 and SCRAM-SHA-256 credentials of a user
+func (r *UserRepository) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	query := `UPDATE users SET scram_sha1 = $1, scram_sha256 = $2 WHERE email = $3`
+	res, err := r.db.ExecContext(ctx, query, marshalSCRAM(sha1), marshalSCRAM(sha256), email)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+// marshalSCRAM encodes SCRAM credentials for storage (empty when unset)
+func marshalSCRAM(creds *domain.SCRAMCredentials) string {
+	if creds == nil {
+		return ""
+	}
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalSCRAM decodes stored SCRAM credentials, returning nil when unset or unreadable
+func unmarshalSCRAM(data string) *domain.SCRAMCredentials {
+	if data == "" {
+		return nil
+	}
+	creds := &domain.SCRAMCredentials{}
+	if err := json.Unmarshal([]byte(data), creds); err != nil {
+		return nil
+	}
+	return creds
+}
+
 func (r *UserRepository) Count(ctx context.Context) (map[string]int64, error) {
 	stats := make(map[string]int64)
 
@@ -215,10 +263,26 @@ func (r *UserRepository) Count(ctx context.Context) (map[string]int64, error) {
 
 // UpdateQuota sets the max storage in bytes for a user (0 = unlimited)
 func (r *UserRepository) UpdateQuota(ctx context.Context, email string, bytes int64) error {
-	return ports.ErrStorageFailure // Not implemented
+	query := `UPDATE users SET storage_quota = $1 WHERE email = $2`
+	res, err := r.db.ExecContext(ctx, query, bytes, email)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
 }
 
 // IncrementStorageUsed updates storage usage by delta (can be negative)
 func (r *UserRepository) IncrementStorageUsed(ctx context.Context, email string, delta int64) error {
-	return ports.ErrStorageFailure // Not implemented
+	query := `UPDATE users SET storage_used = storage_used + $1 WHERE email = $2`
+	if _, err := r.db.ExecContext(ctx, query, delta, email); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
 }
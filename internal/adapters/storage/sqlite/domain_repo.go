@@ -19,12 +19,40 @@ func NewDomainRepository(db *sql.DB) *DomainRepository {
 	return &DomainRepository{db: db}
 }
 
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 func (r *DomainRepository) Create(ctx context.Context, d *domain.Domain) error {
+	return insertDomain(ctx, r.db, d)
+}
+
+// CreateWithDKIMKeys adds a domain and its DKIM keys in one transaction
+func (r *DomainRepository) CreateWithDKIMKeys(ctx context.Context, d *domain.Domain, keys []*domain.DKIMKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := insertDomain(ctx, tx, d); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := insertDKIMKey(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertDomain(ctx context.Context, db execer, d *domain.Domain) error {
 	query := `
 		INSERT INTO domains (name, created_at, updated_at, active, dkim_selector, dkim_private_key, dkim_public_key)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		d.Name,
 		d.CreatedAt.Unix(),
 		d.UpdatedAt.Unix(),
@@ -144,7 +172,9 @@ func (r *DomainRepository) Delete(ctx context.Context, name string) error {
 	if rows == 0 {
 		return ports.ErrNotFound
 	}
-	return nil
+
+	_, err = r.db.ExecContext(ctx, "DELETE FROM dkim_keys WHERE domain = ?", name)
+	return err
 }
 
 func (r *DomainRepository) Exists(ctx context.Context, name string) (bool, error) {
@@ -153,3 +183,156 @@ func (r *DomainRepository) Exists(ctx context.Context, name string) (bool, error
 	err := r.db.QueryRowContext(ctx, query, name).Scan(&exists)
 	return exists, err
 }
+
+// dkimKeyColumns is the column list scanned by scanDKIMKey
+const dkimKeyColumns = `domain, selector, algorithm, private_key, public_key, status, created_at, verified_at, activated_at, retire_at`
+
+func (r *DomainRepository) CreateDKIMKey(ctx context.Context, key *domain.DKIMKey) error {
+	return insertDKIMKey(ctx, r.db, key)
+}
+
+func insertDKIMKey(ctx context.Context, db execer, key *domain.DKIMKey) error {
+	query := `INSERT INTO dkim_keys (` + dkimKeyColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query,
+		key.Domain,
+		key.Selector,
+		key.Algorithm,
+		key.PrivateKey,
+		key.PublicKey,
+		key.Status,
+		key.CreatedAt.Unix(),
+		unixOrZero(key.VerifiedAt),
+		unixOrZero(key.ActivatedAt),
+		unixOrZero(key.RetireAt),
+	)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == 1555 { // SQLITE_CONSTRAINT_PRIMARYKEY
+			return ports.ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *DomainRepository) ListDKIMKeys(ctx context.Context, domainName string) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + ` FROM dkim_keys WHERE domain = ? ORDER BY created_at ASC, selector ASC`
+	return r.queryDKIMKeys(ctx, query, domainName)
+}
+
+func (r *DomainRepository) ListDKIMKeysByStatus(ctx context.Context, status domain.DKIMKeyStatus) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + ` FROM dkim_keys WHERE status = ? ORDER BY domain ASC, created_at ASC`
+	return r.queryDKIMKeys(ctx, query, status)
+}
+
+func (r *DomainRepository) UpdateDKIMKey(ctx context.Context, key *domain.DKIMKey) error {
+	return updateDKIMKey(ctx, r.db, key)
+}
+
+// UpdateDKIMKeys saves several DKIM keys in one transaction
+func (r *DomainRepository) UpdateDKIMKeys(ctx context.Context, keys []*domain.DKIMKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, key := range keys {
+		if err := updateDKIMKey(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func updateDKIMKey(ctx context.Context, db execer, key *domain.DKIMKey) error {
+	query := `
+		UPDATE dkim_keys
+		SET private_key = ?, status = ?, verified_at = ?, activated_at = ?, retire_at = ?
+		WHERE domain = ? AND selector = ?
+	`
+	res, err := db.ExecContext(ctx, query,
+		key.PrivateKey,
+		key.Status,
+		unixOrZero(key.VerifiedAt),
+		unixOrZero(key.ActivatedAt),
+		unixOrZero(key.RetireAt),
+		key.Domain,
+		key.Selector,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+func (r *DomainRepository) DeleteDKIMKey(ctx context.Context, domainName, selector string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM dkim_keys WHERE domain = ? AND selector = ?", domainName, selector)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+func (r *DomainRepository) queryDKIMKeys(ctx context.Context, query string, args ...interface{}) ([]*domain.DKIMKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.DKIMKey
+	for rows.Next() {
+		var k domain.DKIMKey
+		var createdAt, verifiedAt, activatedAt, retireAt int64
+		if err := rows.Scan(
+			&k.Domain,
+			&k.Selector,
+			&k.Algorithm,
+			&k.PrivateKey,
+			&k.PublicKey,
+			&k.Status,
+			&createdAt,
+			&verifiedAt,
+			&activatedAt,
+			&retireAt,
+		); err != nil {
+			return nil, err
+		}
+		k.CreatedAt = time.Unix(createdAt, 0).UTC()
+		k.VerifiedAt = timeOrZero(verifiedAt)
+		k.ActivatedAt = timeOrZero(activatedAt)
+		k.RetireAt = timeOrZero(retireAt)
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+// unixOrZero stores an unset time as 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0).UTC()
+}
@@ -26,59 +26,21 @@ func NewEmailRepository(db *sql.DB, notificationBus ports.NotificationBus) *Emai
 
 // Save stores a new message (atomic with transaction)
 func (r *EmailRepository) Save(ctx context.Context, msg *domain.Message) error {
+	return r.SaveAll(ctx, []*domain.Message{msg})
+}
+
+// SaveAll stores several new messages in a single transaction
+func (r *EmailRepository) SaveAll(ctx context.Context, msgs []*domain.Message) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	if msg.Mailbox == "" {
-		msg.Mailbox = "INBOX"
-	}
-
-	// 1. Ensure mailbox exists
-	// Using random validity if created here.
-	uidValidity := uint32(time.Now().Unix())
-	if uidValidity == 0 {
-		uidValidity = 1
-	}
-	_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO mailboxes (name, user_id, uid_validity, uid_next) VALUES (?, ?, ?, ?)", msg.Mailbox, msg.Recipient, uidValidity, 1)
-	if err != nil {
-		return ports.ErrStorageFailure
-	}
-
-	// 2. Assign UID
-	err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1", msg.Recipient, msg.Mailbox).Scan(&msg.UID)
-	if err != nil {
-		return ports.ErrStorageFailure
-	}
-
-	query := `
-		INSERT INTO messages (
-			id, message_id, sender, recipient, subject, snippet, body_path,
-			read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-			uid, mailbox, flags, mod_seq, size, is_starred
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	readStateInt := 0
-	if msg.ReadState {
-		readStateInt = 1
-	}
-
-	isStarredInt := 0
-	if msg.IsStarred {
-		isStarredInt = 1
-	}
-
-	_, err = tx.ExecContext(ctx, query,
-		msg.ID, msg.MessageID, msg.Sender, msg.Recipient, msg.Subject, msg.Snippet,
-		msg.BodyPath, readStateInt, msg.ReceivedAt.Unix(), msg.SPFResult, msg.DKIMResult,
-		msg.DMARCResult, msg.DMARCPolicy,
-		msg.UID, msg.Mailbox, msg.Flags, msg.ModSeq, msg.Size, isStarredInt,
-	)
-	if err != nil {
-		return ports.ErrStorageFailure
+	for _, msg := range msgs {
+		if err := saveMessage(ctx, tx, msg); err != nil {
+			return ports.ErrStorageFailure
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -87,12 +49,14 @@ func (r *EmailRepository) Save(ctx context.Context, msg *domain.Message) error {
 
 	// Notify (cross-instance via NotificationBus)
 	if r.notificationBus != nil {
-		_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
-			UserID:    msg.Recipient,
-			Mailbox:   msg.Mailbox,
-			EventType: "new_message",
-			MessageID: msg.ID,
-		})
+		for _, msg := range msgs {
+			_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+				UserID:    msg.Recipient,
+				Mailbox:   msg.Mailbox,
+				EventType: "new_message",
+				MessageID: msg.ID,
+			})
+		}
 	}
 
 	return nil
@@ -103,7 +67,8 @@ func (r *EmailRepository) FindByID(ctx context.Context, id string) (*domain.Mess
 	query := `
 		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
 		       read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy, size,
-		       uid, mailbox, flags, mod_seq, is_starred
+		       uid, mailbox, flags, mod_seq, is_starred,
+		       sent_at, in_reply_to, message_references
 		FROM messages
 		WHERE id = ?
 	`
@@ -111,13 +76,14 @@ func (r *EmailRepository) FindByID(ctx context.Context, id string) (*domain.Mess
 	msg := &domain.Message{}
 	var readStateInt int
 	var isStarredInt int
-	var receivedAtUnix int64
+	var receivedAtUnix, sentAt int64
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
 		&msg.BodyPath, &readStateInt, &receivedAtUnix, &msg.SPFResult, &msg.DKIMResult,
 		&msg.DMARCResult, &msg.DMARCPolicy, &msg.Size,
 		&msg.UID, &msg.Mailbox, &msg.Flags, &msg.ModSeq, &isStarredInt,
+		&sentAt, &msg.InReplyTo, &msg.References,
 	)
 
 	if err == sql.ErrNoRows {
@@ -130,6 +96,7 @@ func (r *EmailRepository) FindByID(ctx context.Context, id string) (*domain.Mess
 	msg.ReadState = readStateInt == 1
 	msg.IsStarred = isStarredInt == 1
 	msg.ReceivedAt = time.Unix(receivedAtUnix, 0)
+	msg.SentAt = sentAtTime(sentAt)
 
 	return msg, nil
 }
@@ -215,40 +182,37 @@ func (r *EmailRepository) List(ctx context.Context, email string, filter domain.
 	return messages, nil
 }
 
-// UpdateMailbox moves a message to a new mailbox/folder
+// UpdateMailbox moves a message to a new mailbox/folder, creating the mailbox if needed
 func (r *EmailRepository) UpdateMailbox(ctx context.Context, id string, mailbox string) error {
-	// 1. Ensure mailbox exists (simple approach: require it to exist or insert ignore provided user_id is known... wait, we need user_id)
-	// For efficiency, we assume mailbox table contains standard folders or they are created via IDLE/IMAP.
-	// But let's check recipient from message first to be safe or do a subselect?
-	// Simpler: Just update. If specific mailbox creation logic needed (IMAP), it should be handled there.
-	// However, we need to update UID if moving between mailboxes to be fully IMAP compliant.
-	// That logic is complex (Copy + Delete).
-	// Spec says "User Story 1 - Archive Message... message moves to Archive mailbox".
-	// For a simple DB-based mail system without full IMAP sync requirement here, just updating mailbox col is okay.
-	// But if we want IMAP compliance, it MUST be Copy+Delete or we break UID constraints.
-	// Since implementing full IMAP Copy+Delete logic here is heavy and might violate "atomic" if not careful.
-	// Plan says "Message moves are atomic metadata updates".
-	// Implementation Plan Check: "Protocol Parity: "Starred" maps to IMAP \Flagged, "Junk" maps to \Junk...".
-	// T005 says "Implement UpdateMailbox".
-	// Let's implement simple Column Update for now as per "atomic metadata update".
-	// NOTE: This breaks IMAP UIDs unless we re-assign UID.
-	// Let's do a simple update for now, recognizing this technical debt vs IMAP.
-	// Or even better: `UPDATE messages SET mailbox = ? WHERE id = ?`.
-
-	query := `UPDATE messages SET mailbox = ? WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, query, mailbox, id)
+	var recipient, oldMailbox string
+	err := r.db.QueryRowContext(ctx, "SELECT recipient, mailbox FROM messages WHERE id = ?", id).Scan(&recipient, &oldMailbox)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
+	if oldMailbox == mailbox {
+		return nil
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	uidValidity := uint32(time.Now().Unix())
+	if uidValidity == 0 {
+		uidValidity = 1
+	}
+	_, err = r.db.ExecContext(ctx, "INSERT OR IGNORE INTO mailboxes (name, user_id, uid_validity, uid_next) VALUES (?, ?, ?, ?)", mailbox, recipient, uidValidity, 1)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
-	if rowsAffected == 0 {
+
+	// A move gives the message a new UID in the destination and expunges it from the source
+	uidMap, err := r.MoveMessages(ctx, recipient, oldMailbox, []string{id}, mailbox)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if len(uidMap) == 0 {
 		return ports.ErrNotFound
 	}
-
 	return nil
 }
 
@@ -259,8 +223,14 @@ func (r *EmailRepository) UpdateStarred(ctx context.Context, id string, starred
 		starredInt = 1
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
 	query := `UPDATE messages SET is_starred = ? WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, query, starredInt, id)
+	result, err := tx.ExecContext(ctx, query, starredInt, id)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
@@ -273,6 +243,15 @@ func (r *EmailRepository) UpdateStarred(ctx context.Context, id string, starred
 		return ports.ErrNotFound
 	}
 
+	if err := touchMessage(ctx, tx, id); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	r.notifyFlagsChanged(ctx, id)
 	return nil
 }
 
@@ -296,8 +275,14 @@ func (r *EmailRepository) UpdateReadState(ctx context.Context, id string, read b
 		readStateInt = 1
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
 	query := `UPDATE messages SET read_state = ? WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, query, readStateInt, id)
+	result, err := tx.ExecContext(ctx, query, readStateInt, id)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
@@ -310,6 +295,15 @@ func (r *EmailRepository) UpdateReadState(ctx context.Context, id string, read b
 		return ports.ErrNotFound
 	}
 
+	if err := touchMessage(ctx, tx, id); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	r.notifyFlagsChanged(ctx, id)
 	return nil
 }
 
@@ -379,10 +373,10 @@ func (r *EmailRepository) CountTotal(ctx context.Context) (int64, error) {
 
 // GetMailbox retrieves a mailbox by name for a user
 func (r *EmailRepository) GetMailbox(ctx context.Context, userID, name string) (*domain.Mailbox, error) {
-	query := `SELECT name, user_id, uid_validity, uid_next, message_count, acl FROM mailboxes WHERE user_id = ? AND name = ?`
+	query := `SELECT name, user_id, uid_validity, uid_next, message_count, highest_mod_seq, special_use, subscribed, acl FROM mailboxes WHERE user_id = ? AND name = ?`
 	mb := &domain.Mailbox{}
 	var aclStr string
-	err := r.db.QueryRowContext(ctx, query, userID, name).Scan(&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &aclStr)
+	err := r.db.QueryRowContext(ctx, query, userID, name).Scan(&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &mb.HighestModSeq, &mb.SpecialUse, &mb.Subscribed, &aclStr)
 	if err == sql.ErrNoRows {
 		return nil, ports.ErrNotFound
 	}
@@ -417,7 +411,7 @@ func (r *EmailRepository) CreateMailbox(ctx context.Context, userID, name string
 
 // ListMailboxes retrieves all mailboxes for a user
 func (r *EmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
-	query := `SELECT name, user_id, uid_validity, uid_next, message_count, acl FROM mailboxes WHERE user_id = ?`
+	query := `SELECT name, user_id, uid_validity, uid_next, message_count, highest_mod_seq, special_use, subscribed, acl FROM mailboxes WHERE user_id = ? ORDER BY name`
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, ports.ErrStorageFailure
@@ -428,7 +422,7 @@ func (r *EmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*
 	for rows.Next() {
 		mb := &domain.Mailbox{}
 		var aclStr string
-		if err := rows.Scan(&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &aclStr); err != nil {
+		if err := rows.Scan(&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &mb.HighestModSeq, &mb.SpecialUse, &mb.Subscribed, &aclStr); err != nil {
 			return nil, ports.ErrStorageFailure
 		}
 		if aclStr != "" {
@@ -443,163 +437,641 @@ func (r *EmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*
 	return mailboxes, nil
 }
 
-// FindByUIDRange retrieves messages by UID range
-func (r *EmailRepository) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
-	query := `
-		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
-		       read_state, received_at, uid, mailbox, flags, mod_seq,
-		       spf_result, dkim_result, dmarc_result, dmarc_policy, size
-		FROM messages
-		WHERE recipient = ? AND mailbox = ? AND uid >= ? AND uid <= ?
-		ORDER BY uid ASC
-	`
-	// Note: 2^32-1 is max uint32. If max is 0 (check caller), it implies * (infinity).
-	// IMAP specific: caller handles conversion of * to max uint32.
-
-	rows, err := r.db.QueryContext(ctx, query, userID, mailbox, min, max)
+// ListSharedMailboxes retrieves the mailboxes of other users that are shared with a user
+func (r *EmailRepository) ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	query := `SELECT name, user_id, uid_validity, uid_next, message_count, highest_mod_seq, special_use, subscribed, acl FROM mailboxes WHERE user_id != ? AND acl IS NOT NULL AND acl NOT IN ('', '{}') ORDER BY user_id, name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, ports.ErrStorageFailure
 	}
 	defer rows.Close()
 
-	var messages []*domain.Message
+	var mailboxes []*domain.Mailbox
 	for rows.Next() {
-		msg := &domain.Message{}
-		var readStateInt int
-		var receivedAtUnix int64
-
-		err := rows.Scan(
-			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
-			&msg.BodyPath, &readStateInt, &receivedAtUnix, &msg.UID, &msg.Mailbox, &msg.Flags, &msg.ModSeq,
-			&msg.SPFResult, &msg.DKIMResult, &msg.DMARCResult, &msg.DMARCPolicy, &msg.Size,
-		)
-		if err != nil {
+		mb := &domain.Mailbox{}
+		var aclStr string
+		if err := rows.Scan(&mb.Name, &mb.UserID, &mb.UIDValidity, &mb.UIDNext, &mb.MessageCount, &mb.HighestModSeq, &mb.SpecialUse, &mb.Subscribed, &aclStr); err != nil {
 			return nil, ports.ErrStorageFailure
 		}
-
-		msg.ReadState = readStateInt == 1
-		msg.ReceivedAt = time.Unix(receivedAtUnix, 0)
-		messages = append(messages, msg)
+		if err := json.Unmarshal([]byte(aclStr), &mb.ACL); err != nil {
+			continue
+		}
+		for _, identifier := range []string{userID, "anyone", "authenticated"} {
+			if mb.ACL[identifier] != "" {
+				mailboxes = append(mailboxes, mb)
+				break
+			}
+		}
 	}
-
-	return messages, nil
+	return mailboxes, rows.Err()
 }
 
-// AddFlags adds flags to a message
-func (r *EmailRepository) AddFlags(ctx context.Context, messageID string, flags ...string) error {
+// RenameMailbox renames a mailbox and its inferior hierarchical names
+func (r *EmailRepository) RenameMailbox(ctx context.Context, userID, oldName, newName string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return ports.ErrStorageFailure
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	var currentFlagsStr string
-	err = tx.QueryRowContext(ctx, "SELECT flags FROM messages WHERE id = ?", messageID).Scan(&currentFlagsStr)
-	if err == sql.ErrNoRows {
-		return ports.ErrNotFound
-	}
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM mailboxes WHERE user_id = ?", userID)
 	if err != nil {
-		return err
+		return ports.ErrStorageFailure
 	}
-
-	currentFlags := strings.Fields(currentFlagsStr)
 	existing := make(map[string]bool)
-	for _, f := range currentFlags {
-		existing[f] = true
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return ports.ErrStorageFailure
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return ports.ErrStorageFailure
+	}
+	if !existing[oldName] {
+		return ports.ErrNotFound
 	}
 
-	changed := false
-	for _, f := range flags {
-		if !existing[f] {
-			currentFlags = append(currentFlags, f)
-			existing[f] = true
-			changed = true
+	renames := map[string]string{oldName: newName}
+	prefix := oldName + domain.MailboxDelimiter
+	for name := range existing {
+		if strings.HasPrefix(name, prefix) {
+			renames[name] = newName + strings.TrimPrefix(name, oldName)
+		}
+	}
+	for _, to := range renames {
+		if existing[to] {
+			return ports.ErrAlreadyExists
 		}
 	}
 
-	if changed {
-		newFlagsStr := strings.Join(currentFlags, " ")
-		_, err = tx.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
-		if err != nil {
-			return err
+	for from, to := range renames {
+		if _, err := tx.ExecContext(ctx, "UPDATE mailboxes SET name = ? WHERE user_id = ? AND name = ?", to, userID, from); err != nil {
+			return ports.ErrStorageFailure
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE messages SET mailbox = ? WHERE recipient = ? AND mailbox = ?", to, userID, from); err != nil {
+			return ports.ErrStorageFailure
+		}
+		// The UIDVALIDITY is kept, so the tombstones stay valid under the new name
+		if _, err := tx.ExecContext(ctx, "UPDATE expunged_messages SET mailbox = ? WHERE user_id = ? AND mailbox = ?", to, userID, from); err != nil {
+			return ports.ErrStorageFailure
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE metadata SET mailbox = ? WHERE owner = ? AND mailbox = ?", to, userID, from); err != nil {
+			return ports.ErrStorageFailure
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE quota_limits SET mailbox = ? WHERE owner = ? AND mailbox = ?", to, userID, from); err != nil {
+			return ports.ErrStorageFailure
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
 }
 
-// RemoveFlags removes flags from a message
-func (r *EmailRepository) RemoveFlags(ctx context.Context, messageID string, flags ...string) error {
+// DeleteMailbox removes a mailbox with its messages and returns the orphaned blob paths
+func (r *EmailRepository) DeleteMailbox(ctx context.Context, userID, name string) ([]string, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, ports.ErrStorageFailure
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	var currentFlagsStr string
-	err = tx.QueryRowContext(ctx, "SELECT flags FROM messages WHERE id = ?", messageID).Scan(&currentFlagsStr)
-	if err == sql.ErrNoRows {
-		return ports.ErrNotFound
+	res, err := tx.ExecContext(ctx, "DELETE FROM mailboxes WHERE user_id = ? AND name = ?", userID, name)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ports.ErrNotFound
 	}
+
+	rows, err := tx.QueryContext(ctx, "DELETE FROM messages WHERE recipient = ? AND mailbox = ? RETURNING id, body_path, size", userID, name)
 	if err != nil {
-		return err
+		return nil, ports.ErrStorageFailure
+	}
+	var deleted, paths []string
+	var released int64
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id, bodyPath string
+		var size int64
+		if err := rows.Scan(&id, &bodyPath, &size); err != nil {
+			rows.Close()
+			return nil, ports.ErrStorageFailure
+		}
+		deleted = append(deleted, id)
+		released += size
+		if bodyPath != "" && !seen[bodyPath] {
+			seen[bodyPath] = true
+			paths = append(paths, bodyPath)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
 	}
 
-	currentFlags := strings.Fields(currentFlagsStr)
-	toRemove := make(map[string]bool)
-	for _, f := range flags {
-		toRemove[f] = true
+	if _, err := tx.ExecContext(ctx, "DELETE FROM expunged_messages WHERE user_id = ? AND mailbox = ?", userID, name); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM metadata WHERE owner = ? AND mailbox = ?", userID, name); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM quota_limits WHERE owner = ? AND mailbox = ?", userID, name); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET storage_used = storage_used - ? WHERE email = ?", released, userID); err != nil {
+		return nil, ports.ErrStorageFailure
 	}
 
-	var newFlags []string
-	changed := false
-	for _, f := range currentFlags {
-		if !toRemove[f] {
-			newFlags = append(newFlags, f)
-		} else {
-			changed = true
+	// Copies in other mailboxes share the blob of the original
+	var orphaned []string
+	for _, path := range paths {
+		var refs int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages WHERE body_path = ?", path).Scan(&refs); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		if refs == 0 {
+			orphaned = append(orphaned, path)
 		}
 	}
 
-	if changed {
-		newFlagsStr := strings.Join(newFlags, " ")
-		_, err = tx.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
-		if err != nil {
-			return err
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	if r.notificationBus != nil {
+		for _, id := range deleted {
+			_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+				UserID:    userID,
+				Mailbox:   name,
+				EventType: "message_deleted",
+				MessageID: id,
+			})
 		}
 	}
 
-	return tx.Commit()
+	return orphaned, nil
 }
 
-// SetFlags sets the flags for a message
-func (r *EmailRepository) SetFlags(ctx context.Context, messageID string, flags ...string) error {
-	newFlagsStr := strings.Join(flags, " ")
-	_, err := r.db.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
+// SetSubscribed adds a mailbox to or removes it from the user's subscriptions
+func (r *EmailRepository) SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error {
+	return r.updateMailbox(ctx, "UPDATE mailboxes SET subscribed = ? WHERE user_id = ? AND name = ?", subscribed, userID, name)
+}
+
+// SetSpecialUse sets the RFC 6154 special-use attribute of a mailbox
+func (r *EmailRepository) SetSpecialUse(ctx context.Context, userID, name, specialUse string) error {
+	return r.updateMailbox(ctx, "UPDATE mailboxes SET special_use = ? WHERE user_id = ? AND name = ?", specialUse, userID, name)
+}
+
+// GetMetadata retrieves the shared and the user's private metadata of a mailbox or the server
+func (r *EmailRepository) GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT name, value FROM metadata
+		WHERE owner = ? AND mailbox = ? AND (user_id = '' OR user_id = ?)
+		ORDER BY name
+	`, owner, mailbox, userID)
 	if err != nil {
-		return ports.ErrStorageFailure
+		return nil, ports.ErrStorageFailure
 	}
-	return nil
+	defer rows.Close()
+
+	var entries []*domain.MetadataEntry
+	for rows.Next() {
+		entry := &domain.MetadataEntry{}
+		if err := rows.Scan(&entry.Name, &entry.Value); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		entries = append(entries, entry)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return entries, nil
 }
 
-// AssignUID assigns a UID to a message
-func (r *EmailRepository) AssignUID(ctx context.Context, messageID string, mailboxName string) (uint32, error) {
+// SetMetadata sets and removes metadata entries in one transaction
+func (r *EmailRepository) SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return ports.ErrStorageFailure
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	var recipient string
-	err = tx.QueryRowContext(ctx, "SELECT recipient FROM messages WHERE id = ?", messageID).Scan(&recipient)
-	if err == sql.ErrNoRows {
-		return 0, ports.ErrNotFound
-	}
-	if err != nil {
-		return 0, err
+	for _, entry := range entries {
+		user := ""
+		if strings.HasPrefix(entry.Name, domain.MetadataPrivate+"/") {
+			user = userID
+		}
+		if entry.Value == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM metadata WHERE owner = ? AND mailbox = ? AND user_id = ? AND name = ?", owner, mailbox, user, entry.Name)
+		} else {
+			_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO metadata (owner, mailbox, user_id, name, value) VALUES (?, ?, ?, ?, ?)", owner, mailbox, user, entry.Name, entry.Value)
+		}
+		if err != nil {
+			return ports.ErrStorageFailure
+		}
 	}
 
-	// Update Mailbox and get UID
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// GetQuotaLimits returns the limits set on a quota root
+func (r *EmailRepository) GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT resource, quota_limit FROM quota_limits WHERE owner = ? AND mailbox = ?", root.Owner, root.Mailbox)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	limits := make(map[string]int64)
+	for rows.Next() {
+		var resource string
+		var limit int64
+		if err := rows.Scan(&resource, &limit); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		limits[resource] = limit
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return limits, nil
+}
+
+// SetQuotaLimit sets or, with a limit of 0, removes the limit of one resource of a quota root
+func (r *EmailRepository) SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
+	var err error
+	if limit == 0 {
+		_, err = r.db.ExecContext(ctx, "DELETE FROM quota_limits WHERE owner = ? AND mailbox = ? AND resource = ?", root.Owner, root.Mailbox, resource)
+	} else {
+		_, err = r.db.ExecContext(ctx, "INSERT OR REPLACE INTO quota_limits (owner, mailbox, resource, quota_limit) VALUES (?, ?, ?, ?)", root.Owner, root.Mailbox, resource, limit)
+	}
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// GetQuotaUsage counts the messages, their size and the mailboxes a quota root covers
+func (r *EmailRepository) GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	// The accounts of a domain root are those whose address is in the domain
+	owner := "recipient = ?"
+	mailboxOwner := "user_id = ?"
+	if root.IsDomain() {
+		owner = "LOWER(SUBSTR(recipient, INSTR(recipient, '@') + 1)) = LOWER(?)"
+		mailboxOwner = "LOWER(SUBSTR(user_id, INSTR(user_id, '@') + 1)) = LOWER(?)"
+	}
+	args := []interface{}{root.Owner}
+	if root.Mailbox != "" {
+		owner += " AND mailbox = ?"
+		args = append(args, root.Mailbox)
+	}
+
+	var storage, messages int64
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(size), 0), COUNT(*) FROM messages WHERE "+owner, args...).Scan(&storage, &messages)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	usage := map[string]int64{domain.QuotaStorage: storage, domain.QuotaMessage: messages}
+	if root.Mailbox == "" {
+		var mailboxes int64
+		if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mailboxes WHERE "+mailboxOwner, root.Owner).Scan(&mailboxes); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		usage[domain.QuotaMailbox] = mailboxes
+	}
+	return usage, nil
+}
+
+// ListQuotaRoots returns the user and mailbox roots of owner that have limits set
+func (r *EmailRepository) ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT DISTINCT mailbox FROM quota_limits WHERE owner = ? ORDER BY mailbox", owner)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var roots []domain.QuotaRoot
+	for rows.Next() {
+		root := domain.QuotaRoot{Owner: owner}
+		if err := rows.Scan(&root.Mailbox); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		roots = append(roots, root)
+	}
+	if rows.Err() != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	return roots, nil
+}
+
+// updateMailbox runs an UPDATE of a single mailbox, returning ErrNotFound if it doesn't exist
+func (r *EmailRepository) updateMailbox(ctx context.Context, query string, value interface{}, userID, name string) error {
+	res, err := r.db.ExecContext(ctx, query, value, userID, name)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+// FindByUIDRange retrieves messages by UID range
+func (r *EmailRepository) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
+	query := `
+		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
+		       read_state, received_at, uid, mailbox, flags, mod_seq,
+		       spf_result, dkim_result, dmarc_result, dmarc_policy, size,
+		       sent_at, in_reply_to, message_references
+		FROM messages
+		WHERE recipient = ? AND mailbox = ? AND uid >= ? AND uid <= ?
+		ORDER BY uid ASC
+	`
+	// Note: 2^32-1 is max uint32. If max is 0 (check caller), it implies * (infinity).
+	// IMAP specific: caller handles conversion of * to max uint32.
+
+	rows, err := r.db.QueryContext(ctx, query, userID, mailbox, min, max)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		msg := &domain.Message{}
+		var readStateInt int
+		var receivedAtUnix, sentAt int64
+
+		err := rows.Scan(
+			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
+			&msg.BodyPath, &readStateInt, &receivedAtUnix, &msg.UID, &msg.Mailbox, &msg.Flags, &msg.ModSeq,
+			&msg.SPFResult, &msg.DKIMResult, &msg.DMARCResult, &msg.DMARCPolicy, &msg.Size,
+			&sentAt, &msg.InReplyTo, &msg.References,
+		)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		msg.ReadState = readStateInt == 1
+		msg.ReceivedAt = time.Unix(receivedAtUnix, 0)
+		msg.SentAt = sentAtTime(sentAt)
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// FindInMailbox retrieves the messages of a mailbox matching the filter, ordered by UID
+func (r *EmailRepository) FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error) {
+	queryBuilder := strings.Builder{}
+	queryBuilder.WriteString(`
+		SELECT id, message_id, sender, recipient, subject, snippet, body_path,
+		       read_state, received_at, uid, mailbox, flags, mod_seq,
+		       spf_result, dkim_result, dmarc_result, dmarc_policy, size,
+		       sent_at, in_reply_to, message_references
+		FROM messages
+		WHERE recipient = ? AND mailbox = ?
+	`)
+	args := []interface{}{userID, mailbox}
+
+	if filter.MinUID > 0 {
+		queryBuilder.WriteString(" AND uid >= ?")
+		args = append(args, filter.MinUID)
+	}
+	if filter.MaxUID > 0 {
+		queryBuilder.WriteString(" AND uid <= ?")
+		args = append(args, filter.MaxUID)
+	}
+
+	// Flags are stored space-separated; the read state also counts as \Seen
+	for _, flag := range filter.Flags {
+		if flag == "\\Seen" {
+			queryBuilder.WriteString(" AND (read_state = 1 OR instr(' ' || flags || ' ', ' ' || ? || ' ') > 0)")
+		} else {
+			queryBuilder.WriteString(" AND instr(' ' || flags || ' ', ' ' || ? || ' ') > 0")
+		}
+		args = append(args, flag)
+	}
+	for _, flag := range filter.NotFlags {
+		if flag == "\\Seen" {
+			queryBuilder.WriteString(" AND read_state = 0")
+		}
+		queryBuilder.WriteString(" AND instr(' ' || flags || ' ', ' ' || ? || ' ') = 0")
+		args = append(args, flag)
+	}
+
+	if filter.ChangedSince > 0 {
+		queryBuilder.WriteString(" AND mod_seq > ?")
+		args = append(args, filter.ChangedSince)
+	}
+
+	if !filter.Since.IsZero() {
+		queryBuilder.WriteString(" AND received_at >= ?")
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Before.IsZero() {
+		queryBuilder.WriteString(" AND received_at < ?")
+		args = append(args, filter.Before.Unix())
+	}
+	if filter.Larger > 0 {
+		queryBuilder.WriteString(" AND (size = 0 OR size > ?)")
+		args = append(args, filter.Larger)
+	}
+	if filter.Smaller > 0 {
+		queryBuilder.WriteString(" AND (size = 0 OR size < ?)")
+		args = append(args, filter.Smaller)
+	}
+
+	queryBuilder.WriteString(" ORDER BY uid ASC")
+
+	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		msg := &domain.Message{}
+		var readStateInt int
+		var receivedAtUnix, sentAt int64
+
+		err := rows.Scan(
+			&msg.ID, &msg.MessageID, &msg.Sender, &msg.Recipient, &msg.Subject, &msg.Snippet,
+			&msg.BodyPath, &readStateInt, &receivedAtUnix, &msg.UID, &msg.Mailbox, &msg.Flags, &msg.ModSeq,
+			&msg.SPFResult, &msg.DKIMResult, &msg.DMARCResult, &msg.DMARCPolicy, &msg.Size,
+			&sentAt, &msg.InReplyTo, &msg.References,
+		)
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		msg.ReadState = readStateInt == 1
+		msg.ReceivedAt = time.Unix(receivedAtUnix, 0)
+		msg.SentAt = sentAtTime(sentAt)
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// AddFlags adds flags to a message
+func (r *EmailRepository) AddFlags(ctx context.Context, messageID string, flags ...string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var currentFlagsStr string
+	err = tx.QueryRowContext(ctx, "SELECT flags FROM messages WHERE id = ?", messageID).Scan(&currentFlagsStr)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	currentFlags := strings.Fields(currentFlagsStr)
+	existing := make(map[string]bool)
+	for _, f := range currentFlags {
+		existing[f] = true
+	}
+
+	changed := false
+	for _, f := range flags {
+		if !existing[f] {
+			currentFlags = append(currentFlags, f)
+			existing[f] = true
+			changed = true
+		}
+	}
+
+	if changed {
+		newFlagsStr := strings.Join(currentFlags, " ")
+		_, err = tx.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
+		if err != nil {
+			return err
+		}
+		if err := touchMessage(ctx, tx, messageID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if changed {
+		r.notifyFlagsChanged(ctx, messageID)
+	}
+	return nil
+}
+
+// RemoveFlags removes flags from a message
+func (r *EmailRepository) RemoveFlags(ctx context.Context, messageID string, flags ...string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var currentFlagsStr string
+	err = tx.QueryRowContext(ctx, "SELECT flags FROM messages WHERE id = ?", messageID).Scan(&currentFlagsStr)
+	if err == sql.ErrNoRows {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	currentFlags := strings.Fields(currentFlagsStr)
+	toRemove := make(map[string]bool)
+	for _, f := range flags {
+		toRemove[f] = true
+	}
+
+	var newFlags []string
+	changed := false
+	for _, f := range currentFlags {
+		if !toRemove[f] {
+			newFlags = append(newFlags, f)
+		} else {
+			changed = true
+		}
+	}
+
+	if changed {
+		newFlagsStr := strings.Join(newFlags, " ")
+		_, err = tx.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
+		if err != nil {
+			return err
+		}
+		if err := touchMessage(ctx, tx, messageID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if changed {
+		r.notifyFlagsChanged(ctx, messageID)
+	}
+	return nil
+}
+
+// SetFlags sets the flags for a message
+func (r *EmailRepository) SetFlags(ctx context.Context, messageID string, flags ...string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	newFlagsStr := strings.Join(flags, " ")
+	_, err = tx.ExecContext(ctx, "UPDATE messages SET flags = ? WHERE id = ?", newFlagsStr, messageID)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if err := touchMessage(ctx, tx, messageID); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	r.notifyFlagsChanged(ctx, messageID)
+	return nil
+}
+
+// AssignUID assigns a UID to a message
+func (r *EmailRepository) AssignUID(ctx context.Context, messageID string, mailboxName string) (uint32, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var recipient string
+	err = tx.QueryRowContext(ctx, "SELECT recipient FROM messages WHERE id = ?", messageID).Scan(&recipient)
+	if err == sql.ErrNoRows {
+		return 0, ports.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Update Mailbox and get UID
 	var assignedUID uint32
 
 	// Check existence
@@ -625,12 +1097,13 @@ func (r *EmailRepository) AssignUID(ctx context.Context, messageID string, mailb
 	}
 
 	// Atomic increment and return (sqlite 3.35+)
-	err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1", recipient, mailboxName).Scan(&assignedUID)
+	var modSeq uint64
+	err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1, highest_mod_seq = highest_mod_seq + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1, highest_mod_seq", recipient, mailboxName).Scan(&assignedUID, &modSeq)
 	if err != nil {
 		return 0, err
 	}
 
-	_, err = tx.ExecContext(ctx, "UPDATE messages SET uid = ?, mailbox = ? WHERE id = ?", assignedUID, mailboxName, messageID)
+	_, err = tx.ExecContext(ctx, "UPDATE messages SET uid = ?, mailbox = ?, mod_seq = ? WHERE id = ?", assignedUID, mailboxName, modSeq, messageID)
 	if err != nil {
 		return 0, err
 	}
@@ -675,15 +1148,15 @@ func (r *EmailRepository) SetACL(ctx context.Context, userID, mailboxName, ident
 	return nil
 }
 
-// CopyMessages copies messages to a destination mailbox
-func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, messageIDs []string, destMailbox string) error {
-	if len(messageIDs) == 0 {
-		return nil
+// CopyMessages copies messages to a destination mailbox and charges their size to userID
+func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error) {
+	if len(msgs) == 0 {
+		return nil, nil
 	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return ports.ErrStorageFailure
+		return nil, ports.ErrStorageFailure
 	}
 	defer tx.Rollback() //nolint:errcheck
 
@@ -691,20 +1164,35 @@ func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, messa
 	var exists bool
 	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = ? AND name = ?)", userID, destMailbox).Scan(&exists)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !exists {
-		return ports.ErrNotFound
+		return nil, ports.ErrNotFound
 	}
 
-	for _, id := range messageIDs {
+	uidMap := make(map[uint32]uint32, len(msgs))
+	var copied []string
+	var storage int64
+	for _, msg := range msgs {
+		id := msg.ID
+		var oldUID uint32
+		err = tx.QueryRowContext(ctx, "SELECT uid FROM messages WHERE id = ?", id).Scan(&oldUID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
 		// Increment UID
 		var newUID uint32
-		err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1", userID, destMailbox).Scan(&newUID)
+		var modSeq uint64
+		err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1, highest_mod_seq = highest_mod_seq + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1, highest_mod_seq", userID, destMailbox).Scan(&newUID, &modSeq)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		uidMap[oldUID] = newUID
 
 		newID := uuid.New().String()
 
@@ -713,19 +1201,376 @@ func (r *EmailRepository) CopyMessages(ctx context.Context, userID string, messa
 			INSERT INTO messages (
 				id, message_id, sender, recipient, subject, snippet, body_path,
 				read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-				uid, mailbox, flags, mod_seq
+				uid, mailbox, flags, mod_seq, size, is_starred,
+				sent_at, in_reply_to, message_references
 			)
 			SELECT 
-				?, message_id, sender, recipient, subject, snippet, body_path,
+				?, message_id, sender, ?, subject, snippet, body_path,
 				read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
-				?, ?, flags, mod_seq
+				?, ?, flags, ?, ?, is_starred,
+				sent_at, in_reply_to, message_references
 			FROM messages WHERE id = ?
-		`, newID, newUID, destMailbox, id)
+		`, newID, userID, newUID, destMailbox, modSeq, msg.Size, id)
+
+		if err != nil {
+			return nil, err
+		}
+		copied = append(copied, newID)
+		storage += msg.Size
+
+		// The insert trigger indexes the copy without body text; carry it over from the original
+		_, err = tx.ExecContext(ctx, `
+			UPDATE messages_fts
+			SET body_text = (SELECT body_text FROM messages_fts WHERE message_id = ?)
+			WHERE message_id = ?
+		`, id, newID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET storage_used = storage_used + ? WHERE email = ?", storage, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range copied {
+		r.notify(ctx, userID, destMailbox, "new_message", id)
+	}
+	return uidMap, nil
+}
+
+// MoveMessages atomically moves messages from srcMailbox to destMailbox. The rows keep
+// their IDs and blobs; only the mailbox and UID change.
+func (r *EmailRepository) MoveMessages(ctx context.Context, userID, srcMailbox string, messageIDs []string, destMailbox string) (map[uint32]uint32, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// Ensure dest mailbox exists
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM mailboxes WHERE user_id = ? AND name = ?)", userID, destMailbox).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ports.ErrNotFound
+	}
 
+	uidMap := make(map[uint32]uint32, len(messageIDs))
+	var moved []string
+	var movedUIDs []uint32
+	for _, id := range messageIDs {
+		var oldUID uint32
+		err = tx.QueryRowContext(ctx, "SELECT uid FROM messages WHERE id = ? AND recipient = ? AND mailbox = ?", id, userID, srcMailbox).Scan(&oldUID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var newUID uint32
+		var modSeq uint64
+		err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1, highest_mod_seq = highest_mod_seq + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1, highest_mod_seq", userID, destMailbox).Scan(&newUID, &modSeq)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE messages SET mailbox = ?, uid = ?, mod_seq = ? WHERE id = ?", destMailbox, newUID, modSeq, id)
+		if err != nil {
+			return nil, err
+		}
+		uidMap[oldUID] = newUID
+		moved = append(moved, id)
+		movedUIDs = append(movedUIDs, oldUID)
+	}
+
+	if err := recordExpunged(ctx, tx, userID, srcMailbox, movedUIDs); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE mailboxes SET message_count = MAX(message_count - ?, 0) WHERE user_id = ? AND name = ?", len(moved), userID, srcMailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Notify (cross-instance via NotificationBus)
+	if r.notificationBus != nil {
+		for _, id := range moved {
+			_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+				UserID:    userID,
+				Mailbox:   srcMailbox,
+				EventType: "message_deleted",
+				MessageID: id,
+			})
+			_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+				UserID:    userID,
+				Mailbox:   destMailbox,
+				EventType: "new_message",
+				MessageID: id,
+			})
+		}
+	}
+
+	return uidMap, nil
+}
+
+// DeleteMessages permanently removes messages from a mailbox, releasing their size from
+// userID's storage, and returns the blob paths that no remaining message references
+func (r *EmailRepository) DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var paths, deleted []string
+	var uids []uint32
+	var released int64
+	seen := make(map[string]bool)
+	for _, msg := range msgs {
+		var bodyPath string
+		var uid uint32
+		err := tx.QueryRowContext(ctx, "DELETE FROM messages WHERE id = ? AND recipient = ? AND mailbox = ? RETURNING body_path, uid", msg.ID, userID, mailbox).Scan(&bodyPath, &uid)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		deleted = append(deleted, msg.ID)
+		uids = append(uids, uid)
+		released += msg.Size
+		if bodyPath != "" && !seen[bodyPath] {
+			seen[bodyPath] = true
+			paths = append(paths, bodyPath)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE mailboxes SET message_count = MAX(message_count - ?, 0) WHERE user_id = ? AND name = ?", len(deleted), userID, mailbox)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	if err := recordExpunged(ctx, tx, userID, mailbox, uids); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE users SET storage_used = storage_used - ? WHERE email = ?", released, userID)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	// Copies share the blob of the original; only unreferenced blobs are released
+	var orphaned []string
+	for _, path := range paths {
+		var refs int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages WHERE body_path = ?", path).Scan(&refs); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		if refs == 0 {
+			orphaned = append(orphaned, path)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+
+	// Notify (cross-instance via NotificationBus)
+	if r.notificationBus != nil {
+		for _, id := range deleted {
+			_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+				UserID:    userID,
+				Mailbox:   mailbox,
+				EventType: "message_deleted",
+				MessageID: id,
+			})
+		}
+	}
+
+	return orphaned, nil
+}
+
+// FindExpunged returns the UIDs expunged from a mailbox after the given modification sequence
+func (r *EmailRepository) FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error) {
+	query := `SELECT uid FROM expunged_messages WHERE user_id = ? AND mailbox = ? AND mod_seq > ? ORDER BY uid ASC`
+	rows, err := r.db.QueryContext(ctx, query, userID, mailbox, sinceModSeq)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var uids []uint32
+	for rows.Next() {
+		var uid uint32
+		if err := rows.Scan(&uid); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+// saveMessage inserts a new message within tx, creating its mailbox if needed, and charges
+// its size to the recipient's storage
+func saveMessage(ctx context.Context, tx *sql.Tx, msg *domain.Message) error {
+	if msg.Mailbox == "" {
+		msg.Mailbox = "INBOX"
+	}
+
+	// 1. Ensure mailbox exists
+	// Using random validity if created here.
+	uidValidity := uint32(time.Now().Unix())
+	if uidValidity == 0 {
+		uidValidity = 1
+	}
+	_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO mailboxes (name, user_id, uid_validity, uid_next) VALUES (?, ?, ?, ?)", msg.Mailbox, msg.Recipient, uidValidity, 1)
+	if err != nil {
+		return err
+	}
+
+	// 2. Assign UID and modification sequence
+	err = tx.QueryRowContext(ctx, "UPDATE mailboxes SET uid_next = uid_next + 1, message_count = message_count + 1, highest_mod_seq = highest_mod_seq + 1 WHERE user_id = ? AND name = ? RETURNING uid_next - 1, highest_mod_seq", msg.Recipient, msg.Mailbox).Scan(&msg.UID, &msg.ModSeq)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO messages (
+			id, message_id, sender, recipient, subject, snippet, body_path,
+			read_state, received_at, spf_result, dkim_result, dmarc_result, dmarc_policy,
+			uid, mailbox, flags, mod_seq, size, is_starred,
+			sent_at, in_reply_to, message_references
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	readStateInt := 0
+	if msg.ReadState {
+		readStateInt = 1
+	}
+
+	isStarredInt := 0
+	if msg.IsStarred {
+		isStarredInt = 1
+	}
+
+	_, err = tx.ExecContext(ctx, query,
+		msg.ID, msg.MessageID, msg.Sender, msg.Recipient, msg.Subject, msg.Snippet,
+		msg.BodyPath, readStateInt, msg.ReceivedAt.Unix(), msg.SPFResult, msg.DKIMResult,
+		msg.DMARCResult, msg.DMARCPolicy,
+		msg.UID, msg.Mailbox, msg.Flags, msg.ModSeq, msg.Size, isStarredInt,
+		sentAtUnix(msg.SentAt), msg.InReplyTo, msg.References,
+	)
+	if err != nil {
+		return err
+	}
+
+	// 3. Charge the message to the recipient's storage
+	_, err = tx.ExecContext(ctx, "UPDATE users SET storage_used = storage_used + ? WHERE email = ?", msg.Size, msg.Recipient)
+	return err
+}
+
+// nextModSeq bumps the highest modification sequence of a mailbox (RFC 7162) and returns
+// the new value, or 0 if the mailbox has no IMAP state
+func nextModSeq(ctx context.Context, tx *sql.Tx, userID, mailbox string) (uint64, error) {
+	var modSeq uint64
+	err := tx.QueryRowContext(ctx, "UPDATE mailboxes SET highest_mod_seq = highest_mod_seq + 1 WHERE user_id = ? AND name = ? RETURNING highest_mod_seq", userID, mailbox).Scan(&modSeq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return modSeq, err
+}
+
+// notify publishes a mailbox change event (cross-instance via NotificationBus)
+func (r *EmailRepository) notify(ctx context.Context, userID, mailbox, eventType, messageID string) {
+	if r.notificationBus == nil {
+		return
+	}
+	_ = r.notificationBus.Notify(ctx, ports.NotificationEvent{
+		UserID:    userID,
+		Mailbox:   mailbox,
+		EventType: eventType,
+		MessageID: messageID,
+	})
+}
+
+// notifyFlagsChanged publishes a flags_changed event for a message whose flags or read
+// state were changed
+func (r *EmailRepository) notifyFlagsChanged(ctx context.Context, messageID string) {
+	if r.notificationBus == nil {
+		return
+	}
+	var recipient, mailbox string
+	if err := r.db.QueryRowContext(ctx, "SELECT recipient, mailbox FROM messages WHERE id = ?", messageID).Scan(&recipient, &mailbox); err != nil {
+		return
+	}
+	r.notify(ctx, recipient, mailbox, "flags_changed", messageID)
+}
+
+// touchMessage assigns a new modification sequence to a changed message
+func touchMessage(ctx context.Context, tx *sql.Tx, messageID string) error {
+	var recipient, mailbox string
+	err := tx.QueryRowContext(ctx, "SELECT recipient, mailbox FROM messages WHERE id = ?", messageID).Scan(&recipient, &mailbox)
+	if err != nil {
+		return err
+	}
+	modSeq, err := nextModSeq(ctx, tx, recipient, mailbox)
+	if err != nil || modSeq == 0 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE messages SET mod_seq = ? WHERE id = ?", modSeq, messageID)
+	return err
+}
+
+// recordExpunged keeps tombstones of UIDs removed from a mailbox so that QRESYNC clients
+// can be told which messages vanished. All of them share one new modification sequence.
+func recordExpunged(ctx context.Context, tx *sql.Tx, userID, mailbox string, uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	modSeq, err := nextModSeq(ctx, tx, userID, mailbox)
+	if err != nil || modSeq == 0 {
+		return err
+	}
+	for _, uid := range uids {
+		_, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO expunged_messages (user_id, mailbox, uid, mod_seq) VALUES (?, ?, ?, ?)", userID, mailbox, uid, modSeq)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// sentAtUnix converts a Date header time to the sent_at column, where 0 means unknown
+func sentAtUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// sentAtTime is the inverse of sentAtUnix
+func sentAtTime(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
 }
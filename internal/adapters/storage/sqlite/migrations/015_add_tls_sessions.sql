@@ -0,0 +1,16 @@
+-- Migration: Add tls_sessions table (outbound TLS session results for TLS-RPT, RFC 8460)
+CREATE TABLE IF NOT EXISTS tls_sessions (
+    id TEXT PRIMARY KEY,
+    policy_domain TEXT NOT NULL,
+    policy_type TEXT NOT NULL,
+    policy_string TEXT NOT NULL DEFAULT '',
+    mx_host TEXT NOT NULL DEFAULT '',
+    sending_ip TEXT NOT NULL DEFAULT '',
+    receiving_ip TEXT NOT NULL DEFAULT '',
+    success INTEGER NOT NULL DEFAULT 1,
+    result_type TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tls_sessions_created ON tls_sessions(created_at);
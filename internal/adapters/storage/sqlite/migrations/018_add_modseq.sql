@@ -0,0 +1,18 @@
+-- Migration: CONDSTORE/QRESYNC modification sequences (RFC 7162)
+ALTER TABLE mailboxes ADD COLUMN highest_mod_seq INTEGER DEFAULT 1;
+
+-- Mod-sequences start at 1; messages stored before this migration get the initial value
+UPDATE messages SET mod_seq = 1 WHERE mod_seq IS NULL OR mod_seq = 0;
+
+CREATE INDEX IF NOT EXISTS idx_messages_mailbox_modseq ON messages(recipient, mailbox, mod_seq);
+
+-- Tombstones of expunged messages, reported as VANISHED to resynchronizing clients
+CREATE TABLE IF NOT EXISTS expunged_messages (
+    user_id TEXT NOT NULL,
+    mailbox TEXT NOT NULL,
+    uid INTEGER NOT NULL,
+    mod_seq INTEGER NOT NULL,
+    PRIMARY KEY (user_id, mailbox, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_expunged_messages_modseq ON expunged_messages(user_id, mailbox, mod_seq);
@@ -0,0 +1,12 @@
+-- Migration: RFC 6154 special-use attributes and IMAP subscriptions
+ALTER TABLE mailboxes ADD COLUMN special_use TEXT DEFAULT '';
+ALTER TABLE mailboxes ADD COLUMN subscribed INTEGER DEFAULT 0;
+
+-- Existing mailboxes were all visible before subscriptions existed
+UPDATE mailboxes SET subscribed = 1;
+
+UPDATE mailboxes SET special_use = '\Drafts' WHERE name = 'Drafts';
+UPDATE mailboxes SET special_use = '\Sent' WHERE name = 'Sent';
+UPDATE mailboxes SET special_use = '\Junk' WHERE name = 'Junk';
+UPDATE mailboxes SET special_use = '\Trash' WHERE name = 'Trash';
+UPDATE mailboxes SET special_use = '\Archive' WHERE name = 'Archive';
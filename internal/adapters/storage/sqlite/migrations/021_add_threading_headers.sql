@@ -0,0 +1,5 @@
+-- Migration: headers used by IMAP SORT and THREAD (RFC 5256)
+-- sent_at holds the Date header as a Unix timestamp, 0 when missing or unparsable
+ALTER TABLE messages ADD COLUMN sent_at INTEGER DEFAULT 0;
+ALTER TABLE messages ADD COLUMN in_reply_to TEXT DEFAULT '';
+ALTER TABLE messages ADD COLUMN message_references TEXT DEFAULT '';
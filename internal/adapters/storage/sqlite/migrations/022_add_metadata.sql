@@ -0,0 +1,10 @@
+-- Migration: IMAP METADATA (RFC 5464)
+-- Server entries have an empty owner and mailbox; shared entries an empty user_id
+CREATE TABLE IF NOT EXISTS metadata (
+    owner TEXT NOT NULL,
+    mailbox TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value BLOB NOT NULL,
+    PRIMARY KEY (owner, mailbox, user_id, name)
+);
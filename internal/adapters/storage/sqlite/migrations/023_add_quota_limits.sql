@@ -0,0 +1,10 @@
+-- Migration: RFC 9208 quota roots
+-- A root is a user (empty mailbox), one mailbox of a user, or a domain, whose owner is the
+-- domain name. The storage limit of a user root stays in users.storage_quota.
+CREATE TABLE IF NOT EXISTS quota_limits (
+    owner TEXT NOT NULL,
+    mailbox TEXT NOT NULL DEFAULT '',
+    resource TEXT NOT NULL,
+    quota_limit INTEGER NOT NULL,
+    PRIMARY KEY (owner, mailbox, resource)
+);
@@ -0,0 +1,5 @@
+-- Migration: Domains whose keys are stored in dkim_keys no longer keep a copy on the domain row,
+-- so signing can never fall back to a key that has since been rotated out and destroyed
+UPDATE domains SET dkim_private_key = ''
+WHERE dkim_private_key IS NOT NULL AND dkim_private_key != ''
+  AND EXISTS (SELECT 1 FROM dkim_keys k WHERE k.domain = domains.name);
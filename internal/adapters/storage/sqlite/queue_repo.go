@@ -4,11 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 )
 
+// queueColumns is the column list scanned by scanQueueMessage
+const queueColumns = `id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error, delay_warnings, route, local_delivery`
+
 type QueueRepository struct {
 	db *sql.DB
 }
@@ -17,12 +22,36 @@ func NewQueueRepository(db *sql.DB) *QueueRepository {
 	return &QueueRepository{db: db}
 }
 
+// enqueueQuery inserts one message into the outbound queue
+const enqueueQuery = `
+	INSERT INTO queue (id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error, route, local_delivery)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
 func (r *QueueRepository) Enqueue(ctx context.Context, msg *domain.OutboundMessage) error {
-	query := `
-		INSERT INTO queue (id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.db.ExecContext(ctx, enqueueQuery, enqueueArgs(msg)...)
+	return err
+}
+
+// EnqueueAll adds messages to the outbound queue in one transaction: all of them or none
+func (r *QueueRepository) EnqueueAll(ctx context.Context, msgs []*domain.OutboundMessage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, msg := range msgs {
+		if _, err := tx.ExecContext(ctx, enqueueQuery, enqueueArgs(msg)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// enqueueArgs returns the parameters of enqueueQuery for a message
+func enqueueArgs(msg *domain.OutboundMessage) []interface{} {
+	return []interface{}{
 		msg.ID,
 		msg.Sender,
 		msg.Recipient,
@@ -33,8 +62,9 @@ func (r *QueueRepository) Enqueue(ctx context.Context, msg *domain.OutboundMessa
 		msg.NextRetryAt.Unix(),
 		msg.RetryCount,
 		msg.LastError,
-	)
-	return err
+		msg.Route,
+		msg.Local,
+	}
 }
 
 func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMessage, error) {
@@ -51,24 +81,14 @@ func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMe
 	// Find next ready message
 	// Status must be PENDING or RETRYING, and NextRetryAt must be in the past
 	row := tx.QueryRowContext(ctx, `
-		SELECT id, sender, recipient, blob_key, status, created_at, updated_at, next_retry_at, retry_count, last_error
+		SELECT `+queueColumns+`
 		FROM queue
 		WHERE status IN ('PENDING', 'RETRYING') AND next_retry_at <= ?
 		ORDER BY next_retry_at ASC
 		LIMIT 1
 	`, time.Now().Unix())
 
-	var msg domain.OutboundMessage
-	var createdAt, updatedAt, nextRetryAt int64
-	var statusStr string
-	// sql.NullString for last_error since it can be NULL
-	var lastError sql.NullString
-
-	err = row.Scan(
-		&msg.ID, &msg.Sender, &msg.Recipient, &msg.BlobKey, &statusStr,
-		&createdAt, &updatedAt, &nextRetryAt, &msg.RetryCount, &lastError,
-	)
-
+	msg, err := scanQueueMessage(row)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // No messages ready
 	}
@@ -76,14 +96,6 @@ func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMe
 		return nil, err
 	}
 
-	msg.Status = domain.OutboundStatus(statusStr)
-	msg.CreatedAt = time.Unix(createdAt, 0).UTC()
-	msg.UpdatedAt = time.Unix(updatedAt, 0).UTC()
-	msg.NextRetryAt = time.Unix(nextRetryAt, 0).UTC()
-	if lastError.Valid {
-		msg.LastError = lastError.String
-	}
-
 	// Lock it by setting status to PROCESSING
 	updateQuery := `UPDATE queue SET status = ?, updated_at = ? WHERE id = ?`
 	_, err = tx.ExecContext(ctx, updateQuery, domain.QueueStatusProcessing, time.Now().Unix(), msg.ID)
@@ -98,7 +110,7 @@ func (r *QueueRepository) LockNextReady(ctx context.Context) (*domain.OutboundMe
 	// Update returned struct
 	msg.Status = domain.QueueStatusProcessing
 
-	return &msg, nil
+	return msg, nil
 }
 
 func (r *QueueRepository) UpdateStatus(ctx context.Context, id string, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
@@ -118,6 +130,48 @@ func (r *QueueRepository) UpdateStatus(ctx context.Context, id string, status do
 	return err
 }
 
+// TransitionStatus updates a message like UpdateStatus, but only while it is still in the from status
+func (r *QueueRepository) TransitionStatus(ctx context.Context, id string, from, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error {
+	query := `
+		UPDATE queue
+		SET status = ?, updated_at = ?, retry_count = ?, next_retry_at = ?, last_error = ?
+		WHERE id = ? AND status = ?
+	`
+	res, err := r.db.ExecContext(ctx, query,
+		status,
+		time.Now().Unix(),
+		retryCount,
+		nextRetry.Unix(),
+		lastError,
+		id,
+		from,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	var exists int
+	err = r.db.QueryRowContext(ctx, `SELECT 1 FROM queue WHERE id = ?`, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ports.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ports.ErrConflict
+}
+
+// SetDelayWarnings records how many "delayed" DSNs have been sent for a message
+func (r *QueueRepository) SetDelayWarnings(ctx context.Context, id string, count int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE queue SET delay_warnings = ? WHERE id = ?`, count, id)
+	return err
+}
+
 // Stats returns queue statistics
 func (r *QueueRepository) Stats(ctx context.Context) (int64, int64, int64, int64, error) {
 	query := `SELECT status, COUNT(*) FROM queue GROUP BY status`
@@ -148,3 +202,232 @@ func (r *QueueRepository) Stats(ctx context.Context) (int64, int64, int64, int64
 	}
 	return pending, processing, failed, completed, rows.Err()
 }
+
+// LockReadyForDomain locks up to limit further ready messages for recipients in the domain
+func (r *QueueRepository) LockReadyForDomain(ctx context.Context, recipientDomain string, limit int) ([]*domain.OutboundMessage, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		//nolint:errcheck // Rollback safe to ignore
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+queueColumns+`
+		FROM queue
+		WHERE status IN ('PENDING', 'RETRYING') AND next_retry_at <= ? AND LOWER(recipient) LIKE ? ESCAPE '\'
+		ORDER BY next_retry_at ASC
+		LIMIT ?
+	`, time.Now().Unix(), domainPattern(recipientDomain), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []*domain.OutboundMessage
+	for rows.Next() {
+		msg, err := scanQueueMessage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		msg.Status = domain.QueueStatusProcessing
+		msgs = append(msgs, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for _, msg := range msgs {
+		if _, err := tx.ExecContext(ctx, `UPDATE queue SET status = ?, updated_at = ? WHERE id = ?`, domain.QueueStatusProcessing, now, msg.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// RequeueStale returns messages stuck in PROCESSING since before the cutoff to RETRYING
+func (r *QueueRepository) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE queue SET status = ?, next_retry_at = ?, updated_at = ?
+		WHERE status = ? AND updated_at < ?
+	`, domain.QueueStatusRetrying, time.Now().Unix(), time.Now().Unix(), domain.QueueStatusProcessing, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// List returns queued messages matching the filter, oldest first
+func (r *QueueRepository) List(ctx context.Context, filter domain.QueueFilter) ([]*domain.OutboundMessage, error) {
+	query := `SELECT ` + queueColumns + ` FROM queue WHERE 1=1`
+	var args []interface{}
+
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Domain != "" {
+		query += ` AND LOWER(recipient) LIKE ? ESCAPE '\'`
+		args = append(args, domainPattern(filter.Domain))
+	}
+	if filter.Sender != "" {
+		query += ` AND LOWER(sender) = ?`
+		args = append(args, strings.ToLower(filter.Sender))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += ` ORDER BY created_at ASC, id ASC LIMIT ? OFFSET ?`
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*domain.OutboundMessage
+	for rows.Next() {
+		msg, err := scanQueueMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// Get retrieves a queued message by ID
+func (r *QueueRepository) Get(ctx context.Context, id string) (*domain.OutboundMessage, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+queueColumns+` FROM queue WHERE id = ?`, id)
+	msg, err := scanQueueMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ports.ErrNotFound
+	}
+	return msg, err
+}
+
+// Delete removes a message and its delivery history
+func (r *QueueRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		//nolint:errcheck // Rollback safe to ignore
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM queue WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ports.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM queue_attempts WHERE message_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordAttempt appends an entry to a message's delivery history
+func (r *QueueRepository) RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO queue_attempts (message_id, status, error, attempted_at)
+		VALUES (?, ?, ?, ?)
+	`, attempt.MessageID, attempt.Status, attempt.Error, attempt.AttemptedAt.Unix())
+	return err
+}
+
+// ListAttempts returns a message's delivery history, oldest first
+func (r *QueueRepository) ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT message_id, status, error, attempted_at
+		FROM queue_attempts
+		WHERE message_id = ?
+		ORDER BY attempted_at ASC, id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*domain.DeliveryAttempt
+	for rows.Next() {
+		var attempt domain.DeliveryAttempt
+		var status string
+		var attemptedAt int64
+		if err := rows.Scan(&attempt.MessageID, &status, &attempt.Error, &attemptedAt); err != nil {
+			return nil, err
+		}
+		attempt.Status = domain.OutboundStatus(status)
+		attempt.AttemptedAt = time.Unix(attemptedAt, 0).UTC()
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// Reroute sends every undelivered message for a recipient domain through the relay host.
+// Local redeliveries never leave the server and are not rerouted.
+func (r *QueueRepository) Reroute(ctx context.Context, recipientDomain, relay string) (int64, error) {
+	now := time.Now().Unix()
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE queue SET route = ?, next_retry_at = CASE WHEN status = 'HELD' THEN next_retry_at ELSE ? END, updated_at = ?
+		WHERE status IN ('PENDING', 'RETRYING', 'HELD') AND local_delivery = 0 AND LOWER(recipient) LIKE ? ESCAPE '\'
+	`, relay, now, now, domainPattern(recipientDomain))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQueueMessage scans a row selected with queueColumns
+func scanQueueMessage(row rowScanner) (*domain.OutboundMessage, error) {
+	var msg domain.OutboundMessage
+	var createdAt, updatedAt, nextRetryAt int64
+	var statusStr string
+	// sql.NullString for last_error since it can be NULL
+	var lastError sql.NullString
+
+	if err := row.Scan(
+		&msg.ID, &msg.Sender, &msg.Recipient, &msg.BlobKey, &statusStr,
+		&createdAt, &updatedAt, &nextRetryAt, &msg.RetryCount, &lastError, &msg.DelayWarnings, &msg.Route, &msg.Local,
+	); err != nil {
+		return nil, err
+	}
+
+	msg.Status = domain.OutboundStatus(statusStr)
+	msg.CreatedAt = time.Unix(createdAt, 0).UTC()
+	msg.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+	msg.NextRetryAt = time.Unix(nextRetryAt, 0).UTC()
+	msg.LastError = lastError.String
+	return &msg, nil
+}
+
+// domainPattern builds a LIKE pattern matching addresses in the domain
+func domainPattern(recipientDomain string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(recipientDomain))
+	return "%@" + escaped
+}
@@ -67,6 +67,33 @@ func (r *SearchRepository) Search(ctx context.Context, userEmail, query string,
 	return results, nil
 }
 
+// SearchMailbox returns the IDs of all messages in the mailbox matching the query
+func (r *SearchRepository) SearchMailbox(ctx context.Context, userEmail, mailbox, query string) ([]string, error) {
+	ftsQuery := `
+		SELECT m.id
+		FROM messages_fts fts
+		JOIN messages m ON fts.message_id = m.id
+		WHERE fts.recipient = ? AND m.mailbox = ? AND messages_fts MATCH ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, ftsQuery, userEmail, mailbox, query)
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
 // Delete removes a message from the index
 func (r *SearchRepository) Delete(ctx context.Context, messageID string) error {
 	// Trigger handles deletion when message is deleted from messages table
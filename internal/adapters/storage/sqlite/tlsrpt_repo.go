@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
@@ -122,3 +123,100 @@ func (r *TLSRptRepository) FindLatest(ctx context.Context, limit int) ([]*domain
 
 	return reports, nil
 }
+
+// RecordSession stores the outcome of one outbound TLS session
+func (r *TLSRptRepository) RecordSession(ctx context.Context, result *domain.TLSSessionResult) error {
+	query := `
+		INSERT INTO tls_sessions (
+			id, policy_domain, policy_type, policy_string, mx_host,
+			sending_ip, receiving_ip, success, result_type, details, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	success := 0
+	if result.Success {
+		success = 1
+	}
+
+	_, err := r.db.ExecContext(ctx, query,
+		uuid.New().String(),
+		result.PolicyDomain,
+		result.PolicyType,
+		strings.Join(result.PolicyString, "\n"),
+		result.MXHost,
+		result.SendingIP,
+		result.ReceivingIP,
+		success,
+		result.ResultType,
+		result.Details,
+		result.Timestamp.UTC(),
+	)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// ListSessions returns outbound TLS session results recorded in [start, end)
+func (r *TLSRptRepository) ListSessions(ctx context.Context, start, end time.Time) ([]*domain.TLSSessionResult, error) {
+	query := `
+		SELECT policy_domain, policy_type, policy_string, mx_host,
+			sending_ip, receiving_ip, success, result_type, details, created_at
+		FROM tls_sessions
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY policy_domain, created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, ports.ErrStorageFailure
+	}
+	defer rows.Close()
+
+	var results []*domain.TLSSessionResult
+	for rows.Next() {
+		var res domain.TLSSessionResult
+		var policyString string
+		var success int
+
+		if err := rows.Scan(
+			&res.PolicyDomain,
+			&res.PolicyType,
+			&policyString,
+			&res.MXHost,
+			&res.SendingIP,
+			&res.ReceivingIP,
+			&success,
+			&res.ResultType,
+			&res.Details,
+			&res.Timestamp,
+		); err != nil {
+			return nil, ports.ErrStorageFailure
+		}
+
+		if policyString != "" {
+			res.PolicyString = strings.Split(policyString, "\n")
+		}
+		res.Success = success == 1
+		results = append(results, &res)
+	}
+
+	return results, rows.Err()
+}
+
+// DeleteSessions removes the outbound TLS session results of a policy domain recorded in [start, end)
+func (r *TLSRptRepository) DeleteSessions(ctx context.Context, policyDomain string, start, end time.Time) error {
+	query := `DELETE FROM tls_sessions WHERE policy_domain = ? AND created_at >= ? AND created_at < ?`
+	if _, err := r.db.ExecContext(ctx, query, policyDomain, start.UTC(), end.UTC()); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
+
+// DeleteSessionsBefore removes outbound TLS session results recorded before t
+func (r *TLSRptRepository) DeleteSessionsBefore(ctx context.Context, t time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM tls_sessions WHERE created_at < ?`, t.UTC()); err != nil {
+		return ports.ErrStorageFailure
+	}
+	return nil
+}
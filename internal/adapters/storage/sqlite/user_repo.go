@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -27,12 +28,13 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 		user.Role = domain.RoleUser
 	}
 	query := `
-		INSERT INTO users (email, password_hash, role, created_at, last_login_at, storage_quota, storage_used)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO users (email, password_hash, role, created_at, last_login_at, storage_quota, storage_used, scram_sha1, scram_sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		user.Email, user.PasswordHash, user.Role, user.CreatedAt.Unix(), user.LastLoginAt.Unix(), user.StorageQuota, user.StorageUsed,
+		marshalSCRAM(user.SCRAMSHA1), marshalSCRAM(user.SCRAMSHA256),
 	)
 	if err != nil {
 		// Check for unique constraint violation
@@ -48,17 +50,18 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 // FindByEmail retrieves user by email address
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	query := `
-		SELECT email, password_hash, role, created_at, last_login_at, storage_quota, storage_used
+		SELECT email, password_hash, role, created_at, last_login_at, storage_quota, storage_used, scram_sha1, scram_sha256
 		FROM users
 		WHERE email = ?
 	`
 
 	user := &domain.User{}
 	var createdAtUnix, lastLoginAtUnix int64
-	var role sql.NullString
+	var role, scramSHA1, scramSHA256 sql.NullString
 
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.Email, &user.PasswordHash, &role, &createdAtUnix, &lastLoginAtUnix, &user.StorageQuota, &user.StorageUsed,
+		&scramSHA1, &scramSHA256,
 	)
 
 	if err == sql.ErrNoRows {
@@ -75,6 +78,8 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain
 	} else {
 		user.Role = domain.RoleUser
 	}
+	user.SCRAMSHA1 = unmarshalSCRAM(scramSHA1.String)
+	user.SCRAMSHA256 = unmarshalSCRAM(scramSHA256.String)
 
 	return user, nil
 }
@@ -177,9 +182,9 @@ func (r *UserRepository) Delete(ctx context.Context, email string) error {
 }
 
 // UpdatePassword updates a user's password
-func (r *UserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
-	query := "UPDATE users SET password_hash = ? WHERE email = ?"
-	result, err := r.db.ExecContext(ctx, query, passwordHash, email)
+func (r *UserRepository) UpdatePassword(ctx context.Context, email, passwordHash string, sha1, sha256 *domain.SCRAMCredentials) error {
+	query := "UPDATE users SET password_hash = ?, scram_sha1 = ?, scram_sha256 = ? WHERE email = ?"
+	result, err := r.db.ExecContext(ctx, query, passwordHash, marshalSCRAM(sha1), marshalSCRAM(sha256), email)
 	if err != nil {
 		return ports.ErrStorageFailure
 	}
@@ -214,6 +219,49 @@ func (r *UserRepository) UpdateRole(ctx context.Context, email string, role doma
 	return nil
 }
 
+// UpdateSCRAMCredentials stores the SCRAM-SHA-1 and SCRAM-SHA-256 credentials of a user
+func (r *UserRepository) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	query := "UPDATE users SET scram_sha1 = ?, scram_sha256 = ? WHERE email = ?"
+	result, err := r.db.ExecContext(ctx, query, marshalSCRAM(sha1), marshalSCRAM(sha256), email)
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return ports.ErrStorageFailure
+	}
+	if rows == 0 {
+		return ports.ErrNotFound
+	}
+
+	return nil
+}
+
+// marshalSCRAM encodes SCRAM credentials for storage (empty when unset)
+func marshalSCRAM(creds *domain.SCRAMCredentials) string {
+	if creds == nil {
+		return ""
+	}
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalSCRAM decodes stored SCRAM credentials, returning nil when unset or unreadable
+func unmarshalSCRAM(data string) *domain.SCRAMCredentials {
+	if data == "" {
+		return nil
+	}
+	creds := &domain.SCRAMCredentials{}
+	if err := json.Unmarshal([]byte(data), creds); err != nil {
+		return nil
+	}
+	return creds
+}
+
 // UpdateQuota sets the max storage in bytes for a user (0 = unlimited)
 func (r *UserRepository) UpdateQuota(ctx context.Context, email string, bytes int64) error {
 	query := "UPDATE users SET storage_quota = ? WHERE email = ?"
@@ -0,0 +1,13 @@
+// Package tlsutil holds TLS helpers shared by the protocol servers
+package tlsutil
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+// IsTLSConn reports whether conn is already encrypted, e.g. accepted on the implicit TLS port
+func IsTLSConn(conn net.Conn) bool {
+	_, ok := conn.(*tls.Conn)
+	return ok
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -63,10 +64,39 @@ type ObjectStoreConfig struct {
 
 // SMTPConfig contains SMTP server settings
 type SMTPConfig struct {
-	Port     int        `yaml:"port"`     // SMTP listen port (default: 25)
-	Hostname string     `yaml:"hostname"` // SMTP HELO hostname
-	MaxSize  int64      `yaml:"max_size"` // Maximum message size in bytes (default: 10MB)
-	DANE     DANEConfig `yaml:"dane"`     // DANE verification settings
+	Port     int          `yaml:"port"`     // SMTP listen port (default: 25)
+	Hostname string       `yaml:"hostname"` // SMTP HELO hostname
+	MaxSize  int64        `yaml:"max_size"` // Maximum message size in bytes (default: 10MB)
+	DANE     DANEConfig   `yaml:"dane"`     // DANE verification settings
+	MTASTS   MTASTSConfig `yaml:"mta_sts"`  // Outbound MTA-STS policy enforcement (RFC 8461)
+
+	Submission SubmissionConfig `yaml:"submission"`    // Authenticated message submission (RFC 6409)
+	Delivery   DeliveryConfig   `yaml:"delivery"`      // Outbound delivery settings
+	TLSRPT     TLSRPTConfig     `yaml:"tls_reporting"` // Outbound TLS reporting (RFC 8460)
+}
+
+// TLSRPTConfig contains settings for the TLS reports we send about our outbound sessions
+type TLSRPTConfig struct {
+	Enabled      bool   `yaml:"enabled"`      // Send daily aggregate reports to domains that request them
+	Organization string `yaml:"organization"` // organization-name in reports (default: domain)
+	ContactInfo  string `yaml:"contact_info"` // contact-info in reports (default: postmaster@domain)
+}
+
+// DeliveryConfig contains outbound delivery settings
+type DeliveryConfig struct {
+	DelayWarnings    []string `yaml:"delay_warnings"`    // Queue ages at which "delayed" DSNs are sent (default: ["4h", "24h"])
+	Concurrency      int      `yaml:"concurrency"`       // Total concurrent deliveries (default: 16)
+	PerDomain        int      `yaml:"per_domain"`        // Concurrent deliveries per recipient domain (default: 4)
+	PerMX            int      `yaml:"per_mx"`            // Concurrent SMTP sessions per MX host (default: 4)
+	BatchSize        int      `yaml:"batch_size"`        // Maximum messages delivered in one SMTP session (default: 50)
+	OpportunisticTLS bool     `yaml:"opportunistic_tls"` // Accept MX certificates that fail PKIX validation when no MTA-STS policy is enforced (RFC 7435). Default: false
+}
+
+// SubmissionConfig contains authenticated submission listener settings
+type SubmissionConfig struct {
+	Enabled bool `yaml:"enabled"`  // Enable submission listeners
+	Port    int  `yaml:"port"`     // STARTTLS submission port (default: 587)
+	PortTLS int  `yaml:"port_tls"` // Implicit TLS submission port (default: 465, RFC 8314)
 }
 
 // DANEConfig contains DANE verification settings
@@ -74,6 +104,11 @@ type DANEConfig struct {
 	Mode string `yaml:"mode"` // Mode: "off", "advisory" (log only), "enforce" (fail delivery). Default: "advisory"
 }
 
+// MTASTSConfig contains outbound MTA-STS (RFC 8461) settings
+type MTASTSConfig struct {
+	Mode string `yaml:"mode"` // Mode: "off", "advisory" (treat all policies as testing), "enforce" (honour policy mode). Default: "enforce"
+}
+
 // APIConfig contains REST API settings
 type APIConfig struct {
 	Host        string   `yaml:"host"`         // HTTP listen host (default: "0.0.0.0")
@@ -146,6 +181,7 @@ type IMAPConfig struct {
 	AllowInsecureAuth bool   `yaml:"allow_insecure_auth"` // Allow LOGIN on insecure connection
 	TLSCert           string `yaml:"tls_cert"`            // TLS certificate path
 	TLSKey            string `yaml:"tls_key"`             // TLS key path
+	MaxAppendSize     int64  `yaml:"max_append_size"`     // Maximum total size of the messages of one APPEND in bytes (default: 128MB)
 }
 
 // RateLimitConfig contains rate limiting settings
@@ -164,8 +200,9 @@ type BackupConfig struct {
 
 // ManageSieveConfig contains ManageSieve server settings
 type ManageSieveConfig struct {
-	Enabled bool `yaml:"enabled"` // Enable ManageSieve server (default: true)
-	Port    int  `yaml:"port"`    // ManageSieve listen port (default: 4190)
+	Enabled bool `yaml:"enabled"`  // Enable ManageSieve server (default: true)
+	Port    int  `yaml:"port"`     // ManageSieve listen port (default: 4190)
+	PortTLS int  `yaml:"port_tls"` // Implicit TLS listen port (0 = disabled; there is no standard port)
 }
 
 // LoadFromFile loads configuration from a YAML file
@@ -195,6 +232,30 @@ func LoadFromFile(path string) (*Config, error) {
 	if cfg.SMTP.DANE.Mode == "" {
 		cfg.SMTP.DANE.Mode = "advisory"
 	}
+	if cfg.SMTP.MTASTS.Mode == "" {
+		cfg.SMTP.MTASTS.Mode = "enforce"
+	}
+	if cfg.SMTP.Submission.Port == 0 {
+		cfg.SMTP.Submission.Port = 587
+	}
+	if cfg.SMTP.Submission.PortTLS == 0 {
+		cfg.SMTP.Submission.PortTLS = 465
+	}
+	if cfg.SMTP.Delivery.DelayWarnings == nil {
+		cfg.SMTP.Delivery.DelayWarnings = []string{"4h", "24h"}
+	}
+	if cfg.SMTP.Delivery.Concurrency == 0 {
+		cfg.SMTP.Delivery.Concurrency = 16
+	}
+	if cfg.SMTP.Delivery.PerDomain == 0 {
+		cfg.SMTP.Delivery.PerDomain = 4
+	}
+	if cfg.SMTP.Delivery.PerMX == 0 {
+		cfg.SMTP.Delivery.PerMX = 4
+	}
+	if cfg.SMTP.Delivery.BatchSize == 0 {
+		cfg.SMTP.Delivery.BatchSize = 50
+	}
 	if cfg.API.Host == "" {
 		cfg.API.Host = "0.0.0.0"
 	}
@@ -234,6 +295,12 @@ func LoadFromFile(path string) (*Config, error) {
 	if cfg.ManageSieve.Port == 0 {
 		cfg.ManageSieve.Port = 4190
 	}
+	if cfg.IMAP.Port == 0 {
+		cfg.IMAP.Port = 143
+	}
+	if cfg.IMAP.PortTLS == 0 {
+		cfg.IMAP.PortTLS = 993
+	}
 	if len(cfg.API.CORSOrigins) == 0 {
 		cfg.API.CORSOrigins = []string{"*"}
 	}
@@ -341,6 +408,11 @@ func (c *Config) Validate() error {
 	if c.DKIM.PrivateKeyPath == "" {
 		return fmt.Errorf("dkim.private_key_path is required")
 	}
+	for _, d := range c.SMTP.Delivery.DelayWarnings {
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("smtp.delivery.delay_warnings: invalid duration %q", d)
+		}
+	}
 
 	return nil
 }
@@ -12,3 +12,49 @@ type Domain struct {
 	DKIMPrivateKey string    `json:"-"` // Never expose private key via JSON
 	DKIMPublicKey  string    `json:"dkim_public_key"`
 }
+
+// DKIMKeyStatus is the lifecycle state of a DKIM signing key
+type DKIMKeyStatus string
+
+const (
+	// DKIMKeyPending keys are generated but not yet signing; their DNS record must be published first
+	DKIMKeyPending DKIMKeyStatus = "pending"
+	// DKIMKeyActive keys sign outbound mail
+	DKIMKeyActive DKIMKeyStatus = "active"
+	// DKIMKeyRetiring keys were replaced; their DNS record stays published until RetireAt
+	// so that signatures on mail still in transit keep verifying
+	DKIMKeyRetiring DKIMKeyStatus = "retiring"
+	// DKIMKeyRetired keys have been destroyed; their DNS record can be removed
+	DKIMKeyRetired DKIMKeyStatus = "retired"
+)
+
+// DKIM key algorithms
+const (
+	DKIMAlgorithmRSA     = "rsa"     // rsa-sha256 (RFC 6376)
+	DKIMAlgorithmEd25519 = "ed25519" // ed25519-sha256 (RFC 8463)
+)
+
+// DKIMKey is one selector of a domain's DKIM keys. A domain signs with every active key,
+// typically one RSA and one Ed25519 key.
+type DKIMKey struct {
+	Domain      string        `json:"domain"`
+	Selector    string        `json:"selector"`
+	Algorithm   string        `json:"algorithm"`
+	PrivateKey  string        `json:"-"`          // PEM; cleared when the key is retired
+	PublicKey   string        `json:"public_key"` // Base64 value of the DNS record's p= tag
+	Status      DKIMKeyStatus `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+	VerifiedAt  time.Time     `json:"verified_at"`  // Zero until the DNS record was confirmed
+	ActivatedAt time.Time     `json:"activated_at"` // Zero until the key signed mail
+	RetireAt    time.Time     `json:"retire_at"`    // Set when the key starts retiring
+}
+
+// DNSName returns the owner name of the key's TXT record
+func (k *DKIMKey) DNSName() string {
+	return k.Selector + "._domainkey." + k.Domain
+}
+
+// DNSValue returns the TXT record to publish for the key (RFC 6376 Section 3.6.1)
+func (k *DKIMKey) DNSValue() string {
+	return "v=DKIM1; k=" + k.Algorithm + "; p=" + k.PublicKey
+}
@@ -17,3 +17,17 @@ type MessageFilter struct {
 	IsStarred *bool  // nil = all, true = starred only
 	DateRange DateRangeFilter
 }
+
+// MailboxFilter narrows the messages of a single mailbox, e.g. for an IMAP SEARCH.
+// Zero values do not restrict.
+type MailboxFilter struct {
+	MinUID       uint32
+	MaxUID       uint32
+	Flags        []string  // Messages must have every flag (a read message has \Seen)
+	NotFlags     []string  // Messages must have none of the flags
+	Since        time.Time // ReceivedAt on or after
+	Before       time.Time // ReceivedAt before
+	Larger       int64     // Size greater than; messages of unknown size (0) always match
+	Smaller      int64     // Size less than; messages of unknown size (0) always match
+	ChangedSince uint64    // ModSeq greater than (RFC 7162 CHANGEDSINCE and MODSEQ)
+}
@@ -1,11 +1,50 @@
 package domain
 
+// MailboxDelimiter separates the levels of hierarchical mailbox names, e.g. "Archive/2024"
+const MailboxDelimiter = "/"
+
+// RFC 6154 special-use attributes
+const (
+	SpecialUseArchive = "\\Archive"
+	SpecialUseDrafts  = "\\Drafts"
+	SpecialUseJunk    = "\\Junk"
+	SpecialUseSent    = "\\Sent"
+	SpecialUseTrash   = "\\Trash"
+)
+
 // Mailbox represents an IMAP folder/mailbox used to group messages
 type Mailbox struct {
-	Name         string            // Primary Key (Composite with UserID). e.g., "INBOX"
-	UserID       string            // Owner of the mailbox
-	UIDValidity  uint32            // Random non-zero integer. Changes if UIDs are reset.
-	UIDNext      uint32            // Next UID to assign to a new message. Starts at 1.
-	MessageCount int               // Cached count of messages in this mailbox
-	ACL          map[string]string // Access Control List (Identifier -> Rights)
+	Name          string            // Primary Key (Composite with UserID). e.g., "INBOX"
+	UserID        string            // Owner of the mailbox
+	UIDValidity   uint32            // Random non-zero integer. Changes if UIDs are reset.
+	UIDNext       uint32            // Next UID to assign to a new message. Starts at 1.
+	MessageCount  int               // Cached count of messages in this mailbox
+	HighestModSeq uint64            // Bumped on every message change, including expunges (RFC 7162)
+	SpecialUse    string            // RFC 6154 attribute such as "\Sent", empty for ordinary mailboxes
+	Subscribed    bool              // Included in LSUB and LIST (SUBSCRIBED) responses
+	ACL           map[string]string // Access Control List (Identifier -> Rights)
+}
+
+// DefaultMailboxes is the folder set created for every new user
+var DefaultMailboxes = []Mailbox{
+	{Name: "INBOX"},
+	{Name: "Drafts", SpecialUse: SpecialUseDrafts},
+	{Name: "Sent", SpecialUse: SpecialUseSent},
+	{Name: "Junk", SpecialUse: SpecialUseJunk},
+	{Name: "Trash", SpecialUse: SpecialUseTrash},
+	{Name: "Archive", SpecialUse: SpecialUseArchive},
+}
+
+// RFC 5464 metadata entry prefixes: private entries belong to the user who set them,
+// shared entries are seen by everyone with access to the mailbox
+const (
+	MetadataPrivate = "/private"
+	MetadataShared  = "/shared"
+)
+
+// MetadataEntry is an annotation of a mailbox or of the server (RFC 5464), e.g.
+// "/private/color". When setting entries, a nil Value removes the entry.
+type MetadataEntry struct {
+	Name  string
+	Value []byte
 }
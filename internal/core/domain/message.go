@@ -22,6 +22,11 @@ type Message struct {
 	Flags   string // Space-separated list of flags (e.g., "\Seen \Flagged")
 	ModSeq  uint64 // Modification Sequence (for CONDSTORE)
 
+	// Headers used by IMAP SORT and THREAD (RFC 5256)
+	SentAt     time.Time // Date header value; zero if missing or unparsable
+	InReplyTo  string    // In-Reply-To header value
+	References string    // References header value
+
 	// Email authentication results (from SPF/DKIM/DMARC validation)
 	SPFResult   string // "pass", "fail", "softfail", "neutral", "none"
 	DKIMResult  string // "pass", "fail", "none"
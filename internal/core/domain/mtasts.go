@@ -2,6 +2,7 @@ package domain
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -40,3 +41,81 @@ func (p *MTASTSPolicy) BuildPolicyString() string {
 
 	return sb.String()
 }
+
+// ParseMTASTSPolicy parses the body of a fetched mta-sts.txt file (RFC 8461 Section 3.2).
+// Unknown keys are ignored; version, mode, max_age and (unless mode is none) mx are required.
+func ParseMTASTSPolicy(body string) (*MTASTSPolicy, error) {
+	p := &MTASTSPolicy{}
+	maxAgeSeen := false
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed policy line %q", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "version":
+			p.Version = value
+		case "mode":
+			p.Mode = MTASTSMode(value)
+		case "mx":
+			p.MX = append(p.MX, strings.ToLower(value))
+		case "max_age":
+			age, err := strconv.Atoi(value)
+			if err != nil || age < 0 {
+				return nil, fmt.Errorf("invalid max_age %q", value)
+			}
+			// RFC 8461 caps max_age at one year
+			if age > 31557600 {
+				age = 31557600
+			}
+			p.MaxAge = age
+			maxAgeSeen = true
+		}
+	}
+
+	if p.Version != "STSv1" {
+		return nil, fmt.Errorf("unsupported policy version %q", p.Version)
+	}
+	switch p.Mode {
+	case MTASTSModeEnforce, MTASTSModeTesting:
+		if len(p.MX) == 0 {
+			return nil, fmt.Errorf("policy in mode %s has no mx patterns", p.Mode)
+		}
+	case MTASTSModeNone:
+	default:
+		return nil, fmt.Errorf("invalid policy mode %q", p.Mode)
+	}
+	if !maxAgeSeen {
+		return nil, fmt.Errorf("policy is missing max_age")
+	}
+
+	return p, nil
+}
+
+// MatchesMX reports whether an MX hostname is permitted by the policy.
+// A pattern of the form "*.example.com" matches exactly one leftmost label.
+func (p *MTASTSPolicy) MatchesMX(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			label, rest, found := strings.Cut(host, ".")
+			if found && label != "" && rest == suffix {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
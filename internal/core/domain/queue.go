@@ -13,6 +13,7 @@ const (
 	QueueStatusSent       OutboundStatus = "SENT"
 	QueueStatusFailed     OutboundStatus = "FAILED" // Permanent failure
 	QueueStatusRetrying   OutboundStatus = "RETRYING"
+	QueueStatusHeld       OutboundStatus = "HELD" // Held by an administrator; not delivered until released
 )
 
 // OutboundMessage represents an email waiting to be delivered
@@ -27,4 +28,25 @@ type OutboundMessage struct {
 	NextRetryAt time.Time
 	RetryCount  int
 	LastError   string
+
+	DelayWarnings int    // Number of "delayed" DSNs already sent to the sender
+	Route         string // Relay host used instead of the recipient domain's MX (empty for MX routing)
+	Local         bool   // Redelivered to a local mailbox by the worker instead of over SMTP
+}
+
+// QueueFilter selects queued messages for administrative listing
+type QueueFilter struct {
+	Status OutboundStatus // Empty for any status
+	Domain string         // Recipient domain
+	Sender string
+	Limit  int
+	Offset int
+}
+
+// DeliveryAttempt is one entry in a queued message's retry history
+type DeliveryAttempt struct {
+	MessageID   string
+	Status      OutboundStatus // Status the message moved to after the attempt
+	Error       string
+	AttemptedAt time.Time
 }
@@ -0,0 +1,53 @@
+package domain
+
+import "strings"
+
+// RFC 9208 quota resources
+const (
+	QuotaStorage = "STORAGE" // Sum of the message sizes, in bytes (reported to IMAP clients in KiB)
+	QuotaMessage = "MESSAGE" // Number of messages
+	QuotaMailbox = "MAILBOX" // Number of mailboxes
+)
+
+// QuotaResources lists the resources in the order they are reported
+var QuotaResources = []string{QuotaStorage, QuotaMessage, QuotaMailbox}
+
+// QuotaRoot identifies a set of limits shared by some mailboxes (RFC 9208 Section 3.2):
+// a user's account, a single mailbox of a user, or every account of a domain
+type QuotaRoot struct {
+	Owner   string `json:"owner"`             // Email of the user, or the name of the domain of a domain root
+	Mailbox string `json:"mailbox,omitempty"` // Mailbox of a per-mailbox root, empty otherwise
+}
+
+// IsDomain reports whether the root covers a whole domain
+func (r QuotaRoot) IsDomain() bool {
+	return !strings.Contains(r.Owner, "@")
+}
+
+// Resources returns the resources that can be limited on the root. A mailbox root covers
+// one mailbox, so it has no MAILBOX resource.
+func (r QuotaRoot) Resources() []string {
+	if r.Mailbox != "" {
+		return []string{QuotaStorage, QuotaMessage}
+	}
+	return QuotaResources
+}
+
+// Quota is the usage of the resources of a quota root and the limits set on them
+type Quota struct {
+	Root   QuotaRoot        `json:"root"`
+	Usage  map[string]int64 `json:"usage"`  // Resource -> current usage
+	Limits map[string]int64 `json:"limits"` // Resource -> limit; unlimited resources are absent
+}
+
+// Exceeds reports the first limited resource that would go over its limit if usage grew
+// by the given amounts, or "" if none would
+func (q *Quota) Exceeds(delta map[string]int64) string {
+	for _, resource := range QuotaResources {
+		limit, ok := q.Limits[resource]
+		if ok && delta[resource] > 0 && q.Usage[resource]+delta[resource] > limit {
+			return resource
+		}
+	}
+	return ""
+}
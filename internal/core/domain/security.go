@@ -72,3 +72,39 @@ type FailureDetails struct {
 	AdditionalInformation string `json:"additional-information,omitempty"`
 	FailureReasonCode     string `json:"failure-reason-code,omitempty"`
 }
+
+// TLS-RPT policy types (RFC 8460 Section 4.4)
+const (
+	TLSPolicyTypeSTS      = "sts"
+	TLSPolicyTypeTLSA     = "tlsa"
+	TLSPolicyTypeNoPolicy = "no-policy-found"
+)
+
+// TLS-RPT result types (RFC 8460 Section 4.3)
+const (
+	TLSResultSTARTTLSNotSupported    = "starttls-not-supported"
+	TLSResultCertificateHostMismatch = "certificate-host-mismatch"
+	TLSResultCertificateExpired      = "certificate-expired"
+	TLSResultCertificateNotTrusted   = "certificate-not-trusted"
+	TLSResultValidationFailure       = "validation-failure"
+	TLSResultTLSAInvalid             = "tlsa-invalid"
+	TLSResultSTSPolicyFetchError     = "sts-policy-fetch-error"
+	TLSResultSTSPolicyInvalid        = "sts-policy-invalid"
+	TLSResultSTSWebPKIInvalid        = "sts-webpki-invalid"
+)
+
+// TLSSessionResult is the outcome of one outbound delivery attempt with respect to
+// the recipient domain's transport security policy. Results are aggregated into
+// outgoing TLS reports.
+type TLSSessionResult struct {
+	PolicyDomain string    `json:"policy_domain"`
+	PolicyType   string    `json:"policy_type"`
+	PolicyString []string  `json:"policy_string,omitempty"`
+	MXHost       string    `json:"mx_host"`
+	SendingIP    string    `json:"sending_ip,omitempty"`
+	ReceivingIP  string    `json:"receiving_ip,omitempty"`
+	Success      bool      `json:"success"`
+	ResultType   string    `json:"result_type,omitempty"` // Empty on success
+	Details      string    `json:"details,omitempty"`     // Free-form additional information
+	Timestamp    time.Time `json:"timestamp"`
+}
@@ -5,13 +5,21 @@ import "time"
 // SMTPSession represents temporary state for an active SMTP connection
 // (Not persisted, exists only during connection lifetime)
 type SMTPSession struct {
-	SessionID   string    // Unique session identifier (for logging)
-	RemoteIP    string    // Client IP address
-	HeloName    string    // Client HELO/EHLO name
-	Sender      string    // MAIL FROM value
-	Recipients  []string  // RCPT TO addresses
-	ConnectedAt time.Time // Connection start time
-	BytesRecv   int64     // Bytes received in DATA command
+	SessionID    string    // Unique session identifier (for logging)
+	RemoteIP     string    // Client IP address
+	HeloName     string    // Client HELO/EHLO name
+	Sender       string    // MAIL FROM value
+	Recipients   []string  // RCPT TO addresses
+	ConnectedAt  time.Time // Connection start time
+	BytesRecv    int64     // Bytes received in DATA command
+	DeclaredSize int64     // SIZE parameter of MAIL FROM (RFC 1870), 0 if not given
+	AuthUser     string    // Authenticated user email (submission only, RFC 4954)
+
+	// TLS state, populated after a successful STARTTLS (RFC 3207)
+	TLS           bool   // Whether the session is running over TLS
+	TLSVersion    string // Negotiated protocol version (e.g., "TLS1.3")
+	TLSCipher     string // Negotiated cipher suite name
+	TLSServerName string // SNI requested by the client
 }
 
 // DNSRecord represents a DNS configuration record for the mail domain
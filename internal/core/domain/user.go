@@ -7,6 +7,9 @@ type Role string
 const (
 	RoleUser  Role = "user"
 	RoleAdmin Role = "admin"
+	// RoleShared marks a role account such as support@: it has no password and its
+	// mailboxes are used by the members they are shared with
+	RoleShared Role = "shared"
 )
 
 // User represents a mailbox owner with authentication credentials
@@ -18,6 +21,19 @@ type User struct {
 	LastLoginAt  time.Time // Most recent successful login
 	StorageQuota int64     // Max storage in bytes (0 for default/unlimited)
 	StorageUsed  int64     // Current storage usage in bytes
+
+	// SCRAM credentials derived from the password when it is set (nil until then)
+	SCRAMSHA1   *SCRAMCredentials
+	SCRAMSHA256 *SCRAMCredentials
+}
+
+// SCRAMCredentials are the stored credentials of a SCRAM mechanism (RFC 5802 Section 3),
+// which verify a client proof without knowing the password
+type SCRAMCredentials struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	StoredKey  []byte `json:"stored_key"`
+	ServerKey  []byte `json:"server_key"`
 }
 
 // AuthToken represents a JWT token for API authentication
@@ -7,4 +7,5 @@ var (
 	ErrAlreadyExists      = errors.New("resource already exists")
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrStorageFailure     = errors.New("storage operation failed")
+	ErrConflict           = errors.New("resource was modified concurrently")
 )
@@ -9,10 +9,14 @@ import (
 
 // EmailRepository defines storage operations for email messages
 type EmailRepository interface {
-	// Save stores a new message (atomic with blob storage)
+	// Save stores a new message (atomic with blob storage) and adds msg.Size to the
+	// storage used by the recipient in the same transaction.
 	// Returns error if message already exists or storage fails
 	Save(ctx context.Context, msg *domain.Message) error
 
+	// SaveAll stores several new messages like Save, all in a single transaction
+	SaveAll(ctx context.Context, msgs []*domain.Message) error
+
 	// FindByID retrieves a single message by ID
 	// Returns ErrNotFound if message doesn't exist
 	FindByID(ctx context.Context, id string) (*domain.Message, error)
@@ -50,12 +54,72 @@ type EmailRepository interface {
 	CreateMailbox(ctx context.Context, userID, name string) error
 	ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error)
 
+	// ListSharedMailboxes retrieves the mailboxes of other users whose ACL grants rights
+	// to the user, to "anyone" or to "authenticated"
+	ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error)
+
+	// RenameMailbox renames a mailbox together with its inferior hierarchical names,
+	// keeping their messages and UIDVALIDITY
+	// Returns ErrNotFound if oldName doesn't exist, ErrAlreadyExists if a new name is taken
+	RenameMailbox(ctx context.Context, userID, oldName, newName string) error
+
+	// DeleteMailbox removes a mailbox and its messages, subtracting their recorded sizes from
+	// the storage used by userID in the same transaction. It returns the blob paths that no
+	// remaining message references, so that the caller can release them.
+	DeleteMailbox(ctx context.Context, userID, name string) ([]string, error)
+
+	// SetSubscribed adds a mailbox to or removes it from the user's subscriptions
+	SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error
+
+	// SetSpecialUse sets the RFC 6154 special-use attribute of a mailbox (empty clears it)
+	SetSpecialUse(ctx context.Context, userID, name, specialUse string) error
+
+	// GetMetadata retrieves the RFC 5464 metadata of a mailbox of owner, or of the server
+	// when both are empty: the shared entries and the private entries of userID
+	GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error)
+
+	// SetMetadata atomically sets and removes metadata entries of a mailbox or the server.
+	// Private entries are stored for userID.
+	SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error
+
+	// GetQuotaLimits returns the RFC 9208 limits set on a quota root, by resource. The
+	// storage limit of a user root is the user's StorageQuota, which isn't kept here.
+	GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error)
+
+	// SetQuotaLimit sets the limit of one resource of a quota root (0 removes it)
+	SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error
+
+	// GetQuotaUsage returns the usage of every resource of a quota root, computed from
+	// the messages and mailboxes it covers
+	GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error)
+
+	// ListQuotaRoots returns the user and mailbox roots of owner that have limits set
+	ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error)
+
 	// FindByUIDRange retrieves messages by UID range [min, max]
 	FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error)
 
-	// CopyMessages copies messages to a destination mailbox
-	// Returns map of oldUID -> newUID if possible, or just error
-	CopyMessages(ctx context.Context, userID string, messageIDs []string, destMailbox string) error
+	// FindInMailbox retrieves the messages of a mailbox matching the filter, ordered by UID
+	FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error)
+
+	// CopyMessages copies messages to a destination mailbox of userID, who owns the copies.
+	// Each copy is stored with msg.Size, and the sizes of the copies are added to the storage
+	// used by userID in the same transaction. Returns map of oldUID -> newUID
+	CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error)
+
+	// MoveMessages atomically moves messages from srcMailbox to destMailbox, assigning new UIDs
+	// Returns map of oldUID -> newUID
+	MoveMessages(ctx context.Context, userID, srcMailbox string, messageIDs []string, destMailbox string) (map[uint32]uint32, error)
+
+	// FindExpunged returns the UIDs expunged from a mailbox after the given modification
+	// sequence, for QRESYNC VANISHED responses (RFC 7162)
+	FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error)
+
+	// DeleteMessages permanently removes messages from a mailbox of userID. The msg.Size of
+	// each removed message is subtracted from the storage used by userID in the same
+	// transaction. It returns the blob paths that no remaining message references, so that
+	// the caller can release them.
+	DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error)
 
 	// ACL Management
 	// SetACL updates the access rights for an identifier on a mailbox
@@ -113,9 +177,15 @@ type UserRepository interface {
 	// New Management Methods
 	List(ctx context.Context, limit, offset int) ([]*domain.User, error)
 	Delete(ctx context.Context, email string) error
-	UpdatePassword(ctx context.Context, email, passwordHash string) error
+	// UpdatePassword replaces the password hash together with the SCRAM credentials derived
+	// from the same password, so no mechanism keeps accepting the old one
+	UpdatePassword(ctx context.Context, email, passwordHash string, sha1, sha256 *domain.SCRAMCredentials) error
 	UpdateRole(ctx context.Context, email string, role domain.Role) error
 
+	// UpdateSCRAMCredentials stores the SCRAM-SHA-1 and SCRAM-SHA-256 credentials of a user
+	// Returns ErrNotFound if the user doesn't exist
+	UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error
+
 	// UpdateQuota sets the max storage in bytes for a user (0 = unlimited)
 	UpdateQuota(ctx context.Context, email string, bytes int64) error
 
@@ -131,6 +201,10 @@ type QueueRepository interface {
 	// Enqueue adds a message to the outbound queue
 	Enqueue(ctx context.Context, msg *domain.OutboundMessage) error
 
+	// EnqueueAll adds messages to the outbound queue atomically: either all of them are
+	// queued or none is
+	EnqueueAll(ctx context.Context, msgs []*domain.OutboundMessage) error
+
 	// LockNextReady finds the next message ready for delivery and marks it as PROCESSING
 	// Should check for Status=PENDING/RETRYING and NextRetryAt <= now
 	// Returns (nil, nil) if no messages are ready
@@ -139,8 +213,45 @@ type QueueRepository interface {
 	// UpdateStatus updates the status, retry count and next retry time
 	UpdateStatus(ctx context.Context, id string, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error
 
+	// TransitionStatus is UpdateStatus applied only while the message is still in the from status
+	// Returns ErrConflict if the message changed status in the meantime, ErrNotFound if it is gone
+	TransitionStatus(ctx context.Context, id string, from, status domain.OutboundStatus, retryCount int, nextRetry time.Time, lastError string) error
+
 	// Stats returns queue statistics (pending, processing, failed, completed)
 	Stats(ctx context.Context) (pending, processing, failed, completed int64, err error)
+
+	// SetDelayWarnings records how many "delayed" DSNs have been sent for a message
+	SetDelayWarnings(ctx context.Context, id string, count int) error
+
+	// LockReadyForDomain locks up to limit further ready messages whose recipient is in the
+	// given domain, so they can be delivered in the same SMTP session
+	LockReadyForDomain(ctx context.Context, recipientDomain string, limit int) ([]*domain.OutboundMessage, error)
+
+	// RequeueStale returns messages stuck in PROCESSING since before the cutoff (e.g. after a
+	// crashed worker) to RETRYING. Returns the number of messages requeued.
+	RequeueStale(ctx context.Context, before time.Time) (int64, error)
+
+	// List returns queued messages matching the filter, oldest first
+	List(ctx context.Context, filter domain.QueueFilter) ([]*domain.OutboundMessage, error)
+
+	// Get retrieves a queued message by ID
+	// Returns ErrNotFound if not found
+	Get(ctx context.Context, id string) (*domain.OutboundMessage, error)
+
+	// Delete removes a message and its delivery history from the queue
+	// Returns ErrNotFound if not found
+	Delete(ctx context.Context, id string) error
+
+	// RecordAttempt appends an entry to a message's delivery history
+	RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error
+
+	// ListAttempts returns a message's delivery history, oldest first
+	ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error)
+
+	// Reroute sends every undelivered message for a recipient domain through the relay host
+	// (empty restores MX routing) and makes it ready for delivery. Held messages stay held.
+	// Returns the number of messages updated.
+	Reroute(ctx context.Context, recipientDomain, relay string) (int64, error)
 }
 
 // DomainRepository defines storage operations for hosted domains
@@ -149,6 +260,10 @@ type DomainRepository interface {
 	// Returns ErrAlreadyExists if domain exists
 	Create(ctx context.Context, domain *domain.Domain) error
 
+	// CreateWithDKIMKeys adds a new domain together with its DKIM keys atomically
+	// Returns ErrAlreadyExists if domain exists
+	CreateWithDKIMKeys(ctx context.Context, domain *domain.Domain, keys []*domain.DKIMKey) error
+
 	// Get retrieves a domain by name
 	// Returns ErrNotFound if not found
 	Get(ctx context.Context, name string) (*domain.Domain, error)
@@ -161,6 +276,28 @@ type DomainRepository interface {
 
 	// Exists checks if a domain exists
 	Exists(ctx context.Context, name string) (bool, error)
+
+	// CreateDKIMKey stores a new DKIM key for a domain
+	// Returns ErrAlreadyExists if the domain already has the selector
+	CreateDKIMKey(ctx context.Context, key *domain.DKIMKey) error
+
+	// ListDKIMKeys returns the DKIM keys of a domain, oldest first
+	ListDKIMKeys(ctx context.Context, domainName string) ([]*domain.DKIMKey, error)
+
+	// ListDKIMKeysByStatus returns the DKIM keys of all domains in the given state
+	ListDKIMKeysByStatus(ctx context.Context, status domain.DKIMKeyStatus) ([]*domain.DKIMKey, error)
+
+	// UpdateDKIMKey saves the state, timestamps and private key of a DKIM key
+	// Returns ErrNotFound if the key does not exist
+	UpdateDKIMKey(ctx context.Context, key *domain.DKIMKey) error
+
+	// UpdateDKIMKeys saves several DKIM keys like UpdateDKIMKey, atomically
+	// Returns ErrNotFound if one of the keys does not exist
+	UpdateDKIMKeys(ctx context.Context, keys []*domain.DKIMKey) error
+
+	// DeleteDKIMKey removes a DKIM key
+	// Returns ErrNotFound if the key does not exist
+	DeleteDKIMKey(ctx context.Context, domainName, selector string) error
 }
 
 // TLSRptRepository defines storage operations for TLS Reports
@@ -170,4 +307,16 @@ type TLSRptRepository interface {
 
 	// FindLatest retrieves the most recent reports
 	FindLatest(ctx context.Context, limit int) ([]*domain.TLSReport, error)
+
+	// RecordSession stores the outcome of one outbound TLS session
+	RecordSession(ctx context.Context, result *domain.TLSSessionResult) error
+
+	// ListSessions returns outbound TLS session results recorded in [start, end)
+	ListSessions(ctx context.Context, start, end time.Time) ([]*domain.TLSSessionResult, error)
+
+	// DeleteSessions removes the outbound TLS session results of a policy domain recorded in [start, end)
+	DeleteSessions(ctx context.Context, policyDomain string, start, end time.Time) error
+
+	// DeleteSessionsBefore removes outbound TLS session results recorded before t
+	DeleteSessionsBefore(ctx context.Context, t time.Time) error
 }
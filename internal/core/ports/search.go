@@ -22,6 +22,10 @@ type SearchIndex interface {
 	// Results ordered by relevance (BM25 ranking)
 	Search(ctx context.Context, userEmail, query string, limit, offset int) ([]SearchResult, error)
 
+	// SearchMailbox returns the IDs of every message in one mailbox matching query, unranked
+	// and without a limit, for protocol searches that must be exhaustive (IMAP SEARCH)
+	SearchMailbox(ctx context.Context, userEmail, mailbox, query string) ([]string, error)
+
 	// Delete removes a message from the index
 	Delete(ctx context.Context, messageID string) error
 }
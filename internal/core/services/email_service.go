@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
 )
 
@@ -23,7 +24,7 @@ func NewEmailService(emailRepo ports.EmailRepository) *EmailService {
 // UpdateACL updates the Access Control List for a mailbox
 func (s *EmailService) UpdateACL(ctx context.Context, ownerID, mailboxName, identifier, rights string) error {
 	// 1. Verify rights format
-	if err := s.validateRights(rights); err != nil {
+	if err := s.ValidateRights(rights); err != nil {
 		return err
 	}
 
@@ -37,32 +38,36 @@ func (s *EmailService) UpdateACL(ctx context.Context, ownerID, mailboxName, iden
 	return s.emailRepo.SetACL(ctx, ownerID, mailboxName, identifier, rights)
 }
 
-func (s *EmailService) validateRights(rights string) error {
+// ValidateRights rejects characters that aren't RFC 4314 rights
+func (s *EmailService) ValidateRights(rights string) error {
 	// RFC 4314 standard rights + 'a' (admin)
 	// l: lookup, r: read, s: seen, w: write, i: insert, p: post, k: create, x: delete, t: delete msgs, e: expunge, a: admin
-	allowed := "lrswipkxtea"
 	for _, char := range rights {
-		if !strings.ContainsRune(allowed, char) {
+		if !strings.ContainsRune(allRights, char) {
 			return errors.New("invalid right: " + string(char))
 		}
 	}
 	return nil
 }
 
-// CheckAccess verifies if a user has the required rights on a mailbox
-func (s *EmailService) CheckAccess(ctx context.Context, ownerID, mailboxName, user string, requiredRights string) error {
+// allRights are the RFC 4314 rights, which the owner of a mailbox always has
+const allRights = "lrswipkxtea"
+
+// Rights returns the rights of a user on a mailbox: those granted to the user, to
+// "anyone" and to "authenticated"
+func (s *EmailService) Rights(ctx context.Context, ownerID, mailboxName, user string) (string, error) {
 	// 1. Owner always has full access (simplification for MVP)
 	if ownerID == user {
-		return nil
+		return allRights, nil
 	}
 
 	// 2. Fetch Mailbox ACLs
 	mb, err := s.emailRepo.GetMailbox(ctx, ownerID, mailboxName)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// 3. Check rights
+	// 3. Collect rights
 	// Identifier can be "anyone" or specific user
 	// Rights format: "lrs"
 	userRights := ""
@@ -82,9 +87,26 @@ func (s *EmailService) CheckAccess(ctx context.Context, ownerID, mailboxName, us
 		}
 	}
 
+	// Canonical order without duplicates
+	rights := ""
+	for _, char := range allRights {
+		if strings.ContainsRune(userRights, char) {
+			rights += string(char)
+		}
+	}
+	return rights, nil
+}
+
+// CheckAccess verifies if a user has the required rights on a mailbox
+func (s *EmailService) CheckAccess(ctx context.Context, ownerID, mailboxName, user string, requiredRights string) error {
+	rights, err := s.Rights(ctx, ownerID, mailboxName, user)
+	if err != nil {
+		return err
+	}
+
 	// Check required rights
 	for _, char := range requiredRights {
-		if !strings.ContainsRune(userRights, char) {
+		if !strings.ContainsRune(rights, char) {
 			return ErrAccessDenied
 		}
 	}
@@ -103,3 +125,43 @@ func (s *EmailService) EnsureMailbox(ctx context.Context, userID, name string) e
 	// Create if missing
 	return s.emailRepo.CreateMailbox(ctx, userID, name)
 }
+
+// CreateDefaultMailboxes provisions the default folder set of a new user, subscribed and
+// with their special-use attributes. Mailboxes that already exist are left as they are.
+func (s *EmailService) CreateDefaultMailboxes(ctx context.Context, userID string) error {
+	for _, mb := range domain.DefaultMailboxes {
+		if _, err := s.emailRepo.GetMailbox(ctx, userID, mb.Name); err == nil {
+			continue
+		}
+		if err := s.emailRepo.CreateMailbox(ctx, userID, mb.Name); err != nil {
+			return err
+		}
+		if mb.SpecialUse != "" {
+			if err := s.emailRepo.SetSpecialUse(ctx, userID, mb.Name, mb.SpecialUse); err != nil {
+				return err
+			}
+		}
+		if err := s.emailRepo.SetSubscribed(ctx, userID, mb.Name, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShareMailboxes grants a member rights on every mailbox of an account, e.g. the members
+// of a shared role account such as support@. Empty rights revoke the member's access.
+func (s *EmailService) ShareMailboxes(ctx context.Context, ownerID, member, rights string) error {
+	if err := s.ValidateRights(rights); err != nil {
+		return err
+	}
+	mailboxes, err := s.emailRepo.ListMailboxes(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	for _, mb := range mailboxes {
+		if err := s.emailRepo.SetACL(ctx, ownerID, mb.Name, member, rights); err != nil {
+			return err
+		}
+	}
+	return nil
+}
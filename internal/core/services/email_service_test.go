@@ -20,6 +20,10 @@ func (m *MockEmailRepository) Save(ctx context.Context, msg *domain.Message) err
 	args := m.Called(ctx, msg)
 	return args.Error(0)
 }
+func (m *MockEmailRepository) SaveAll(ctx context.Context, msgs []*domain.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
 func (m *MockEmailRepository) FindByID(ctx context.Context, id string) (*domain.Message, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -72,16 +76,72 @@ func (m *MockEmailRepository) GetMailbox(ctx context.Context, userID, name strin
 func (m *MockEmailRepository) CreateMailbox(ctx context.Context, userID, name string) error {
 	return m.Called(ctx, userID, name).Error(0)
 }
+func (m *MockEmailRepository) RenameMailbox(ctx context.Context, userID, oldName, newName string) error {
+	return m.Called(ctx, userID, oldName, newName).Error(0)
+}
+func (m *MockEmailRepository) DeleteMailbox(ctx context.Context, userID, name string) ([]string, error) {
+	args := m.Called(ctx, userID, name)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *MockEmailRepository) SetSubscribed(ctx context.Context, userID, name string, subscribed bool) error {
+	return m.Called(ctx, userID, name, subscribed).Error(0)
+}
+func (m *MockEmailRepository) SetSpecialUse(ctx context.Context, userID, name, specialUse string) error {
+	return m.Called(ctx, userID, name, specialUse).Error(0)
+}
+func (m *MockEmailRepository) GetMetadata(ctx context.Context, owner, mailbox, userID string) ([]*domain.MetadataEntry, error) {
+	args := m.Called(ctx, owner, mailbox, userID)
+	return args.Get(0).([]*domain.MetadataEntry), args.Error(1)
+}
+func (m *MockEmailRepository) SetMetadata(ctx context.Context, owner, mailbox, userID string, entries []*domain.MetadataEntry) error {
+	return m.Called(ctx, owner, mailbox, userID, entries).Error(0)
+}
+func (m *MockEmailRepository) GetQuotaLimits(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	args := m.Called(ctx, root)
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+func (m *MockEmailRepository) SetQuotaLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
+	return m.Called(ctx, root, resource, limit).Error(0)
+}
+func (m *MockEmailRepository) GetQuotaUsage(ctx context.Context, root domain.QuotaRoot) (map[string]int64, error) {
+	args := m.Called(ctx, root)
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+func (m *MockEmailRepository) ListQuotaRoots(ctx context.Context, owner string) ([]domain.QuotaRoot, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).([]domain.QuotaRoot), args.Error(1)
+}
 func (m *MockEmailRepository) ListMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
 	args := m.Called(ctx, userID)
 	return args.Get(0).([]*domain.Mailbox), args.Error(1)
 }
+func (m *MockEmailRepository) ListSharedMailboxes(ctx context.Context, userID string) ([]*domain.Mailbox, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.Mailbox), args.Error(1)
+}
 func (m *MockEmailRepository) FindByUIDRange(ctx context.Context, userID, mailbox string, min, max uint32) ([]*domain.Message, error) {
 	args := m.Called(ctx, userID, mailbox, min, max)
 	return args.Get(0).([]*domain.Message), args.Error(1)
 }
-func (m *MockEmailRepository) CopyMessages(ctx context.Context, userID string, messageIDs []string, destMailbox string) error {
-	return m.Called(ctx, userID, messageIDs, destMailbox).Error(0)
+func (m *MockEmailRepository) FindInMailbox(ctx context.Context, userID, mailbox string, filter domain.MailboxFilter) ([]*domain.Message, error) {
+	args := m.Called(ctx, userID, mailbox, filter)
+	return args.Get(0).([]*domain.Message), args.Error(1)
+}
+func (m *MockEmailRepository) CopyMessages(ctx context.Context, userID string, msgs []*domain.Message, destMailbox string) (map[uint32]uint32, error) {
+	args := m.Called(ctx, userID, msgs, destMailbox)
+	return args.Get(0).(map[uint32]uint32), args.Error(1)
+}
+func (m *MockEmailRepository) MoveMessages(ctx context.Context, userID, srcMailbox string, messageIDs []string, destMailbox string) (map[uint32]uint32, error) {
+	args := m.Called(ctx, userID, srcMailbox, messageIDs, destMailbox)
+	return args.Get(0).(map[uint32]uint32), args.Error(1)
+}
+func (m *MockEmailRepository) FindExpunged(ctx context.Context, userID, mailbox string, sinceModSeq uint64) ([]uint32, error) {
+	args := m.Called(ctx, userID, mailbox, sinceModSeq)
+	return args.Get(0).([]uint32), args.Error(1)
+}
+func (m *MockEmailRepository) DeleteMessages(ctx context.Context, userID, mailbox string, msgs []*domain.Message) ([]string, error) {
+	args := m.Called(ctx, userID, mailbox, msgs)
+	return args.Get(0).([]string), args.Error(1)
 }
 func (m *MockEmailRepository) SetACL(ctx context.Context, userID, mailboxName, identifier, rights string) error {
 	return m.Called(ctx, userID, mailboxName, identifier, rights).Error(0)
@@ -170,3 +230,53 @@ func TestCheckAccess(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestRights(t *testing.T) {
+	mockRepo := new(MockEmailRepository)
+	service := NewEmailService(mockRepo)
+	ctx := context.Background()
+
+	rights, err := service.Rights(ctx, "alice", "INBOX", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "lrswipkxtea", rights)
+
+	mockRepo.On("GetMailbox", ctx, "alice", "INBOX").Return(&domain.Mailbox{
+		ACL: map[string]string{"bob": "sr", "authenticated": "lr"},
+	}, nil)
+	rights, err = service.Rights(ctx, "alice", "INBOX", "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "lrs", rights, "rights are merged in canonical order")
+}
+
+func TestShareMailboxes(t *testing.T) {
+	mockRepo := new(MockEmailRepository)
+	service := NewEmailService(mockRepo)
+	ctx := context.Background()
+
+	assert.Error(t, service.ShareMailboxes(ctx, "support", "bob", "lz"))
+
+	mockRepo.On("ListMailboxes", ctx, "support").Return([]*domain.Mailbox{{Name: "INBOX"}, {Name: "Sent"}}, nil)
+	mockRepo.On("SetACL", ctx, "support", "INBOX", "bob", "lrs").Return(nil)
+	mockRepo.On("SetACL", ctx, "support", "Sent", "bob", "lrs").Return(nil)
+	assert.NoError(t, service.ShareMailboxes(ctx, "support", "bob", "lrs"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateDefaultMailboxes(t *testing.T) {
+	mockRepo := new(MockEmailRepository)
+	service := NewEmailService(mockRepo)
+	ctx := context.Background()
+
+	// INBOX already exists and is left alone
+	mockRepo.On("GetMailbox", ctx, "alice", "INBOX").Return(&domain.Mailbox{Name: "INBOX"}, nil)
+	for _, mb := range domain.DefaultMailboxes[1:] {
+		mockRepo.On("GetMailbox", ctx, "alice", mb.Name).Return(nil, errors.New("not found"))
+		mockRepo.On("CreateMailbox", ctx, "alice", mb.Name).Return(nil)
+		mockRepo.On("SetSpecialUse", ctx, "alice", mb.Name, mb.SpecialUse).Return(nil)
+		mockRepo.On("SetSubscribed", ctx, "alice", mb.Name, true).Return(nil)
+	}
+
+	assert.NoError(t, service.CreateDefaultMailboxes(ctx, "alice"))
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateMailbox", ctx, "alice", "INBOX")
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+)
+
+var (
+	// ErrQuotaExceeded is matched by every QuotaExceededError
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrUnsupportedQuotaResource is returned for a resource the quota root can't limit
+	ErrUnsupportedQuotaResource = errors.New("resource cannot be limited on this quota root")
+)
+
+// QuotaExceededError reports the quota root and resource whose limit would be exceeded
+type QuotaExceededError struct {
+	Root     domain.QuotaRoot
+	Resource string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s limit of quota root %s exceeded", e.Resource, quotaRootName(e.Root))
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) hold
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// quotaRootName names a root in messages: the owner, followed by the mailbox if any
+func quotaRootName(root domain.QuotaRoot) string {
+	if root.Mailbox != "" {
+		return root.Owner + "/" + root.Mailbox
+	}
+	return root.Owner
+}
+
+// QuotaService evaluates the RFC 9208 quota roots of accounts, mailboxes and domains.
+// A mailbox counts against its owner's account, against its own root if it has limits
+// and against the root of the owner's domain if that has limits.
+type QuotaService struct {
+	userRepo  ports.UserRepository
+	emailRepo ports.EmailRepository
+}
+
+// NewQuotaService creates a new quota service
+func NewQuotaService(userRepo ports.UserRepository, emailRepo ports.EmailRepository) *QuotaService {
+	return &QuotaService{userRepo: userRepo, emailRepo: emailRepo}
+}
+
+// isUserRoot reports whether the root is a user's account, whose storage is kept on the user
+func isUserRoot(root domain.QuotaRoot) bool {
+	return root.Mailbox == "" && !root.IsDomain()
+}
+
+// Get returns the usage and limits of a quota root. The storage of an account is the
+// user's StorageUsed, limited by StorageQuota.
+func (s *QuotaService) Get(ctx context.Context, root domain.QuotaRoot) (*domain.Quota, error) {
+	limits, err := s.emailRepo.GetQuotaLimits(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.emailRepo.GetQuotaUsage(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	quota := &domain.Quota{Root: root, Usage: make(map[string]int64), Limits: make(map[string]int64)}
+	for resource, value := range usage {
+		quota.Usage[resource] = value
+	}
+	for resource, limit := range limits {
+		quota.Limits[resource] = limit
+	}
+
+	if isUserRoot(root) {
+		user, err := s.userRepo.FindByEmail(ctx, root.Owner)
+		if err != nil {
+			return nil, err
+		}
+		quota.Usage[domain.QuotaStorage] = user.StorageUsed
+		delete(quota.Limits, domain.QuotaStorage)
+		if user.StorageQuota > 0 {
+			quota.Limits[domain.QuotaStorage] = user.StorageQuota
+		}
+	}
+	return quota, nil
+}
+
+// Roots returns the quotas a mailbox of owner counts against, the owner's account first.
+// With an empty mailbox, those of the account as a whole.
+func (s *QuotaService) Roots(ctx context.Context, owner, mailbox string) ([]*domain.Quota, error) {
+	account, err := s.Get(ctx, domain.QuotaRoot{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	quotas := []*domain.Quota{account}
+	var optional []domain.QuotaRoot
+	if mailbox != "" {
+		optional = append(optional, domain.QuotaRoot{Owner: owner, Mailbox: mailbox})
+	}
+	if i := strings.LastIndex(owner, "@"); i >= 0 {
+		optional = append(optional, domain.QuotaRoot{Owner: strings.ToLower(owner[i+1:])})
+	}
+	for _, root := range optional {
+		quota, err := s.getLimited(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		if quota != nil {
+			quotas = append(quotas, quota)
+		}
+	}
+	return quotas, nil
+}
+
+// getLimited returns the quota of a mailbox or domain root, or nil if it has no limits
+func (s *QuotaService) getLimited(ctx context.Context, root domain.QuotaRoot) (*domain.Quota, error) {
+	limits, err := s.emailRepo.GetQuotaLimits(ctx, root)
+	if err != nil || len(limits) == 0 {
+		return nil, err
+	}
+	return s.Get(ctx, root)
+}
+
+// SetLimit sets the limit of one resource of a quota root; 0 removes it
+func (s *QuotaService) SetLimit(ctx context.Context, root domain.QuotaRoot, resource string, limit int64) error {
+	if limit < 0 {
+		return errors.New("quota cannot be negative")
+	}
+	if !slices.Contains(root.Resources(), resource) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedQuotaResource, resource)
+	}
+	if isUserRoot(root) && resource == domain.QuotaStorage {
+		return s.userRepo.UpdateQuota(ctx, root.Owner, limit)
+	}
+	return s.emailRepo.SetQuotaLimit(ctx, root, resource, limit)
+}
+
+// SetLimits replaces the limits of a quota root, as SETQUOTA does (RFC 9208 Section 4.1.3):
+// the resources that aren't listed become unlimited
+func (s *QuotaService) SetLimits(ctx context.Context, root domain.QuotaRoot, limits map[string]int64) error {
+	for resource, limit := range limits {
+		if limit < 0 {
+			return errors.New("quota cannot be negative")
+		}
+		if !slices.Contains(root.Resources(), resource) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedQuotaResource, resource)
+		}
+	}
+	for _, resource := range root.Resources() {
+		if err := s.SetLimit(ctx, root, resource, limits[resource]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckStore returns a QuotaExceededError if adding count messages of size bytes in total
+// to a mailbox of owner would exceed a limit
+func (s *QuotaService) CheckStore(ctx context.Context, owner, mailbox string, count int, size int64) error {
+	quotas, err := s.Roots(ctx, owner, mailbox)
+	if err != nil {
+		return err
+	}
+	return CheckQuotas(quotas, map[string]int64{domain.QuotaStorage: size, domain.QuotaMessage: int64(count)})
+}
+
+// CheckDelivery returns a QuotaExceededError if a message of size bytes can't be stored in
+// each of the mailboxes of owner: the account is charged for every copy, each mailbox for one
+func (s *QuotaService) CheckDelivery(ctx context.Context, owner string, mailboxes []string, size int64) error {
+	copies := int64(len(mailboxes))
+	quotas, err := s.Roots(ctx, owner, "")
+	if err != nil {
+		return err
+	}
+	if err := CheckQuotas(quotas, map[string]int64{domain.QuotaStorage: copies * size, domain.QuotaMessage: copies}); err != nil {
+		return err
+	}
+	for _, mailbox := range mailboxes {
+		quota, err := s.getLimited(ctx, domain.QuotaRoot{Owner: owner, Mailbox: mailbox})
+		if err != nil {
+			return err
+		}
+		if quota == nil {
+			continue
+		}
+		if err := CheckQuotas([]*domain.Quota{quota}, map[string]int64{domain.QuotaStorage: size, domain.QuotaMessage: 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckCreateMailbox returns a QuotaExceededError if owner can't have count more mailboxes
+func (s *QuotaService) CheckCreateMailbox(ctx context.Context, owner string, count int) error {
+	quotas, err := s.Roots(ctx, owner, "")
+	if err != nil {
+		return err
+	}
+	return CheckQuotas(quotas, map[string]int64{domain.QuotaMailbox: int64(count)})
+}
+
+// CheckQuotas returns a QuotaExceededError for the first quota that growing by delta
+// would take over a limit, or nil
+func CheckQuotas(quotas []*domain.Quota, delta map[string]int64) error {
+	for _, quota := range quotas {
+		if resource := quota.Exceeds(delta); resource != "" {
+			return &QuotaExceededError{Root: quota.Root, Resource: resource}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestQuotaGetAccount(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	emailRepo := new(MockEmailRepository)
+	service := NewQuotaService(userRepo, emailRepo)
+	ctx := context.Background()
+	root := domain.QuotaRoot{Owner: "alice@example.com"}
+
+	emailRepo.On("GetQuotaLimits", ctx, root).Return(map[string]int64{domain.QuotaMessage: 100}, nil)
+	emailRepo.On("GetQuotaUsage", ctx, root).Return(map[string]int64{domain.QuotaStorage: 10, domain.QuotaMessage: 3, domain.QuotaMailbox: 5}, nil)
+	userRepo.On("FindByEmail", ctx, "alice@example.com").Return(&domain.User{StorageUsed: 2048, StorageQuota: 4096}, nil)
+
+	quota, err := service.Get(ctx, root)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), quota.Usage[domain.QuotaStorage], "storage usage comes from the user")
+	assert.Equal(t, map[string]int64{domain.QuotaStorage: 4096, domain.QuotaMessage: 100}, quota.Limits)
+}
+
+func TestQuotaSetLimit(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	emailRepo := new(MockEmailRepository)
+	service := NewQuotaService(userRepo, emailRepo)
+	ctx := context.Background()
+	account := domain.QuotaRoot{Owner: "alice@example.com"}
+	junk := domain.QuotaRoot{Owner: "alice@example.com", Mailbox: "Junk"}
+
+	assert.Error(t, service.SetLimit(ctx, account, domain.QuotaMessage, -1))
+	assert.ErrorIs(t, service.SetLimit(ctx, junk, domain.QuotaMailbox, 10), ErrUnsupportedQuotaResource)
+
+	userRepo.On("UpdateQuota", ctx, "alice@example.com", int64(1024)).Return(nil)
+	assert.NoError(t, service.SetLimit(ctx, account, domain.QuotaStorage, 1024))
+
+	emailRepo.On("SetQuotaLimit", ctx, junk, domain.QuotaMessage, int64(10000)).Return(nil)
+	assert.NoError(t, service.SetLimit(ctx, junk, domain.QuotaMessage, 10000))
+
+	userRepo.AssertExpectations(t)
+	emailRepo.AssertExpectations(t)
+}
+
+func TestQuotaCheckDelivery(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	emailRepo := new(MockEmailRepository)
+	service := NewQuotaService(userRepo, emailRepo)
+	ctx := context.Background()
+	account := domain.QuotaRoot{Owner: "alice@example.com"}
+	junk := domain.QuotaRoot{Owner: "alice@example.com", Mailbox: "Junk"}
+	inbox := domain.QuotaRoot{Owner: "alice@example.com", Mailbox: "INBOX"}
+	domainRoot := domain.QuotaRoot{Owner: "example.com"}
+
+	userRepo.On("FindByEmail", ctx, "alice@example.com").Return(&domain.User{StorageUsed: 100}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, account).Return(map[string]int64{}, nil)
+	emailRepo.On("GetQuotaUsage", ctx, account).Return(map[string]int64{domain.QuotaMessage: 20}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, domainRoot).Return(map[string]int64{}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, inbox).Return(map[string]int64{}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, junk).Return(map[string]int64{domain.QuotaMessage: 10}, nil)
+	emailRepo.On("GetQuotaUsage", ctx, junk).Return(map[string]int64{domain.QuotaMessage: 10}, nil)
+
+	assert.NoError(t, service.CheckDelivery(ctx, "alice@example.com", []string{"INBOX"}, 500))
+
+	err := service.CheckDelivery(ctx, "alice@example.com", []string{"INBOX", "Junk"}, 500)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	var qerr *QuotaExceededError
+	assert.True(t, errors.As(err, &qerr))
+	assert.Equal(t, junk, qerr.Root)
+	assert.Equal(t, domain.QuotaMessage, qerr.Resource)
+}
+
+func TestQuotaRootsIncludesDomain(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	emailRepo := new(MockEmailRepository)
+	service := NewQuotaService(userRepo, emailRepo)
+	ctx := context.Background()
+	account := domain.QuotaRoot{Owner: "alice@Example.com"}
+	domainRoot := domain.QuotaRoot{Owner: "example.com"}
+
+	userRepo.On("FindByEmail", ctx, "alice@Example.com").Return(&domain.User{}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, account).Return(map[string]int64{}, nil)
+	emailRepo.On("GetQuotaUsage", ctx, account).Return(map[string]int64{}, nil)
+	emailRepo.On("GetQuotaLimits", ctx, domainRoot).Return(map[string]int64{domain.QuotaMailbox: 50}, nil)
+	emailRepo.On("GetQuotaUsage", ctx, domainRoot).Return(map[string]int64{domain.QuotaMailbox: 50}, nil)
+
+	quotas, err := service.Roots(ctx, "alice@Example.com", "")
+	assert.NoError(t, err)
+	assert.Len(t, quotas, 2)
+	assert.Equal(t, domainRoot, quotas[1].Root)
+
+	err = service.CheckCreateMailbox(ctx, "alice@Example.com", 1)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	emailRepo.AssertNotCalled(t, "SetQuotaLimit", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -42,12 +42,15 @@ func (m *MockUserRepository) List(ctx context.Context, limit, offset int) ([]*do
 func (m *MockUserRepository) Delete(ctx context.Context, email string) error {
 	return m.Called(ctx, email).Error(0)
 }
-func (m *MockUserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
-	return m.Called(ctx, email, passwordHash).Error(0)
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, email, passwordHash string, sha1, sha256 *domain.SCRAMCredentials) error {
+	return m.Called(ctx, email, passwordHash, sha1, sha256).Error(0)
 }
 func (m *MockUserRepository) UpdateRole(ctx context.Context, email string, role domain.Role) error {
 	return m.Called(ctx, email, role).Error(0)
 }
+func (m *MockUserRepository) UpdateSCRAMCredentials(ctx context.Context, email string, sha1, sha256 *domain.SCRAMCredentials) error {
+	return m.Called(ctx, email, sha1, sha256).Error(0)
+}
 func (m *MockUserRepository) Count(ctx context.Context) (map[string]int64, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(map[string]int64), args.Error(1)
@@ -16,6 +16,7 @@ type Metrics struct {
 	MessagesRejected int64
 	SMTPConnections  int64
 	SMTPErrors       int64
+	SMTPTLSSessions  int64
 
 	// Gauges (for KEDA/HPA scaling decisions)
 	ActiveSMTPConnections int64
@@ -80,6 +81,13 @@ func (m *Metrics) IncrementSMTPErrors() {
 	m.SMTPErrors++
 }
 
+// IncrementSMTPTLSSessions increments the STARTTLS-upgraded SMTP sessions counter
+func (m *Metrics) IncrementSMTPTLSSessions() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SMTPTLSSessions++
+}
+
 // IncrementAPIRequests increments the API requests counter
 func (m *Metrics) IncrementAPIRequests() {
 	m.mu.Lock()
@@ -165,6 +173,7 @@ func (m *Metrics) GetSnapshot() MetricsSnapshot {
 		MessagesRejected:        m.MessagesRejected,
 		SMTPConnections:         m.SMTPConnections,
 		SMTPErrors:              m.SMTPErrors,
+		SMTPTLSSessions:         m.SMTPTLSSessions,
 		ActiveSMTPConnections:   m.ActiveSMTPConnections,
 		ActiveIMAPConnections:   m.ActiveIMAPConnections,
 		QueueDepth:              m.QueueDepth,
@@ -197,6 +206,7 @@ func (m *Metrics) WritePrometheus(w io.Writer) {
 	writeMetric("mailraven_messages_rejected_total", "Total incoming messages rejected", "counter", snap.MessagesRejected)
 	writeMetric("mailraven_smtp_connections_total", "Total SMTP connections accepted", "counter", snap.SMTPConnections)
 	writeMetric("mailraven_smtp_errors_total", "Total SMTP errors encountered", "counter", snap.SMTPErrors)
+	writeMetric("mailraven_smtp_tls_sessions_total", "Total SMTP sessions upgraded with STARTTLS", "counter", snap.SMTPTLSSessions)
 
 	writeMetric("mailraven_api_requests_total", "Total HTTP API requests", "counter", snap.APIRequests)
 	writeMetric("mailraven_api_errors_total", "Total HTTP API errors", "counter", snap.APIErrors)
@@ -268,6 +278,7 @@ type MetricsSnapshot struct {
 	MessagesRejected        int64
 	SMTPConnections         int64
 	SMTPErrors              int64
+	SMTPTLSSessions         int64
 	ActiveSMTPConnections   int64
 	ActiveIMAPConnections   int64
 	QueueDepth              int64
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAdminQueueAPI(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	adminPassHash, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	require.NoError(t, env.userRepo.Create(ctx, &domain.User{
+		Email:        "admin@example.com",
+		PasswordHash: string(adminPassHash),
+		Role:         domain.RoleAdmin,
+		CreatedAt:    time.Now(),
+		LastLoginAt:  time.Now(),
+	}))
+	token := env.authenticateUser(t, "admin@example.com", "admin123")
+
+	blobKey, err := env.blobStore.Write(ctx, "queued-1", []byte("From: test@example.com\r\nSubject: Queued\r\n\r\nBody\r\n"))
+	require.NoError(t, err)
+
+	enqueue := func(id, sender, recipient string, status domain.OutboundStatus, age time.Duration) {
+		now := time.Now()
+		require.NoError(t, env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+			ID: id, Sender: sender, Recipient: recipient, BlobKey: blobKey, Status: status,
+			CreatedAt: now.Add(-age), UpdatedAt: now, NextRetryAt: now.Add(time.Hour),
+		}))
+	}
+	enqueue("q-1", "test@example.com", "a@slow.test", domain.QueueStatusRetrying, 3*time.Hour)
+	enqueue("q-2", "test@example.com", "b@slow.test", domain.QueueStatusPending, 2*time.Hour)
+	enqueue("q-3", "other@example.com", "c@fast.test", domain.QueueStatusPending, time.Hour)
+	require.NoError(t, env.queueRepo.UpdateStatus(ctx, "q-1", domain.QueueStatusRetrying, 1, time.Now().Add(time.Hour), "451 4.7.1 Try again later"))
+	require.NoError(t, env.queueRepo.RecordAttempt(ctx, &domain.DeliveryAttempt{
+		MessageID: "q-1", Status: domain.QueueStatusRetrying, Error: "451 4.7.1 Try again later", AttemptedAt: time.Now(),
+	}))
+
+	call := func(method, path string, body interface{}) *http.Response {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := env.newRequest(t, method, "/api/v1/admin/queue"+path, &buf, token)
+		req.Header.Set("Content-Type", "application/json")
+		return env.doRequest(t, req)
+	}
+	detail := func(resp *http.Response) dto.QueueMessageDetail {
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var d dto.QueueMessageDetail
+		env.decodeJSON(t, resp.Body, &d)
+		return d
+	}
+
+	t.Run("ListAndFilter", func(t *testing.T) {
+		list := func(query string) []dto.QueueMessage {
+			resp := call("GET", query, nil)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			var l dto.QueueListResponse
+			env.decodeJSON(t, resp.Body, &l)
+			return l.Messages
+		}
+
+		all := list("")
+		require.Len(t, all, 3)
+		assert.Equal(t, "q-1", all[0].ID, "oldest first")
+
+		assert.Len(t, list("?domain=slow.test"), 2)
+		assert.Len(t, list("?sender=other@example.com"), 1)
+		retrying := list("?status=retrying")
+		require.Len(t, retrying, 1)
+		assert.Equal(t, "q-1", retrying[0].ID)
+	})
+
+	t.Run("ShowIncludesHistory", func(t *testing.T) {
+		d := detail(call("GET", "/q-1", nil))
+		assert.Equal(t, "451 4.7.1 Try again later", d.LastError)
+		require.Len(t, d.Attempts, 1)
+		assert.Equal(t, "RETRYING", d.Attempts[0].Status)
+
+		resp := call("GET", "/missing", nil)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("HoldReleaseRetry", func(t *testing.T) {
+		assert.Equal(t, "HELD", detail(call("POST", "/q-2/hold", nil)).Status)
+
+		// A held message is never picked up for delivery
+		msg, err := env.queueRepo.LockNextReady(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, msg)
+
+		resp := call("POST", "/q-2/hold", nil)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "already held")
+
+		released := detail(call("POST", "/q-2/release", nil))
+		assert.Equal(t, "PENDING", released.Status)
+		assert.False(t, released.NextRetryAt.After(time.Now()), "released messages are ready immediately")
+
+		retried := detail(call("POST", "/q-1/retry", nil))
+		assert.Equal(t, "RETRYING", retried.Status)
+		assert.False(t, retried.NextRetryAt.After(time.Now()))
+	})
+
+	t.Run("Bounce", func(t *testing.T) {
+		d := detail(call("POST", "/q-2/bounce", nil))
+		assert.Equal(t, "FAILED", d.Status)
+		assert.Contains(t, d.LastError, "bounced by administrator")
+
+		// The local sender receives a non-delivery report
+		filter := domain.MessageFilter{Mailbox: "INBOX", Limit: 50}
+		inbox, err := env.emailRepo.List(ctx, "test@example.com", filter)
+		require.NoError(t, err)
+		found := false
+		for _, m := range inbox {
+			if m.Subject == "Undelivered Mail Returned to Sender" && strings.Contains(m.Snippet, "b@slow.test") {
+				found = true
+			}
+		}
+		assert.True(t, found, "expected DSN in sender's INBOX")
+
+		// A failed message can be retried
+		assert.Equal(t, "PENDING", detail(call("POST", "/q-2/retry", nil)).Status)
+	})
+
+	t.Run("TransitionIsConditional", func(t *testing.T) {
+		// The worker picked the message up after the admin loaded it as PENDING
+		require.NoError(t, env.queueRepo.UpdateStatus(ctx, "q-3", domain.QueueStatusProcessing, 0, time.Now(), ""))
+		err := env.queueRepo.TransitionStatus(ctx, "q-3", domain.QueueStatusPending, domain.QueueStatusHeld, 0, time.Now(), "")
+		assert.ErrorIs(t, err, ports.ErrConflict)
+		msg, err := env.queueRepo.Get(ctx, "q-3")
+		require.NoError(t, err)
+		assert.Equal(t, domain.QueueStatusProcessing, msg.Status)
+
+		err = env.queueRepo.TransitionStatus(ctx, "missing", domain.QueueStatusPending, domain.QueueStatusHeld, 0, time.Now(), "")
+		assert.ErrorIs(t, err, ports.ErrNotFound)
+		require.NoError(t, env.queueRepo.UpdateStatus(ctx, "q-3", domain.QueueStatusPending, 0, time.Now().Add(time.Hour), ""))
+	})
+
+	t.Run("Reroute", func(t *testing.T) {
+		resp := call("POST", "/reroute", dto.RerouteRequest{Domain: "slow.test", Relay: "relay.example.net"})
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result dto.RerouteResponse
+		env.decodeJSON(t, resp.Body, &result)
+		assert.Equal(t, int64(2), result.Rerouted)
+
+		msg, err := env.queueRepo.Get(ctx, "q-1")
+		require.NoError(t, err)
+		assert.Equal(t, "relay.example.net", msg.Route)
+		other, err := env.queueRepo.Get(ctx, "q-3")
+		require.NoError(t, err)
+		assert.Empty(t, other.Route)
+
+		bad := call("POST", "/reroute", dto.RerouteRequest{Domain: "slow.test", Relay: "http://relay"})
+		bad.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, bad.StatusCode)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := call("DELETE", "/q-1", nil)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		attempts, err := env.queueRepo.ListAttempts(ctx, "q-1")
+		require.NoError(t, err)
+		assert.Empty(t, attempts)
+
+		resp = call("GET", "/q-1", nil)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("RequiresAdmin", func(t *testing.T) {
+		userToken := env.authenticateUser(t, "test@example.com", "testpassword123")
+		req := env.newRequest(t, "GET", "/api/v1/admin/queue", nil, userToken)
+		resp := env.doRequest(t, req)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -3,12 +3,16 @@ package tests
 import (
 	"context"
 	"errors"
+	"net"
+	"net/textproto"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/disk"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/google/uuid"
@@ -17,6 +21,7 @@ import (
 // MockSender for testing DeliveryWorker
 type MockSender struct {
 	ShouldFail bool
+	Err        error // Returned instead of the generic failure when set
 	Calls      int
 	LastData   []byte
 }
@@ -24,6 +29,9 @@ type MockSender struct {
 func (m *MockSender) Send(ctx context.Context, from, to string, data []byte) error {
 	m.Calls++
 	m.LastData = data
+	if m.Err != nil {
+		return m.Err
+	}
 	if m.ShouldFail {
 		return errors.New("simulated network failure")
 	}
@@ -141,3 +149,418 @@ func TestDeliveryRetry(t *testing.T) {
 		t.Errorf("Expected ~5m backoff, got %v", diff)
 	}
 }
+
+// TestDeliveryPermanentFailure_Bounce verifies 5xx replies fail immediately and bounce to a local sender
+func TestDeliveryPermanentFailure_Bounce(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	mockSender := &MockSender{Err: &textproto.Error{Code: 550, Msg: "5.1.1 <nobody@remote.test>: User unknown"}}
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, mockSender, logger, metrics)
+	worker.SetBouncer(env.bouncer, nil)
+
+	original := []byte("From: test@example.com\r\nTo: nobody@remote.test\r\nSubject: Lost letter\r\nMessage-ID: <lost@example.com>\r\n\r\nHello?\r\n")
+	blobKey, err := env.blobStore.Write(ctx, uuid.New().String(), original)
+	if err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+	msgID := uuid.New().String()
+	if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+		ID: msgID, Sender: "test@example.com", Recipient: "nobody@remote.test", BlobKey: blobKey,
+		Status: domain.QueueStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now(), NextRetryAt: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	before, _ := env.emailRepo.CountByUser(ctx, "test@example.com")
+	userBefore, err := env.userRepo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail failed: %v", err)
+	}
+	worker.ProcessNext()
+
+	var status string
+	var retryCount int
+	if err := env.conn.DB.QueryRow("SELECT status, retry_count FROM queue WHERE id = ?", msgID).Scan(&status, &retryCount); err != nil {
+		t.Fatalf("Failed to query queue: %v", err)
+	}
+	if status != "FAILED" || retryCount != 0 {
+		t.Errorf("Expected immediate FAILED without retries, got %s after %d retries", status, retryCount)
+	}
+
+	after, _ := env.emailRepo.CountByUser(ctx, "test@example.com")
+	if after != before+1 {
+		t.Fatalf("Expected DSN in sender mailbox, count %d -> %d", before, after)
+	}
+	msgs, err := env.emailRepo.FindByUser(ctx, "test@example.com", 10, 0)
+	if err != nil {
+		t.Fatalf("FindByUser failed: %v", err)
+	}
+	var dsn *domain.Message
+	for _, m := range msgs {
+		if strings.HasPrefix(m.Sender, "MAILER-DAEMON@") {
+			dsn = m
+		}
+	}
+	if dsn == nil {
+		t.Fatal("DSN message not found in sender mailbox")
+	}
+	body, err := env.blobStore.Read(ctx, dsn.BodyPath)
+	if err != nil {
+		t.Fatalf("Failed to read DSN: %v", err)
+	}
+	for _, want := range []string{"report-type=delivery-status", "Action: failed", "Status: 5.1.1", "Final-Recipient: rfc822; nobody@remote.test", "Subject: Lost letter"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("DSN missing %q", want)
+		}
+	}
+
+	// The DSN is delivered like any local message: accounted and searchable
+	userAfter, err := env.userRepo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail failed: %v", err)
+	}
+	if userAfter.StorageUsed != userBefore.StorageUsed+int64(len(body)) {
+		t.Errorf("Expected storage used to grow by %d, got %d -> %d", len(body), userBefore.StorageUsed, userAfter.StorageUsed)
+	}
+	results, err := env.searchIdx.Search(ctx, "test@example.com", "Undelivered", 10, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].MessageID != dsn.ID {
+		t.Errorf("Expected the DSN in search results, got %+v", results)
+	}
+}
+
+// TestDeliveryDelayWarning verifies a "delayed" DSN is queued once for a remote sender
+func TestDeliveryDelayWarning(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	mockSender := &MockSender{ShouldFail: true}
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, mockSender, logger, metrics)
+	worker.SetBouncer(env.bouncer, []time.Duration{time.Hour})
+
+	blobKey, err := env.blobStore.Write(ctx, uuid.New().String(), []byte("Subject: Slow\r\n\r\nBody\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+	msgID := uuid.New().String()
+	if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+		ID: msgID, Sender: "someone@remote.test", Recipient: "slow@remote.test", BlobKey: blobKey,
+		Status: domain.QueueStatusPending, CreatedAt: time.Now().Add(-2 * time.Hour), UpdatedAt: time.Now(), NextRetryAt: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	countDSNs := func() int {
+		var n int
+		if err := env.conn.DB.QueryRow("SELECT COUNT(*) FROM queue WHERE sender = '' AND recipient = 'someone@remote.test'").Scan(&n); err != nil {
+			t.Fatalf("Failed to count DSNs: %v", err)
+		}
+		return n
+	}
+
+	worker.ProcessNext()
+	if n := countDSNs(); n != 1 {
+		t.Fatalf("Expected 1 delayed DSN queued, got %d", n)
+	}
+
+	// A second transient failure must not repeat the same warning
+	if _, err := env.conn.DB.Exec("UPDATE queue SET next_retry_at = ? WHERE id = ?", time.Now().Add(-time.Minute).Unix(), msgID); err != nil {
+		t.Fatalf("Failed to update retry time: %v", err)
+	}
+	// Move the DSN out of the way so ProcessNext picks the original message
+	if _, err := env.conn.DB.Exec("UPDATE queue SET status = 'SENT' WHERE sender = ''"); err != nil {
+		t.Fatalf("Failed to update DSN status: %v", err)
+	}
+	worker.ProcessNext()
+	if n := countDSNs(); n != 1 {
+		t.Errorf("Expected delay warning to be sent once, got %d", n)
+	}
+}
+
+// TestDeliveryEngine_BatchesPerDomain verifies that ready messages for one domain share a
+// single SMTP session, that one blob sent to several recipients becomes one transaction,
+// and that the session is reused while there is more work for the MX
+func TestDeliveryEngine_BatchesPerDomain(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	mockServer, err := NewMockSMTPServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mockServer.Close()
+
+	logger := observability.NewLogger("error", "text")
+	client := smtp.NewClient(config.DANEConfig{Mode: "off"}, logger)
+	client.Port = mockServer.Port
+	client.LookupMX = func(name string) ([]*net.MX, error) {
+		return []*net.MX{{Host: "127.0.0.1", Pref: 10}}, nil
+	}
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, client, logger, observability.NewMetrics())
+	defer client.Close()
+
+	enqueue := func(blobKey, recipient string) string {
+		id := uuid.New().String()
+		if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+			ID: id, Sender: "test@example.com", Recipient: recipient, BlobKey: blobKey,
+			Status: domain.QueueStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now(), NextRetryAt: time.Now().Add(-time.Minute),
+		}); err != nil {
+			t.Fatalf("Failed to enqueue: %v", err)
+		}
+		return id
+	}
+	writeBlob := func(body string) string {
+		key, err := env.blobStore.Write(ctx, uuid.New().String(), []byte(body))
+		if err != nil {
+			t.Fatalf("Failed to write blob: %v", err)
+		}
+		return key
+	}
+
+	shared := writeBlob("Subject: Shared\r\n\r\nOne message, two recipients\r\n")
+	ids := []string{
+		enqueue(shared, "a@batch.test"),
+		enqueue(shared, "b@batch.test"),
+		enqueue(writeBlob("Subject: Other\r\n\r\nSecond message\r\n"), "c@batch.test"),
+	}
+
+	if !worker.ProcessNext() {
+		t.Fatal("Expected a message to be processed")
+	}
+
+	for _, id := range ids {
+		var status string
+		if err := env.conn.DB.QueryRow("SELECT status FROM queue WHERE id = ?", id).Scan(&status); err != nil {
+			t.Fatalf("Failed to query queue: %v", err)
+		}
+		if status != string(domain.QueueStatusSent) {
+			t.Errorf("Expected message %s SENT, got %s", id, status)
+		}
+	}
+	if msgs := mockServer.getMessages(); len(msgs) != 2 {
+		t.Errorf("Expected 2 transactions (shared blob batched), got %d", len(msgs))
+	}
+	connections, recipients := mockServer.counts()
+	if connections != 1 || recipients != 3 {
+		t.Errorf("Expected 1 session with 3 RCPTs, got %d sessions and %d RCPTs", connections, recipients)
+	}
+
+	// More work for the same MX reuses the idle session
+	enqueue(writeBlob("Subject: Later\r\n\r\nThird message\r\n"), "d@batch.test")
+	worker.ProcessNext()
+	if connections, _ := mockServer.counts(); connections != 1 {
+		t.Errorf("Expected idle session to be reused, got %d sessions", connections)
+	}
+}
+
+// blockingSender holds every delivery until released
+type blockingSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSender) Send(ctx context.Context, from, to string, data []byte) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+// TestDeliveryEngine_PerDomainLimit verifies that a domain at its concurrency limit has
+// further messages handed back to the queue instead of opening another session
+func TestDeliveryEngine_PerDomainLimit(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	sender := &blockingSender{started: make(chan struct{}, 1), release: make(chan struct{})}
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, sender, observability.NewLogger("error", "text"), observability.NewMetrics())
+	worker.SetLimits(4, 1, 1)
+
+	blobKey, err := env.blobStore.Write(ctx, uuid.New().String(), []byte("Subject: Limit\r\n\r\nBody\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+	enqueue := func(recipient string, readyAt time.Time) string {
+		id := uuid.New().String()
+		if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+			ID: id, Sender: "test@example.com", Recipient: recipient, BlobKey: blobKey,
+			Status: domain.QueueStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now(), NextRetryAt: readyAt,
+		}); err != nil {
+			t.Fatalf("Failed to enqueue: %v", err)
+		}
+		return id
+	}
+
+	first := enqueue("one@busy.test", time.Now().Add(-2*time.Minute))
+	second := enqueue("two@busy.test", time.Now().Add(-time.Minute))
+
+	done := make(chan struct{})
+	go func() {
+		worker.ProcessNext()
+		close(done)
+	}()
+	<-sender.started
+
+	// The only busy.test slot is taken: the second message must be deferred, not delivered
+	if !worker.ProcessNext() {
+		t.Fatal("Expected second message to be picked up")
+	}
+	var status string
+	var nextRetry int64
+	if err := env.conn.DB.QueryRow("SELECT status, next_retry_at FROM queue WHERE id = ?", second).Scan(&status, &nextRetry); err != nil {
+		t.Fatalf("Failed to query queue: %v", err)
+	}
+	if status != string(domain.QueueStatusPending) || !time.Unix(nextRetry, 0).After(time.Now()) {
+		t.Errorf("Expected deferred PENDING message, got %s ready at %v", status, time.Unix(nextRetry, 0))
+	}
+
+	close(sender.release)
+	<-done
+	if err := env.conn.DB.QueryRow("SELECT status FROM queue WHERE id = ?", first).Scan(&status); err != nil {
+		t.Fatalf("Failed to query queue: %v", err)
+	}
+	if status != string(domain.QueueStatusSent) {
+		t.Errorf("Expected first message SENT, got %s", status)
+	}
+}
+
+// TestDeliveryEngine_RequeueStale verifies messages orphaned in PROCESSING are recovered
+func TestDeliveryEngine_RequeueStale(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+		ID: id, Sender: "test@example.com", Recipient: "orphan@remote.test", BlobKey: "missing",
+		Status: domain.QueueStatusProcessing, CreatedAt: time.Now().Add(-time.Hour), UpdatedAt: time.Now().Add(-time.Hour), NextRetryAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	count, err := env.queueRepo.RequeueStale(ctx, time.Now().Add(-15*time.Minute))
+	if err != nil {
+		t.Fatalf("RequeueStale failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 requeued message, got %d", count)
+	}
+	msg, err := env.queueRepo.LockNextReady(ctx)
+	if err != nil || msg == nil || msg.ID != id {
+		t.Fatalf("Expected requeued message to be ready again, got %v (%v)", msg, err)
+	}
+}
+
+// TestDeliveryEngine_RerouteAndHistory verifies that rerouted messages are delivered to the
+// relay host instead of the domain's MX, and that each attempt is recorded in the history
+func TestDeliveryEngine_RerouteAndHistory(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	mockServer, err := NewMockSMTPServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mockServer.Close()
+
+	logger := observability.NewLogger("error", "text")
+	client := smtp.NewClient(config.DANEConfig{Mode: "off"}, logger)
+	client.Port = mockServer.Port
+	client.LookupMX = func(name string) ([]*net.MX, error) {
+		return nil, errors.New("no such host")
+	}
+	defer client.Close()
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, client, logger, observability.NewMetrics())
+
+	blobKey, err := env.blobStore.Write(ctx, uuid.New().String(), []byte("Subject: Relay\r\n\r\nBody\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+	id := uuid.New().String()
+	if err := env.queueRepo.Enqueue(ctx, &domain.OutboundMessage{
+		ID: id, Sender: "test@example.com", Recipient: "user@unresolvable.test", BlobKey: blobKey,
+		Status: domain.QueueStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now(), NextRetryAt: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+
+	// Without a route the MX lookup fails and the message is retried
+	worker.ProcessNext()
+	msg, err := env.queueRepo.Get(ctx, id)
+	if err != nil || msg.Status != domain.QueueStatusRetrying {
+		t.Fatalf("Expected RETRYING after failed MX lookup, got %v (%v)", msg, err)
+	}
+
+	count, err := env.queueRepo.Reroute(ctx, "unresolvable.test", "127.0.0.1")
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 rerouted message, got %d (%v)", count, err)
+	}
+	if !worker.ProcessNext() {
+		t.Fatal("Expected rerouted message to be ready immediately")
+	}
+
+	msg, err = env.queueRepo.Get(ctx, id)
+	if err != nil || msg.Status != domain.QueueStatusSent {
+		t.Fatalf("Expected SENT via relay, got %v (%v)", msg, err)
+	}
+	if len(mockServer.getMessages()) != 1 {
+		t.Errorf("Expected relay to receive the message")
+	}
+
+	attempts, err := env.queueRepo.ListAttempts(ctx, id)
+	if err != nil {
+		t.Fatalf("ListAttempts failed: %v", err)
+	}
+	if len(attempts) != 2 || attempts[0].Status != domain.QueueStatusRetrying || attempts[0].Error == "" || attempts[1].Status != domain.QueueStatusSent {
+		t.Errorf("Unexpected retry history: %+v", attempts)
+	}
+}
+
+// TestQueue_EnqueueAllIsAtomic verifies that a failed batch leaves no recipient queued, so
+// that a retried submission can't duplicate the message
+func TestQueue_EnqueueAllIsAtomic(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	now := time.Now()
+	newMsg := func(id, rcpt string) *domain.OutboundMessage {
+		return &domain.OutboundMessage{
+			ID: id, Sender: "test@example.com", Recipient: rcpt, BlobKey: "blob",
+			Status: domain.QueueStatusPending, CreatedAt: now, UpdatedAt: now, NextRetryAt: now,
+		}
+	}
+
+	// The duplicate ID fails the second insert
+	err := env.queueRepo.EnqueueAll(ctx, []*domain.OutboundMessage{newMsg("batch-1", "a@remote.test"), newMsg("batch-1", "b@remote.test")})
+	if err == nil {
+		t.Fatal("Expected duplicate ID to fail the batch")
+	}
+	queued, err := env.queueRepo.List(ctx, domain.QueueFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(queued) != 0 {
+		t.Fatalf("Expected nothing queued after a failed batch, got %d", len(queued))
+	}
+
+	if err := env.queueRepo.EnqueueAll(ctx, []*domain.OutboundMessage{newMsg("batch-1", "a@remote.test"), newMsg("batch-2", "b@remote.test")}); err != nil {
+		t.Fatalf("EnqueueAll failed: %v", err)
+	}
+	if queued, _ = env.queueRepo.List(ctx, domain.QueueFilter{Limit: 10}); len(queued) != 2 {
+		t.Errorf("Expected 2 queued messages, got %d", len(queued))
+	}
+}
@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestDKIM_PerDomainSigning verifies that outbound mail is signed with the key of the sender's domain
+func TestDKIM_PerDomainSigning(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	createUser := func(email, password string, role domain.Role) string {
+		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		require.NoError(t, env.userRepo.Create(ctx, &domain.User{
+			Email:        email,
+			PasswordHash: string(hash),
+			Role:         role,
+			CreatedAt:    time.Now(),
+			LastLoginAt:  time.Now(),
+		}))
+		return env.authenticateUser(t, email, password)
+	}
+	adminToken := createUser("admin@example.com", "admin123", domain.RoleAdmin)
+
+	post := func(path, token string, body interface{}) *http.Response {
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		req := env.newRequest(t, "POST", path, &buf, token)
+		req.Header.Set("Content-Type", "application/json")
+		return env.doRequest(t, req)
+	}
+
+	// send submits a message through the API and returns the queued raw message
+	send := func(token string) string {
+		resp := post("/api/v1/messages/send", token, map[string]string{
+			"to": "bob@remote.test", "subject": "Hello", "body": "Hi Bob",
+		})
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		var result map[string]string
+		env.decodeJSON(t, resp.Body, &result)
+		resp.Body.Close()
+
+		msg, err := env.queueRepo.Get(ctx, result["id"])
+		require.NoError(t, err)
+		data, err := env.blobStore.Read(ctx, msg.BlobKey)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	resp := post("/api/v1/admin/domains", adminToken, map[string]string{"name": "hosted.test"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	t.Run("HostedDomainKeys", func(t *testing.T) {
+		raw := send(createUser("alice@hosted.test", "alicepass1", domain.RoleUser))
+		require.True(t, strings.HasPrefix(raw, "DKIM-Signature:"), "message not signed: %.80q", raw)
+		assert.Equal(t, 2, strings.Count(raw, "DKIM-Signature:"), "expected RSA and Ed25519 signatures")
+		assert.Contains(t, raw, "a=rsa-sha256; c=relaxed/relaxed; d=hosted.test;")
+		assert.Contains(t, raw, "a=ed25519-sha256; c=relaxed/relaxed; d=hosted.test;")
+	})
+
+	t.Run("PrimaryDomainFallsBackToGlobalKey", func(t *testing.T) {
+		raw := send(createUser("carol@test.example.com", "carolpass1", domain.RoleUser))
+		require.True(t, strings.HasPrefix(raw, "DKIM-Signature:"), "message not signed: %.80q", raw)
+		assert.Contains(t, raw, "d=test.example.com;")
+	})
+
+	t.Run("UnknownDomainUnsigned", func(t *testing.T) {
+		raw := send(env.authenticateUser(t, "test@example.com", "testpassword123"))
+		assert.False(t, strings.Contains(raw, "DKIM-Signature:"), "message for a domain without a key was signed")
+	})
+}
+
+// TestDKIM_KeyRotation walks a domain's Ed25519 key through the rotation workflow of the admin API
+func TestDKIM_KeyRotation(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	dns := map[string][]string{}
+	env.dkimKeys.LookupTXT = func(name string) ([]string, error) {
+		if records, ok := dns[name]; ok {
+			return records, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	require.NoError(t, env.userRepo.Create(ctx, &domain.User{
+		Email:        "admin@rotate.test",
+		PasswordHash: string(hash),
+		Role:         domain.RoleAdmin,
+		CreatedAt:    time.Now(),
+		LastLoginAt:  time.Now(),
+	}))
+	token := env.authenticateUser(t, "admin@rotate.test", "admin123")
+
+	call := func(method, path string, body interface{}, wantStatus int, v interface{}) {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := env.newRequest(t, method, "/api/v1/admin/domains"+path, &buf, token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := env.doRequest(t, req)
+		defer resp.Body.Close()
+		require.Equal(t, wantStatus, resp.StatusCode, "%s %s", method, path)
+		if v != nil {
+			env.decodeJSON(t, resp.Body, v)
+		}
+	}
+	listKeys := func() map[string]dto.DKIMKey {
+		var list dto.DKIMKeyListResponse
+		call("GET", "/rotate.test/dkim", nil, http.StatusOK, &list)
+		keys := map[string]dto.DKIMKey{}
+		for _, k := range list.Keys {
+			keys[k.Selector] = k
+		}
+		return keys
+	}
+
+	call("POST", "", map[string]string{"name": "rotate.test"}, http.StatusCreated, nil)
+	initial := listKeys()
+	require.Len(t, initial, 2)
+	var oldEd dto.DKIMKey
+	for _, k := range initial {
+		assert.Equal(t, "active", k.Status)
+		if k.Algorithm == domain.DKIMAlgorithmEd25519 {
+			oldEd = k
+		}
+	}
+	require.NotEmpty(t, oldEd.Selector)
+
+	// A new key is pending and gets a selector of its own
+	var newEd dto.DKIMKey
+	call("POST", "/rotate.test/dkim", map[string]string{"algorithm": "ed25519"}, http.StatusCreated, &newEd)
+	assert.Equal(t, "pending", newEd.Status)
+	assert.NotEqual(t, oldEd.Selector, newEd.Selector)
+	assert.Equal(t, newEd.Selector+"._domainkey.rotate.test", newEd.DNSName)
+	assert.True(t, strings.HasPrefix(newEd.DNSValue, "v=DKIM1; k=ed25519; p="), newEd.DNSValue)
+	call("POST", "/rotate.test/dkim", map[string]string{"algorithm": "dsa"}, http.StatusBadRequest, nil)
+
+	// Activation requires the DNS record to be published
+	call("POST", "/rotate.test/dkim/"+newEd.Selector+"/activate", nil, http.StatusUnprocessableEntity, nil)
+	call("POST", "/rotate.test/dkim/"+newEd.Selector+"/verify", nil, http.StatusUnprocessableEntity, nil)
+	dns[newEd.DNSName] = []string{newEd.DNSValue}
+	var verified dto.DKIMKey
+	call("POST", "/rotate.test/dkim/"+newEd.Selector+"/verify", nil, http.StatusOK, &verified)
+	require.NotNil(t, verified.VerifiedAt)
+
+	var activated dto.DKIMKey
+	call("POST", "/rotate.test/dkim/"+newEd.Selector+"/activate", map[string]string{"grace_period": "1ms"}, http.StatusOK, &activated)
+	assert.Equal(t, "active", activated.Status)
+
+	keys := listKeys()
+	assert.Equal(t, "retiring", keys[oldEd.Selector].Status)
+	assert.NotNil(t, keys[oldEd.Selector].RetireAt)
+	for _, k := range initial {
+		if k.Algorithm == domain.DKIMAlgorithmRSA {
+			assert.Equal(t, "active", keys[k.Selector].Status, "RSA key must keep signing")
+		}
+	}
+
+	// Signing has switched to the new selector
+	signers, err := env.signers.SignersFor(ctx, "rotate.test")
+	require.NoError(t, err)
+	selectors := map[string]bool{}
+	for _, s := range signers {
+		selectors[s.Selector] = true
+	}
+	assert.Len(t, selectors, 2)
+	assert.True(t, selectors[newEd.Selector])
+	assert.False(t, selectors[oldEd.Selector])
+
+	// Keys in use cannot be deleted
+	call("DELETE", "/rotate.test/dkim/"+newEd.Selector, nil, http.StatusConflict, nil)
+	call("DELETE", "/rotate.test/dkim/"+oldEd.Selector, nil, http.StatusConflict, nil)
+
+	// Once the grace period has passed the old key is destroyed and can be removed
+	retired, err := env.dkimKeys.RetireExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, retired)
+	assert.Equal(t, "retired", listKeys()[oldEd.Selector].Status)
+	call("POST", "/rotate.test/dkim/"+oldEd.Selector+"/activate", nil, http.StatusConflict, nil)
+	call("DELETE", "/rotate.test/dkim/"+oldEd.Selector, nil, http.StatusNoContent, nil)
+	assert.Len(t, listKeys(), 2)
+
+	call("GET", "/unknown.test/dkim", nil, http.StatusNotFound, nil)
+	call("POST", "/rotate.test/dkim/nope/verify", nil, http.StatusNotFound, nil)
+
+	// The domain row keeps no private key that signing could fall back to
+	d, err := env.domainRepo.Get(ctx, "rotate.test")
+	require.NoError(t, err)
+	assert.Empty(t, d.DKIMPrivateKey)
+}
+
+// TestDKIM_ProvisionIsAtomic verifies a domain is not created when storing its keys fails
+func TestDKIM_ProvisionIsAtomic(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	now := time.Now()
+	key := &domain.DKIMKey{
+		Domain: "atomic.test", Selector: "dup", Algorithm: domain.DKIMAlgorithmRSA,
+		PublicKey: "AAAA", Status: domain.DKIMKeyActive, CreatedAt: now,
+	}
+	err := env.domainRepo.CreateWithDKIMKeys(ctx, &domain.Domain{Name: "atomic.test", CreatedAt: now, UpdatedAt: now, Active: true},
+		[]*domain.DKIMKey{key, key})
+	require.Error(t, err)
+
+	exists, err := env.domainRepo.Exists(ctx, "atomic.test")
+	require.NoError(t, err)
+	assert.False(t, exists, "domain must not exist without its keys")
+	keys, err := env.domainRepo.ListDKIMKeys(ctx, "atomic.test")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+// TestDKIM_UpdateKeysIsAtomic verifies that retiring the active key and activating its
+// successor either both happen or neither does
+func TestDKIM_UpdateKeysIsAtomic(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	now := time.Now()
+	active := &domain.DKIMKey{
+		Domain: "swap.test", Selector: "old", Algorithm: domain.DKIMAlgorithmRSA,
+		PublicKey: "AAAA", Status: domain.DKIMKeyActive, CreatedAt: now,
+	}
+	require.NoError(t, env.domainRepo.CreateWithDKIMKeys(ctx, &domain.Domain{Name: "swap.test", CreatedAt: now, UpdatedAt: now, Active: true},
+		[]*domain.DKIMKey{active}))
+
+	// The second key doesn't exist, so the first update must not stick either
+	retiring := *active
+	retiring.Status = domain.DKIMKeyRetiring
+	retiring.RetireAt = now.Add(time.Hour)
+	missing := &domain.DKIMKey{Domain: "swap.test", Selector: "missing", Status: domain.DKIMKeyActive}
+	assert.ErrorIs(t, env.domainRepo.UpdateDKIMKeys(ctx, []*domain.DKIMKey{&retiring, missing}), ports.ErrNotFound)
+
+	keys, err := env.domainRepo.ListDKIMKeys(ctx, "swap.test")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, domain.DKIMKeyActive, keys[0].Status)
+}
@@ -101,6 +101,21 @@ func TestArchiveAndSpamFeatures(t *testing.T) {
 		// Verify in DB
 		updatedMsg, _ := env.emailRepo.FindByID(ctx, msg1.ID)
 		assert.Equal(t, "Archive", updatedMsg.Mailbox)
+
+		// The message gets a UID of the new mailbox and vanishes from the old one
+		archive, err := env.emailRepo.GetMailbox(ctx, user.Email, "Archive")
+		if assert.NoError(t, err) {
+			assert.Equal(t, uint32(1), updatedMsg.UID)
+			assert.Equal(t, uint32(2), archive.UIDNext)
+			assert.Equal(t, 1, archive.MessageCount)
+		}
+		inbox, err := env.emailRepo.GetMailbox(ctx, user.Email, "INBOX")
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, inbox.MessageCount)
+		}
+		expunged, err := env.emailRepo.FindExpunged(ctx, user.Email, "INBOX", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []uint32{msg1.UID}, expunged)
 	})
 
 	t.Run("Report Spam", func(t *testing.T) {
@@ -1,25 +1,39 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	httpAdapter "github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/dto"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/middleware"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/imap"
+	memorypubsub "github.com/Kartikey2011yadav/mailraven-server/internal/adapters/pubsub/memory"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sasl"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/disk"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
@@ -30,15 +44,24 @@ import (
 )
 
 // testEnvironment holds test infrastructure
+// testJWTSecret signs the API tokens of the test environment
+const testJWTSecret = "test-secret-key-for-testing-only"
+
 type testEnvironment struct {
-	server    *httptest.Server
-	tempDir   string
-	emailRepo *sqlite.EmailRepository
-	userRepo  *sqlite.UserRepository
-	queueRepo *sqlite.QueueRepository
-	blobStore *disk.BlobStore
-	messages  []*domain.Message
-	conn      *sqlite.Connection
+	server        *httptest.Server
+	tempDir       string
+	emailRepo     *sqlite.EmailRepository
+	userRepo      *sqlite.UserRepository
+	queueRepo     *sqlite.QueueRepository
+	domainRepo    *sqlite.DomainRepository
+	signers       *dkim.Resolver
+	dkimKeys      *dkim.KeyManager
+	blobStore     *disk.BlobStore
+	searchIdx     *sqlite.SearchRepository
+	notifications *memorypubsub.NotificationBus
+	bouncer       *smtp.Bouncer
+	messages      []*domain.Message
+	conn          *sqlite.Connection
 }
 
 // setupTestEnvironment creates test database and server
@@ -58,7 +81,7 @@ func setupTestEnvironment(t *testing.T) *testEnvironment {
 		API: config.APIConfig{
 			Host:      "127.0.0.1",
 			Port:      8443,
-			JWTSecret: "test-secret-key-for-testing-only",
+			JWTSecret: testJWTSecret,
 		},
 		Storage: config.StorageConfig{
 			DBPath:   filepath.Join(tempDir, "test.db"),
@@ -102,7 +125,8 @@ func setupTestEnvironment(t *testing.T) *testEnvironment {
 	}
 
 	// Initialize repositories
-	emailRepo := sqlite.NewEmailRepository(conn.DB, nil)
+	notifications := memorypubsub.NewNotificationBus(memorypubsub.NewPubSub())
+	emailRepo := sqlite.NewEmailRepository(conn.DB, notifications)
 	userRepo := sqlite.NewUserRepository(conn.DB)
 	queueRepo := sqlite.NewQueueRepository(conn.DB)
 	domainRepo := sqlite.NewDomainRepository(conn.DB)
@@ -119,6 +143,7 @@ func setupTestEnvironment(t *testing.T) *testEnvironment {
 		PasswordHash: string(passwordHash),
 		CreatedAt:    time.Now(),
 	}
+	testUser.SCRAMSHA1, testUser.SCRAMSHA256, _ = sasl.DeriveSCRAM("testpassword123")
 	if err := userRepo.Create(context.Background(), testUser); err != nil {
 		t.Fatalf("Failed to create test user: %v", err)
 	}
@@ -187,19 +212,43 @@ func setupTestEnvironment(t *testing.T) *testEnvironment {
 	// Create TLSRpt Repo for tests
 	tlsRptRepo := sqlite.NewTLSRptRepository(conn.DB)
 
+	// DKIM signers: hosted domains use their own keys, the configured key signs for cfg.Domain
+	dkimPEM, err := os.ReadFile(dkimKeyPath)
+	if err != nil {
+		t.Fatalf("Failed to read DKIM key: %v", err)
+	}
+	dkimKey, err := dkim.ParsePrivateKey(dkimPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse DKIM key: %v", err)
+	}
+	signers := dkim.NewResolver(domainRepo, dkim.NewSigner(cfg.Domain, cfg.DKIM.Selector, dkimKey))
+	dkimKeys := dkim.NewKeyManager(domainRepo, signers, logger)
+
+	// DSNs for local senders go through the regular SMTP delivery path
+	sieveEngine := sieve.NewSieveEngine(sqlite.NewSqliteScriptRepository(conn.DB), emailRepo,
+		sqlite.NewSqliteVacationRepository(conn.DB), queueRepo, blobStore)
+	smtpHandler := smtp.NewHandler(emailRepo, userRepo, blobStore, searchIdx, sieveEngine, logger, metrics)
+	bouncer := smtp.NewBouncer(cfg.Domain, smtpHandler, userRepo, queueRepo, blobStore, logger)
+
 	// Create HTTP server
-	httpServer := httpAdapter.NewServer(cfg, emailRepo, userRepo, queueRepo, domainRepo, blobStore, searchIdx, nil, nil, tlsRptRepo, nil, nil, &NoOpSpamFilter{}, logger, metrics)
+	httpServer := httpAdapter.NewServer(cfg, emailRepo, userRepo, queueRepo, domainRepo, signers, dkimKeys, blobStore, searchIdx, nil, nil, tlsRptRepo, nil, nil, &NoOpSpamFilter{}, bouncer, logger, metrics)
 	testServer := httptest.NewServer(httpServer.Router())
 
 	return &testEnvironment{
-		server:    testServer,
-		tempDir:   tempDir,
-		conn:      conn,
-		emailRepo: emailRepo,
-		userRepo:  userRepo,
-		queueRepo: queueRepo,
-		blobStore: blobStore,
-		messages:  messages,
+		server:        testServer,
+		tempDir:       tempDir,
+		conn:          conn,
+		emailRepo:     emailRepo,
+		userRepo:      userRepo,
+		queueRepo:     queueRepo,
+		domainRepo:    domainRepo,
+		signers:       signers,
+		dkimKeys:      dkimKeys,
+		blobStore:     blobStore,
+		searchIdx:     searchIdx,
+		notifications: notifications,
+		bouncer:       bouncer,
+		messages:      messages,
 	}
 }
 
@@ -285,9 +334,6 @@ func boolPtr(b bool) *bool {
 
 // generateExpiredToken creates a JWT token with specified expiration time
 func generateExpiredToken(t *testing.T, email string, expiresAt time.Time) string {
-	// JWT secret from test config (matches what quickstart generates)
-	jwtSecret := "test-secret-key-for-testing-only"
-
 	claims := &middleware.Claims{
 		Email: email,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -298,7 +344,7 @@ func generateExpiredToken(t *testing.T, email string, expiresAt time.Time) strin
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString([]byte(testJWTSecret))
 	if err != nil {
 		t.Fatalf("Failed to generate expired token: %v", err)
 	}
@@ -326,6 +372,33 @@ func generateTestDKIMKey(t *testing.T, path string) {
 	}
 }
 
+// generateTestTLSConfig returns a server TLS config with a self-signed certificate for hostname
+func generateTestTLSConfig(t *testing.T, hostname string) *tls.Config {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
 // NoOpSpamFilter for tests
 type NoOpSpamFilter struct{}
 
@@ -338,3 +411,101 @@ func (n *NoOpSpamFilter) CheckRecipient(ctx context.Context, ip, sender, recipie
 }
 func (n *NoOpSpamFilter) TrainSpam(ctx context.Context, content io.Reader) error { return nil }
 func (n *NoOpSpamFilter) TrainHam(ctx context.Context, content io.Reader) error  { return nil }
+
+// startIMAPServer starts an IMAP server on a random port backed by the test environment
+// and returns its address. The options are applied before the server starts.
+func (e *testEnvironment) startIMAPServer(t *testing.T, cfg config.IMAPConfig, options ...func(*imap.Server)) string {
+	logger := observability.NewLogger("error", "text")
+	server := imap.NewServer(cfg, logger, nil, e.userRepo, e.emailRepo, e.searchIdx, &NoOpSpamFilter{}, e.blobStore, e.notifications, sasl.NewAuthenticator(e.userRepo, testJWTSecret))
+	for _, option := range options {
+		option(server)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = server.Start(ctx)
+	}()
+
+	for i := 0; i < 50; i++ {
+		if addr := server.Addr(); addr != nil {
+			return addr.String()
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("IMAP server did not start")
+	return ""
+}
+
+// imapTestClient is a minimal line-based IMAP client that understands literals
+type imapTestClient struct {
+	t      *testing.T
+	conn   io.ReadWriteCloser
+	reader *bufio.Reader
+	tag    int
+}
+
+var literalSuffix = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+
+// dialIMAP connects to addr and consumes the greeting
+func dialIMAP(t *testing.T, addr string) *imapTestClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial IMAP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	c := &imapTestClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	if greeting := c.readLine(); !strings.HasPrefix(greeting, "* OK") {
+		t.Fatalf("Unexpected greeting: %q", greeting)
+	}
+	return c
+}
+
+// readLine reads one response line, including the contents of any literals it contains
+func (c *imapTestClient) readLine() string {
+	var sb strings.Builder
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("IMAP read failed: %v (partial %q)", err, sb.String())
+		}
+		sb.WriteString(line)
+		m := literalSuffix.FindStringSubmatch(line)
+		if m == nil {
+			return strings.TrimRight(sb.String(), "\r\n")
+		}
+		n, _ := strconv.Atoi(m[1])
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			c.t.Fatalf("IMAP literal read failed: %v", err)
+		}
+		sb.Write(buf)
+	}
+}
+
+// command sends a tagged command and returns the untagged responses and the tagged status line
+func (c *imapTestClient) command(format string, args ...interface{}) (untagged []string, status string) {
+	c.tag++
+	tag := fmt.Sprintf("T%03d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		c.t.Fatalf("IMAP write failed: %v", err)
+	}
+	for {
+		line := c.readLine()
+		if strings.HasPrefix(line, tag+" ") {
+			return untagged, strings.TrimPrefix(line, tag+" ")
+		}
+		untagged = append(untagged, line)
+	}
+}
+
+// mustOK runs a command and fails the test unless it completes with OK
+func (c *imapTestClient) mustOK(format string, args ...interface{}) []string {
+	untagged, status := c.command(format, args...)
+	if !strings.HasPrefix(status, "OK") {
+		c.t.Fatalf("%q failed: %s", fmt.Sprintf(format, args...), status)
+	}
+	return untagged
+}
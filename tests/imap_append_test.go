@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_Append verifies APPEND with flags and dates, MULTIAPPEND and LITERAL+
+func TestIMAP_Append(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	capability := c.mustOK("CAPABILITY")[0]
+	for _, ext := range []string{"LITERAL+", "MULTIAPPEND", "COMPRESS=DEFLATE"} {
+		assert.Contains(t, capability, " "+ext)
+	}
+
+	// LOGIN with non-synchronizing literals
+	c.mustOK("LOGIN {16+}\r\ntest@example.com {15+}\r\ntestpassword123")
+	c.mustOK("CREATE Archive")
+	archive, err := env.emailRepo.GetMailbox(t.Context(), "test@example.com", "Archive")
+	require.NoError(t, err)
+
+	// Two messages in one command, each with its own flags and internal date
+	first := "Subject: First\r\n\r\nOne"
+	second := "Subject: Second\r\n\r\nTwo"
+	_, status := c.command("APPEND Archive (\\Seen $Label) \" 7-Jul-1996 02:44:25 -0700\" {%d+}\r\n%s () {%d+}\r\n%s",
+		len(first), first, len(second), second)
+	assert.Equal(t, fmt.Sprintf("OK [APPENDUID %d 1:2] APPEND completed", archive.UIDValidity), status)
+
+	c.mustOK("SELECT Archive")
+	untagged := c.mustOK("FETCH 1:2 (FLAGS INTERNALDATE)")
+	require.Len(t, untagged, 2)
+	assert.Contains(t, untagged[0], "\\Seen")
+	assert.Contains(t, untagged[0], "$Label")
+	assert.Contains(t, untagged[0], `INTERNALDATE " 7-Jul-1996 09:44:25 +0000"`)
+	assert.NotContains(t, untagged[1], "\\Seen")
+
+	// A missing mailbox asks the client to create it; the literal data must not be taken for commands
+	_, status = c.command("APPEND Missing {%d+}\r\n%s", len(first), first)
+	assert.True(t, strings.HasPrefix(status, "NO [TRYCREATE]"), status)
+
+	// A bad second message rejects the whole command
+	_, status = c.command("APPEND Archive {%d+}\r\n%s (\\Recent) {%d+}\r\n%s", len(first), first, len(second), second)
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	untagged = c.mustOK("STATUS Archive (MESSAGES)")
+	assert.Equal(t, []string{`* STATUS "Archive" (MESSAGES 2)`}, untagged)
+
+	// The messages of a MULTIAPPEND are limited together, not only one by one
+	c = dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true, MaxAppendSize: int64(len(first) + len(second) - 1)}))
+	c.mustOK("LOGIN test@example.com testpassword123")
+	_, status = c.command("APPEND Archive {%d+}\r\n%s {%d+}\r\n%s", len(first), first, len(second), second)
+	assert.True(t, strings.HasPrefix(status, "NO [TOOBIG]"), status)
+	c.mustOK("APPEND Archive {%d+}\r\n%s", len(second), second)
+	untagged = c.mustOK("STATUS Archive (MESSAGES)")
+	assert.Equal(t, []string{`* STATUS "Archive" (MESSAGES 3)`}, untagged)
+}
+
+// deflateStream speaks DEFLATE in both directions over a connection, like a client after
+// COMPRESS DEFLATE
+type deflateStream struct {
+	io.Reader
+	w    *flate.Writer
+	conn io.Closer
+}
+
+func (d *deflateStream) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, d.w.Flush()
+}
+
+func (d *deflateStream) Close() error {
+	return d.conn.Close()
+}
+
+// TestIMAP_Compress verifies COMPRESS DEFLATE (RFC 4978)
+func TestIMAP_Compress(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	_, status := c.command("COMPRESS DEFLATE")
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+
+	c.mustOK("LOGIN test@example.com testpassword123")
+	_, status = c.command("COMPRESS GZIP")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	c.mustOK("COMPRESS DEFLATE")
+
+	w, err := flate.NewWriter(c.conn, flate.DefaultCompression)
+	require.NoError(t, err)
+	stream := &deflateStream{Reader: flate.NewReader(c.reader), w: w, conn: c.conn}
+	c.conn, c.reader = stream, bufio.NewReader(stream)
+
+	untagged := c.mustOK("SELECT INBOX")
+	assert.Contains(t, untagged, "* 3 EXISTS")
+	untagged = c.mustOK("FETCH 1 (BODY.PEEK[HEADER.FIELDS (SUBJECT)])")
+	require.Len(t, untagged, 1)
+	assert.Contains(t, untagged[0], "Subject: Test Message")
+
+	_, status = c.command("COMPRESS DEFLATE")
+	assert.Equal(t, "NO [COMPRESSIONACTIVE] Compression already active", status)
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/middleware"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authenticate runs AUTHENTICATE, answering each server challenge with respond, and returns
+// the tagged status line
+func (c *imapTestClient) authenticate(args string, respond func(challenge []byte) []byte) string {
+	c.tag++
+	tag := fmt.Sprintf("T%03d", c.tag)
+	_, err := fmt.Fprintf(c.conn, "%s AUTHENTICATE %s\r\n", tag, args)
+	require.NoError(c.t, err)
+	for {
+		line := c.readLine()
+		if strings.HasPrefix(line, tag+" ") {
+			return strings.TrimPrefix(line, tag+" ")
+		}
+		if !strings.HasPrefix(line, "+") {
+			continue
+		}
+		challenge, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(line, "+")))
+		require.NoError(c.t, err)
+		response := respond(challenge)
+		if string(response) == "*" {
+			_, err = fmt.Fprintf(c.conn, "*\r\n")
+		} else {
+			_, err = fmt.Fprintf(c.conn, "%s\r\n", base64.StdEncoding.EncodeToString(response))
+		}
+		require.NoError(c.t, err)
+	}
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// scramSHA256 returns a responder playing the client side of SCRAM-SHA-256 (RFC 7677)
+func scramSHA256(t *testing.T, username, password string) func([]byte) []byte {
+	clientFirstBare := "n=" + username + ",r=rOprNGfwEbeRWgbNEkqO"
+	var salted []byte
+	var authMessage string
+	step := 0
+	return func(challenge []byte) []byte {
+		step++
+		switch step {
+		case 1:
+			return []byte("n,," + clientFirstBare)
+		case 2:
+			attrs := map[string]string{}
+			for _, attr := range strings.Split(string(challenge), ",") {
+				attrs[attr[:1]] = attr[2:]
+			}
+			salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+			require.NoError(t, err)
+			var iterations int
+			_, err = fmt.Sscan(attrs["i"], &iterations)
+			require.NoError(t, err)
+			salted, err = pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+			require.NoError(t, err)
+
+			withoutProof := "c=biws,r=" + attrs["r"]
+			authMessage = clientFirstBare + "," + string(challenge) + "," + withoutProof
+			clientKey := hmacSHA256(salted, []byte("Client Key"))
+			storedKey := sha256.Sum256(clientKey)
+			proof := hmacSHA256(storedKey[:], []byte(authMessage))
+			for i := range proof {
+				proof[i] ^= clientKey[i]
+			}
+			return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof))
+		default:
+			serverSignature := hmacSHA256(hmacSHA256(salted, []byte("Server Key")), []byte(authMessage))
+			assert.Equal(t, "v="+base64.StdEncoding.EncodeToString(serverSignature), string(challenge))
+			return nil
+		}
+	}
+}
+
+// TestIMAP_Authenticate verifies SASL authentication with SASL-IR, SCRAM and OAUTHBEARER
+func TestIMAP_Authenticate(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	// Without TLS only SCRAM is offered
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{}))
+	capability := c.mustOK("CAPABILITY")[0]
+	assert.Contains(t, capability, " SASL-IR ")
+	assert.Contains(t, capability, "AUTH=SCRAM-SHA-256")
+	assert.Contains(t, capability, "LOGINDISABLED")
+	assert.NotContains(t, capability, "AUTH=PLAIN")
+
+	status := c.authenticate("PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00test@example.com\x00testpassword123")), nil)
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+
+	status = c.authenticate("SCRAM-SHA-256", scramSHA256(t, "test@example.com", "wrongpassword"))
+	assert.True(t, strings.HasPrefix(status, "NO [AUTHENTICATIONFAILED]"), status)
+	status = c.authenticate("SCRAM-SHA-256", scramSHA256(t, "test@example.com", "testpassword123"))
+	require.True(t, strings.HasPrefix(status, "OK [CAPABILITY "), status)
+	assert.NotContains(t, status, "AUTH=")
+	c.mustOK("SELECT INBOX")
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+
+	// PLAIN with an initial response
+	c = dialIMAP(t, addr)
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], "AUTH=PLAIN")
+	status = c.authenticate("PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00test@example.com\x00testpassword123")), nil)
+	require.True(t, strings.HasPrefix(status, "OK"), status)
+	_, status = c.command("AUTHENTICATE PLAIN =")
+	assert.Contains(t, status, "NO Already authenticated")
+
+	// PLAIN prompted with an empty challenge, a wrong password, and a cancelled exchange
+	c = dialIMAP(t, addr)
+	status = c.authenticate("PLAIN", func([]byte) []byte { return []byte("\x00test@example.com\x00wrong") })
+	assert.Equal(t, "NO [AUTHENTICATIONFAILED] Authentication failed", status)
+	status = c.authenticate("LOGIN", func([]byte) []byte { return []byte("*") })
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	status = c.authenticate("PLAIN", func([]byte) []byte { return []byte("\x00test@example.com\x00testpassword123") })
+	require.True(t, strings.HasPrefix(status, "OK"), status)
+
+	// OAUTHBEARER with an API token
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &middleware.Claims{
+		Email:            "test@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+
+	c = dialIMAP(t, addr)
+	status = c.authenticate("OAUTHBEARER "+base64.StdEncoding.EncodeToString([]byte("n,a=test@example.com,\x01auth=Bearer invalid\x01\x01")),
+		func(challenge []byte) []byte {
+			assert.Contains(t, string(challenge), "invalid_token")
+			return []byte("\x01")
+		})
+	assert.True(t, strings.HasPrefix(status, "NO [AUTHENTICATIONFAILED]"), status)
+	status = c.authenticate("OAUTHBEARER "+base64.StdEncoding.EncodeToString([]byte("n,a=test@example.com,\x01auth=Bearer "+token+"\x01\x01")), nil)
+	require.True(t, strings.HasPrefix(status, "OK"), status)
+	c.mustOK("SELECT INBOX")
+
+	// Changing the password replaces the SCRAM credentials with it
+	apiToken := env.authenticateUser(t, "test@example.com", "testpassword123")
+	req := env.newRequest(t, "PUT", "/api/v1/users/self/password",
+		strings.NewReader(`{"current_password":"testpassword123","new_password":"newpassword456"}`), apiToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp := env.doRequest(t, req)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	c = dialIMAP(t, addr)
+	status = c.authenticate("SCRAM-SHA-256", scramSHA256(t, "test@example.com", "testpassword123"))
+	assert.True(t, strings.HasPrefix(status, "NO [AUTHENTICATIONFAILED]"), status)
+	status = c.authenticate("SCRAM-SHA-256", scramSHA256(t, "test@example.com", "newpassword456"))
+	assert.True(t, strings.HasPrefix(status, "OK"), status)
+}
+
+// TestIMAP_LoginDerivesSCRAM verifies that LOGIN gives users without SCRAM credentials the
+// same upgrade as AUTHENTICATE PLAIN, so that they can use SCRAM afterwards
+func TestIMAP_LoginDerivesSCRAM(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("legacypass"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	require.NoError(t, env.userRepo.Create(t.Context(), &domain.User{
+		Email: "legacy@example.com", PasswordHash: string(hash), Role: domain.RoleUser, CreatedAt: time.Now(),
+	}))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	status := c.authenticate("SCRAM-SHA-256", scramSHA256(t, "legacy@example.com", "legacypass"))
+	assert.True(t, strings.HasPrefix(status, "NO [AUTHENTICATIONFAILED]"), status)
+
+	_, status = c.command("LOGIN legacy@example.com wrongpass")
+	assert.True(t, strings.HasPrefix(status, "NO [AUTHENTICATIONFAILED]"), status)
+	c.mustOK("LOGIN legacy@example.com legacypass")
+
+	user, err := env.userRepo.FindByEmail(t.Context(), "legacy@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, user.SCRAMSHA256)
+
+	c = dialIMAP(t, addr)
+	status = c.authenticate("SCRAM-SHA-256", scramSHA256(t, "legacy@example.com", "legacypass"))
+	assert.True(t, strings.HasPrefix(status, "OK"), status)
+}
@@ -39,7 +39,7 @@ func TestIMAP_Compliance(t *testing.T) {
 		AllowInsecureAuth: true,
 	}
 	logger := observability.NewLogger("error", "text")
-	server := imap.NewServer(imapCfg, logger, nil, env.userRepo, env.emailRepo, &NoOpSpamFilter{}, env.blobStore, nil)
+	server := imap.NewServer(imapCfg, logger, nil, env.userRepo, env.emailRepo, env.searchIdx, &NoOpSpamFilter{}, env.blobStore, nil, nil)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// responseNumber extracts the number following prefix from the first matching response line
+func responseNumber(t *testing.T, lines []string, prefix string) uint64 {
+	t.Helper()
+	re := regexp.MustCompile(regexp.QuoteMeta(prefix) + `(\d+)`)
+	for _, line := range lines {
+		if m := re.FindStringSubmatch(line); m != nil {
+			n, err := strconv.ParseUint(m[1], 10, 64)
+			require.NoError(t, err)
+			return n
+		}
+	}
+	t.Fatalf("no %q in %q", prefix, lines)
+	return 0
+}
+
+// TestIMAP_CondStoreQResync verifies CONDSTORE mod-sequences and QRESYNC resynchronization
+func TestIMAP_CondStoreQResync(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+
+	untagged := c.mustOK("SELECT INBOX (CONDSTORE)")
+	synced := responseNumber(t, untagged, "[HIGHESTMODSEQ ")
+	uidValidity := responseNumber(t, untagged, "[UIDVALIDITY ")
+
+	// Every flag change gets a new mod-sequence
+	untagged = c.mustOK("UID STORE 1 +FLAGS (\\Flagged)")
+	require.Len(t, untagged, 1)
+	flagged := responseNumber(t, untagged, "MODSEQ (")
+	assert.Greater(t, flagged, synced)
+	assert.Equal(t, fmt.Sprintf("* 1 FETCH (UID 1 FLAGS (\\Flagged) MODSEQ (%d))", flagged), untagged[0])
+
+	untagged = c.mustOK("UID FETCH 1:* (FLAGS) (CHANGEDSINCE %d)", synced)
+	assert.Equal(t, []string{fmt.Sprintf("* 1 FETCH (UID 1 FLAGS (\\Flagged) MODSEQ (%d))", flagged)}, untagged)
+
+	untagged = c.mustOK("FETCH 2 (MODSEQ)")
+	require.Len(t, untagged, 1)
+	assert.Regexp(t, `^\* 2 FETCH \(MODSEQ \(\d+\)\)$`, untagged[0])
+
+	// A conditional store fails for messages changed since the given mod-sequence
+	untagged, status := c.command("STORE 1:2 (UNCHANGEDSINCE %d) +FLAGS.SILENT (\\Seen)", flagged-1)
+	assert.Equal(t, "OK [MODIFIED 1] Conditional STORE failed", status)
+	require.Len(t, untagged, 1)
+	assert.True(t, strings.HasPrefix(untagged[0], "* 2 FETCH (MODSEQ ("), untagged[0])
+
+	assert.Equal(t, []string{fmt.Sprintf("* SEARCH 1 (MODSEQ %d)", flagged)}, c.mustOK("SEARCH MODSEQ %d FLAGGED", flagged))
+
+	// Another client resynchronizes after a message was expunged
+	c.mustOK("UID STORE 3 +FLAGS (\\Deleted)")
+	c.mustOK("EXPUNGE")
+
+	q := dialIMAP(t, addr)
+	q.mustOK("LOGIN test@example.com testpassword123")
+	_, status = q.command("SELECT INBOX (QRESYNC (%d %d))", uidValidity, synced)
+	assert.Contains(t, status, "BAD", "QRESYNC must be enabled first")
+
+	assert.Equal(t, []string{"* ENABLED QRESYNC"}, q.mustOK("ENABLE QRESYNC"))
+	untagged = q.mustOK("SELECT INBOX (QRESYNC (%d %d 1:*))", uidValidity, synced)
+	assert.Contains(t, untagged, "* VANISHED (EARLIER) 3")
+	assert.Contains(t, untagged, fmt.Sprintf("* 1 FETCH (UID 1 FLAGS (\\Flagged) MODSEQ (%d))", flagged))
+
+	untagged = q.mustOK("UID FETCH 1:* (FLAGS) (CHANGEDSINCE %d VANISHED)", synced)
+	assert.Equal(t, "* VANISHED (EARLIER) 3", untagged[0])
+
+	// QRESYNC clients are told about expunges with VANISHED
+	q.mustOK("UID STORE 2 +FLAGS (\\Deleted)")
+	assert.Equal(t, []string{"* VANISHED 2"}, q.mustOK("EXPUNGE"))
+}
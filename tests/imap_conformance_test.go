@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceStep is one command of the conformance script. The tagged status must start
+// with status, every string of want must occur in an untagged response and none of reject.
+type conformanceStep struct {
+	command string
+	status  string
+	want    []string
+	reject  []string
+}
+
+// conformanceMessage has a base64-encoded attachment of six octets, 0x00 to 0x05
+const conformanceMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: test@example.com\r\n" +
+	"Subject: Binary\r\n" +
+	"Date: Mon, 2 Mar 2026 10:00:00 +0000\r\n" +
+	"Message-ID: <binary@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"See attached\r\n" +
+	"--b\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"AAECAwQF\r\n" +
+	"--b--\r\n"
+
+// TestIMAP_Conformance runs the whole command set over a socket, once as an IMAP4rev1
+// client and once as an IMAP4rev2 client (RFC 9051), which sees different responses
+func TestIMAP_Conformance(t *testing.T) {
+	for _, mode := range []string{"IMAP4rev1", "IMAP4rev2"} {
+		t.Run(mode, func(t *testing.T) {
+			testConformance(t, mode == "IMAP4rev2")
+		})
+	}
+}
+
+func testConformance(t *testing.T, rev2 bool) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+
+	either := func(rev1, rev2Value string) []string {
+		if rev2 {
+			return []string{rev2Value}
+		}
+		return []string{rev1}
+	}
+	cafe := `"Caf&AOk-"`
+	if rev2 {
+		cafe = `"Café"`
+	}
+
+	steps := []conformanceStep{
+		// Any state
+		{command: "CAPABILITY", want: []string{"* CAPABILITY IMAP4rev1 IMAP4rev2 ", " BINARY ", " SEARCHRES ", " CHILDREN ", " ENABLE "}},
+		{command: "NOOP"},
+		{command: `ID ("name" "conformance")`, want: []string{`* ID ("name" "MailRaven"`}},
+		{command: "SELECT INBOX", status: "NO"},
+
+		// Not authenticated and authenticated states
+		{command: "LOGIN test@example.com testpassword123", status: "OK [CAPABILITY IMAP4rev1 IMAP4rev2 "},
+	}
+	if rev2 {
+		steps = append(steps, conformanceStep{command: "ENABLE IMAP4rev2", want: []string{"* ENABLED IMAP4rev2"}})
+	}
+	steps = append(steps, []conformanceStep{
+		{command: "NAMESPACE", want: []string{`* NAMESPACE (("" "/"))`}},
+		{command: "CREATE Archive/2026"},
+		{command: "CREATE Archive", status: "NO [ALREADYEXISTS]"},
+		{command: "CREATE " + cafe},
+		{command: `LIST "" "*"`, want: []string{
+			`* LIST (\HasNoChildren) "/" "INBOX"`,
+			`* LIST (\HasChildren) "/" "Archive"`,
+			`* LIST (\HasNoChildren) "/" "Archive/2026"`,
+			`* LIST (\HasNoChildren) "/" ` + cafe,
+		}},
+		{command: `LIST "" %`, want: []string{`"Archive"`}, reject: []string{`"Archive/2026"`}},
+		{command: `LIST "" ""`, want: []string{`* LIST (\Noselect) "/" ""`}},
+		{command: "RENAME Archive/2026 Archive/Old"},
+		{command: "SUBSCRIBE Archive/Old"},
+		{command: `LSUB "" "*"`, want: []string{`"Archive/Old"`}},
+		{command: `LIST (SUBSCRIBED) "" "*" RETURN (STATUS (MESSAGES))`, want: []string{`\Subscribed) "/" "Archive/Old"`, `* STATUS "Archive/Old" (MESSAGES 0)`}},
+		{command: "UNSUBSCRIBE Archive/Old"},
+		{command: "DELETE Archive/Old"},
+		{command: "STATUS INBOX (MESSAGES UIDNEXT UNSEEN DELETED SIZE)", want: []string{`* STATUS "INBOX" (MESSAGES 3 UIDNEXT `}},
+		{command: "APPEND INBOX (\\Flagged) ~{" + strconv.Itoa(len(conformanceMessage)) + "+}\r\n" + conformanceMessage, status: "OK [APPENDUID "},
+		{command: "GETQUOTAROOT INBOX", want: []string{"* QUOTAROOT ", "* QUOTA "}},
+		{command: "MYRIGHTS INBOX", want: []string{`* MYRIGHTS "INBOX" `}},
+		{command: "SETACL Archive anyone lr"},
+		{command: "GETACL Archive", want: []string{"anyone lr"}},
+		{command: "LISTRIGHTS Archive anyone", want: []string{"* LISTRIGHTS "}},
+		{command: "DELETEACL Archive anyone"},
+		{command: `SETMETADATA INBOX (/private/comment "Conformance")`},
+		{command: "GETMETADATA INBOX /private/comment", want: []string{`* METADATA "INBOX" (/private/comment "Conformance")`}},
+		{command: "NOTIFY NONE"},
+		{command: "FETCH 1 (FLAGS)", status: "NO"},
+
+		// Selected state
+		{command: "SELECT INBOX", status: "OK [READ-WRITE]",
+			want: append([]string{"* 4 EXISTS", "* OK [UIDVALIDITY ", "* OK [UIDNEXT ", "* FLAGS (", "* OK [PERMANENTFLAGS ("},
+				either("* 0 RECENT", `* LIST (\HasNoChildren) "/" "INBOX"`)...),
+			reject: either("* LIST ", " RECENT")},
+		{command: "CHECK"},
+		{command: "FETCH 1:* (UID FLAGS)", want: []string{"* 1 FETCH (UID 1 ", "* 4 FETCH (UID 4 FLAGS (\\Flagged)"}},
+		{command: "FETCH 4 (INTERNALDATE RFC822.SIZE ENVELOPE BODYSTRUCTURE)", want: []string{`"Binary"`, `("APPLICATION" "OCTET-STREAM" `}},
+		{command: "FETCH 4 (BINARY.SIZE[2] BINARY.PEEK[2] BINARY.PEEK[1])", want: []string{"BINARY.SIZE[2] 6", "BINARY[2] ~{6}\r\n\x00\x01\x02\x03\x04\x05", "BINARY[1] {12}\r\nSee attached"}},
+		{command: "FETCH 4 BINARY.PEEK[2]<0.3>", want: []string{"BINARY[2]<0> ~{3}\r\n\x00\x01\x02"}},
+		{command: "FETCH 4 BINARY.PEEK[2]<3.3>", want: []string{"BINARY[2]<3> {3}\r\n\x03\x04\x05"}},
+		{command: "FETCH 4 BINARY[1.HEADER]", status: "BAD"},
+		{command: "FETCH 4 BODY.PEEK[HEADER.FIELDS (SUBJECT)]", want: []string{"Subject: Binary"}},
+		{command: "FETCH 4 BODY[2]", want: []string{"BODY[2] {8}\r\nAAECAwQF", "\\Seen"}},
+		{command: `SEARCH SUBJECT "Test Message"`, want: either("* SEARCH 1 2 3", " ALL 1:3")},
+		{command: "UID SEARCH FLAGGED", want: either("* SEARCH 4", ") UID ALL 4")},
+		{command: "SEARCH RETURN (COUNT) ALL", want: []string{" COUNT 4"}},
+		{command: "SEARCH RETURN (BOGUS) ALL", status: "BAD"},
+		// RFC 5182: the saved result is kept as messages, not as numbers
+		{command: `UID SEARCH RETURN (SAVE) SUBJECT "Message 2"`, reject: []string{"SEARCH"}},
+		{command: "FETCH $ (UID)", want: []string{"* 2 FETCH (UID 2)"}},
+		{command: "SEARCH $", want: either("* SEARCH 2", " ALL 2")},
+		{command: "SEARCH RETURN (SAVE MIN MAX) ALL", want: []string{" MIN 1 MAX 4"}},
+		{command: "SEARCH $", want: either("* SEARCH 1 4", " ALL 1,4")},
+		{command: "STORE 1 +FLAGS (\\Answered)", want: []string{"* 1 FETCH (FLAGS (", "\\Answered"}},
+		{command: "STORE 1 -FLAGS.SILENT (\\Answered)", reject: []string{"FETCH"}},
+		{command: "SORT (REVERSE SUBJECT) UTF-8 ALL", want: []string{"* SORT 3 2 1 4"}},
+		{command: "THREAD ORDEREDSUBJECT UTF-8 ALL", want: []string{"* THREAD (4)(1)(2)(3)"}},
+		{command: "COPY 1 Archive", status: "OK [COPYUID "},
+		{command: "UID SEARCH RETURN (SAVE) UID 2"},
+		{command: "MOVE 2 Archive", want: []string{"* OK [COPYUID ", "* 2 EXPUNGE"}},
+		// The moved message is gone from the saved result
+		{command: "UID STORE $ +FLAGS (\\Seen)", reject: []string{"FETCH"}},
+		{command: "STORE 1 +FLAGS.SILENT (\\Deleted)"},
+		{command: "EXPUNGE", want: []string{"* 1 EXPUNGE"}},
+		{command: "UID STORE 4 +FLAGS.SILENT (\\Deleted)"},
+		{command: "UID EXPUNGE 4", want: []string{"* 2 EXPUNGE"}},
+		{command: "EXAMINE Archive", status: "OK [READ-ONLY]", want: append([]string{"* 2 EXISTS"}, either("* 0 RECENT", "* OK [CLOSED]")...)},
+		{command: "STORE 1 +FLAGS (\\Seen)", status: "NO"},
+		{command: "UNSELECT"},
+		{command: "SELECT Archive"},
+		{command: "CLOSE"},
+		{command: "STATUS Archive (MESSAGES)", want: []string{`* STATUS "Archive" (MESSAGES 2)`}},
+		{command: "DELETE " + cafe},
+		{command: "LOGOUT", want: []string{"* BYE "}},
+	}...)
+
+	for _, step := range steps {
+		status := step.status
+		if status == "" {
+			status = "OK"
+		}
+		untagged, got := c.command("%s", step.command)
+		name := strings.SplitN(step.command, "\r\n", 2)[0]
+		assert.True(t, strings.HasPrefix(got, status), "%s: got %q, want %s", name, got, status)
+		responses := strings.Join(untagged, "\n")
+		for _, want := range step.want {
+			assert.Contains(t, responses, want, name)
+		}
+		for _, reject := range step.reject {
+			assert.NotContains(t, responses, reject, name)
+		}
+	}
+	// IDLE reports a delivery to the selected mailbox
+	c = dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	if rev2 {
+		c.mustOK("ENABLE IMAP4rev2")
+	}
+	c.mustOK("SELECT INBOX")
+	c.idle()
+	env.deliver(t, "conformance-idle", "INBOX")
+	assert.Equal(t, "* 2 EXISTS", c.readLine())
+	assert.Equal(t, "OK IDLE terminated", c.done())
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_Expunge verifies EXPUNGE, UID EXPUNGE, CLOSE, UNSELECT and read-only EXAMINE
+func TestIMAP_Expunge(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	ctx := context.Background()
+	var blobSizes, copySize int64
+	for _, msg := range env.messages[:2] {
+		raw, err := env.blobStore.Read(ctx, msg.BodyPath)
+		require.NoError(t, err)
+		blobSizes += int64(len(raw))
+		copySize = int64(len(raw))
+	}
+	require.NoError(t, env.userRepo.IncrementStorageUsed(ctx, "test@example.com", 10000))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	other := dialIMAP(t, addr)
+	other.mustOK("LOGIN test@example.com testpassword123")
+	other.mustOK("SELECT INBOX")
+
+	// EXAMINE opens the mailbox read-only
+	_, status := c.command("EXAMINE INBOX")
+	assert.Contains(t, status, "OK [READ-ONLY]")
+	_, status = c.command("UID STORE 1 +FLAGS (\\Deleted)")
+	assert.Contains(t, status, "NO [READ-ONLY]")
+	_, status = c.command("EXPUNGE")
+	assert.Contains(t, status, "NO [READ-ONLY]")
+
+	_, status = c.command("SELECT INBOX")
+	assert.Contains(t, status, "OK [READ-WRITE]")
+
+	// msg-2 is copied, so its blob must survive the expunge
+	c.mustOK("CREATE Archive")
+	c.mustOK("UID COPY 2 Archive")
+	c.mustOK("UID STORE 1:2 +FLAGS (\\Deleted)")
+
+	untagged := c.mustOK("EXPUNGE")
+	assert.Equal(t, []string{"* 1 EXPUNGE", "* 1 EXPUNGE"}, untagged)
+
+	_, err := env.blobStore.Read(ctx, env.messages[0].BodyPath)
+	assert.Error(t, err, "unreferenced blob should be deleted")
+	_, err = env.blobStore.Read(ctx, env.messages[1].BodyPath)
+	assert.NoError(t, err, "blob referenced by a copy should be kept")
+
+	user, err := env.userRepo.FindByEmail(ctx, "test@example.com")
+	require.NoError(t, err)
+	// The copy of msg-2, stored without a size, is charged the size of its blob
+	assert.Equal(t, 10000+copySize-blobSizes, user.StorageUsed)
+
+	// Other sessions learn about the expunge on their next NOOP
+	untagged = other.mustOK("NOOP")
+	assert.Equal(t, []string{"* 1 EXPUNGE", "* 1 EXPUNGE"}, untagged)
+
+	// UNSELECT leaves \Deleted messages in place
+	c.mustOK("UID STORE 3 +FLAGS (\\Deleted)")
+	c.mustOK("UNSELECT")
+	_, status = c.command("UID FETCH 3 FLAGS")
+	assert.Contains(t, status, "NO")
+
+	untagged = c.mustOK("SELECT INBOX")
+	assert.Contains(t, untagged, "* 1 EXISTS")
+
+	// UID EXPUNGE only removes messages within the UID set
+	untagged = c.mustOK("UID EXPUNGE 1:2")
+	assert.Empty(t, untagged)
+	untagged = c.mustOK("UID EXPUNGE 3")
+	assert.Equal(t, []string{"* 1 EXPUNGE"}, untagged)
+
+	// CLOSE expunges silently; the last reference to msg-2's blob goes with it
+	c.mustOK("SELECT Archive")
+	c.mustOK("UID STORE 1:* +FLAGS (\\Deleted)")
+	untagged = c.mustOK("CLOSE")
+	assert.Empty(t, untagged)
+	_, err = env.blobStore.Read(ctx, env.messages[1].BodyPath)
+	assert.Error(t, err, "blob should be deleted with its last copy")
+
+	untagged = c.mustOK("SELECT Archive")
+	assert.Contains(t, untagged, "* 0 EXISTS")
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multipartTestMessage = "From: Alice Example <alice@example.org>\r\n" +
+	"To: test@example.com, Bob <bob@example.net>\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"Date: Mon, 02 Mar 2026 10:00:00 +0000\r\n" +
+	"Message-ID: <report@example.org>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"XYZ\"\r\n" +
+	"\r\n" +
+	"preamble\r\n" +
+	"--XYZ\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Numbers attached.\r\n" +
+	"--XYZ\r\n" +
+	"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--XYZ--\r\n"
+
+// TestIMAP_Fetch verifies FETCH and UID FETCH return real message data
+func TestIMAP_Fetch(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	ctx := context.Background()
+	receivedAt := time.Date(2026, 3, 2, 10, 0, 5, 0, time.UTC)
+	path, err := env.blobStore.Write(ctx, "msg-multipart", []byte(multipartTestMessage))
+	require.NoError(t, err)
+	require.NoError(t, env.emailRepo.Save(ctx, &domain.Message{
+		ID:         "msg-multipart",
+		Recipient:  "test@example.com",
+		Subject:    "Quarterly report",
+		BodyPath:   path,
+		ReceivedAt: receivedAt,
+	}))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	untagged := c.mustOK("SELECT INBOX")
+	assert.Contains(t, untagged, "* 4 EXISTS")
+
+	t.Run("sequence numbers and sizes", func(t *testing.T) {
+		untagged := c.mustOK("FETCH 1:* (UID RFC822.SIZE)")
+		require.Len(t, untagged, 4)
+		assert.Equal(t, "* 4 FETCH (UID 4 RFC822.SIZE "+strconv.Itoa(len(multipartTestMessage))+")", untagged[3])
+
+		untagged = c.mustOK("FETCH 1,3:* UID")
+		require.Len(t, untagged, 3)
+		assert.True(t, strings.HasPrefix(untagged[0], "* 1 FETCH (UID 1)"))
+		assert.True(t, strings.HasPrefix(untagged[1], "* 3 FETCH (UID 3)"))
+
+		untagged = c.mustOK("UID FETCH * FLAGS")
+		require.Len(t, untagged, 1)
+		assert.Equal(t, "* 4 FETCH (UID 4 FLAGS ())", untagged[0])
+	})
+
+	t.Run("full body and peek", func(t *testing.T) {
+		untagged := c.mustOK("FETCH 4 (BODY.PEEK[])")
+		require.Len(t, untagged, 1)
+		assert.Contains(t, untagged[0], "BODY[] {"+strconv.Itoa(len(multipartTestMessage))+"}\r\n"+multipartTestMessage)
+		assert.NotContains(t, untagged[0], "FLAGS")
+
+		untagged = c.mustOK("FETCH 4 BODY[]<0.4>")
+		require.Len(t, untagged, 1)
+		assert.Equal(t, "* 4 FETCH (BODY[]<0> {4}\r\nFrom FLAGS (\\Seen))", untagged[0])
+
+		untagged = c.mustOK("FETCH 4 FLAGS")
+		assert.Equal(t, "* 4 FETCH (FLAGS (\\Seen))", untagged[0])
+	})
+
+	t.Run("sections", func(t *testing.T) {
+		untagged := c.mustOK("UID FETCH 4 (BODY.PEEK[1] BODY.PEEK[2.MIME] BODY.PEEK[HEADER.FIELDS (Subject)])")
+		require.Len(t, untagged, 1)
+		assert.Contains(t, untagged[0], "BODY[1] {17}\r\nNumbers attached.")
+		assert.Contains(t, untagged[0], "BODY[2.MIME] {")
+		assert.Contains(t, untagged[0], "Content-Disposition: attachment")
+		assert.Contains(t, untagged[0], "BODY[HEADER.FIELDS (Subject)] {29}\r\nSubject: Quarterly report\r\n\r\n")
+
+		untagged = c.mustOK("FETCH 1 (RFC822.HEADER RFC822.TEXT)")
+		assert.Contains(t, untagged[0], "RFC822.TEXT {17}\r\nTest body content")
+	})
+
+	t.Run("envelope and bodystructure", func(t *testing.T) {
+		untagged := c.mustOK("FETCH 4 (ENVELOPE BODYSTRUCTURE INTERNALDATE)")
+		require.Len(t, untagged, 1)
+		assert.Contains(t, untagged[0], `ENVELOPE ("Mon, 02 Mar 2026 10:00:00 +0000" "Quarterly report" (("Alice Example" NIL "alice" "example.org"))`)
+		assert.Contains(t, untagged[0], `((NIL NIL "test" "example.com")("Bob" NIL "bob" "example.net")) NIL NIL NIL "<report@example.org>")`)
+		assert.Contains(t, untagged[0], `BODYSTRUCTURE (("TEXT" "PLAIN" ("CHARSET" "utf-8") NIL NIL "7BIT" 17 1 NIL NIL NIL NIL)`)
+		assert.Contains(t, untagged[0], `("APPLICATION" "PDF" ("NAME" "report.pdf") NIL NIL "BASE64" 12 NIL ("ATTACHMENT" ("FILENAME" "report.pdf")) NIL NIL) "MIXED" ("BOUNDARY" "XYZ") NIL NIL NIL)`)
+		assert.Contains(t, untagged[0], `INTERNALDATE "`+receivedAt.Local().Format("_2-Jan-2006 15:04:05 -0700")+`"`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, status := c.command("FETCH 0 FLAGS")
+		assert.True(t, strings.HasPrefix(status, "BAD"), status)
+		_, status = c.command("FETCH 1 (BODY[BOGUS])")
+		assert.True(t, strings.HasPrefix(status, "BAD"), status)
+
+		// Sequence numbers beyond the mailbox match nothing
+		untagged := c.mustOK("FETCH 9 FLAGS")
+		assert.Empty(t, untagged)
+	})
+}
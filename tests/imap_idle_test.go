@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idle starts IDLE and waits for the continuation request
+func (c *imapTestClient) idle() {
+	c.tag++
+	if _, err := fmt.Fprintf(c.conn, "T%03d IDLE\r\n", c.tag); err != nil {
+		c.t.Fatalf("IMAP write failed: %v", err)
+	}
+	if line := c.readLine(); !strings.HasPrefix(line, "+") {
+		c.t.Fatalf("IDLE not accepted: %s", line)
+	}
+}
+
+// done ends IDLE and returns the tagged status line
+func (c *imapTestClient) done() string {
+	if _, err := fmt.Fprint(c.conn, "DONE\r\n"); err != nil {
+		c.t.Fatalf("IMAP write failed: %v", err)
+	}
+	tag := fmt.Sprintf("T%03d ", c.tag)
+	for {
+		line := c.readLine()
+		if strings.HasPrefix(line, tag) {
+			return strings.TrimPrefix(line, tag)
+		}
+		c.t.Errorf("Unexpected response after DONE: %s", line)
+	}
+}
+
+// deliver stores a new message in a mailbox of the test user, as local delivery would
+func (e *testEnvironment) deliver(t *testing.T, id, mailbox string) {
+	body := "Subject: " + id + "\r\n\r\nHello"
+	path, err := e.blobStore.Write(t.Context(), id, []byte(body))
+	require.NoError(t, err)
+	require.NoError(t, e.emailRepo.Save(t.Context(), &domain.Message{
+		ID: id, Recipient: "test@example.com", Mailbox: mailbox, Subject: id,
+		BodyPath: path, Size: int64(len(body)), ReceivedAt: time.Now(),
+	}))
+}
+
+// TestIMAP_Idle verifies that IDLE reports flag changes, expunges and new messages made by
+// other sessions, and that NOOP reports flag changes but not the session's own
+func TestIMAP_Idle(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	phone, laptop := dialIMAP(t, addr), dialIMAP(t, addr)
+	for _, c := range []*imapTestClient{phone, laptop} {
+		c.mustOK("LOGIN test@example.com testpassword123")
+		c.mustOK("SELECT INBOX")
+	}
+
+	phone.idle()
+	laptop.mustOK("STORE 1 +FLAGS.SILENT ($Important)")
+	assert.Equal(t, `* 1 FETCH (FLAGS ($Important))`, phone.readLine())
+	laptop.mustOK("STORE 2 +FLAGS.SILENT (\\Deleted)")
+	assert.Equal(t, `* 2 FETCH (FLAGS (\Deleted))`, phone.readLine())
+	laptop.mustOK("EXPUNGE")
+	assert.Equal(t, "* 2 EXPUNGE", phone.readLine())
+	env.deliver(t, "idle-1", "INBOX")
+	assert.Equal(t, "* 3 EXISTS", phone.readLine())
+	// Events of other mailboxes are not reported
+	env.deliver(t, "idle-2", "Archive")
+	assert.Equal(t, "OK IDLE terminated", phone.done())
+
+	// NOOP reports what changed since, except the session's own changes
+	laptop.mustOK("NOOP")
+	laptop.mustOK("STORE 1 -FLAGS.SILENT ($Important)")
+	assert.Empty(t, laptop.mustOK("NOOP"))
+	assert.Equal(t, []string{`* 1 FETCH (FLAGS ())`}, phone.mustOK("NOOP"))
+
+	// With CONDSTORE the mod-sequence is reported as well
+	phone.mustOK("SELECT INBOX (CONDSTORE)")
+	laptop.mustOK("STORE 1 +FLAGS.SILENT (\\Flagged)")
+	untagged := phone.mustOK("NOOP")
+	require.Len(t, untagged, 1)
+	assert.Regexp(t, `^\* 1 FETCH \(FLAGS \(\\Flagged\) MODSEQ \(\d+\)\)$`, untagged[0])
+
+	// IDLE is not allowed before authentication
+	c := dialIMAP(t, addr)
+	_, status := c.command("IDLE")
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+}
+
+// TestIMAP_Notify verifies NOTIFY (RFC 5465) for mailboxes other than the selected one
+func TestIMAP_Notify(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], " NOTIFY ")
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("CREATE Lists/Go")
+	c.mustOK("CREATE Archive")
+
+	_, status := c.command("NOTIFY SET (PERSONAL (MailboxName))")
+	assert.Equal(t, "NO [BADEVENT (MessageNew MessageExpunge FlagChange)] unsupported event MailboxName", status)
+	_, status = c.command("NOTIFY SET (PERSONAL (MessageNew))")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	_, status = c.command("NOTIFY SET (PERSONAL (MessageNew (FLAGS) MessageExpunge))")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+
+	// (STATUS) reports the watched mailboxes right away
+	untagged := c.mustOK("NOTIFY SET (STATUS) (SELECTED (MessageNew (UID BODY.PEEK[HEADER.FIELDS (SUBJECT)]) MessageExpunge)) (SUBTREE Lists (MessageNew MessageExpunge FlagChange)) (MAILBOXES INBOX (MessageNew MessageExpunge))")
+	uidValidity := regexp.MustCompile(`UIDVALIDITY \d+`)
+	for i := range untagged {
+		untagged[i] = uidValidity.ReplaceAllString(untagged[i], "UIDVALIDITY n")
+	}
+	assert.ElementsMatch(t, []string{
+		`* STATUS "INBOX" (MESSAGES 3 UIDNEXT 4 UIDVALIDITY n)`,
+		`* STATUS "Lists" (MESSAGES 0 UIDNEXT 1 UIDVALIDITY n)`,
+		`* STATUS "Lists/Go" (MESSAGES 0 UIDNEXT 1 UIDVALIDITY n)`,
+	}, untagged)
+
+	// Without a selected mailbox the events of the watched ones arrive while idling
+	c.idle()
+	env.deliver(t, "notify-1", "Archive")
+	env.deliver(t, "notify-2", "Lists/Go")
+	assert.Equal(t, `* STATUS "Lists/Go" (MESSAGES 1 UIDNEXT 2 UNSEEN 1)`, c.readLine())
+	assert.Equal(t, "OK IDLE terminated", c.done())
+
+	// Flag changes are only reported where FlagChange was asked for
+	msgs, err := env.emailRepo.FindInMailbox(t.Context(), "test@example.com", "INBOX", domain.MailboxFilter{})
+	require.NoError(t, err)
+	require.NoError(t, env.emailRepo.AddFlags(t.Context(), msgs[0].ID, "$Important"))
+	assert.Empty(t, c.mustOK("NOOP"))
+	list, err := env.emailRepo.FindInMailbox(t.Context(), "test@example.com", "Lists/Go", domain.MailboxFilter{})
+	require.NoError(t, err)
+	require.NoError(t, env.emailRepo.UpdateReadState(t.Context(), list[0].ID, true))
+	assert.Equal(t, []string{`* STATUS "Lists/Go" (UNSEEN 0)`}, c.mustOK("NOOP"))
+
+	// The selected mailbox gets the data items asked for with MessageNew
+	c.mustOK("SELECT Lists/Go")
+	c.idle()
+	env.deliver(t, "notify-3", "Lists/Go")
+	assert.Equal(t, "* 2 EXISTS", c.readLine())
+	assert.Equal(t, "* 2 FETCH (UID 2 BODY[HEADER.FIELDS (SUBJECT)] {21}\r\nSubject: notify-3\r\n\r\n)", c.readLine())
+	env.deliver(t, "notify-4", "INBOX")
+	assert.Equal(t, `* STATUS "INBOX" (MESSAGES 4 UIDNEXT 5 UNSEEN 4)`, c.readLine())
+	assert.Equal(t, "OK IDLE terminated", c.done())
+
+	c.mustOK("NOTIFY NONE")
+	env.deliver(t, "notify-5", "INBOX")
+	assert.Empty(t, c.mustOK("NOOP"))
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_MailboxManagement verifies hierarchical CREATE, LIST-EXTENDED, LSUB, STATUS,
+// RENAME and DELETE
+func TestIMAP_MailboxManagement(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+
+	// Superior names are created as needed
+	c.mustOK("CREATE Projects/2024/Q1")
+	c.mustOK("CREATE Sent (USE (\\Sent))")
+	_, status := c.command("CREATE Everything (USE (\\All))")
+	assert.Contains(t, status, "NO [USEATTR]")
+	_, status = c.command("CREATE Sent")
+	assert.Contains(t, status, "NO [ALREADYEXISTS]")
+
+	assert.Equal(t, []string{`* LIST (\Noselect) "/" ""`}, c.mustOK(`LIST "" ""`))
+	assert.Equal(t, []string{
+		`* LIST (\HasNoChildren) "/" "INBOX"`,
+		`* LIST (\HasChildren) "/" "Projects"`,
+		`* LIST (\HasChildren) "/" "Projects/2024"`,
+		`* LIST (\HasNoChildren) "/" "Projects/2024/Q1"`,
+		`* LIST (\HasNoChildren \Sent) "/" "Sent"`,
+	}, c.mustOK(`LIST "" "*"`))
+	assert.Equal(t, []string{`* LIST (\HasChildren) "/" "Projects/2024"`}, c.mustOK(`LIST "Projects/" "%%"`))
+	assert.Equal(t, []string{`* LIST (\HasNoChildren \Sent) "/" "Sent"`}, c.mustOK(`LIST (SPECIAL-USE) "" "*"`))
+
+	// Subscriptions
+	c.mustOK("SUBSCRIBE Projects/2024/Q1")
+	assert.Equal(t, []string{`* LSUB () "/" "Projects/2024/Q1"`}, c.mustOK(`LSUB "" "*"`))
+	assert.Equal(t, []string{`* LSUB (\Noselect) "/" "Projects/2024"`}, c.mustOK(`LSUB "Projects/" "%%"`))
+	assert.Equal(t, []string{`* LIST (\HasChildren) "/" "Projects" ("CHILDINFO" ("SUBSCRIBED"))`},
+		c.mustOK(`LIST (SUBSCRIBED RECURSIVEMATCH) "" "%%"`))
+	assert.Equal(t, []string{`* LIST (\HasNoChildren \Subscribed) "/" "Projects/2024/Q1"`},
+		c.mustOK(`LIST "" "Projects/2024/%%" RETURN (SUBSCRIBED)`))
+
+	// STATUS, and LIST-STATUS for every selectable mailbox
+	assert.Equal(t, []string{`* STATUS "INBOX" (MESSAGES 3 UIDNEXT 4 UNSEEN 3)`}, c.mustOK("STATUS INBOX (MESSAGES UIDNEXT UNSEEN)"))
+	assert.Equal(t, []string{
+		`* LIST (\HasNoChildren) "/" "INBOX"`,
+		`* STATUS "INBOX" (MESSAGES 3)`,
+		`* LIST (\HasNoChildren \Sent) "/" "Sent"`,
+		`* STATUS "Sent" (MESSAGES 0)`,
+	}, c.mustOK(`LIST "" (INBOX Sent) RETURN (STATUS (MESSAGES))`))
+
+	c.mustOK("SELECT INBOX")
+	c.mustOK("UID MOVE 1 Projects/2024/Q1")
+	validity := responseNumber(t, c.mustOK("STATUS Projects/2024/Q1 (UIDVALIDITY)"), "UIDVALIDITY ")
+
+	// RENAME moves the whole subtree, keeping messages and UIDVALIDITY
+	_, status = c.command("RENAME Projects Projects/Old")
+	assert.Contains(t, status, "NO")
+	_, status = c.command("RENAME Projects Sent")
+	assert.Contains(t, status, "NO [ALREADYEXISTS]")
+	c.mustOK("RENAME Projects Work")
+	assert.Equal(t, []string{
+		`* LIST (\HasChildren) "/" "Work"`,
+		`* LIST (\HasChildren) "/" "Work/2024"`,
+		`* LIST (\HasNoChildren) "/" "Work/2024/Q1"`,
+	}, c.mustOK(`LIST "" "Work*"`))
+	assert.Empty(t, c.mustOK(`LIST "" "Projects*"`))
+	assert.Equal(t, []string{fmt.Sprintf(`* STATUS "Work/2024/Q1" (MESSAGES 1 UIDVALIDITY %d)`, validity)},
+		c.mustOK("STATUS Work/2024/Q1 (MESSAGES UIDVALIDITY)"))
+
+	// DELETE refuses mailboxes with inferiors and INBOX
+	_, status = c.command("DELETE Work")
+	assert.Contains(t, status, "NO [HASCHILDREN]")
+	_, status = c.command("DELETE INBOX")
+	assert.Contains(t, status, "NO")
+	c.mustOK("DELETE Work/2024/Q1")
+	_, status = c.command("STATUS Work/2024/Q1 (MESSAGES)")
+	assert.Contains(t, status, "NO [NONEXISTENT]")
+
+	// Renaming INBOX moves its messages and leaves it empty
+	c.mustOK("RENAME INBOX Old")
+	assert.Equal(t, []string{`* STATUS "INBOX" (MESSAGES 0)`}, c.mustOK("STATUS INBOX (MESSAGES)"))
+	untagged := c.mustOK("STATUS Old (MESSAGES)")
+	require.Len(t, untagged, 1)
+	assert.Equal(t, `* STATUS "Old" (MESSAGES 2)`, untagged[0])
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIMAP_ID verifies the server identifies itself to clients, before and after login
+func TestIMAP_ID(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], " ID ")
+
+	want := []string{`* ID ("name" "MailRaven" "vendor" "MailRaven" "version" "` + config.Version + `")`}
+	assert.Equal(t, want, c.mustOK(`ID ("name" "Thunderbird" "version" "128.0" "os" NIL)`))
+	c.mustOK("LOGIN test@example.com testpassword123")
+	assert.Equal(t, want, c.mustOK("ID NIL"))
+
+	_, status := c.command(`ID ("name")`)
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+}
+
+// TestIMAP_UTF8Accept verifies mailbox names are exchanged in modified UTF-7 until the
+// client enables UTF8=ACCEPT
+func TestIMAP_UTF8Accept(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], " UTF8=ACCEPT")
+	c.mustOK("LOGIN test@example.com testpassword123")
+
+	c.mustOK(`CREATE "&AOk-t&AOk-"`)
+	assert.Contains(t, strings.Join(c.mustOK(`LIST "" "*"`), "\n"), `"/" "&AOk-t&AOk-"`)
+	assert.Contains(t, c.mustOK(`STATUS "&AOk-t&AOk-" (MESSAGES)`)[0], `* STATUS "&AOk-t&AOk-" (MESSAGES 0)`)
+
+	assert.Equal(t, []string{"* ENABLED UTF8=ACCEPT"}, c.mustOK("ENABLE UTF8=ACCEPT"))
+	assert.Contains(t, strings.Join(c.mustOK(`LIST "" "*"`), "\n"), `"/" "été"`)
+	c.mustOK(`CREATE "été/日本語"`)
+	assert.Contains(t, strings.Join(c.mustOK(`LIST "" "été/%%"`), "\n"), `"/" "été/日本語"`)
+	c.mustOK(`SELECT "été"`)
+
+	// Another session sees the same mailbox in modified UTF-7
+	other := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	other.mustOK("LOGIN test@example.com testpassword123")
+	assert.Contains(t, strings.Join(other.mustOK(`LIST "" "&AOk-t&AOk-/*"`), "\n"), `"/" "&AOk-t&AOk-/&ZeVnLIqe-"`)
+}
+
+// TestIMAP_Metadata verifies server and mailbox annotations (RFC 5464) are stored and
+// reported according to the GETMETADATA options
+func TestIMAP_Metadata(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], " METADATA ")
+	c.mustOK("LOGIN test@example.com testpassword123")
+
+	c.mustOK(`SETMETADATA INBOX (/private/comment "My inbox" /shared/vendor/example/color "#ff0000")`)
+	c.mustOK("SETMETADATA INBOX (/shared/vendor/example/note {11+}\r\nA long note)")
+	c.mustOK(`SETMETADATA "" (/private/vendor/example/push-token "abc123")`)
+
+	for _, tt := range []struct{ command, want string }{
+		{"GETMETADATA INBOX /private/comment", `* METADATA "INBOX" (/private/comment "My inbox")`},
+		{"GETMETADATA inbox (/Private/Comment /shared/missing)", `* METADATA "INBOX" (/private/comment "My inbox" /shared/missing NIL)`},
+		{"GETMETADATA (DEPTH 1) INBOX /shared/vendor/example", `* METADATA "INBOX" (/shared/vendor/example/color "#ff0000" /shared/vendor/example/note "A long note")`},
+		{"GETMETADATA (DEPTH 1) INBOX /shared/vendor", `* METADATA "INBOX" (/shared/vendor NIL)`},
+		{`GETMETADATA "" /private/vendor/example/push-token`, `* METADATA "" (/private/vendor/example/push-token "abc123")`},
+	} {
+		assert.Equal(t, []string{tt.want}, c.mustOK("%s", tt.command), tt.command)
+	}
+
+	untagged, status := c.command("GETMETADATA (MAXSIZE 8 DEPTH infinity) INBOX /shared")
+	assert.Equal(t, []string{`* METADATA "INBOX" (/shared/vendor/example/color "#ff0000")`}, untagged)
+	assert.Equal(t, "OK [METADATA LONGENTRIES 11] GETMETADATA completed", status)
+
+	// NIL removes an entry, for every session of the user
+	other := dialIMAP(t, addr)
+	other.mustOK("LOGIN test@example.com testpassword123")
+	other.mustOK(`SETMETADATA INBOX (/private/comment NIL)`)
+	assert.Equal(t, []string{`* METADATA "INBOX" (/private/comment NIL)`}, c.mustOK("GETMETADATA INBOX /private/comment"))
+
+	// Server annotations are only writable by administrators
+	_, status = c.command(`SETMETADATA "" (/shared/comment "Hello")`)
+	assert.Equal(t, "NO [PERMISSION DENIED] Access denied", status)
+
+	_, status = c.command(`SETMETADATA Missing (/private/comment "x")`)
+	assert.Equal(t, "NO [NONEXISTENT] Mailbox does not exist", status)
+	_, status = c.command(`GETMETADATA INBOX /comment`)
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	_, status = c.command(`GETMETADATA (DEPTH 2) INBOX /private/comment`)
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_MoveAndUIDPlus verifies MOVE, UID MOVE and the UIDPLUS response codes
+func TestIMAP_MoveAndUIDPlus(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("CREATE Archive")
+
+	archive, err := env.emailRepo.GetMailbox(t.Context(), "test@example.com", "Archive")
+	require.NoError(t, err)
+	validity := archive.UIDValidity
+
+	// APPEND reports the assigned UID
+	body := "Subject: Appended\r\n\r\nHello"
+	_, err = fmt.Fprintf(c.conn, "A001 APPEND Archive {%d}\r\n", len(body))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(c.readLine(), "+"))
+	_, err = fmt.Fprintf(c.conn, "%s\r\n", body)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("A001 OK [APPENDUID %d 1] APPEND completed", validity), c.readLine())
+
+	c.mustOK("SELECT INBOX")
+
+	// COPY reports the source and destination UIDs in the tagged response
+	_, status := c.command("COPY 1 Archive")
+	assert.Equal(t, fmt.Sprintf("OK [COPYUID %d 1 2] COPY completed", validity), status)
+
+	_, status = c.command("UID COPY 1 Missing")
+	assert.Contains(t, status, "NO [TRYCREATE]")
+
+	// MOVE sends COPYUID in an untagged OK, then expunges the source messages
+	untagged := c.mustOK("MOVE 2:3 Archive")
+	assert.Equal(t, []string{
+		fmt.Sprintf("* OK [COPYUID %d 2:3 3:4] Moved", validity),
+		"* 2 EXPUNGE",
+		"* 2 EXPUNGE",
+	}, untagged)
+
+	untagged = c.mustOK("UID MOVE 1 Archive")
+	assert.Equal(t, []string{
+		fmt.Sprintf("* OK [COPYUID %d 1 5] Moved", validity),
+		"* 1 EXPUNGE",
+	}, untagged)
+
+	untagged = c.mustOK("SELECT INBOX")
+	assert.Contains(t, untagged, "* 0 EXISTS")
+
+	untagged = c.mustOK("SELECT Archive")
+	assert.Contains(t, untagged, "* 5 EXISTS")
+
+	// The moved messages keep their content
+	untagged = c.mustOK("UID FETCH 3:5 (BODY.PEEK[HEADER.FIELDS (SUBJECT)])")
+	require.Len(t, untagged, 3)
+	assert.Contains(t, untagged[0], "Subject: Test Message 2")
+	assert.Contains(t, untagged[2], "Subject: Test Message 1")
+
+	// A read-only mailbox cannot be moved from
+	c.mustOK("EXAMINE Archive")
+	_, status = c.command("MOVE 1 INBOX")
+	assert.Contains(t, status, "NO [READ-ONLY]")
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestIMAP_QuotaRoots verifies message and mailbox limits on account, mailbox and domain
+// quota roots, set over IMAP and the admin API and enforced by APPEND, COPY, CREATE and
+// SMTP delivery
+func TestIMAP_QuotaRoots(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	require.NoError(t, env.userRepo.Create(ctx, &domain.User{
+		Email: "admin@example.com", PasswordHash: string(hash), Role: domain.RoleAdmin, CreatedAt: time.Now(),
+	}))
+	adminToken := env.authenticateUser(t, "admin@example.com", "secret123")
+	request := func(method, path string, body interface{}, v interface{}) int {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := env.newRequest(t, method, path, &buf, adminToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp := env.doRequest(t, req)
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			env.decodeJSON(t, resp.Body, v)
+		}
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusCreated, request("POST", "/api/v1/admin/domains", map[string]string{"name": "example.com"}, nil))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	capabilities := c.mustOK("CAPABILITY")[0]
+	for _, capability := range []string{"QUOTA", "QUOTA=RES-STORAGE", "QUOTA=RES-MESSAGE", "QUOTA=RES-MAILBOX", "STATUS=SIZE"} {
+		assert.Contains(t, capabilities, " "+capability+" ")
+	}
+	c.mustOK("CREATE Junk")
+
+	// Only administrators set limits
+	_, status := c.command(`SETQUOTA "" (STORAGE 10)`)
+	assert.Equal(t, "NO [PERMFAIL] Permission denied", status)
+
+	// Cap Junk at one message through the admin API
+	var junk domain.Quota
+	require.Equal(t, http.StatusOK, request("PUT", "/api/v1/admin/users/test@example.com/mailboxes/Junk/quota",
+		map[string]int64{"messages": 1}, &junk))
+	assert.Equal(t, map[string]int64{domain.QuotaMessage: 1}, junk.Limits)
+	assert.Equal(t, http.StatusBadRequest, request("PUT", "/api/v1/admin/users/test@example.com/mailboxes/Junk/quota",
+		map[string]int64{"mailboxes": 1}, nil))
+	assert.Equal(t, http.StatusNotFound, request("PUT", "/api/v1/admin/users/test@example.com/mailboxes/Missing/quota",
+		map[string]int64{"messages": 1}, nil))
+
+	untagged := c.mustOK("GETQUOTAROOT Junk")
+	require.Len(t, untagged, 3)
+	assert.Equal(t, `* QUOTAROOT "Junk" "" "Junk"`, untagged[0])
+	assert.Equal(t, `* QUOTA "Junk" (MESSAGE 0 1)`, untagged[2])
+	untagged = c.mustOK("GETQUOTAROOT INBOX")
+	assert.Equal(t, `* QUOTAROOT "INBOX" ""`, untagged[0])
+	_, status = c.command(`GETQUOTA "@other.test"`)
+	assert.Equal(t, "NO Quota root does not exist", status)
+
+	// APPEND fills Junk, then APPEND and COPY are refused
+	message := "Subject: Spam\r\n\r\nBuy now"
+	c.mustOK("APPEND Junk {%d+}\r\n%s", len(message), message)
+	_, status = c.command("APPEND Junk {%d+}\r\n%s", len(message), message)
+	assert.Equal(t, "NO [OVERQUOTA] Message limit exceeded", status)
+	c.mustOK("SELECT INBOX")
+	_, status = c.command("COPY 1 Junk")
+	assert.Equal(t, "NO [OVERQUOTA] Message limit exceeded", status)
+
+	// DELETED-STORAGE counts the messages marked \Deleted
+	c.mustOK("SELECT Junk")
+	c.mustOK(`STORE 1 +FLAGS (\Deleted)`)
+	untagged = c.mustOK("STATUS Junk (MESSAGES DELETED-STORAGE)")
+	assert.Equal(t, fmt.Sprintf(`* STATUS "Junk" (MESSAGES 1 DELETED-STORAGE %d)`, len(message)), untagged[0])
+
+	// An administrator limits the account to the mailboxes it has
+	var quotas []domain.Quota
+	require.Equal(t, http.StatusOK, request("GET", "/api/v1/admin/users/test@example.com/quota", nil, &quotas))
+	require.Len(t, quotas, 2)
+	mailboxes := quotas[0].Usage[domain.QuotaMailbox]
+	assert.Equal(t, domain.QuotaRoot{Owner: "test@example.com", Mailbox: "Junk"}, quotas[1].Root)
+
+	admin := dialIMAP(t, addr)
+	admin.mustOK("LOGIN admin@example.com secret123")
+	untagged = admin.mustOK("SETQUOTA test@example.com (MAILBOX %d)", mailboxes)
+	assert.Equal(t, []string{fmt.Sprintf(`* QUOTA "test@example.com" (MAILBOX %d %d)`, mailboxes, mailboxes)}, untagged)
+	_, status = admin.command(`SETQUOTA "Junk" (MAILBOX 1)`)
+	assert.Equal(t, "NO Quota root does not exist", status, "admin has no Junk of its own")
+
+	_, status = c.command("CREATE Archive/2026")
+	assert.Equal(t, "NO [OVERQUOTA] Mailbox limit exceeded", status)
+	untagged = c.mustOK(`GETQUOTA ""`)
+	assert.Equal(t, []string{fmt.Sprintf(`* QUOTA "" (MAILBOX %d %d)`, mailboxes, mailboxes)}, untagged)
+
+	// A domain root caps every account of the domain together
+	var domainQuota domain.Quota
+	require.Equal(t, http.StatusOK, request("GET", "/api/v1/admin/domains/example.com/quota", nil, &domainQuota))
+	messages := domainQuota.Usage[domain.QuotaMessage]
+	require.Greater(t, messages, int64(0))
+	require.Equal(t, http.StatusOK, request("PUT", "/api/v1/admin/domains/Example.com/quota",
+		map[string]int64{"messages": messages}, &domainQuota))
+	assert.Equal(t, map[string]int64{domain.QuotaMessage: messages}, domainQuota.Limits)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/api/v1/admin/domains/other.test/quota", nil, nil))
+
+	untagged = c.mustOK(`GETQUOTA "@example.com"`)
+	assert.Equal(t, []string{fmt.Sprintf(`* QUOTA "@example.com" (MESSAGE %d %d)`, messages, messages)}, untagged)
+
+	logger := observability.NewLogger("error", "text")
+	sieveEngine := sieve.NewSieveEngine(sqlite.NewSqliteScriptRepository(env.conn.DB), env.emailRepo,
+		sqlite.NewSqliteVacationRepository(env.conn.DB), env.queueRepo, env.blobStore)
+	handler := smtp.NewHandler(env.emailRepo, env.userRepo, env.blobStore, sqlite.NewSearchRepository(env.conn.DB),
+		sieveEngine, logger, observability.NewMetrics())
+	err := handler.Handle(&domain.SMTPSession{
+		SessionID:  "quota",
+		RemoteIP:   "127.0.0.1",
+		Sender:     "sender@localhost",
+		Recipients: []string{"test@example.com"},
+	}, []byte("From: sender@localhost\r\nTo: test@example.com\r\nSubject: Full\r\nMessage-ID: <quota@localhost>\r\n\r\nHello\r\n"))
+	assert.True(t, errors.Is(err, smtp.ErrQuotaExceeded), "delivery over the domain limit: %v", err)
+
+	// Lifting the limit lets delivery through again
+	require.Equal(t, http.StatusOK, request("PUT", "/api/v1/admin/domains/example.com/quota",
+		map[string]int64{"messages": 0}, nil))
+	_, status = c.command(`GETQUOTA "@example.com"`)
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+}
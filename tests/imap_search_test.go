@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_Search verifies SEARCH, UID SEARCH and ESEARCH result options
+func TestIMAP_Search(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	ctx := context.Background()
+	path, err := env.blobStore.Write(ctx, "msg-multipart", []byte(multipartTestMessage))
+	require.NoError(t, err)
+	report := &domain.Message{
+		ID:         "msg-multipart",
+		Sender:     "alice@example.org",
+		Recipient:  "test@example.com",
+		Subject:    "Quarterly report",
+		BodyPath:   path,
+		Size:       int64(len(multipartTestMessage)),
+		ReceivedAt: time.Now(),
+	}
+	require.NoError(t, env.emailRepo.Save(ctx, report))
+
+	// Index bodies as the SMTP handler does on delivery
+	for _, msg := range env.messages {
+		require.NoError(t, env.searchIdx.Index(ctx, msg, "Test body content"))
+	}
+	require.NoError(t, env.searchIdx.Index(ctx, report, "Numbers attached."))
+
+	require.NoError(t, env.emailRepo.AddFlags(ctx, "msg-1", "\\Seen"))
+	require.NoError(t, env.emailRepo.AddFlags(ctx, "msg-2", "\\Flagged", "$Important"))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+	c := dialIMAP(t, addr)
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("SELECT INBOX")
+
+	search := func(criteria string) string {
+		untagged := c.mustOK("SEARCH %s", criteria)
+		require.Len(t, untagged, 1)
+		return untagged[0]
+	}
+
+	assert.Equal(t, "* SEARCH 1 2 3 4", search("ALL"))
+	assert.Equal(t, "* SEARCH 2 3 4", search("UNSEEN"))
+	assert.Equal(t, "* SEARCH 1 2", search("OR SEEN FLAGGED"))
+	assert.Equal(t, "* SEARCH 3 4", search("NOT (SEEN) NOT FLAGGED"))
+	assert.Equal(t, "* SEARCH 2", search("KEYWORD $Important"))
+	assert.Equal(t, "* SEARCH 2 4", search("2,4:*"))
+	assert.Equal(t, "* SEARCH 3", search("UID 3"))
+	assert.Equal(t, "* SEARCH 4", search("FROM alice"))
+	assert.Equal(t, "* SEARCH 4", search(`CHARSET UTF-8 SUBJECT "quarterly report"`))
+	assert.Equal(t, "* SEARCH 4", search("HEADER Message-ID report@example.org"))
+	assert.Equal(t, "* SEARCH 4", search("BODY numbers"))
+	assert.Equal(t, "* SEARCH 1 2 3", search("TEXT test"))
+	assert.Equal(t, "* SEARCH 4", search("LARGER 200"))
+	assert.Equal(t, "* SEARCH 1 2 3", search("SMALLER 200"))
+	assert.Equal(t, "* SEARCH 4", search("SENTON 2-Mar-2026"))
+	assert.Equal(t, "* SEARCH 1 2 3 4", search("SINCE "+time.Now().AddDate(0, 0, -1).Format("2-Jan-2006")))
+	assert.Equal(t, "* SEARCH", search("BEFORE 1-Jan-2000"))
+
+	untagged := c.mustOK("UID SEARCH UNSEEN")
+	assert.Equal(t, []string{"* SEARCH 2 3 4"}, untagged)
+
+	untagged = c.mustOK("SEARCH RETURN (MIN MAX COUNT) UNSEEN")
+	require.Len(t, untagged, 1)
+	assert.True(t, strings.HasPrefix(untagged[0], `* ESEARCH (TAG "`), untagged[0])
+	assert.True(t, strings.HasSuffix(untagged[0], `") MIN 2 MAX 4 COUNT 3`), untagged[0])
+
+	untagged = c.mustOK("UID SEARCH RETURN () 1:2,4")
+	assert.True(t, strings.HasSuffix(untagged[0], `") UID ALL 1:2,4`), untagged[0])
+
+	untagged = c.mustOK("SEARCH RETURN (COUNT ALL) DELETED")
+	assert.True(t, strings.HasSuffix(untagged[0], `") COUNT 0`), untagged[0])
+
+	// Body matches in other mailboxes are left out of the index lookup
+	archived := &domain.Message{
+		ID:         "msg-archived",
+		Sender:     "alice@example.org",
+		Recipient:  "test@example.com",
+		Subject:    "Archived",
+		Mailbox:    "Archive",
+		BodyPath:   path,
+		ReceivedAt: time.Now(),
+	}
+	require.NoError(t, env.emailRepo.Save(ctx, archived))
+	require.NoError(t, env.searchIdx.Index(ctx, archived, "Test body content"))
+	ids, err := env.searchIdx.SearchMailbox(ctx, "test@example.com", "INBOX", "test")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"msg-1", "msg-2", "msg-3"}, ids)
+	assert.Equal(t, "* SEARCH 1 2 3", search("TEXT test"))
+
+	_, status := c.command("SEARCH CHARSET KOI8-R ALL")
+	assert.True(t, strings.HasPrefix(status, "NO [BADCHARSET"), status)
+	_, status = c.command("SEARCH BOGUS")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	_, status = c.command("SEARCH OR SEEN")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestIMAP_SharedMailboxes verifies NAMESPACE and access to other users' and shared role
+// accounts' mailboxes under their ACLs
+func TestIMAP_SharedMailboxes(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	for _, user := range []struct {
+		email string
+		role  domain.Role
+	}{{"admin@example.com", domain.RoleAdmin}, {"alice@example.com", domain.RoleUser}} {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+		require.NoError(t, env.userRepo.Create(ctx, &domain.User{
+			Email: user.email, PasswordHash: string(hash), Role: user.role, CreatedAt: time.Now(),
+		}))
+	}
+	adminToken := env.authenticateUser(t, "admin@example.com", "secret123")
+	post := func(path string, body interface{}) int {
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		req := env.newRequest(t, "POST", path, &buf, adminToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp := env.doRequest(t, req)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusCreated, post("/api/v1/admin/domains", map[string]string{"name": "example.com"}))
+
+	// A role account whose folders alice may read and change
+	require.Equal(t, http.StatusCreated, post("/api/v1/admin/shared-mailboxes", map[string]interface{}{
+		"email":   "support@example.com",
+		"members": map[string]string{"alice@example.com": "lrswite"},
+	}))
+	body := "Subject: Help\r\n\r\nPlease help"
+	path, err := env.blobStore.Write(ctx, "support-1", []byte(body))
+	require.NoError(t, err)
+	require.NoError(t, env.emailRepo.Save(ctx, &domain.Message{
+		ID: "support-1", Recipient: "support@example.com", Mailbox: "INBOX", Subject: "Help",
+		BodyPath: path, Size: int64(len(body)), ReceivedAt: time.Now(),
+	}))
+
+	addr := env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true})
+
+	// The role account can't log in itself
+	c := dialIMAP(t, addr)
+	_, status := c.command(`LOGIN support@example.com ""`)
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+
+	// The owner shares INBOX read-only
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("SELECT INBOX")
+	c.mustOK("SETACL INBOX alice@example.com lr")
+	untagged := c.mustOK("GETACL INBOX")
+	assert.Equal(t, []string{`* ACL "INBOX" alice@example.com lr`}, untagged)
+
+	c = dialIMAP(t, addr)
+	c.mustOK("LOGIN alice@example.com secret123")
+	assert.Contains(t, c.mustOK("CAPABILITY")[0], " NAMESPACE ")
+	untagged = c.mustOK("NAMESPACE")
+	assert.Equal(t, []string{`* NAMESPACE (("" "/")) (("Other Users/" "/")) (("Shared/" "/"))`}, untagged)
+
+	untagged = c.mustOK(`LIST "" "*"`)
+	assert.Contains(t, untagged, `* LIST (\HasNoChildren) "/" "Other Users/test/INBOX"`)
+	assert.Contains(t, untagged, `* LIST (\HasNoChildren \Sent) "/" "Shared/support/Sent"`)
+	assert.Contains(t, untagged, `* LIST (\Noselect \HasChildren) "/" "Shared"`)
+	for _, line := range untagged {
+		assert.NotContains(t, line, "Other Users/test/Archive", "unshared mailboxes are not listed")
+	}
+	untagged = c.mustOK(`LIST "Other Users/" "%%"`)
+	assert.Equal(t, []string{`* LIST (\Noselect \HasChildren) "/" "Other Users/test"`}, untagged)
+
+	// Read-only access to the other user's INBOX
+	_, status = c.command(`SELECT "Other Users/test/INBOX"`)
+	assert.Equal(t, "OK [READ-ONLY] SELECT completed", status)
+	untagged = c.mustOK("FETCH 1:* (BODY.PEEK[HEADER.FIELDS (SUBJECT)])")
+	assert.Len(t, untagged, 3)
+	assert.Contains(t, untagged[0], "Subject: Test Message 1")
+	_, status = c.command(`MYRIGHTS "Other Users/test/INBOX"`)
+	assert.Equal(t, "OK MyRights completed", status)
+	_, status = c.command(`SETACL "Other Users/test/INBOX" alice@example.com lrswi`)
+	assert.True(t, strings.HasPrefix(status, "NO [PERMISSION DENIED]"), status)
+	_, status = c.command(`SELECT "Other Users/test/Archive"`)
+	assert.True(t, strings.HasPrefix(status, "NO [NONEXISTENT]"), status)
+
+	// Read-write access to the role account
+	untagged = c.mustOK(`STATUS "Shared/support/INBOX" (MESSAGES UNSEEN)`)
+	assert.Equal(t, []string{`* STATUS "Shared/support/INBOX" (MESSAGES 1 UNSEEN 1)`}, untagged)
+	untagged = c.mustOK(`MYRIGHTS "Shared/support/INBOX"`)
+	assert.Equal(t, []string{`* MYRIGHTS "Shared/support/INBOX" lrswite`}, untagged)
+	_, status = c.command(`SELECT "Shared/support/INBOX"`)
+	assert.Equal(t, "OK [READ-WRITE] SELECT completed", status)
+	untagged = c.mustOK(`STORE 1 +FLAGS (\Seen)`)
+	assert.Equal(t, []string{`* 1 FETCH (FLAGS (\Seen))`}, untagged)
+
+	// Copies into the user's own mailboxes belong to the user
+	_, status = c.command("COPY 1 INBOX")
+	assert.True(t, strings.HasPrefix(status, "OK [COPYUID "), status)
+	_, status = c.command("MOVE 1 INBOX")
+	assert.True(t, strings.HasPrefix(status, "NO [CANNOT]"), status)
+	untagged = c.mustOK("STATUS INBOX (MESSAGES)")
+	assert.Equal(t, []string{`* STATUS "INBOX" (MESSAGES 1)`}, untagged)
+
+	msg := "Subject: Reply\r\n\r\nDone"
+	_, status = c.command(`APPEND "Shared/support/Sent" {%d+}`+"\r\n%s", len(msg), msg)
+	assert.True(t, strings.HasPrefix(status, "OK [APPENDUID "), status)
+	sent, err := env.emailRepo.FindInMailbox(ctx, "support@example.com", "Sent", domain.MailboxFilter{})
+	require.NoError(t, err)
+	assert.Len(t, sent, 1)
+
+	_, status = c.command(`CREATE "Shared/support/Projects"`)
+	assert.True(t, strings.HasPrefix(status, "NO [NOPERM]"), status)
+	_, status = c.command(`SUBSCRIBE "Shared/support/INBOX"`)
+	assert.True(t, strings.HasPrefix(status, "NO"), status)
+}
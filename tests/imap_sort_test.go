@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIMAP_SortThread verifies SORT and THREAD (RFC 5256) on appended messages, whose
+// Date, In-Reply-To and References headers are stored on delivery
+func TestIMAP_SortThread(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	c := dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{AllowInsecureAuth: true}))
+	caps := c.mustOK("CAPABILITY")[0]
+	for _, capability := range []string{" SORT ", " THREAD=ORDEREDSUBJECT ", " THREAD=REFERENCES "} {
+		assert.Contains(t, caps, capability)
+	}
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("CREATE Threads")
+
+	// Internal dates run opposite to the Date headers
+	dates := []string{"05-Mar-2026 10:00:00 +0000", "04-Mar-2026 10:00:00 +0000", "03-Mar-2026 10:00:00 +0000", "02-Mar-2026 10:00:00 +0000"}
+	for i, msg := range []string{
+		"From: Zoe <zoe@example.com>\r\nTo: bob@example.com\r\nSubject: Plans\r\nDate: Mon, 2 Mar 2026 10:00:00 +0000\r\nMessage-ID: <1@example.com>\r\n\r\nShall we?",
+		"From: adam@example.com\r\nTo: zoe@example.com\r\nSubject: Lunch\r\nDate: Sun, 1 Mar 2026 12:00:00 +0000\r\nMessage-ID: <2@example.com>\r\n\r\nHungry",
+		"From: bob@example.com\r\nTo: Zoe <zoe@example.com>\r\nSubject: Re: Plans\r\nDate: Tue, 3 Mar 2026 09:00:00 +0000\r\nMessage-ID: <3@example.com>\r\nIn-Reply-To: <1@example.com>\r\n\r\nSure, with a longer body",
+		"From: Carol <carol@example.com>\r\nTo: zoe@example.com\r\nSubject: Re: [team] Plans\r\nDate: Wed, 4 Mar 2026 08:00:00 +0000\r\nMessage-ID: <4@example.com>\r\nReferences: <1@example.com>\r\n <3@example.com>\r\n\r\nMe too",
+	} {
+		_, status := c.command(`APPEND Threads "%s" {%d+}`+"\r\n%s", dates[i], len(msg), msg)
+		assert.True(t, strings.HasPrefix(status, "OK"), status)
+	}
+	c.mustOK("SELECT Threads")
+
+	for _, tt := range []struct{ command, want string }{
+		{"SORT (DATE) UTF-8 ALL", "* SORT 2 1 3 4"},
+		{"SORT (REVERSE DATE) UTF-8 ALL", "* SORT 4 3 1 2"},
+		{"SORT (ARRIVAL) UTF-8 ALL", "* SORT 4 3 2 1"},
+		{"SORT (SUBJECT DATE) UTF-8 ALL", "* SORT 2 1 3 4"},
+		{"SORT (FROM) UTF-8 ALL", "* SORT 2 3 4 1"},
+		{"SORT (TO REVERSE SIZE) US-ASCII ALL", "* SORT 1 4 3 2"},
+		{"SORT (SIZE) UTF-8 SUBJECT plans", "* SORT 1 3 4"},
+		{"UID SORT (DATE) UTF-8 UID 2:*", "* SORT 2 3 4"},
+		{"THREAD ORDEREDSUBJECT UTF-8 ALL", "* THREAD (2)(1 (3)(4))"},
+		{"THREAD REFERENCES UTF-8 ALL", "* THREAD (2)(1 3 4)"},
+		{"UID THREAD REFERENCES UTF-8 NOT UID 3", "* THREAD (2)(1 4)"},
+		{"THREAD REFERENCES UTF-8 SUBJECT nothing", "* THREAD"},
+	} {
+		assert.Equal(t, []string{tt.want}, c.mustOK("%s", tt.command), tt.command)
+	}
+
+	_, status := c.command("SORT (DATE) KOI8-R ALL")
+	assert.Equal(t, "NO [BADCHARSET (UTF-8 US-ASCII)] Unsupported charset", status)
+	_, status = c.command("SORT (DISPLAYFROM) UTF-8 ALL")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+	_, status = c.command("THREAD X-JWZ UTF-8 ALL")
+	assert.True(t, strings.HasPrefix(status, "BAD"), status)
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/imap"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIMAP_TLS verifies implicit TLS (RFC 8314) and STARTTLS with a shared TLS configuration
+func TestIMAP_TLS(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	tlsCfg := generateTestTLSConfig(t, "mail.example.com")
+	clientTLS := &tls.Config{ServerName: "mail.example.com", InsecureSkipVerify: true} //nolint:gosec // self-signed test certificate
+
+	// Port 993: TLS from the first byte, so passwords are accepted and STARTTLS isn't offered
+	addr := env.startIMAPServer(t, config.IMAPConfig{}, func(s *imap.Server) {
+		s.SetTLSConfig(tlsCfg)
+		s.SetImplicitTLS(true)
+	})
+	conn, err := tls.Dial("tcp", addr, clientTLS)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &imapTestClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	greeting := c.readLine()
+	assert.True(t, strings.HasPrefix(greeting, "* OK [CAPABILITY "), greeting)
+	assert.Contains(t, greeting, "AUTH=PLAIN")
+	assert.NotContains(t, greeting, "STARTTLS")
+	assert.NotContains(t, greeting, "LOGINDISABLED")
+	c.mustOK("LOGIN test@example.com testpassword123")
+	c.mustOK("SELECT INBOX")
+
+	// Plain port without a certificate: no STARTTLS
+	c = dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{}))
+	assert.NotContains(t, c.mustOK("CAPABILITY")[0], "STARTTLS")
+	_, status := c.command("STARTTLS")
+	assert.Equal(t, "NO TLS not available", status)
+
+	// Plain port with a certificate: STARTTLS upgrades the connection and enables LOGIN
+	c = dialIMAP(t, env.startIMAPServer(t, config.IMAPConfig{}, func(s *imap.Server) {
+		s.SetTLSConfig(tlsCfg)
+	}))
+	capability := c.mustOK("CAPABILITY")[0]
+	assert.Contains(t, capability, "STARTTLS")
+	assert.Contains(t, capability, "LOGINDISABLED")
+	c.mustOK("STARTTLS")
+
+	upgraded := tls.Client(c.conn.(net.Conn), clientTLS)
+	require.NoError(t, upgraded.Handshake())
+	c.conn, c.reader = upgraded, bufio.NewReader(upgraded)
+	capability = c.mustOK("CAPABILITY")[0]
+	assert.NotContains(t, capability, "STARTTLS")
+	assert.Contains(t, capability, "AUTH=PLAIN")
+	c.mustOK("LOGIN test@example.com testpassword123")
+}
@@ -1,13 +1,22 @@
 package tests
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/handlers"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/validators"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMTASTS_Serving(t *testing.T) {
@@ -40,3 +49,154 @@ func TestMTASTS_Serving(t *testing.T) {
 	assert.Contains(t, bodyString, "mx: mail.example.com")
 	assert.Contains(t, bodyString, "max_age: 3600")
 }
+
+// tlsResultCollector records outbound TLS session results
+type tlsResultCollector struct {
+	mu      sync.Mutex
+	results []domain.TLSSessionResult
+}
+
+func (c *tlsResultCollector) RecordTLSResult(ctx context.Context, result domain.TLSSessionResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, result)
+}
+
+func (c *tlsResultCollector) all() []domain.TLSSessionResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]domain.TLSSessionResult(nil), c.results...)
+}
+
+func TestMTASTS_PolicyParsing(t *testing.T) {
+	policy, err := domain.ParseMTASTSPolicy("version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.example.net\r\nmax_age: 604800\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, domain.MTASTSModeEnforce, policy.Mode)
+	assert.Equal(t, 604800, policy.MaxAge)
+
+	assert.True(t, policy.MatchesMX("mail.example.com"))
+	assert.True(t, policy.MatchesMX("MAIL.example.com."))
+	assert.True(t, policy.MatchesMX("mx1.example.net"))
+	assert.False(t, policy.MatchesMX("a.b.example.net"))
+	assert.False(t, policy.MatchesMX("example.net"))
+	assert.False(t, policy.MatchesMX("evil.example.org"))
+
+	_, err = domain.ParseMTASTSPolicy("version: STSv1\nmode: enforce\nmax_age: 100\n")
+	assert.Error(t, err, "enforce policy without mx must be rejected")
+	_, err = domain.ParseMTASTSPolicy("version: STSv2\nmode: none\nmax_age: 100\n")
+	assert.Error(t, err)
+}
+
+func TestMTASTS_OutboundEnforcement(t *testing.T) {
+	mockServer, err := NewMockSMTPServer()
+	require.NoError(t, err)
+	defer mockServer.Close()
+
+	var policyBody string
+	var fetches int
+	var mu sync.Mutex
+	policyServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(policyBody))
+	}))
+	defer policyServer.Close()
+
+	setPolicy := func(body string) {
+		mu.Lock()
+		defer mu.Unlock()
+		policyBody = body
+	}
+
+	policyID := "1"
+	newClient := func() (*smtp.Client, *tlsResultCollector) {
+		validator := validators.NewMTASTSValidator()
+		validator.HTTPClient = policyServer.Client()
+		validator.PolicyURL = func(string) string { return policyServer.URL + "/.well-known/mta-sts.txt" }
+		validator.LookupTXT = func(name string) ([]string, error) {
+			assert.Equal(t, "_mta-sts.test.local", name)
+			return []string{"v=STSv1; id=" + policyID}, nil
+		}
+
+		client := smtp.NewClient(config.DANEConfig{Mode: "off"}, observability.NewLogger("error", "text"))
+		client.Port = mockServer.Port
+		client.LookupMX = func(name string) ([]*net.MX, error) {
+			return []*net.MX{{Host: "127.0.0.1", Pref: 10}}, nil
+		}
+		client.SetMTASTS(validator, "enforce")
+		collector := &tlsResultCollector{}
+		client.SetTLSRecorder(collector)
+		return client, collector
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	body := []byte("Subject: Test\r\n\r\nHello MTA-STS")
+
+	t.Run("EnforceRejectsUnlistedMX", func(t *testing.T) {
+		setPolicy("version: STSv1\nmode: enforce\nmx: mx.test.local\nmax_age: 86400\n")
+		client, collector := newClient()
+
+		err := client.Send(ctx, "sender@example.com", "rcpt@test.local", body)
+		require.Error(t, err)
+		assert.Empty(t, mockServer.getMessages(), "no connection may be made to an unlisted MX")
+
+		results := collector.all()
+		require.Len(t, results, 1)
+		assert.Equal(t, domain.TLSPolicyTypeSTS, results[0].PolicyType)
+		assert.Equal(t, domain.TLSResultValidationFailure, results[0].ResultType)
+	})
+
+	t.Run("EnforceRequiresSTARTTLS", func(t *testing.T) {
+		setPolicy("version: STSv1\nmode: enforce\nmx: 127.0.0.1\nmax_age: 86400\n")
+		client, collector := newClient()
+
+		err := client.Send(ctx, "sender@example.com", "rcpt@test.local", body)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "STARTTLS")
+		assert.Empty(t, mockServer.getMessages())
+
+		results := collector.all()
+		require.Len(t, results, 1)
+		assert.False(t, results[0].Success)
+		assert.Equal(t, domain.TLSResultSTARTTLSNotSupported, results[0].ResultType)
+		assert.Equal(t, "127.0.0.1", results[0].ReceivingIP)
+	})
+
+	t.Run("TestingModeDeliversAndReports", func(t *testing.T) {
+		setPolicy("version: STSv1\nmode: testing\nmx: mx.test.local\nmax_age: 86400\n")
+		client, collector := newClient()
+
+		err := client.Send(ctx, "sender@example.com", "rcpt@test.local", body)
+		require.NoError(t, err)
+		assert.Len(t, mockServer.getMessages(), 1)
+
+		// One result per session: the unlisted MX is reported with the session it delivered over
+		results := collector.all()
+		require.Len(t, results, 1)
+		assert.Equal(t, domain.TLSResultSTARTTLSNotSupported, results[0].ResultType)
+		assert.Contains(t, results[0].PolicyString, "mode: testing")
+	})
+
+	t.Run("PolicyCachedUntilIDChanges", func(t *testing.T) {
+		setPolicy("version: STSv1\nmode: testing\nmx: 127.0.0.1\nmax_age: 86400\n")
+		client, _ := newClient()
+		mu.Lock()
+		fetches = 0
+		mu.Unlock()
+
+		require.NoError(t, client.Send(ctx, "sender@example.com", "rcpt@test.local", body))
+		require.NoError(t, client.Send(ctx, "sender@example.com", "rcpt@test.local", body))
+		mu.Lock()
+		assert.Equal(t, 1, fetches)
+		mu.Unlock()
+
+		policyID = "2"
+		require.NoError(t, client.Send(ctx, "sender@example.com", "rcpt@test.local", body))
+		mu.Lock()
+		assert.Equal(t, 2, fetches)
+		mu.Unlock()
+	})
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 )
 
@@ -21,6 +23,12 @@ type MockSMTPServer struct {
 	Port     string
 	Messages []string // Store received messages
 	mu       sync.Mutex
+
+	Connections int // Number of SMTP sessions accepted
+	Recipients  int // Number of RCPT commands accepted
+	Closed      int // Number of SMTP sessions ended by the client
+
+	TLSConfig *tls.Config // When set, STARTTLS is advertised and negotiated with it
 }
 
 func NewMockSMTPServer() (*MockSMTPServer, error) {
@@ -52,6 +60,15 @@ func (s *MockSMTPServer) serve() {
 
 func (s *MockSMTPServer) handle(conn net.Conn) {
 	defer conn.Close()
+	defer func() {
+		s.mu.Lock()
+		s.Closed++
+		s.mu.Unlock()
+	}()
+
+	s.mu.Lock()
+	s.Connections++
+	s.mu.Unlock()
 
 	reader := bufio.NewReader(conn)
 	writer := bufio.NewWriter(conn)
@@ -62,6 +79,7 @@ func (s *MockSMTPServer) handle(conn net.Conn) {
 
 	var messageBody strings.Builder
 	inData := false
+	tlsActive := false
 
 	for {
 		line, err := reader.ReadString('\n')
@@ -75,6 +93,7 @@ func (s *MockSMTPServer) handle(conn net.Conn) {
 			if line == "." {
 				inData = false
 				s.addMessage(messageBody.String())
+				messageBody.Reset()
 				fmt.Fprintf(writer, "250 OK\r\n")
 				writer.Flush()
 			} else {
@@ -90,13 +109,22 @@ func (s *MockSMTPServer) handle(conn net.Conn) {
 		cmd := strings.ToUpper(strings.Split(line, " ")[0])
 		switch cmd {
 		case "EHLO", "HELO":
-			// No STARTTLS advertised
-			fmt.Fprintf(writer, "250-localhost\r\n250 SIZE 10485760\r\n")
+			if s.TLSConfig != nil && !tlsActive {
+				fmt.Fprintf(writer, "250-localhost\r\n250-STARTTLS\r\n250 SIZE 10485760\r\n")
+			} else {
+				fmt.Fprintf(writer, "250-localhost\r\n250 SIZE 10485760\r\n")
+			}
 			writer.Flush()
 		case "MAIL":
 			fmt.Fprintf(writer, "250 OK\r\n")
 			writer.Flush()
 		case "RCPT":
+			s.mu.Lock()
+			s.Recipients++
+			s.mu.Unlock()
+			fmt.Fprintf(writer, "250 OK\r\n")
+			writer.Flush()
+		case "RSET", "NOOP":
 			fmt.Fprintf(writer, "250 OK\r\n")
 			writer.Flush()
 		case "DATA":
@@ -108,8 +136,20 @@ func (s *MockSMTPServer) handle(conn net.Conn) {
 			writer.Flush()
 			return
 		case "STARTTLS":
-			fmt.Fprintf(writer, "502 Not implemented\r\n")
+			if s.TLSConfig == nil || tlsActive {
+				fmt.Fprintf(writer, "502 Not implemented\r\n")
+				writer.Flush()
+				continue
+			}
+			fmt.Fprintf(writer, "220 Ready to start TLS\r\n")
 			writer.Flush()
+			tlsConn := tls.Server(conn, s.TLSConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			reader = bufio.NewReader(tlsConn)
+			writer = bufio.NewWriter(tlsConn)
+			tlsActive = true
 		default:
 			fmt.Fprintf(writer, "500 Unknown command\r\n")
 			writer.Flush()
@@ -129,6 +169,18 @@ func (s *MockSMTPServer) getMessages() []string {
 	return append([]string(nil), s.Messages...)
 }
 
+func (s *MockSMTPServer) counts() (connections, recipients int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Connections, s.Recipients
+}
+
+func (s *MockSMTPServer) closed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Closed
+}
+
 func (s *MockSMTPServer) Close() {
 	s.Listener.Close()
 }
@@ -191,3 +243,129 @@ func TestSMTPClientDelivery(t *testing.T) {
 		t.Errorf("Message body mismatch. Got: %s", received)
 	}
 }
+
+// TestSMTPClientCertificateVerification verifies MX certificates are PKIX-validated unless
+// opportunistic TLS is configured
+func TestSMTPClientCertificateVerification(t *testing.T) {
+	mockServer, err := NewMockSMTPServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mockServer.Close()
+	// The certificate names a hostname, not the IP address the client connects to
+	mockServer.TLSConfig = generateTestTLSConfig(t, "mx.test.local")
+
+	newClient := func() (*smtp.Client, *tlsResultCollector) {
+		client := smtp.NewClient(config.DANEConfig{Mode: "off"}, observability.NewLogger("error", "text"))
+		client.Port = mockServer.Port
+		client.LookupMX = func(name string) ([]*net.MX, error) {
+			return []*net.MX{{Host: "127.0.0.1", Pref: 10}}, nil
+		}
+		collector := &tlsResultCollector{}
+		client.SetTLSRecorder(collector)
+		return client, collector
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body := []byte("Subject: Test\r\n\r\nHello TLS")
+
+	// The certificate is rejected by default
+	client, collector := newClient()
+	if err := client.Send(ctx, "sender@example.com", "rcpt@test.local", body); err == nil {
+		t.Fatal("expected delivery to fail with an untrusted certificate")
+	}
+	if n := len(mockServer.getMessages()); n != 0 {
+		t.Fatalf("expected no message delivered, got %d", n)
+	}
+	if results := collector.all(); len(results) != 1 || results[0].ResultType != domain.TLSResultCertificateHostMismatch {
+		t.Errorf("expected one certificate-host-mismatch result, got %+v", results)
+	}
+
+	// Opportunistic TLS delivers over the encrypted session and still reports the failure
+	client, collector = newClient()
+	client.SetOpportunisticTLS(true)
+	if err := client.Send(ctx, "sender@example.com", "rcpt@test.local", body); err != nil {
+		t.Fatalf("opportunistic delivery failed: %v", err)
+	}
+	if n := len(mockServer.getMessages()); n != 1 {
+		t.Fatalf("expected 1 message delivered, got %d", n)
+	}
+	if results := collector.all(); len(results) != 1 || results[0].ResultType != domain.TLSResultCertificateHostMismatch {
+		t.Errorf("expected one certificate-host-mismatch result, got %+v", results)
+	}
+}
+
+// TestSMTPClientReapsIdleSessions verifies pooled sessions are quit once idle for longer
+// than the idle timeout, without further deliveries
+func TestSMTPClientReapsIdleSessions(t *testing.T) {
+	mockServer, err := NewMockSMTPServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mockServer.Close()
+
+	client := smtp.NewClient(config.DANEConfig{Mode: "off"}, observability.NewLogger("error", "text"))
+	defer client.Close()
+	client.Port = mockServer.Port
+	client.LookupMX = func(name string) ([]*net.MX, error) {
+		return []*net.MX{{Host: "127.0.0.1", Pref: 10}}, nil
+	}
+	client.SetConnectionLimits(4, 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Send(ctx, "sender@example.com", "rcpt@test.local", []byte("Subject: Test\r\n\r\nHello")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if n := mockServer.closed(); n != 0 {
+		t.Fatalf("expected the session to be pooled, %d closed", n)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for mockServer.closed() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := mockServer.closed(); n != 1 {
+		t.Errorf("expected the idle session to be reaped, %d closed", n)
+	}
+}
+
+// TestSMTPClientCommandTimeout verifies a server that stops responding fails the delivery
+// once the command deadline passes
+func TestSMTPClientCommandTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Never send a greeting
+			defer conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	client := smtp.NewClient(config.DANEConfig{Mode: "off"}, observability.NewLogger("error", "text"))
+	defer client.Close()
+	client.Port = port
+	client.LookupMX = func(name string) ([]*net.MX, error) {
+		return []*net.MX{{Host: "127.0.0.1", Pref: 10}}, nil
+	}
+	client.SetTimeouts(200*time.Millisecond, 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := client.Send(ctx, "sender@example.com", "rcpt@test.local", []byte("Subject: Test\r\n\r\nHello")); err == nil {
+		t.Fatal("expected delivery to a silent server to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command deadline to end the attempt, took %v", elapsed)
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// TestHandler_DeliversToAllRecipients verifies per-recipient storage and partial failure reporting
+func TestHandler_DeliversToAllRecipients(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	testUser, _ := env.userRepo.FindByEmail(ctx, "test@example.com")
+	for _, email := range []string{"second@example.com", "full@example.com"} {
+		if err := env.userRepo.Create(ctx, &domain.User{Email: email, PasswordHash: testUser.PasswordHash, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to create user %s: %v", email, err)
+		}
+	}
+	if err := env.userRepo.UpdateQuota(ctx, "full@example.com", 10); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+
+	searchIdx := sqlite.NewSearchRepository(env.conn.DB)
+	sieveEngine := sieve.NewSieveEngine(sqlite.NewSqliteScriptRepository(env.conn.DB), env.emailRepo,
+		sqlite.NewSqliteVacationRepository(env.conn.DB), env.queueRepo, env.blobStore)
+	handler := smtp.NewHandler(env.emailRepo, env.userRepo, env.blobStore, searchIdx, sieveEngine, logger, metrics)
+
+	countFor := func(email string) int {
+		n, err := env.emailRepo.CountByUser(ctx, email)
+		if err != nil {
+			t.Fatalf("CountByUser(%s): %v", email, err)
+		}
+		return n
+	}
+	before := countFor("test@example.com")
+
+	raw := []byte("From: sender@localhost\r\nTo: test@example.com, second@example.com, full@example.com\r\nSubject: Team update\r\nMessage-ID: <multi@localhost>\r\n\r\nHello team\r\n")
+	session := &domain.SMTPSession{
+		SessionID:  "multi-rcpt",
+		RemoteIP:   "127.0.0.1",
+		Sender:     "sender@localhost",
+		Recipients: []string{"test@example.com", "second@example.com", "full@example.com"},
+	}
+
+	err := handler.Handle(session, raw)
+	var derr *smtp.DeliveryError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected DeliveryError for over-quota recipient, got %v", err)
+	}
+	if len(derr.Delivered) != 2 || len(derr.Failed) != 1 || derr.Failed[0].Recipient != "full@example.com" {
+		t.Fatalf("unexpected delivery result: %+v", derr)
+	}
+	if !errors.Is(err, smtp.ErrQuotaExceeded) || !derr.Permanent() {
+		t.Errorf("quota failure should be permanent: %v", err)
+	}
+
+	if got := countFor("test@example.com"); got != before+1 {
+		t.Errorf("test@example.com: expected %d messages, got %d", before+1, got)
+	}
+	if got := countFor("second@example.com"); got != 1 {
+		t.Errorf("second@example.com: expected 1 message, got %d", got)
+	}
+	if got := countFor("full@example.com"); got != 0 {
+		t.Errorf("full@example.com: expected 0 messages, got %d", got)
+	}
+
+	// Each recipient gets its own search index entry
+	for _, email := range []string{"test@example.com", "second@example.com"} {
+		results, err := searchIdx.Search(ctx, email, "team", 10, 0)
+		if err != nil || len(results) != 1 {
+			t.Errorf("%s: expected 1 search hit, got %d (err %v)", email, len(results), err)
+		}
+	}
+}
+
+// TestEmailRepository_SaveAllIsAtomic verifies that a recipient's Sieve targets are stored all
+// or none, so that a retried delivery can't duplicate the message in the folders already saved
+func TestEmailRepository_SaveAllIsAtomic(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	userBefore, err := env.userRepo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail failed: %v", err)
+	}
+	countBefore, _ := env.emailRepo.CountByUser(ctx, "test@example.com")
+
+	newMsg := func(id, mailbox string) *domain.Message {
+		return &domain.Message{
+			ID: id, Recipient: "test@example.com", Mailbox: mailbox, BodyPath: "blob",
+			Size: 100, ReceivedAt: time.Now(),
+		}
+	}
+
+	// The duplicate ID fails the second insert
+	if err := env.emailRepo.SaveAll(ctx, []*domain.Message{newMsg("folders-1", "INBOX"), newMsg("folders-1", "Work")}); err == nil {
+		t.Fatal("Expected duplicate ID to fail the batch")
+	}
+	if n, _ := env.emailRepo.CountByUser(ctx, "test@example.com"); n != countBefore {
+		t.Fatalf("Expected nothing stored after a failed batch, got %d new messages", n-countBefore)
+	}
+	if user, _ := env.userRepo.FindByEmail(ctx, "test@example.com"); user.StorageUsed != userBefore.StorageUsed {
+		t.Errorf("Expected storage used to stay at %d, got %d", userBefore.StorageUsed, user.StorageUsed)
+	}
+
+	if err := env.emailRepo.SaveAll(ctx, []*domain.Message{newMsg("folders-1", "INBOX"), newMsg("folders-2", "Work")}); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+	if n, _ := env.emailRepo.CountByUser(ctx, "test@example.com"); n != countBefore+2 {
+		t.Errorf("Expected 2 new messages, got %d", n-countBefore)
+	}
+	if user, _ := env.userRepo.FindByEmail(ctx, "test@example.com"); user.StorageUsed != userBefore.StorageUsed+200 {
+		t.Errorf("Expected storage used to grow by 200, got %d -> %d", userBefore.StorageUsed, user.StorageUsed)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/sieve"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/services"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// TestSMTP_RecipientChecks verifies that unknown and over-quota recipients are refused at
+// RCPT time and that recipients failing temporarily after DATA are queued for retry, not bounced
+func TestSMTP_RecipientChecks(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	testUser, _ := env.userRepo.FindByEmail(ctx, "test@example.com")
+	for _, email := range []string{"second@example.com", "full@example.com"} {
+		if err := env.userRepo.Create(ctx, &domain.User{Email: email, PasswordHash: testUser.PasswordHash, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to create user %s: %v", email, err)
+		}
+	}
+	if err := env.userRepo.UpdateQuota(ctx, "full@example.com", 10); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+	if err := env.userRepo.IncrementStorageUsed(ctx, "full@example.com", 10); err != nil {
+		t.Fatalf("Failed to set usage: %v", err)
+	}
+
+	// The handler delivers to the first recipient and fails temporarily for the second
+	handler := func(session *domain.SMTPSession, message []byte) error {
+		return &smtp.DeliveryError{
+			Delivered: session.Recipients[:1],
+			Failed:    []smtp.RecipientError{{Recipient: session.Recipients[1], Err: errors.New("database is locked")}},
+		}
+	}
+
+	cfg := &config.Config{SMTP: config.SMTPConfig{Hostname: "mx.example.com", MaxSize: 1024 * 1024}}
+	server := smtp.NewServer(cfg, logger, metrics, handler, nil, env.userRepo)
+	server.SetQuotas(services.NewQuotaService(env.userRepo, env.emailRepo))
+	server.SetRetryQueue(env.queueRepo, env.blobStore)
+	server.SetBouncer(env.bouncer)
+
+	serverCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = server.Start(serverCtx) }()
+	var addr net.Addr
+	for i := 0; i < 20 && addr == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		addr = server.Addr()
+	}
+	if addr == nil {
+		t.Fatal("SMTP server failed to start")
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	cmd := func(line, prefix string) {
+		t.Helper()
+		if line != "" {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+		reply, err := reader.ReadLine()
+		for err == nil && strings.HasPrefix(reply, prefix[:3]+"-") {
+			reply, err = reader.ReadLine()
+		}
+		if err != nil {
+			t.Fatalf("read error after %q: %v", line, err)
+		}
+		if !strings.HasPrefix(reply, prefix) {
+			t.Fatalf("%q: expected %s, got %s", line, prefix, reply)
+		}
+	}
+
+	cmd("", "220")
+	cmd("EHLO remote.test", "250")
+	cmd("MAIL FROM:<sender@remote.test> SIZE=100", "250")
+	cmd("RCPT TO:<nobody@example.com>", "550 5.1.1")
+	cmd("RCPT TO:<full@example.com>", "552 5.2.2")
+	cmd("RCPT TO:<test@example.com>", "250")
+	cmd("RCPT TO:<second@example.com>", "250")
+	cmd("DATA", "354")
+	cmd("Subject: Hello\r\n\r\nHi\r\n.", "250")
+	cmd("QUIT", "221")
+
+	// The temporary failure is queued for the worker to retry, and no DSN is sent
+	queued, err := env.queueRepo.List(ctx, domain.QueueFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("Expected one queued retry and no DSN, got %d entries", len(queued))
+	}
+	retry := queued[0]
+	if retry.Recipient != "second@example.com" || retry.Sender != "sender@remote.test" || retry.Status != domain.QueueStatusRetrying || !retry.Local {
+		t.Errorf("Unexpected queue entry: %+v", retry)
+	}
+	data, err := env.blobStore.Read(ctx, retry.BlobKey)
+	if err != nil || !strings.Contains(string(data), "Subject: Hello") {
+		t.Errorf("Queued message not stored: %v", err)
+	}
+
+	// The worker redelivers into the local mailbox without going over SMTP
+	sieveEngine := sieve.NewSieveEngine(sqlite.NewSqliteScriptRepository(env.conn.DB), env.emailRepo,
+		sqlite.NewSqliteVacationRepository(env.conn.DB), env.queueRepo, env.blobStore)
+	localHandler := smtp.NewHandler(env.emailRepo, env.userRepo, env.blobStore, env.searchIdx, sieveEngine, logger, metrics)
+	sender := &MockSender{}
+	worker := smtp.NewDeliveryWorker(env.queueRepo, env.blobStore, sender, logger, metrics)
+	worker.SetLocalDeliverer(localHandler)
+	if err := env.queueRepo.UpdateStatus(ctx, retry.ID, domain.QueueStatusRetrying, retry.RetryCount, time.Now().Add(-time.Second), retry.LastError); err != nil {
+		t.Fatalf("Failed to make the retry ready: %v", err)
+	}
+	if !worker.ProcessNext() {
+		t.Fatal("Worker did not pick up the retry")
+	}
+	if sender.Calls != 0 {
+		t.Errorf("Local retry was sent over SMTP %d times", sender.Calls)
+	}
+	retried, err := env.queueRepo.Get(ctx, retry.ID)
+	if err != nil || retried.Status != domain.QueueStatusSent {
+		t.Errorf("Retry not marked sent: %+v, %v", retried, err)
+	}
+	inbox, err := env.emailRepo.FindInMailbox(ctx, "second@example.com", "INBOX", domain.MailboxFilter{})
+	if err != nil || len(inbox) != 1 || inbox[0].Subject != "Hello" {
+		t.Errorf("Retry not stored in the INBOX: %d messages, %v", len(inbox), err)
+	}
+}
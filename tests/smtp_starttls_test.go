@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// TestSMTP_STARTTLS verifies the RFC 3207 upgrade, session reset and recorded TLS details
+func TestSMTP_STARTTLS(t *testing.T) {
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	cfg := &config.Config{
+		Domain: "tls.test",
+		SMTP: config.SMTPConfig{
+			Hostname: "mx.tls.test",
+			Port:     0,
+			MaxSize:  1024 * 1024,
+		},
+	}
+
+	var mu sync.Mutex
+	var gotSession domain.SMTPSession
+	var gotMessage string
+	handler := func(session *domain.SMTPSession, message []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		gotSession = *session
+		gotMessage = string(message)
+		return nil
+	}
+
+	server := smtp.NewServer(cfg, logger, metrics, handler, nil, nil)
+	server.SetTLSConfig(generateTestTLSConfig(t, "mx.tls.test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = server.Start(ctx) }()
+
+	var addr net.Addr
+	for i := 0; i < 20; i++ {
+		addr = server.Addr()
+		if addr != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if addr == nil {
+		t.Fatal("SMTP server failed to start")
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	expect := func(prefix string) string {
+		t.Helper()
+		line, err := reader.ReadLine()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if !strings.HasPrefix(line, prefix) {
+			t.Fatalf("expected %s, got %s", prefix, line)
+		}
+		return line
+	}
+	ehlo := func() []string {
+		t.Helper()
+		fmt.Fprintf(conn, "EHLO client.test\r\n")
+		var lines []string
+		for {
+			line := expect("250")
+			lines = append(lines, line)
+			if strings.HasPrefix(line, "250 ") {
+				return lines
+			}
+		}
+	}
+
+	expect("220")
+	if !strings.Contains(strings.Join(ehlo(), "\n"), "STARTTLS") {
+		t.Fatal("STARTTLS not advertised before upgrade")
+	}
+
+	fmt.Fprintf(conn, "STARTTLS\r\n")
+	expect("220")
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         "mx.tls.test",
+		InsecureSkipVerify: true, //nolint:gosec // self-signed test certificate
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+	conn = tlsConn
+	reader = textproto.NewReader(bufio.NewReader(conn))
+
+	// Client must re-issue EHLO after the upgrade; STARTTLS is no longer offered
+	if strings.Contains(strings.Join(ehlo(), "\n"), "STARTTLS") {
+		t.Fatal("STARTTLS advertised after upgrade")
+	}
+
+	fmt.Fprintf(conn, "MAIL FROM:<sender@remote.test>\r\n")
+	expect("250")
+	fmt.Fprintf(conn, "RCPT TO:<user@tls.test>\r\n")
+	expect("250")
+	fmt.Fprintf(conn, "DATA\r\n")
+	expect("354")
+	fmt.Fprintf(conn, "Subject: TLS\r\n\r\nHello over TLS\r\n.\r\n")
+	expect("250")
+	fmt.Fprintf(conn, "QUIT\r\n")
+	expect("221")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !gotSession.TLS {
+		t.Error("session not marked as TLS")
+	}
+	if gotSession.TLSServerName != "mx.tls.test" {
+		t.Errorf("expected SNI mx.tls.test, got %q", gotSession.TLSServerName)
+	}
+	if gotSession.TLSVersion == "" || gotSession.TLSCipher == "" {
+		t.Errorf("TLS version/cipher not recorded: %+v", gotSession)
+	}
+	if !strings.HasPrefix(gotMessage, "Received: from client.test") || !strings.Contains(gotMessage, "with ESMTPS") {
+		t.Errorf("missing TLS Received header: %q", gotMessage)
+	}
+	if metrics.GetSnapshot().SMTPTLSSessions != 1 {
+		t.Errorf("expected 1 TLS session metric, got %d", metrics.GetSnapshot().SMTPTLSSessions)
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp/dkim"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/config"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
+)
+
+// TestSMTP_Submission verifies AUTH, sender ownership, DKIM signing and enqueueing on the submission listener
+func TestSMTP_Submission(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+
+	logger := observability.NewLogger("error", "text")
+	metrics := observability.NewMetrics()
+
+	keyPath := filepath.Join(env.tempDir, "submission-dkim.pem")
+	generateTestDKIMKey(t, keyPath)
+	pemBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to read DKIM key: %v", err)
+	}
+	key, err := dkim.ParsePrivateKey(pemBytes)
+	if err != nil {
+		t.Fatalf("Failed to parse DKIM key: %v", err)
+	}
+
+	cfg := &config.Config{
+		Domain: "example.com",
+		SMTP: config.SMTPConfig{
+			Hostname: "mail.example.com",
+			MaxSize:  1024 * 1024,
+		},
+	}
+
+	handler := smtp.NewSubmissionHandler(env.queueRepo, env.blobStore, dkim.NewResolver(env.domainRepo, dkim.NewSigner("example.com", "default", key)), logger, metrics)
+	tlsCfg := generateTestTLSConfig(t, "mail.example.com")
+
+	startServer := func(implicitTLS bool) net.Addr {
+		srv := smtp.NewSubmissionServer(cfg, logger, metrics, handler.Handle, env.userRepo, 0, implicitTLS)
+		srv.SetTLSConfig(tlsCfg)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = srv.Start(ctx) }()
+		for i := 0; i < 20; i++ {
+			if addr := srv.Addr(); addr != nil {
+				return addr
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatal("submission server failed to start")
+		return nil
+	}
+
+	clientTLS := &tls.Config{ServerName: "mail.example.com", InsecureSkipVerify: true} //nolint:gosec // self-signed test certificate
+
+	type client struct {
+		conn   net.Conn
+		reader *textproto.Reader
+	}
+	newClient := func(conn net.Conn) *client {
+		return &client{conn: conn, reader: textproto.NewReader(bufio.NewReader(conn))}
+	}
+	cmd := func(c *client, line, prefix string) string {
+		t.Helper()
+		if line != "" {
+			fmt.Fprintf(c.conn, "%s\r\n", line)
+		}
+		for {
+			resp, err := c.reader.ReadLine()
+			if err != nil {
+				t.Fatalf("read error after %q: %v", line, err)
+			}
+			if len(resp) > 3 && resp[3] == '-' {
+				continue
+			}
+			if !strings.HasPrefix(resp, prefix) {
+				t.Fatalf("%q: expected %s, got %s", line, prefix, resp)
+			}
+			return resp
+		}
+	}
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00test@example.com\x00testpassword123"))
+
+	// Port 587: AUTH is refused before STARTTLS
+	conn, err := net.Dial("tcp", startServer(false).String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	c := newClient(conn)
+	cmd(c, "", "220")
+	cmd(c, "EHLO client.test", "250")
+	cmd(c, "AUTH PLAIN "+plain, "538")
+	cmd(c, "MAIL FROM:<test@example.com>", "530")
+	cmd(c, "STARTTLS", "220")
+	tlsConn := tls.Client(conn, clientTLS)
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+	c = newClient(tlsConn)
+	cmd(c, "EHLO client.test", "250")
+	cmd(c, "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00test@example.com\x00wrong")), "535")
+	cmd(c, "AUTH PLAIN "+plain, "235")
+	cmd(c, "QUIT", "221")
+
+	// Port 465: implicit TLS with AUTH LOGIN
+	tlsConn, err = tls.Dial("tcp", startServer(true).String(), clientTLS)
+	if err != nil {
+		t.Fatalf("Failed to dial implicit TLS: %v", err)
+	}
+	defer tlsConn.Close()
+	c = newClient(tlsConn)
+	cmd(c, "", "220")
+	cmd(c, "EHLO client.test", "250")
+	cmd(c, "AUTH LOGIN", "334")
+	cmd(c, base64.StdEncoding.EncodeToString([]byte("test@example.com")), "334")
+	cmd(c, base64.StdEncoding.EncodeToString([]byte("testpassword123")), "235")
+
+	// Envelope sender must belong to the authenticated user
+	cmd(c, "MAIL FROM:<someone@else.com>", "553")
+	cmd(c, "MAIL FROM:<test@example.com> SIZE=100", "250")
+	cmd(c, "RCPT TO:<alice@remote.test>", "250")
+	cmd(c, "RCPT TO:<bob@remote.test>", "250")
+
+	// From header must belong to the authenticated user
+	cmd(c, "DATA", "354")
+	cmd(c, "From: CEO <ceo@example.com>\r\nTo: alice@remote.test\r\nSubject: Spoof\r\n\r\nNope\r\n.", "550")
+
+	cmd(c, "MAIL FROM:<test@example.com>", "250")
+	cmd(c, "RCPT TO:<alice@remote.test>", "250")
+	cmd(c, "RCPT TO:<bob@remote.test>", "250")
+	cmd(c, "DATA", "354")
+	cmd(c, "From: Test User <test@example.com>\r\nTo: alice@remote.test, bob@remote.test\r\nSubject: Hello\r\n\r\nHi both\r\n.", "250")
+	cmd(c, "QUIT", "221")
+
+	// One queue entry per recipient, sharing a DKIM-signed blob
+	recipients := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg, err := env.queueRepo.LockNextReady(context.Background())
+		if err != nil || msg == nil {
+			t.Fatalf("expected queued message %d, got %v (err %v)", i+1, msg, err)
+		}
+		if msg.Sender != "test@example.com" {
+			t.Errorf("unexpected sender %q", msg.Sender)
+		}
+		recipients[msg.Recipient] = true
+
+		data, err := env.blobStore.Read(context.Background(), msg.BlobKey)
+		if err != nil {
+			t.Fatalf("Failed to read queued blob: %v", err)
+		}
+		raw := string(data)
+		if !strings.HasPrefix(raw, "DKIM-Signature:") || !strings.Contains(raw, "d=example.com") {
+			t.Errorf("queued message not DKIM-signed for example.com: %.120q", raw)
+		}
+		if !strings.Contains(raw, "Message-ID:") {
+			t.Error("submission did not add a Message-ID")
+		}
+	}
+	if !recipients["alice@remote.test"] || !recipients["bob@remote.test"] {
+		t.Errorf("unexpected recipients queued: %v", recipients)
+	}
+}
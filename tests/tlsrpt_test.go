@@ -2,16 +2,26 @@ package tests
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/http/handlers"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/smtp"
+	"github.com/Kartikey2011yadav/mailraven-server/internal/adapters/storage/sqlite"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/core/domain"
 	"github.com/Kartikey2011yadav/mailraven-server/internal/observability"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockTLSRptRepo
@@ -29,6 +39,26 @@ func (m *MockTLSRptRepo) FindLatest(ctx context.Context, limit int) ([]*domain.T
 	return args.Get(0).([]*domain.TLSReport), args.Error(1)
 }
 
+func (m *MockTLSRptRepo) RecordSession(ctx context.Context, result *domain.TLSSessionResult) error {
+	args := m.Called(ctx, result)
+	return args.Error(0)
+}
+
+func (m *MockTLSRptRepo) ListSessions(ctx context.Context, start, end time.Time) ([]*domain.TLSSessionResult, error) {
+	args := m.Called(ctx, start, end)
+	return args.Get(0).([]*domain.TLSSessionResult), args.Error(1)
+}
+
+func (m *MockTLSRptRepo) DeleteSessions(ctx context.Context, policyDomain string, start, end time.Time) error {
+	args := m.Called(ctx, policyDomain, start, end)
+	return args.Error(0)
+}
+
+func (m *MockTLSRptRepo) DeleteSessionsBefore(ctx context.Context, t time.Time) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
 func TestTLSRPT_Ingestion(t *testing.T) {
 	// Setup
 	mockRepo := &MockTLSRptRepo{}
@@ -75,3 +105,142 @@ func TestTLSRPT_Ingestion(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestTLSRPT_OutboundReports(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	// Collector endpoint for the https rua
+	var mu sync.Mutex
+	var posted []byte
+	var postedType string
+	collector := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		postedType = r.Header.Get("Content-Type")
+		posted, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer collector.Close()
+
+	repo := sqlite.NewTLSRptRepository(env.conn.DB)
+	reporter := smtp.NewTLSReporter("mail.example.com", "Example Inc.", "", repo, env.queueRepo, env.blobStore, nil, observability.NewLogger("error", "text"))
+	reporter.HTTPClient = collector.Client()
+	reporter.LookupTXT = func(name string) ([]string, error) {
+		if name == "_smtp._tls.remote.test" {
+			return []string{"v=TLSRPTv1; rua=mailto:tlsrpt@remote.test," + collector.URL + "/report"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	at := dayStart.Add(time.Hour)
+	policy := []string{"version: STSv1", "mode: enforce", "mx: mx.remote.test", "max_age: 86400"}
+	for i := 0; i < 3; i++ {
+		reporter.RecordTLSResult(ctx, domain.TLSSessionResult{
+			PolicyDomain: "remote.test", PolicyType: domain.TLSPolicyTypeSTS, PolicyString: policy,
+			MXHost: "mx.remote.test", SendingIP: "192.0.2.1", ReceivingIP: "198.51.100.7", Success: true, Timestamp: at,
+		})
+	}
+	reporter.RecordTLSResult(ctx, domain.TLSSessionResult{
+		PolicyDomain: "remote.test", PolicyType: domain.TLSPolicyTypeSTS, PolicyString: policy,
+		MXHost: "mx.remote.test", SendingIP: "192.0.2.1", ReceivingIP: "198.51.100.7",
+		ResultType: domain.TLSResultSTARTTLSNotSupported, Timestamp: at,
+	})
+	// A domain without a TLS-RPT record is aggregated but never reported
+	reporter.RecordTLSResult(ctx, domain.TLSSessionResult{
+		PolicyDomain: "quiet.test", PolicyType: domain.TLSPolicyTypeNoPolicy, Success: true, Timestamp: at,
+	})
+
+	require.NoError(t, reporter.SendReports(ctx, dayStart, dayStart.Add(24*time.Hour)))
+
+	// HTTPS delivery: gzipped RFC 8460 JSON
+	mu.Lock()
+	assert.Equal(t, "application/tlsrpt+gzip", postedType)
+	zr, err := gzip.NewReader(bytes.NewReader(posted))
+	mu.Unlock()
+	require.NoError(t, err)
+	var report map[string]interface{}
+	require.NoError(t, json.NewDecoder(zr).Decode(&report))
+	assert.Equal(t, "Example Inc.", report["organization-name"])
+	assert.Equal(t, "postmaster@mail.example.com", report["contact-info"])
+
+	policies := report["policies"].([]interface{})
+	require.Len(t, policies, 1)
+	summary := policies[0].(map[string]interface{})["summary"].(map[string]interface{})
+	assert.EqualValues(t, 3, summary["total-successful-session-count"])
+	assert.EqualValues(t, 1, summary["total-failure-session-count"])
+	details := policies[0].(map[string]interface{})["failure-details"].([]interface{})
+	require.Len(t, details, 1)
+	assert.Equal(t, "starttls-not-supported", details[0].(map[string]interface{})["result-type"])
+	assert.Equal(t, "192.0.2.1", details[0].(map[string]interface{})["sending-mta-ip"])
+
+	// mailto delivery: queued multipart/report with the gzipped attachment
+	queued, err := env.queueRepo.LockNextReady(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, queued)
+	assert.Equal(t, "tlsrpt@remote.test", queued.Recipient)
+	assert.Equal(t, "", queued.Sender)
+	raw, err := env.blobStore.Read(ctx, queued.BlobKey)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "TLS-Report-Domain: remote.test")
+	assert.Contains(t, string(raw), `report-type="tlsrpt"`)
+	assert.Contains(t, string(raw), "Content-Type: application/tlsrpt+gzip")
+	assert.True(t, strings.Contains(string(raw), "filename=\"mail.example.com!remote.test!"))
+
+	next, err := env.queueRepo.LockNextReady(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, next, "only one mailed report expected")
+
+	// Reported sessions are discarded
+	remaining, err := repo.ListSessions(ctx, dayStart, dayStart.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+// TestTLSRPT_KeepsUnacceptedReports verifies that sessions stay recorded until a rua accepts
+// their report, so that a later run can retry
+func TestTLSRPT_KeepsUnacceptedReports(t *testing.T) {
+	env := setupTestEnvironment(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	collector := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer collector.Close()
+
+	repo := sqlite.NewTLSRptRepository(env.conn.DB)
+	reporter := smtp.NewTLSReporter("mail.example.com", "", "", repo, env.queueRepo, env.blobStore, nil, observability.NewLogger("error", "text"))
+	reporter.HTTPClient = collector.Client()
+	reporter.LookupTXT = func(name string) ([]string, error) {
+		if name == "_smtp._tls.remote.test" {
+			return []string{"v=TLSRPTv1; rua=" + collector.URL + "/report"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	dayEnd := dayStart.Add(24 * time.Hour)
+	reporter.RecordTLSResult(ctx, domain.TLSSessionResult{
+		PolicyDomain: "remote.test", PolicyType: domain.TLSPolicyTypeNoPolicy, Success: true, Timestamp: dayStart.Add(time.Hour),
+	})
+
+	require.NoError(t, reporter.SendReports(ctx, dayStart, dayEnd))
+	remaining, err := repo.ListSessions(ctx, dayStart, dayEnd)
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1, "sessions of a rejected report are kept")
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	require.NoError(t, reporter.SendReports(ctx, dayStart, dayEnd))
+	remaining, err = repo.ListSessions(ctx, dayStart, dayEnd)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}